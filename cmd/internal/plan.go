@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/plan"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/desiredmanifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
+	"code.cloudfoundry.org/quarks-utils/pkg/cmd"
+)
+
+const planFailedMessage = "plan command failed."
+
+// planCmd prints the changes a BOSHDeployment would cause
+var planCmd = &cobra.Command{
+	Use:   "plan [flags]",
+	Short: "Shows the changes of a BOSHDeployment before applying it",
+	Long: `Shows the changes of a BOSHDeployment before applying it.
+
+This resolves the manifest and ops files of a BOSHDeployment and compares the
+generated instance groups, QuarksStatefulSets, QuarksJobs, QuarksSecrets and
+services with the ones of the currently deployed desired manifest.
+
+The BOSHDeployment is read from the file given by --bdpl-file, or from the
+cluster by its name. Job templates are not rendered, so BPM configurations are
+only taken from the quarks properties of the manifest.
+`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		deploymentNameFlagViperBind(cmd.Flags())
+		viper.BindPFlag("bdpl-file", cmd.Flags().Lookup("bdpl-file"))
+		viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		log = cmd.Logger()
+		defer log.Sync()
+
+		namespace := viper.GetString("watch-namespace")
+		if len(namespace) == 0 {
+			return errors.Errorf("%s watch-namespace flag is empty.", planFailedMessage)
+		}
+
+		output := viper.GetString("output")
+		if output != "yaml" && output != "json" {
+			return errors.Errorf("%s unsupported output format '%s', expected yaml or json.", planFailedMessage, output)
+		}
+
+		restConfig, err := cmd.KubeConfig(log)
+		if err != nil {
+			return errors.Wrap(err, planFailedMessage)
+		}
+
+		scheme := runtime.NewScheme()
+		if err := controllers.AddToScheme(scheme); err != nil {
+			return errors.Wrap(err, planFailedMessage)
+		}
+		client, err := crc.New(restConfig, crc.Options{Scheme: scheme})
+		if err != nil {
+			return errors.Wrapf(err, "%s Creating the kube client failed.", planFailedMessage)
+		}
+
+		ctx := context.Background()
+		bdpl := &bdv1.BOSHDeployment{}
+		if path := viper.GetString("bdpl-file"); path != "" {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return errors.Wrapf(err, "%s Reading file specified in the bdpl-file flag failed.", planFailedMessage)
+			}
+			if err := yaml.Unmarshal(data, bdpl); err != nil {
+				return errors.Wrapf(err, "%s Loading BOSHDeployment from file failed.", planFailedMessage)
+			}
+		} else {
+			deploymentName, err := deploymentNameFlagValidation()
+			if err != nil {
+				return errors.Wrap(err, planFailedMessage)
+			}
+			err = client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: deploymentName}, bdpl)
+			if err != nil {
+				return errors.Wrapf(err, "%s Getting BOSHDeployment '%s' failed.", planFailedMessage, deploymentName)
+			}
+		}
+		bdpl.Namespace = namespace
+
+		pinned, _, err := withops.PinGitReferences(ctx, client, bdpl)
+		if err != nil {
+			return errors.Wrap(err, planFailedMessage)
+		}
+		resolver := withops.NewResolver(
+			client,
+			func() withops.Interpolator { return withops.NewInterpolator() },
+			func(deploymentName string, m bdm.Manifest) (withops.DomainNameService, error) {
+				return boshdns.NewDNS(deploymentName, m)
+			},
+		)
+		manifest, _, err := resolver.Manifest(pinned, namespace)
+		if err != nil {
+			return errors.Wrapf(err, "%s Resolving the manifest failed.", planFailedMessage)
+		}
+
+		// Nothing is deployed yet, if the desired manifest doesn't exist
+		deployed, err := desiredmanifest.NewDesiredManifest(client).DesiredManifest(ctx, bdpl.Name, namespace)
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			log.Debugf("No deployed manifest found for '%s': %v", bdpl.Name, err)
+			deployed = nil
+		} else if err != nil {
+			return errors.Wrapf(err, "%s Reading the deployed manifest failed.", planFailedMessage)
+		}
+
+		changes, err := plan.Plan(namespace, bdpl.Name, deployed, manifest)
+		if err != nil {
+			return errors.Wrap(err, planFailedMessage)
+		}
+
+		var out []byte
+		if output == "json" {
+			out, err = json.MarshalIndent(changes, "", "  ")
+		} else {
+			out, err = yaml.Marshal(changes)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "%s Marshalling the changes failed.", planFailedMessage)
+		}
+
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+func init() {
+	utilCmd.AddCommand(planCmd)
+
+	pf := planCmd.Flags()
+	argToEnv := map[string]string{}
+
+	deploymentNameFlagCobraSet(pf, argToEnv)
+	pf.StringP("bdpl-file", "f", "", "path to a BOSHDeployment YAML file, instead of reading it from the cluster")
+	pf.StringP("output", "", "yaml", "output format, yaml or json")
+	argToEnv["bdpl-file"] = "BDPL_FILE"
+	argToEnv["output"] = "OUTPUT"
+
+	cmd.AddEnvToUsage(planCmd, argToEnv)
+}
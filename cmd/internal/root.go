@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"io/ioutil"
 	golog "log"
 	"os"
+	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -11,11 +14,15 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	certv1 "k8s.io/api/certificates/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc" // from https://github.com/kubernetes/client-go/issues/345
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarkssecret"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/operator"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/operatorimage"
@@ -39,6 +46,17 @@ func wrapError(err error, msg string) error {
 	return errors.Wrap(err, "cf-operator command failed. "+msg)
 }
 
+// splitList splits a comma separated flag value, ignoring empty items
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "cf-operator",
 	Short: "cf-operator manages BOSH deployments on Kubernetes",
@@ -75,6 +93,42 @@ var rootCmd = &cobra.Command{
 		boshdns.SetBoshDNSDockerImage(viper.GetString("bosh-dns-docker-image"))
 		boshdns.SetClusterDomain(viper.GetString("cluster-domain"))
 
+		backends := quarkssecret.GeneratorBackends{
+			Default:       viper.GetString("credsgen-backend"),
+			ExternalURL:   viper.GetString("credsgen-external-url"),
+			ExternalToken: viper.GetString("credsgen-external-token"),
+		}
+		if caFile := viper.GetString("credsgen-external-ca-file"); caFile != "" {
+			backends.ExternalCACert, err = ioutil.ReadFile(caFile)
+			if err != nil {
+				return wrapError(err, "Couldn't read CA file of external credential store.")
+			}
+		}
+
+		renewalFraction := viper.GetFloat64("certificate-renewal-fraction")
+		if renewalFraction < 0 || renewalFraction > 1 {
+			return wrapError(errors.New("certificate renewal fraction must be between 0 and 1"), "")
+		}
+
+		approvalPolicy := quarkssecret.ApprovalPolicy{
+			Requesters: splitList(viper.GetString("csr-approval-requesters")),
+		}
+		if len(approvalPolicy.Requesters) == 0 {
+			username, err := quarkssecret.ServiceAccountUsername(restConfig)
+			if err != nil {
+				log.Warnf("Denying all cluster signed certificate requests, no requesters are configured: %v", err)
+			} else {
+				approvalPolicy.Requesters = []string{username}
+			}
+		}
+		for _, usage := range splitList(viper.GetString("csr-approval-usages")) {
+			approvalPolicy.Usages = append(approvalPolicy.Usages, certv1.KeyUsage(usage))
+		}
+
+		if _, err := exec.LookPath("git"); err != nil {
+			log.Warnf("Git references of BOSHDeployments can't be resolved, no git binary found: %v", err)
+		}
+
 		log.Infof("Starting cf-operator %s with namespace %s", version.Version, cfg.Namespace)
 		log.Infof("cf-operator docker image: %s", config.GetOperatorDockerImage())
 
@@ -103,7 +157,13 @@ var rootCmd = &cobra.Command{
 			return wrapError(err, "Couldn't apply CRDs.")
 		}
 
-		mgr, err := operator.NewManager(ctx, cfg, restConfig, manager.Options{
+		mgr, err := operator.NewManager(ctx, cfg, controllers.Options{
+			GeneratorBackends:          backends,
+			VMTypesConfigMap:           viper.GetString("vm-types-configmap"),
+			CertificateRenewalFraction: renewalFraction,
+			CopyNamespaces:             splitList(viper.GetString("secret-copy-namespaces")),
+			ApprovalPolicy:             approvalPolicy,
+		}, restConfig, manager.Options{
 			Namespace:          cfg.Namespace,
 			MetricsBindAddress: "0",
 			LeaderElection:     false,
@@ -153,34 +213,61 @@ func init() {
 
 	pf.StringP("bosh-dns-docker-image", "", "coredns/coredns:1.6.3", "The docker image used for emulating bosh DNS (a CoreDNS image)")
 	pf.String("cluster-domain", "cluster.local", "The Kubernetes cluster domain")
+	pf.Float64("certificate-renewal-fraction", quarkssecret.DefaultRenewalFraction, "Fraction of their lifetime after which generated certificates are renewed, 0 disables the renewal")
+	pf.String("csr-approval-requesters", "", "Comma separated users allowed to request cluster signed certificates, defaults to the operator's service account")
+	pf.String("csr-approval-usages", "", "Comma separated key usages allowed for cluster signed certificates, the usages of the QuarksSecret are allowed if empty")
+	pf.String("credsgen-backend", credsgen.InMemoryBackend, "Default backend for generating QuarksSecret credentials, 'in-memory' or 'external'")
+	pf.String("credsgen-external-url", "", "HTTPS URL of the external credential store, enables the 'external' credential generator backend")
+	pf.String("credsgen-external-ca-file", "", "Path to a CA certificate to verify the external credential store")
+	pf.String("credsgen-external-token", "", "Bearer token for the external credential store")
 	pf.Int("max-boshdeployment-workers", 1, "Maximum number of workers concurrently running BOSHDeployment controller")
 	pf.Int("max-quarks-secret-workers", 5, "Maximum number of workers concurrently running QuarksSecret controller")
 	pf.Int("max-quarks-statefulset-workers", 1, "Maximum number of workers concurrently running QuarksStatefulSet controller")
 	pf.StringP("operator-webhook-service-host", "w", "", "Hostname/IP under which the webhook server can be reached from the cluster")
 	pf.StringP("operator-webhook-service-port", "p", "2999", "Port the webhook server listens on")
 	pf.BoolP("operator-webhook-use-service-reference", "x", false, "If true the webhook service is targeted using a service reference instead of a URL")
+	pf.String("secret-copy-namespaces", "", "Comma separated namespaces QuarksSecrets may copy their secrets to")
+	pf.String("vm-types-configmap", "", "Name of a config map in the operator namespace, which maps vm_type names to vm_resources")
 
 	for _, name := range []string{
 		"bosh-dns-docker-image",
 		"cluster-domain",
+		"certificate-renewal-fraction",
+		"csr-approval-requesters",
+		"csr-approval-usages",
+		"credsgen-backend",
+		"credsgen-external-url",
+		"credsgen-external-ca-file",
+		"credsgen-external-token",
 		"max-boshdeployment-workers",
 		"max-quarks-secret-workers",
 		"max-quarks-statefulset-workers",
 		"operator-webhook-service-host",
 		"operator-webhook-service-port",
 		"operator-webhook-use-service-reference",
+		"secret-copy-namespaces",
+		"vm-types-configmap",
 	} {
 		viper.BindPFlag(name, pf.Lookup(name))
 	}
 
 	argToEnv["bosh-dns-docker-image"] = "BOSH_DNS_DOCKER_IMAGE"
 	argToEnv["cluster-domain"] = "CLUSTER_DOMAIN"
+	argToEnv["certificate-renewal-fraction"] = "CERTIFICATE_RENEWAL_FRACTION"
+	argToEnv["csr-approval-requesters"] = "CSR_APPROVAL_REQUESTERS"
+	argToEnv["csr-approval-usages"] = "CSR_APPROVAL_USAGES"
+	argToEnv["credsgen-backend"] = "CREDSGEN_BACKEND"
+	argToEnv["credsgen-external-url"] = "CREDSGEN_EXTERNAL_URL"
+	argToEnv["credsgen-external-ca-file"] = "CREDSGEN_EXTERNAL_CA_FILE"
+	argToEnv["credsgen-external-token"] = "CREDSGEN_EXTERNAL_TOKEN"
 	argToEnv["max-boshdeployment-workers"] = "MAX_BOSHDEPLOYMENT_WORKERS"
 	argToEnv["max-quarks-secret-workers"] = "MAX_QUARKS_SECRET_WORKERS"
 	argToEnv["max-quarks-statefulset-workers"] = "MAX_QUARKS_STATEFULSET_WORKERS"
 	argToEnv["operator-webhook-service-host"] = "CF_OPERATOR_WEBHOOK_SERVICE_HOST"
 	argToEnv["operator-webhook-service-port"] = "CF_OPERATOR_WEBHOOK_SERVICE_PORT"
 	argToEnv["operator-webhook-use-service-reference"] = "CF_OPERATOR_WEBHOOK_USE_SERVICE_REFERENCE"
+	argToEnv["secret-copy-namespaces"] = "SECRET_COPY_NAMESPACES"
+	argToEnv["vm-types-configmap"] = "VM_TYPES_CONFIGMAP"
 
 	// Add env variables to help
 	cmd.AddEnvToUsage(rootCmd, argToEnv)
@@ -1,22 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"regexp"
-	"time"
+	"syscall"
 
-	"code.cloudfoundry.org/quarks-utils/pkg/cmd"
-	"github.com/gonvenience/bunt"
-
-	"github.com/fsnotify/fsnotify"
-	"github.com/hpcloud/tail"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/logs"
+	"code.cloudfoundry.org/quarks-utils/pkg/cmd"
 )
 
-// dataGatherCmd represents the dataGather command
+// tailLogsCmd represents the tail-logs command
 var tailLogsCmd = &cobra.Command{
 	Use:   "tail-logs [flags]",
 	Short: "Tail logs from a pod",
@@ -26,19 +26,36 @@ This will tail all logs under the specified dir.
 The dir can be set using the "-z" flag, or setting
 the LOGS_DIR env variable.
 
+Each line is printed as JSON object, tagged with the
+deployment, instance group, job and file name. The job
+is the name of the sub directory the file is in.
+
+Files are selected by the --include and --exclude globs,
+which match the path relative to the logs dir or the file
+name. Lines not matching the --multiline-pattern are joined
+with the previous line. With --syslog-address the entries
+are forwarded to a syslog endpoint, too, as RFC5424 messages.
+The certificate of a TLS endpoint is verified with the CAs
+of --syslog-ca-file or the system CAs.
 `,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		deploymentNameFlagViperBind(cmd.Flags())
+		instanceGroupFlagViperBind(cmd.Flags())
+		for _, name := range []string{"include", "exclude", "multiline-pattern", "syslog-address", "syslog-transport", "syslog-ca-file"} {
+			viper.BindPFlag(name, cmd.Flags().Lookup(name))
+		}
+	},
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		return TailLogsFromDir()
 	},
 }
 
-// TailLogsFromDir will stream to the pod
-// STDOUT all file logs per line in the
-// following syntax:
-// timestampt, file name, message
+// TailLogsFromDir will stream all file logs under the logs dir
+// as JSON lines to the pod STDOUT and optionally to a
+// syslog endpoint.
 //
-// This only tail logs from files names
-// in the form *.log
+// This only tail logs from files matching the include globs,
+// which are "*.log" by default.
 func TailLogsFromDir() error {
 	log = cmd.Logger()
 	defer log.Sync()
@@ -48,237 +65,79 @@ func TailLogsFromDir() error {
 		return fmt.Errorf("logs directory cannot be empty")
 	}
 
-	if _, err := os.Stat(monitorDir); os.IsNotExist(err) {
-		return err
+	config := logs.Config{
+		Dir:           monitorDir,
+		Deployment:    viper.GetString("deployment-name"),
+		InstanceGroup: viper.GetString("instance-group-name"),
+		Filter: logs.Filter{
+			Include: viper.GetStringSlice("include"),
+			Exclude: viper.GetStringSlice("exclude"),
+		},
 	}
-
-	// Get any existing subDir, so that it
-	// can be added to the watcher.
-	// If no subdirs exists, it will add
-	// the current parent dir, for future watch
-	listDir, err := getSubDirs(monitorDir)
-	if err != nil {
+	if err := config.Filter.Validate(); err != nil {
 		return err
 	}
-
-	// will host all files to be tail
-	fileList := make(chan string)
-	done := make(chan bool)
-
-	// regex for files that should be tailed
-	fileNameRegex := regexp.MustCompile(`(.*log)$`)
-
-	// add all already existing files
-	// to the list of files to be tailed
-	go func() {
-		if err := addExistingFiles(monitorDir, fileNameRegex, fileList); err != nil {
-			log.Error(err)
-		}
-	}()
-
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	defer watcher.Close()
-
-	// Start a Go routine to process watcher
-	// events, which can include:
-	// - new directory added -> needs to be watched
-	// - new file added -> needs to be tailed
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return // events channel was closed
-				}
-
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					info, err := os.Stat(event.Name)
-					switch {
-					case err == nil && info.IsDir():
-						watcher.Add(event.Name)
-
-					case err == nil && !info.IsDir():
-						if isValidFile(event.Name, fileNameRegex) {
-							fileList <- event.Name
-						}
-					}
-				}
-
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return // events channel was closed
-				}
-
-				log.Error(err)
-			}
-		}
-	}()
-
-	// Add watcher for each of the initially
-	// identified subDirs
-	for _, dir := range listDir {
-		if err = watcher.Add(dir); err != nil {
-			log.Error(err)
+	if pattern := viper.GetString("multiline-pattern"); pattern != "" {
+		multiline, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "invalid multiline pattern '%s'", pattern)
 		}
+		config.Multiline = multiline
 	}
 
-	// Start the log tailing to process each file,
-	// either existing or new ones.
-	if err := LogTailors(fileList); err != nil {
-		return err
-	}
-
-	// Block indefinitely
-	<-done
-	return nil
-}
-
-func init() {
-	utilCmd.AddCommand(tailLogsCmd)
-
-	tailLogsCmd.Flags().StringP("logs-dir", "z", "", "a path from where to tail logs")
-	viper.BindPFlag("logs-dir", tailLogsCmd.Flags().Lookup("logs-dir"))
-
-	argToEnv := map[string]string{
-		"logs-dir": "LOGS_DIR",
-	}
-	cmd.AddEnvToUsage(tailLogsCmd, argToEnv)
-}
-
-func addExistingFiles(monitDir string, fileRegex *regexp.Regexp, fileList chan string) error {
-	err := filepath.Walk(monitDir, func(path string, info os.FileInfo, err error) error {
+	sinks := []logs.Sink{logs.NewJSONSink(os.Stdout)}
+	if address := viper.GetString("syslog-address"); address != "" {
+		syslog, err := logs.NewSyslogSink(log, logs.SyslogConfig{
+			Address:   address,
+			Transport: viper.GetString("syslog-transport"),
+			CAFile:    viper.GetString("syslog-ca-file"),
+		})
 		if err != nil {
 			return err
 		}
-
-		if !info.IsDir() && isValidFile(path, fileRegex) {
-			fileList <- path
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return err
+		defer syslog.Close()
+		sinks = append(sinks, syslog)
 	}
 
-	return nil
-}
-
-func getSubDirs(path string) ([]string, error) {
-	var listDirs []string
-	err := filepath.Walk(path,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				listDirs = append(listDirs, path)
-			}
-			return nil
-		})
-	if err != nil {
-		return nil, err
-	}
-
-	return listDirs, nil
-}
-
-// isValidFile returns file names that follow the "*.log"
-// naming convention
-func isValidFile(fileName string, fileRegex *regexp.Regexp) bool {
-	match := fileRegex.FindStringSubmatch(fileName)
-	return len(match) > 0
-}
-
-// LogTailors stream logs per file from a channel
-// into STDOUT of the pod where it runs.
-func LogTailors(files chan string) error {
-	output := make(chan StdOutMsg)
-	errors := make(chan error)
-	done := make(chan bool)
-
-	// Routine for streaming lines into
-	// pod STDOUT
+	// Stop tailing when the pod terminates, so the queued entries are
+	// forwarded to the syslog endpoint when it's closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
-		PrintOutput(output)
-	}()
-
-	// Routine for logging errors
-	go func() {
-		for err := range errors {
-			log.Error(err)
-		}
+		<-signals
+		cancel()
 	}()
 
-	var i = 0
-	for file := range files {
-		go func(fileName string, id int) {
-			t, err := tail.TailFile(fileName, tail.Config{Follow: true})
-			if err != nil {
-				errors <- err
-			}
-
-			for line := range t.Lines {
-				outputMsg := StdOutMsg{
-					Message:   line.Text,
-					Origin:    fileName,
-					Timestamp: line.Time,
-					ID:        id,
-				}
-				output <- outputMsg
-			}
-		}(file, i)
-
-		i++
-	}
-
-	<-done
-	return nil
+	return logs.NewTailer(log, config, sinks...).Run(ctx)
 }
 
-// Force colors to be seen in the pod STDOUT
-// even though it is not a terminal
 func init() {
-	bunt.ColorSetting = bunt.ON
-}
-
-// PrintOutput ensures that all logs send to STDOUT stream
-// will be displayed on an specific way:
-// - an specific color per file logs
-// - added italics font, for the file name
-func PrintOutput(messages chan StdOutMsg) {
-	colors := bunt.RandomTerminalFriendlyColors(64)
-
-	for msg := range messages {
-		idx := msg.ID % len(colors)
-		color := colors[idx]
-
-		line := bunt.Sprintf("%04d/%02d/%02d %02d:%02d:%02d, _%s_, %s",
-			msg.Timestamp.Year(),
-			msg.Timestamp.Month(),
-			msg.Timestamp.Day(),
-			msg.Timestamp.Hour(),
-			msg.Timestamp.Minute(),
-			msg.Timestamp.Second(),
-			msg.Origin,
-			msg.Message,
-		)
+	utilCmd.AddCommand(tailLogsCmd)
 
-		fmt.Println(bunt.Style(line, bunt.Foreground(color)))
+	pf := tailLogsCmd.Flags()
+	argToEnv := map[string]string{
+		"logs-dir": "LOGS_DIR",
 	}
-}
 
-// StdOutMsg serves as an struct
-// where a msg corresponding a file can be store.
-// It also stores a file ID, which will help on assigning
-// an specific color to that file logs, when sending to STDOUT
-type StdOutMsg struct {
-	Timestamp time.Time
-	Origin    string
-	Message   string
-	ID        int
+	pf.StringP("logs-dir", "z", "", "a path from where to tail logs")
+	viper.BindPFlag("logs-dir", pf.Lookup("logs-dir"))
+
+	deploymentNameFlagCobraSet(pf, argToEnv)
+	instanceGroupFlagCobraSet(pf, argToEnv)
+	pf.StringSlice("include", []string{}, "globs of the files to tail (default [*.log])")
+	pf.StringSlice("exclude", []string{}, "globs of the files not to tail")
+	pf.String("multiline-pattern", "", "regular expression matching the first line of a log entry, other lines are joined with the previous one")
+	pf.String("syslog-address", "", "host:port of a syslog endpoint to forward the logs to")
+	pf.String("syslog-transport", logs.SyslogTCP, "transport of the syslog endpoint, tcp, udp or tls")
+	pf.String("syslog-ca-file", "", "PEM file of the CA certificates, which verify the certificate of a tls syslog endpoint (default system CAs)")
+	argToEnv["include"] = "LOGS_INCLUDE"
+	argToEnv["exclude"] = "LOGS_EXCLUDE"
+	argToEnv["multiline-pattern"] = "LOGS_MULTILINE_PATTERN"
+	argToEnv["syslog-address"] = "SYSLOG_ADDRESS"
+	argToEnv["syslog-transport"] = "SYSLOG_TRANSPORT"
+	argToEnv["syslog-ca-file"] = "SYSLOG_CA_FILE"
+
+	cmd.AddEnvToUsage(tailLogsCmd, argToEnv)
 }
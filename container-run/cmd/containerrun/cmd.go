@@ -22,10 +22,14 @@ func NewContainerRunCmd(
 	var postStartCommandArgs []string
 	var postStartConditionCommandName string
 	var postStartConditionCommandArgs []string
+	var postStopCommandName string
+	var rlimits pkg.Rlimits
+	var supervision pkg.Supervision
+	var drain pkg.Drain
 
 	cmd := &cobra.Command{
 		Use:           "container-run",
-		Short:         "Runs a command and a post-start with optional conditions",
+		Short:         "Runs and supervises a command, drains it and runs a post-start with optional conditions and a post-stop",
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
@@ -35,10 +39,14 @@ func NewContainerRunCmd(
 				commandChecker,
 				stdio,
 				args,
+				rlimits,
+				supervision,
+				drain,
 				postStartCommandName,
 				postStartCommandArgs,
 				postStartConditionCommandName,
 				postStartConditionCommandArgs,
+				postStopCommandName,
 			)
 		},
 	}
@@ -47,6 +55,20 @@ func NewContainerRunCmd(
 	cmd.Flags().StringArrayVar(&postStartCommandArgs, "post-start-arg", []string{}, "a post-start command arg")
 	cmd.Flags().StringVar(&postStartConditionCommandName, "post-start-condition-name", "", "the post-start condition command name")
 	cmd.Flags().StringArrayVar(&postStartConditionCommandArgs, "post-start-condition-arg", []string{}, "a post-start condition command arg")
+	cmd.Flags().StringVar(&postStopCommandName, "post-stop-name", "", "the post-stop command name, run after the command exited on termination")
+	cmd.Flags().Uint64Var(&rlimits.OpenFiles, "limit-open-files", 0, "the maximum number of open files of the command (RLIMIT_NOFILE)")
+	cmd.Flags().Uint64Var(&rlimits.Processes, "limit-processes", 0, "the maximum number of processes of the command's user (RLIMIT_NPROC)")
+	cmd.Flags().StringVar(&supervision.RestartPolicy, "restart-policy", pkg.RestartNever, "restart the command if it fails, 'never' or 'on-failure'")
+	cmd.Flags().IntVar(&supervision.MaxRestarts, "max-restarts", 5, "the maximum of consecutive restarts of the command, 0 is unlimited")
+	cmd.Flags().DurationVar(&supervision.Backoff, "restart-backoff", time.Second, "the delay before restarting the command, doubled with every consecutive restart")
+	cmd.Flags().DurationVar(&supervision.MaxBackoff, "restart-max-backoff", time.Minute, "the maximum delay before restarting the command")
+	cmd.Flags().StringVar(&supervision.StatusFile, "status-file", "", "the file the status of the command is written to as JSON")
+	cmd.Flags().StringVar(&supervision.StatusAddress, "status-address", "", "the address of the HTTP endpoint serving the status of the command, e.g. ':8090'")
+	cmd.Flags().StringVar(&drain.Path, "drain", "", "the drain script or a directory of drain scripts, run before the command is signaled to terminate")
+	cmd.Flags().StringVar(&drain.LockFile, "drain-lock", "", "the lock file shared by the containers of a job, so the drain scripts run once per pod")
+	cmd.Flags().DurationVar(&drain.Timeout, "drain-timeout", 0, "the maximum drain time, 0 is unlimited")
+	cmd.Flags().StringVar(&drain.Job, "drain-job", "", "the job of the drain scripts, whose current and desired hashes are compared to pass the job and hash changes")
+	cmd.Flags().StringVar(&drain.HashesDir, "drain-hashes", "", "the directory of the current and desired job hashes files")
 
 	return cmd
 }
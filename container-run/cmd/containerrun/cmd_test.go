@@ -25,10 +25,14 @@ var _ = Describe("NewContainerRunCmd", func() {
 			_ pkg.Checker,
 			_ pkg.Stdio,
 			_ []string,
+			_ pkg.Rlimits,
+			_ pkg.Supervision,
+			_ pkg.Drain,
 			_ string,
 			_ []string,
 			_ string,
 			_ []string,
+			_ string,
 		) error {
 			return expectedErr
 		}
@@ -47,10 +51,14 @@ var _ = Describe("NewContainerRunCmd", func() {
 			_ pkg.Checker,
 			_ pkg.Stdio,
 			_ []string,
+			_ pkg.Rlimits,
+			_ pkg.Supervision,
+			_ pkg.Drain,
 			_ string,
 			_ []string,
 			_ string,
 			_ []string,
+			_ string,
 		) error {
 			return nil
 		}
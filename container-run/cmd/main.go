@@ -4,9 +4,12 @@ import (
 	"os"
 
 	"code.cloudfoundry.org/cf-operator/container-run/cmd/containerrun"
+	pkg "code.cloudfoundry.org/cf-operator/container-run/pkg/containerrun"
 )
 
 func main() {
+	pkg.ExecWithRlimits()
+
 	if err := containerrun.NewDefaultContainerRunCmd().Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -11,16 +11,31 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 const (
 	postStartTimeout   = time.Minute * 15
+	postStopTimeout    = time.Minute * 15
 	conditionSleepTime = time.Second * 3
 )
 
+// forwardedSignals are the signals container-run forwards to its processes.
+// Other signals, like SIGCHLD or the runtime's SIGURG, are not meant for them.
+var forwardedSignals = []os.Signal{
+	syscall.SIGTERM,
+	syscall.SIGINT,
+	syscall.SIGQUIT,
+	syscall.SIGHUP,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
 // CmdRun represents the signature for the top-level Run command.
 type CmdRun func(
 	runner Runner,
@@ -28,10 +43,14 @@ type CmdRun func(
 	commandChecker Checker,
 	stdio Stdio,
 	args []string,
+	rlimits Rlimits,
+	supervision Supervision,
+	drain Drain,
 	postStartCommandName string,
 	postStartCommandArgs []string,
 	postStartConditionCommandName string,
 	postStartConditionCommandArgs []string,
+	postStopCommandName string,
 ) error
 
 // Run implements the logic for the container-run CLI command.
@@ -41,25 +60,49 @@ func Run(
 	commandChecker Checker,
 	stdio Stdio,
 	args []string,
+	rlimits Rlimits,
+	supervision Supervision,
+	drain Drain,
 	postStartCommandName string,
 	postStartCommandArgs []string,
 	postStartConditionCommandName string,
 	postStartConditionCommandArgs []string,
+	postStopCommandName string,
 ) error {
 	if len(args) == 0 {
 		err := fmt.Errorf("a command is required")
 		return &runErr{err}
 	}
+	if err := supervision.Validate(); err != nil {
+		return &runErr{err}
+	}
 
 	done := make(chan struct{}, 1)
 	errors := make(chan error)
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs)
+	signal.Notify(sigs, forwardedSignals...)
+	defer signal.Stop(sigs)
 	processRegistry := NewProcessRegistry()
 
+	status := &statusReporter{file: supervision.StatusFile}
+	if supervision.StatusAddress != "" {
+		server, err := status.serve(supervision.StatusAddress)
+		if err != nil {
+			return &runErr{err}
+		}
+		defer server.Close()
+	}
+
+	// A restarted container drains again when terminated.
+	drainer := NewDrainer(drain, runner, stdio, SleepContext)
+	if err := drainer.Reset(); err != nil {
+		return &runErr{err}
+	}
+
 	command := Command{
-		Name: args[0],
-		Arg:  args[1:],
+		Name:    args[0],
+		Arg:     args[1:],
+		Rlimits: rlimits,
 	}
 	process, err := runner.Run(command, stdio)
 	if err != nil {
@@ -105,22 +148,63 @@ func Run(
 		}
 	}
 
+	supervisor := newSupervisor(supervision, runner, command, stdio, processRegistry, status)
 	go func() {
-		if err := process.Wait(); err != nil {
+		if err := supervisor.supervise(process); err != nil {
 			errors <- err
 			return
 		}
 		done <- struct{}{}
 	}()
 
-	go processRegistry.HandleSignals(sigs, errors)
+	// Terminating container-run stops the supervision and runs the drain
+	// scripts, before the signal is forwarded to the processes. All other
+	// signals are forwarded right away.
+	var drainOnce sync.Once
+	forwardedSigs := make(chan os.Signal, 1)
+	go func() {
+		for sig := range sigs {
+			if isTermination(sig) {
+				supervisor.stop()
+				drainOnce.Do(func() {
+					if err := drainer.Run(); err != nil {
+						drainer.printf("%v", err)
+					}
+				})
+			}
+			forwardedSigs <- sig
+		}
+	}()
+	go processRegistry.HandleSignals(forwardedSigs, errors)
 
+	var result error
 	select {
 	case <-done:
-		return nil
 	case err := <-errors:
-		return &runErr{err}
+		result = &runErr{err}
 	}
+
+	// The post-stop script runs only after the processes exited because
+	// container-run was terminated.
+	if supervisor.isStopping() && postStopCommandName != "" && commandChecker.Check(postStopCommandName) {
+		if err := runPostStop(runner, stdio, postStopCommandName); err != nil && result == nil {
+			result = &runErr{err}
+		}
+	}
+
+	return result
+}
+
+// runPostStop runs the post-stop command and waits for it.
+func runPostStop(runner Runner, stdio Stdio, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), postStopTimeout)
+	defer cancel()
+
+	postStopProcess, err := runner.RunContext(ctx, Command{Name: name}, stdio)
+	if err != nil {
+		return err
+	}
+	return postStopProcess.Wait()
 }
 
 type runErr struct {
@@ -133,8 +217,118 @@ func (e *runErr) Error() string {
 
 // Command represents a command to be run.
 type Command struct {
-	Name string
-	Arg  []string
+	Name    string
+	Arg     []string
+	Rlimits Rlimits
+}
+
+// Rlimits represents the resource limits of a command. Zero values are not applied.
+type Rlimits struct {
+	OpenFiles uint64
+	Processes uint64
+}
+
+// rlimitNames are the names of the BPM limits of the rlimit resources.
+var rlimitNames = map[int]string{
+	unix.RLIMIT_NOFILE: "open_files",
+	unix.RLIMIT_NPROC:  "processes",
+}
+
+// resources maps the limits to their rlimit resources.
+func (r Rlimits) resources() map[int]uint64 {
+	resources := map[int]uint64{}
+	if r.OpenFiles > 0 {
+		resources[unix.RLIMIT_NOFILE] = r.OpenFiles
+	}
+	if r.Processes > 0 {
+		resources[unix.RLIMIT_NPROC] = r.Processes
+	}
+	return resources
+}
+
+// rlimitsExecName is the name container-run is executed with, to set the
+// resource limits of a command before executing it.
+const rlimitsExecName = "container-run-rlimits"
+
+// withRlimits wraps the command in container-run, which sets the resource
+// limits and then executes the command, so the limits are only set for the
+// command and not for container-run or its other commands.
+func withRlimits(cmd *exec.Cmd, rlimits Rlimits) error {
+	if len(rlimits.resources()) == 0 {
+		return nil
+	}
+
+	path, err := exec.LookPath(cmd.Path)
+	if err != nil {
+		return err
+	}
+	cmd.Args = append([]string{
+		rlimitsExecName,
+		strconv.FormatUint(rlimits.OpenFiles, 10),
+		strconv.FormatUint(rlimits.Processes, 10),
+		path,
+	}, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+	return nil
+}
+
+// ExecWithRlimits sets the resource limits and executes the command, if
+// container-run was executed by withRlimits. Otherwise it returns, so it has
+// to be called before anything else in main.
+func ExecWithRlimits() {
+	if len(os.Args) < 5 || os.Args[0] != rlimitsExecName {
+		return
+	}
+
+	err := execWithRlimits(os.Args[1], os.Args[2], os.Args[3], os.Args[4:])
+	fmt.Fprintf(os.Stderr, "container-run: failed to run command with resource limits: %v\n", err)
+	os.Exit(127)
+}
+
+func execWithRlimits(openFiles, processes, path string, args []string) error {
+	var rlimits Rlimits
+	var err error
+	if rlimits.OpenFiles, err = strconv.ParseUint(openFiles, 10, 64); err != nil {
+		return err
+	}
+	if rlimits.Processes, err = strconv.ParseUint(processes, 10, 64); err != nil {
+		return err
+	}
+
+	for resource, value := range rlimits.resources() {
+		if err := setRlimit(resource, value); err != nil {
+			return err
+		}
+	}
+
+	return syscall.Exec(path, args, os.Environ())
+}
+
+// setRlimit sets the soft limit of the resource to the value and raises the
+// hard limit if needed. Raising the hard limit requires CAP_SYS_RESOURCE,
+// without it the soft limit is clamped to the hard limit with a warning.
+func setRlimit(resource int, value uint64) error {
+	name := rlimitNames[resource]
+
+	var previous syscall.Rlimit
+	if err := syscall.Getrlimit(resource, &previous); err != nil {
+		return fmt.Errorf("failed to get %s limit: %v", name, err)
+	}
+	limit := syscall.Rlimit{Cur: value, Max: previous.Max}
+	if value > previous.Max {
+		limit.Max = value
+	}
+
+	err := syscall.Setrlimit(resource, &limit)
+	if err == syscall.EPERM && value > previous.Max {
+		fmt.Fprintf(os.Stderr, "container-run: %s limit %d exceeds the hard limit %d, which can't be raised without CAP_SYS_RESOURCE, using %d instead\n", name, value, previous.Max, previous.Max)
+		limit = syscall.Rlimit{Cur: previous.Max, Max: previous.Max}
+		err = syscall.Setrlimit(resource, &limit)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set %s limit to %d: %v", name, value, err)
+	}
+	return nil
 }
 
 // Runner is the interface that wraps the Run methods.
@@ -158,7 +352,7 @@ func (cr *ContainerRunner) Run(
 	stdio Stdio,
 ) (Process, error) {
 	cmd := exec.Command(command.Name, command.Arg...)
-	return cr.run(cmd, stdio)
+	return cr.run(cmd, stdio, command.Rlimits)
 }
 
 // RunContext runs a command async with a context.
@@ -168,15 +362,23 @@ func (cr *ContainerRunner) RunContext(
 	stdio Stdio,
 ) (Process, error) {
 	cmd := exec.CommandContext(ctx, command.Name, command.Arg...)
-	return cr.run(cmd, stdio)
+	return cr.run(cmd, stdio, command.Rlimits)
 }
 
 func (cr *ContainerRunner) run(
 	cmd *exec.Cmd,
 	stdio Stdio,
+	rlimits Rlimits,
 ) (Process, error) {
 	cmd.Stdout = stdio.Out
 	cmd.Stderr = stdio.Err
+	// Commands run in their own process group, so signals sent to the process
+	// group of container-run, e.g. by dumb-init, reach container-run only,
+	// which forwards them after draining.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := withRlimits(cmd, rlimits); err != nil {
+		return nil, fmt.Errorf("failed to run command: %v", err)
+	}
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("failed to run command: %v", err)
 	}
@@ -321,9 +523,10 @@ func (pr *ProcessRegistry) SignalAll(sig os.Signal) []error {
 
 // HandleSignals handles the signals channel and forwards them to the registered processes.
 func (pr *ProcessRegistry) HandleSignals(sigs <-chan os.Signal, errors chan<- error) {
-	sig := <-sigs
-	for _, err := range pr.SignalAll(sig) {
-		errors <- err
+	for sig := range sigs {
+		for _, err := range pr.SignalAll(sig) {
+			errors <- err
+		}
 	}
 }
 
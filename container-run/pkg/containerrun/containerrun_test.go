@@ -2,11 +2,14 @@ package containerrun_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
@@ -49,7 +52,7 @@ var _ = Describe("Run", func() {
 	})
 
 	It("fails when args is empty", func() {
-		err := Run(nil, nil, nil, stdio, []string{}, "", []string{}, "", []string{})
+		err := Run(nil, nil, nil, stdio, []string{}, Rlimits{}, Supervision{}, Drain{}, "", []string{}, "", []string{}, "")
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal("failed to run container: a command is required"))
 	})
@@ -60,7 +63,7 @@ var _ = Describe("Run", func() {
 			Run(command, stdio).
 			Return(nil, fmt.Errorf(`¯\_(ツ)_/¯`)).
 			Times(1)
-		err := Run(runner, nil, nil, stdio, commandLine, "", []string{}, "", []string{})
+		err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, "", []string{}, "", []string{}, "")
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal(`failed to run container: ¯\_(ツ)_/¯`))
 	})
@@ -80,7 +83,7 @@ var _ = Describe("Run", func() {
 			Run(command, stdio).
 			Return(process, nil).
 			Times(1)
-		err := Run(runner, nil, nil, stdio, commandLine, "", []string{}, "", []string{})
+		err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, "", []string{}, "", []string{}, "")
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal(`failed to run container: ¯\_(ツ)_/¯`))
 	})
@@ -100,7 +103,49 @@ var _ = Describe("Run", func() {
 			Run(command, stdio).
 			Return(process, nil).
 			Times(1)
-		err := Run(runner, nil, nil, stdio, commandLine, "", []string{}, "", []string{})
+		err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, "", []string{}, "", []string{}, "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("doesn't forward signals other than TERM, INT, QUIT, HUP, USR1 and USR2", func() {
+		process := NewMockProcess(ctrl)
+		process.EXPECT().
+			Wait().
+			DoAndReturn(func() error {
+				Expect(syscall.Kill(os.Getpid(), syscall.SIGWINCH)).To(Succeed())
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			}).
+			Times(1)
+		process.EXPECT().
+			Signal(gomock.Any()).
+			Times(0)
+		runner := NewMockRunner(ctrl)
+		runner.EXPECT().
+			Run(command, stdio).
+			Return(process, nil).
+			Times(1)
+		err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, "", []string{}, "", []string{}, "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("passes the resource limits to the command", func() {
+		rlimits := Rlimits{OpenFiles: 65536, Processes: 1024}
+		process := NewMockProcess(ctrl)
+		process.EXPECT().
+			Wait().
+			Return(nil).
+			Times(1)
+		process.EXPECT().
+			Signal(gomock.Any()).
+			Return(nil).
+			AnyTimes()
+		runner := NewMockRunner(ctrl)
+		runner.EXPECT().
+			Run(Command{Name: command.Name, Arg: command.Arg, Rlimits: rlimits}, stdio).
+			Return(process, nil).
+			Times(1)
+		err := Run(runner, nil, nil, stdio, commandLine, rlimits, Supervision{}, Drain{}, "", []string{}, "", []string{}, "")
 		Expect(err).ToNot(HaveOccurred())
 	})
 
@@ -124,10 +169,275 @@ var _ = Describe("Run", func() {
 			Check(postStart.Name).
 			Return(false).
 			Times(1)
-		err := Run(runner, nil, checker, stdio, commandLine, postStart.Name, postStart.Arg, "", []string{})
+		err := Run(runner, nil, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, postStart.Name, postStart.Arg, "", []string{}, "")
 		Expect(err).ToNot(HaveOccurred())
 	})
 
+	Context("With post-stop", func() {
+		postStop := Command{Name: "/var/vcap/jobs/fake-job/bin/post-stop"}
+
+		It("runs the post-stop command after the command exited on termination", func() {
+			wait := make(chan error, 1)
+			process := NewMockProcess(ctrl)
+			process.EXPECT().
+				Wait().
+				DoAndReturn(func() error {
+					// Ginkgo handles SIGTERM itself, SIGQUIT terminates container-run as well.
+					for {
+						Expect(syscall.Kill(os.Getpid(), syscall.SIGQUIT)).To(Succeed())
+						select {
+						case err := <-wait:
+							return err
+						case <-time.After(100 * time.Millisecond):
+						}
+					}
+				}).
+				Times(1)
+			process.EXPECT().
+				Signal(gomock.Any()).
+				DoAndReturn(func(sig os.Signal) error {
+					if sig == syscall.SIGQUIT {
+						select {
+						case wait <- fmt.Errorf("signal: quit"):
+						default:
+						}
+					}
+					return nil
+				}).
+				AnyTimes()
+			runner := NewMockRunner(ctrl)
+			runner.EXPECT().
+				Run(command, stdio).
+				Return(process, nil).
+				Times(1)
+			postStopProcess := NewMockProcess(ctrl)
+			postStopProcess.EXPECT().
+				Wait().
+				Return(nil).
+				Times(1)
+			runner.EXPECT().
+				RunContext(gomock.Any(), postStop, stdio).
+				Return(postStopProcess, nil).
+				Times(1)
+			checker := NewMockChecker(ctrl)
+			checker.EXPECT().
+				Check(postStop.Name).
+				Return(true).
+				Times(1)
+			err := Run(runner, nil, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, "", []string{}, "", []string{}, postStop.Name)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("failed to run container: signal: quit"))
+		})
+
+		It("does not run the post-stop command when the command exits by itself", func() {
+			process := NewMockProcess(ctrl)
+			process.EXPECT().
+				Wait().
+				Return(nil).
+				Times(1)
+			process.EXPECT().
+				Signal(gomock.Any()).
+				Return(nil).
+				AnyTimes()
+			runner := NewMockRunner(ctrl)
+			runner.EXPECT().
+				Run(command, stdio).
+				Return(process, nil).
+				Times(1)
+			checker := NewMockChecker(ctrl)
+			err := Run(runner, nil, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, "", []string{}, "", []string{}, postStop.Name)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("With supervision", func() {
+		supervision := Supervision{
+			RestartPolicy: RestartOnFailure,
+			MaxRestarts:   2,
+			Backoff:       time.Millisecond,
+			MaxBackoff:    time.Second,
+		}
+
+		It("fails when the restart policy is unknown", func() {
+			runner := NewMockRunner(ctrl)
+			err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, Supervision{RestartPolicy: "always"}, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`failed to run container: unknown restart policy 'always'`))
+		})
+
+		It("fails when the restart backoff isn't positive", func() {
+			runner := NewMockRunner(ctrl)
+			withoutBackoff := supervision
+			withoutBackoff.Backoff = 0
+			err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, withoutBackoff, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`failed to run container: restart backoff must be positive, got 0s`))
+		})
+
+		It("fails when the restart max backoff is less than the restart backoff", func() {
+			runner := NewMockRunner(ctrl)
+			withSmallMaxBackoff := supervision
+			withSmallMaxBackoff.MaxBackoff = time.Microsecond
+			err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, withSmallMaxBackoff, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`failed to run container: restart max backoff 1µs must not be less than the restart backoff 1ms`))
+		})
+
+		It("stops waiting for the restart when terminated", func() {
+			process := NewMockProcess(ctrl)
+			process.EXPECT().
+				Wait().
+				DoAndReturn(func() error {
+					// Ginkgo handles SIGTERM itself, SIGQUIT terminates container-run as well.
+					Expect(syscall.Kill(os.Getpid(), syscall.SIGQUIT)).To(Succeed())
+					return fmt.Errorf(`¯\_(ツ)_/¯`)
+				}).
+				Times(1)
+			process.EXPECT().
+				Signal(gomock.Any()).
+				Return(nil).
+				AnyTimes()
+			runner := NewMockRunner(ctrl)
+			runner.EXPECT().
+				Run(command, stdio).
+				Return(process, nil).
+				Times(1)
+
+			withLongBackoff := supervision
+			withLongBackoff.Backoff = time.Hour
+			withLongBackoff.MaxBackoff = time.Hour
+			started := time.Now()
+			err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, withLongBackoff, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`failed to run container: ¯\_(ツ)_/¯`))
+			Expect(time.Since(started)).To(BeNumerically("<", 10*time.Second))
+		})
+
+		It("restarts the command when it fails", func() {
+			failing := NewMockProcess(ctrl)
+			failing.EXPECT().
+				Wait().
+				Return(fmt.Errorf(`¯\_(ツ)_/¯`)).
+				Times(1)
+			succeeding := NewMockProcess(ctrl)
+			succeeding.EXPECT().
+				Wait().
+				Return(nil).
+				Times(1)
+			runner := NewMockRunner(ctrl)
+			gomock.InOrder(
+				runner.EXPECT().
+					Run(command, stdio).
+					Return(failing, nil).
+					Times(1),
+				runner.EXPECT().
+					Run(command, stdio).
+					Return(succeeding, nil).
+					Times(1),
+			)
+			err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, supervision, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("gives up after the maximum of restarts", func() {
+			process := NewMockProcess(ctrl)
+			process.EXPECT().
+				Wait().
+				Return(fmt.Errorf(`¯\_(ツ)_/¯`)).
+				Times(3)
+			runner := NewMockRunner(ctrl)
+			runner.EXPECT().
+				Run(command, stdio).
+				Return(process, nil).
+				Times(3)
+			err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, supervision, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`failed to run container: giving up after 2 restarts: ¯\_(ツ)_/¯`))
+		})
+
+		It("does not restart the command with the 'never' restart policy", func() {
+			process := NewMockProcess(ctrl)
+			process.EXPECT().
+				Wait().
+				Return(fmt.Errorf(`¯\_(ツ)_/¯`)).
+				Times(1)
+			runner := NewMockRunner(ctrl)
+			runner.EXPECT().
+				Run(command, stdio).
+				Return(process, nil).
+				Times(1)
+			err := Run(runner, nil, nil, stdio, commandLine, Rlimits{}, Supervision{RestartPolicy: RestartNever}, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`failed to run container: ¯\_(ツ)_/¯`))
+		})
+
+		It("writes the status to the status file", func() {
+			dir, err := ioutil.TempDir("", "container-run")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			failing := NewMockProcess(ctrl)
+			failing.EXPECT().
+				Wait().
+				Return(fmt.Errorf(`¯\_(ツ)_/¯`)).
+				Times(1)
+			succeeding := NewMockProcess(ctrl)
+			succeeding.EXPECT().
+				Wait().
+				Return(nil).
+				Times(1)
+			runner := NewMockRunner(ctrl)
+			gomock.InOrder(
+				runner.EXPECT().Run(command, stdio).Return(failing, nil),
+				runner.EXPECT().Run(command, stdio).Return(succeeding, nil),
+			)
+
+			withStatusFile := supervision
+			withStatusFile.StatusFile = filepath.Join(dir, "status.json")
+			err = Run(runner, nil, nil, stdio, commandLine, Rlimits{}, withStatusFile, Drain{}, "", []string{}, "", []string{}, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			data, err := ioutil.ReadFile(withStatusFile.StatusFile)
+			Expect(err).ToNot(HaveOccurred())
+			status := Status{}
+			Expect(json.Unmarshal(data, &status)).To(Succeed())
+			Expect(status.State).To(Equal(StateExited))
+			Expect(status.Restarts).To(Equal(1))
+		})
+
+		It("serves the status on the status address", func() {
+			wait := make(chan error)
+			process := NewMockProcess(ctrl)
+			process.EXPECT().
+				Wait().
+				DoAndReturn(func() error { return <-wait }).
+				Times(1)
+			runner := NewMockRunner(ctrl)
+			runner.EXPECT().
+				Run(command, stdio).
+				Return(process, nil).
+				Times(1)
+
+			withStatusAddress := Supervision{StatusAddress: "127.0.0.1:18090"}
+			errors := make(chan error)
+			go func() {
+				errors <- Run(runner, nil, nil, stdio, commandLine, Rlimits{}, withStatusAddress, Drain{}, "", []string{}, "", []string{}, "")
+			}()
+
+			Eventually(func() (int, error) {
+				resp, err := http.Get("http://127.0.0.1:18090/")
+				if err != nil {
+					return 0, err
+				}
+				defer resp.Body.Close()
+				return resp.StatusCode, nil
+			}).Should(Equal(http.StatusOK))
+
+			wait <- nil
+			Expect(<-errors).ToNot(HaveOccurred())
+		})
+	})
+
 	Context("With post-start condition", func() {
 		It("fails when post-start RunContext fails", func() {
 			expectedErr := fmt.Errorf(`¯\_(ツ)_/¯`)
@@ -159,7 +469,7 @@ var _ = Describe("Run", func() {
 				Return(true).
 				Times(1)
 			conditionRunner := NewMockRunner(ctrl)
-			err := Run(runner, conditionRunner, checker, stdio, commandLine, postStart.Name, postStart.Arg, "", []string{})
+			err := Run(runner, conditionRunner, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, postStart.Name, postStart.Arg, "", []string{}, "")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(fmt.Errorf("failed to run container: %v", expectedErr).Error()))
 		})
@@ -203,7 +513,7 @@ var _ = Describe("Run", func() {
 				Return(true).
 				Times(1)
 			conditionRunner := NewMockRunner(ctrl)
-			err := Run(runner, conditionRunner, checker, stdio, commandLine, postStart.Name, postStart.Arg, "", []string{})
+			err := Run(runner, conditionRunner, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, postStart.Name, postStart.Arg, "", []string{}, "")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(fmt.Errorf("failed to run container: %v", expectedErr).Error()))
 		})
@@ -252,7 +562,7 @@ var _ = Describe("Run", func() {
 				Return(true).
 				Times(1)
 			conditionRunner := NewMockRunner(ctrl)
-			err := Run(runner, conditionRunner, checker, stdio, commandLine, postStart.Name, postStart.Arg, "", []string{})
+			err := Run(runner, conditionRunner, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, postStart.Name, postStart.Arg, "", []string{}, "")
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
@@ -286,7 +596,7 @@ var _ = Describe("Run", func() {
 				RunContext(gomock.Any(), postStartCondition, gomock.Any()).
 				Return(nil, expectedErr).
 				Times(1)
-			err := Run(runner, conditionRunner, checker, stdio, commandLine, postStart.Name, postStart.Arg, postStartCondition.Name, postStartCondition.Arg)
+			err := Run(runner, conditionRunner, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, postStart.Name, postStart.Arg, postStartCondition.Name, postStartCondition.Arg, "")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal(fmt.Errorf("failed to run container: %v", expectedErr).Error()))
 		})
@@ -343,7 +653,7 @@ var _ = Describe("Run", func() {
 				}).
 				Return(nil, nil).
 				Times(1)
-			err := Run(runner, conditionRunner, checker, stdio, commandLine, postStart.Name, postStart.Arg, postStartCondition.Name, postStartCondition.Arg)
+			err := Run(runner, conditionRunner, checker, stdio, commandLine, Rlimits{}, Supervision{}, Drain{}, postStart.Name, postStart.Arg, postStartCondition.Name, postStartCondition.Arg, "")
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
@@ -667,6 +977,65 @@ var _ = Describe("ContainerRunner", func() {
 			Expect(err.Error()).To(Equal("failed to run process: exit status 1"))
 		})
 
+		It("applies the resource limits to the command only", func() {
+			var previous syscall.Rlimit
+			Expect(syscall.Getrlimit(syscall.RLIMIT_NOFILE, &previous)).To(Succeed())
+
+			cr := NewContainerRunner()
+			cmd := Command{
+				Name:    "bash",
+				Arg:     []string{"-c", `[ "$(ulimit -n)" = 100 ] && [ "$(ulimit -u)" = 1024 ]`},
+				Rlimits: Rlimits{OpenFiles: 100, Processes: 1024},
+			}
+			stdio := Stdio{
+				Out: ioutil.Discard,
+				Err: ioutil.Discard,
+			}
+			p, err := cr.Run(cmd, stdio)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.Wait()).To(Succeed())
+
+			var current syscall.Rlimit
+			Expect(syscall.Getrlimit(syscall.RLIMIT_NOFILE, &current)).To(Succeed())
+			Expect(current).To(Equal(previous))
+		})
+
+		It("clamps resource limits which exceed the hard limit if it can't be raised", func() {
+			var previous syscall.Rlimit
+			Expect(syscall.Getrlimit(syscall.RLIMIT_NOFILE, &previous)).To(Succeed())
+
+			// The hard limit of open files can't be raised above fs.nr_open,
+			// even with CAP_SYS_RESOURCE
+			stderr, err := ioutil.TempFile("", "stderr")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.Remove(stderr.Name())
+
+			cr := NewContainerRunner()
+			cmd := Command{
+				Name:    "bash",
+				Arg:     []string{"-c", fmt.Sprintf(`[ "$(ulimit -n)" = %d ]`, previous.Max)},
+				Rlimits: Rlimits{OpenFiles: 1 << 40},
+			}
+			p, err := cr.Run(cmd, Stdio{Out: ioutil.Discard, Err: stderr})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.Wait()).To(Succeed())
+
+			output, err := ioutil.ReadFile(stderr.Name())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(output)).To(ContainSubstring(fmt.Sprintf("open_files limit %d exceeds the hard limit %d", uint64(1<<40), previous.Max)))
+		})
+
+		It("fails when the command with resource limits doesn't exist", func() {
+			cr := NewContainerRunner()
+			cmd := Command{
+				Name:    "does-not-exist",
+				Rlimits: Rlimits{OpenFiles: 100},
+			}
+			_, err := cr.Run(cmd, Stdio{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to run command"))
+		})
+
 		It("succeeds", func() {
 			Skip("this test needs to be fixed, it's currently flaky")
 			cr := NewContainerRunner()
@@ -0,0 +1,363 @@
+package containerrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Arguments passed to the drain scripts, see https://bosh.io/docs/drain/.
+const (
+	DrainJobShutdown    = "job_shutdown"
+	DrainJobChanged     = "job_changed"
+	DrainJobUnchanged   = "job_unchanged"
+	DrainJobCheckStatus = "job_check_status"
+	DrainHashChanged    = "hash_changed"
+	DrainHashUnchanged  = "hash_unchanged"
+)
+
+// Files of the job hashes directory.
+const (
+	// CurrentJobHashesFile contains the hashes of the jobs the pod runs.
+	CurrentJobHashesFile = "current"
+	// DesiredJobHashesFile contains the hashes of the jobs the pod is
+	// replaced with. It's empty, unless the pod is updated.
+	DesiredJobHashesFile = "desired"
+)
+
+// drained is written to the lock file once the drain scripts finished.
+const drained = "drained"
+
+// JobHashes identify the release of a job and its rendered spec. Their
+// changes are passed to the drain scripts like BOSH passes the changes of
+// the job and the instance spec.
+type JobHashes struct {
+	Job  string `json:"job"`
+	Spec string `json:"spec"`
+}
+
+// Drain configures the BOSH drain scripts, which run before the processes are
+// signaled to terminate.
+type Drain struct {
+	// Path is the drain script of the job or a directory of drain scripts.
+	Path string
+	// LockFile is shared by all containers of a job, so the drain scripts run
+	// once per pod, and the containers wait for them before signaling their processes.
+	LockFile string
+	// Timeout is the maximum drain time, 0 is unlimited.
+	Timeout time.Duration
+	// Job is the name of the job, whose hashes are compared.
+	Job string
+	// HashesDir contains the JSON encoded JobHashes of all jobs of the pod,
+	// by job name, in the current and the desired hashes files.
+	HashesDir string
+}
+
+// changes returns the job change and the hash change arguments of the drain
+// scripts. The job shuts down, unless the desired hashes of an update
+// contain the job.
+func (d Drain) changes() (string, string, error) {
+	if d.Job == "" || d.HashesDir == "" {
+		return DrainJobShutdown, DrainHashUnchanged, nil
+	}
+
+	current, err := readJobHashes(filepath.Join(d.HashesDir, CurrentJobHashesFile))
+	if err != nil {
+		return DrainJobShutdown, DrainHashUnchanged, err
+	}
+	desired, err := readJobHashes(filepath.Join(d.HashesDir, DesiredJobHashesFile))
+	if err != nil {
+		return DrainJobShutdown, DrainHashUnchanged, err
+	}
+	currentHashes, ok := current[d.Job]
+	if !ok {
+		return DrainJobShutdown, DrainHashUnchanged, nil
+	}
+	desiredHashes, ok := desired[d.Job]
+	if !ok {
+		return DrainJobShutdown, DrainHashUnchanged, nil
+	}
+
+	jobChange := DrainJobUnchanged
+	if currentHashes.Job != desiredHashes.Job {
+		jobChange = DrainJobChanged
+	}
+	hashChange := DrainHashUnchanged
+	if currentHashes.Spec != desiredHashes.Spec {
+		hashChange = DrainHashChanged
+	}
+	return jobChange, hashChange, nil
+}
+
+// readJobHashes reads a job hashes file. A missing or empty file has no hashes.
+func readJobHashes(path string) (map[string]JobHashes, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read job hashes: %v", err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil
+	}
+
+	hashes := map[string]JobHashes{}
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return nil, fmt.Errorf("failed to parse job hashes '%s': %v", path, err)
+	}
+	return hashes, nil
+}
+
+// scripts returns the drain scripts, which exist.
+func (d Drain) scripts() ([]string, error) {
+	if d.Path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(d.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{d.Path}, nil
+	}
+
+	files, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	scripts := []string{}
+	for _, file := range files {
+		if !file.IsDir() {
+			scripts = append(scripts, filepath.Join(d.Path, file.Name()))
+		}
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+// Drainer runs the drain scripts following the BOSH drain protocol.
+type Drainer struct {
+	Drain
+	runner Runner
+	stdio  Stdio
+	sleep  func(context.Context, time.Duration) error
+}
+
+// NewDrainer constructs a new Drainer.
+func NewDrainer(
+	drain Drain,
+	runner Runner,
+	stdio Stdio,
+	sleep func(context.Context, time.Duration) error,
+) *Drainer {
+	return &Drainer{
+		Drain:  drain,
+		runner: runner,
+		stdio:  stdio,
+		sleep:  sleep,
+	}
+}
+
+// Reset clears the drained state of the lock file. It's called when a
+// container starts, so a container restarted after a drain, e.g. by a failed
+// liveness probe, drains again when the pod terminates.
+func (d *Drainer) Reset() error {
+	if d.LockFile == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.LockFile), 0755); err != nil {
+		return fmt.Errorf("failed to create drain lock directory: %v", err)
+	}
+	lock, err := os.OpenFile(d.LockFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open drain lock file: %v", err)
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock drain lock file: %v", err)
+	}
+	defer func() {
+		_ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	}()
+
+	if err := lock.Truncate(0); err != nil {
+		return fmt.Errorf("failed to reset drain lock file: %v", err)
+	}
+	return nil
+}
+
+// Run runs the drain scripts once, even if called by several containers of the same pod.
+func (d *Drainer) Run() error {
+	scripts, err := d.scripts()
+	if err != nil {
+		return fmt.Errorf("failed to find drain scripts: %v", err)
+	}
+	if len(scripts) == 0 {
+		return nil
+	}
+
+	if d.LockFile == "" {
+		return d.runScripts(scripts)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.LockFile), 0755); err != nil {
+		return fmt.Errorf("failed to create drain lock directory: %v", err)
+	}
+	lock, err := os.OpenFile(d.LockFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open drain lock file: %v", err)
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock drain lock file: %v", err)
+	}
+	defer func() {
+		_ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	}()
+
+	state, err := ioutil.ReadAll(lock)
+	if err != nil {
+		return fmt.Errorf("failed to read drain lock file: %v", err)
+	}
+	if string(state) == drained {
+		return nil
+	}
+
+	// Failed drain scripts are not retried by the other containers.
+	err = d.runScripts(scripts)
+	if _, writeErr := lock.WriteString(drained); writeErr != nil && err == nil {
+		err = fmt.Errorf("failed to write drain lock file: %v", writeErr)
+	}
+	return err
+}
+
+// runScripts runs the drain scripts in parallel and waits for all of them
+// within the drain timeout.
+func (d *Drainer) runScripts(scripts []string) error {
+	ctx := context.Background()
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	// Broken job hashes don't prevent draining, the job shuts down instead
+	jobChange, hashChange, err := d.changes()
+	if err != nil {
+		d.printf("%v", err)
+	}
+
+	var wg sync.WaitGroup
+	errors := make([]error, len(scripts))
+	for i, script := range scripts {
+		wg.Add(1)
+		go func(i int, script string) {
+			defer wg.Done()
+			errors[i] = d.runScript(ctx, script, jobChange, hashChange)
+		}(i, script)
+	}
+	wg.Wait()
+
+	messages := []string{}
+	for _, err := range errors {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("failed to drain: %s", strings.Join(messages, ", "))
+	}
+	return nil
+}
+
+// runScript runs a drain script and waits for the time it prints. A negative
+// wait time is dynamic: the script is called again with 'job_check_status'
+// after waiting, until it prints a static wait time.
+func (d *Drainer) runScript(ctx context.Context, script string, jobChange string, hashChange string) error {
+	args := []string{jobChange, hashChange}
+	for {
+		d.printf("running drain script %s %s", script, strings.Join(args, " "))
+
+		out, err := d.output(ctx, Command{Name: script, Arg: args})
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("drain script '%s' timed out after %s", script, d.Timeout)
+			}
+			return fmt.Errorf("drain script '%s': %v", script, err)
+		}
+
+		output := strings.TrimSpace(out)
+		wait, err := strconv.Atoi(output)
+		if err != nil {
+			return fmt.Errorf("drain script '%s' printed an invalid wait time '%s'", script, output)
+		}
+
+		if wait >= 0 {
+			d.printf("waiting %ds static drain time for %s", wait, script)
+			if err := d.sleep(ctx, time.Duration(wait)*time.Second); err != nil {
+				return fmt.Errorf("drain script '%s' timed out after %s", script, d.Timeout)
+			}
+			return nil
+		}
+
+		d.printf("waiting %ds dynamic drain time for %s", -wait, script)
+		if err := d.sleep(ctx, time.Duration(-wait)*time.Second); err != nil {
+			return fmt.Errorf("drain script '%s' timed out after %s", script, d.Timeout)
+		}
+		args = []string{DrainJobCheckStatus, hashChange}
+	}
+}
+
+// output runs the command and returns its output. The output is read from a
+// pipe, since processes are waited for without copying their output.
+func (d *Drainer) output(ctx context.Context, command Command) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	process, err := d.runner.RunContext(ctx, command, Stdio{Out: w, Err: d.stdio.Err})
+	w.Close()
+	if err != nil {
+		return "", err
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if err := process.Wait(); err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func (d *Drainer) printf(format string, a ...interface{}) {
+	if d.stdio.Err != nil {
+		fmt.Fprintf(d.stdio.Err, "container-run: "+format+"\n", a...)
+	}
+}
+
+// SleepContext sleeps for the duration or until the context is done.
+func SleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
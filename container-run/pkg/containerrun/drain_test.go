@@ -0,0 +1,210 @@
+package containerrun_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cf-operator/container-run/pkg/containerrun"
+)
+
+var _ = Describe("Drainer", func() {
+	var (
+		dir    string
+		drain  Drain
+		sleeps []time.Duration
+		mutex  sync.Mutex
+		sleep  func(context.Context, time.Duration) error
+	)
+
+	writeScript := func(name string, script string) string {
+		path := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte("#!/bin/bash\n"+script), 0755)).To(Succeed())
+		return path
+	}
+
+	calls := func() string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "drain")
+		Expect(err).ToNot(HaveOccurred())
+
+		drain = Drain{}
+		sleeps = []time.Duration{}
+		sleep = func(_ context.Context, duration time.Duration) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			sleeps = append(sleeps, duration)
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	act := func() error {
+		return NewDrainer(drain, NewContainerRunner(), Stdio{}, sleep).Run()
+	}
+
+	It("succeeds when the drain script does not exist", func() {
+		drain.Path = filepath.Join(dir, "bin", "drain")
+		Expect(act()).To(Succeed())
+	})
+
+	It("waits for the static drain time", func() {
+		drain.Path = writeScript("bin/drain", fmt.Sprintf(`echo "$@" >> %s/calls; echo 5`, dir))
+
+		Expect(act()).To(Succeed())
+		Expect(calls()).To(Equal("job_shutdown hash_unchanged\n"))
+		Expect(sleeps).To(Equal([]time.Duration{5 * time.Second}))
+	})
+
+	It("calls the drain script again after a dynamic drain time", func() {
+		drain.Path = writeScript("bin/drain", fmt.Sprintf(`
+echo "$@" >> %[1]s/calls
+if [ "$1" == "job_check_status" ]; then echo 0; else echo -3; fi`, dir))
+
+		Expect(act()).To(Succeed())
+		Expect(calls()).To(Equal("job_shutdown hash_unchanged\njob_check_status hash_unchanged\n"))
+		Expect(sleeps).To(Equal([]time.Duration{3 * time.Second, 0}))
+	})
+
+	Context("with job hashes", func() {
+		writeHashes := func(file string, hashes string) {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "hashes", file), []byte(hashes), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			drain.Path = writeScript("bin/drain", fmt.Sprintf(`
+echo "$@" >> %[1]s/calls
+if [ "$1" == "job_check_status" ]; then echo 0; else echo -3; fi`, dir))
+			drain.Job = "redis"
+			drain.HashesDir = filepath.Join(dir, "hashes")
+			Expect(os.MkdirAll(drain.HashesDir, 0755)).To(Succeed())
+			writeHashes(CurrentJobHashesFile, `{"redis":{"job":"a","spec":"b"},"sentinel":{"job":"c","spec":"d"}}`)
+		})
+
+		It("shuts down the job without desired hashes", func() {
+			writeHashes(DesiredJobHashesFile, "")
+
+			Expect(act()).To(Succeed())
+			Expect(calls()).To(HavePrefix("job_shutdown hash_unchanged\n"))
+		})
+
+		It("shuts down the job if the update removes it", func() {
+			writeHashes(DesiredJobHashesFile, `{"sentinel":{"job":"c","spec":"d"}}`)
+
+			Expect(act()).To(Succeed())
+			Expect(calls()).To(HavePrefix("job_shutdown hash_unchanged\n"))
+		})
+
+		It("passes the unchanged job and spec if the update changes other jobs", func() {
+			writeHashes(DesiredJobHashesFile, `{"redis":{"job":"a","spec":"b"},"sentinel":{"job":"e","spec":"f"}}`)
+
+			Expect(act()).To(Succeed())
+			Expect(calls()).To(Equal("job_unchanged hash_unchanged\njob_check_status hash_unchanged\n"))
+		})
+
+		It("passes the changed spec if the update changes the properties of the job", func() {
+			writeHashes(DesiredJobHashesFile, `{"redis":{"job":"a","spec":"e"}}`)
+
+			Expect(act()).To(Succeed())
+			Expect(calls()).To(Equal("job_unchanged hash_changed\njob_check_status hash_changed\n"))
+		})
+
+		It("passes the changed job and spec if the update changes the release of the job", func() {
+			writeHashes(DesiredJobHashesFile, `{"redis":{"job":"e","spec":"f"}}`)
+
+			Expect(act()).To(Succeed())
+			Expect(calls()).To(Equal("job_changed hash_changed\njob_check_status hash_changed\n"))
+		})
+
+		It("shuts down the job if the hashes can't be parsed", func() {
+			writeHashes(DesiredJobHashesFile, "{")
+
+			Expect(act()).To(Succeed())
+			Expect(calls()).To(HavePrefix("job_shutdown hash_unchanged\n"))
+		})
+	})
+
+	It("runs all drain scripts of a directory", func() {
+		drain.Path = filepath.Join(dir, "bin", "drain")
+		writeScript("bin/drain/a", fmt.Sprintf(`echo a >> %s/calls; echo 1`, dir))
+		writeScript("bin/drain/b", fmt.Sprintf(`echo b >> %s/calls; echo 1`, dir))
+
+		Expect(act()).To(Succeed())
+		Expect(calls()).To(ContainSubstring("a\n"))
+		Expect(calls()).To(ContainSubstring("b\n"))
+	})
+
+	It("runs the drain script once for all containers sharing the lock file", func() {
+		drain.Path = writeScript("bin/drain", fmt.Sprintf(`echo drain >> %s/calls; echo 0`, dir))
+		drain.LockFile = filepath.Join(dir, "run", "drain.lock")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(act()).To(Succeed())
+			}()
+		}
+		wg.Wait()
+
+		Expect(calls()).To(Equal("drain\n"))
+	})
+
+	It("drains again after the lock file was reset by a restarted container", func() {
+		drain.Path = writeScript("bin/drain", fmt.Sprintf(`echo drain >> %s/calls; echo 0`, dir))
+		drain.LockFile = filepath.Join(dir, "run", "drain.lock")
+
+		Expect(act()).To(Succeed())
+		Expect(act()).To(Succeed())
+		Expect(calls()).To(Equal("drain\n"))
+
+		Expect(NewDrainer(drain, NewContainerRunner(), Stdio{}, sleep).Reset()).To(Succeed())
+		Expect(act()).To(Succeed())
+		Expect(calls()).To(Equal("drain\ndrain\n"))
+	})
+
+	It("fails when the drain script fails", func() {
+		drain.Path = writeScript("bin/drain", `exit 1`)
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("failed to drain: drain script '%s'", drain.Path))
+	})
+
+	It("fails when the drain script prints an invalid wait time", func() {
+		drain.Path = writeScript("bin/drain", `echo done`)
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(fmt.Sprintf("failed to drain: drain script '%s' printed an invalid wait time 'done'", drain.Path)))
+	})
+
+	It("fails when the drain time exceeds the timeout", func() {
+		drain.Path = writeScript("bin/drain", `echo 60`)
+		drain.Timeout = 10 * time.Millisecond
+		sleep = SleepContext
+
+		err := act()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(fmt.Sprintf("failed to drain: drain script '%s' timed out after 10ms", drain.Path)))
+	})
+})
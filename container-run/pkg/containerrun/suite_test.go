@@ -1,12 +1,22 @@
 package containerrun_test
 
 import (
+	"os"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-operator/container-run/pkg/containerrun"
 )
 
+// TestMain lets the test binary set the resource limits of commands, like
+// container-run does.
+func TestMain(m *testing.M) {
+	containerrun.ExecWithRlimits()
+	os.Exit(m.Run())
+}
+
 func TestContainerrun(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "ContainerRun Suite")
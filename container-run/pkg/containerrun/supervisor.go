@@ -0,0 +1,263 @@
+package containerrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Restart policies of the supervised main process.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+)
+
+// States of the supervised main process.
+const (
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	StateFailed     = "failed"
+	StateExited     = "exited"
+)
+
+// Supervision configures the supervision of the main process.
+type Supervision struct {
+	// RestartPolicy is either RestartNever or RestartOnFailure.
+	RestartPolicy string
+	// MaxRestarts is the number of consecutive restarts before giving up, 0 is unlimited.
+	MaxRestarts int
+	// Backoff is the delay before the first restart, it doubles with every consecutive restart.
+	Backoff time.Duration
+	// MaxBackoff caps the delay. A process running longer than MaxBackoff
+	// resets the delay and the consecutive restarts.
+	MaxBackoff time.Duration
+	// StatusFile is the path the status is written to as JSON, if set.
+	StatusFile string
+	// StatusAddress is the address of the HTTP status endpoint, if set.
+	StatusAddress string
+}
+
+// Validate checks the restart policy and, if the process is restarted, the
+// backoff, so a failing process isn't restarted in a tight loop.
+func (s Supervision) Validate() error {
+	switch s.RestartPolicy {
+	case "", RestartNever:
+		return nil
+	case RestartOnFailure:
+	default:
+		return fmt.Errorf("unknown restart policy '%s'", s.RestartPolicy)
+	}
+
+	if s.Backoff <= 0 {
+		return fmt.Errorf("restart backoff must be positive, got %s", s.Backoff)
+	}
+	if s.MaxBackoff < s.Backoff {
+		return fmt.Errorf("restart max backoff %s must not be less than the restart backoff %s", s.MaxBackoff, s.Backoff)
+	}
+	return nil
+}
+
+// Status is the status of the supervised main process.
+type Status struct {
+	State     string    `json:"state"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"lastError,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// statusReporter publishes the status to the status file and serves it on
+// the HTTP endpoint. The endpoint responds with 200 while the process is
+// running and with 503 otherwise, so it can be used for readiness probes.
+type statusReporter struct {
+	sync.Mutex
+	status Status
+	file   string
+}
+
+func (r *statusReporter) set(state string, restarts int, err error) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.status = Status{
+		State:    state,
+		Restarts: restarts,
+		Since:    time.Now(),
+	}
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+	if r.file == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r.status)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so readers never see a partial status.
+	tmp, err := ioutil.TempFile(filepath.Dir(r.file), filepath.Base(r.file))
+	if err != nil {
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), r.file); err != nil {
+		return fmt.Errorf("failed to write status file: %v", err)
+	}
+	return nil
+}
+
+// ServeHTTP writes the status as JSON.
+func (r *statusReporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.Lock()
+	status := r.status
+	r.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.State != StateRunning {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// serve starts the HTTP status endpoint.
+func (r *statusReporter) serve(address string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on status address: %v", err)
+	}
+	server := &http.Server{Handler: r}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return server, nil
+}
+
+// supervisor waits for the main process and restarts it according to the
+// supervision settings.
+type supervisor struct {
+	Supervision
+	runner   Runner
+	command  Command
+	stdio    Stdio
+	registry *ProcessRegistry
+	status   *statusReporter
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func newSupervisor(supervision Supervision, runner Runner, command Command, stdio Stdio, registry *ProcessRegistry, status *statusReporter) *supervisor {
+	return &supervisor{
+		Supervision: supervision,
+		runner:      runner,
+		command:     command,
+		stdio:       stdio,
+		registry:    registry,
+		status:      status,
+		stopped:     make(chan struct{}),
+	}
+}
+
+// stop prevents further restarts and interrupts a pending restart, it's
+// called when container-run is terminated.
+func (s *supervisor) stop() {
+	s.stopOnce.Do(func() { close(s.stopped) })
+}
+
+func (s *supervisor) isStopping() bool {
+	select {
+	case <-s.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *supervisor) setStatus(state string, restarts int, err error) {
+	if err := s.status.set(state, restarts, err); err != nil && s.stdio.Err != nil {
+		fmt.Fprintf(s.stdio.Err, "container-run: %v\n", err)
+	}
+}
+
+// supervise waits for the process. Failed processes are restarted with an
+// exponential backoff, unless the restart policy is RestartNever, the maximum
+// of consecutive restarts is reached or container-run is terminated.
+func (s *supervisor) supervise(process Process) error {
+	restarts := 0
+	consecutive := 0
+	backoff := s.Backoff
+
+	for {
+		s.setStatus(StateRunning, restarts, nil)
+		started := time.Now()
+
+		err := process.Wait()
+		if err == nil {
+			s.setStatus(StateExited, restarts, nil)
+			return nil
+		}
+		if s.RestartPolicy != RestartOnFailure || s.isStopping() {
+			s.setStatus(StateFailed, restarts, err)
+			return err
+		}
+
+		if time.Since(started) > s.MaxBackoff {
+			consecutive = 0
+			backoff = s.Backoff
+		}
+		if s.MaxRestarts > 0 && consecutive >= s.MaxRestarts {
+			s.setStatus(StateFailed, restarts, err)
+			return fmt.Errorf("giving up after %d restarts: %v", consecutive, err)
+		}
+
+		s.setStatus(StateRestarting, restarts, err)
+		if s.stdio.Err != nil {
+			fmt.Fprintf(s.stdio.Err, "container-run: restarting in %s: %v\n", backoff, err)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-s.stopped:
+			timer.Stop()
+			s.setStatus(StateFailed, restarts, err)
+			return err
+		}
+
+		process, err = s.runner.Run(s.command, s.stdio)
+		if err != nil {
+			s.setStatus(StateFailed, restarts, err)
+			return err
+		}
+		s.registry.Register(process)
+
+		restarts++
+		consecutive++
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// isTermination returns true for signals, which terminate container-run.
+func isTermination(sig os.Signal) bool {
+	switch sig {
+	case syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT:
+		return true
+	}
+	return false
+}
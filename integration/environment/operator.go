@@ -16,6 +16,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarkssecret"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/operator"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/operatorimage"
 	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
@@ -102,7 +104,9 @@ func (e *Environment) setupCFOperator() (manager.Manager, error) {
 
 	ctx := e.SetupLoggerContext("cf-operator-tests")
 
-	mgr, err := operator.NewManager(ctx, e.Config, e.KubeConfig, manager.Options{
+	mgr, err := operator.NewManager(ctx, e.Config, controllers.Options{
+		CertificateRenewalFraction: quarkssecret.DefaultRenewalFraction,
+	}, e.KubeConfig, manager.Options{
 		Namespace:          e.Namespace,
 		MetricsBindAddress: "0",
 		LeaderElection:     false,
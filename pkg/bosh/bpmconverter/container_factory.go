@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -32,6 +33,8 @@ const (
 
 	// EnvLogsDir is the path from where to tail file logs.
 	EnvLogsDir = "LOGS_DIR"
+
+	logsTailerContainerName = "logs"
 )
 
 // ContainerFactoryImpl is a concrete implementation of ContainerFactor.
@@ -157,6 +160,54 @@ func (c *ContainerFactoryImpl) JobsToInitContainers(
 	return initContainers, nil
 }
 
+// JobsToPostDeployContainers creates the init containers and the containers of
+// the pod, which runs the BOSH post-deploy scripts of the jobs.
+func (c *ContainerFactoryImpl) JobsToPostDeployContainers(
+	jobs []bdm.Job,
+	defaultVolumeMounts []corev1.VolumeMount,
+) ([]corev1.Container, []corev1.Container, error) {
+	copyingSpecsInitContainers := make([]corev1.Container, 0)
+	postDeployContainers := make([]corev1.Container, 0)
+
+	copyingSpecsUniq := map[string]struct{}{}
+	for _, job := range jobs {
+		jobImage, err := c.releaseImageProvider.GetReleaseImage(c.instanceGroupName, job.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// One copying specs init container for each release.
+		if _, done := copyingSpecsUniq[job.Release]; !done {
+			copyingSpecsUniq[job.Release] = struct{}{}
+			copyingSpecsInitContainer := JobSpecCopierContainer(job.Release, jobImage, VolumeRenderingDataName)
+			copyingSpecsInitContainers = append(copyingSpecsInitContainers, copyingSpecsInitContainer)
+		}
+
+		postDeployContainer := boshPostDeployContainer(
+			job.Name,
+			jobImage,
+			defaultVolumeMounts,
+			job.Properties.Quarks.Run.SecurityContext.DeepCopy(),
+		)
+		postDeployContainers = append(postDeployContainers, *postDeployContainer.DeepCopy())
+	}
+
+	resolvedPropertiesSecretName := names.InstanceGroupSecretName(
+		names.DeploymentSecretTypeInstanceGroupResolvedProperties, // ig-resolved
+		c.deploymentName,
+		c.instanceGroupName,
+		c.version,
+	)
+
+	initContainers := flattenContainers(
+		copyingSpecsInitContainers,
+		templateRenderingContainer(c.deploymentName, c.instanceGroupName, resolvedPropertiesSecretName),
+		createDirContainer(jobs),
+	)
+
+	return initContainers, postDeployContainers, nil
+}
+
 func createWaitContainer(requiredService *string) []corev1.Container {
 	if requiredService == nil {
 		return nil
@@ -209,6 +260,11 @@ func (c *ContainerFactoryImpl) JobsToContainers(
 			persistentDiskMount = persistentDiskDisks[0].VolumeMount
 		}
 
+		processRestart, err := jobRestart(job)
+		if err != nil {
+			return nil, err
+		}
+
 		for processIndex, process := range bpmConfig.Processes {
 			processDisks := jobDisks.Filter("process_name", process.Name)
 			bpmVolumeMounts := make([]corev1.VolumeMount, 0)
@@ -223,9 +279,10 @@ func (c *ContainerFactoryImpl) JobsToContainers(
 				processVolumeMounts = append(processVolumeMounts, *persistentDiskMount)
 			}
 
-			// The post-start script should be executed only once per job, so we set it up in the first
-			// process container.
+			// The post-start and post-stop scripts should be executed only once per job, so we set them
+			// up in the first process container.
 			var postStart postStart
+			var postStop *containerrun.Command
 			if processIndex == 0 {
 				conditionProperty := job.Properties.Quarks.PostStart.Condition
 				if conditionProperty != nil && conditionProperty.Exec != nil && len(conditionProperty.Exec.Command) > 0 {
@@ -238,6 +295,9 @@ func (c *ContainerFactoryImpl) JobsToContainers(
 				postStart.command = &containerrun.Command{
 					Name: filepath.Join(VolumeJobsDirMountPath, job.Name, "bin", "post-start"),
 				}
+				postStop = &containerrun.Command{
+					Name: filepath.Join(VolumeJobsDirMountPath, job.Name, "bin", "post-stop"),
+				}
 			}
 
 			container := bpmProcessContainer(
@@ -250,6 +310,9 @@ func (c *ContainerFactoryImpl) JobsToContainers(
 				job.Properties.Quarks.Envs,
 				job.Properties.Quarks.Run.SecurityContext.DeepCopy(),
 				postStart,
+				postStop,
+				jobDrain(job),
+				processRestart,
 			)
 
 			containers = append(containers, *container.DeepCopy())
@@ -260,7 +323,7 @@ func (c *ContainerFactoryImpl) JobsToContainers(
 	// appending the sidecar, default behaviour is to
 	// colocate it always in the pod.
 	if !c.disableLogSidecar {
-		logsTailer := logsTailerContainer(c.instanceGroupName)
+		logsTailer := logsTailerContainer(c.deploymentName, c.instanceGroupName)
 		containers = append(containers, logsTailer)
 	}
 
@@ -268,9 +331,9 @@ func (c *ContainerFactoryImpl) JobsToContainers(
 }
 
 // logsTailerContainer is a container that tails all logs in /var/vcap/sys/log.
-func logsTailerContainer(instanceGroupName string) corev1.Container {
+func logsTailerContainer(deploymentName string, instanceGroupName string) corev1.Container {
 	return corev1.Container{
-		Name:            "logs",
+		Name:            logsTailerContainerName,
 		Image:           operatorimage.GetOperatorDockerImage(),
 		ImagePullPolicy: operatorimage.GetOperatorImagePullPolicy(),
 		VolumeMounts:    []corev1.VolumeMount{*sysDirVolumeMount()},
@@ -283,6 +346,14 @@ func logsTailerContainer(instanceGroupName string) corev1.Container {
 				Name:  EnvLogsDir,
 				Value: "/var/vcap/sys/log",
 			},
+			{
+				Name:  EnvDeploymentName,
+				Value: deploymentName,
+			},
+			{
+				Name:  EnvInstanceGroupName,
+				Value: instanceGroupName,
+			},
 		},
 		SecurityContext: &corev1.SecurityContext{
 			RunAsUser: &rootUserID,
@@ -444,6 +515,33 @@ func boshPreStartInitContainer(
 	}
 }
 
+func boshPostDeployContainer(
+	jobName string,
+	jobImage string,
+	volumeMounts []corev1.VolumeMount,
+	securityContext *corev1.SecurityContext,
+) corev1.Container {
+	boshPostDeploy := filepath.Join(VolumeJobsDirMountPath, jobName, "bin", "post-deploy")
+
+	if securityContext == nil {
+		securityContext = &corev1.SecurityContext{}
+	}
+	securityContext.RunAsUser = &rootUserID
+
+	return corev1.Container{
+		Name:         names.Sanitize(fmt.Sprintf("bosh-post-deploy-%s", jobName)),
+		Image:        jobImage,
+		VolumeMounts: deduplicateVolumeMounts(volumeMounts),
+		Command:      entrypoint,
+		Args: []string{
+			"/bin/sh",
+			"-xc",
+			fmt.Sprintf(`if [ -x "%[1]s" ]; then "%[1]s"; fi`, boshPostDeploy),
+		},
+		SecurityContext: securityContext,
+	}
+}
+
 func bpmPreStartInitContainer(
 	process bpm.Process,
 	jobImage string,
@@ -489,6 +587,30 @@ type postStart struct {
 	command, condition *containerrun.Command
 }
 
+// restart configures how container-run restarts the failed processes of a job
+type restart struct {
+	policy      string
+	maxRestarts *int
+}
+
+// jobRestart returns the restart policy of the job's quarks properties. By
+// default container-run doesn't restart failed processes and the container
+// is restarted instead.
+func jobRestart(job bdm.Job) (restart, error) {
+	quarks := job.Properties.Quarks
+	switch quarks.RestartPolicy {
+	case "", containerrun.RestartNever, containerrun.RestartOnFailure:
+	default:
+		return restart{}, errors.Errorf("invalid restart policy '%s' of job '%s', expected '%s' or '%s'",
+			quarks.RestartPolicy, job.Name, containerrun.RestartNever, containerrun.RestartOnFailure)
+	}
+	if quarks.MaxRestarts != nil && *quarks.MaxRestarts < 0 {
+		return restart{}, errors.Errorf("max restarts of job '%s' must not be negative, got %d", job.Name, *quarks.MaxRestarts)
+	}
+
+	return restart{policy: quarks.RestartPolicy, maxRestarts: quarks.MaxRestarts}, nil
+}
+
 func bpmProcessContainer(
 	jobName string,
 	processName string,
@@ -499,6 +621,9 @@ func bpmProcessContainer(
 	quarksEnvs []corev1.EnvVar,
 	securityContext *corev1.SecurityContext,
 	postStart postStart,
+	postStop *containerrun.Command,
+	drain containerrun.Drain,
+	restart restart,
 ) corev1.Container {
 	name := names.Sanitize(fmt.Sprintf("%s-%s", jobName, processName))
 
@@ -521,7 +646,7 @@ func bpmProcessContainer(
 	if workdir == "" {
 		workdir = filepath.Join(VolumeJobsDirMountPath, jobName)
 	}
-	command, args := generateBPMCommand(&process, postStart)
+	command, args := generateBPMCommand(&process, postStart, postStop, drain, restart)
 	limits := corev1.ResourceList{}
 	if process.Limits.Memory != "" {
 		quantity, err := resource.ParseQuantity(process.Limits.Memory)
@@ -540,54 +665,12 @@ func bpmProcessContainer(
 		Env:             process.NewEnvs(quarksEnvs),
 		WorkingDir:      workdir,
 		SecurityContext: securityContext,
-		Lifecycle:       &corev1.Lifecycle{},
 		Resources: corev1.ResourceRequirements{
 			Requests: process.Requests,
 			Limits:   limits,
 		},
 	}
 
-	// Setup the job drain script handler.
-	drainGlob := filepath.Join(VolumeJobsDirMountPath, jobName, "bin", "drain", "*")
-	container.Lifecycle.PreStop = &corev1.Handler{
-		Exec: &corev1.ExecAction{
-			Command: []string{
-				"/bin/sh",
-				"-c",
-				`
-shopt -s nullglob
-for s in ` + drainGlob + `; do
-	(
-		echo "Running drain script $s"
-		while true; do
-			out=$($s)
-			status=$?
-
-			if [ "$status" -ne "0" ]; then
-				echo "$s FAILED with exit code $status"
-				exit $status
-			fi
-
-			if [ "$out" -lt "0" ]; then
-				echo "Sleeping dynamic draining wait time for $s..."
-				sleep ${out:1}
-				echo "Running $s again"
-			else
-				echo "Sleeping static draining wait time for $s..."
-				sleep $out
-				echo "$s done"
-				exit 0
-			fi
-		done
-	)&
-done
-echo "Waiting for subprocesses to finish..."
-wait
-echo "Done"`,
-			},
-		},
-	}
-
 	for name, hc := range healthchecks {
 		if name == process.Name {
 			if hc.ReadinessProbe != nil {
@@ -639,9 +722,20 @@ func flattenContainers(containers ...interface{}) []corev1.Container {
 func generateBPMCommand(
 	process *bpm.Process,
 	postStart postStart,
+	postStop *containerrun.Command,
+	drain containerrun.Drain,
+	restart restart,
 ) ([]string, []string) {
 	command := []string{"/usr/bin/dumb-init", "--"}
 	args := []string{fmt.Sprintf("%s/container-run/container-run", VolumeRenderingDataMountPath)}
+	// Like monit, container-run can restart failed processes, instead of
+	// restarting the whole container
+	if restart.policy == containerrun.RestartOnFailure {
+		args = append(args, "--restart-policy", restart.policy)
+		if restart.maxRestarts != nil {
+			args = append(args, "--max-restarts", strconv.Itoa(*restart.maxRestarts))
+		}
+	}
 	if postStart.command != nil {
 		args = append(args, "--post-start-name", postStart.command.Name)
 		if postStart.condition != nil {
@@ -651,6 +745,20 @@ func generateBPMCommand(
 			}
 		}
 	}
+	if postStop != nil {
+		args = append(args, "--post-stop-name", postStop.Name)
+	}
+	if process.Limits.OpenFiles > 0 {
+		args = append(args, "--limit-open-files", strconv.Itoa(process.Limits.OpenFiles))
+	}
+	if process.Limits.Processes > 0 {
+		args = append(args, "--limit-processes", strconv.Itoa(process.Limits.Processes))
+	}
+	args = append(args, "--drain", drain.Path, "--drain-lock", drain.LockFile)
+	if drain.Timeout > 0 {
+		args = append(args, "--drain-timeout", drain.Timeout.String())
+	}
+	args = append(args, "--drain-job", drain.Job, "--drain-hashes", drain.HashesDir)
 	args = append(args, "--")
 	args = append(args, process.Executable)
 	args = append(args, process.Args...)
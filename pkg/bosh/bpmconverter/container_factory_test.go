@@ -407,6 +407,45 @@ var _ = Describe("ContainerFactory", func() {
 			Expect(containers[0].Resources.Limits.Memory().String()).To(Equal("5G"))
 		})
 
+		It("passes open_files and processes limits from bpm config to container-run", func() {
+			jobs = []bdm.Job{
+				{Name: "fake-job"},
+			}
+
+			bpmConfigs["fake-job"] = bpm.Config{
+				Processes: []bpm.Process{
+					{
+						Name:       "fake-job",
+						Executable: "/var/vcap/packages/fake-job/bin/fake-job",
+						Limits:     bpm.Limits{OpenFiles: 100000, Processes: 1024},
+					},
+				},
+			}
+			containers, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(containers[0].Args).To(Equal([]string{
+				"/var/vcap/all-releases/container-run/container-run",
+				"--post-start-name",
+				"/var/vcap/jobs/fake-job/bin/post-start",
+				"--post-stop-name",
+				"/var/vcap/jobs/fake-job/bin/post-stop",
+				"--limit-open-files",
+				"100000",
+				"--limit-processes",
+				"1024",
+				"--drain",
+				"/var/vcap/jobs/fake-job/bin/drain",
+				"--drain-lock",
+				"/var/vcap/sys/run/container-run/fake-job-drain.lock",
+				"--drain-job",
+				"fake-job",
+				"--drain-hashes",
+				"/var/run/job-hashes",
+				"--",
+				"/var/vcap/packages/fake-job/bin/fake-job",
+			}))
+		})
+
 		It("doesn't add invalid k8s resource limits from bpm config", func() {
 			jobs = []bdm.Job{
 				{Name: "fake-job"},
@@ -432,17 +471,108 @@ var _ = Describe("ContainerFactory", func() {
 		})
 
 		Context("with lifecycle events", func() {
-			It("creates a preStop handler per job", func() {
+			It("lets container-run drain each job", func() {
+				containers, err := act()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(containers[0].Lifecycle).To(BeNil())
+				Expect(containers[0].Args).To(ContainElements(
+					"--drain", "/var/vcap/jobs/fake-job/bin/drain",
+					"--drain-lock", "/var/vcap/sys/run/container-run/fake-job-drain.lock",
+					"--drain-job", "fake-job",
+					"--drain-hashes", "/var/run/job-hashes",
+				))
+				Expect(containers[0].Args).ToNot(ContainElement("--drain-timeout"))
+
+				Expect(containers[1].Lifecycle).To(BeNil())
+				Expect(containers[1].Args).To(ContainElements(
+					"--drain", "/var/vcap/jobs/other-job/bin/drain",
+					"--drain-lock", "/var/vcap/sys/run/container-run/other-job-drain.lock",
+					"--drain-job", "other-job",
+					"--drain-hashes", "/var/run/job-hashes",
+				))
+			})
+
+			It("runs the post-stop script in the first process container of a job", func() {
+				bpmConfigs["fake-job"] = bpm.Config{
+					Processes: []bpm.Process{
+						{Name: "fake-process"},
+						{Name: "other-process"},
+					},
+				}
+				jobs = []bdm.Job{{Name: "fake-job"}}
+
+				containers, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers[0].Args).To(ContainElements("--post-stop-name", "/var/vcap/jobs/fake-job/bin/post-stop"))
+				Expect(containers[1].Args).ToNot(ContainElement("--post-stop-name"))
+			})
+
+			It("shares the drain lock between the process containers of a job", func() {
+				bpmConfigs["fake-job"] = bpm.Config{
+					Processes: []bpm.Process{
+						{Name: "fake-process"},
+						{Name: "other-process"},
+					},
+				}
+				jobs = []bdm.Job{{Name: "fake-job"}}
+
 				containers, err := act()
 				Expect(err).ToNot(HaveOccurred())
+				Expect(containers[0].Args).To(ContainElements("--drain-lock", "/var/vcap/sys/run/container-run/fake-job-drain.lock"))
+				Expect(containers[1].Args).To(ContainElements("--drain-lock", "/var/vcap/sys/run/container-run/fake-job-drain.lock"))
+			})
 
-				Expect(containers[0].Lifecycle).ToNot(BeNil())
-				Expect(containers[0].Lifecycle.PreStop).ToNot(BeNil())
-				Expect(containers[0].Lifecycle.PreStop.Exec.Command).To(ContainElement(ContainSubstring("/var/vcap/jobs/fake-job/bin/drain/")))
+			It("passes the drain timeout to container-run", func() {
+				jobs = []bdm.Job{
+					{
+						Name: "fake-job",
+						Properties: bdm.JobProperties{
+							Quarks: bdm.Quarks{Drain: bdm.Drain{Timeout: 120}},
+						},
+					},
+				}
 
-				Expect(containers[1].Lifecycle).ToNot(BeNil())
-				Expect(containers[1].Lifecycle.PreStop).ToNot(BeNil())
-				Expect(containers[1].Lifecycle.PreStop.Exec.Command).To(ContainElement(ContainSubstring("/var/vcap/jobs/other-job/bin/drain/")))
+				containers, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers[0].Args).To(ContainElements("--drain-timeout", "2m0s"))
+			})
+
+			It("restarts the container instead of failed processes by default", func() {
+				containers, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers[0].Args).ToNot(ContainElement("--restart-policy"))
+				Expect(containers[0].Args).ToNot(ContainElement("--max-restarts"))
+			})
+
+			It("passes the restart policy of the job to container-run", func() {
+				maxRestarts := 3
+				jobs = []bdm.Job{
+					{
+						Name: "fake-job",
+						Properties: bdm.JobProperties{
+							Quarks: bdm.Quarks{RestartPolicy: "on-failure", MaxRestarts: &maxRestarts},
+						},
+					},
+				}
+
+				containers, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers[0].Args).To(ContainElements("--restart-policy", "on-failure", "--max-restarts", "3"))
+			})
+
+			It("fails for an invalid restart policy", func() {
+				jobs = []bdm.Job{
+					{
+						Name: "fake-job",
+						Properties: bdm.JobProperties{
+							Quarks: bdm.Quarks{RestartPolicy: "always"},
+						},
+					},
+				}
+
+				_, err := act()
+				Expect(err).To(MatchError("invalid restart policy 'always' of job 'fake-job', expected 'never' or 'on-failure'"))
 			})
 
 			It("creates a postStart condition command", func() {
@@ -477,6 +607,16 @@ var _ = Describe("ContainerFactory", func() {
 					"-c",
 					"--post-start-condition-arg",
 					"fake_health_check",
+					"--post-stop-name",
+					"/var/vcap/jobs/fake-job/bin/post-stop",
+					"--drain",
+					"/var/vcap/jobs/fake-job/bin/drain",
+					"--drain-lock",
+					"/var/vcap/sys/run/container-run/fake-job-drain.lock",
+					"--drain-job",
+					"fake-job",
+					"--drain-hashes",
+					"/var/run/job-hashes",
 					"--",
 					""))
 			})
@@ -525,6 +665,20 @@ var _ = Describe("ContainerFactory", func() {
 				Expect(len(containers)).To(Equal(2))
 			})
 
+			It("tags the logs with the deployment and instance group", func() {
+				containerFactory := NewContainerFactory("fake-manifest", "fake-ig", "v1", false, releaseImageProvider, bpmJobConfigs)
+				containers, err := containerFactory.JobsToContainers(igJobs, []corev1.VolumeMount{}, disk.BPMResourceDisks{})
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers[1].Name).To(Equal("logs"))
+				Expect(containers[1].Args).To(Equal([]string{"util", "tail-logs"}))
+				Expect(containers[1].Env).To(ConsistOf(
+					corev1.EnvVar{Name: "LOGS_DIR", Value: "/var/vcap/sys/log"},
+					corev1.EnvVar{Name: "DEPLOYMENT_NAME", Value: "fake-manifest"},
+					corev1.EnvVar{Name: "INSTANCE_GROUP_NAME", Value: "fake-ig"},
+				))
+			})
+
 			It("disables it if specified", func() {
 				ig := bdm.InstanceGroup{
 					Name: "fake-ig",
@@ -755,4 +909,35 @@ var _ = Describe("ContainerFactory", func() {
 			})
 		})
 	})
+
+	Context("JobsToPostDeployContainers", func() {
+		act := func() ([]corev1.Container, []corev1.Container, error) {
+			return containerFactory.JobsToPostDeployContainers(jobs, defaultVolumeMounts)
+		}
+
+		It("renders the job templates before running the post-deploy scripts", func() {
+			initContainers, _, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(initContainers).To(HaveLen(3))
+			Expect(initContainers[0].Name).To(Equal("spec-copier"))
+			Expect(initContainers[1].Name).To(Equal("template-render"))
+			Expect(initContainers[2].Name).To(Equal("create-dirs"))
+		})
+
+		It("generates one BOSH post-deploy container per job", func() {
+			_, containers, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(containers).To(HaveLen(2))
+			Expect(containers[0].Name).To(Equal("bosh-post-deploy-fake-job"))
+			Expect(containers[0].Args).To(ContainElement(`if [ -x "/var/vcap/jobs/fake-job/bin/post-deploy" ]; then "/var/vcap/jobs/fake-job/bin/post-deploy"; fi`))
+			Expect(*containers[0].SecurityContext.RunAsUser).To(BeEquivalentTo(0))
+			Expect(containers[1].Name).To(Equal("bosh-post-deploy-other-job"))
+		})
+
+		It("handles an error when getting release image fails", func() {
+			releaseImageProvider.GetReleaseImageReturns("", errors.New("fake-release-image-error"))
+			_, _, err := act()
+			Expect(err).To(MatchError(ContainSubstring("fake-release-image-error")))
+		})
+	})
 })
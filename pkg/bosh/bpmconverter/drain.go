@@ -0,0 +1,79 @@
+package bpmconverter
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"code.cloudfoundry.org/cf-operator/container-run/pkg/containerrun"
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+)
+
+// processStopGracePeriod is the time the processes of a pod get to stop after
+// they are signaled, which is the Kubernetes default grace period.
+const processStopGracePeriod = 30
+
+// jobDrain configures container-run to run the drain script of a job before
+// signaling its processes. All process containers of the job share the lock
+// file, so the drain script runs once per pod.
+func jobDrain(job bdm.Job) containerrun.Drain {
+	return containerrun.Drain{
+		Path:      filepath.Join(VolumeJobsDirMountPath, job.Name, "bin", "drain"),
+		LockFile:  filepath.Join(VolumeSysDirMountPath, "run", "container-run", job.Name+"-drain.lock"),
+		Timeout:   time.Duration(job.Properties.Quarks.Drain.Timeout) * time.Second,
+		Job:       job.Name,
+		HashesDir: VolumeJobHashesMountPath,
+	}
+}
+
+// jobHashes returns the JSON encoded hashes of the jobs of an instance group,
+// which container-run compares to pass the job and hash changes to the drain
+// scripts. The job hash changes with the release image of the job, the spec
+// hash with the release image and the rendered spec.
+func jobHashes(instanceGroup *bdm.InstanceGroup, releaseImageProvider bdm.ReleaseImageProvider) (string, error) {
+	hashes := map[string]containerrun.JobHashes{}
+	for _, job := range instanceGroup.Jobs {
+		image, err := releaseImageProvider.GetReleaseImage(instanceGroup.Name, job.Name)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get release image of job '%s'", job.Name)
+		}
+		spec, err := json.Marshal(&job)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to marshal spec of job '%s'", job.Name)
+		}
+
+		jobHash := fmt.Sprintf("%x", sha1.Sum([]byte(image)))
+		hashes[job.Name] = containerrun.JobHashes{
+			Job:  jobHash,
+			Spec: fmt.Sprintf("%x", sha1.Sum(append([]byte(jobHash), spec...))),
+		}
+	}
+
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal job hashes")
+	}
+	return string(data), nil
+}
+
+// terminationGracePeriod derives the termination grace period of a pod from
+// the longest drain timeout of its jobs. It returns nil, the Kubernetes
+// default, if no job declares a drain timeout.
+func terminationGracePeriod(jobs []bdm.Job) *int64 {
+	timeout := 0
+	for _, job := range jobs {
+		if job.Properties.Quarks.Drain.Timeout > timeout {
+			timeout = job.Properties.Quarks.Drain.Timeout
+		}
+	}
+	if timeout == 0 {
+		return nil
+	}
+
+	period := int64(timeout + processStopGracePeriod)
+	return &period
+}
@@ -42,6 +42,22 @@ type FakeContainerFactory struct {
 		result1 []v1.Container
 		result2 error
 	}
+	JobsToPostDeployContainersStub        func([]manifest.Job, []v1.VolumeMount) ([]v1.Container, []v1.Container, error)
+	jobsToPostDeployContainersMutex       sync.RWMutex
+	jobsToPostDeployContainersArgsForCall []struct {
+		arg1 []manifest.Job
+		arg2 []v1.VolumeMount
+	}
+	jobsToPostDeployContainersReturns struct {
+		result1 []v1.Container
+		result2 []v1.Container
+		result3 error
+	}
+	jobsToPostDeployContainersReturnsOnCall map[int]struct {
+		result1 []v1.Container
+		result2 []v1.Container
+		result3 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -197,6 +213,83 @@ func (fake *FakeContainerFactory) JobsToInitContainersReturnsOnCall(i int, resul
 	}{result1, result2}
 }
 
+func (fake *FakeContainerFactory) JobsToPostDeployContainers(arg1 []manifest.Job, arg2 []v1.VolumeMount) ([]v1.Container, []v1.Container, error) {
+	var arg1Copy []manifest.Job
+	if arg1 != nil {
+		arg1Copy = make([]manifest.Job, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	var arg2Copy []v1.VolumeMount
+	if arg2 != nil {
+		arg2Copy = make([]v1.VolumeMount, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.jobsToPostDeployContainersMutex.Lock()
+	ret, specificReturn := fake.jobsToPostDeployContainersReturnsOnCall[len(fake.jobsToPostDeployContainersArgsForCall)]
+	fake.jobsToPostDeployContainersArgsForCall = append(fake.jobsToPostDeployContainersArgsForCall, struct {
+		arg1 []manifest.Job
+		arg2 []v1.VolumeMount
+	}{arg1Copy, arg2Copy})
+	fake.recordInvocation("JobsToPostDeployContainers", []interface{}{arg1Copy, arg2Copy})
+	fake.jobsToPostDeployContainersMutex.Unlock()
+	if fake.JobsToPostDeployContainersStub != nil {
+		return fake.JobsToPostDeployContainersStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	fakeReturns := fake.jobsToPostDeployContainersReturns
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeContainerFactory) JobsToPostDeployContainersCallCount() int {
+	fake.jobsToPostDeployContainersMutex.RLock()
+	defer fake.jobsToPostDeployContainersMutex.RUnlock()
+	return len(fake.jobsToPostDeployContainersArgsForCall)
+}
+
+func (fake *FakeContainerFactory) JobsToPostDeployContainersCalls(stub func([]manifest.Job, []v1.VolumeMount) ([]v1.Container, []v1.Container, error)) {
+	fake.jobsToPostDeployContainersMutex.Lock()
+	defer fake.jobsToPostDeployContainersMutex.Unlock()
+	fake.JobsToPostDeployContainersStub = stub
+}
+
+func (fake *FakeContainerFactory) JobsToPostDeployContainersArgsForCall(i int) ([]manifest.Job, []v1.VolumeMount) {
+	fake.jobsToPostDeployContainersMutex.RLock()
+	defer fake.jobsToPostDeployContainersMutex.RUnlock()
+	argsForCall := fake.jobsToPostDeployContainersArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeContainerFactory) JobsToPostDeployContainersReturns(result1 []v1.Container, result2 []v1.Container, result3 error) {
+	fake.jobsToPostDeployContainersMutex.Lock()
+	defer fake.jobsToPostDeployContainersMutex.Unlock()
+	fake.JobsToPostDeployContainersStub = nil
+	fake.jobsToPostDeployContainersReturns = struct {
+		result1 []v1.Container
+		result2 []v1.Container
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeContainerFactory) JobsToPostDeployContainersReturnsOnCall(i int, result1 []v1.Container, result2 []v1.Container, result3 error) {
+	fake.jobsToPostDeployContainersMutex.Lock()
+	defer fake.jobsToPostDeployContainersMutex.Unlock()
+	fake.JobsToPostDeployContainersStub = nil
+	if fake.jobsToPostDeployContainersReturnsOnCall == nil {
+		fake.jobsToPostDeployContainersReturnsOnCall = make(map[int]struct {
+			result1 []v1.Container
+			result2 []v1.Container
+			result3 error
+		})
+	}
+	fake.jobsToPostDeployContainersReturnsOnCall[i] = struct {
+		result1 []v1.Container
+		result2 []v1.Container
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *FakeContainerFactory) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -204,6 +297,8 @@ func (fake *FakeContainerFactory) Invocations() map[string][][]interface{} {
 	defer fake.jobsToContainersMutex.RUnlock()
 	fake.jobsToInitContainersMutex.RLock()
 	defer fake.jobsToInitContainersMutex.RUnlock()
+	fake.jobsToPostDeployContainersMutex.RLock()
+	defer fake.jobsToPostDeployContainersMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,104 @@
+package bpmconverter
+
+import (
+	"github.com/pkg/errors"
+
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
+)
+
+// serviceToHorizontalPodAutoscaler generates a HorizontalPodAutoscaler, which
+// scales the QuarksStatefulSet of the instance group by its scale subresource.
+// It's only generated if the quarks.autoscaling property is set. Without
+// target utilizations the autoscaler targets 80% CPU utilization.
+func (kc *BPMConverter) serviceToHorizontalPodAutoscaler(manifestName string, instanceGroup *bdm.InstanceGroup) (*autoscalingv2beta2.HorizontalPodAutoscaler, error) {
+	autoscaling := instanceGroup.Properties.Quarks.Autoscaling
+	if autoscaling == nil {
+		return nil, nil
+	}
+
+	minInstances := autoscalingMinInstances(autoscaling)
+	if autoscaling.MaxInstances < minInstances {
+		return nil, errors.Errorf("max_instances %d of autoscaling must be at least min_instances %d", autoscaling.MaxInstances, minInstances)
+	}
+
+	metrics := []autoscalingv2beta2.MetricSpec{}
+	for _, target := range []struct {
+		name        corev1.ResourceName
+		utilization *int32
+	}{
+		{corev1.ResourceCPU, autoscaling.TargetCPUUtilization},
+		{corev1.ResourceMemory, autoscaling.TargetMemoryUtilization},
+	} {
+		if target.utilization == nil {
+			continue
+		}
+		if *target.utilization < 1 {
+			return nil, errors.Errorf("target %s utilization of autoscaling must be positive", target.name)
+		}
+		metrics = append(metrics, autoscalingv2beta2.MetricSpec{
+			Type: autoscalingv2beta2.ResourceMetricSourceType,
+			Resource: &autoscalingv2beta2.ResourceMetricSource{
+				Name: target.name,
+				Target: autoscalingv2beta2.MetricTarget{
+					Type:               autoscalingv2beta2.UtilizationMetricType,
+					AverageUtilization: target.utilization,
+				},
+			},
+		})
+	}
+
+	return &autoscalingv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instanceGroup.QuarksStatefulSetName(manifestName),
+			Namespace: kc.namespace,
+			Labels: map[string]string{
+				bdm.LabelDeploymentName:    manifestName,
+				bdm.LabelInstanceGroupName: instanceGroup.Name,
+			},
+		},
+		Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{
+				APIVersion: qstsv1a1.SchemeGroupVersion.String(),
+				Kind:       qstsv1a1.QuarksStatefulSetResourceKind,
+				Name:       instanceGroup.QuarksStatefulSetName(manifestName),
+			},
+			MinReplicas: pointers.Int32(int32(minInstances)),
+			MaxReplicas: int32(autoscaling.MaxInstances),
+			Metrics:     metrics,
+		},
+	}, nil
+}
+
+// autoscaledReplicas returns the initial replicas of an autoscaled instance
+// group, its instances across all zones within the autoscaling bounds
+func autoscaledReplicas(instanceGroup *bdm.InstanceGroup) *int32 {
+	autoscaling := instanceGroup.Properties.Quarks.Autoscaling
+	if autoscaling == nil {
+		return nil
+	}
+
+	replicas := instanceGroup.Instances
+	if len(instanceGroup.AZs) > 1 {
+		replicas *= len(instanceGroup.AZs)
+	}
+	if min := autoscalingMinInstances(autoscaling); replicas < min {
+		replicas = min
+	}
+	if replicas > autoscaling.MaxInstances {
+		replicas = autoscaling.MaxInstances
+	}
+	return pointers.Int32(int32(replicas))
+}
+
+func autoscalingMinInstances(autoscaling *bdm.Autoscaling) int {
+	if autoscaling.MinInstances < 1 {
+		return 1
+	}
+	return autoscaling.MinInstances
+}
@@ -0,0 +1,101 @@
+package bpmconverter
+
+import (
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/logs"
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+)
+
+const (
+	// VolumeSyslogCAName is the volume name of the syslog CA certificates
+	VolumeSyslogCAName = "syslog-ca"
+	// VolumeSyslogCAMountPath is the mount path of the syslog CA certificates
+	VolumeSyslogCAMountPath = "/var/run/syslog-ca"
+
+	syslogCAFileName   = "ca.crt"
+	syslogCADefaultKey = "certificate"
+)
+
+// applyLogsConfig passes the logs configuration of the instance group to the
+// logs sidecar, if there is one. It returns the volumes the sidecar mounts.
+func applyLogsConfig(containers []corev1.Container, config *bdm.Logs) ([]corev1.Volume, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	filter := logs.Filter{Include: config.Include, Exclude: config.Exclude}
+	if err := filter.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid logs configuration")
+	}
+	if _, err := regexp.Compile(config.Multiline); err != nil {
+		return nil, errors.Wrapf(err, "invalid logs configuration, multiline pattern '%s'", config.Multiline)
+	}
+
+	args := []string{}
+	for _, include := range config.Include {
+		args = append(args, "--include", include)
+	}
+	for _, exclude := range config.Exclude {
+		args = append(args, "--exclude", exclude)
+	}
+	if config.Multiline != "" {
+		args = append(args, "--multiline-pattern", config.Multiline)
+	}
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	if config.Syslog != nil {
+		syslog := logs.SyslogConfig{Address: config.Syslog.Address, Transport: config.Syslog.Transport}
+		if err := syslog.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid logs configuration")
+		}
+		args = append(args, "--syslog-address", syslog.Address)
+		if syslog.Transport != "" {
+			args = append(args, "--syslog-transport", syslog.Transport)
+		}
+
+		// The CA certificates of a private syslog endpoint are mounted from
+		// a secret
+		if ca := config.Syslog.CA; ca != nil {
+			if syslog.Transport != logs.SyslogTLS {
+				return nil, errors.Errorf("invalid logs configuration, the syslog CA requires the '%s' transport", logs.SyslogTLS)
+			}
+			if ca.Secret == "" {
+				return nil, errors.New("invalid logs configuration, the syslog CA secret is missing")
+			}
+			key := ca.Key
+			if key == "" {
+				key = syslogCADefaultKey
+			}
+
+			volumes = append(volumes, corev1.Volume{
+				Name: VolumeSyslogCAName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: ca.Secret,
+						Items:      []corev1.KeyToPath{{Key: key, Path: syslogCAFileName}},
+					},
+				},
+			})
+			mounts = append(mounts, corev1.VolumeMount{
+				Name:      VolumeSyslogCAName,
+				MountPath: VolumeSyslogCAMountPath,
+				ReadOnly:  true,
+			})
+			args = append(args, "--syslog-ca-file", filepath.Join(VolumeSyslogCAMountPath, syslogCAFileName))
+		}
+	}
+
+	for i := range containers {
+		if containers[i].Name == logsTailerContainerName {
+			containers[i].Args = append(containers[i].Args, args...)
+			containers[i].VolumeMounts = append(containers[i].VolumeMounts, mounts...)
+			return volumes, nil
+		}
+	}
+	return nil, nil
+}
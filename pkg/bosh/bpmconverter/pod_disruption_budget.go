@@ -0,0 +1,90 @@
+package bpmconverter
+
+import (
+	"github.com/pkg/errors"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/statefulset"
+)
+
+// serviceToPodDisruptionBudget generates a PodDisruptionBudget, which limits
+// voluntary disruptions, like node drains, of the pods of an instance group
+// across all its zones.
+// The budget is taken from the quarks.pod_disruption_budget property or, for
+// instance groups with more than one instance, from the max_in_flight of the
+// update block, defaulting to one unavailable pod.
+func (kc *BPMConverter) serviceToPodDisruptionBudget(manifestName string, instanceGroup *bdm.InstanceGroup) (*policyv1beta1.PodDisruptionBudget, error) {
+	spec := policyv1beta1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				bdm.LabelDeploymentName:    manifestName,
+				bdm.LabelInstanceGroupName: instanceGroup.Name,
+			},
+		},
+	}
+
+	budget := instanceGroup.Properties.Quarks.PodDisruptionBudget
+	if budget != nil && (budget.MinAvailable != "" || budget.MaxUnavailable != "") {
+		if budget.MinAvailable != "" && budget.MaxUnavailable != "" {
+			return nil, errors.New("only one of min_available and max_unavailable can be set in pod_disruption_budget")
+		}
+
+		var err error
+		if budget.MinAvailable != "" {
+			spec.MinAvailable, err = intOrPercent(budget.MinAvailable)
+			if err != nil {
+				return nil, errors.Errorf("invalid min_available '%s' in pod_disruption_budget", budget.MinAvailable)
+			}
+		} else {
+			spec.MaxUnavailable, err = intOrPercent(budget.MaxUnavailable)
+			if err != nil {
+				return nil, errors.Errorf("invalid max_unavailable '%s' in pod_disruption_budget", budget.MaxUnavailable)
+			}
+		}
+	} else {
+		instances := instanceGroup.Instances
+		if len(instanceGroup.AZs) > 1 {
+			instances *= len(instanceGroup.AZs)
+		}
+		if instances < 2 {
+			return nil, nil
+		}
+
+		maxUnavailable := "1"
+		if instanceGroup.Update != nil && instanceGroup.Update.MaxInFlight != "" {
+			maxUnavailable = instanceGroup.Update.MaxInFlight
+		}
+
+		var err error
+		spec.MaxUnavailable, err = intOrPercent(maxUnavailable)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instanceGroup.QuarksStatefulSetName(manifestName),
+			Namespace: kc.namespace,
+			Labels: map[string]string{
+				bdm.LabelDeploymentName:    manifestName,
+				bdm.LabelInstanceGroupName: instanceGroup.Name,
+			},
+		},
+		Spec: spec,
+	}, nil
+}
+
+// intOrPercent parses an absolute number or a percentage, like max_in_flight
+func intOrPercent(value string) (*intstr.IntOrString, error) {
+	value, err := statefulset.ExtractMaxInFlight(value)
+	if err != nil {
+		return nil, err
+	}
+	parsed := intstr.Parse(value)
+	return &parsed, nil
+}
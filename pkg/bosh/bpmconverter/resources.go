@@ -7,9 +7,11 @@ import (
 	"github.com/pkg/errors"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1b1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpm"
@@ -36,6 +38,7 @@ type BPMConverter struct {
 type ContainerFactory interface {
 	JobsToInitContainers(jobs []bdm.Job, defaultVolumeMounts []corev1.VolumeMount, bpmDisks disk.BPMResourceDisks, requiredService *string) ([]corev1.Container, error)
 	JobsToContainers(jobs []bdm.Job, defaultVolumeMounts []corev1.VolumeMount, bpmDisks disk.BPMResourceDisks) ([]corev1.Container, error)
+	JobsToPostDeployContainers(jobs []bdm.Job, defaultVolumeMounts []corev1.VolumeMount) ([]corev1.Container, []corev1.Container, error)
 }
 
 // NewContainerFactoryFunc returns ContainerFactory from single BOSH instance group.
@@ -67,14 +70,18 @@ func NewConverter(namespace string, volumeFactory VolumeFactory, newContainerFac
 
 // Resources contains BPM related k8s resources, which were converted from BOSH objects
 type Resources struct {
-	InstanceGroups         []qstsv1a1.QuarksStatefulSet
+	InstanceGroups []qstsv1a1.QuarksStatefulSet
+	// Errands contains the QuarksJobs of errands and of post-deploy scripts
 	Errands                []qjv1a1.QuarksJob
 	Services               []corev1.Service
 	PersistentVolumeClaims []corev1.PersistentVolumeClaim
+	PodDisruptionBudgets   []policyv1beta1.PodDisruptionBudget
+	// HorizontalPodAutoscalers scale the QuarksStatefulSets of autoscaled instance groups
+	HorizontalPodAutoscalers []autoscalingv2beta2.HorizontalPodAutoscaler
 }
 
 // Resources uses BOSH Process Manager information to create k8s container specs from single BOSH instance group.
-// It returns quarks stateful sets, services and quarks jobs.
+// It returns quarks stateful sets, services, pod disruption budgets, horizontal pod autoscalers and quarks jobs for errands and post-deploy scripts.
 func (kc *BPMConverter) Resources(manifestName string, dns DomainNameService, qStsVersion string, instanceGroup *bdm.InstanceGroup, releaseImageProvider bdm.ReleaseImageProvider, bpmConfigs bpm.Configs, igResolvedSecretVersion string) (*Resources, error) {
 	instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Set(manifestName, instanceGroup.Name, qStsVersion)
 
@@ -101,7 +108,7 @@ func (kc *BPMConverter) Resources(manifestName string, dns DomainNameService, qS
 
 	switch instanceGroup.LifeCycle {
 	case bdm.IGTypeService, "":
-		convertedExtStatefulSet, err := kc.serviceToQuarksStatefulSet(cfac, manifestName, dns, instanceGroup, defaultDisks, bpmDisks)
+		convertedExtStatefulSet, err := kc.serviceToQuarksStatefulSet(cfac, manifestName, dns, instanceGroup, releaseImageProvider, bpmConfigs, defaultDisks, bpmDisks)
 		if err != nil {
 			return nil, err
 		}
@@ -112,8 +119,32 @@ func (kc *BPMConverter) Resources(manifestName string, dns DomainNameService, qS
 		}
 
 		res.InstanceGroups = append(res.InstanceGroups, convertedExtStatefulSet)
+
+		pdb, err := kc.serviceToPodDisruptionBudget(manifestName, instanceGroup)
+		if err != nil {
+			return nil, errors.Wrapf(err, "generating pod disruption budget failed for instance group %s", instanceGroup.Name)
+		}
+		if pdb != nil {
+			res.PodDisruptionBudgets = append(res.PodDisruptionBudgets, *pdb)
+		}
+
+		hpa, err := kc.serviceToHorizontalPodAutoscaler(manifestName, instanceGroup)
+		if err != nil {
+			return nil, errors.Wrapf(err, "generating horizontal pod autoscaler failed for instance group %s", instanceGroup.Name)
+		}
+		if hpa != nil {
+			res.HorizontalPodAutoscalers = append(res.HorizontalPodAutoscalers, *hpa)
+		}
+
+		if instanceGroup.Properties.Quarks.PostDeploy {
+			postDeployQJob, err := kc.postDeployToQuarksJob(cfac, manifestName, dns, instanceGroup, defaultDisks)
+			if err != nil {
+				return nil, err
+			}
+			res.Errands = append(res.Errands, postDeployQJob)
+		}
 	case bdm.IGTypeErrand, bdm.IGTypeAutoErrand:
-		convertedQJob, err := kc.errandToQuarksJob(cfac, manifestName, dns, instanceGroup, defaultDisks, bpmDisks)
+		convertedQJob, err := kc.errandToQuarksJob(cfac, manifestName, dns, instanceGroup, bpmConfigs, defaultDisks, bpmDisks)
 		if err != nil {
 			return nil, err
 		}
@@ -130,6 +161,8 @@ func (kc *BPMConverter) serviceToQuarksStatefulSet(
 	manifestName string,
 	dns DomainNameService,
 	instanceGroup *bdm.InstanceGroup,
+	releaseImageProvider bdm.ReleaseImageProvider,
+	bpmConfigs bpm.Configs,
 	defaultDisks disk.BPMResourceDisks,
 	bpmDisks disk.BPMResourceDisks,
 ) (qstsv1a1.QuarksStatefulSet, error) {
@@ -143,12 +176,18 @@ func (kc *BPMConverter) serviceToQuarksStatefulSet(
 	if err != nil {
 		return qstsv1a1.QuarksStatefulSet{}, errors.Wrapf(err, "building containers failed for instance group %s", instanceGroup.Name)
 	}
+	applyVMResources(containers, processContainerCount(instanceGroup.Jobs, bpmConfigs), instanceGroup.VMResources)
+	logsVolumes, err := applyLogsConfig(containers, instanceGroup.Properties.Quarks.Logs)
+	if err != nil {
+		return qstsv1a1.QuarksStatefulSet{}, errors.Wrapf(err, "instance group %s", instanceGroup.Name)
+	}
 
 	defaultVolumes := defaultDisks.Volumes()
 	bpmVolumes := bpmDisks.Volumes()
-	volumes := make([]corev1.Volume, 0, len(defaultVolumes)+len(bpmVolumes))
+	volumes := make([]corev1.Volume, 0, len(defaultVolumes)+len(bpmVolumes)+len(logsVolumes))
 	volumes = append(volumes, defaultVolumes...)
 	volumes = append(volumes, bpmVolumes...)
+	volumes = append(volumes, logsVolumes...)
 
 	defaultVolumeClaims := defaultDisks.PVCs()
 	bpmVolumeClaims := bpmDisks.PVCs()
@@ -161,6 +200,25 @@ func (kc *BPMConverter) serviceToQuarksStatefulSet(
 	if err != nil {
 		return qstsv1a1.QuarksStatefulSet{}, errors.Wrapf(err, "computing annotations failed for instance group %s", instanceGroup.Name)
 	}
+	if instanceGroup.Properties.Quarks.PostDeploy {
+		annotations := map[string]string{}
+		for key, value := range statefulSetAnnotations {
+			annotations[key] = value
+		}
+		annotations[statefulset.AnnotationPostDeployJob] = postDeployJobName(manifestName, instanceGroup)
+		statefulSetAnnotations = annotations
+	}
+
+	hashes, err := jobHashes(instanceGroup, releaseImageProvider)
+	if err != nil {
+		return qstsv1a1.QuarksStatefulSet{}, errors.Wrapf(err, "computing job hashes failed for instance group %s", instanceGroup.Name)
+	}
+	podAnnotations := map[string]string{}
+	for key, value := range instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Annotations {
+		podAnnotations[key] = value
+	}
+	podAnnotations[statefulset.AnnotationJobHashes] = hashes
+
 	extSts := qstsv1a1.QuarksStatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        instanceGroup.QuarksStatefulSetName(manifestName),
@@ -170,8 +228,10 @@ func (kc *BPMConverter) serviceToQuarksStatefulSet(
 		},
 		Spec: qstsv1a1.QuarksStatefulSetSpec{
 			Zones:                instanceGroup.AZs,
+			Replicas:             autoscaledReplicas(instanceGroup),
 			UpdateOnConfigChange: true,
 			ActivePassiveProbes:  instanceGroup.ActivePassiveProbes(),
+			ActivePassive:        activePassive(instanceGroup.Properties.Quarks.ActivePassive),
 			Template: appsv1.StatefulSet{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:        instanceGroup.NameSanitized(),
@@ -187,7 +247,7 @@ func (kc *BPMConverter) serviceToQuarksStatefulSet(
 						ObjectMeta: metav1.ObjectMeta{
 							Labels:      statefulSetLabels,
 							Name:        instanceGroup.NameSanitized(),
-							Annotations: instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Annotations,
+							Annotations: podAnnotations,
 						},
 						Spec: corev1.PodSpec{
 							Affinity:       instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Affinity,
@@ -197,8 +257,9 @@ func (kc *BPMConverter) serviceToQuarksStatefulSet(
 							SecurityContext: &corev1.PodSecurityContext{
 								FSGroup: &admGroupID,
 							},
-							Subdomain:        dns.HeadlessServiceName(instanceGroup.Name),
-							ImagePullSecrets: instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.ImagePullSecrets,
+							Subdomain:                     dns.HeadlessServiceName(instanceGroup.Name),
+							ImagePullSecrets:              instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.ImagePullSecrets,
+							TerminationGracePeriodSeconds: terminationGracePeriod(instanceGroup.Jobs),
 						},
 					},
 					VolumeClaimTemplates: volumeClaims,
@@ -313,6 +374,28 @@ func (kc *BPMConverter) serviceToKubeServices(manifestName string, dns DomainNam
 
 	services = append(services, headlessService)
 
+	// allService backs BOSH DNS queries for all instances (q-s4), which
+	// include the instances that are not ready and passive ones.
+	allService := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        instanceGroup.AllServiceName(manifestName),
+			Namespace:   kc.namespace,
+			Labels:      instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Labels,
+			Annotations: instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: ports,
+			Selector: map[string]string{
+				bdm.LabelDeploymentName:    manifestName,
+				bdm.LabelInstanceGroupName: instanceGroup.Name,
+			},
+			ClusterIP:                "None",
+			PublishNotReadyAddresses: true,
+		},
+	}
+
+	services = append(services, allService)
+
 	return services
 }
 
@@ -322,6 +405,7 @@ func (kc *BPMConverter) errandToQuarksJob(
 	manifestName string,
 	dns DomainNameService,
 	instanceGroup *bdm.InstanceGroup,
+	bpmConfigs bpm.Configs,
 	defaultDisks disk.BPMResourceDisks,
 	bpmDisks disk.BPMResourceDisks,
 ) (qjv1a1.QuarksJob, error) {
@@ -335,25 +419,82 @@ func (kc *BPMConverter) errandToQuarksJob(
 	if err != nil {
 		return qjv1a1.QuarksJob{}, errors.Wrapf(err, "building containers failed for instance group %s", instanceGroup.Name)
 	}
-
-	podLabels := instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Labels
-	// Controller will delete successful job
-	podLabels["delete"] = "pod"
+	applyVMResources(containers, processContainerCount(instanceGroup.Jobs, bpmConfigs), instanceGroup.VMResources)
+	logsVolumes, err := applyLogsConfig(containers, instanceGroup.Properties.Quarks.Logs)
+	if err != nil {
+		return qjv1a1.QuarksJob{}, errors.Wrapf(err, "instance group %s", instanceGroup.Name)
+	}
 
 	defaultVolumes := defaultDisks.Volumes()
 	bpmVolumes := bpmDisks.Volumes()
-	volumes := make([]corev1.Volume, 0, len(defaultVolumes)+len(bpmVolumes))
+	volumes := make([]corev1.Volume, 0, len(defaultVolumes)+len(bpmVolumes)+len(logsVolumes))
 	volumes = append(volumes, defaultVolumes...)
 	volumes = append(volumes, bpmVolumes...)
+	volumes = append(volumes, logsVolumes...)
 
 	strategy := qjv1a1.TriggerManual
 	if instanceGroup.LifeCycle == bdm.IGTypeAutoErrand {
 		strategy = qjv1a1.TriggerOnce
 	}
 
+	name := fmt.Sprintf("%s-%s", manifestName, instanceGroup.Name)
+	return kc.quarksJob(name, strategy, dns, instanceGroup, initContainers, containers, volumes)
+}
+
+// postDeployToQuarksJob will generate a QuarksJob, which runs the post-deploy
+// scripts of the instance group's jobs. It is triggered by the rollout
+// reconciler, once all StatefulSets of the instance group are rolled out.
+func (kc *BPMConverter) postDeployToQuarksJob(
+	cfac ContainerFactory,
+	manifestName string,
+	dns DomainNameService,
+	instanceGroup *bdm.InstanceGroup,
+	defaultDisks disk.BPMResourceDisks,
+) (qjv1a1.QuarksJob, error) {
+	initContainers, containers, err := cfac.JobsToPostDeployContainers(instanceGroup.Jobs, defaultDisks.VolumeMounts())
+	if err != nil {
+		return qjv1a1.QuarksJob{}, errors.Wrapf(err, "building post-deploy containers failed for instance group %s", instanceGroup.Name)
+	}
+
+	qJob, err := kc.quarksJob(postDeployJobName(manifestName, instanceGroup), qjv1a1.TriggerManual, dns, instanceGroup, initContainers, containers, defaultDisks.Volumes())
+	if err != nil {
+		return qjv1a1.QuarksJob{}, err
+	}
+
+	// The post-deploy pod is not an instance of the instance group, it must
+	// not be selected by its services or its pod disruption budget.
+	podLabels := qJob.Spec.Template.Spec.Template.Labels
+	delete(podLabels, bdm.LabelInstanceGroupName)
+	podLabels[bdm.LabelPostDeploy] = instanceGroup.Name
+
+	return qJob, nil
+}
+
+// postDeployJobName returns the name of the post-deploy QuarksJob of an instance group
+func postDeployJobName(manifestName string, instanceGroup *bdm.InstanceGroup) string {
+	return fmt.Sprintf("%s-post-deploy", instanceGroup.QuarksStatefulSetName(manifestName))
+}
+
+// quarksJob generates a QuarksJob for the instance group with the given containers
+func (kc *BPMConverter) quarksJob(
+	name string,
+	strategy qjv1a1.Strategy,
+	dns DomainNameService,
+	instanceGroup *bdm.InstanceGroup,
+	initContainers []corev1.Container,
+	containers []corev1.Container,
+	volumes []corev1.Volume,
+) (qjv1a1.QuarksJob, error) {
+	podLabels := map[string]string{}
+	for key, value := range instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Labels {
+		podLabels[key] = value
+	}
+	// Controller will delete successful job
+	podLabels["delete"] = "pod"
+
 	qJob := qjv1a1.QuarksJob{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        fmt.Sprintf("%s-%s", manifestName, instanceGroup.Name),
+			Name:        name,
 			Namespace:   kc.namespace,
 			Labels:      instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Labels,
 			Annotations: instanceGroup.Env.AgentEnvBoshConfig.Agent.Settings.Annotations,
@@ -386,6 +527,7 @@ func (kc *BPMConverter) errandToQuarksJob(
 		},
 	}
 
+	var err error
 	qJob.Spec.Template.Spec.Template.Spec.DNSPolicy, qJob.Spec.Template.Spec.Template.Spec.DNSConfig, err = dns.DNSSetting(kc.namespace)
 
 	if err != nil {
@@ -410,3 +552,14 @@ func (kc *BPMConverter) errandToQuarksJob(
 
 	return qJob, nil
 }
+
+// activePassive returns the selection of active pods for the QuarksStatefulSet
+func activePassive(ap *bdm.ActivePassive) *qstsv1a1.ActivePassive {
+	if ap == nil {
+		return nil
+	}
+	return &qstsv1a1.ActivePassive{
+		Exclusive: ap.Exclusive,
+		MaxActive: ap.MaxActive,
+	}
+}
@@ -1,6 +1,7 @@
 package bpmconverter_test
 
 import (
+	"encoding/json"
 	"fmt"
 
 	. "github.com/onsi/ginkgo"
@@ -10,7 +11,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
+	"code.cloudfoundry.org/cf-operator/container-run/pkg/containerrun"
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpm"
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpmconverter"
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpmconverter/fakes"
@@ -200,11 +203,13 @@ var _ = Describe("BPM Converter", func() {
 					}
 					m.InstanceGroups[1].Env.AgentEnvBoshConfig.Agent.Settings.Tolerations = tolerations
 
-					activePassiveProbes := map[string]corev1.Probe{
-						"rep-server": corev1.Probe{
-							Handler: corev1.Handler{
-								Exec: &corev1.ExecAction{
-									Command: []string{"ls", "/"},
+					activePassiveProbes := map[string]qstsv1a1.ActivePassiveProbe{
+						"rep-server": qstsv1a1.ActivePassiveProbe{
+							Probe: corev1.Probe{
+								Handler: corev1.Handler{
+									Exec: &corev1.ExecAction{
+										Command: []string{"ls", "/"},
+									},
 								},
 							},
 						},
@@ -226,6 +231,13 @@ var _ = Describe("BPM Converter", func() {
 						qstsv1a1.LabelPodOrdinal:        "0",
 						qstsv1a1.LabelActivePod:         "active",
 					}))
+
+					// The all service publishes the passive pods, too
+					allService := resources.Services[len(resources.Services)-1]
+					Expect(allService.Spec.Selector).To(Equal(map[string]string{
+						manifest.LabelDeploymentName:    deploymentName,
+						manifest.LabelInstanceGroupName: stS.Name,
+					}))
 				})
 
 				It("converts the instance group to an QuarksStatefulSet", func() {
@@ -336,6 +348,13 @@ var _ = Describe("BPM Converter", func() {
 					}))
 					Expect(headlessService.Spec.ClusterIP).To(Equal("None"))
 
+					allService := resources.Services[5]
+					Expect(allService.Name).To(Equal(fmt.Sprintf("%s-%s-all", deploymentName, stS.Name)))
+					Expect(allService.Spec.Selector).To(Equal(headlessService.Spec.Selector))
+					Expect(allService.Spec.Ports).To(Equal(headlessService.Spec.Ports))
+					Expect(allService.Spec.ClusterIP).To(Equal("None"))
+					Expect(allService.Spec.PublishNotReadyAddresses).To(BeTrue())
+
 					// Test affinity & tolerations
 					Expect(stS.Spec.Affinity).To(BeNil())
 					Expect(stS.Spec.Tolerations).To(Equal(tolerations))
@@ -390,6 +409,314 @@ var _ = Describe("BPM Converter", func() {
 				Expect(qSts.Spec.ActivePassiveProbes).ToNot(BeNil())
 				Expect(qSts.Spec.ActivePassiveProbes["some-bpm-process"].Handler.Exec.Command).To(Equal([]string{"ls", "/"}))
 				Expect(qSts.Spec.ActivePassiveProbes["another-bpm-process"].Handler.Exec.Command).To(Equal([]string{"find", "*"}))
+				Expect(qSts.Spec.ActivePassive).To(BeNil())
+			})
+
+			It("passes on grpc probes and thresholds", func() {
+				resources, err := act(bpm.Configs{}, m.InstanceGroups[0])
+				Expect(err).ShouldNot(HaveOccurred())
+				probe := resources.InstanceGroups[0].Spec.ActivePassiveProbes["grpc-process"]
+				Expect(probe.GRPCHealthCheck).To(Equal(&qstsv1a1.GRPCAction{Port: 9090, Service: "db"}))
+				Expect(probe.PeriodSeconds).To(Equal(int32(5)))
+				Expect(probe.FailureThreshold).To(Equal(int32(3)))
+			})
+
+			It("passes on the exclusive mode", func() {
+				m.InstanceGroups[0].Properties.Quarks.ActivePassive = &manifest.ActivePassive{Exclusive: true, MaxActive: 2}
+
+				resources, err := act(bpm.Configs{}, m.InstanceGroups[0])
+				Expect(err).ShouldNot(HaveOccurred())
+				qSts := resources.InstanceGroups[0]
+				Expect(qSts.Spec.ActivePassive).To(Equal(&qstsv1a1.ActivePassive{Exclusive: true, MaxActive: 2}))
+				Expect(qSts.IsExclusiveActivePassive()).To(BeTrue())
+			})
+		})
+
+		Context("when converting pod disruption budgets", func() {
+			var instanceGroup *manifest.InstanceGroup
+
+			BeforeEach(func() {
+				instanceGroup = m.InstanceGroups[1]
+			})
+
+			It("allows one unavailable pod across all zones by default", func() {
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.PodDisruptionBudgets).To(HaveLen(1))
+
+				pdb := resources.PodDisruptionBudgets[0]
+				Expect(pdb.Name).To(Equal("fake-deployment-diego-cell"))
+				Expect(pdb.Labels).To(HaveKeyWithValue(manifest.LabelInstanceGroupName, "diego-cell"))
+				Expect(pdb.Spec.Selector.MatchLabels).To(Equal(map[string]string{
+					manifest.LabelDeploymentName:    deploymentName,
+					manifest.LabelInstanceGroupName: "diego-cell",
+				}))
+				Expect(pdb.Spec.MaxUnavailable.String()).To(Equal("1"))
+				Expect(pdb.Spec.MinAvailable).To(BeNil())
+			})
+
+			It("uses max_in_flight of the update block", func() {
+				instanceGroup.Update.MaxInFlight = "25%"
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.PodDisruptionBudgets[0].Spec.MaxUnavailable.String()).To(Equal("25%"))
+			})
+
+			It("uses the pod_disruption_budget property", func() {
+				instanceGroup.Properties.Quarks.PodDisruptionBudget = &manifest.PodDisruptionBudget{MinAvailable: "2"}
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				pdb := resources.PodDisruptionBudgets[0]
+				Expect(pdb.Spec.MinAvailable.String()).To(Equal("2"))
+				Expect(pdb.Spec.MaxUnavailable).To(BeNil())
+			})
+
+			It("fails for an invalid pod_disruption_budget property", func() {
+				instanceGroup.Properties.Quarks.PodDisruptionBudget = &manifest.PodDisruptionBudget{MinAvailable: "1", MaxUnavailable: "1"}
+				_, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).To(MatchError(ContainSubstring("only one of min_available and max_unavailable")))
+
+				instanceGroup.Properties.Quarks.PodDisruptionBudget = &manifest.PodDisruptionBudget{MaxUnavailable: "half"}
+				_, err = act(bpm.Configs{}, instanceGroup)
+				Expect(err).To(MatchError(ContainSubstring("invalid max_unavailable 'half'")))
+			})
+
+			It("skips instance groups with a single instance", func() {
+				instanceGroup.Instances = 1
+				instanceGroup.AZs = []string{"z1"}
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.PodDisruptionBudgets).To(BeEmpty())
+			})
+
+			It("skips errands", func() {
+				resources, err := act(bpm.Configs{}, m.InstanceGroups[0])
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.PodDisruptionBudgets).To(BeEmpty())
+			})
+		})
+
+		Context("when converting horizontal pod autoscalers", func() {
+			var instanceGroup *manifest.InstanceGroup
+
+			BeforeEach(func() {
+				instanceGroup = m.InstanceGroups[1]
+				instanceGroup.Instances = 2
+				instanceGroup.AZs = []string{"z1", "z2"}
+			})
+
+			It("skips instance groups without autoscaling", func() {
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.HorizontalPodAutoscalers).To(BeEmpty())
+				Expect(resources.InstanceGroups[0].Spec.Replicas).To(BeNil())
+			})
+
+			It("scales the quarks statefulset within the autoscaling bounds", func() {
+				instanceGroup.Properties.Quarks.Autoscaling = &manifest.Autoscaling{
+					MinInstances:         2,
+					MaxInstances:         10,
+					TargetCPUUtilization: pointers.Int32(60),
+				}
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.HorizontalPodAutoscalers).To(HaveLen(1))
+
+				hpa := resources.HorizontalPodAutoscalers[0]
+				Expect(hpa.Name).To(Equal("fake-deployment-diego-cell"))
+				Expect(hpa.Labels).To(HaveKeyWithValue(manifest.LabelInstanceGroupName, "diego-cell"))
+				Expect(hpa.Spec.ScaleTargetRef.Kind).To(Equal("QuarksStatefulSet"))
+				Expect(hpa.Spec.ScaleTargetRef.APIVersion).To(Equal("quarks.cloudfoundry.org/v1alpha1"))
+				Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(resources.InstanceGroups[0].Name))
+				Expect(*hpa.Spec.MinReplicas).To(Equal(int32(2)))
+				Expect(hpa.Spec.MaxReplicas).To(Equal(int32(10)))
+				Expect(hpa.Spec.Metrics).To(HaveLen(1))
+				Expect(hpa.Spec.Metrics[0].Resource.Name).To(Equal(corev1.ResourceCPU))
+				Expect(*hpa.Spec.Metrics[0].Resource.Target.AverageUtilization).To(Equal(int32(60)))
+
+				Expect(*resources.InstanceGroups[0].Spec.Replicas).To(Equal(int32(4)))
+			})
+
+			It("limits the initial replicas to max_instances", func() {
+				instanceGroup.Properties.Quarks.Autoscaling = &manifest.Autoscaling{MaxInstances: 3}
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*resources.HorizontalPodAutoscalers[0].Spec.MinReplicas).To(Equal(int32(1)))
+				Expect(resources.HorizontalPodAutoscalers[0].Spec.Metrics).To(BeEmpty())
+				Expect(*resources.InstanceGroups[0].Spec.Replicas).To(Equal(int32(3)))
+			})
+
+			It("fails for invalid autoscaling bounds", func() {
+				instanceGroup.Properties.Quarks.Autoscaling = &manifest.Autoscaling{MinInstances: 3, MaxInstances: 2}
+
+				_, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).To(MatchError(ContainSubstring("max_instances 2 of autoscaling must be at least min_instances 3")))
+			})
+		})
+
+		Context("when logs are configured", func() {
+			var instanceGroup *manifest.InstanceGroup
+
+			BeforeEach(func() {
+				instanceGroup = m.InstanceGroups[1]
+				containerFactory.JobsToContainersReturns([]corev1.Container{
+					{Name: "redis-server"},
+					{Name: "logs", Args: []string{"util", "tail-logs"}},
+				}, nil)
+			})
+
+			It("passes the filters, the multiline pattern and the syslog endpoint to the logs sidecar", func() {
+				instanceGroup.Properties.Quarks.Logs = &manifest.Logs{
+					Include:   []string{"*.log", "*.txt"},
+					Exclude:   []string{"*.stdout.log"},
+					Multiline: `^\d{4}-`,
+					Syslog:    &manifest.Syslog{Address: "syslog.example.com:6514", Transport: "tls"},
+				}
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				containers := resources.InstanceGroups[0].Spec.Template.Spec.Template.Spec.Containers
+				Expect(containers[0].Args).To(BeEmpty())
+				Expect(containers[1].Args).To(Equal([]string{
+					"util", "tail-logs",
+					"--include", "*.log",
+					"--include", "*.txt",
+					"--exclude", "*.stdout.log",
+					"--multiline-pattern", `^\d{4}-`,
+					"--syslog-address", "syslog.example.com:6514",
+					"--syslog-transport", "tls",
+				}))
+			})
+
+			It("mounts the syslog CA secret into the logs sidecar", func() {
+				instanceGroup.Properties.Quarks.Logs = &manifest.Logs{
+					Syslog: &manifest.Syslog{
+						Address:   "syslog.example.com:6514",
+						Transport: "tls",
+						CA:        &manifest.SyslogCA{Secret: "syslog-ca"},
+					},
+				}
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				podSpec := resources.InstanceGroups[0].Spec.Template.Spec.Template.Spec
+				Expect(podSpec.Volumes).To(ContainElement(corev1.Volume{
+					Name: bpmconverter.VolumeSyslogCAName,
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{
+							SecretName: "syslog-ca",
+							Items:      []corev1.KeyToPath{{Key: "certificate", Path: "ca.crt"}},
+						},
+					},
+				}))
+				Expect(podSpec.Containers[0].VolumeMounts).To(BeEmpty())
+				Expect(podSpec.Containers[1].VolumeMounts).To(ConsistOf(corev1.VolumeMount{
+					Name:      bpmconverter.VolumeSyslogCAName,
+					MountPath: bpmconverter.VolumeSyslogCAMountPath,
+					ReadOnly:  true,
+				}))
+				Expect(podSpec.Containers[1].Args).To(ContainElements("--syslog-ca-file", "/var/run/syslog-ca/ca.crt"))
+			})
+
+			It("mounts the key of the syslog CA secret", func() {
+				instanceGroup.Properties.Quarks.Logs = &manifest.Logs{
+					Syslog: &manifest.Syslog{
+						Address:   "syslog.example.com:6514",
+						Transport: "tls",
+						CA:        &manifest.SyslogCA{Secret: "syslog-ca", Key: "ca.crt"},
+					},
+				}
+
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				volumes := resources.InstanceGroups[0].Spec.Template.Spec.Template.Spec.Volumes
+				volume := volumes[len(volumes)-1]
+				Expect(volume.Name).To(Equal(bpmconverter.VolumeSyslogCAName))
+				Expect(volume.Secret.Items).To(Equal([]corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}}))
+			})
+
+			It("fails for a syslog CA without the tls transport", func() {
+				instanceGroup.Properties.Quarks.Logs = &manifest.Logs{
+					Syslog: &manifest.Syslog{Address: "syslog:514", CA: &manifest.SyslogCA{Secret: "syslog-ca"}},
+				}
+				_, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).To(MatchError(ContainSubstring("the syslog CA requires the 'tls' transport")))
+			})
+
+			It("fails for an invalid multiline pattern", func() {
+				instanceGroup.Properties.Quarks.Logs = &manifest.Logs{Multiline: "("}
+				_, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).To(MatchError(ContainSubstring("invalid logs configuration, multiline pattern '('")))
+			})
+
+			It("fails for an invalid syslog transport", func() {
+				instanceGroup.Properties.Quarks.Logs = &manifest.Logs{Syslog: &manifest.Syslog{Address: "syslog:514", Transport: "http"}}
+				_, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).To(MatchError(ContainSubstring("invalid syslog transport 'http'")))
+			})
+		})
+
+		Context("when post-deploy is enabled", func() {
+			var instanceGroup *manifest.InstanceGroup
+
+			BeforeEach(func() {
+				instanceGroup = m.InstanceGroups[1]
+				instanceGroup.Properties.Quarks.PostDeploy = true
+				containerFactory.JobsToPostDeployContainersReturns(
+					[]corev1.Container{{Name: "spec-copier"}},
+					[]corev1.Container{{Name: "bosh-post-deploy-cflinuxfs3-rootfs-setup"}},
+					nil,
+				)
+			})
+
+			It("adds a manually triggered QuarksJob running the post-deploy scripts", func() {
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.Errands).To(HaveLen(1))
+
+				qJob := resources.Errands[0]
+				Expect(qJob.Name).To(Equal("fake-deployment-diego-cell-post-deploy"))
+				Expect(qJob.Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerManual))
+				Expect(qJob.Spec.Template.Spec.Template.Spec.InitContainers[0].Name).To(Equal("spec-copier"))
+				Expect(qJob.Spec.Template.Spec.Template.Spec.Containers[0].Name).To(Equal("bosh-post-deploy-cflinuxfs3-rootfs-setup"))
+				Expect(qJob.Spec.Template.Spec.Template.Labels).To(HaveKeyWithValue("delete", "pod"))
+			})
+
+			It("labels the post-deploy pod so it is not selected by the services and the budget of the instance group", func() {
+				instanceGroup.Instances = 2
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resources.Services).ToNot(BeEmpty())
+				Expect(resources.PodDisruptionBudgets).To(HaveLen(1))
+
+				podLabels := labels.Set(resources.Errands[0].Spec.Template.Spec.Template.Labels)
+				Expect(podLabels).To(HaveKeyWithValue(manifest.LabelPostDeploy, "diego-cell"))
+				Expect(podLabels).ToNot(HaveKey(manifest.LabelInstanceGroupName))
+
+				for _, svc := range resources.Services {
+					Expect(labels.SelectorFromSet(svc.Spec.Selector).Matches(podLabels)).To(BeFalse(), svc.Name)
+				}
+				selector, err := metav1.LabelSelectorAsSelector(resources.PodDisruptionBudgets[0].Spec.Selector)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(selector.Matches(podLabels)).To(BeFalse())
+			})
+
+			It("references the QuarksJob from the StatefulSet", func() {
+				resources, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).ShouldNot(HaveOccurred())
+				annotations := resources.InstanceGroups[0].Spec.Template.Annotations
+				Expect(annotations).To(HaveKeyWithValue(statefulset.AnnotationPostDeployJob, "fake-deployment-diego-cell-post-deploy"))
+			})
+
+			It("handles an error when converting jobs to post-deploy containers", func() {
+				containerFactory.JobsToPostDeployContainersReturns(nil, nil, errors.New("fake-container-factory-error"))
+				_, err := act(bpm.Configs{}, instanceGroup)
+				Expect(err).To(MatchError(ContainSubstring("building post-deploy containers failed")))
 			})
 		})
 
@@ -442,6 +769,120 @@ var _ = Describe("BPM Converter", func() {
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(resources.InstanceGroups).To(HaveLen(1))
 			})
+
+			Context("when vm_resources are set", func() {
+				BeforeEach(func() {
+					m.InstanceGroups[1].VMResources = &manifest.VMResource{CPU: 2, RAM: 4096, EphemeralDiskSize: 8192}
+
+					containerFactory.JobsToContainersReturns([]corev1.Container{
+						{Name: "fake-job-a-test-server"},
+						{
+							Name: "fake-job-b-test-server",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+								Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+							},
+						},
+						{Name: "fake-job-c-test-server"},
+						{Name: "fake-job-c-alt-test-server"},
+						{Name: "logs"},
+					}, nil)
+				})
+
+				It("distributes them across the BPM process containers", func() {
+					resources, err := act(bpmConfigs[1], m.InstanceGroups[1])
+					Expect(err).ShouldNot(HaveOccurred())
+
+					containers := resources.InstanceGroups[0].Spec.Template.Spec.Template.Spec.Containers
+					Expect(containers).To(HaveLen(5))
+					for _, i := range []int{0, 2, 3} {
+						requests := containers[i].Resources.Requests
+						Expect(requests.Cpu().String()).To(Equal("500m"))
+						Expect(requests.Memory().String()).To(Equal("1Gi"))
+						Expect(requests.StorageEphemeral().String()).To(Equal("2Gi"))
+					}
+
+					By("keeping BPM requests and limits")
+					requests := containers[1].Resources.Requests
+					Expect(requests.Cpu().String()).To(Equal("100m"))
+					Expect(requests.Memory().String()).To(Equal("512Mi"))
+					Expect(requests.StorageEphemeral().String()).To(Equal("2Gi"))
+
+					By("skipping the logs sidecar")
+					Expect(containers[4].Resources.Requests).To(BeEmpty())
+				})
+			})
+
+			Context("when jobs declare a drain timeout", func() {
+				BeforeEach(func() {
+					m.InstanceGroups[1].Jobs[0].Properties.Quarks.Drain.Timeout = 60
+					m.InstanceGroups[1].Jobs[1].Properties.Quarks.Drain.Timeout = 300
+				})
+
+				It("derives the termination grace period from the longest drain timeout", func() {
+					resources, err := act(bpmConfigs[1], m.InstanceGroups[1])
+					Expect(err).ShouldNot(HaveOccurred())
+
+					spec := resources.InstanceGroups[0].Spec.Template.Spec.Template.Spec
+					Expect(spec.TerminationGracePeriodSeconds).To(Equal(pointers.Int64(330)))
+				})
+			})
+
+			It("keeps the default termination grace period without drain timeouts", func() {
+				resources, err := act(bpmConfigs[1], m.InstanceGroups[1])
+				Expect(err).ShouldNot(HaveOccurred())
+
+				spec := resources.InstanceGroups[0].Spec.Template.Spec.Template.Spec
+				Expect(spec.TerminationGracePeriodSeconds).To(BeNil())
+			})
+
+			Context("when computing the job hashes", func() {
+				jobHashes := func() map[string]containerrun.JobHashes {
+					resources, err := act(bpmConfigs[1], m.InstanceGroups[1])
+					Expect(err).ShouldNot(HaveOccurred())
+
+					annotations := resources.InstanceGroups[0].Spec.Template.Spec.Template.Annotations
+					hashes := map[string]containerrun.JobHashes{}
+					Expect(json.Unmarshal([]byte(annotations[statefulset.AnnotationJobHashes]), &hashes)).To(Succeed())
+					return hashes
+				}
+
+				It("annotates the pod template with the hashes of all jobs", func() {
+					hashes := jobHashes()
+					Expect(hashes).To(HaveLen(len(m.InstanceGroups[1].Jobs)))
+					for _, job := range m.InstanceGroups[1].Jobs {
+						Expect(hashes[job.Name].Job).ToNot(BeEmpty())
+						Expect(hashes[job.Name].Spec).ToNot(BeEmpty())
+					}
+				})
+
+				It("changes the spec hash with the job properties", func() {
+					before := jobHashes()
+					job := m.InstanceGroups[1].Jobs[0]
+					job.Properties.Properties = map[string]interface{}{"fake-property": "changed"}
+					m.InstanceGroups[1].Jobs[0] = job
+
+					after := jobHashes()
+					Expect(after[job.Name].Job).To(Equal(before[job.Name].Job))
+					Expect(after[job.Name].Spec).ToNot(Equal(before[job.Name].Spec))
+					other := m.InstanceGroups[1].Jobs[1].Name
+					Expect(after[other]).To(Equal(before[other]))
+				})
+
+				It("changes the job and spec hashes with the release version", func() {
+					before := jobHashes()
+					job := m.InstanceGroups[1].Jobs[0]
+					for _, release := range m.Releases {
+						if release.Name == job.Release {
+							release.Version = "changed"
+						}
+					}
+
+					after := jobHashes()
+					Expect(after[job.Name].Job).ToNot(Equal(before[job.Name].Job))
+					Expect(after[job.Name].Spec).ToNot(Equal(before[job.Name].Spec))
+				})
+			})
 		})
 
 		Context("when the instance group name contains an underscore", func() {
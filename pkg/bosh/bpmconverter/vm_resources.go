@@ -0,0 +1,58 @@
+package bpmconverter
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpm"
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+)
+
+// processContainerCount returns the number of BPM process containers created
+// for the jobs of an instance group
+func processContainerCount(jobs []bdm.Job, bpmConfigs bpm.Configs) int {
+	count := 0
+	for _, job := range jobs {
+		count += len(bpmConfigs[job.Name].Processes)
+	}
+	return count
+}
+
+// applyVMResources distributes the vm_resources of an instance group evenly
+// across its BPM process containers, which are the first containers returned
+// by the container factory. CPU and RAM become requests for cpu and memory,
+// the ephemeral disk size becomes a request for ephemeral-storage.
+// Requests from the BPM config take precedence and no request exceeds the
+// limit of its container.
+func applyVMResources(containers []corev1.Container, count int, vmResources *bdm.VMResource) {
+	if vmResources == nil || count == 0 || count > len(containers) {
+		return
+	}
+
+	shares := corev1.ResourceList{}
+	if vmResources.CPU > 0 {
+		shares[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(vmResources.CPU)*1000/int64(count), resource.DecimalSI)
+	}
+	if vmResources.RAM > 0 {
+		shares[corev1.ResourceMemory] = *resource.NewQuantity(int64(vmResources.RAM)*1024*1024/int64(count), resource.BinarySI)
+	}
+	if vmResources.EphemeralDiskSize > 0 {
+		shares[corev1.ResourceEphemeralStorage] = *resource.NewQuantity(int64(vmResources.EphemeralDiskSize)*1024*1024/int64(count), resource.BinarySI)
+	}
+
+	for i := range containers[:count] {
+		resources := &containers[i].Resources
+		for name, share := range shares {
+			if _, ok := resources.Requests[name]; ok {
+				continue
+			}
+			if limit, ok := resources.Limits[name]; ok && limit.Cmp(share) < 0 {
+				share = limit
+			}
+			if resources.Requests == nil {
+				resources.Requests = corev1.ResourceList{}
+			}
+			resources.Requests[name] = share
+		}
+	}
+}
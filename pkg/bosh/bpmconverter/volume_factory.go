@@ -12,9 +12,11 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"code.cloudfoundry.org/cf-operator/container-run/pkg/containerrun"
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpm"
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/disk"
 	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/statefulset"
 	"code.cloudfoundry.org/quarks-utils/pkg/names"
 )
 
@@ -44,6 +46,11 @@ const (
 	// VolumeSysDirMountPath is the mount path for the sys directory.
 	VolumeSysDirMountPath = bdm.SysDir
 
+	// VolumeJobHashesName is the volume name for the job hashes.
+	VolumeJobHashesName = "job-hashes"
+	// VolumeJobHashesMountPath is the mount path for the current and desired job hashes of the pod.
+	VolumeJobHashesMountPath = "/var/run/job-hashes"
+
 	// VolumeStoreDirMountPath is the mount path for the store directory.
 	VolumeStoreDirMountPath = "/var/vcap/store"
 
@@ -80,6 +87,7 @@ func NewVolumeFactory() *VolumeFactoryImpl {
 // - the the jobs volume
 // - the ephemeral (data) volume
 // - the sys volume
+// - the job hashes volume
 // - the "not interpolated" manifest volume
 // - resolved properties data volume
 func (f *VolumeFactoryImpl) GenerateDefaultDisks(manifestName string, instanceGroupName string, igResolvedSecretVersion string, namespace string) disk.BPMResourceDisks {
@@ -109,6 +117,12 @@ func (f *VolumeFactoryImpl) GenerateDefaultDisks(manifestName string, instanceGr
 			Volume:      sysDirVolume(),
 			VolumeMount: sysDirVolumeMount(),
 		},
+		{
+			// For the drain scripts.
+			// https://bosh.io/docs/drain/
+			Volume:      jobHashesVolume(),
+			VolumeMount: jobHashesVolumeMount(),
+		},
 		{
 			Volume: resolvedPropertiesVolume(resolvedPropertiesSecretName),
 		},
@@ -388,6 +402,42 @@ func sysDirVolumeMount() *corev1.VolumeMount {
 	}
 }
 
+// jobHashesVolume exposes the current and the desired job hashes annotations
+// of the pod to container-run.
+func jobHashesVolume() *corev1.Volume {
+	return &corev1.Volume{
+		Name: VolumeJobHashesName,
+		VolumeSource: corev1.VolumeSource{
+			DownwardAPI: &corev1.DownwardAPIVolumeSource{
+				Items: []corev1.DownwardAPIVolumeFile{
+					{
+						Path:     containerrun.CurrentJobHashesFile,
+						FieldRef: annotationFieldRef(statefulset.AnnotationJobHashes),
+					},
+					{
+						Path:     containerrun.DesiredJobHashesFile,
+						FieldRef: annotationFieldRef(statefulset.AnnotationDesiredJobHashes),
+					},
+				},
+			},
+		},
+	}
+}
+
+func jobHashesVolumeMount() *corev1.VolumeMount {
+	return &corev1.VolumeMount{
+		Name:      VolumeJobHashesName,
+		MountPath: VolumeJobHashesMountPath,
+		ReadOnly:  true,
+	}
+}
+
+func annotationFieldRef(annotation string) *corev1.ObjectFieldSelector {
+	return &corev1.ObjectFieldSelector{
+		FieldPath: fmt.Sprintf("metadata.annotations['%s']", annotation),
+	}
+}
+
 func deduplicateVolumeMounts(volumeMounts []corev1.VolumeMount) []corev1.VolumeMount {
 	result := []corev1.VolumeMount{}
 	uniqueMounts := map[string]struct{}{}
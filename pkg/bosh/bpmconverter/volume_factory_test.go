@@ -50,7 +50,7 @@ var _ = Describe("VolumeFactory", func() {
 		It("creates default disks", func() {
 			disks := factory.GenerateDefaultDisks(manifestName, instanceGroup.Name, version, namespace)
 
-			Expect(disks).Should(HaveLen(5))
+			Expect(disks).Should(HaveLen(6))
 			Expect(disks).Should(ContainElement(disk.BPMResourceDisk{
 				Volume: &corev1.Volume{
 					Name:         VolumeRenderingDataName,
@@ -91,6 +91,30 @@ var _ = Describe("VolumeFactory", func() {
 					MountPath: VolumeSysDirMountPath,
 				},
 			}))
+			Expect(disks).Should(ContainElement(disk.BPMResourceDisk{
+				Volume: &corev1.Volume{
+					Name: VolumeJobHashesName,
+					VolumeSource: corev1.VolumeSource{
+						DownwardAPI: &corev1.DownwardAPIVolumeSource{
+							Items: []corev1.DownwardAPIVolumeFile{
+								{
+									Path:     "current",
+									FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations['quarks.cloudfoundry.org/job-hashes']"},
+								},
+								{
+									Path:     "desired",
+									FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations['quarks.cloudfoundry.org/desired-job-hashes']"},
+								},
+							},
+						},
+					},
+				},
+				VolumeMount: &corev1.VolumeMount{
+					Name:      VolumeJobHashesName,
+					MountPath: VolumeJobHashesMountPath,
+					ReadOnly:  true,
+				},
+			}))
 			Expect(disks).Should(ContainElement(disk.BPMResourceDisk{
 				Volume: &corev1.Volume{
 					Name: "ig-resolved",
@@ -2,6 +2,7 @@ package converter
 
 import (
 	"fmt"
+	"time"
 
 	certv1 "k8s.io/api/certificates/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,6 +29,12 @@ func (vc *VariablesConverter) Variables(manifestName string, variables []bdm.Var
 	secrets := []qsv1a1.QuarksSecret{}
 
 	for _, v := range variables {
+		switch v.Type {
+		case qsv1a1.Password, qsv1a1.Certificate, qsv1a1.SSHKey, qsv1a1.RSAKey, qsv1a1.User, qsv1a1.HTPasswd, qsv1a1.DHParams:
+		default:
+			return secrets, fmt.Errorf("invalid type '%s' of variable '%s'", v.Type, v.Name)
+		}
+
 		secretName := names.DeploymentSecretName(names.DeploymentSecretTypeVariable, manifestName, v.Name)
 		s := qsv1a1.QuarksSecret{
 			ObjectMeta: metav1.ObjectMeta{
@@ -43,6 +50,33 @@ func (vc *VariablesConverter) Variables(manifestName string, variables []bdm.Var
 				SecretName: secretName,
 			},
 		}
+		if v.Options != nil && (v.Type == qsv1a1.Password || v.Type == qsv1a1.User || v.Type == qsv1a1.HTPasswd) {
+			s.Spec.Request.PasswordRequest = qsv1a1.PasswordRequest{
+				Length:            v.Options.Length,
+				ExcludeUpper:      v.Options.ExcludeUpper,
+				ExcludeLower:      v.Options.ExcludeLower,
+				ExcludeNumber:     v.Options.ExcludeNumber,
+				IncludeSpecial:    v.Options.IncludeSpecial,
+				RequireEachClass:  v.Options.RequireEachClass,
+				MinSpecial:        v.Options.MinSpecial,
+				ExcludeCharacters: v.Options.ExcludeCharacters,
+				Passphrase:        v.Options.Passphrase,
+				Words:             v.Options.Words,
+				Separator:         v.Options.Separator,
+			}
+		}
+		if v.Options != nil && (v.Type == qsv1a1.Certificate || v.Type == qsv1a1.RSAKey || v.Type == qsv1a1.SSHKey) {
+			s.Spec.Request.KeyRequest = qsv1a1.KeyRequest{
+				Algorithm: v.Options.Algorithm,
+				Bits:      v.Options.KeyLength,
+			}
+		}
+		if v.Options != nil && (v.Type == qsv1a1.User || v.Type == qsv1a1.HTPasswd) {
+			s.Spec.Request.UserRequest = qsv1a1.UserRequest{Username: v.Options.Username}
+		}
+		if v.Options != nil && v.Type == qsv1a1.DHParams {
+			s.Spec.Request.DHParamsRequest = qsv1a1.DHParamsRequest{Bits: v.Options.KeyLength}
+		}
 		if v.Type == qsv1a1.Certificate {
 			if v.Options == nil {
 				return secrets, fmt.Errorf("invalid certificate QuarksSecret: missing options key")
@@ -78,6 +112,9 @@ func (vc *VariablesConverter) Variables(manifestName string, variables []bdm.Var
 				ActivateEKSWorkaroundForSAN: v.Options.ActivateEKSWorkaroundForSAN,
 				Usages:                      usages,
 			}
+			if v.Options.Duration > 0 {
+				certRequest.Duration = &metav1.Duration{Duration: time.Duration(v.Options.Duration) * 24 * time.Hour}
+			}
 			if len(certRequest.SignerType) == 0 {
 				certRequest.SignerType = qsv1a1.LocalSigner
 			}
@@ -1,6 +1,8 @@
 package converter_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/format"
@@ -62,6 +64,25 @@ var _ = Describe("kube converter", func() {
 				Expect(var1.Spec.SecretName).To(Equal("foo-deployment.var-adminpass"))
 			})
 
+			It("converts password options to a password policy", func() {
+				m.Variables[0].Options = &manifest.VariableOptions{
+					Length:            20,
+					ExcludeUpper:      true,
+					IncludeSpecial:    true,
+					MinSpecial:        2,
+					ExcludeCharacters: "'\"",
+				}
+				variables, err := act()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(variables[0].Spec.Request.PasswordRequest).To(Equal(qsv1a1.PasswordRequest{
+					Length:            20,
+					ExcludeUpper:      true,
+					IncludeSpecial:    true,
+					MinSpecial:        2,
+					ExcludeCharacters: "'\"",
+				}))
+			})
+
 			It("converts rsa key variables", func() {
 				m.Variables[0] = manifest.Variable{
 					Name: "adminkey",
@@ -94,6 +115,52 @@ var _ = Describe("kube converter", func() {
 				Expect(var1.Spec.SecretName).To(Equal("foo-deployment.var-adminkey"))
 			})
 
+			It("converts the key options of ssh key variables", func() {
+				m.Variables[0] = manifest.Variable{
+					Name:    "adminkey",
+					Type:    "ssh",
+					Options: &manifest.VariableOptions{Algorithm: "ecdsa", KeyLength: 384},
+				}
+				variables, err := act()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(variables[0].Spec.Request.KeyRequest).To(Equal(qsv1a1.KeyRequest{Algorithm: "ecdsa", Bits: 384}))
+			})
+
+			It("converts user variables", func() {
+				m.Variables[0] = manifest.Variable{
+					Name:    "admin",
+					Type:    "user",
+					Options: &manifest.VariableOptions{Username: "admin", Length: 20},
+				}
+				variables, err := act()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(variables[0].Spec.Type).To(Equal(qsv1a1.User))
+				Expect(variables[0].Spec.Request.UserRequest.Username).To(Equal("admin"))
+				Expect(variables[0].Spec.Request.PasswordRequest.Length).To(Equal(20))
+			})
+
+			It("converts dhparams variables", func() {
+				m.Variables[0] = manifest.Variable{
+					Name:    "dh",
+					Type:    "dhparams",
+					Options: &manifest.VariableOptions{KeyLength: 4096},
+				}
+				variables, err := act()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(variables[0].Spec.Type).To(Equal(qsv1a1.DHParams))
+				Expect(variables[0].Spec.Request.DHParamsRequest.Bits).To(Equal(4096))
+			})
+
+			It("raises an error for unsupported variable types", func() {
+				m.Variables[0] = manifest.Variable{
+					Name: "foo",
+					Type: "json",
+				}
+				_, err := act()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid type 'json' of variable 'foo'"))
+			})
+
 			It("raises an error when the options are missing for a certificate variable", func() {
 				m.Variables[0] = manifest.Variable{
 					Name: "foo-cert",
@@ -130,6 +197,21 @@ var _ = Describe("kube converter", func() {
 				Expect(request.IsCA).To(Equal(true))
 				Expect(request.CARef.Name).To(Equal("foo-deployment.var-theca"))
 				Expect(request.CARef.Key).To(Equal("certificate"))
+				Expect(request.Duration).To(BeNil())
+			})
+
+			It("converts the certificate duration from days", func() {
+				m.Variables[0] = manifest.Variable{
+					Name: "foo-cert",
+					Type: "certificate",
+					Options: &manifest.VariableOptions{
+						CommonName: "example.com",
+						Duration:   30,
+					},
+				}
+				variables, err := act()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(variables[0].Spec.Request.CertificateRequest.Duration.Duration).To(Equal(30 * 24 * time.Hour))
 			})
 		})
 
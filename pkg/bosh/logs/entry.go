@@ -0,0 +1,43 @@
+// Package logs tails the log files of BOSH jobs and ships them as structured
+// entries to stdout and syslog endpoints.
+package logs
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Entry is a log message of a job's log file, tagged with its origin.
+type Entry struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Deployment    string    `json:"deployment,omitempty"`
+	InstanceGroup string    `json:"instance_group,omitempty"`
+	Job           string    `json:"job,omitempty"`
+	File          string    `json:"file"`
+	Message       string    `json:"message"`
+}
+
+// Sink receives the log entries of the tailed files.
+type Sink interface {
+	Write(entry Entry) error
+}
+
+// JSONSink writes each entry as a JSON line.
+type JSONSink struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONSink returns a sink, which writes JSON lines to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{encoder: json.NewEncoder(w)}
+}
+
+// Write writes the entry as a JSON line.
+func (s *JSONSink) Write(entry Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.encoder.Encode(entry)
+}
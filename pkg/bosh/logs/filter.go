@@ -0,0 +1,54 @@
+package logs
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultInclude are the files tailed, if no include globs are given.
+var DefaultInclude = []string{"*.log"}
+
+// Filter selects the files to tail by globs. A glob matches if it matches
+// the path relative to the logs directory or the file name.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Validate returns an error if one of the globs is malformed.
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid glob '%s'", pattern)
+		}
+	}
+	return nil
+}
+
+// Match returns true if the file at path below dir is included and not excluded.
+func (f Filter) Match(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = path
+	}
+
+	include := f.Include
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	return matchAny(include, rel) && !matchAny(f.Exclude, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	base := filepath.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
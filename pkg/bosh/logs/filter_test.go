@@ -0,0 +1,34 @@
+package logs_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/logs"
+)
+
+var _ = Describe("Filter", func() {
+	dir := "/var/vcap/sys/log"
+
+	It("includes log files by default", func() {
+		filter := logs.Filter{}
+		Expect(filter.Match(dir, "/var/vcap/sys/log/nats/nats.log")).To(BeTrue())
+		Expect(filter.Match(dir, "/var/vcap/sys/log/nats/nats.err")).To(BeFalse())
+	})
+
+	It("matches globs against the relative path and the file name", func() {
+		filter := logs.Filter{
+			Include: []string{"nats/*", "*.txt"},
+			Exclude: []string{"*.stdout.log"},
+		}
+		Expect(filter.Match(dir, "/var/vcap/sys/log/nats/nats.err")).To(BeTrue())
+		Expect(filter.Match(dir, "/var/vcap/sys/log/doppler/doppler.txt")).To(BeTrue())
+		Expect(filter.Match(dir, "/var/vcap/sys/log/doppler/doppler.log")).To(BeFalse())
+		Expect(filter.Match(dir, "/var/vcap/sys/log/nats/nats.stdout.log")).To(BeFalse())
+	})
+
+	It("validates the globs", func() {
+		Expect(logs.Filter{Include: []string{"*.log"}}.Validate()).To(Succeed())
+		Expect(logs.Filter{Exclude: []string{"[.log"}}.Validate()).To(MatchError(ContainSubstring("invalid glob '[.log'")))
+	})
+})
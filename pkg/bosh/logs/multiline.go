@@ -0,0 +1,69 @@
+package logs
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxJoinedLines limits the lines joined into one entry.
+const maxJoinedLines = 1000
+
+// Line is a line read from a log file.
+type Line struct {
+	Text string
+	Time time.Time
+}
+
+// Joiner joins continuation lines, e.g. of stack traces, with the line
+// starting the entry. Without a pattern every line is an entry.
+type Joiner struct {
+	pattern *regexp.Regexp
+	pending []string
+	time    time.Time
+	added   bool
+}
+
+// NewJoiner returns a joiner, which starts a new entry for each line
+// matching the pattern. The pattern may be nil.
+func NewJoiner(pattern *regexp.Regexp) *Joiner {
+	return &Joiner{pattern: pattern}
+}
+
+// Add adds a line and returns the previous entry, if the line completes it.
+func (j *Joiner) Add(line Line) (Line, bool) {
+	j.added = true
+	if j.pattern == nil {
+		return line, true
+	}
+
+	if len(j.pending) > 0 && !j.pattern.MatchString(line.Text) && len(j.pending) < maxJoinedLines {
+		j.pending = append(j.pending, line.Text)
+		return Line{}, false
+	}
+
+	entry, ok := j.Flush()
+	j.pending = []string{line.Text}
+	j.time = line.Time
+	return entry, ok
+}
+
+// Flush returns the pending entry.
+func (j *Joiner) Flush() (Line, bool) {
+	if len(j.pending) == 0 {
+		return Line{}, false
+	}
+	entry := Line{Text: strings.Join(j.pending, "\n"), Time: j.time}
+	j.pending = nil
+	return entry, true
+}
+
+// Expire returns the pending entry, if no line was added since the last
+// call, so the last entry of a file isn't held back until the next one starts.
+func (j *Joiner) Expire() (Line, bool) {
+	if j.added {
+		j.added = false
+		return Line{}, false
+	}
+	return j.Flush()
+}
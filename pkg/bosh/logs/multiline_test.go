@@ -0,0 +1,64 @@
+package logs_test
+
+import (
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/logs"
+)
+
+var _ = Describe("Joiner", func() {
+	now := time.Now()
+	line := func(text string) logs.Line {
+		return logs.Line{Text: text, Time: now}
+	}
+
+	It("returns every line without a pattern", func() {
+		joiner := logs.NewJoiner(nil)
+		entry, ok := joiner.Add(line("first"))
+		Expect(ok).To(BeTrue())
+		Expect(entry.Text).To(Equal("first"))
+	})
+
+	Context("with a pattern", func() {
+		var joiner *logs.Joiner
+
+		BeforeEach(func() {
+			joiner = logs.NewJoiner(regexp.MustCompile(`^\d{4}-`))
+		})
+
+		It("joins continuation lines with the first line of the entry", func() {
+			_, ok := joiner.Add(line("2020-01-01 panic: boom"))
+			Expect(ok).To(BeFalse())
+			_, ok = joiner.Add(line("goroutine 1 [running]:"))
+			Expect(ok).To(BeFalse())
+			_, ok = joiner.Add(line("main.main()"))
+			Expect(ok).To(BeFalse())
+
+			entry, ok := joiner.Add(line("2020-01-01 next"))
+			Expect(ok).To(BeTrue())
+			Expect(entry.Text).To(Equal("2020-01-01 panic: boom\ngoroutine 1 [running]:\nmain.main()"))
+
+			entry, ok = joiner.Flush()
+			Expect(ok).To(BeTrue())
+			Expect(entry.Text).To(Equal("2020-01-01 next"))
+		})
+
+		It("expires the pending entry once no line was added", func() {
+			joiner.Add(line("2020-01-01 first"))
+
+			_, ok := joiner.Expire()
+			Expect(ok).To(BeFalse())
+
+			entry, ok := joiner.Expire()
+			Expect(ok).To(BeTrue())
+			Expect(entry.Text).To(Equal("2020-01-01 first"))
+
+			_, ok = joiner.Expire()
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,337 @@
+package logs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Transports for syslog forwarding
+const (
+	SyslogTCP = "tcp"
+	SyslogUDP = "udp"
+	SyslogTLS = "tls"
+)
+
+const (
+	// syslogEnterpriseID is the private enterprise number of Cloud Foundry,
+	// which is used by its syslog drains for structured data, too.
+	syslogEnterpriseID = 47450
+	// facility user
+	syslogFacility = 1
+	severityError  = 3
+	severityInfo   = 6
+	syslogTimeout  = 10 * time.Second
+	// syslogCloseTimeout is the time a closed sink gets to send the queued
+	// entries, the remaining entries are dropped.
+	syslogCloseTimeout = 5 * time.Second
+	// syslogBufferSize is the number of entries queued for the endpoint,
+	// further entries are dropped.
+	syslogBufferSize = 1000
+	// RFC5424 allows up to six digits of fractional seconds
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// SyslogConfig configures the forwarding of log entries to a syslog endpoint.
+type SyslogConfig struct {
+	// Address is the host:port of the syslog endpoint.
+	Address string
+	// Transport is one of tcp, udp or tls.
+	Transport string
+	// CAFile contains the PEM encoded CA certificates, which verify the
+	// certificate of a TLS endpoint. The system CAs are used if it's empty.
+	CAFile string
+}
+
+// Validate returns an error if the address or the transport is invalid.
+func (c SyslogConfig) Validate() error {
+	switch c.Transport {
+	case "", SyslogTCP, SyslogUDP, SyslogTLS:
+	default:
+		return errors.Errorf("invalid syslog transport '%s', expected tcp, udp or tls", c.Transport)
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return errors.Wrapf(err, "invalid syslog address '%s'", c.Address)
+	}
+	return nil
+}
+
+// SyslogSink forwards log entries as RFC5424 messages. TCP and TLS messages
+// are framed by octet counting (RFC6587).
+// Entries are queued and sent in the background, so an unavailable endpoint
+// doesn't block the other sinks. Entries are dropped if the queue is full.
+type SyslogSink struct {
+	config   SyslogConfig
+	hostname string
+	log      *zap.SugaredLogger
+	rootCAs  *x509.CertPool
+	queue    chan Entry
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	dropped  uint64
+	conn     net.Conn
+	// deadline limits sending the queued entries once the sink is closed,
+	// it's set before the stop channel is closed.
+	deadline time.Time
+}
+
+// NewSyslogSink returns a sink, which connects to the syslog endpoint on the
+// first entry. Errors of the endpoint are logged.
+func NewSyslogSink(log *zap.SugaredLogger, config SyslogConfig) (*SyslogSink, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Transport == "" {
+		config.Transport = SyslogTCP
+	}
+
+	var rootCAs *x509.CertPool
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading syslog CA file '%s'", config.CAFile)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("syslog CA file '%s' contains no PEM encoded certificates", config.CAFile)
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	s := &SyslogSink{
+		config:   config,
+		hostname: hostname,
+		log:      log,
+		rootCAs:  rootCAs,
+		queue:    make(chan Entry, syslogBufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write queues the entry for the syslog endpoint. It never blocks, the entry
+// is dropped and counted if the queue is full.
+func (s *SyslogSink) Write(entry Entry) error {
+	select {
+	case <-s.stop:
+		return errors.Errorf("syslog sink for endpoint '%s' is closed", s.config.Address)
+	default:
+	}
+
+	select {
+	case s.queue <- entry:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return nil
+}
+
+// Dropped returns the number of entries dropped, because the queue was full.
+func (s *SyslogSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close stops accepting entries and waits until the queued entries are sent,
+// at most for the close timeout. The number of entries left in the queue is
+// logged. The connection to the syslog endpoint is closed once the entry being
+// sent, if any, is done.
+func (s *SyslogSink) Close() error {
+	s.once.Do(func() {
+		s.deadline = time.Now().Add(syslogCloseTimeout)
+		close(s.stop)
+	})
+
+	timer := time.NewTimer(time.Until(s.deadline))
+	defer timer.Stop()
+	select {
+	case <-s.done:
+	case <-timer.C:
+	}
+	if left := len(s.queue); left > 0 {
+		s.log.Errorf("Dropped %d log entries for syslog endpoint '%s', they were not sent within %s after closing", left, s.config.Address, syslogCloseTimeout)
+	}
+	return nil
+}
+
+// run sends the queued entries until the sink is closed. The number of
+// entries dropped since the last report is logged.
+func (s *SyslogSink) run() {
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		close(s.done)
+	}()
+
+	var reported uint64
+	for {
+		// Closing takes precedence over the queued entries
+		select {
+		case <-s.stop:
+			s.flush()
+			return
+		default:
+		}
+
+		select {
+		case <-s.stop:
+			s.flush()
+			return
+		case entry := <-s.queue:
+			if dropped := s.Dropped(); dropped > reported {
+				s.log.Errorf("Dropped %d log entries for syslog endpoint '%s', the queue was full", dropped-reported, s.config.Address)
+				reported = dropped
+			}
+			if err := s.send(entry); err != nil {
+				s.log.Error(err)
+			}
+		}
+	}
+}
+
+// flush sends the entries left in the queue of the closed sink, until the
+// close timeout is exceeded.
+func (s *SyslogSink) flush() {
+	for time.Now().Before(s.deadline) {
+		select {
+		case entry := <-s.queue:
+			if err := s.send(entry); err != nil {
+				s.log.Error(err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// timeout returns the deadline of dialing or writing to the endpoint. Once
+// the sink is closed, it doesn't exceed the close timeout.
+func (s *SyslogSink) timeout() time.Time {
+	deadline := time.Now().Add(syslogTimeout)
+	select {
+	case <-s.stop:
+		if s.deadline.Before(deadline) {
+			return s.deadline
+		}
+	default:
+	}
+	return deadline
+}
+
+// send sends the entry to the syslog endpoint. The connection is
+// re-established once if sending fails.
+func (s *SyslogSink) send(entry Entry) error {
+	message := FormatRFC5424(entry, s.hostname)
+	if s.config.Transport != SyslogUDP {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			var conn net.Conn
+			if conn, err = s.dial(); err != nil {
+				continue
+			}
+			s.conn = conn
+		}
+		if err = s.conn.SetWriteDeadline(s.timeout()); err == nil {
+			if _, err = s.conn.Write([]byte(message)); err == nil {
+				return nil
+			}
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return errors.Wrapf(err, "forwarding log entry to syslog endpoint '%s'", s.config.Address)
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Deadline: s.timeout()}
+	switch s.config.Transport {
+	case SyslogTLS:
+		return tls.DialWithDialer(dialer, "tcp", s.config.Address, &tls.Config{RootCAs: s.rootCAs})
+	default:
+		return dialer.Dial(s.config.Transport, s.config.Address)
+	}
+}
+
+// FormatRFC5424 formats the entry as RFC5424 syslog message. The job is the
+// app name and the origin of the entry is added as structured data.
+func FormatRFC5424(entry Entry, hostname string) string {
+	severity := severityInfo
+	if strings.Contains(filepath.Base(entry.File), "stderr") {
+		severity = severityError
+	}
+
+	appName := entry.Job
+	if appName == "" {
+		appName = entry.InstanceGroup
+	}
+
+	params := []string{}
+	for _, param := range []struct{ name, value string }{
+		{"deployment", entry.Deployment},
+		{"instance_group", entry.InstanceGroup},
+		{"job", entry.Job},
+		{"file", entry.File},
+	} {
+		if param.value != "" {
+			params = append(params, fmt.Sprintf(`%s="%s"`, param.name, escapeParamValue(param.value)))
+		}
+	}
+
+	structuredData := "-"
+	if len(params) > 0 {
+		structuredData = fmt.Sprintf("[tags@%d %s]", syslogEnterpriseID, strings.Join(params, " "))
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s - - %s %s",
+		syslogFacility*8+severity,
+		entry.Timestamp.UTC().Format(syslogTimeFormat),
+		header(hostname, 255),
+		header(appName, 48),
+		structuredData,
+		entry.Message,
+	)
+}
+
+// header returns the value as header field of the maximum length, or the
+// nil value '-' if it's empty.
+func header(value string, length int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+// escapeParamValue escapes '"', '\' and ']' in structured data parameter values.
+func escapeParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
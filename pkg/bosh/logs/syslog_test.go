@@ -0,0 +1,293 @@
+package logs_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"go.uber.org/zap"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/logs"
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("Syslog", func() {
+	entry := logs.Entry{
+		Timestamp:     time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		Deployment:    "cf",
+		InstanceGroup: "nats",
+		Job:           "nats",
+		File:          "/var/vcap/sys/log/nats/nats.log",
+		Message:       "nats-msg-line",
+	}
+
+	Describe("FormatRFC5424", func() {
+		It("tags the message with its origin", func() {
+			Expect(logs.FormatRFC5424(entry, "nats-0")).To(Equal(
+				`<14>1 2020-01-02T03:04:05.123456Z nats-0 nats - - [tags@47450 deployment="cf" instance_group="nats" job="nats" file="/var/vcap/sys/log/nats/nats.log"] nats-msg-line`,
+			))
+		})
+
+		It("uses the error severity for stderr logs and escapes parameter values", func() {
+			e := entry
+			e.File = `/var/vcap/sys/log/nats/"nats].stderr.log`
+			Expect(logs.FormatRFC5424(e, "nats-0")).To(HavePrefix("<11>1 "))
+			Expect(logs.FormatRFC5424(e, "nats-0")).To(ContainSubstring(`file="/var/vcap/sys/log/nats/\"nats\].stderr.log"`))
+		})
+	})
+
+	Describe("SyslogSink", func() {
+		var log *zap.SugaredLogger
+
+		BeforeEach(func() {
+			_, log = helper.NewTestLogger()
+		})
+
+		// blackhole accepts connections, but never answers, so TLS handshakes
+		// hang until they time out.
+		blackhole := func() net.Listener {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			go func() {
+				conns := []net.Conn{}
+				defer func() {
+					for _, conn := range conns {
+						conn.Close()
+					}
+				}()
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					conns = append(conns, conn)
+				}
+			}()
+			return listener
+		}
+
+		// receive reads the octet counted messages of the first connection.
+		receive := func(listener net.Listener, received chan<- string) {
+			go func() {
+				defer GinkgoRecover()
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					length, err := reader.ReadString(' ')
+					if err != nil {
+						return
+					}
+					var n int
+					_, err = fmt.Sscanf(length, "%d ", &n)
+					Expect(err).ToNot(HaveOccurred())
+					message := make([]byte, n)
+					_, err = io.ReadFull(reader, message)
+					Expect(err).ToNot(HaveOccurred())
+					received <- string(message)
+				}
+			}()
+		}
+
+		It("validates the configuration", func() {
+			_, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: "syslog:514", Transport: "http"})
+			Expect(err).To(MatchError(ContainSubstring("invalid syslog transport 'http'")))
+
+			_, err = logs.NewSyslogSink(log, logs.SyslogConfig{Address: "syslog"})
+			Expect(err).To(MatchError(ContainSubstring("invalid syslog address 'syslog'")))
+		})
+
+		It("forwards octet counted messages over tcp", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer listener.Close()
+
+			received := make(chan string)
+			receive(listener, received)
+
+			sink, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String()})
+			Expect(err).ToNot(HaveOccurred())
+			defer sink.Close()
+
+			Expect(sink.Write(entry)).To(Succeed())
+			Eventually(received).Should(Receive(HaveSuffix("] nats-msg-line")))
+
+			second := entry
+			second.Message = "second"
+			Expect(sink.Write(second)).To(Succeed())
+			Eventually(received).Should(Receive(HaveSuffix("] second")))
+		})
+
+		It("doesn't delay the stdout output if the endpoint doesn't answer", func() {
+			listener := blackhole()
+			defer listener.Close()
+
+			dir, err := ioutil.TempDir("", "logs")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			syslog, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String(), Transport: "tls"})
+			Expect(err).ToNot(HaveOccurred())
+			defer syslog.Close()
+
+			stdout := gbytes.NewBuffer()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				_ = logs.NewTailer(log, logs.Config{Dir: dir}, logs.NewJSONSink(stdout), syslog).Run(ctx)
+			}()
+
+			path := filepath.Join(dir, "nats", "nats.log")
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Eventually(func() error {
+				return ioutil.WriteFile(path, []byte("first\nsecond\nthird\n"), 0644)
+			}).Should(Succeed())
+
+			Eventually(stdout, 2*time.Second).Should(gbytes.Say(`"message":"first"`))
+			Eventually(stdout, 2*time.Second).Should(gbytes.Say(`"message":"third"`))
+		})
+
+		It("drops and counts entries if the queue is full", func() {
+			listener := blackhole()
+			defer listener.Close()
+
+			sink, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String(), Transport: "tls"})
+			Expect(err).ToNot(HaveOccurred())
+			defer sink.Close()
+
+			start := time.Now()
+			for i := 0; i < 1100; i++ {
+				Expect(sink.Write(entry)).To(Succeed())
+			}
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(sink.Dropped()).To(BeNumerically(">=", 99))
+		})
+
+		It("sends the queued entries when closed", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer listener.Close()
+
+			received := make(chan string, 100)
+			receive(listener, received)
+
+			sink, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String()})
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 100; i++ {
+				Expect(sink.Write(entry)).To(Succeed())
+			}
+			Expect(sink.Close()).To(Succeed())
+			Eventually(func() int { return len(received) }).Should(Equal(100))
+			Expect(sink.Write(entry)).To(MatchError(ContainSubstring("is closed")))
+		})
+
+		It("drops the queued entries, which are not sent within the close timeout", func() {
+			listener := blackhole()
+			defer listener.Close()
+
+			sink, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String(), Transport: "tls"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sink.Write(entry)).To(Succeed())
+			Expect(sink.Write(entry)).To(Succeed())
+
+			start := time.Now()
+			Expect(sink.Close()).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically("<", 7*time.Second))
+		})
+
+		Context("with a CA file", func() {
+			var (
+				caFile   string
+				listener net.Listener
+				received chan string
+			)
+
+			BeforeEach(func() {
+				generator := inmemorygenerator.NewInMemoryGenerator(log)
+				ca, err := generator.GenerateCertificate("syslog-ca", credsgen.CertificateGenerationRequest{CommonName: "syslog-ca", IsCA: true})
+				Expect(err).ToNot(HaveOccurred())
+				cert, err := generator.GenerateCertificate("syslog", credsgen.CertificateGenerationRequest{
+					CommonName:       "syslog",
+					AlternativeNames: []string{"127.0.0.1"},
+					CA:               ca,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				keyPair, err := tls.X509KeyPair(cert.Certificate, cert.PrivateKey)
+				Expect(err).ToNot(HaveOccurred())
+
+				file, err := ioutil.TempFile("", "syslog-ca")
+				Expect(err).ToNot(HaveOccurred())
+				_, err = file.Write(ca.Certificate)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(file.Close()).To(Succeed())
+				caFile = file.Name()
+
+				listener, err = tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{keyPair}})
+				Expect(err).ToNot(HaveOccurred())
+				received = make(chan string)
+				receive(listener, received)
+			})
+
+			AfterEach(func() {
+				listener.Close()
+				Expect(os.Remove(caFile)).To(Succeed())
+			})
+
+			It("verifies the certificate of the TLS endpoint with the CA", func() {
+				sink, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String(), Transport: "tls", CAFile: caFile})
+				Expect(err).ToNot(HaveOccurred())
+				defer sink.Close()
+
+				Expect(sink.Write(entry)).To(Succeed())
+				Eventually(received).Should(Receive(HaveSuffix("] nats-msg-line")))
+			})
+
+			It("doesn't trust the certificate of the TLS endpoint without the CA", func() {
+				sink, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String(), Transport: "tls"})
+				Expect(err).ToNot(HaveOccurred())
+				defer sink.Close()
+
+				Expect(sink.Write(entry)).To(Succeed())
+				Consistently(received, time.Second).ShouldNot(Receive())
+			})
+
+			It("fails for an invalid CA file", func() {
+				Expect(ioutil.WriteFile(caFile, []byte("no certificate"), 0644)).To(Succeed())
+				_, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: listener.Addr().String(), Transport: "tls", CAFile: caFile})
+				Expect(err).To(MatchError(ContainSubstring("contains no PEM encoded certificates")))
+			})
+		})
+
+		It("forwards messages over udp", func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			sink, err := logs.NewSyslogSink(log, logs.SyslogConfig{Address: conn.LocalAddr().String(), Transport: "udp"})
+			Expect(err).ToNot(HaveOccurred())
+			defer sink.Close()
+			Expect(sink.Write(entry)).To(Succeed())
+
+			buffer := make([]byte, 1024)
+			Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+			n, _, err := conn.ReadFrom(buffer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(buffer[:n])).To(HavePrefix("<14>1 2020-01-02T03:04:05.123456Z "))
+		})
+	})
+})
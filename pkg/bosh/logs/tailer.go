@@ -0,0 +1,223 @@
+package logs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hpcloud/tail"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// DefaultFlushInterval is the time after which an incomplete multiline
+// entry is written, if no further lines were appended.
+const DefaultFlushInterval = time.Second
+
+// Config configures which files are tailed and how their entries are tagged.
+type Config struct {
+	// Dir is the logs directory, its sub directories are named after the jobs.
+	Dir           string
+	Deployment    string
+	InstanceGroup string
+	Filter        Filter
+	// Multiline matches the first line of an entry, continuation lines are joined with it.
+	Multiline     *regexp.Regexp
+	FlushInterval time.Duration
+}
+
+// Tailer tails the log files of a directory, including files and
+// directories created later on, and writes their entries to the sinks.
+type Tailer struct {
+	config Config
+	log    *zap.SugaredLogger
+	sinks  []Sink
+
+	mutex  sync.Mutex
+	tailed map[string]struct{}
+}
+
+// NewTailer returns a new Tailer.
+func NewTailer(log *zap.SugaredLogger, config Config, sinks ...Sink) *Tailer {
+	if config.FlushInterval == 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+	return &Tailer{
+		config: config,
+		log:    log,
+		sinks:  sinks,
+		tailed: map[string]struct{}{},
+	}
+}
+
+// Run tails the logs directory until the context is done.
+func (t *Tailer) Run(ctx context.Context) error {
+	if _, err := os.Stat(t.config.Dir); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := t.add(ctx, watcher, t.config.Dir); err != nil {
+		return errors.Wrapf(err, "watching logs directory '%s'", t.config.Dir)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotify.Create != fsnotify.Create {
+				continue
+			}
+			info, err := os.Stat(event.Name)
+			switch {
+			case err != nil:
+			case info.IsDir():
+				if err := t.add(ctx, watcher, event.Name); err != nil {
+					t.log.Error(err)
+				}
+			default:
+				t.tail(ctx, event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			t.log.Error(err)
+		}
+	}
+}
+
+// add watches the directory and its sub directories and tails their files.
+// Directories are watched before their files are listed, so no file created
+// in between is missed.
+func (t *Tailer) add(ctx context.Context, watcher *fsnotify.Watcher, dir string) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				t.log.Error(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			t.tail(ctx, path)
+		}
+		return nil
+	})
+}
+
+// tail starts following a file, if it matches the filter and isn't tailed yet.
+func (t *Tailer) tail(ctx context.Context, path string) {
+	if !t.config.Filter.Match(t.config.Dir, path) {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.tailed[path]; ok {
+		return
+	}
+	t.tailed[path] = struct{}{}
+
+	go t.follow(ctx, path)
+}
+
+// follow writes the entries of a file, joining multiline entries.
+func (t *Tailer) follow(ctx context.Context, path string) {
+	tf, err := tail.TailFile(path, tail.Config{Follow: true, ReOpen: true, Logger: tail.DiscardingLogger})
+	if err != nil {
+		t.log.Error(err)
+		return
+	}
+	defer tf.Cleanup()
+
+	joiner := NewJoiner(t.config.Multiline)
+	ticker := time.NewTicker(t.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			_ = tf.Stop()
+			if line, ok := joiner.Flush(); ok {
+				t.write(path, line)
+			}
+			return
+		case line, ok := <-tf.Lines:
+			if !ok {
+				if line, ok := joiner.Flush(); ok {
+					t.write(path, line)
+				}
+				if err := tf.Err(); err != nil {
+					t.log.Error(err)
+				}
+				return
+			}
+			if line.Err != nil {
+				t.log.Error(line.Err)
+				continue
+			}
+			if entry, ok := joiner.Add(Line{Text: line.Text, Time: line.Time}); ok {
+				t.write(path, entry)
+			}
+		case <-ticker.C:
+			if entry, ok := joiner.Expire(); ok {
+				t.write(path, entry)
+			}
+		}
+	}
+}
+
+func (t *Tailer) write(path string, line Line) {
+	entry := Entry{
+		Timestamp:     line.Time,
+		Deployment:    t.config.Deployment,
+		InstanceGroup: t.config.InstanceGroup,
+		Job:           jobName(t.config.Dir, path),
+		File:          path,
+		Message:       line.Text,
+	}
+	for _, sink := range t.sinks {
+		if err := sink.Write(entry); err != nil {
+			t.log.Error(err)
+		}
+	}
+}
+
+// jobName returns the name of the job's log directory the file is in.
+func jobName(dir string, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 2 || parts[0] == ".." {
+		return ""
+	}
+	return parts[0]
+}
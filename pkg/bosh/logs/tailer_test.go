@@ -0,0 +1,135 @@
+package logs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/logs"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+type fakeSink struct {
+	mutex   sync.Mutex
+	entries []logs.Entry
+}
+
+func (s *fakeSink) Write(entry logs.Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *fakeSink) Entries() []logs.Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]logs.Entry{}, s.entries...)
+}
+
+var _ = Describe("Tailer", func() {
+	var (
+		dir    string
+		config logs.Config
+		sink   *fakeSink
+		cancel context.CancelFunc
+		done   chan error
+	)
+
+	appendFile := func(path string, text string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+		_, err = file.WriteString(text)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	messages := func() []string {
+		result := []string{}
+		for _, entry := range sink.Entries() {
+			result = append(result, entry.Message)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "logs")
+		Expect(err).ToNot(HaveOccurred())
+
+		config = logs.Config{
+			Dir:           dir,
+			Deployment:    "cf",
+			InstanceGroup: "nats",
+			FlushInterval: 50 * time.Millisecond,
+		}
+		sink = &fakeSink{}
+	})
+
+	JustBeforeEach(func() {
+		_, log := helper.NewTestLogger()
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan error)
+		go func() {
+			done <- logs.NewTailer(log, config, sink).Run(ctx)
+		}()
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Context("with existing files", func() {
+		BeforeEach(func() {
+			appendFile(filepath.Join(dir, "nats", "nats.log"), "first\n")
+			appendFile(filepath.Join(dir, "nats", "nats.err"), "ignored\n")
+		})
+
+		It("tags the entries with their origin", func() {
+			Eventually(sink.Entries).Should(HaveLen(1))
+			entry := sink.Entries()[0]
+			Expect(entry.Deployment).To(Equal("cf"))
+			Expect(entry.InstanceGroup).To(Equal("nats"))
+			Expect(entry.Job).To(Equal("nats"))
+			Expect(entry.File).To(Equal(filepath.Join(dir, "nats", "nats.log")))
+			Expect(entry.Message).To(Equal("first"))
+			Expect(entry.Timestamp).ToNot(BeZero())
+
+			appendFile(filepath.Join(dir, "nats", "nats.log"), "second\n")
+			Eventually(messages).Should(Equal([]string{"first", "second"}))
+			Consistently(messages, 200*time.Millisecond).ShouldNot(ContainElement("ignored"))
+		})
+	})
+
+	It("tails files of directories created later on", func() {
+		appendFile(filepath.Join(dir, "doppler", "doppler.log"), "doppler-msg-line\n")
+
+		Eventually(messages, 5*time.Second).Should(ContainElement("doppler-msg-line"))
+		Expect(sink.Entries()[0].Job).To(Equal("doppler"))
+	})
+
+	Context("with a multiline pattern", func() {
+		BeforeEach(func() {
+			config.Multiline = regexp.MustCompile(`^\d{4}-`)
+			appendFile(filepath.Join(dir, "nats", "nats.log"), "2020-01-01 panic: boom\ngoroutine 1 [running]:\n2020-01-01 next\n")
+		})
+
+		It("joins continuation lines and flushes the last entry", func() {
+			Eventually(messages).Should(Equal([]string{
+				"2020-01-01 panic: boom\ngoroutine 1 [running]:",
+				"2020-01-01 next",
+			}))
+		})
+	})
+})
@@ -43,13 +43,7 @@ func InterpolateVariables(log *zap.SugaredLogger, boshManifestBytes []byte, vari
 						return errors.Wrapf(err, "could not read variables variable %s", variable.Name())
 					}
 
-					// If variable type is password, set password value directly
-					switch varFileName {
-					case "password":
-						staticVars[variable.Name()] = string(varBytes)
-					default:
-						staticVars[variable.Name()] = mergeStaticVar(staticVars[variable.Name()], varFileName, string(varBytes))
-					}
+					staticVars[variable.Name()] = mergeStaticVar(staticVars[variable.Name()], varFileName, string(varBytes))
 				}
 				return nil
 			})
@@ -57,6 +51,14 @@ func InterpolateVariables(log *zap.SugaredLogger, boshManifestBytes []byte, vari
 				return errors.Wrapf(err, "could not read directory  %s", variable.Name())
 			}
 
+			// If variable type is password, set password value directly.
+			// User variables have a password, too, but also a username.
+			if fields, ok := staticVars[variable.Name()].(map[interface{}]interface{}); ok && len(fields) == 1 {
+				if password, ok := fields["password"]; ok {
+					staticVars[variable.Name()] = password
+				}
+			}
+
 			vars = append(vars, staticVars)
 		}
 	}
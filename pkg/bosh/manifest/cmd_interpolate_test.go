@@ -47,6 +47,24 @@ instance_groups:
 		Expect(string(dataBytes)).To(Equal(`{"manifest.yaml":"director_uuid: |\n  fake-password\ninstance_groups:\n- azs: null\n  env:\n    bosh:\n      agent:\n        settings: {}\n      ipv6:\n        enable: false\n  instances: 0\n  jobs: null\n  name: |\n    baz\n  properties:\n    quarks: {}\n  stemcell: \"\"\n  vm_resources: null\n- azs: null\n  env:\n    bosh:\n      agent:\n        settings: {}\n      ipv6:\n        enable: false\n  instances: 0\n  jobs: null\n  name: |\n    foo\n  properties:\n    quarks: {}\n  stemcell: \"\"\n  vm_resources: null\n- azs: null\n  env:\n    bosh:\n      agent:\n        settings: {}\n      ipv6:\n        enable: false\n  instances: 0\n  jobs: null\n  name: |\n    bar\n  properties:\n    quarks: {}\n  stemcell: \"\"\n  vm_resources: null\n"}`))
 	})
 
+	It("interpolates the username and password of user variables", func() {
+		baseManifest = []byte(`
+---
+director_uuid: ((password1))
+instance_groups:
+- name: ((user1.username))
+- name: ((user1.password))
+`)
+		err := InterpolateVariables(log, baseManifest, varDir, outputFilePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		dataBytes, err := ioutil.ReadFile(outputFilePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dataBytes)).To(ContainSubstring(`director_uuid: |\n  fake-password`))
+		Expect(string(dataBytes)).To(ContainSubstring(`name: |\n    admin\n`))
+		Expect(string(dataBytes)).To(ContainSubstring(`name: |\n    fake-user-password\n`))
+	})
+
 	It("raises error when variablesDir is not directory", func() {
 		varDir = assetPath + "/nonexisting"
 		err := InterpolateVariables(log, baseManifest, varDir, outputFilePath)
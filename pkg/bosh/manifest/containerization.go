@@ -4,23 +4,27 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpm"
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
 )
 
 // Quarks represents the special 'quarks' property key.
 // It contains all kubernetes structures we need to add to the BOSH manifest.
 type Quarks struct {
-	Consumes            map[string]JobLink      `json:"consumes"`
-	Instances           []JobInstance           `json:"instances"`
-	Release             string                  `json:"release"`
-	BPM                 *bpm.Config             `json:"bpm,omitempty" yaml:"bpm,omitempty"`
-	Ports               []Port                  `json:"ports"`
-	Run                 RunConfig               `json:"run"`
-	PreRenderScripts    PreRenderScripts        `json:"pre_render_scripts" yaml:"pre_render_scripts"`
-	PostStart           PostStart               `json:"post_start"`
-	Debug               bool                    `json:"debug" yaml:"debug"`
-	IsAddon             bool                    `json:"is_addon" yaml:"is_addon"`
-	Envs                []corev1.EnvVar         `json:"envs" yaml:"envs"`
-	ActivePassiveProbes map[string]corev1.Probe `json:"activePassiveProbes,omitempty"`
+	Consumes            map[string]JobLink                     `json:"consumes"`
+	Instances           []JobInstance                          `json:"instances"`
+	Release             string                                 `json:"release"`
+	BPM                 *bpm.Config                            `json:"bpm,omitempty" yaml:"bpm,omitempty"`
+	Ports               []Port                                 `json:"ports"`
+	Run                 RunConfig                              `json:"run"`
+	PreRenderScripts    PreRenderScripts                       `json:"pre_render_scripts" yaml:"pre_render_scripts"`
+	PostStart           PostStart                              `json:"post_start"`
+	Drain               Drain                                  `json:"drain"`
+	RestartPolicy       string                                 `json:"restart_policy,omitempty" yaml:"restart_policy,omitempty"`
+	MaxRestarts         *int                                   `json:"max_restarts,omitempty" yaml:"max_restarts,omitempty"`
+	Debug               bool                                   `json:"debug" yaml:"debug"`
+	IsAddon             bool                                   `json:"is_addon" yaml:"is_addon"`
+	Envs                []corev1.EnvVar                        `json:"envs" yaml:"envs"`
+	ActivePassiveProbes map[string]qstsv1a1.ActivePassiveProbe `json:"activePassiveProbes,omitempty"`
 }
 
 // Port represents the port to be opened up for this job.
@@ -82,6 +86,13 @@ type PostStartCondition struct {
 	Exec *corev1.ExecAction `json:"exec,omitempty"`
 }
 
+// Drain allows drain specifics to be passed through the manifest.
+type Drain struct {
+	// Timeout is the maximum time in seconds the drain scripts of the job
+	// take, the termination grace period of the pod is derived from it.
+	Timeout int `json:"timeout,omitempty"`
+}
+
 // QuarksLink represents the links to share/discover information between BOSH and Kube Native components
 type QuarksLink struct {
 	Type      string        `json:"type,omitempty"`
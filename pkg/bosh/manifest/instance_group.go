@@ -11,6 +11,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util"
 	"code.cloudfoundry.org/quarks-utils/pkg/names"
 )
@@ -51,7 +52,64 @@ type InstanceGroup struct {
 
 // InstanceGroupQuarks represents the quark property of a InstanceGroup
 type InstanceGroupQuarks struct {
-	RequiredService *string `json:"required_service,omitempty" mapstructure:"required_service"`
+	RequiredService     *string              `json:"required_service,omitempty" mapstructure:"required_service"`
+	PodDisruptionBudget *PodDisruptionBudget `json:"pod_disruption_budget,omitempty" mapstructure:"pod_disruption_budget"`
+	PostDeploy          bool                 `json:"post_deploy,omitempty" mapstructure:"post_deploy"`
+	Logs                *Logs                `json:"logs,omitempty" mapstructure:"logs"`
+	ActivePassive       *ActivePassive       `json:"active_passive,omitempty" mapstructure:"active_passive"`
+	Autoscaling         *Autoscaling         `json:"autoscaling,omitempty" mapstructure:"autoscaling"`
+}
+
+// Autoscaling configures a HorizontalPodAutoscaler for the instance group.
+// The instances are counted across all zones, the target utilizations are
+// percentages of the requested resources.
+type Autoscaling struct {
+	MinInstances            int    `json:"min_instances,omitempty" mapstructure:"min_instances"`
+	MaxInstances            int    `json:"max_instances" mapstructure:"max_instances"`
+	TargetCPUUtilization    *int32 `json:"target_cpu_utilization,omitempty" mapstructure:"target_cpu_utilization"`
+	TargetMemoryUtilization *int32 `json:"target_memory_utilization,omitempty" mapstructure:"target_memory_utilization"`
+}
+
+// ActivePassive configures how the active pods are selected from the pods
+// passing the active/passive probes. In exclusive mode at most max_active
+// pods are active.
+type ActivePassive struct {
+	Exclusive bool `json:"exclusive" mapstructure:"exclusive"`
+	MaxActive int  `json:"max_active,omitempty" mapstructure:"max_active"`
+}
+
+// Logs configures the logs sidecar, which tails the log files of the jobs.
+// Include and exclude are globs of the files to tail, multiline is a regular
+// expression matching the first line of an entry.
+type Logs struct {
+	Include   []string `json:"include,omitempty" mapstructure:"include"`
+	Exclude   []string `json:"exclude,omitempty" mapstructure:"exclude"`
+	Multiline string   `json:"multiline,omitempty" mapstructure:"multiline"`
+	Syslog    *Syslog  `json:"syslog,omitempty" mapstructure:"syslog"`
+}
+
+// Syslog is the endpoint the logs sidecar forwards the logs to. Transport is
+// tcp, udp or tls.
+type Syslog struct {
+	Address   string    `json:"address" mapstructure:"address"`
+	Transport string    `json:"transport,omitempty" mapstructure:"transport"`
+	CA        *SyslogCA `json:"ca,omitempty" mapstructure:"ca"`
+}
+
+// SyslogCA references the key of a secret in the namespace, which contains
+// the PEM encoded CA certificates verifying a tls endpoint. The key is
+// 'certificate' by default.
+type SyslogCA struct {
+	Secret string `json:"secret" mapstructure:"secret"`
+	Key    string `json:"key,omitempty" mapstructure:"key"`
+}
+
+// PodDisruptionBudget overrides the disruption budget derived from the
+// instances and the update block of an instance group. Values are absolute
+// numbers or percentages of the instances.
+type PodDisruptionBudget struct {
+	MinAvailable   string `json:"min_available,omitempty" mapstructure:"min_available"`
+	MaxUnavailable string `json:"max_unavailable,omitempty" mapstructure:"max_unavailable"`
 }
 
 // InstanceGroupProperties represents the properties map of a InstanceGroup
@@ -102,8 +160,8 @@ func (ig *InstanceGroup) NameSanitized() string {
 }
 
 // ActivePassiveProbes returns all the probes defined in the instance group jobs
-func (ig *InstanceGroup) ActivePassiveProbes() map[string]corev1.Probe {
-	probes := map[string]corev1.Probe{}
+func (ig *InstanceGroup) ActivePassiveProbes() map[string]qstsv1a1.ActivePassiveProbe {
+	probes := map[string]qstsv1a1.ActivePassiveProbe{}
 	for _, job := range ig.Jobs {
 		for container, probe := range job.Properties.Quarks.ActivePassiveProbes {
 			probes[container] = probe
@@ -125,6 +183,13 @@ func (ig *InstanceGroup) IndexedServiceName(deploymentName string, index int) st
 	return fmt.Sprintf("%s-%d", sn, index)
 }
 
+// AllServiceName constructs the name of the headless service, which publishes
+// all instances, not only the ready ones.
+func (ig *InstanceGroup) AllServiceName(deploymentName string) string {
+	sn := util.ServiceName(ig.Name, deploymentName, 59)
+	return fmt.Sprintf("%s-all", sn)
+}
+
 func (ig *InstanceGroup) jobInstances(
 	deploymentName string,
 	jobName string,
@@ -196,7 +261,7 @@ type Network struct {
 
 // Update from BOSH deployment manifest.
 type Update struct {
-	Canaries        int     `json:"canaries"`
+	Canaries        *int    `json:"canaries"` // must be pointer, because zero canaries are valid
 	MaxInFlight     string  `json:"max_in_flight"`
 	CanaryWatchTime string  `json:"canary_watch_time"`
 	UpdateWatchTime string  `json:"update_watch_time"`
@@ -230,6 +295,8 @@ var (
 	LabelDeploymentVersion = fmt.Sprintf("%s/deployment-version", apis.GroupName)
 	// LabelReferencedJobName is the name key for dependent job
 	LabelReferencedJobName = fmt.Sprintf("%s/referenced-job-name", apis.GroupName)
+	// LabelPostDeploy is the name of a label for the instance group of a post-deploy pod.
+	LabelPostDeploy = fmt.Sprintf("%s/post-deploy", apis.GroupName)
 )
 
 // AgentSettings from BOSH deployment manifest.
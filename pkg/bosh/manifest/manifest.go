@@ -71,6 +71,30 @@ type VariableOptions struct {
 	SignerType                  string                    `json:"signer_type,omitempty"`
 	ServiceRef                  []qsv1a1.ServiceReference `json:"serviceRef,omitempty"`
 	ActivateEKSWorkaroundForSAN bool                      `json:"activateEKSWorkaroundForSAN,omitempty"`
+	// Duration of the certificate in days
+	Duration int `json:"duration,omitempty"`
+
+	// Password options, see https://bosh.io/docs/variable-types/#password
+	Length            int    `json:"length,omitempty"`
+	ExcludeUpper      bool   `json:"exclude_upper,omitempty"`
+	ExcludeLower      bool   `json:"exclude_lower,omitempty"`
+	ExcludeNumber     bool   `json:"exclude_number,omitempty"`
+	IncludeSpecial    bool   `json:"include_special,omitempty"`
+	RequireEachClass  bool   `json:"require_each_class,omitempty"`
+	MinSpecial        int    `json:"min_special,omitempty"`
+	ExcludeCharacters string `json:"exclude_characters,omitempty"`
+	Passphrase        bool   `json:"passphrase,omitempty"`
+	Words             int    `json:"words,omitempty"`
+	Separator         string `json:"separator,omitempty"`
+
+	// Key options for certificate, rsa and ssh variables. The algorithm is
+	// one of rsa, ecdsa or ed25519. The key length is also the size of the
+	// prime of dhparams variables.
+	KeyLength int    `json:"key_length,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Username of user and htpasswd variables, it's generated if empty
+	Username string `json:"username,omitempty"`
 }
 
 // Variable from BOSH deployment manifest
@@ -507,6 +531,12 @@ func (m *Manifest) PropagateGlobalUpdateBlockToIGs() {
 		if ig.Update == nil {
 			ig.Update = m.Update
 		} else {
+			if ig.Update.Canaries == nil {
+				ig.Update.Canaries = m.Update.Canaries
+			}
+			if ig.Update.MaxInFlight == "" {
+				ig.Update.MaxInFlight = m.Update.MaxInFlight
+			}
 			if ig.Update.CanaryWatchTime == "" {
 				ig.Update.CanaryWatchTime = m.Update.CanaryWatchTime
 			}
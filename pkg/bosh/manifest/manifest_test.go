@@ -14,6 +14,7 @@ import (
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
 	"code.cloudfoundry.org/cf-operator/testing"
 	"code.cloudfoundry.org/cf-operator/testing/boshmanifest"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
 )
 
 var (
@@ -1455,18 +1456,24 @@ var _ = Describe("Manifest", func() {
 				manifest.ApplyUpdateBlock(dns)
 				By("propagating if ig has no update block")
 				Expect(*manifest.InstanceGroups[0].Update).To(Equal(Update{
+					Canaries:        pointers.Int(2),
+					MaxInFlight:     "25%",
 					CanaryWatchTime: "20000-1200000",
 					UpdateWatchTime: "20000-1200000",
 					Serial:          pointer.BoolPtr(false),
 				}))
 				By("retaining ig's serial configuration")
 				Expect(*manifest.InstanceGroups[1].Update).To(Equal(Update{
+					Canaries:        pointers.Int(2),
+					MaxInFlight:     "25%",
 					CanaryWatchTime: "20000-1200000",
 					UpdateWatchTime: "20000-1200000",
 					Serial:          pointer.BoolPtr(true),
 				}))
-				By("retaining ig's canaryWatchTime configuration")
+				By("retaining ig's canaries, max_in_flight and canaryWatchTime configuration")
 				Expect(*manifest.InstanceGroups[2].Update).To(Equal(Update{
+					Canaries:        pointers.Int(0),
+					MaxInFlight:     "1",
 					CanaryWatchTime: "10000-9900000",
 					UpdateWatchTime: "10000-9900000",
 					Serial:          pointer.BoolPtr(false),
@@ -0,0 +1,302 @@
+// Package plan calculates the changes to kubernetes resources, which
+// deploying a new BOSH manifest would cause.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpm"
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpmconverter"
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/converter"
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
+)
+
+// Valid values for the action of a planned change
+const (
+	// ActionAdded means the resource will be created
+	ActionAdded = "added"
+	// ActionModified means the resource will be updated
+	ActionModified = "modified"
+	// ActionRemoved means the resource is no longer part of the deployment
+	ActionRemoved = "removed"
+)
+
+// Kinds of the compared resources
+const (
+	KindInstanceGroup       = "InstanceGroup"
+	KindQuarksStatefulSet   = "QuarksStatefulSet"
+	KindQuarksJob           = "QuarksJob"
+	KindQuarksSecret        = "QuarksSecret"
+	KindService             = "Service"
+	KindPodDisruptionBudget = "PodDisruptionBudget"
+)
+
+// planVersion is used for all versions in generated resources, so they
+// don't show up as changes
+const planVersion = "1"
+
+// instanceGroupSummary contains the fields of an instance group, which are
+// compared. Properties are left out, since they are not interpolated in the
+// with-ops manifest.
+type instanceGroupSummary struct {
+	Instances      int                   `json:"instances"`
+	AZs            []string              `json:"azs,omitempty"`
+	LifeCycle      bdm.InstanceGroupType `json:"lifecycle,omitempty"`
+	Jobs           []string              `json:"jobs,omitempty"`
+	Stemcell       string                `json:"stemcell,omitempty"`
+	VMType         string                `json:"vm_type,omitempty"`
+	VMResources    *bdm.VMResource       `json:"vm_resources,omitempty"`
+	PersistentDisk *int                  `json:"persistent_disk,omitempty"`
+}
+
+// resources maps kind and name to the generated resource
+type resources map[string]map[string]interface{}
+
+func (r resources) add(kind, name string, object interface{}) {
+	if _, ok := r[kind]; !ok {
+		r[kind] = map[string]interface{}{}
+	}
+	r[kind][name] = object
+}
+
+// Plan generates the resources for the deployed and the new manifest and
+// returns the differences. The deployed manifest can be nil, if nothing was
+// deployed yet.
+// Since job templates are not rendered, BPM configurations are taken from
+// the manifest's quarks properties or default to one process per job.
+func Plan(namespace, deploymentName string, deployed, manifest *bdm.Manifest) ([]bdv1.PlannedChange, error) {
+	current, err := generate(namespace, deploymentName, deployed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate resources of deployed manifest")
+	}
+
+	desired, err := generate(namespace, deploymentName, manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate resources of new manifest")
+	}
+
+	return diff(current, desired)
+}
+
+// generate returns the resources for a manifest
+func generate(namespace, deploymentName string, m *bdm.Manifest) (resources, error) {
+	res := resources{}
+	if m == nil {
+		return res, nil
+	}
+
+	// The bpm converter modifies the instance groups, work on a copy
+	data, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	m, err = bdm.LoadYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := converter.NewVariablesConverter(namespace).Variables(deploymentName, m.Variables)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range secrets {
+		res.add(KindQuarksSecret, s.Name, s.Spec)
+	}
+
+	dns, err := boshdns.NewDNS(deploymentName, *m)
+	if err != nil {
+		return nil, err
+	}
+
+	bpmConverter := bpmconverter.NewConverter(
+		namespace,
+		bpmconverter.NewVolumeFactory(),
+		func(deploymentName string, instanceGroupName string, version string, disableLogSidecar bool, releaseImageProvider bdm.ReleaseImageProvider, bpmConfigs bpm.Configs) bpmconverter.ContainerFactory {
+			return bpmconverter.NewContainerFactory(deploymentName, instanceGroupName, version, disableLogSidecar, releaseImageProvider, bpmConfigs)
+		},
+	)
+
+	for _, ig := range m.InstanceGroups {
+		summary := instanceGroupSummary{
+			Instances:      ig.Instances,
+			AZs:            ig.AZs,
+			LifeCycle:      ig.LifeCycle,
+			Stemcell:       ig.Stemcell,
+			VMType:         ig.VMType,
+			VMResources:    ig.VMResources,
+			PersistentDisk: ig.PersistentDisk,
+		}
+		for _, job := range ig.Jobs {
+			summary.Jobs = append(summary.Jobs, fmt.Sprintf("%s/%s", job.Release, job.Name))
+		}
+		res.add(KindInstanceGroup, ig.Name, summary)
+
+		r, err := bpmConverter.Resources(deploymentName, dns, planVersion, ig, m, bpmConfigs(ig), planVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to generate resources for instance group '%s'", ig.Name)
+		}
+		for _, qsts := range r.InstanceGroups {
+			res.add(KindQuarksStatefulSet, qsts.Name, qsts.Spec)
+		}
+		for _, qjob := range r.Errands {
+			res.add(KindQuarksJob, qjob.Name, qjob.Spec)
+		}
+		for _, svc := range r.Services {
+			res.add(KindService, svc.Name, svc.Spec)
+		}
+		for _, pdb := range r.PodDisruptionBudgets {
+			res.add(KindPodDisruptionBudget, pdb.Name, pdb.Spec)
+		}
+	}
+
+	return res, nil
+}
+
+// bpmConfigs returns the BPM configs from the job properties, or a config
+// with one process named after the job
+func bpmConfigs(ig *bdm.InstanceGroup) bpm.Configs {
+	configs := bpm.Configs{}
+	for _, job := range ig.Jobs {
+		if job.Properties.Quarks.BPM != nil {
+			configs[job.Name] = *job.Properties.Quarks.BPM
+			continue
+		}
+		configs[job.Name] = bpm.Config{
+			Processes: []bpm.Process{{Name: job.Name}},
+		}
+	}
+	return configs
+}
+
+// diff compares the resources by kind and name
+func diff(current, desired resources) ([]bdv1.PlannedChange, error) {
+	changes := []bdv1.PlannedChange{}
+
+	for _, kind := range []string{KindInstanceGroup, KindQuarksSecret, KindQuarksStatefulSet, KindQuarksJob, KindService, KindPodDisruptionBudget} {
+		names := map[string]bool{}
+		for name := range current[kind] {
+			names[name] = true
+		}
+		for name := range desired[kind] {
+			names[name] = true
+		}
+
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+
+		for _, name := range sorted {
+			old, inCurrent := current[kind][name]
+			new, inDesired := desired[kind][name]
+
+			switch {
+			case !inCurrent:
+				changes = append(changes, bdv1.PlannedChange{Kind: kind, Name: name, Action: ActionAdded})
+			case !inDesired:
+				changes = append(changes, bdv1.PlannedChange{Kind: kind, Name: name, Action: ActionRemoved})
+			default:
+				details, err := fieldChanges(old, new)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to compare %s '%s'", kind, name)
+				}
+				if len(details) > 0 {
+					changes = append(changes, bdv1.PlannedChange{Kind: kind, Name: name, Action: ActionModified, Details: details})
+				}
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// fieldChanges returns the paths of all fields, which differ between the
+// JSON representations of the objects
+func fieldChanges(old, new interface{}) ([]string, error) {
+	o, err := toJSONValue(old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := toJSONValue(new)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []string{}
+	compare("", o, n, &changes)
+	sort.Strings(changes)
+	return changes, nil
+}
+
+func toJSONValue(object interface{}) (interface{}, error) {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+func compare(path string, old, new interface{}, changes *[]string) {
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			keys := map[string]bool{}
+			for k := range o {
+				keys[k] = true
+			}
+			for k := range n {
+				keys[k] = true
+			}
+			for k := range keys {
+				compare(joinPath(path, k), o[k], n[k], changes)
+			}
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok && len(o) == len(n) {
+			for i := range o {
+				compare(fmt.Sprintf("%s[%d]", path, i), o[i], n[i], changes)
+			}
+			return
+		}
+	}
+
+	*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, short(old), short(new)))
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// short returns a compact representation of a value, nested values are
+// not printed
+func short(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "<none>"
+	case map[string]interface{}:
+		return "{...}"
+	case []interface{}:
+		return fmt.Sprintf("[%d items]", len(v))
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
@@ -0,0 +1,141 @@
+package plan_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/plan"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+)
+
+var _ = Describe("Plan", func() {
+	var (
+		deployed *bdm.Manifest
+		manifest *bdm.Manifest
+	)
+
+	load := func(text string) *bdm.Manifest {
+		m, err := bdm.LoadYAML([]byte(text))
+		Expect(err).ToNot(HaveOccurred())
+		return m
+	}
+
+	find := func(changes []bdv1.PlannedChange, kind, name string) *bdv1.PlannedChange {
+		for i := range changes {
+			if changes[i].Kind == kind && changes[i].Name == name {
+				return &changes[i]
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		deployed = load(`---
+name: foo
+releases:
+- name: redis
+  url: docker.io/cfcontainerization
+  version: 36.15.0
+  stemcell:
+    os: opensuse-42.3
+    version: 28.g837c5b3-30.263-7.0.0_234.gcd7d1132
+instance_groups:
+- name: redis-slave
+  instances: 2
+  lifecycle: service
+  jobs:
+  - name: redis-server
+    release: redis
+    properties:
+      quarks:
+        ports:
+        - name: redis
+          protocol: TCP
+          internal: 6379
+- name: smoke
+  instances: 1
+  lifecycle: errand
+  jobs:
+  - name: smoke-tests
+    release: redis
+variables:
+- name: redis-password
+  type: password
+`)
+		manifest = load(`---
+name: foo
+releases:
+- name: redis
+  url: docker.io/cfcontainerization
+  version: 36.15.0
+  stemcell:
+    os: opensuse-42.3
+    version: 28.g837c5b3-30.263-7.0.0_234.gcd7d1132
+instance_groups:
+- name: redis-slave
+  instances: 3
+  lifecycle: service
+  jobs:
+  - name: redis-server
+    release: redis
+    properties:
+      quarks:
+        ports:
+        - name: redis
+          protocol: TCP
+          internal: 6379
+- name: sentinel
+  instances: 1
+  jobs:
+  - name: sentinel
+    release: redis
+variables:
+- name: redis-password
+  type: password
+- name: sentinel-password
+  type: password
+`)
+	})
+
+	It("lists all resources as added if nothing was deployed", func() {
+		changes, err := plan.Plan("default", "foo", nil, manifest)
+		Expect(err).ToNot(HaveOccurred())
+
+		for _, c := range changes {
+			Expect(c.Action).To(Equal(plan.ActionAdded))
+		}
+		Expect(find(changes, plan.KindInstanceGroup, "redis-slave")).ToNot(BeNil())
+		Expect(find(changes, plan.KindQuarksStatefulSet, "foo-redis-slave")).ToNot(BeNil())
+		Expect(find(changes, plan.KindQuarksSecret, "foo.var-redis-password")).ToNot(BeNil())
+		Expect(find(changes, plan.KindService, "foo-redis-slave")).ToNot(BeNil())
+	})
+
+	It("returns no changes for the same manifest", func() {
+		changes, err := plan.Plan("default", "foo", deployed, deployed)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("lists added, modified and removed resources", func() {
+		changes, err := plan.Plan("default", "foo", deployed, manifest)
+		Expect(err).ToNot(HaveOccurred())
+
+		ig := find(changes, plan.KindInstanceGroup, "redis-slave")
+		Expect(ig).ToNot(BeNil())
+		Expect(ig.Action).To(Equal(plan.ActionModified))
+		Expect(ig.Details).To(Equal([]string{"instances: 2 -> 3"}))
+
+		qsts := find(changes, plan.KindQuarksStatefulSet, "foo-redis-slave")
+		Expect(qsts).ToNot(BeNil())
+		Expect(qsts.Action).To(Equal(plan.ActionModified))
+		Expect(qsts.Details).To(ContainElement("template.spec.replicas: 2 -> 3"))
+
+		Expect(find(changes, plan.KindInstanceGroup, "sentinel").Action).To(Equal(plan.ActionAdded))
+		Expect(find(changes, plan.KindQuarksStatefulSet, "foo-sentinel").Action).To(Equal(plan.ActionAdded))
+		Expect(find(changes, plan.KindQuarksSecret, "foo.var-sentinel-password").Action).To(Equal(plan.ActionAdded))
+		Expect(find(changes, plan.KindInstanceGroup, "smoke").Action).To(Equal(plan.ActionRemoved))
+		Expect(find(changes, plan.KindQuarksJob, "foo-smoke").Action).To(Equal(plan.ActionRemoved))
+		Expect(find(changes, plan.KindQuarksSecret, "foo.var-redis-password")).To(BeNil())
+	})
+})
@@ -88,7 +88,7 @@ func (f *JobFactory) VariableInterpolationJob(deploymentName string, manifest bd
 		volumeMounts = append(volumeMounts, noVarsVolumeMount())
 	}
 
-	qJobName := fmt.Sprintf("dm-%s", deploymentName)
+	qJobName := VariableInterpolationJobName(deploymentName)
 	secretName := names.DesiredManifestPrefix(deploymentName) + VarInterpolationContainerName
 
 	// Construct the var interpolation auto-errand qJob
@@ -183,7 +183,7 @@ func (f *JobFactory) InstanceGroupManifestJob(deploymentName string, manifest bd
 		}
 	}
 
-	qJobName := fmt.Sprintf("ig-%s", deploymentName)
+	qJobName := InstanceGroupManifestJobName(deploymentName)
 	qJob, err := f.releaseImageQJob(qJobName, deploymentName, manifest, containers, linkInfos.Volumes())
 	if err != nil {
 		return nil, err
@@ -200,6 +200,16 @@ func (f *JobFactory) InstanceGroupManifestJob(deploymentName string, manifest bd
 	return qJob, nil
 }
 
+// VariableInterpolationJobName returns the name of the QuarksJob, which renders the desired manifest
+func VariableInterpolationJobName(deploymentName string) string {
+	return fmt.Sprintf("dm-%s", deploymentName)
+}
+
+// InstanceGroupManifestJobName returns the name of the QuarksJob, which renders the instance group manifests and BPM configs
+func InstanceGroupManifestJobName(deploymentName string) string {
+	return fmt.Sprintf("ig-%s", deploymentName)
+}
+
 // desiredManifestName returns the sanitized, versioned name of the manifest.
 // QuarksJob will always pick the latest version for versioned secrets
 func desiredManifestName(name string) string {
@@ -0,0 +1,408 @@
+package externalgenerator
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+)
+
+// CredentialsPath is the path of the credential store API, relative to the
+// store's URL
+const CredentialsPath = "/credentials"
+
+// Credential types understood by the credential store
+const (
+	TypePassword           = "password"
+	TypeCertificate        = "certificate"
+	TypeCertificateRequest = "certificate-request"
+	TypeSSHKey             = "ssh"
+	TypeRSAKey             = "rsa"
+	TypeUser               = "user"
+	TypeHTPasswd           = "htpasswd"
+	TypeDHParams           = "dhparams"
+)
+
+// requestTimeout limits the time waiting for the credential store
+const requestTimeout = 30 * time.Second
+
+// CA references the signing CA of certificate requests by its certificate.
+// The CA's private key is never sent, the store signs with the key it keeps
+// for this certificate.
+type CA struct {
+	Certificate string `json:"certificate"`
+}
+
+// PasswordPolicy contains the password policy of a password request
+type PasswordPolicy struct {
+	ExcludeUpper      bool   `json:"excludeUpper,omitempty"`
+	ExcludeLower      bool   `json:"excludeLower,omitempty"`
+	ExcludeNumber     bool   `json:"excludeNumber,omitempty"`
+	IncludeSpecial    bool   `json:"includeSpecial,omitempty"`
+	RequireEachClass  bool   `json:"requireEachClass,omitempty"`
+	MinSpecial        int    `json:"minSpecial,omitempty"`
+	ExcludeCharacters string `json:"excludeCharacters,omitempty"`
+	Passphrase        bool   `json:"passphrase,omitempty"`
+	Words             int    `json:"words,omitempty"`
+	Separator         string `json:"separator,omitempty"`
+}
+
+// GenerationRequest is posted to the credential store
+type GenerationRequest struct {
+	// Namespace and Name identify the credential in the store
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Type      string `json:"type"`
+	// Regenerate asks the store to replace the credential it keeps, it's
+	// set when a credential is rotated or renewed
+	Regenerate bool `json:"regenerate,omitempty"`
+	Length     int  `json:"length,omitempty"`
+	PasswordPolicy
+	CommonName       string   `json:"commonName,omitempty"`
+	AlternativeNames []string `json:"alternativeNames,omitempty"`
+	IsCA             bool     `json:"isCA,omitempty"`
+	CA               *CA      `json:"ca,omitempty"`
+	KeyAlgorithm     string   `json:"keyAlgorithm,omitempty"`
+	KeyBits          int      `json:"keyBits,omitempty"`
+	// DurationSeconds is the requested validity of a certificate
+	DurationSeconds int64 `json:"durationSeconds,omitempty"`
+	// Username of user and htpasswd credentials, the store generates one
+	// if it's empty
+	Username     string `json:"username,omitempty"`
+	DHParamsBits int    `json:"dhParamsBits,omitempty"`
+}
+
+// Credential is returned by the credential store
+type Credential struct {
+	Password           string `json:"password,omitempty"`
+	Certificate        string `json:"certificate,omitempty"`
+	CertificateRequest string `json:"certificateRequest,omitempty"`
+	PrivateKey         string `json:"privateKey,omitempty"`
+	PublicKey          string `json:"publicKey,omitempty"`
+	Fingerprint        string `json:"fingerprint,omitempty"`
+	Username           string `json:"username,omitempty"`
+	HTPasswd           string `json:"htpasswd,omitempty"`
+	DHParams           string `json:"dhparams,omitempty"`
+}
+
+// ExternalGenerator represents a secret generator that requests all
+// credentials from an external credential store via HTTP. Credential names
+// have to be qualified by their namespace, i.e. 'namespace/name'.
+type ExternalGenerator struct {
+	url        string
+	token      string
+	client     *http.Client
+	regenerate bool
+
+	log *zap.SugaredLogger
+}
+
+var _ credsgen.StoringGenerator = ExternalGenerator{}
+
+// NewExternalGenerator creates an ExternalGenerator for the credential store
+// at storeURL, which has to use https. The CA certificate is used to verify
+// the store's TLS certificate and the token is sent as bearer token, both
+// are optional.
+func NewExternalGenerator(log *zap.SugaredLogger, storeURL string, caCert []byte, token string) (*ExternalGenerator, error) {
+	return newExternalGenerator(log, storeURL, caCert, token, false)
+}
+
+// NewInsecureExternalGenerator creates an ExternalGenerator, which accepts
+// plain http store urls. It's only meant for tests, credentials must not be
+// sent unencrypted.
+func NewInsecureExternalGenerator(log *zap.SugaredLogger, storeURL string, token string) (*ExternalGenerator, error) {
+	return newExternalGenerator(log, storeURL, nil, token, true)
+}
+
+func newExternalGenerator(log *zap.SugaredLogger, storeURL string, caCert []byte, token string, insecure bool) (*ExternalGenerator, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid credential store url '%s'", storeURL)
+	}
+	if u.Scheme != "https" && !(insecure && u.Scheme == "http") {
+		return nil, fmt.Errorf("unsupported scheme '%s' in credential store url '%s', expected https", u.Scheme, storeURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(caCert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("failed to parse CA certificate of credential store")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &ExternalGenerator{
+		url:    strings.TrimSuffix(storeURL, "/") + CredentialsPath,
+		token:  token,
+		client: &http.Client{Transport: transport, Timeout: requestTimeout},
+		log:    log,
+	}, nil
+}
+
+// Regenerating returns a copy of the generator, which asks the store to
+// replace the credentials it keeps
+func (g ExternalGenerator) Regenerating() credsgen.Generator {
+	g.regenerate = true
+	return g
+}
+
+// GeneratePassword requests a password from the credential store
+func (g ExternalGenerator) GeneratePassword(name string, request credsgen.PasswordGenerationRequest) (string, error) {
+	g.log.Debugf("Requesting password %s from credential store", name)
+
+	credential, err := g.request(name, GenerationRequest{
+		Type:           TypePassword,
+		Length:         request.Length,
+		PasswordPolicy: passwordPolicy(request),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "Requesting password failed for secret %s", name)
+	}
+	if credential.Password == "" {
+		return "", fmt.Errorf("credential store returned an empty password for secret %s", name)
+	}
+
+	return credential.Password, nil
+}
+
+// GenerateCertificate requests a certificate from the credential store. If
+// the request contains a CA, it's passed to the store for signing.
+func (g ExternalGenerator) GenerateCertificate(name string, request credsgen.CertificateGenerationRequest) (credsgen.Certificate, error) {
+	g.log.Debugf("Requesting certificate %s from credential store", name)
+
+	req := certificateRequest(request)
+	req.Type = TypeCertificate
+
+	credential, err := g.request(name, req)
+	if err != nil {
+		return credsgen.Certificate{}, errors.Wrapf(err, "Requesting certificate failed for secret %s", name)
+	}
+	if credential.Certificate == "" || credential.PrivateKey == "" {
+		return credsgen.Certificate{}, fmt.Errorf("credential store returned an incomplete certificate for secret %s", name)
+	}
+
+	return credsgen.Certificate{
+		IsCA:        request.IsCA,
+		Certificate: []byte(credential.Certificate),
+		PrivateKey:  []byte(credential.PrivateKey),
+	}, nil
+}
+
+// GenerateCertificateSigningRequest requests a certificate signing request
+// and its private key from the credential store
+func (g ExternalGenerator) GenerateCertificateSigningRequest(request credsgen.CertificateGenerationRequest) ([]byte, []byte, error) {
+	g.log.Debugf("Requesting certificate signing request for %s from credential store", request.CommonName)
+
+	req := certificateRequest(request)
+	req.Type = TypeCertificateRequest
+
+	credential, err := g.send(req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Requesting certificate signing request failed")
+	}
+	if credential.CertificateRequest == "" || credential.PrivateKey == "" {
+		return nil, nil, fmt.Errorf("credential store returned an incomplete certificate signing request for %s", request.CommonName)
+	}
+
+	return []byte(credential.CertificateRequest), []byte(credential.PrivateKey), nil
+}
+
+// GenerateSSHKey requests an SSH key from the credential store
+func (g ExternalGenerator) GenerateSSHKey(name string, request credsgen.KeyGenerationRequest) (credsgen.SSHKey, error) {
+	g.log.Debugf("Requesting SSH key %s from credential store", name)
+
+	credential, err := g.request(name, GenerationRequest{
+		Type:         TypeSSHKey,
+		KeyAlgorithm: request.Algorithm,
+		KeyBits:      request.Bits,
+	})
+	if err != nil {
+		return credsgen.SSHKey{}, errors.Wrapf(err, "Requesting ssh key failed for secret %s", name)
+	}
+	if credential.PrivateKey == "" || credential.PublicKey == "" || credential.Fingerprint == "" {
+		return credsgen.SSHKey{}, fmt.Errorf("credential store returned an incomplete ssh key for secret %s", name)
+	}
+
+	return credsgen.SSHKey{
+		PrivateKey:  []byte(credential.PrivateKey),
+		PublicKey:   []byte(credential.PublicKey),
+		Fingerprint: credential.Fingerprint,
+	}, nil
+}
+
+// GenerateRSAKey requests an RSA key from the credential store
+func (g ExternalGenerator) GenerateRSAKey(name string, request credsgen.KeyGenerationRequest) (credsgen.RSAKey, error) {
+	g.log.Debugf("Requesting RSA key %s from credential store", name)
+
+	credential, err := g.request(name, GenerationRequest{
+		Type:         TypeRSAKey,
+		KeyAlgorithm: request.Algorithm,
+		KeyBits:      request.Bits,
+	})
+	if err != nil {
+		return credsgen.RSAKey{}, errors.Wrapf(err, "Requesting rsa key failed for secret %s", name)
+	}
+	if credential.PrivateKey == "" || credential.PublicKey == "" {
+		return credsgen.RSAKey{}, fmt.Errorf("credential store returned an incomplete rsa key for secret %s", name)
+	}
+
+	return credsgen.RSAKey{
+		PrivateKey: []byte(credential.PrivateKey),
+		PublicKey:  []byte(credential.PublicKey),
+	}, nil
+}
+
+// GenerateUser requests a username and password pair from the credential store
+func (g ExternalGenerator) GenerateUser(name string, request credsgen.UserGenerationRequest) (credsgen.User, error) {
+	g.log.Debugf("Requesting user %s from credential store", name)
+
+	credential, err := g.request(name, userRequest(TypeUser, request))
+	if err != nil {
+		return credsgen.User{}, errors.Wrapf(err, "Requesting user failed for secret %s", name)
+	}
+	if credential.Username == "" || credential.Password == "" {
+		return credsgen.User{}, fmt.Errorf("credential store returned an incomplete user for secret %s", name)
+	}
+
+	return credsgen.User{Username: credential.Username, Password: credential.Password}, nil
+}
+
+// GenerateHTPasswd requests a user and its htpasswd entry from the credential
+// store
+func (g ExternalGenerator) GenerateHTPasswd(name string, request credsgen.UserGenerationRequest) (credsgen.HTPasswd, error) {
+	g.log.Debugf("Requesting htpasswd %s from credential store", name)
+
+	credential, err := g.request(name, userRequest(TypeHTPasswd, request))
+	if err != nil {
+		return credsgen.HTPasswd{}, errors.Wrapf(err, "Requesting htpasswd failed for secret %s", name)
+	}
+	if credential.Username == "" || credential.Password == "" || credential.HTPasswd == "" {
+		return credsgen.HTPasswd{}, fmt.Errorf("credential store returned an incomplete htpasswd for secret %s", name)
+	}
+
+	return credsgen.HTPasswd{
+		Username: credential.Username,
+		Password: credential.Password,
+		Entry:    credential.HTPasswd,
+	}, nil
+}
+
+// GenerateDHParams requests Diffie-Hellman parameters from the credential store
+func (g ExternalGenerator) GenerateDHParams(name string, request credsgen.DHParamsGenerationRequest) ([]byte, error) {
+	g.log.Debugf("Requesting DH parameters %s from credential store", name)
+
+	credential, err := g.request(name, GenerationRequest{
+		Type:         TypeDHParams,
+		DHParamsBits: request.Bits,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Requesting DH parameters failed for secret %s", name)
+	}
+	if credential.DHParams == "" {
+		return nil, fmt.Errorf("credential store returned empty DH parameters for secret %s", name)
+	}
+
+	return []byte(credential.DHParams), nil
+}
+
+func passwordPolicy(request credsgen.PasswordGenerationRequest) PasswordPolicy {
+	return PasswordPolicy{
+		ExcludeUpper:      request.ExcludeUpper,
+		ExcludeLower:      request.ExcludeLower,
+		ExcludeNumber:     request.ExcludeNumber,
+		IncludeSpecial:    request.IncludeSpecial,
+		RequireEachClass:  request.RequireEachClass,
+		MinSpecial:        request.MinSpecial,
+		ExcludeCharacters: request.ExcludeCharacters,
+		Passphrase:        request.Passphrase,
+		Words:             request.Words,
+		Separator:         request.Separator,
+	}
+}
+
+func userRequest(credentialType string, request credsgen.UserGenerationRequest) GenerationRequest {
+	return GenerationRequest{
+		Type:           credentialType,
+		Username:       request.Username,
+		Length:         request.Password.Length,
+		PasswordPolicy: passwordPolicy(request.Password),
+	}
+}
+
+func certificateRequest(request credsgen.CertificateGenerationRequest) GenerationRequest {
+	req := GenerationRequest{
+		CommonName:       request.CommonName,
+		AlternativeNames: request.AlternativeNames,
+		IsCA:             request.IsCA,
+		KeyAlgorithm:     request.Key.Algorithm,
+		KeyBits:          request.Key.Bits,
+		DurationSeconds:  int64(request.Duration / time.Second),
+	}
+	if len(request.CA.Certificate) > 0 {
+		req.CA = &CA{Certificate: string(request.CA.Certificate)}
+	}
+	return req
+}
+
+// request posts the generation request for the credential identified by the
+// namespace qualified name to the credential store
+func (g ExternalGenerator) request(name string, req GenerationRequest) (Credential, error) {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Credential{}, fmt.Errorf("credential name '%s' is not qualified by a namespace", name)
+	}
+	req.Namespace, req.Name = parts[0], parts[1]
+	req.Regenerate = g.regenerate
+
+	return g.send(req)
+}
+
+// send posts the generation request to the credential store
+func (g ExternalGenerator) send(req GenerationRequest) (Credential, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	httpRequest, err := http.NewRequest(http.MethodPost, g.url, bytes.NewReader(body))
+	if err != nil {
+		return Credential{}, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	response, err := g.client.Do(httpRequest)
+	if err != nil {
+		return Credential{}, errors.Wrap(err, "failed to reach credential store")
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return Credential{}, errors.Wrap(err, "failed to read response of credential store")
+	}
+	if response.StatusCode != http.StatusOK {
+		return Credential{}, fmt.Errorf("credential store returned status %d: %s", response.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	credential := Credential{}
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return Credential{}, errors.Wrap(err, "failed to parse response of credential store")
+	}
+
+	return credential, nil
+}
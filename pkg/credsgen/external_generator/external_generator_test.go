@@ -0,0 +1,299 @@
+package externalgenerator_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	cfssllog "github.com/cloudflare/cfssl/log"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	externalgenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/external_generator"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("ExternalGenerator", func() {
+	var (
+		dir       string
+		server    *httptest.Server
+		generator credsgen.Generator
+		token     string
+	)
+
+	BeforeEach(func() {
+		cfssllog.Level = cfssllog.LevelFatal
+
+		var err error
+		dir, err = ioutil.TempDir("", "credential-store")
+		Expect(err).ToNot(HaveOccurred())
+		token = "store-token"
+	})
+
+	JustBeforeEach(func() {
+		_, log := helper.NewTestLogger()
+		inMemory := inmemorygenerator.NewInMemoryGenerator(log)
+		// speed up tests with a fast algo
+		inMemory.Algorithm = "ecdsa"
+		inMemory.Bits = 256
+
+		server = httptest.NewTLSServer(externalgenerator.NewFileStore(dir, inMemory, "store-token"))
+		caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+		var err error
+		generator, err = externalgenerator.NewExternalGenerator(log, server.URL, caCert, token)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	Describe("GeneratePassword", func() {
+		It("returns the stored password on following requests", func() {
+			password, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{Length: 10})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(HaveLen(10))
+
+			again, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again).To(Equal(password))
+
+			other, err := generator.GeneratePassword("default/bar", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(other).ToNot(Equal(password))
+		})
+
+		It("passes the password policy to the store", func() {
+			password, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{
+				Passphrase: true,
+				Words:      3,
+				Separator:  ".",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(MatchRegexp(`^[a-z]+\.[a-z]+\.[a-z]+$`))
+		})
+
+		It("keeps the credentials in the store's directory", func() {
+			_, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dir + "/password/default/foo.json").To(BeAnExistingFile())
+		})
+
+		Context("when the token is wrong", func() {
+			BeforeEach(func() {
+				token = "wrong"
+			})
+
+			It("fails", func() {
+				_, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("status 401"))
+			})
+		})
+
+		It("keeps the credentials of each namespace apart", func() {
+			password, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+
+			other, err := generator.GeneratePassword("other/foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(other).ToNot(Equal(password))
+		})
+
+		It("replaces the stored password when regenerating", func() {
+			password, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+
+			storing, ok := generator.(credsgen.StoringGenerator)
+			Expect(ok).To(BeTrue())
+			rotated, err := storing.Regenerating().GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).ToNot(Equal(password))
+
+			again, err := generator.GeneratePassword("default/foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again).To(Equal(rotated))
+		})
+
+		It("rejects names without a namespace", func() {
+			_, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not qualified by a namespace"))
+		})
+
+		It("rejects invalid names", func() {
+			_, err := generator.GeneratePassword("default/../foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid credential name"))
+		})
+	})
+
+	Describe("GenerateCertificate", func() {
+		It("generates certificates signed by the passed CA", func() {
+			ca, err := generator.GenerateCertificate("default/ca", credsgen.CertificateGenerationRequest{CommonName: "Fake CA", IsCA: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ca.IsCA).To(BeTrue())
+
+			cert, err := generator.GenerateCertificate("default/cert", credsgen.CertificateGenerationRequest{CommonName: "foo.com", CA: ca})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cert.PrivateKey).ToNot(BeEmpty())
+
+			block, _ := pem.Decode(cert.Certificate)
+			parsed, err := x509.ParseCertificate(block.Bytes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed.Issuer.CommonName).To(Equal("Fake CA"))
+			Expect(parsed.DNSNames).To(ContainElement("foo.com"))
+		})
+
+		It("doesn't sign with CAs of other namespaces", func() {
+			ca, err := generator.GenerateCertificate("default/ca", credsgen.CertificateGenerationRequest{CommonName: "Fake CA", IsCA: true})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = generator.GenerateCertificate("other/cert", credsgen.CertificateGenerationRequest{CommonName: "foo.com", CA: ca})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("CA certificate is not stored in namespace 'other'"))
+		})
+	})
+
+	Describe("GenerateCertificateSigningRequest", func() {
+		It("returns a CSR and its key", func() {
+			csr, key, err := generator.GenerateCertificateSigningRequest(credsgen.CertificateGenerationRequest{CommonName: "foo.com"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(csr)).To(ContainSubstring("CERTIFICATE REQUEST"))
+			Expect(key).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("GenerateSSHKey", func() {
+		It("returns the key with its fingerprint", func() {
+			key, err := generator.GenerateSSHKey("default/foo", credsgen.KeyGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(key.PublicKey)).To(HavePrefix("ssh-rsa "))
+			Expect(key.Fingerprint).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("GenerateRSAKey", func() {
+		It("returns the key pair", func() {
+			key, err := generator.GenerateRSAKey("default/foo", credsgen.KeyGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(key.PrivateKey)).To(ContainSubstring("RSA PRIVATE KEY"))
+			Expect(string(key.PublicKey)).To(ContainSubstring("PUBLIC KEY"))
+		})
+	})
+
+	Describe("GenerateUser", func() {
+		It("returns the stored user on following requests", func() {
+			user, err := generator.GenerateUser("default/foo", credsgen.UserGenerationRequest{Username: "admin"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.Username).To(Equal("admin"))
+			Expect(user.Password).ToNot(BeEmpty())
+
+			again, err := generator.GenerateUser("default/foo", credsgen.UserGenerationRequest{Username: "admin"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again).To(Equal(user))
+		})
+	})
+
+	Describe("GenerateHTPasswd", func() {
+		It("returns the user with its htpasswd entry", func() {
+			htpasswd, err := generator.GenerateHTPasswd("default/foo", credsgen.UserGenerationRequest{Username: "admin"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(htpasswd.Entry).To(HavePrefix("admin:$2a$"))
+			Expect(htpasswd.Password).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("GenerateDHParams", func() {
+		It("returns the DH parameters", func() {
+			params, err := generator.GenerateDHParams("default/foo", credsgen.DHParamsGenerationRequest{Bits: 1024})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(params)).To(ContainSubstring("BEGIN DH PARAMETERS"))
+		})
+	})
+})
+
+var _ = Describe("NewExternalGenerator", func() {
+	It("rejects unsupported schemes", func() {
+		_, log := helper.NewTestLogger()
+		_, err := externalgenerator.NewExternalGenerator(log, "file:///tmp/store", nil, "")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unsupported scheme"))
+	})
+
+	It("rejects plain http", func() {
+		_, log := helper.NewTestLogger()
+		_, err := externalgenerator.NewExternalGenerator(log, "http://store.example.com", nil, "")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unsupported scheme 'http'"))
+	})
+
+	It("sends the CA certificate without its private key", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/credentials"),
+			ghttp.VerifyJSON(`{"namespace": "default", "name": "cert", "type": "certificate", "commonName": "foo.com", "ca": {"certificate": "ca-cert"}}`),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, externalgenerator.Credential{Certificate: "cert", PrivateKey: "key"}),
+		))
+
+		_, log := helper.NewTestLogger()
+		generator, err := externalgenerator.NewInsecureExternalGenerator(log, server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = generator.GenerateCertificate("default/cert", credsgen.CertificateGenerationRequest{
+			CommonName: "foo.com",
+			CA:         credsgen.Certificate{IsCA: true, Certificate: []byte("ca-cert"), PrivateKey: []byte("ca-key")},
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("reports errors of the credential store", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/credentials"),
+			ghttp.VerifyJSON(`{"namespace": "default", "name": "foo", "type": "rsa"}`),
+			ghttp.RespondWith(http.StatusInternalServerError, "store unavailable"),
+		))
+
+		_, log := helper.NewTestLogger()
+		generator, err := externalgenerator.NewInsecureExternalGenerator(log, server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = generator.GenerateRSAKey("default/foo", credsgen.KeyGenerationRequest{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("status 500: store unavailable"))
+	})
+
+	It("rejects incomplete certificates and keys", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+		server.RouteToHandler("POST", "/credentials", ghttp.RespondWithJSONEncoded(http.StatusOK, externalgenerator.Credential{PrivateKey: "key"}))
+
+		_, log := helper.NewTestLogger()
+		generator, err := externalgenerator.NewInsecureExternalGenerator(log, server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = generator.GenerateCertificate("default/cert", credsgen.CertificateGenerationRequest{CommonName: "foo.com"})
+		Expect(err).To(MatchError(ContainSubstring("incomplete certificate")))
+
+		_, _, err = generator.GenerateCertificateSigningRequest(credsgen.CertificateGenerationRequest{CommonName: "foo.com"})
+		Expect(err).To(MatchError(ContainSubstring("incomplete certificate signing request")))
+
+		_, err = generator.GenerateSSHKey("default/ssh", credsgen.KeyGenerationRequest{})
+		Expect(err).To(MatchError(ContainSubstring("incomplete ssh key")))
+
+		_, err = generator.GenerateRSAKey("default/rsa", credsgen.KeyGenerationRequest{})
+		Expect(err).To(MatchError(ContainSubstring("incomplete rsa key")))
+	})
+})
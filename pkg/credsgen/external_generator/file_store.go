@@ -0,0 +1,219 @@
+package externalgenerator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+)
+
+var credentialNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// FileStore is a reference implementation of the credential store API. It
+// keeps every credential as a JSON file in a directory. Credentials which
+// don't exist yet, or which are requested to be regenerated, are generated by
+// the given generator and stored, so every following request for the same
+// namespace and name returns the stored credential. Certificates are signed
+// by a CA certificate stored in the same namespace. Certificate signing
+// requests are generated on every request and not stored.
+type FileStore struct {
+	dir       string
+	generator credsgen.Generator
+	token     string
+	mutex     sync.Mutex
+}
+
+// NewFileStore returns a file store, which keeps credentials in dir. If token
+// is not empty, requests have to present it as bearer token.
+func NewFileStore(dir string, generator credsgen.Generator, token string) *FileStore {
+	return &FileStore{dir: dir, generator: generator, token: token}
+}
+
+// ServeHTTP handles requests to the credential store API
+func (s *FileStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != CredentialsPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	req := GenerationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	credential, err := s.credential(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(credential)
+}
+
+// credential returns the stored credential or generates a new one
+func (s *FileStore) credential(req GenerationRequest) (Credential, error) {
+	if req.Type == TypeCertificateRequest {
+		csr, key, err := s.generator.GenerateCertificateSigningRequest(generatorCertificateRequest(req))
+		if err != nil {
+			return Credential{}, err
+		}
+		return Credential{CertificateRequest: string(csr), PrivateKey: string(key)}, nil
+	}
+
+	if !credentialNameRegexp.MatchString(req.Namespace) {
+		return Credential{}, fmt.Errorf("invalid credential namespace '%s'", req.Namespace)
+	}
+	if !credentialNameRegexp.MatchString(req.Name) {
+		return Credential{}, fmt.Errorf("invalid credential name '%s'", req.Name)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	path := filepath.Join(s.dir, req.Type, req.Namespace, req.Name+".json")
+	if !req.Regenerate {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			credential := Credential{}
+			if err := json.Unmarshal(data, &credential); err != nil {
+				return Credential{}, errors.Wrapf(err, "failed to read stored credential '%s/%s'", req.Namespace, req.Name)
+			}
+			return credential, nil
+		}
+	}
+
+	credential, err := s.generate(req)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return Credential{}, errors.Wrapf(err, "failed to store credential '%s/%s'", req.Namespace, req.Name)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return Credential{}, errors.Wrapf(err, "failed to store credential '%s/%s'", req.Namespace, req.Name)
+	}
+
+	return credential, nil
+}
+
+func (s *FileStore) generate(req GenerationRequest) (Credential, error) {
+	switch req.Type {
+	case TypePassword:
+		password, err := s.generator.GeneratePassword(req.Name, generatorPasswordRequest(req))
+		return Credential{Password: password}, err
+	case TypeCertificate:
+		request := generatorCertificateRequest(req)
+		if req.CA != nil {
+			ca, err := s.signingCA(req.Namespace, req.CA.Certificate)
+			if err != nil {
+				return Credential{}, err
+			}
+			request.CA = ca
+		}
+		cert, err := s.generator.GenerateCertificate(req.Name, request)
+		return Credential{Certificate: string(cert.Certificate), PrivateKey: string(cert.PrivateKey)}, err
+	case TypeSSHKey:
+		key, err := s.generator.GenerateSSHKey(req.Name, keyRequest(req))
+		return Credential{PrivateKey: string(key.PrivateKey), PublicKey: string(key.PublicKey), Fingerprint: key.Fingerprint}, err
+	case TypeRSAKey:
+		key, err := s.generator.GenerateRSAKey(req.Name, keyRequest(req))
+		return Credential{PrivateKey: string(key.PrivateKey), PublicKey: string(key.PublicKey)}, err
+	case TypeUser:
+		user, err := s.generator.GenerateUser(req.Name, generatorUserRequest(req))
+		return Credential{Username: user.Username, Password: user.Password}, err
+	case TypeHTPasswd:
+		htpasswd, err := s.generator.GenerateHTPasswd(req.Name, generatorUserRequest(req))
+		return Credential{Username: htpasswd.Username, Password: htpasswd.Password, HTPasswd: htpasswd.Entry}, err
+	case TypeDHParams:
+		params, err := s.generator.GenerateDHParams(req.Name, credsgen.DHParamsGenerationRequest{Bits: req.DHParamsBits})
+		return Credential{DHParams: string(params)}, err
+	default:
+		return Credential{}, fmt.Errorf("unsupported credential type '%s'", req.Type)
+	}
+}
+
+// signingCA returns the stored CA certificate of the namespace together with
+// its private key
+func (s *FileStore) signingCA(namespace string, certificate string) (credsgen.Certificate, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, TypeCertificate, namespace, "*.json"))
+	if err != nil {
+		return credsgen.Certificate{}, err
+	}
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return credsgen.Certificate{}, errors.Wrapf(err, "failed to read stored credential '%s'", path)
+		}
+		credential := Credential{}
+		if err := json.Unmarshal(data, &credential); err != nil {
+			return credsgen.Certificate{}, errors.Wrapf(err, "failed to read stored credential '%s'", path)
+		}
+		if credential.Certificate == certificate && credential.PrivateKey != "" {
+			return credsgen.Certificate{
+				IsCA:        true,
+				Certificate: []byte(credential.Certificate),
+				PrivateKey:  []byte(credential.PrivateKey),
+			}, nil
+		}
+	}
+
+	return credsgen.Certificate{}, fmt.Errorf("CA certificate is not stored in namespace '%s'", namespace)
+}
+
+func generatorCertificateRequest(req GenerationRequest) credsgen.CertificateGenerationRequest {
+	return credsgen.CertificateGenerationRequest{
+		CommonName:       req.CommonName,
+		AlternativeNames: req.AlternativeNames,
+		IsCA:             req.IsCA,
+		Key:              keyRequest(req),
+		Duration:         time.Duration(req.DurationSeconds) * time.Second,
+	}
+}
+
+func generatorPasswordRequest(req GenerationRequest) credsgen.PasswordGenerationRequest {
+	return credsgen.PasswordGenerationRequest{
+		Length:            req.Length,
+		ExcludeUpper:      req.ExcludeUpper,
+		ExcludeLower:      req.ExcludeLower,
+		ExcludeNumber:     req.ExcludeNumber,
+		IncludeSpecial:    req.IncludeSpecial,
+		RequireEachClass:  req.RequireEachClass,
+		MinSpecial:        req.MinSpecial,
+		ExcludeCharacters: req.ExcludeCharacters,
+		Passphrase:        req.Passphrase,
+		Words:             req.Words,
+		Separator:         req.Separator,
+	}
+}
+
+func generatorUserRequest(req GenerationRequest) credsgen.UserGenerationRequest {
+	return credsgen.UserGenerationRequest{Username: req.Username, Password: generatorPasswordRequest(req)}
+}
+
+func keyRequest(req GenerationRequest) credsgen.KeyGenerationRequest {
+	return credsgen.KeyGenerationRequest{Algorithm: req.KeyAlgorithm, Bits: req.KeyBits}
+}
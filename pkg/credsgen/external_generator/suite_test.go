@@ -0,0 +1,13 @@
+package externalgenerator_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestExternalGenerator(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ExternalGenerator Suite")
+}
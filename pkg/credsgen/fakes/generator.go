@@ -37,7 +37,35 @@ type FakeGenerator struct {
 		result2 []byte
 		result3 error
 	}
-	GeneratePasswordStub        func(string, credsgen.PasswordGenerationRequest) string
+	GenerateDHParamsStub        func(string, credsgen.DHParamsGenerationRequest) ([]byte, error)
+	generateDHParamsMutex       sync.RWMutex
+	generateDHParamsArgsForCall []struct {
+		arg1 string
+		arg2 credsgen.DHParamsGenerationRequest
+	}
+	generateDHParamsReturns struct {
+		result1 []byte
+		result2 error
+	}
+	generateDHParamsReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	GenerateHTPasswdStub        func(string, credsgen.UserGenerationRequest) (credsgen.HTPasswd, error)
+	generateHTPasswdMutex       sync.RWMutex
+	generateHTPasswdArgsForCall []struct {
+		arg1 string
+		arg2 credsgen.UserGenerationRequest
+	}
+	generateHTPasswdReturns struct {
+		result1 credsgen.HTPasswd
+		result2 error
+	}
+	generateHTPasswdReturnsOnCall map[int]struct {
+		result1 credsgen.HTPasswd
+		result2 error
+	}
+	GeneratePasswordStub        func(string, credsgen.PasswordGenerationRequest) (string, error)
 	generatePasswordMutex       sync.RWMutex
 	generatePasswordArgsForCall []struct {
 		arg1 string
@@ -45,14 +73,17 @@ type FakeGenerator struct {
 	}
 	generatePasswordReturns struct {
 		result1 string
+		result2 error
 	}
 	generatePasswordReturnsOnCall map[int]struct {
 		result1 string
+		result2 error
 	}
-	GenerateRSAKeyStub        func(string) (credsgen.RSAKey, error)
+	GenerateRSAKeyStub        func(string, credsgen.KeyGenerationRequest) (credsgen.RSAKey, error)
 	generateRSAKeyMutex       sync.RWMutex
 	generateRSAKeyArgsForCall []struct {
 		arg1 string
+		arg2 credsgen.KeyGenerationRequest
 	}
 	generateRSAKeyReturns struct {
 		result1 credsgen.RSAKey
@@ -62,10 +93,11 @@ type FakeGenerator struct {
 		result1 credsgen.RSAKey
 		result2 error
 	}
-	GenerateSSHKeyStub        func(string) (credsgen.SSHKey, error)
+	GenerateSSHKeyStub        func(string, credsgen.KeyGenerationRequest) (credsgen.SSHKey, error)
 	generateSSHKeyMutex       sync.RWMutex
 	generateSSHKeyArgsForCall []struct {
 		arg1 string
+		arg2 credsgen.KeyGenerationRequest
 	}
 	generateSSHKeyReturns struct {
 		result1 credsgen.SSHKey
@@ -75,6 +107,20 @@ type FakeGenerator struct {
 		result1 credsgen.SSHKey
 		result2 error
 	}
+	GenerateUserStub        func(string, credsgen.UserGenerationRequest) (credsgen.User, error)
+	generateUserMutex       sync.RWMutex
+	generateUserArgsForCall []struct {
+		arg1 string
+		arg2 credsgen.UserGenerationRequest
+	}
+	generateUserReturns struct {
+		result1 credsgen.User
+		result2 error
+	}
+	generateUserReturnsOnCall map[int]struct {
+		result1 credsgen.User
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -209,7 +255,135 @@ func (fake *FakeGenerator) GenerateCertificateSigningRequestReturnsOnCall(i int,
 	}{result1, result2, result3}
 }
 
-func (fake *FakeGenerator) GeneratePassword(arg1 string, arg2 credsgen.PasswordGenerationRequest) string {
+func (fake *FakeGenerator) GenerateDHParams(arg1 string, arg2 credsgen.DHParamsGenerationRequest) ([]byte, error) {
+	fake.generateDHParamsMutex.Lock()
+	ret, specificReturn := fake.generateDHParamsReturnsOnCall[len(fake.generateDHParamsArgsForCall)]
+	fake.generateDHParamsArgsForCall = append(fake.generateDHParamsArgsForCall, struct {
+		arg1 string
+		arg2 credsgen.DHParamsGenerationRequest
+	}{arg1, arg2})
+	fake.recordInvocation("GenerateDHParams", []interface{}{arg1, arg2})
+	fake.generateDHParamsMutex.Unlock()
+	if fake.GenerateDHParamsStub != nil {
+		return fake.GenerateDHParamsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.generateDHParamsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeGenerator) GenerateDHParamsCallCount() int {
+	fake.generateDHParamsMutex.RLock()
+	defer fake.generateDHParamsMutex.RUnlock()
+	return len(fake.generateDHParamsArgsForCall)
+}
+
+func (fake *FakeGenerator) GenerateDHParamsCalls(stub func(string, credsgen.DHParamsGenerationRequest) ([]byte, error)) {
+	fake.generateDHParamsMutex.Lock()
+	defer fake.generateDHParamsMutex.Unlock()
+	fake.GenerateDHParamsStub = stub
+}
+
+func (fake *FakeGenerator) GenerateDHParamsArgsForCall(i int) (string, credsgen.DHParamsGenerationRequest) {
+	fake.generateDHParamsMutex.RLock()
+	defer fake.generateDHParamsMutex.RUnlock()
+	argsForCall := fake.generateDHParamsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeGenerator) GenerateDHParamsReturns(result1 []byte, result2 error) {
+	fake.generateDHParamsMutex.Lock()
+	defer fake.generateDHParamsMutex.Unlock()
+	fake.GenerateDHParamsStub = nil
+	fake.generateDHParamsReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGenerator) GenerateDHParamsReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.generateDHParamsMutex.Lock()
+	defer fake.generateDHParamsMutex.Unlock()
+	fake.GenerateDHParamsStub = nil
+	if fake.generateDHParamsReturnsOnCall == nil {
+		fake.generateDHParamsReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.generateDHParamsReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGenerator) GenerateHTPasswd(arg1 string, arg2 credsgen.UserGenerationRequest) (credsgen.HTPasswd, error) {
+	fake.generateHTPasswdMutex.Lock()
+	ret, specificReturn := fake.generateHTPasswdReturnsOnCall[len(fake.generateHTPasswdArgsForCall)]
+	fake.generateHTPasswdArgsForCall = append(fake.generateHTPasswdArgsForCall, struct {
+		arg1 string
+		arg2 credsgen.UserGenerationRequest
+	}{arg1, arg2})
+	fake.recordInvocation("GenerateHTPasswd", []interface{}{arg1, arg2})
+	fake.generateHTPasswdMutex.Unlock()
+	if fake.GenerateHTPasswdStub != nil {
+		return fake.GenerateHTPasswdStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.generateHTPasswdReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeGenerator) GenerateHTPasswdCallCount() int {
+	fake.generateHTPasswdMutex.RLock()
+	defer fake.generateHTPasswdMutex.RUnlock()
+	return len(fake.generateHTPasswdArgsForCall)
+}
+
+func (fake *FakeGenerator) GenerateHTPasswdCalls(stub func(string, credsgen.UserGenerationRequest) (credsgen.HTPasswd, error)) {
+	fake.generateHTPasswdMutex.Lock()
+	defer fake.generateHTPasswdMutex.Unlock()
+	fake.GenerateHTPasswdStub = stub
+}
+
+func (fake *FakeGenerator) GenerateHTPasswdArgsForCall(i int) (string, credsgen.UserGenerationRequest) {
+	fake.generateHTPasswdMutex.RLock()
+	defer fake.generateHTPasswdMutex.RUnlock()
+	argsForCall := fake.generateHTPasswdArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeGenerator) GenerateHTPasswdReturns(result1 credsgen.HTPasswd, result2 error) {
+	fake.generateHTPasswdMutex.Lock()
+	defer fake.generateHTPasswdMutex.Unlock()
+	fake.GenerateHTPasswdStub = nil
+	fake.generateHTPasswdReturns = struct {
+		result1 credsgen.HTPasswd
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGenerator) GenerateHTPasswdReturnsOnCall(i int, result1 credsgen.HTPasswd, result2 error) {
+	fake.generateHTPasswdMutex.Lock()
+	defer fake.generateHTPasswdMutex.Unlock()
+	fake.GenerateHTPasswdStub = nil
+	if fake.generateHTPasswdReturnsOnCall == nil {
+		fake.generateHTPasswdReturnsOnCall = make(map[int]struct {
+			result1 credsgen.HTPasswd
+			result2 error
+		})
+	}
+	fake.generateHTPasswdReturnsOnCall[i] = struct {
+		result1 credsgen.HTPasswd
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGenerator) GeneratePassword(arg1 string, arg2 credsgen.PasswordGenerationRequest) (string, error) {
 	fake.generatePasswordMutex.Lock()
 	ret, specificReturn := fake.generatePasswordReturnsOnCall[len(fake.generatePasswordArgsForCall)]
 	fake.generatePasswordArgsForCall = append(fake.generatePasswordArgsForCall, struct {
@@ -222,64 +396,76 @@ func (fake *FakeGenerator) GeneratePassword(arg1 string, arg2 credsgen.PasswordG
 		return fake.GeneratePasswordStub(arg1, arg2)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
 	fakeReturns := fake.generatePasswordReturns
-	return fakeReturns.result1
+	return fakeReturns.result1, fakeReturns.result2
 }
 
 func (fake *FakeGenerator) GeneratePasswordCallCount() int {
+	fake.generateDHParamsMutex.RLock()
+	defer fake.generateDHParamsMutex.RUnlock()
+	fake.generateHTPasswdMutex.RLock()
+	defer fake.generateHTPasswdMutex.RUnlock()
 	fake.generatePasswordMutex.RLock()
 	defer fake.generatePasswordMutex.RUnlock()
 	return len(fake.generatePasswordArgsForCall)
 }
 
-func (fake *FakeGenerator) GeneratePasswordCalls(stub func(string, credsgen.PasswordGenerationRequest) string) {
+func (fake *FakeGenerator) GeneratePasswordCalls(stub func(string, credsgen.PasswordGenerationRequest) (string, error)) {
 	fake.generatePasswordMutex.Lock()
 	defer fake.generatePasswordMutex.Unlock()
 	fake.GeneratePasswordStub = stub
 }
 
 func (fake *FakeGenerator) GeneratePasswordArgsForCall(i int) (string, credsgen.PasswordGenerationRequest) {
+	fake.generateDHParamsMutex.RLock()
+	defer fake.generateDHParamsMutex.RUnlock()
+	fake.generateHTPasswdMutex.RLock()
+	defer fake.generateHTPasswdMutex.RUnlock()
 	fake.generatePasswordMutex.RLock()
 	defer fake.generatePasswordMutex.RUnlock()
 	argsForCall := fake.generatePasswordArgsForCall[i]
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *FakeGenerator) GeneratePasswordReturns(result1 string) {
+func (fake *FakeGenerator) GeneratePasswordReturns(result1 string, result2 error) {
 	fake.generatePasswordMutex.Lock()
 	defer fake.generatePasswordMutex.Unlock()
 	fake.GeneratePasswordStub = nil
 	fake.generatePasswordReturns = struct {
 		result1 string
-	}{result1}
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *FakeGenerator) GeneratePasswordReturnsOnCall(i int, result1 string) {
+func (fake *FakeGenerator) GeneratePasswordReturnsOnCall(i int, result1 string, result2 error) {
 	fake.generatePasswordMutex.Lock()
 	defer fake.generatePasswordMutex.Unlock()
 	fake.GeneratePasswordStub = nil
 	if fake.generatePasswordReturnsOnCall == nil {
 		fake.generatePasswordReturnsOnCall = make(map[int]struct {
 			result1 string
+			result2 error
 		})
 	}
 	fake.generatePasswordReturnsOnCall[i] = struct {
 		result1 string
-	}{result1}
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *FakeGenerator) GenerateRSAKey(arg1 string) (credsgen.RSAKey, error) {
+func (fake *FakeGenerator) GenerateRSAKey(arg1 string, arg2 credsgen.KeyGenerationRequest) (credsgen.RSAKey, error) {
 	fake.generateRSAKeyMutex.Lock()
 	ret, specificReturn := fake.generateRSAKeyReturnsOnCall[len(fake.generateRSAKeyArgsForCall)]
 	fake.generateRSAKeyArgsForCall = append(fake.generateRSAKeyArgsForCall, struct {
 		arg1 string
-	}{arg1})
-	fake.recordInvocation("GenerateRSAKey", []interface{}{arg1})
+		arg2 credsgen.KeyGenerationRequest
+	}{arg1, arg2})
+	fake.recordInvocation("GenerateRSAKey", []interface{}{arg1, arg2})
 	fake.generateRSAKeyMutex.Unlock()
 	if fake.GenerateRSAKeyStub != nil {
-		return fake.GenerateRSAKeyStub(arg1)
+		return fake.GenerateRSAKeyStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -294,17 +480,17 @@ func (fake *FakeGenerator) GenerateRSAKeyCallCount() int {
 	return len(fake.generateRSAKeyArgsForCall)
 }
 
-func (fake *FakeGenerator) GenerateRSAKeyCalls(stub func(string) (credsgen.RSAKey, error)) {
+func (fake *FakeGenerator) GenerateRSAKeyCalls(stub func(string, credsgen.KeyGenerationRequest) (credsgen.RSAKey, error)) {
 	fake.generateRSAKeyMutex.Lock()
 	defer fake.generateRSAKeyMutex.Unlock()
 	fake.GenerateRSAKeyStub = stub
 }
 
-func (fake *FakeGenerator) GenerateRSAKeyArgsForCall(i int) string {
+func (fake *FakeGenerator) GenerateRSAKeyArgsForCall(i int) (string, credsgen.KeyGenerationRequest) {
 	fake.generateRSAKeyMutex.RLock()
 	defer fake.generateRSAKeyMutex.RUnlock()
 	argsForCall := fake.generateRSAKeyArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeGenerator) GenerateRSAKeyReturns(result1 credsgen.RSAKey, result2 error) {
@@ -333,16 +519,17 @@ func (fake *FakeGenerator) GenerateRSAKeyReturnsOnCall(i int, result1 credsgen.R
 	}{result1, result2}
 }
 
-func (fake *FakeGenerator) GenerateSSHKey(arg1 string) (credsgen.SSHKey, error) {
+func (fake *FakeGenerator) GenerateSSHKey(arg1 string, arg2 credsgen.KeyGenerationRequest) (credsgen.SSHKey, error) {
 	fake.generateSSHKeyMutex.Lock()
 	ret, specificReturn := fake.generateSSHKeyReturnsOnCall[len(fake.generateSSHKeyArgsForCall)]
 	fake.generateSSHKeyArgsForCall = append(fake.generateSSHKeyArgsForCall, struct {
 		arg1 string
-	}{arg1})
-	fake.recordInvocation("GenerateSSHKey", []interface{}{arg1})
+		arg2 credsgen.KeyGenerationRequest
+	}{arg1, arg2})
+	fake.recordInvocation("GenerateSSHKey", []interface{}{arg1, arg2})
 	fake.generateSSHKeyMutex.Unlock()
 	if fake.GenerateSSHKeyStub != nil {
-		return fake.GenerateSSHKeyStub(arg1)
+		return fake.GenerateSSHKeyStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -357,17 +544,17 @@ func (fake *FakeGenerator) GenerateSSHKeyCallCount() int {
 	return len(fake.generateSSHKeyArgsForCall)
 }
 
-func (fake *FakeGenerator) GenerateSSHKeyCalls(stub func(string) (credsgen.SSHKey, error)) {
+func (fake *FakeGenerator) GenerateSSHKeyCalls(stub func(string, credsgen.KeyGenerationRequest) (credsgen.SSHKey, error)) {
 	fake.generateSSHKeyMutex.Lock()
 	defer fake.generateSSHKeyMutex.Unlock()
 	fake.GenerateSSHKeyStub = stub
 }
 
-func (fake *FakeGenerator) GenerateSSHKeyArgsForCall(i int) string {
+func (fake *FakeGenerator) GenerateSSHKeyArgsForCall(i int) (string, credsgen.KeyGenerationRequest) {
 	fake.generateSSHKeyMutex.RLock()
 	defer fake.generateSSHKeyMutex.RUnlock()
 	argsForCall := fake.generateSSHKeyArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeGenerator) GenerateSSHKeyReturns(result1 credsgen.SSHKey, result2 error) {
@@ -396,6 +583,70 @@ func (fake *FakeGenerator) GenerateSSHKeyReturnsOnCall(i int, result1 credsgen.S
 	}{result1, result2}
 }
 
+func (fake *FakeGenerator) GenerateUser(arg1 string, arg2 credsgen.UserGenerationRequest) (credsgen.User, error) {
+	fake.generateUserMutex.Lock()
+	ret, specificReturn := fake.generateUserReturnsOnCall[len(fake.generateUserArgsForCall)]
+	fake.generateUserArgsForCall = append(fake.generateUserArgsForCall, struct {
+		arg1 string
+		arg2 credsgen.UserGenerationRequest
+	}{arg1, arg2})
+	fake.recordInvocation("GenerateUser", []interface{}{arg1, arg2})
+	fake.generateUserMutex.Unlock()
+	if fake.GenerateUserStub != nil {
+		return fake.GenerateUserStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.generateUserReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeGenerator) GenerateUserCallCount() int {
+	fake.generateUserMutex.RLock()
+	defer fake.generateUserMutex.RUnlock()
+	return len(fake.generateUserArgsForCall)
+}
+
+func (fake *FakeGenerator) GenerateUserCalls(stub func(string, credsgen.UserGenerationRequest) (credsgen.User, error)) {
+	fake.generateUserMutex.Lock()
+	defer fake.generateUserMutex.Unlock()
+	fake.GenerateUserStub = stub
+}
+
+func (fake *FakeGenerator) GenerateUserArgsForCall(i int) (string, credsgen.UserGenerationRequest) {
+	fake.generateUserMutex.RLock()
+	defer fake.generateUserMutex.RUnlock()
+	argsForCall := fake.generateUserArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeGenerator) GenerateUserReturns(result1 credsgen.User, result2 error) {
+	fake.generateUserMutex.Lock()
+	defer fake.generateUserMutex.Unlock()
+	fake.GenerateUserStub = nil
+	fake.generateUserReturns = struct {
+		result1 credsgen.User
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeGenerator) GenerateUserReturnsOnCall(i int, result1 credsgen.User, result2 error) {
+	fake.generateUserMutex.Lock()
+	defer fake.generateUserMutex.Unlock()
+	fake.GenerateUserStub = nil
+	if fake.generateUserReturnsOnCall == nil {
+		fake.generateUserReturnsOnCall = make(map[int]struct {
+			result1 credsgen.User
+			result2 error
+		})
+	}
+	fake.generateUserReturnsOnCall[i] = struct {
+		result1 credsgen.User
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeGenerator) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -403,12 +654,18 @@ func (fake *FakeGenerator) Invocations() map[string][][]interface{} {
 	defer fake.generateCertificateMutex.RUnlock()
 	fake.generateCertificateSigningRequestMutex.RLock()
 	defer fake.generateCertificateSigningRequestMutex.RUnlock()
+	fake.generateDHParamsMutex.RLock()
+	defer fake.generateDHParamsMutex.RUnlock()
+	fake.generateHTPasswdMutex.RLock()
+	defer fake.generateHTPasswdMutex.RUnlock()
 	fake.generatePasswordMutex.RLock()
 	defer fake.generatePasswordMutex.RUnlock()
 	fake.generateRSAKeyMutex.RLock()
 	defer fake.generateRSAKeyMutex.RUnlock()
 	fake.generateSSHKeyMutex.RLock()
 	defer fake.generateSSHKeyMutex.RUnlock()
+	fake.generateUserMutex.RLock()
+	defer fake.generateUserMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
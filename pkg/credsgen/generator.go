@@ -1,14 +1,106 @@
 package credsgen
 
+import "time"
+
 const (
 	// DefaultPasswordLength represents the default length of a generated password
 	// (number of characters)
 	DefaultPasswordLength = 64
+
+	// MaxPasswordLength is the maximum length of a generated password
+	MaxPasswordLength = 1024
+
+	// DefaultPassphraseWords represents the default number of words in a
+	// generated passphrase
+	DefaultPassphraseWords = 8
+
+	// MaxPassphraseWords is the maximum number of words in a generated
+	// passphrase
+	MaxPassphraseWords = 64
+
+	// MaxPassphraseSeparatorLength is the maximum length of the separator
+	// between the words of a generated passphrase
+	MaxPassphraseSeparatorLength = 8
+
+	// DefaultUsernameLength represents the default length of a generated
+	// username
+	DefaultUsernameLength = 20
+
+	// DefaultDHParamsBits represents the default size of the prime of
+	// generated Diffie-Hellman parameters
+	DefaultDHParamsBits = 2048
+
+	// MinDHParamsBits is the minimum size of the prime of generated
+	// Diffie-Hellman parameters
+	MinDHParamsBits = 1024
+
+	// MaxDHParamsBits is the maximum size of the prime of generated
+	// Diffie-Hellman parameters, larger primes take too long to find
+	MaxDHParamsBits = 4096
+
+	// DefaultDHParamsTimeout limits the search for the prime of
+	// Diffie-Hellman parameters
+	DefaultDHParamsTimeout = 5 * time.Minute
 )
 
-// PasswordGenerationRequest specifies the generation parameters for Passwords
+// Key algorithms for generated keys and certificates
+const (
+	// RSAKeyAlgorithm generates RSA keys, bits set the key size
+	RSAKeyAlgorithm = "rsa"
+	// ECDSAKeyAlgorithm generates ECDSA keys, bits select the P-256 or P-384 curve
+	ECDSAKeyAlgorithm = "ecdsa"
+	// Ed25519KeyAlgorithm generates Ed25519 keys, bits are ignored
+	Ed25519KeyAlgorithm = "ed25519"
+)
+
+// KeyGenerationRequest specifies the algorithm and size of a generated key.
+// Empty fields are set to the generator's defaults.
+type KeyGenerationRequest struct {
+	Algorithm string
+	Bits      int
+}
+
+// PasswordGenerationRequest specifies the generation parameters for Passwords.
+// Without any options, passwords consist of lower and upper case letters and
+// digits.
 type PasswordGenerationRequest struct {
 	Length int
+
+	// Character classes
+	ExcludeUpper   bool
+	ExcludeLower   bool
+	ExcludeNumber  bool
+	IncludeSpecial bool
+
+	// RequireEachClass ensures the password contains at least one
+	// character of every used character class
+	RequireEachClass bool
+	// MinSpecial is the minimum number of special characters, it implies
+	// IncludeSpecial
+	MinSpecial int
+	// ExcludeCharacters are never used in the password
+	ExcludeCharacters string
+
+	// Passphrase generates words separated by Separator instead of random
+	// characters. Length is ignored, Words sets the number of words.
+	Passphrase bool
+	Words      int
+	Separator  string
+}
+
+// UserGenerationRequest specifies the generation parameters for a username and
+// password pair. A random username is generated, if it's empty.
+type UserGenerationRequest struct {
+	Username string
+	Password PasswordGenerationRequest
+}
+
+// DHParamsGenerationRequest specifies the size of the prime of generated
+// Diffie-Hellman parameters. The generation fails, if no prime is found
+// within the timeout.
+type DHParamsGenerationRequest struct {
+	Bits    int
+	Timeout time.Duration
 }
 
 // CertificateGenerationRequest specifies the generation parameters for Certificates
@@ -17,6 +109,10 @@ type CertificateGenerationRequest struct {
 	AlternativeNames []string
 	IsCA             bool
 	CA               Certificate
+	Key              KeyGenerationRequest
+	// Duration is the validity of the certificate, the generator's default
+	// is used if it's zero
+	Duration time.Duration
 }
 
 // Certificate holds the information about a certificate
@@ -33,17 +129,45 @@ type SSHKey struct {
 	Fingerprint string
 }
 
-// RSAKey represents an RSA key
+// RSAKey represents an RSA key. Despite the name it may hold an ECDSA or
+// Ed25519 key, if another algorithm was requested.
 type RSAKey struct {
 	PrivateKey []byte
 	PublicKey  []byte
 }
 
-// Generator provides an interface for generating credentials like passwords, certificates or SSH and RSA keys
+// User holds a username and its password
+type User struct {
+	Username string
+	Password string
+}
+
+// HTPasswd holds a username and its password, together with an htpasswd
+// entry containing the bcrypt hash of the password
+type HTPasswd struct {
+	Username string
+	Password string
+	Entry    string
+}
+
+// Generator provides an interface for generating credentials like passwords, certificates, SSH and RSA keys,
+// users, htpasswd entries or Diffie-Hellman parameters
 type Generator interface {
-	GeneratePassword(name string, request PasswordGenerationRequest) string
+	GeneratePassword(name string, request PasswordGenerationRequest) (string, error)
 	GenerateCertificate(name string, request CertificateGenerationRequest) (Certificate, error)
 	GenerateCertificateSigningRequest(request CertificateGenerationRequest) ([]byte, []byte, error)
-	GenerateSSHKey(name string) (SSHKey, error)
-	GenerateRSAKey(name string) (RSAKey, error)
+	GenerateSSHKey(name string, request KeyGenerationRequest) (SSHKey, error)
+	GenerateRSAKey(name string, request KeyGenerationRequest) (RSAKey, error)
+	GenerateUser(name string, request UserGenerationRequest) (User, error)
+	GenerateHTPasswd(name string, request UserGenerationRequest) (HTPasswd, error)
+	GenerateDHParams(name string, request DHParamsGenerationRequest) ([]byte, error)
+}
+
+// StoringGenerator is implemented by generators, which keep the credentials
+// they generate and return them again on repeated requests for the same name
+type StoringGenerator interface {
+	Generator
+	// Regenerating returns a generator, which replaces the kept credentials
+	// instead of returning them, e.g. to rotate or renew them
+	Regenerating() Generator
 }
@@ -1,7 +1,12 @@
 package inmemorygenerator
 
 import (
-	"fmt"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
 	"time"
 
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
@@ -42,15 +47,23 @@ func (g InMemoryGenerator) GenerateCertificate(name string, request credsgen.Cer
 func (g InMemoryGenerator) GenerateCertificateSigningRequest(request credsgen.CertificateGenerationRequest) ([]byte, []byte, error) {
 	cfssllog.Level = cfssllog.LevelWarning
 
-	var csReq, privateKey []byte
+	keyRequest, err := g.keyRequest(request.Key)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Generate certificate request
-	certReq := &csr.CertificateRequest{KeyRequest: &csr.BasicKeyRequest{A: g.Algorithm, S: g.Bits}}
+	certReq := &csr.CertificateRequest{KeyRequest: &csr.BasicKeyRequest{A: keyRequest.Algorithm, S: keyRequest.Bits}}
 
 	certReq.Hosts = append(certReq.Hosts, request.CommonName)
 	certReq.Hosts = append(certReq.Hosts, request.AlternativeNames...)
 	certReq.CN = certReq.Hosts[0]
 
+	// cfssl only generates RSA and ECDSA keys
+	if keyRequest.Algorithm == credsgen.Ed25519KeyAlgorithm {
+		return generateCSRWithKey(certReq, keyRequest)
+	}
+
 	sslValidator := &csr.Generator{Validator: genkey.Validator}
 	csReq, privateKey, err := sslValidator.ProcessRequest(certReq)
 	if err != nil {
@@ -75,10 +88,11 @@ func (g InMemoryGenerator) generateCertificate(request credsgen.CertificateGener
 		return credsgen.Certificate{}, err
 	}
 	// Sign certificate
+	expiry := g.expiry(request)
 	signingProfile := &config.SigningProfile{
 		Usage:        []string{"server auth", "client auth"},
-		Expiry:       time.Duration(g.Expiry*24) * time.Hour,
-		ExpiryString: fmt.Sprintf("%dh", g.Expiry*24),
+		Expiry:       expiry,
+		ExpiryString: expiry.String(),
 	}
 	cert.Certificate, err = g.signCertificate(signingReq, signingProfile, request)
 	if err != nil {
@@ -91,12 +105,22 @@ func (g InMemoryGenerator) generateCertificate(request credsgen.CertificateGener
 
 // generateCACertificate Generate self-signed root CA certificate and private key
 func (g InMemoryGenerator) generateCACertificate(request credsgen.CertificateGenerationRequest) (credsgen.Certificate, error) {
+	keyRequest, err := g.keyRequest(request.Key)
+	if err != nil {
+		return credsgen.Certificate{}, err
+	}
+
 	req := &csr.CertificateRequest{
-		CA:         &csr.CAConfig{Expiry: fmt.Sprintf("%dh", g.Expiry*24)},
+		CA:         &csr.CAConfig{Expiry: g.expiry(request).String()},
 		CN:         request.CommonName,
-		KeyRequest: &csr.BasicKeyRequest{A: g.Algorithm, S: g.Bits},
+		KeyRequest: &csr.BasicKeyRequest{A: keyRequest.Algorithm, S: keyRequest.Bits},
+	}
+	var ca, csr, privateKey []byte
+	if keyRequest.Algorithm == credsgen.Ed25519KeyAlgorithm {
+		ca, csr, privateKey, err = newEd25519CA(req, keyRequest)
+	} else {
+		ca, csr, privateKey, err = initca.New(req)
 	}
-	ca, csr, privateKey, err := initca.New(req)
 	if err != nil {
 		return credsgen.Certificate{}, err
 	}
@@ -107,10 +131,15 @@ func (g InMemoryGenerator) generateCACertificate(request credsgen.CertificateGen
 		PrivateKey:  privateKey,
 	}
 	if request.CA.IsCA {
+		// Intermediate CAs are valid for five years, unless requested otherwise
+		expiry := 5 * helpers.OneYear
+		if request.Duration > 0 {
+			expiry = request.Duration
+		}
 		signingProfile := &config.SigningProfile{
 			Usage:        []string{"cert sign", "crl sign"},
-			ExpiryString: "43800h",
-			Expiry:       5 * helpers.OneYear,
+			ExpiryString: expiry.String(),
+			Expiry:       expiry,
 			CAConstraint: config.CAConstraint{
 				IsCA: true,
 			},
@@ -124,6 +153,15 @@ func (g InMemoryGenerator) generateCACertificate(request credsgen.CertificateGen
 	return cert, nil
 }
 
+// expiry returns the requested validity of the certificate, which defaults to
+// the generator's expiry in days
+func (g InMemoryGenerator) expiry(request credsgen.CertificateGenerationRequest) time.Duration {
+	if request.Duration > 0 {
+		return request.Duration
+	}
+	return time.Duration(g.Expiry*24) * time.Hour
+}
+
 // Given a signing profile, csr  & request with CA, the certificate is signed by the CA.
 func (g InMemoryGenerator) signCertificate(csr []byte, signingProfile *config.SigningProfile, request credsgen.CertificateGenerationRequest) ([]byte, error) {
 
@@ -137,12 +175,12 @@ func (g InMemoryGenerator) signCertificate(csr []byte, signingProfile *config.Si
 	if err != nil {
 		return []byte{}, errors.Wrap(err, "Parsing CA PEM failed.")
 	}
-	parentCAKey, err := helpers.ParsePrivateKeyPEM([]byte(request.CA.PrivateKey))
+	parentCAKey, err := parsePrivateKey([]byte(request.CA.PrivateKey))
 	if err != nil {
 		return []byte{}, errors.Wrap(err, "Parsing CA private key failed.")
 	}
 
-	s, err := local.NewSigner(parentCAKey, parentCACert, signer.DefaultSigAlgo(parentCAKey), policy)
+	s, err := local.NewSigner(parentCAKey, parentCACert, signatureAlgorithm(parentCAKey), policy)
 	if err != nil {
 		return []byte{}, errors.Wrap(err, "Creating signer failed.")
 	}
@@ -153,3 +191,81 @@ func (g InMemoryGenerator) signCertificate(csr []byte, signingProfile *config.Si
 
 	return certificate, nil
 }
+
+// generateCSRWithKey generates a key, which cfssl doesn't support, and a
+// certificate signing request for it
+func generateCSRWithKey(req *csr.CertificateRequest, keyRequest credsgen.KeyGenerationRequest) ([]byte, []byte, error) {
+	key, err := generatePrivateKey(keyRequest)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Generating private key failed.")
+	}
+	privateKey, err := marshalPrivateKey(key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Encoding private key failed.")
+	}
+	csReq, err := createCSR(key, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return csReq, privateKey, nil
+}
+
+// newEd25519CA creates a self-signed CA with an Ed25519 key, like initca.New
+// does for RSA and ECDSA keys
+func newEd25519CA(req *csr.CertificateRequest, keyRequest credsgen.KeyGenerationRequest) ([]byte, []byte, []byte, error) {
+	csReq, privateKey, err := generateCSRWithKey(req, keyRequest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	policy := initca.CAPolicy()
+	policy.Default.ExpiryString = req.CA.Expiry
+	policy.Default.Expiry, err = time.ParseDuration(req.CA.Expiry)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	s, err := local.NewSigner(key, nil, signatureAlgorithm(key), policy)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "Creating signer failed.")
+	}
+	ca, err := s.Sign(signer.SignRequest{Request: string(csReq)})
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "Signing CA certificate failed.")
+	}
+	return ca, csReq, privateKey, nil
+}
+
+// createCSR creates a PEM encoded certificate signing request for the
+// hosts of the request. Unlike csr.Generate it supports Ed25519 keys.
+func createCSR(key crypto.Signer, req *csr.CertificateRequest) ([]byte, error) {
+	template := x509.CertificateRequest{
+		Subject:            req.Name(),
+		SignatureAlgorithm: signatureAlgorithm(key),
+	}
+	for _, host := range req.Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &template, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating certificate signing request failed.")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// signatureAlgorithm returns the signature algorithm for the key
+func signatureAlgorithm(key crypto.Signer) x509.SignatureAlgorithm {
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		return x509.PureEd25519
+	}
+	return signer.DefaultSigAlgo(key)
+}
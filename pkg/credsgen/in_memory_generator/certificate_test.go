@@ -1,6 +1,9 @@
 package inmemorygenerator_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -111,6 +114,80 @@ var _ = Describe("InMemoryGenerator", func() {
 			})
 		})
 
+		Context("with a requested duration", func() {
+			It("uses the duration for CAs and certificates", func() {
+				ca, err := generator.GenerateCertificate("ca", credsgen.CertificateGenerationRequest{
+					CommonName: "Fake CA",
+					IsCA:       true,
+					Duration:   48 * time.Hour,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				parsedCA, err := parseCert(ca.Certificate)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parsedCA.NotAfter.Sub(parsedCA.NotBefore)).To(BeNumerically("~", 48*time.Hour, 10*time.Minute))
+
+				cert, err := generator.GenerateCertificate("foo", credsgen.CertificateGenerationRequest{
+					CommonName: "foo.com",
+					CA:         ca,
+					Duration:   time.Hour,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				parsedCert, err := parseCert(cert.Certificate)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parsedCert.NotAfter.Sub(parsedCert.NotBefore)).To(BeNumerically("~", time.Hour, 10*time.Minute))
+			})
+		})
+
+		Context("with a requested key algorithm", func() {
+			It("generates an ECDSA P-384 certificate signed by an RSA CA", func() {
+				ca, err := generator.GenerateCertificate("ca", credsgen.CertificateGenerationRequest{
+					CommonName: "Fake CA",
+					IsCA:       true,
+					Key:        credsgen.KeyGenerationRequest{Algorithm: credsgen.RSAKeyAlgorithm},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				cert, err := generator.GenerateCertificate("foo", credsgen.CertificateGenerationRequest{
+					CommonName: "foo.com",
+					CA:         ca,
+					Key:        credsgen.KeyGenerationRequest{Algorithm: credsgen.ECDSAKeyAlgorithm, Bits: 384},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				parsedCert, err := parseCert(cert.Certificate)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parsedCert.SignatureAlgorithm).To(Equal(x509.SHA256WithRSA))
+				Expect(parsedCert.PublicKey.(*ecdsa.PublicKey).Curve).To(Equal(elliptic.P384()))
+			})
+
+			It("generates Ed25519 CAs and certificates", func() {
+				ca, err := generator.GenerateCertificate("ca", credsgen.CertificateGenerationRequest{
+					CommonName: "Fake CA",
+					IsCA:       true,
+					Key:        credsgen.KeyGenerationRequest{Algorithm: credsgen.Ed25519KeyAlgorithm},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				parsedCA, err := parseCert(ca.Certificate)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parsedCA.IsCA).To(BeTrue())
+				Expect(parsedCA.SignatureAlgorithm).To(Equal(x509.PureEd25519))
+
+				cert, err := generator.GenerateCertificate("foo", credsgen.CertificateGenerationRequest{
+					CommonName: "foo.com",
+					CA:         ca,
+					Key:        credsgen.KeyGenerationRequest{Algorithm: credsgen.Ed25519KeyAlgorithm},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				parsedCert, err := parseCert(cert.Certificate)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parsedCert.PublicKey).To(BeAssignableToTypeOf(ed25519.PublicKey{}))
+				Expect(parsedCert.CheckSignatureFrom(parsedCA)).To(Succeed())
+				Expect(parsedCert.DNSNames).To(ContainElement("foo.com"))
+			})
+		})
+
 		Context("when generating a CA", func() {
 			var (
 				request credsgen.CertificateGenerationRequest
@@ -162,6 +239,47 @@ var _ = Describe("InMemoryGenerator", func() {
 	})
 })
 
+var _ = Describe("GenerateCertificateSigningRequest", func() {
+	var generator credsgen.Generator
+
+	BeforeEach(func() {
+		cfssllog.Level = cfssllog.LevelFatal
+		_, log := helper.NewTestLogger()
+		generator = inmemorygenerator.NewInMemoryGenerator(log)
+	})
+
+	It("uses the requested key algorithm", func() {
+		csr, key, err := generator.GenerateCertificateSigningRequest(credsgen.CertificateGenerationRequest{
+			CommonName:       "foo.com",
+			AlternativeNames: []string{"10.0.0.1"},
+			Key:              credsgen.KeyGenerationRequest{Algorithm: credsgen.Ed25519KeyAlgorithm},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(key)).To(ContainSubstring("BEGIN PRIVATE KEY"))
+
+		block, _ := pem.Decode(csr)
+		parsed, err := x509.ParseCertificateRequest(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed.CheckSignature()).To(Succeed())
+		Expect(parsed.PublicKeyAlgorithm).To(Equal(x509.Ed25519))
+		Expect(parsed.Subject.CommonName).To(Equal("foo.com"))
+		Expect(parsed.IPAddresses[0].String()).To(Equal("10.0.0.1"))
+	})
+
+	It("generates ECDSA requests", func() {
+		csr, _, err := generator.GenerateCertificateSigningRequest(credsgen.CertificateGenerationRequest{
+			CommonName: "foo.com",
+			Key:        credsgen.KeyGenerationRequest{Algorithm: credsgen.ECDSAKeyAlgorithm},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		block, _ := pem.Decode(csr)
+		parsed, err := x509.ParseCertificateRequest(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed.SignatureAlgorithm).To(Equal(x509.ECDSAWithSHA256))
+	})
+})
+
 func parseCert(certificate []byte) (*x509.Certificate, error) {
 	certBlob, _ := pem.Decode(certificate)
 	if certBlob == nil {
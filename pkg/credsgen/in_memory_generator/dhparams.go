@@ -0,0 +1,141 @@
+package inmemorygenerator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+)
+
+const (
+	// dhGenerator is the generator of all Diffie-Hellman parameters, like
+	// `openssl dhparam` uses by default
+	dhGenerator = 2
+	// maxSieveDelta limits the search for a safe prime, before starting
+	// over with another random number
+	maxSieveDelta = 1 << 20
+)
+
+// smallPrimes are used to sieve safe prime candidates, before running the
+// expensive primality tests
+var smallPrimes = primesUpTo(2000)
+
+// dhParameters is the ASN.1 structure of PKCS #3 Diffie-Hellman parameters
+type dhParameters struct {
+	P *big.Int
+	G *big.Int
+}
+
+// GenerateDHParams generates PEM encoded Diffie-Hellman parameters with a
+// safe prime. The search for the prime is canceled after the request's
+// timeout.
+func (g InMemoryGenerator) GenerateDHParams(name string, request credsgen.DHParamsGenerationRequest) ([]byte, error) {
+	g.log.Debugf("Generating DH parameters %s", name)
+
+	bits := request.Bits
+	if bits == 0 {
+		bits = credsgen.DefaultDHParamsBits
+	}
+	if bits < credsgen.MinDHParamsBits {
+		return nil, errors.Errorf("DH parameters need at least %d bits, got %d", credsgen.MinDHParamsBits, bits)
+	}
+	if bits > credsgen.MaxDHParamsBits {
+		return nil, errors.Errorf("DH parameters support at most %d bits, got %d", credsgen.MaxDHParamsBits, bits)
+	}
+
+	timeout := request.Timeout
+	if timeout == 0 {
+		timeout = credsgen.DefaultDHParamsTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	prime, err := safePrime(ctx, bits)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating safe prime")
+	}
+
+	der, err := asn1.Marshal(dhParameters{P: prime, G: big.NewInt(dhGenerator)})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling DH parameters")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "DH PARAMETERS", Bytes: der}), nil
+}
+
+// safePrime returns a prime p with the given number of bits, for which
+// q = (p-1)/2 is prime, too. p ≡ 23 (mod 24), so 2 generates the subgroup of
+// order q. The search stops with the context's error, once it's done.
+func safePrime(ctx context.Context, bits int) (*big.Int, error) {
+	qBits := bits - 1
+	// The two top bits are set, so p always has the requested size
+	top := new(big.Int).Lsh(big.NewInt(3), uint(qBits-2))
+	max := new(big.Int).Lsh(big.NewInt(1), uint(qBits-2))
+	residues := make([]uint64, len(smallPrimes))
+
+	for {
+		q, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		q.Add(q, top)
+		// q ≡ 11 (mod 12) results in p ≡ 23 (mod 24)
+		q.Sub(q, new(big.Int).Mod(q, big.NewInt(12)))
+		q.Sub(q, big.NewInt(1))
+
+		for i, prime := range smallPrimes {
+			residues[i] = new(big.Int).Mod(q, new(big.Int).SetUint64(prime)).Uint64()
+		}
+
+	search:
+		for delta := uint64(0); delta < maxSieveDelta; delta += 12 {
+			for i, prime := range smallPrimes {
+				r := (residues[i] + delta) % prime
+				if r == 0 || (2*r+1)%prime == 0 {
+					continue search
+				}
+			}
+
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			candidate := new(big.Int).Add(q, new(big.Int).SetUint64(delta))
+			if candidate.BitLen() != qBits {
+				break
+			}
+			if !candidate.ProbablyPrime(20) {
+				continue
+			}
+			p := new(big.Int).Lsh(candidate, 1)
+			p.Add(p, big.NewInt(1))
+			if p.ProbablyPrime(20) {
+				return p, nil
+			}
+		}
+	}
+}
+
+// primesUpTo returns the odd primes from 5 up to n, smaller primes are
+// excluded by the residue of the candidates
+func primesUpTo(n int) []uint64 {
+	composite := make([]bool, n+1)
+	primes := []uint64{}
+	for i := 2; i <= n; i++ {
+		if composite[i] {
+			continue
+		}
+		if i > 3 {
+			primes = append(primes, uint64(i))
+		}
+		for j := i * i; j <= n; j += i {
+			composite[j] = true
+		}
+	}
+	return primes
+}
@@ -0,0 +1,68 @@
+package inmemorygenerator_test
+
+import (
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("InMemoryGenerator", func() {
+	var (
+		generator credsgen.Generator
+	)
+
+	BeforeEach(func() {
+		_, log := helper.NewTestLogger()
+		generator = inmemorygenerator.NewInMemoryGenerator(log)
+	})
+
+	Describe("GenerateDHParams", func() {
+		It("generates DH parameters with a safe prime", func() {
+			params, err := generator.GenerateDHParams("foo", credsgen.DHParamsGenerationRequest{Bits: 1024})
+			Expect(err).ToNot(HaveOccurred())
+
+			block, _ := pem.Decode(params)
+			Expect(block).ToNot(BeNil())
+			Expect(block.Type).To(Equal("DH PARAMETERS"))
+
+			var dh struct {
+				P *big.Int
+				G *big.Int
+			}
+			_, err = asn1.Unmarshal(block.Bytes, &dh)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dh.G.Int64()).To(Equal(int64(2)))
+			Expect(dh.P.BitLen()).To(Equal(1024))
+			Expect(dh.P.ProbablyPrime(20)).To(BeTrue())
+
+			q := new(big.Int).Rsh(dh.P, 1)
+			Expect(q.ProbablyPrime(20)).To(BeTrue())
+		})
+
+		It("rejects small primes", func() {
+			_, err := generator.GenerateDHParams("foo", credsgen.DHParamsGenerationRequest{Bits: 512})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("DH parameters need at least 1024 bits"))
+		})
+
+		It("rejects large primes", func() {
+			_, err := generator.GenerateDHParams("foo", credsgen.DHParamsGenerationRequest{Bits: 8192})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("DH parameters support at most 4096 bits"))
+		})
+
+		It("stops searching the prime after the timeout", func() {
+			_, err := generator.GenerateDHParams("foo", credsgen.DHParamsGenerationRequest{Bits: 4096, Timeout: time.Millisecond})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("context deadline exceeded"))
+		})
+	})
+})
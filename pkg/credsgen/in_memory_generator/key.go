@@ -0,0 +1,185 @@
+package inmemorygenerator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+)
+
+// Default key sizes, if the request doesn't specify the bits
+const (
+	defaultRSABits   = 2048
+	defaultECDSABits = 256
+)
+
+// keyRequest fills the empty fields of the key request with the
+// generator's defaults and validates the result
+func (g InMemoryGenerator) keyRequest(request credsgen.KeyGenerationRequest) (credsgen.KeyGenerationRequest, error) {
+	if request.Algorithm == "" {
+		request.Algorithm = g.Algorithm
+	}
+	if request.Bits == 0 && request.Algorithm == g.Algorithm {
+		request.Bits = g.Bits
+	}
+
+	switch request.Algorithm {
+	case credsgen.RSAKeyAlgorithm:
+		if request.Bits == 0 {
+			request.Bits = defaultRSABits
+		}
+		if request.Bits < defaultRSABits {
+			return request, errors.Errorf("RSA keys need at least %d bits, got %d", defaultRSABits, request.Bits)
+		}
+	case credsgen.ECDSAKeyAlgorithm:
+		if request.Bits == 0 {
+			request.Bits = defaultECDSABits
+		}
+		if request.Bits != 256 && request.Bits != 384 {
+			return request, errors.Errorf("ECDSA keys support the P-256 and P-384 curves, got %d bits", request.Bits)
+		}
+	case credsgen.Ed25519KeyAlgorithm:
+		request.Bits = 0
+	default:
+		return request, errors.Errorf("unsupported key algorithm '%s'", request.Algorithm)
+	}
+
+	return request, nil
+}
+
+// generatePrivateKey generates a key for a validated key request
+func generatePrivateKey(request credsgen.KeyGenerationRequest) (crypto.Signer, error) {
+	switch request.Algorithm {
+	case credsgen.ECDSAKeyAlgorithm:
+		curve := elliptic.P256()
+		if request.Bits == 384 {
+			curve = elliptic.P384()
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case credsgen.Ed25519KeyAlgorithm:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		return rsa.GenerateKey(rand.Reader, request.Bits)
+	}
+}
+
+// marshalPrivateKey PEM encodes the private key. RSA and ECDSA keys use their
+// traditional formats, Ed25519 keys are encoded as PKCS #8.
+func marshalPrivateKey(key crypto.Signer) ([]byte, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+}
+
+// marshalPublicKey PEM encodes the public key as PKIX
+func marshalPublicKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// parsePrivateKey parses a PEM encoded private key of any supported
+// algorithm. cfssl doesn't know about Ed25519 keys.
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	key, err := helpers.ParsePrivateKeyPEM(data)
+	if err == nil {
+		return key, nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, err
+	}
+	pkcs8, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if pkcs8Err != nil {
+		return nil, err
+	}
+	signer, ok := pkcs8.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key can't be used for signing")
+	}
+	return signer, nil
+}
+
+// marshalSSHPrivateKey PEM encodes the private key in a format understood by
+// OpenSSH. Ed25519 keys are only supported in the OpenSSH key format.
+func marshalSSHPrivateKey(key crypto.Signer) ([]byte, error) {
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return marshalPrivateKey(key)
+	}
+
+	public := private.Public().(ed25519.PublicKey)
+	check := make([]byte, 4)
+	if _, err := rand.Read(check); err != nil {
+		return nil, err
+	}
+
+	// The private section is padded to the cipher's block size of 8
+	privateSection := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		KeyType string
+		Public  []byte
+		Private []byte
+		Comment string
+	}{
+		Check1:  binary.BigEndian.Uint32(check),
+		Check2:  binary.BigEndian.Uint32(check),
+		KeyType: ssh.KeyAlgoED25519,
+		Public:  public,
+		Private: private,
+	})
+	for i := byte(1); len(privateSection)%8 != 0; i++ {
+		privateSection = append(privateSection, i)
+	}
+
+	publicKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte("openssh-key-v1\x00"), ssh.Marshal(struct {
+		CipherName  string
+		KdfName     string
+		KdfOpts     string
+		NumKeys     uint32
+		PublicKey   []byte
+		PrivateKeys []byte
+	}{
+		CipherName:  "none",
+		KdfName:     "none",
+		NumKeys:     1,
+		PublicKey:   publicKey.Marshal(),
+		PrivateKeys: privateSection,
+	})...)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data}), nil
+}
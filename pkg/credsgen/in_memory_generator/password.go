@@ -1,18 +1,174 @@
 package inmemorygenerator
 
 import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
-	"github.com/dchest/uniuri"
+	"github.com/pkg/errors"
+)
+
+// Character classes for passwords
+const (
+	lowerChars   = "abcdefghijklmnopqrstuvwxyz"
+	upperChars   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	numberChars  = "0123456789"
+	specialChars = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
 )
 
+// defaultSeparator is used between the words of a passphrase
+const defaultSeparator = "-"
+
 // GeneratePassword generates a random password
-func (g InMemoryGenerator) GeneratePassword(name string, request credsgen.PasswordGenerationRequest) string {
+func (g InMemoryGenerator) GeneratePassword(name string, request credsgen.PasswordGenerationRequest) (string, error) {
 	g.log.Debugf("Generating password %s", name)
 
+	if request.Passphrase {
+		return generatePassphrase(name, request)
+	}
+
 	length := request.Length
 	if length == 0 {
 		length = credsgen.DefaultPasswordLength
 	}
+	if length < 0 || length > credsgen.MaxPasswordLength {
+		return "", errors.Errorf("Generating password failed for secret %s: length %d is not between 1 and %d", name, length, credsgen.MaxPasswordLength)
+	}
+	if request.MinSpecial < 0 {
+		return "", errors.Errorf("Generating password failed for secret %s: minimum of %d special characters is negative", name, request.MinSpecial)
+	}
+
+	type class struct {
+		chars    string
+		required int
+	}
+	classes := []class{}
+	// Classes without characters are only kept, if they are required
+	add := func(chars string, required int) {
+		chars = removeChars(chars, request.ExcludeCharacters)
+		if chars != "" || required > 0 {
+			classes = append(classes, class{chars: chars, required: required})
+		}
+	}
 
-	return uniuri.NewLen(length)
+	minClass := 0
+	if request.RequireEachClass {
+		minClass = 1
+	}
+	if !request.ExcludeLower {
+		add(lowerChars, minClass)
+	}
+	if !request.ExcludeUpper {
+		add(upperChars, minClass)
+	}
+	if !request.ExcludeNumber {
+		add(numberChars, minClass)
+	}
+	if request.IncludeSpecial || request.MinSpecial > 0 {
+		required := request.MinSpecial
+		if required < minClass {
+			required = minClass
+		}
+		add(specialChars, required)
+	}
+
+	all := ""
+	required := 0
+	for _, c := range classes {
+		if c.chars == "" {
+			return "", errors.Errorf("Generating password failed for secret %s: all characters of a required character class are excluded", name)
+		}
+		all += c.chars
+		required += c.required
+	}
+	if all == "" {
+		return "", errors.Errorf("Generating password failed for secret %s: no characters left to choose from", name)
+	}
+	if required > length {
+		return "", errors.Errorf("Generating password failed for secret %s: length %d is too short for %d required characters", name, length, required)
+	}
+
+	password := make([]byte, 0, length)
+	for _, c := range classes {
+		for i := 0; i < c.required; i++ {
+			char, err := randomChar(c.chars)
+			if err != nil {
+				return "", errors.Wrapf(err, "Generating password failed for secret %s", name)
+			}
+			password = append(password, char)
+		}
+	}
+	for len(password) < length {
+		char, err := randomChar(all)
+		if err != nil {
+			return "", errors.Wrapf(err, "Generating password failed for secret %s", name)
+		}
+		password = append(password, char)
+	}
+
+	// Required characters must not always be at the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", errors.Wrapf(err, "Generating password failed for secret %s", name)
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+// generatePassphrase returns random words from the word list
+func generatePassphrase(name string, request credsgen.PasswordGenerationRequest) (string, error) {
+	words := request.Words
+	if words == 0 {
+		words = credsgen.DefaultPassphraseWords
+	}
+	if words < 0 || words > credsgen.MaxPassphraseWords {
+		return "", errors.Errorf("Generating passphrase failed for secret %s: %d words are not between 1 and %d", name, words, credsgen.MaxPassphraseWords)
+	}
+	separator := request.Separator
+	if separator == "" {
+		separator = defaultSeparator
+	}
+	if len(separator) > credsgen.MaxPassphraseSeparatorLength {
+		return "", errors.Errorf("Generating passphrase failed for secret %s: separator is longer than %d characters", name, credsgen.MaxPassphraseSeparatorLength)
+	}
+
+	phrase := make([]string, words)
+	for i := range phrase {
+		n, err := randomInt(len(wordList))
+		if err != nil {
+			return "", errors.Wrapf(err, "Generating passphrase failed for secret %s", name)
+		}
+		phrase[i] = wordList[n]
+	}
+
+	return strings.Join(phrase, separator), nil
+}
+
+func removeChars(chars, exclude string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(exclude, r) {
+			return -1
+		}
+		return r
+	}, chars)
+}
+
+func randomChar(chars string) (byte, error) {
+	n, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n], nil
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
 }
@@ -1,6 +1,9 @@
 package inmemorygenerator_test
 
 import (
+	"regexp"
+	"strings"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -21,15 +24,136 @@ var _ = Describe("InMemoryGenerator", func() {
 
 	Describe("GeneratePassword", func() {
 		It("has a default length", func() {
-			password := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{})
+			password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
 
 			Expect(len(password)).To(Equal(credsgen.DefaultPasswordLength))
 		})
 
 		It("considers custom lengths", func() {
-			password := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{Length: 10})
+			password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{Length: 10})
+			Expect(err).ToNot(HaveOccurred())
 
 			Expect(len(password)).To(Equal(10))
 		})
+
+		It("uses letters and digits by default", func() {
+			password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(MatchRegexp("^[a-zA-Z0-9]+$"))
+		})
+
+		It("excludes character classes", func() {
+			password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+				ExcludeUpper:  true,
+				ExcludeNumber: true,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(MatchRegexp("^[a-z]+$"))
+		})
+
+		It("includes the required number of special characters", func() {
+			for i := 0; i < 20; i++ {
+				password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+					Length:     8,
+					MinSpecial: 3,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(password).To(HaveLen(8))
+				Expect(len(regexp.MustCompile("[^a-zA-Z0-9]").FindAllString(password, -1))).To(BeNumerically(">=", 3))
+			}
+		})
+
+		It("includes every character class if required", func() {
+			for i := 0; i < 20; i++ {
+				password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+					Length:           4,
+					IncludeSpecial:   true,
+					RequireEachClass: true,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(password).To(MatchRegexp("[a-z]"))
+				Expect(password).To(MatchRegexp("[A-Z]"))
+				Expect(password).To(MatchRegexp("[0-9]"))
+				Expect(password).To(MatchRegexp("[^a-zA-Z0-9]"))
+			}
+		})
+
+		It("never uses excluded characters", func() {
+			password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+				Length:            200,
+				IncludeSpecial:    true,
+				ExcludeCharacters: "\"'`$lIO0",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strings.ContainsAny(password, "\"'`$lIO0")).To(BeFalse())
+		})
+
+		It("fails if the length is too short for the required characters", func() {
+			_, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+				Length:           3,
+				IncludeSpecial:   true,
+				RequireEachClass: true,
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("too short"))
+		})
+
+		It("fails if all characters are excluded", func() {
+			_, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+				ExcludeUpper:      true,
+				ExcludeLower:      true,
+				ExcludeNumber:     true,
+				MinSpecial:        1,
+				ExcludeCharacters: "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("are excluded"))
+		})
+
+		It("generates passphrases", func() {
+			password, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{Passphrase: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strings.Split(password, "-")).To(HaveLen(credsgen.DefaultPassphraseWords))
+
+			password, err = generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+				Passphrase: true,
+				Words:      4,
+				Separator:  " ",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(MatchRegexp("^[a-z]+ [a-z]+ [a-z]+ [a-z]+$"))
+		})
+
+		It("fails if the length is out of range", func() {
+			for _, length := range []int{-1, credsgen.MaxPasswordLength + 1} {
+				_, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{Length: length})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("is not between 1 and"))
+			}
+		})
+
+		It("fails if the minimum of special characters is negative", func() {
+			_, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{Length: 8, MinSpecial: -1})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is negative"))
+		})
+
+		It("fails if the number of passphrase words is out of range", func() {
+			for _, words := range []int{-1, credsgen.MaxPassphraseWords + 1} {
+				_, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{Passphrase: true, Words: words})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("are not between 1 and"))
+			}
+		})
+
+		It("fails if the passphrase separator is too long", func() {
+			_, err := generator.GeneratePassword("foo", credsgen.PasswordGenerationRequest{
+				Passphrase: true,
+				Separator:  strings.Repeat("-", credsgen.MaxPassphraseSeparatorLength+1),
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("separator is longer than"))
+		})
 	})
 })
@@ -1,41 +1,38 @@
 package inmemorygenerator
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
-
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
 	"github.com/pkg/errors"
 )
 
-// GenerateRSAKey generates an RSA key using go's standard crypto library
-func (g InMemoryGenerator) GenerateRSAKey(name string) (credsgen.RSAKey, error) {
+// GenerateRSAKey generates a key pair using go's standard crypto library. It
+// generates an RSA key unless another algorithm is requested.
+func (g InMemoryGenerator) GenerateRSAKey(name string, request credsgen.KeyGenerationRequest) (credsgen.RSAKey, error) {
 	g.log.Debugf("Generating RSA key %s", name)
 
+	if request.Algorithm == "" {
+		request.Algorithm = credsgen.RSAKeyAlgorithm
+	}
+	request, err := g.keyRequest(request)
+	if err != nil {
+		return credsgen.RSAKey{}, errors.Wrapf(err, "Generating private key failed for secret name %s", name)
+	}
+
 	// generate private key
-	private, err := rsa.GenerateKey(rand.Reader, g.Bits)
+	private, err := generatePrivateKey(request)
 	if err != nil {
 		return credsgen.RSAKey{}, errors.Wrapf(err, "Generating private key failed for secret name %s", name)
 	}
-	privateBlock := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(private),
+	privatePEM, err := marshalPrivateKey(private)
+	if err != nil {
+		return credsgen.RSAKey{}, errors.Wrapf(err, "Encoding private key failed for secret name %s", name)
 	}
-	privatePEM := pem.EncodeToMemory(privateBlock)
 
 	// Calculate public key
-	public := private.Public().(*rsa.PublicKey)
-	publicSerialized, err := x509.MarshalPKIXPublicKey(public)
+	publicPEM, err := marshalPublicKey(private)
 	if err != nil {
 		return credsgen.RSAKey{}, errors.Wrap(err, "generating public key")
 	}
-	publicBlock := &pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: publicSerialized,
-	}
-	publicPEM := pem.EncodeToMemory(publicBlock)
 
 	key := credsgen.RSAKey{
 		PrivateKey: privatePEM,
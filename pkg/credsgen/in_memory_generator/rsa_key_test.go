@@ -21,11 +21,33 @@ var _ = Describe("InMemoryGenerator", func() {
 
 	Describe("GenerateRSAKey", func() {
 		It("generates an RSA key", func() {
-			key, err := generator.GenerateRSAKey("foo")
+			key, err := generator.GenerateRSAKey("foo", credsgen.KeyGenerationRequest{})
 
 			Expect(err).ToNot(HaveOccurred())
 			Expect(key.PrivateKey).To(ContainSubstring("BEGIN RSA PRIVATE KEY"))
 			Expect(key.PublicKey).To(ContainSubstring("BEGIN PUBLIC KEY"))
 		})
+
+		It("generates an ECDSA key", func() {
+			key, err := generator.GenerateRSAKey("foo", credsgen.KeyGenerationRequest{Algorithm: credsgen.ECDSAKeyAlgorithm})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key.PrivateKey).To(ContainSubstring("BEGIN EC PRIVATE KEY"))
+			Expect(key.PublicKey).To(ContainSubstring("BEGIN PUBLIC KEY"))
+		})
+
+		It("generates an Ed25519 key", func() {
+			key, err := generator.GenerateRSAKey("foo", credsgen.KeyGenerationRequest{Algorithm: credsgen.Ed25519KeyAlgorithm})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key.PrivateKey).To(ContainSubstring("BEGIN PRIVATE KEY"))
+			Expect(key.PublicKey).To(ContainSubstring("BEGIN PUBLIC KEY"))
+		})
+
+		It("rejects unsupported ECDSA curves", func() {
+			_, err := generator.GenerateRSAKey("foo", credsgen.KeyGenerationRequest{Algorithm: credsgen.ECDSAKeyAlgorithm, Bits: 521})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("P-256 and P-384"))
+		})
 	})
 })
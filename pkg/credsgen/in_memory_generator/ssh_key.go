@@ -1,33 +1,36 @@
 package inmemorygenerator
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
-
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
 )
 
-// GenerateSSHKey generates an SSH key using go's standard crypto library
-func (g InMemoryGenerator) GenerateSSHKey(name string) (credsgen.SSHKey, error) {
+// GenerateSSHKey generates an SSH key using go's standard crypto library. It
+// generates an RSA key unless another algorithm is requested.
+func (g InMemoryGenerator) GenerateSSHKey(name string, request credsgen.KeyGenerationRequest) (credsgen.SSHKey, error) {
 	g.log.Debugf("Generating SSH key %s", name)
 
+	if request.Algorithm == "" {
+		request.Algorithm = credsgen.RSAKeyAlgorithm
+	}
+	request, err := g.keyRequest(request)
+	if err != nil {
+		return credsgen.SSHKey{}, errors.Wrapf(err, "Generating ssh key failed for secret %s", name)
+	}
+
 	// generate private key
-	private, err := rsa.GenerateKey(rand.Reader, g.Bits)
+	private, err := generatePrivateKey(request)
 	if err != nil {
 		return credsgen.SSHKey{}, errors.Wrapf(err, "Generating ssh key failed for secret %s", name)
 	}
-	privateBlock := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(private),
+	privatePEM, err := marshalSSHPrivateKey(private)
+	if err != nil {
+		return credsgen.SSHKey{}, errors.Wrapf(err, "Encoding ssh key failed for secret %s", name)
 	}
-	privatePEM := pem.EncodeToMemory(privateBlock)
 
 	// Calculate public key
-	public, err := ssh.NewPublicKey(&private.PublicKey)
+	public, err := ssh.NewPublicKey(private.Public())
 	if err != nil {
 		return credsgen.SSHKey{}, err
 	}
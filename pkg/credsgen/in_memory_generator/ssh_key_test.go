@@ -1,12 +1,16 @@
 package inmemorygenerator_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
 	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
 	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+	"golang.org/x/crypto/ssh"
 )
 
 var _ = Describe("InMemoryGenerator", func() {
@@ -21,12 +25,45 @@ var _ = Describe("InMemoryGenerator", func() {
 
 	Describe("GenerateSSHKey", func() {
 		It("generates an SSH key", func() {
-			key, err := generator.GenerateSSHKey("foo")
+			key, err := generator.GenerateSSHKey("foo", credsgen.KeyGenerationRequest{})
 
 			Expect(err).ToNot(HaveOccurred())
 			Expect(key.PrivateKey).To(ContainSubstring("BEGIN RSA PRIVATE KEY"))
 			Expect(key.PublicKey).To(MatchRegexp("ssh-rsa\\s.+"))
 			Expect(key.Fingerprint).To(MatchRegexp("([0-9a-f]{2}:){15}[0-9a-f]{2}"))
 		})
+
+		It("generates an ECDSA SSH key", func() {
+			key, err := generator.GenerateSSHKey("foo", credsgen.KeyGenerationRequest{Algorithm: credsgen.ECDSAKeyAlgorithm, Bits: 384})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key.PublicKey).To(MatchRegexp("ecdsa-sha2-nistp384\\s.+"))
+
+			private, err := ssh.ParseRawPrivateKey(key.PrivateKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(private).To(BeAssignableToTypeOf(&ecdsa.PrivateKey{}))
+		})
+
+		It("generates an Ed25519 SSH key in the OpenSSH format", func() {
+			key, err := generator.GenerateSSHKey("foo", credsgen.KeyGenerationRequest{Algorithm: credsgen.Ed25519KeyAlgorithm})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key.PrivateKey).To(ContainSubstring("BEGIN OPENSSH PRIVATE KEY"))
+			Expect(key.PublicKey).To(MatchRegexp("ssh-ed25519\\s.+"))
+
+			private, err := ssh.ParseRawPrivateKey(key.PrivateKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(private).To(BeAssignableToTypeOf(&ed25519.PrivateKey{}))
+
+			signer, err := ssh.NewSignerFromKey(private)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ssh.MarshalAuthorizedKey(signer.PublicKey())).To(Equal(key.PublicKey))
+		})
+
+		It("fails for unsupported algorithms", func() {
+			_, err := generator.GenerateSSHKey("foo", credsgen.KeyGenerationRequest{Algorithm: "dsa"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unsupported key algorithm 'dsa'"))
+		})
 	})
 })
@@ -0,0 +1,59 @@
+package inmemorygenerator
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+)
+
+// usernameRequest generates usernames of lower case letters
+var usernameRequest = credsgen.PasswordGenerationRequest{
+	Length:        credsgen.DefaultUsernameLength,
+	ExcludeUpper:  true,
+	ExcludeNumber: true,
+}
+
+// GenerateUser generates a password for the user and a random username, if
+// the request doesn't contain one
+func (g InMemoryGenerator) GenerateUser(name string, request credsgen.UserGenerationRequest) (credsgen.User, error) {
+	g.log.Debugf("Generating user %s", name)
+
+	username := request.Username
+	if username == "" {
+		var err error
+		username, err = g.GeneratePassword(name, usernameRequest)
+		if err != nil {
+			return credsgen.User{}, errors.Wrap(err, "generating username")
+		}
+	}
+
+	password, err := g.GeneratePassword(name, request.Password)
+	if err != nil {
+		return credsgen.User{}, errors.Wrap(err, "generating password")
+	}
+
+	return credsgen.User{Username: username, Password: password}, nil
+}
+
+// GenerateHTPasswd generates a user and an htpasswd entry with the bcrypt
+// hash of its password
+func (g InMemoryGenerator) GenerateHTPasswd(name string, request credsgen.UserGenerationRequest) (credsgen.HTPasswd, error) {
+	user, err := g.GenerateUser(name, request)
+	if err != nil {
+		return credsgen.HTPasswd{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return credsgen.HTPasswd{}, errors.Wrap(err, "hashing password")
+	}
+
+	return credsgen.HTPasswd{
+		Username: user.Username,
+		Password: user.Password,
+		Entry:    fmt.Sprintf("%s:%s", user.Username, hash),
+	}, nil
+}
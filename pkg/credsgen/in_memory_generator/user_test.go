@@ -0,0 +1,60 @@
+package inmemorygenerator_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("InMemoryGenerator", func() {
+	var (
+		generator credsgen.Generator
+	)
+
+	BeforeEach(func() {
+		_, log := helper.NewTestLogger()
+		generator = inmemorygenerator.NewInMemoryGenerator(log)
+	})
+
+	Describe("GenerateUser", func() {
+		It("generates a username and a password", func() {
+			user, err := generator.GenerateUser("foo", credsgen.UserGenerationRequest{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.Username).To(MatchRegexp("^[a-z]{20}$"))
+			Expect(user.Password).To(HaveLen(credsgen.DefaultPasswordLength))
+		})
+
+		It("keeps the requested username", func() {
+			user, err := generator.GenerateUser("foo", credsgen.UserGenerationRequest{Username: "admin"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.Username).To(Equal("admin"))
+		})
+
+		It("applies the password policy", func() {
+			user, err := generator.GenerateUser("foo", credsgen.UserGenerationRequest{
+				Password: credsgen.PasswordGenerationRequest{Length: 12, ExcludeUpper: true, ExcludeLower: true},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.Password).To(MatchRegexp("^[0-9]{12}$"))
+		})
+	})
+
+	Describe("GenerateHTPasswd", func() {
+		It("generates an htpasswd entry with the bcrypt hash of the password", func() {
+			htpasswd, err := generator.GenerateHTPasswd("foo", credsgen.UserGenerationRequest{Username: "admin"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(htpasswd.Username).To(Equal("admin"))
+
+			fields := strings.SplitN(htpasswd.Entry, ":", 2)
+			Expect(fields).To(HaveLen(2))
+			Expect(fields[0]).To(Equal("admin"))
+			Expect(bcrypt.CompareHashAndPassword([]byte(fields[1]), []byte(htpasswd.Password))).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,93 @@
+package inmemorygenerator
+
+// wordList contains the words used for generating passphrases
+var wordList = []string{
+	"able", "acid", "acorn", "acre", "actor", "adapt", "admit", "adobe", "adopt", "adult", "agent",
+	"agree", "ahead", "aisle", "alarm", "album", "alert", "algae", "alien", "alley", "allow", "alloy",
+	"aloe", "alpha", "amber", "amend", "ample", "anchor", "angle", "ankle", "anvil", "apex", "apple",
+	"april", "apron", "arch", "arena", "argue", "armor", "aroma", "arrow", "artist", "ashes", "aspen",
+	"atlas", "attic", "audio", "autumn", "avoid", "awake", "award", "axis", "bacon", "badge", "bagel",
+	"baker", "bale", "ballad", "balmy", "bamboo", "banjo", "banner", "barge", "barley", "barn",
+	"basil", "basin", "basket", "batch", "baton", "bay", "beach", "beacon", "beard", "beast",
+	"beetle", "begin", "bell", "belly", "bench", "beret", "berry", "bike", "birch", "bison", "bistro",
+	"blade", "blank", "blanket", "blaze", "blend", "bliss", "block", "bloom", "blues", "blunt",
+	"board", "boat", "bobcat", "bonnet", "bonus", "boost", "booth", "bottle", "bouquet", "bowl",
+	"boxer", "bracket", "brain", "brass", "brave", "bread", "breeze", "brick", "bride", "bridge",
+	"brief", "brisk", "brook", "broom", "brush", "bubble", "bucket", "buckle", "buddy", "buffalo",
+	"buggy", "bugle", "bulb", "bunch", "bundle", "bunny", "burst", "butter", "button", "buzzard",
+	"cabbage", "cabin", "cable", "cactus", "cadet", "cafe", "cake", "calf", "camel", "camera",
+	"canal", "candle", "candy", "canoe", "canvas", "canyon", "cape", "caramel", "cardinal", "cargo",
+	"carol", "carpet", "carrot", "carton", "cashew", "castle", "cattle", "cedar", "cellar", "cereal",
+	"chair", "chalk", "champ", "chant", "chapel", "charm", "chase", "cheek", "cheer", "cherry",
+	"chess", "chest", "chief", "chili", "chime", "chimney", "chip", "chirp", "choir", "chord",
+	"chunk", "cider", "cinder", "cinema", "circle", "citrus", "civic", "claim", "clam", "clamp",
+	"clash", "clasp", "claw", "clay", "clerk", "cliff", "climb", "cloak", "clock", "cloud", "clover",
+	"coach", "coast", "cobalt", "cobra", "cocoa", "coconut", "coffee", "collar", "comet", "comic",
+	"compass", "cookie", "copper", "coral", "cord", "corn", "cotton", "couch", "cougar", "cove",
+	"crab", "crane", "crate", "crayon", "cream", "creek", "crest", "cricket", "crisp", "crocus",
+	"crown", "crumb", "crust", "crystal", "cubic", "cupcake", "cupid", "curtain", "curve", "cushion",
+	"cycle", "dagger", "daisy", "dance", "dash", "dawn", "debut", "decal", "decoy", "delta", "denim",
+	"depot", "depth", "derby", "desk", "dessert", "diamond", "diary", "diner", "dinghy", "disco",
+	"ditch", "diver", "dock", "dodge", "dolphin", "domino", "donor", "donut", "doorway", "dough",
+	"dove", "draft", "dragon", "dragonfly", "drama", "dream", "dress", "drift", "drill", "drizzle",
+	"drum", "dune", "eagle", "earth", "easel", "ebony", "echo", "eclipse", "eel", "elbow", "elder",
+	"elect", "elite", "elk", "ember", "emblem", "emerald", "empty", "engine", "enjoy", "entry",
+	"envoy", "epic", "equal", "erupt", "essay", "ethic", "event", "exact", "exile", "exit", "expo",
+	"fable", "fabric", "facet", "fairy", "faith", "falafel", "falcon", "fancy", "farm", "feast",
+	"feather", "fence", "fern", "ferry", "fever", "fiber", "fiddle", "field", "fifty", "fig", "finch",
+	"firefly", "fjord", "flag", "flame", "flannel", "flask", "fleet", "flint", "flock", "flora",
+	"flute", "focus", "foggy", "folk", "forest", "forge", "fossil", "fountain", "fox", "frame",
+	"freckle", "fresh", "frog", "frost", "fruit", "fudge", "fungi", "gala", "galaxy", "gamma",
+	"garden", "garlic", "garnet", "gauge", "gazelle", "gecko", "gem", "genie", "geyser", "ghost",
+	"giant", "ginger", "glacier", "glade", "glass", "globe", "glove", "glow", "goat", "goblet",
+	"gold", "gong", "goose", "gopher", "gorge", "grain", "granite", "grape", "grass", "gravel",
+	"gravy", "green", "grid", "grill", "grove", "guard", "guava", "guest", "guide", "guitar", "gulf",
+	"gust", "habit", "hammer", "hamster", "harbor", "harp", "hatch", "haven", "hawk", "hazel",
+	"hazelnut", "heart", "hedge", "hedgehog", "helix", "helmet", "herb", "hero", "heron", "hickory",
+	"hiker", "hill", "hippo", "hobby", "honey", "honeybee", "hook", "hope", "hornet", "horse",
+	"hotel", "hound", "house", "humor", "husky", "hymn", "iceberg", "icon", "igloo", "image", "inch",
+	"index", "inlet", "input", "iris", "iron", "island", "ivory", "jacket", "jade", "jaguar", "jam",
+	"jar", "jasmine", "jazz", "jelly", "jellybean", "jewel", "jockey", "joke", "jolly", "journal",
+	"judge", "juice", "jumbo", "jungle", "juniper", "juror", "kayak", "kennel", "kernel", "ketchup",
+	"kettle", "kingdom", "kiosk", "kite", "kiwi", "knack", "knee", "knife", "koala", "label",
+	"ladder", "ladle", "lagoon", "lake", "lamb", "lamp", "lance", "lantern", "laser", "latch", "lava",
+	"lawn", "layer", "leaf", "ledge", "lemon", "lens", "lettuce", "level", "lilac", "lily", "lime",
+	"linen", "lion", "liquid", "llama", "lobby", "lobster", "locket", "lodge", "logic", "lotus",
+	"lucky", "lullaby", "lunar", "lunch", "lyric", "magic", "magnet", "magpie", "mammoth", "mango",
+	"manor", "mantle", "maple", "marble", "march", "marigold", "marsh", "mask", "meadow", "medal",
+	"meerkat", "melon", "menu", "merit", "metal", "meteor", "mint", "mirror", "mitten", "mocha",
+	"model", "molar", "monk", "monsoon", "moose", "mosaic", "moss", "motel", "motor", "mound",
+	"mouse", "muffin", "mural", "music", "mustard", "myth", "nacho", "napkin", "navy", "nectar",
+	"needle", "nest", "nickel", "night", "ninja", "noble", "noodle", "north", "novel", "nugget",
+	"nurse", "nutmeg", "oak", "oasis", "oats", "ocean", "octopus", "olive", "omega", "onion", "opal",
+	"opera", "orbit", "orchard", "orchid", "oregano", "organ", "ostrich", "otter", "outfit", "oval",
+	"oven", "owl", "oxide", "oyster", "paddle", "pagoda", "palm", "pancake", "panda", "panel",
+	"panther", "papaya", "paper", "parade", "parrot", "parsley", "pasta", "patio", "peach", "peacock",
+	"peanut", "pearl", "pebble", "pecan", "pedal", "pelican", "penguin", "penny", "pepper",
+	"peppermint", "perch", "pheasant", "piano", "pickle", "pigeon", "pillow", "pilot", "pine",
+	"pinecone", "pistachio", "pixel", "pizza", "planet", "plank", "plateau", "plaza", "plum", "poem",
+	"polar", "pony", "poppy", "porch", "pouch", "prairie", "pretzel", "prism", "pulse", "puma",
+	"pumpkin", "puppy", "puzzle", "quail", "quake", "quartz", "queen", "quest", "quiche", "quiet",
+	"quill", "quilt", "quota", "rabbit", "raccoon", "radar", "radio", "radish", "raft", "rain",
+	"rainbow", "raisin", "ranch", "raspberry", "raven", "razor", "reef", "reindeer", "relay",
+	"remedy", "rhubarb", "rhyme", "ribbon", "riddle", "ridge", "rifle", "ring", "river", "roast",
+	"robin", "robot", "rocket", "rodeo", "roof", "rose", "rover", "ruby", "rugby", "ruler", "saddle",
+	"safari", "saffron", "saga", "sailboat", "salad", "salmon", "salsa", "sandal", "sapphire",
+	"sardine", "satin", "sauce", "savor", "scale", "scallop", "scarf", "scene", "scout", "seashell",
+	"sedan", "seed", "sequoia", "sesame", "shade", "shamrock", "shark", "shelf", "shell", "sherbet",
+	"shield", "shore", "shrimp", "sierra", "signal", "silk", "silver", "siren", "sketch", "skill",
+	"skylark", "slate", "sleet", "slope", "smile", "snack", "snail", "snowflake", "solar", "sonic",
+	"spade", "spark", "sparrow", "spice", "spider", "spike", "spinach", "spoon", "sprout", "spruce",
+	"squad", "squirrel", "stable", "stage", "stamp", "starfish", "steam", "steel", "stem", "stone",
+	"storm", "straw", "stream", "strudel", "studio", "sugar", "summit", "sunflower", "sunny", "swamp",
+	"swan", "sweater", "syrup", "table", "tablet", "taco", "talon", "tango", "tapir", "teacup",
+	"teapot", "tempo", "tent", "thimble", "thistle", "thorn", "thunder", "tiger", "timber", "toast",
+	"toffee", "token", "topaz", "torch", "tortoise", "totem", "toucan", "tower", "trail", "tram",
+	"treetop", "tribe", "trophy", "trumpet", "tulip", "tuna", "tundra", "turnip", "turtle", "tutor",
+	"twig", "twilight", "umbra", "unicorn", "union", "unit", "urban", "vanilla", "vapor", "vault",
+	"velvet", "venom", "verse", "vessel", "view", "villa", "vine", "violet", "violin", "visor",
+	"vivid", "vocal", "volcano", "voter", "voyage", "wafer", "waffle", "wagon", "walnut", "walrus",
+	"wand", "warbler", "wave", "whale", "wheat", "wheel", "whisk", "wildcat", "willow", "window",
+	"winter", "wizard", "wolf", "wombat", "woodland", "wool", "yacht", "yard", "yeast", "yodel",
+	"yogurt", "zebra", "zenith", "zephyr", "zinc", "zipper", "zodiac", "zone",
+}
@@ -0,0 +1,92 @@
+package credsgen
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Names of the built-in generator backends
+const (
+	// InMemoryBackend generates credentials inside the operator
+	InMemoryBackend = "in-memory"
+	// ExternalBackend requests credentials from an external credential store
+	ExternalBackend = "external"
+)
+
+// Registry holds the available generator backends by name
+type Registry struct {
+	defaultBackend string
+	backends       map[string]Generator
+	mutex          sync.RWMutex
+}
+
+// NewRegistry returns a registry, which uses the given generator as default backend
+func NewRegistry(name string, generator Generator) *Registry {
+	return &Registry{
+		defaultBackend: name,
+		backends:       map[string]Generator{name: generator},
+	}
+}
+
+// Register adds a generator backend, replacing an existing backend of the same name
+func (r *Registry) Register(name string, generator Generator) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.backends[name] = generator
+}
+
+// SetDefault selects the backend, which is used if no backend is requested
+func (r *Registry) SetDefault(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.backends[name]; !ok {
+		return fmt.Errorf("unknown credential generator backend '%s', available backends: %v", name, r.names())
+	}
+	r.defaultBackend = name
+	return nil
+}
+
+// Default returns the name of the default backend
+func (r *Registry) Default() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.defaultBackend
+}
+
+// Generator returns the backend with the given name, or the default backend
+// if name is empty
+func (r *Registry) Generator(name string) (Generator, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if name == "" {
+		name = r.defaultBackend
+	}
+
+	generator, ok := r.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown credential generator backend '%s', available backends: %v", name, r.names())
+	}
+	return generator, nil
+}
+
+// Backends returns the sorted names of all registered backends
+func (r *Registry) Backends() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.names()
+}
+
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
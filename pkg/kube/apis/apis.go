@@ -1,6 +1,7 @@
 package apis
 
 import (
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -26,3 +27,24 @@ type Object interface {
 	runtime.Object
 	metav1.Object
 }
+
+// PrunePolicy defines how owned resources, which are not desired anymore, are removed
+type PrunePolicy string
+
+const (
+	// PrunePolicyDelete deletes resources, which are not desired anymore. It's the default.
+	PrunePolicyDelete PrunePolicy = "delete"
+	// PrunePolicyOrphan keeps resources, which are not desired anymore, but
+	// removes the owner reference, so they are not managed nor garbage collected anymore.
+	PrunePolicyOrphan PrunePolicy = "orphan"
+)
+
+// PrunePolicyValidation is the validation of prune policy fields
+var PrunePolicyValidation = extv1.JSONSchemaProps{
+	Type:        "string",
+	Description: "Indicates whether resources, which are not desired anymore, are deleted or orphaned",
+	Enum: []extv1.JSON{
+		{Raw: []byte(`"delete"`)},
+		{Raw: []byte(`"orphan"`)},
+	},
+}
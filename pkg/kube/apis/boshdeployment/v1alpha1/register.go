@@ -39,71 +39,108 @@ var (
 				"spec": {
 					Type: "object",
 					Properties: map[string]extv1.JSONSchemaProps{
-						"manifest": {
-							Type: "object",
-							Properties: map[string]extv1.JSONSchemaProps{
-								"name": {
-									Type:      "string",
-									MinLength: pointers.Int64(1),
-								},
-								"type": {
-									Type: "string",
-									Enum: []extv1.JSON{
-										{
-											Raw: []byte(`"configmap"`),
-										},
-										{
-											Raw: []byte(`"secret"`),
-										},
-										{
-											Raw: []byte(`"url"`),
-										},
-									},
-								},
-							},
-							Required: []string{
-								"type",
-								"name",
-							},
-						},
+						"manifest": resourceReferenceValidation,
 						"ops": {
 							Type: "array",
 							Items: &extv1.JSONSchemaPropsOrArray{
-								Schema: &extv1.JSONSchemaProps{
-									Type: "object",
-									Properties: map[string]extv1.JSONSchemaProps{
-										"name": {
-											Type:      "string",
-											MinLength: pointers.Int64(1),
-										},
-										"type": {
-											Type: "string",
-											Enum: []extv1.JSON{
-												{
-													Raw: []byte(`"configmap"`),
-												},
-												{
-													Raw: []byte(`"secret"`),
-												},
-												{
-													Raw: []byte(`"url"`),
-												},
-											},
-										},
-									},
-									Required: []string{
-										"type",
-										"name",
-									},
-								},
+								Schema: &resourceReferenceValidation,
 							},
 						},
+						"prunePolicy": apis.PrunePolicyValidation,
 					},
 					Required: []string{
 						"manifest",
 					},
 				},
+				"status": {
+					Type:                   "object",
+					Description:            "Conditions of the deployment stages and readiness of the instance groups, written by the operator",
+					XPreserveUnknownFields: pointers.Bool(true),
+				},
+			},
+		},
+	}
+
+	resourceReferenceValidation = extv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]extv1.JSONSchemaProps{
+			"name": {
+				Type:      "string",
+				MinLength: pointers.Int64(1),
+			},
+			"type": {
+				Type: "string",
+				Enum: []extv1.JSON{
+					{
+						Raw: []byte(`"configmap"`),
+					},
+					{
+						Raw: []byte(`"secret"`),
+					},
+					{
+						Raw: []byte(`"url"`),
+					},
+					{
+						Raw: []byte(`"git"`),
+					},
+				},
 			},
+			"sha256": {
+				Type:    "string",
+				Pattern: "^[a-fA-F0-9]{64}$",
+			},
+			"caBundle": {
+				Type: "string",
+			},
+			"secretName": {
+				Type: "string",
+			},
+			"pollInterval": {
+				Type: "string",
+			},
+			"ref": {
+				Type: "string",
+			},
+			"path": {
+				Type: "string",
+			},
+		},
+		Required: []string{
+			"type",
+			"name",
+		},
+	}
+
+	// BOSHDeploymentAdditionalPrinterColumns are the columns shown by `kubectl get bdpl`
+	BOSHDeploymentAdditionalPrinterColumns = []extv1.CustomResourceColumnDefinition{
+		{
+			Name:        "Ready",
+			Type:        "string",
+			Description: "Indicates if all instance groups are ready",
+			JSONPath:    `.status.conditions[?(@.type=="Ready")].status`,
+		},
+		{
+			Name:        "Reason",
+			Type:        "string",
+			Description: "Reason for the current ready status",
+			JSONPath:    `.status.conditions[?(@.type=="Ready")].reason`,
+		},
+		{
+			Name:        "Ready IGs",
+			Type:        "integer",
+			Description: "Number of instance groups with all replicas ready",
+			JSONPath:    ".status.readyInstanceGroups",
+		},
+		{
+			Name:        "Total IGs",
+			Type:        "integer",
+			Description: "Number of instance groups in the desired manifest",
+			JSONPath:    ".status.totalInstanceGroups",
+		},
+		{
+			Name:     "Age",
+			Type:     "date",
+			JSONPath: ".metadata.creationTimestamp",
 		},
 	}
 
@@ -3,6 +3,7 @@ package v1alpha1
 import (
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
@@ -23,6 +24,8 @@ const (
 	SecretReference ReferenceType = "secret"
 	// URLReference represents URL reference
 	URLReference ReferenceType = "url"
+	// GitReference represents a file in a git repository
+	GitReference ReferenceType = "git"
 
 	ManifestSpecName        string = "manifest"
 	OpsSpecName             string = "ops"
@@ -38,24 +41,157 @@ var (
 	AnnotationLinkProvidesKey = fmt.Sprintf("%s/provides", apis.GroupName)
 	// AnnotationLinkProviderService is the annotation key used on services to identify the link provider
 	AnnotationLinkProviderService = fmt.Sprintf("%s/link-provider-name", apis.GroupName)
+	// AnnotationDryRun is the annotation key to only plan changes to a BOSHDeployment instead of applying them
+	AnnotationDryRun = fmt.Sprintf("%s/dry-run", apis.GroupName)
+	// AnnotationURLDigests is the annotation key for the digests of the
+	// polled url references used for the last render, as a JSON object
+	AnnotationURLDigests = fmt.Sprintf("%s/url-digests", apis.GroupName)
 )
 
 // BOSHDeploymentSpec defines the desired state of BOSHDeployment
 type BOSHDeploymentSpec struct {
 	Manifest ResourceReference   `json:"manifest"`
 	Ops      []ResourceReference `json:"ops,omitempty"`
+	// Indicates whether resources of removed instance groups are deleted or orphaned, defaults to delete
+	PrunePolicy apis.PrunePolicy `json:"prunePolicy,omitempty"`
 }
 
 // ResourceReference defines the resource reference type and location
 type ResourceReference struct {
 	Name string        `json:"name"`
 	Type ReferenceType `json:"type"`
+	// The following fields are only used by url and git references
+
+	// Hex encoded SHA256 checksum the fetched content has to match
+	SHA256 string `json:"sha256,omitempty"`
+	// PEM encoded CA certificates to verify the https server with
+	CABundle string `json:"caBundle,omitempty"`
+	// Name of a secret in the same namespace, which can contain a CA bundle
+	// ('ca.crt'), basic auth credentials ('username', 'password') or a
+	// bearer token ('token'). Git references only use the credentials.
+	SecretName string `json:"secretName,omitempty"`
+	// Branch, tag or commit of a git reference, defaults to HEAD
+	Ref string `json:"ref,omitempty"`
+	// Path of the file in a git repository. For ops files this can be a glob
+	// pattern, all matching files are applied in alphabetical order.
+	Path string `json:"path,omitempty"`
+	// Interval for fetching the url again, a changed content triggers a
+	// reconcile. Polling is disabled if unset or if a checksum is pinned.
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// BOSHDeploymentConditionType is the type of a BOSHDeployment condition
+type BOSHDeploymentConditionType string
+
+// Valid values for condition types, listed in the order the stages are
+// reached during a deployment
+const (
+	// ManifestResolved is true when the manifest and all ops files were
+	// resolved into the with-ops manifest secret
+	ManifestResolved BOSHDeploymentConditionType = "ManifestResolved"
+	// VariablesGenerated is true when the QuarksSecrets for all explicit
+	// BOSH variables were applied
+	VariablesGenerated BOSHDeploymentConditionType = "VariablesGenerated"
+	// DesiredManifestRendered is true when the variable interpolation and
+	// instance group manifest QuarksJobs produced their output secrets
+	DesiredManifestRendered BOSHDeploymentConditionType = "DesiredManifestRendered"
+	// InstanceGroupsDeployed is true when a StatefulSet exists for every
+	// instance group of the desired manifest
+	InstanceGroupsDeployed BOSHDeploymentConditionType = "InstanceGroupsDeployed"
+	// Ready is true when all stages succeeded and all instance group
+	// replicas are ready
+	Ready BOSHDeploymentConditionType = "Ready"
+)
+
+// BOSHDeploymentCondition describes the state of one deployment stage
+type BOSHDeploymentCondition struct {
+	Type   BOSHDeploymentConditionType `json:"type"`
+	Status corev1.ConditionStatus      `json:"status"`
+	// Last time the condition transitioned from one status to another
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Machine readable reason for the last transition
+	Reason string `json:"reason,omitempty"`
+	// Human readable details about the last transition
+	Message string `json:"message,omitempty"`
+}
+
+// InstanceGroupStatus contains the replica counts of a deployed instance group
+type InstanceGroupStatus struct {
+	Name          string `json:"name"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+	// Reason of a failed rollout of the instance group's StatefulSets
+	RolloutFailure string `json:"rolloutFailure,omitempty"`
+}
+
+// GitReferenceStatus contains the commit a git reference was resolved to
+type GitReferenceStatus struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Commit     string `json:"commit"`
+}
+
+// PlannedChange describes how applying the spec would change a resource
+type PlannedChange struct {
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Action  string   `json:"action"`
+	Details []string `json:"details,omitempty"`
 }
 
 // BOSHDeploymentStatus defines the observed state of BOSHDeployment
 type BOSHDeploymentStatus struct {
 	// Timestamp for the last reconcile
 	LastReconcile *metav1.Time `json:"lastReconcile"`
+	// The generation of the spec the conditions refer to
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Number of instance groups in the desired manifest
+	TotalInstanceGroups int32 `json:"totalInstanceGroups,omitempty"`
+	// Number of instance groups with all replicas ready
+	ReadyInstanceGroups int32 `json:"readyInstanceGroups,omitempty"`
+	// Replica counts per instance group
+	InstanceGroups []InstanceGroupStatus `json:"instanceGroups,omitempty"`
+	// Conditions of the deployment stages
+	Conditions []BOSHDeploymentCondition `json:"conditions,omitempty"`
+	// Commits of the git references used for the last resolved manifest
+	GitReferences []GitReferenceStatus `json:"gitReferences,omitempty"`
+	// Changes the current spec would cause, only set in dry-run mode
+	PlannedChanges []PlannedChange `json:"plannedChanges,omitempty"`
+}
+
+// GetCondition returns the condition with the given type, or nil if it is not set
+func (s *BOSHDeploymentStatus) GetCondition(conditionType BOSHDeploymentConditionType) *BOSHDeploymentCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns true if the condition with the given type has status true
+func (s *BOSHDeploymentStatus) IsConditionTrue(conditionType BOSHDeploymentConditionType) bool {
+	c := s.GetCondition(conditionType)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
+
+// SetCondition adds or updates a condition. The transition time is only
+// changed if the status of the condition changes.
+func (s *BOSHDeploymentStatus) SetCondition(conditionType BOSHDeploymentConditionType, status corev1.ConditionStatus, reason, message string) {
+	c := s.GetCondition(conditionType)
+	if c == nil {
+		s.Conditions = append(s.Conditions, BOSHDeploymentCondition{Type: conditionType})
+		c = &s.Conditions[len(s.Conditions)-1]
+	}
+
+	if c.Status != status || c.LastTransitionTime == nil {
+		now := metav1.Now()
+		c.LastTransitionTime = &now
+	}
+	c.Status = status
+	c.Reason = reason
+	c.Message = message
 }
 
 // +genclient
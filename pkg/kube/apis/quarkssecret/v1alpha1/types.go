@@ -22,6 +22,9 @@ const (
 	Certificate SecretType = "certificate"
 	SSHKey      SecretType = "ssh"
 	RSAKey      SecretType = "rsa"
+	User        SecretType = "user"
+	HTPasswd    SecretType = "htpasswd"
+	DHParams    SecretType = "dhparams"
 )
 
 // SignerType defines the type of the certificate signer
@@ -42,10 +45,19 @@ var (
 	AnnotationCertSecretName = fmt.Sprintf("%s/cert-secret-name", apis.GroupName)
 	// AnnotationQSecNamespace is the annotation key for quarks secret namespace
 	AnnotationQSecNamespace = fmt.Sprintf("%s/quarks-secret-namespace", apis.GroupName)
+	// AnnotationQSecName is the annotation key for quarks secret name
+	AnnotationQSecName = fmt.Sprintf("%s/quarks-secret-name", apis.GroupName)
 	// LabelSecretRotationTrigger is set on a config map to trigger secret
 	// rotation. If set, then creating the config map will trigger secret
 	// rotation.
 	LabelSecretRotationTrigger = fmt.Sprintf("%s/secret-rotation", apis.GroupName)
+	// AnnotationCredsgenBackend is the annotation key to select the
+	// credential generator backend, if the spec doesn't name one
+	AnnotationCredsgenBackend = fmt.Sprintf("%s/credsgen-backend", apis.GroupName)
+	// FinalizerSecretCopies is set on QuarksSecrets with copies, which are
+	// deleted before the QuarksSecret, since owner references can't cross
+	// namespaces
+	FinalizerSecretCopies = fmt.Sprintf("%s/secret-copies", apis.GroupName)
 	// RotateQSecretListName is the name of the config map entry, which
 	// contains a JSON array of quarks secret names to rotate
 	RotateQSecretListName = "secrets"
@@ -54,6 +66,9 @@ var (
 const (
 	// GeneratedSecretKind is the kind of generated secret
 	GeneratedSecretKind = "generated"
+	// CopiedSecretKind is the kind of a copy of a generated secret in
+	// another namespace
+	CopiedSecretKind = "copy"
 )
 
 // SecretReference specifies a reference to another secret
@@ -62,6 +77,14 @@ type SecretReference struct {
 	Key  string
 }
 
+// SecretCopy specifies a namespace the generated secret is copied to. The
+// namespace has to be watched by the operator.
+type SecretCopy struct {
+	Namespace string `json:"namespace"`
+	// Name of the copy, defaults to the name of the generated secret
+	Name string `json:"name,omitempty"`
+}
+
 // ServiceReference specifies a reference to a service
 type ServiceReference struct {
 	Name string
@@ -78,11 +101,59 @@ type CertificateRequest struct {
 	Usages                      []certv1.KeyUsage  `json:"usages"`
 	ServiceRef                  []ServiceReference `json:"serviceRef"`
 	ActivateEKSWorkaroundForSAN bool               `json:"activateEKSWorkaroundForSAN,omitempty"`
+	// Duration is the validity of locally signed certificates, defaults
+	// to one year
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// PasswordRequest specifies the policy for the password generation.
+// Without any options, passwords consist of 64 lower and upper case letters
+// and digits.
+type PasswordRequest struct {
+	Length         int  `json:"length,omitempty"`
+	ExcludeUpper   bool `json:"excludeUpper,omitempty"`
+	ExcludeLower   bool `json:"excludeLower,omitempty"`
+	ExcludeNumber  bool `json:"excludeNumber,omitempty"`
+	IncludeSpecial bool `json:"includeSpecial,omitempty"`
+	// RequireEachClass ensures at least one character of every used class
+	RequireEachClass bool `json:"requireEachClass,omitempty"`
+	// MinSpecial is the minimum number of special characters
+	MinSpecial        int    `json:"minSpecial,omitempty"`
+	ExcludeCharacters string `json:"excludeCharacters,omitempty"`
+	// Passphrase generates a number of words instead of random characters
+	Passphrase bool   `json:"passphrase,omitempty"`
+	Words      int    `json:"words,omitempty"`
+	Separator  string `json:"separator,omitempty"`
+}
+
+// KeyRequest specifies the key algorithm for certificates, SSH and RSA keys.
+// Supported algorithms are rsa (default), ecdsa and ed25519. Bits is the
+// RSA key size or the ECDSA curve size (256 or 384).
+type KeyRequest struct {
+	Algorithm string `json:"algorithm,omitempty"`
+	Bits      int    `json:"bits,omitempty"`
+}
+
+// UserRequest specifies the username of user and htpasswd secrets. A random
+// username is generated, if it's empty. The password follows the password
+// request's policy.
+type UserRequest struct {
+	Username string `json:"username,omitempty"`
+}
+
+// DHParamsRequest specifies the size of the prime of Diffie-Hellman
+// parameters, defaults to 2048 bits
+type DHParamsRequest struct {
+	Bits int `json:"bits,omitempty"`
 }
 
 // Request specifies details for the secret generation
 type Request struct {
 	CertificateRequest CertificateRequest `json:"certificate"`
+	PasswordRequest    PasswordRequest    `json:"password,omitempty"`
+	KeyRequest         KeyRequest         `json:"key,omitempty"`
+	UserRequest        UserRequest        `json:"user,omitempty"`
+	DHParamsRequest    DHParamsRequest    `json:"dhparams,omitempty"`
 }
 
 // QuarksSecretSpec defines the desired state of QuarksSecret
@@ -90,6 +161,12 @@ type QuarksSecretSpec struct {
 	Type       SecretType `json:"type"`
 	Request    Request    `json:"request"`
 	SecretName string     `json:"secretName"`
+	// Backend names the credential generator backend, defaults to the
+	// backend selected by the operator
+	Backend string `json:"backend,omitempty"`
+	// Copies of the generated secret are created in these namespaces and
+	// kept in sync with the generated secret
+	Copies []SecretCopy `json:"copies,omitempty"`
 }
 
 // QuarksSecretStatus defines the observed state of QuarksSecret
@@ -98,6 +175,27 @@ type QuarksSecretStatus struct {
 	LastReconcile *metav1.Time `json:"lastReconcile"`
 	// Indicates if the secret has already been generated
 	Generated bool `json:"generated"`
+	// Validity of the generated certificate
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+	NotAfter  *metav1.Time `json:"notAfter,omitempty"`
+	// Approval records the decision about the certificate signing request
+	// of a cluster signed certificate
+	Approval *CertificateApproval `json:"approval,omitempty"`
+	// Copies of the generated secret in other namespaces, which are deleted
+	// once they are removed from the spec
+	Copies []SecretCopy `json:"copies,omitempty"`
+}
+
+// CertificateApproval is the operator's decision about a certificate
+// signing request
+type CertificateApproval struct {
+	// Request is the name of the certificate signing request
+	Request  string `json:"request"`
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message,omitempty"`
+	// Time of the decision
+	Time metav1.Time `json:"time"`
 }
 
 // +genclient
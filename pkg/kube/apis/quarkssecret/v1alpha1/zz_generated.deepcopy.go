@@ -11,9 +11,27 @@ package v1alpha1
 
 import (
 	v1beta1 "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateApproval) DeepCopyInto(out *CertificateApproval) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateApproval.
+func (in *CertificateApproval) DeepCopy() *CertificateApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertificateRequest) DeepCopyInto(out *CertificateRequest) {
 	*out = *in
@@ -34,6 +52,11 @@ func (in *CertificateRequest) DeepCopyInto(out *CertificateRequest) {
 		*out = make([]ServiceReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	return
 }
 
@@ -47,6 +70,54 @@ func (in *CertificateRequest) DeepCopy() *CertificateRequest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DHParamsRequest) DeepCopyInto(out *DHParamsRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DHParamsRequest.
+func (in *DHParamsRequest) DeepCopy() *DHParamsRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(DHParamsRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRequest) DeepCopyInto(out *KeyRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRequest.
+func (in *KeyRequest) DeepCopy() *KeyRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordRequest) DeepCopyInto(out *PasswordRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordRequest.
+func (in *PasswordRequest) DeepCopy() *PasswordRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *QuarksSecret) DeepCopyInto(out *QuarksSecret) {
 	*out = *in
@@ -112,6 +183,11 @@ func (in *QuarksSecretList) DeepCopyObject() runtime.Object {
 func (in *QuarksSecretSpec) DeepCopyInto(out *QuarksSecretSpec) {
 	*out = *in
 	in.Request.DeepCopyInto(&out.Request)
+	if in.Copies != nil {
+		in, out := &in.Copies, &out.Copies
+		*out = make([]SecretCopy, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -132,6 +208,24 @@ func (in *QuarksSecretStatus) DeepCopyInto(out *QuarksSecretStatus) {
 		in, out := &in.LastReconcile, &out.LastReconcile
 		*out = (*in).DeepCopy()
 	}
+	if in.NotBefore != nil {
+		in, out := &in.NotBefore, &out.NotBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(CertificateApproval)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Copies != nil {
+		in, out := &in.Copies, &out.Copies
+		*out = make([]SecretCopy, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -149,6 +243,10 @@ func (in *QuarksSecretStatus) DeepCopy() *QuarksSecretStatus {
 func (in *Request) DeepCopyInto(out *Request) {
 	*out = *in
 	in.CertificateRequest.DeepCopyInto(&out.CertificateRequest)
+	out.PasswordRequest = in.PasswordRequest
+	out.KeyRequest = in.KeyRequest
+	out.UserRequest = in.UserRequest
+	out.DHParamsRequest = in.DHParamsRequest
 	return
 }
 
@@ -162,6 +260,22 @@ func (in *Request) DeepCopy() *Request {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretCopy) DeepCopyInto(out *SecretCopy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretCopy.
+func (in *SecretCopy) DeepCopy() *SecretCopy {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretCopy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretReference) DeepCopyInto(out *SecretReference) {
 	*out = *in
@@ -193,3 +307,19 @@ func (in *ServiceReference) DeepCopy() *ServiceReference {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserRequest) DeepCopyInto(out *UserRequest) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserRequest.
+func (in *UserRequest) DeepCopy() *UserRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(UserRequest)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,6 +22,9 @@ const (
 )
 
 var (
+	minActive   = float64(1)
+	minReplicas = float64(0)
+
 	schemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
 
 	// AddToScheme is used for schema registrations in the controller package
@@ -50,6 +54,27 @@ var (
 							Type:        "object",
 							Description: "Defines probes to determine active/passive component instances",
 						},
+						"activePassive": {
+							Type:        "object",
+							Description: "Configures how the active pods are selected from the pods passing the active/passive probe",
+							Properties: map[string]extv1.JSONSchemaProps{
+								"exclusive": {
+									Type:        "boolean",
+									Description: "Indicates whether at most maxActive pods are active, each holding a lease",
+								},
+								"maxActive": {
+									Type:        "integer",
+									Description: "Maximum number of active pods in exclusive mode, defaults to 1",
+									Minimum:     &minActive,
+								},
+							},
+						},
+						"prunePolicy": apis.PrunePolicyValidation,
+						"replicas": {
+							Type:        "integer",
+							Description: "Total number of replicas, spread across the zones",
+							Minimum:     &minReplicas,
+						},
 						"zoneNodeLabel": {
 							Type:        "string",
 							Description: "Indicates the node label that a node locates",
@@ -68,10 +93,33 @@ var (
 						"template",
 					},
 				},
+				"status": {
+					Type:                   "object",
+					Description:            "Observed state of the QuarksStatefulSet, written by the operator",
+					XPreserveUnknownFields: pointers.Bool(true),
+					Properties: map[string]extv1.JSONSchemaProps{
+						"replicas": {
+							Type:        "integer",
+							Description: "Total number of replicas of the StatefulSets, used by the scale subresource",
+						},
+						"selector": {
+							Type:        "string",
+							Description: "Label selector of the pods, used by the scale subresource",
+						},
+					},
+				},
 			},
 		},
 	}
 
+	// QuarksStatefulSetScaleSubresource maps the scale subresource to the
+	// total replicas of the QuarksStatefulSet
+	QuarksStatefulSetScaleSubresource = extv1.CustomResourceSubresourceScale{
+		SpecReplicasPath:   ".spec.replicas",
+		StatusReplicasPath: ".status.replicas",
+		LabelSelectorPath:  pointers.String(".status.selector"),
+	}
+
 	// QuarksStatefulSetResourceName is the resource name of QuarksStatefulSet
 	QuarksStatefulSetResourceName = fmt.Sprintf("%s.%s", QuarksStatefulSetResourcePlural, apis.GroupName)
 
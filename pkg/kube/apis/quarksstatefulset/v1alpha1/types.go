@@ -20,6 +20,9 @@ const DefaultZoneNodeLabel = "failure-domain.beta.kubernetes.io/zone"
 var (
 	// AnnotationVersion is the annotation key for the StatefulSet version
 	AnnotationVersion = fmt.Sprintf("%s/version", apis.GroupName)
+	// AnnotationTemplateHash is the hash of the desired pod template of a
+	// StatefulSet, without its version
+	AnnotationTemplateHash = fmt.Sprintf("%s/template-hash", apis.GroupName)
 	// AnnotationZones is an array of all zones
 	AnnotationZones = fmt.Sprintf("%s/zones", apis.GroupName)
 	// LabelAZIndex is the index of available zone
@@ -32,8 +35,14 @@ var (
 	LabelQStsName = fmt.Sprintf("%s/quarks-statefulset-name", apis.GroupName)
 	// LabelActivePod is the active pod on an active/passive setup
 	LabelActivePod = fmt.Sprintf("%s/pod-active", apis.GroupName)
+	// AnnotationActiveLease is the lease held by an active pod in exclusive
+	// mode and its transitions, the fencing token of the pod
+	AnnotationActiveLease = fmt.Sprintf("%s/active-lease", apis.GroupName)
 )
 
+// DefaultMaxActive is the number of active pods in exclusive active/passive mode
+const DefaultMaxActive = 1
+
 // QuarksStatefulSetSpec defines the desired state of QuarksStatefulSet
 type QuarksStatefulSetSpec struct {
 	// Indicates whether to update Pods in the StatefulSet when an env value or mount changes
@@ -48,15 +57,93 @@ type QuarksStatefulSetSpec struct {
 	// Defines a regular StatefulSet template
 	Template appsv1.StatefulSet `json:"template"`
 
+	// Total number of replicas, spread across the zones. Overrides the
+	// replicas of the template, which are the replicas per zone, if set.
+	// Set by the scale subresource.
+	Replicas *int32 `json:"replicas,omitempty"`
+
 	// Periodic probe for active/passive containers
 	// Only an active container will process request from a service
-	ActivePassiveProbes map[string]corev1.Probe `json:"activePassiveProbes,omitempty"`
+	ActivePassiveProbes map[string]ActivePassiveProbe `json:"activePassiveProbes,omitempty"`
+
+	// Configures how the active pods are selected from the pods passing the active/passive probe
+	ActivePassive *ActivePassive `json:"activePassive,omitempty"`
+
+	// Indicates whether StatefulSets of removed zones are deleted or orphaned, defaults to delete
+	PrunePolicy apis.PrunePolicy `json:"prunePolicy,omitempty"`
+}
+
+// ActivePassiveProbe is a probe, which determines whether a container is
+// active. Besides the handlers of a regular probe it supports gRPC health
+// checks. Its key differs from the `grpc` handler of newer Kubernetes probes,
+// which would clash with the inlined probe.
+type ActivePassiveProbe struct {
+	corev1.Probe `json:",inline"`
+
+	// GRPCHealthCheck checks the health of a gRPC service
+	GRPCHealthCheck *GRPCAction `json:"grpcHealthCheck,omitempty"`
+}
+
+// GRPCAction checks the health of a gRPC service by the gRPC health checking protocol
+type GRPCAction struct {
+	// Port of the gRPC service
+	Port int32 `json:"port"`
+	// Name of the service to check, the overall health of the server is checked if empty
+	Service string `json:"service,omitempty"`
+}
+
+// ActivePassive configures the selection of active pods
+type ActivePassive struct {
+	// Indicates whether at most MaxActive pods are active, each holding a lease,
+	// instead of all pods passing the probe
+	Exclusive bool `json:"exclusive"`
+	// Maximum number of active pods in exclusive mode, defaults to 1
+	MaxActive int `json:"maxActive,omitempty"`
+}
+
+// GetMaxActive returns the maximum number of active pods in exclusive mode
+func (a *ActivePassive) GetMaxActive() int {
+	if a == nil || a.MaxActive < 1 {
+		return DefaultMaxActive
+	}
+	return a.MaxActive
 }
 
 // QuarksStatefulSetStatus defines the observed state of QuarksStatefulSet
 type QuarksStatefulSetStatus struct {
 	// Timestamp for the last reconcile
 	LastReconcile *metav1.Time `json:"lastReconcile"`
+
+	// Failed rollouts of the owned StatefulSets
+	FailedRollouts []RolloutFailure `json:"failedRollouts,omitempty"`
+
+	// Pods labeled active by the active/passive controller
+	ActivePods []string `json:"activePods,omitempty"`
+
+	// Time the active pods changed last
+	LastActiveTransition *metav1.Time `json:"lastActiveTransition,omitempty"`
+
+	// Total number of replicas of the StatefulSets, used by the scale subresource
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Label selector of the pods, used by the scale subresource
+	Selector string `json:"selector,omitempty"`
+}
+
+// RolloutFailure describes the failed rollout of a StatefulSet
+type RolloutFailure struct {
+	// Name of the StatefulSet
+	StatefulSet string `json:"statefulSet"`
+	// Controller revision, which failed to roll out
+	Revision string `json:"revision,omitempty"`
+	// Machine readable reason for the failure
+	Reason string `json:"reason"`
+	// Human readable details about the failure
+	Message string `json:"message,omitempty"`
+	// Indicates whether the updated pods were rolled back to the previous revision
+	RolledBack bool `json:"rolledBack"`
+	// Time of the failure
+	Time metav1.Time `json:"time"`
 }
 
 // +genclient
@@ -81,6 +168,29 @@ type QuarksStatefulSetList struct {
 	Items           []QuarksStatefulSet `json:"items"`
 }
 
+// IsExclusiveActivePassive returns true if at most MaxActive pods are selected as active
+func (q *QuarksStatefulSet) IsExclusiveActivePassive() bool {
+	return q.Spec.ActivePassive != nil && q.Spec.ActivePassive.Exclusive
+}
+
+// GetZoneReplicas returns the replicas of the StatefulSet of the zone. The
+// total replicas are spread evenly, lower zone indexes get the remainder.
+func (q *QuarksStatefulSet) GetZoneReplicas(zoneIndex int) *int32 {
+	if q.Spec.Replicas == nil {
+		return q.Spec.Template.Spec.Replicas
+	}
+
+	zones := int32(len(q.Spec.Zones))
+	if zones == 0 {
+		zones = 1
+	}
+	replicas := *q.Spec.Replicas / zones
+	if int32(zoneIndex) < *q.Spec.Replicas%zones {
+		replicas++
+	}
+	return &replicas
+}
+
 // GetMaxAvailableVersion gets the greatest available version owned by the QuarksStatefulSet
 func (q *QuarksStatefulSet) GetMaxAvailableVersion(versions map[int]bool) int {
 	maxAvailableVersion := 0
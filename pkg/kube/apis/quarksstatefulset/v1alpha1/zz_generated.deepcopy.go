@@ -10,10 +10,63 @@ Don't alter this file, it was generated.
 package v1alpha1
 
 import (
-	v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActivePassive) DeepCopyInto(out *ActivePassive) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActivePassive.
+func (in *ActivePassive) DeepCopy() *ActivePassive {
+	if in == nil {
+		return nil
+	}
+	out := new(ActivePassive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActivePassiveProbe) DeepCopyInto(out *ActivePassiveProbe) {
+	*out = *in
+	in.Probe.DeepCopyInto(&out.Probe)
+	if in.GRPCHealthCheck != nil {
+		in, out := &in.GRPCHealthCheck, &out.GRPCHealthCheck
+		*out = new(GRPCAction)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActivePassiveProbe.
+func (in *ActivePassiveProbe) DeepCopy() *ActivePassiveProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(ActivePassiveProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GRPCAction) DeepCopyInto(out *GRPCAction) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GRPCAction.
+func (in *GRPCAction) DeepCopy() *GRPCAction {
+	if in == nil {
+		return nil
+	}
+	out := new(GRPCAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *QuarksStatefulSet) DeepCopyInto(out *QuarksStatefulSet) {
 	*out = *in
@@ -84,13 +137,23 @@ func (in *QuarksStatefulSetSpec) DeepCopyInto(out *QuarksStatefulSetSpec) {
 		copy(*out, *in)
 	}
 	in.Template.DeepCopyInto(&out.Template)
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
 	if in.ActivePassiveProbes != nil {
 		in, out := &in.ActivePassiveProbes, &out.ActivePassiveProbes
-		*out = make(map[string]v1.Probe, len(*in))
+		*out = make(map[string]ActivePassiveProbe, len(*in))
 		for key, val := range *in {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.ActivePassive != nil {
+		in, out := &in.ActivePassive, &out.ActivePassive
+		*out = new(ActivePassive)
+		**out = **in
+	}
 	return
 }
 
@@ -111,6 +174,22 @@ func (in *QuarksStatefulSetStatus) DeepCopyInto(out *QuarksStatefulSetStatus) {
 		in, out := &in.LastReconcile, &out.LastReconcile
 		*out = (*in).DeepCopy()
 	}
+	if in.FailedRollouts != nil {
+		in, out := &in.FailedRollouts, &out.FailedRollouts
+		*out = make([]RolloutFailure, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ActivePods != nil {
+		in, out := &in.ActivePods, &out.ActivePods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastActiveTransition != nil {
+		in, out := &in.LastActiveTransition, &out.LastActiveTransition
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -123,3 +202,20 @@ func (in *QuarksStatefulSetStatus) DeepCopy() *QuarksStatefulSetStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutFailure) DeepCopyInto(out *RolloutFailure) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutFailure.
+func (in *RolloutFailure) DeepCopy() *RolloutFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutFailure)
+	in.DeepCopyInto(out)
+	return out
+}
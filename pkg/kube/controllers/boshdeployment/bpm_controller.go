@@ -31,12 +31,14 @@ import (
 // AddBPM creates a new BPM controller to watch for BPM configs and instance
 // group manifests.  It will reconcile those into k8s resources
 // (QuarksStatefulSet, QuarksJob), which represent BOSH instance groups and
-// BOSH errands.
-func AddBPM(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+// BOSH errands. The vm_types of instance groups are looked up in the
+// vmTypesConfigMap in the operator namespace, if it's set.
+func AddBPM(ctx context.Context, config *config.Config, mgr manager.Manager, vmTypesConfigMap string) error {
 	ctx = ctxlog.NewContextWithRecorder(ctx, "bpm-reconciler", mgr.GetEventRecorderFor("bpm-recorder"))
+	resolver := desiredmanifest.NewDesiredManifest(mgr.GetClient())
 	r := NewBPMReconciler(
 		ctx, config, mgr,
-		desiredmanifest.NewDesiredManifest(mgr.GetClient()),
+		resolver,
 		controllerutil.SetControllerReference,
 		bpmconverter.NewConverter(
 			config.Namespace,
@@ -47,6 +49,7 @@ func AddBPM(ctx context.Context, config *config.Config, mgr manager.Manager) err
 		func(deploymentName string, m bdm.Manifest) (boshdns.DomainNameService, error) {
 			return boshdns.NewDNS(deploymentName, m)
 		},
+		vmTypesConfigMap,
 	)
 
 	// Create a new controller
@@ -91,6 +94,11 @@ func AddBPM(ctx context.Context, config *config.Config, mgr manager.Manager) err
 		return errors.Wrapf(err, "Watching secrets failed in BPM controller.")
 	}
 
+	err = watchVMTypes(ctx, config, mgr, c, resolver, vmTypesConfigMap)
+	if err != nil {
+		return errors.Wrapf(err, "Watching the vm types config map failed in BPM controller.")
+	}
+
 	return nil
 }
 
@@ -0,0 +1,134 @@
+package boshdeployment
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpmconverter"
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/prune"
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// pruneResources removes QuarksJobs, Services, PodDisruptionBudgets,
+// QuarksStatefulSets and HorizontalPodAutoscalers of the BOSHDeployment,
+// which are not desired anymore.
+// These belong to instance groups removed from the manifest, or to the
+// instance group of the BPM secret, but are not among its resources anymore,
+// e.g. the services of removed instances or zones.
+func (r *ReconcileBPM) pruneResources(ctx context.Context, bdpl *bdv1.BOSHDeployment, manifest *bdm.Manifest, instanceGroupName string, resources *bpmconverter.Resources) error {
+	instanceGroups := map[string]struct{}{}
+	for _, ig := range manifest.InstanceGroups {
+		instanceGroups[ig.Name] = struct{}{}
+	}
+
+	desired := map[string]struct{}{}
+	for _, qJob := range resources.Errands {
+		desired["QuarksJob/"+qJob.Name] = struct{}{}
+	}
+	for _, svc := range resources.Services {
+		desired["Service/"+svc.Name] = struct{}{}
+	}
+	for _, pdb := range resources.PodDisruptionBudgets {
+		desired["PodDisruptionBudget/"+pdb.Name] = struct{}{}
+	}
+	for _, qSts := range resources.InstanceGroups {
+		desired["QuarksStatefulSet/"+qSts.Name] = struct{}{}
+	}
+	for _, hpa := range resources.HorizontalPodAutoscalers {
+		desired["HorizontalPodAutoscaler/"+hpa.Name] = struct{}{}
+	}
+
+	// stale returns the objects controlled by the deployment, which are not desired
+	stale := func(kind string, objects []apis.Object) []apis.Object {
+		result := []apis.Object{}
+		for _, object := range objects {
+			if !metav1.IsControlledBy(object, bdpl) {
+				continue
+			}
+			igName, ok := object.GetLabels()[bdm.LabelInstanceGroupName]
+			if !ok || igName == "" {
+				continue
+			}
+			if _, found := instanceGroups[igName]; !found {
+				result = append(result, object)
+				continue
+			}
+			if _, found := desired[kind+"/"+object.GetName()]; igName == instanceGroupName && !found {
+				result = append(result, object)
+			}
+		}
+		return result
+	}
+
+	opts := []client.ListOption{
+		client.InNamespace(bdpl.Namespace),
+		client.MatchingLabels{bdm.LabelDeploymentName: bdpl.Name},
+	}
+
+	qJobs := &qjv1a1.QuarksJobList{}
+	if err := r.client.List(ctx, qJobs, opts...); err != nil {
+		return errors.Wrap(err, "could not list QuarksJobs")
+	}
+	services := &corev1.ServiceList{}
+	if err := r.client.List(ctx, services, opts...); err != nil {
+		return errors.Wrap(err, "could not list Services")
+	}
+	pdbs := &policyv1beta1.PodDisruptionBudgetList{}
+	if err := r.client.List(ctx, pdbs, opts...); err != nil {
+		return errors.Wrap(err, "could not list PodDisruptionBudgets")
+	}
+	qStatefulSets := &qstsv1a1.QuarksStatefulSetList{}
+	if err := r.client.List(ctx, qStatefulSets, opts...); err != nil {
+		return errors.Wrap(err, "could not list QuarksStatefulSets")
+	}
+	hpas := &autoscalingv2beta2.HorizontalPodAutoscalerList{}
+	if err := r.client.List(ctx, hpas, opts...); err != nil {
+		return errors.Wrap(err, "could not list HorizontalPodAutoscalers")
+	}
+
+	candidates := map[string][]apis.Object{}
+	for i := range qJobs.Items {
+		candidates["QuarksJob"] = append(candidates["QuarksJob"], &qJobs.Items[i])
+	}
+	for i := range services.Items {
+		candidates["Service"] = append(candidates["Service"], &services.Items[i])
+	}
+	for i := range pdbs.Items {
+		candidates["PodDisruptionBudget"] = append(candidates["PodDisruptionBudget"], &pdbs.Items[i])
+	}
+	for i := range qStatefulSets.Items {
+		candidates["QuarksStatefulSet"] = append(candidates["QuarksStatefulSet"], &qStatefulSets.Items[i])
+	}
+	for i := range hpas.Items {
+		candidates["HorizontalPodAutoscaler"] = append(candidates["HorizontalPodAutoscaler"], &hpas.Items[i])
+	}
+
+	pruned := []string{}
+	var err error
+	for _, kind := range []string{"QuarksJob", "Service", "PodDisruptionBudget", "QuarksStatefulSet", "HorizontalPodAutoscaler"} {
+		var objects []string
+		objects, err = prune.Objects(ctx, r.client, bdpl, bdpl.Spec.PrunePolicy, kind, stale(kind, candidates[kind]))
+		pruned = append(pruned, objects...)
+		if err != nil {
+			break
+		}
+	}
+
+	if len(pruned) > 0 {
+		log.WithEvent(bdpl, "Pruned").Infof(ctx, "%s resources no longer desired by BOSHDeployment '%s': %s",
+			prune.Verb(bdpl.Spec.PrunePolicy), bdpl.Name, strings.Join(pruned, ", "))
+	}
+	return err
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -45,18 +46,21 @@ type DesiredManifest interface {
 
 var _ reconcile.Reconciler = &ReconcileBOSHDeployment{}
 
-// NewBPMReconciler returns a new reconcile.Reconciler
-func NewBPMReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, resolver DesiredManifest, srf setReferenceFunc, converter BPMConverter, dns boshdns.NewDNSFunc) reconcile.Reconciler {
+// NewBPMReconciler returns a new reconcile.Reconciler. The vm_types of
+// instance groups are looked up in the vmTypesConfigMap, if it's set.
+func NewBPMReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, resolver DesiredManifest, srf setReferenceFunc, converter BPMConverter, dns boshdns.NewDNSFunc, vmTypesConfigMap string) reconcile.Reconciler {
 	return &ReconcileBPM{
 		ctx:                  ctx,
 		config:               config,
 		client:               mgr.GetClient(),
+		apiReader:            mgr.GetAPIReader(),
 		scheme:               mgr.GetScheme(),
 		resolver:             resolver,
 		setReference:         srf,
 		converter:            converter,
 		versionedSecretStore: versionedsecretstore.NewVersionedSecretStore(mgr.GetClient()),
 		newDNSFunc:           dns,
+		vmTypesConfigMap:     vmTypesConfigMap,
 	}
 }
 
@@ -65,12 +69,14 @@ type ReconcileBPM struct {
 	ctx                  context.Context
 	config               *config.Config
 	client               client.Client
+	apiReader            client.Reader
 	scheme               *runtime.Scheme
 	resolver             DesiredManifest
 	setReference         setReferenceFunc
 	converter            BPMConverter
 	versionedSecretStore versionedsecretstore.VersionedSecretStore
 	newDNSFunc           boshdns.NewDNSFunc
+	vmTypesConfigMap     string
 }
 
 // Reconcile reconciles an Instance Group BPM versioned secret read the corresponding
@@ -142,6 +148,10 @@ func (r *ReconcileBPM) Reconcile(request reconcile.Request) (reconcile.Result, e
 			log.WithEvent(bpmSecret, "GetBOSHDeployment").Errorf(ctx, "Failed to get BoshDeployment instance '%s': %v", instanceName, err)
 	}
 
+	// The BPM secret is the output of the instance group manifest QuarksJob,
+	// which runs once the desired manifest was rendered
+	r.updateCondition(ctx, bdpl, bdv1.DesiredManifestRendered, corev1.ConditionTrue, "Rendered", "desired manifest and instance group manifests rendered")
+
 	err = dns.Reconcile(ctx, request.Namespace, r.client, func(object metav1.Object) error {
 		return r.setReference(bdpl, object, r.scheme)
 	})
@@ -164,9 +174,16 @@ func (r *ReconcileBPM) Reconcile(request reconcile.Request) (reconcile.Result, e
 
 	// Deploy instance groups
 	err = r.deployInstanceGroups(ctx, bdpl, instanceGroupName, resources)
+	if err != nil {
+		err = log.WithEvent(bpmSecret, "InstanceGroupStartError").Errorf(ctx, "Failed to start: %v", err)
+		r.updateCondition(ctx, bdpl, bdv1.InstanceGroupsDeployed, corev1.ConditionFalse, "InstanceGroupStartError", err.Error())
+		return reconcile.Result{}, err
+	}
+
+	err = r.pruneResources(ctx, bdpl, manifest, instanceGroupName, resources)
 	if err != nil {
 		return reconcile.Result{},
-			log.WithEvent(bpmSecret, "InstanceGroupStartError").Errorf(ctx, "Failed to start: %v", err)
+			log.WithEvent(bpmSecret, "PruneError").Errorf(ctx, "Failed to prune resources of BOSHDeployment '%s': %v", bdpl.Name, err)
 	}
 
 	meltdown.SetLastReconcile(&bpmSecret.ObjectMeta, time.Now())
@@ -179,6 +196,18 @@ func (r *ReconcileBPM) Reconcile(request reconcile.Request) (reconcile.Result, e
 	return reconcile.Result{}, nil
 }
 
+// updateCondition sets a condition on the BOSHDeployment status. Failing to
+// update the status is logged, but does not fail the reconcile.
+func (r *ReconcileBPM) updateCondition(ctx context.Context, bdpl *bdv1.BOSHDeployment, conditionType bdv1.BOSHDeploymentConditionType, status corev1.ConditionStatus, reason, message string) {
+	err := updateStatus(ctx, r.client, types.NamespacedName{Namespace: bdpl.Namespace, Name: bdpl.Name}, func(s *bdv1.BOSHDeploymentStatus) {
+		s.SetCondition(conditionType, status, reason, message)
+		setReadyCondition(s)
+	})
+	if err != nil {
+		log.WithEvent(bdpl, "UpdateError").Errorf(ctx, "Failed to update condition '%s' on BOSHDeployment '%s': %v", conditionType, bdpl.Name, err)
+	}
+}
+
 func (r *ReconcileBPM) applyBPMResources(bdplName string, bpmSecret *corev1.Secret, manifest *bdm.Manifest, dns boshdns.DomainNameService) (*bpmconverter.Resources, error) {
 
 	instanceGroupName, ok := bpmSecret.Labels[qjv1a1.LabelRemoteID]
@@ -201,10 +230,15 @@ func (r *ReconcileBPM) applyBPMResources(bdplName string, bpmSecret *corev1.Secr
 		return nil, errors.Errorf("instance group '%s' not found", instanceGroupName)
 	}
 
+	err := r.resolveVMType(r.ctx, instanceGroup)
+	if err != nil {
+		return nil, err
+	}
+
 	// Fetch qSts version
 	quarksStatefulSet := &qstsv1a1.QuarksStatefulSet{}
 	quarksStatefulSetName := instanceGroup.QuarksStatefulSetName(bdplName)
-	err := r.client.Get(r.ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: quarksStatefulSetName}, quarksStatefulSet)
+	err = r.client.Get(r.ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: quarksStatefulSetName}, quarksStatefulSet)
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			return nil, errors.Errorf("Failed to get QuarksStatefulSet instance '%s': %v", quarksStatefulSetName, err)
@@ -247,7 +281,33 @@ func (r *ReconcileBPM) fetchIGresolvedVersion(manifestName, instanceGroupName st
 	return igResolvedSecret.GetLabels()[versionedsecretstore.LabelVersion], nil
 }
 
-// deployInstanceGroups create or update QuarksJobs and QuarksStatefulSets for instance groups
+// applyPodDisruptionBudget creates or updates the PodDisruptionBudget.
+// The spec of a PodDisruptionBudget is immutable before Kubernetes 1.15, so
+// the budget is deleted and recreated if the update is rejected as invalid.
+// Budgets of instance groups, which don't need one anymore, are removed by
+// pruneResources.
+func (r *ReconcileBPM) applyPodDisruptionBudget(ctx context.Context, pdb *policyv1beta1.PodDisruptionBudget) (controllerutil.OperationResult, error) {
+	desired := pdb.DeepCopy()
+	op, err := controllerutil.CreateOrUpdate(ctx, r.client, pdb, mutate.PodDisruptionBudgetMutateFn(pdb))
+	if err == nil || !apierrors.IsInvalid(err) {
+		return op, err
+	}
+
+	log.Debugf(ctx, "Recreating PodDisruptionBudget '%s', its update was rejected: %v", pdb.Name, err)
+	err = r.client.Delete(ctx, pdb)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return controllerutil.OperationResultNone, errors.Wrapf(err, "could not delete PodDisruptionBudget '%s' to change its spec", pdb.Name)
+	}
+
+	desired.DeepCopyInto(pdb)
+	err = r.client.Create(ctx, pdb)
+	if err != nil {
+		return controllerutil.OperationResultNone, errors.Wrapf(err, "could not recreate PodDisruptionBudget '%s'", pdb.Name)
+	}
+	return controllerutil.OperationResultUpdated, nil
+}
+
+// deployInstanceGroups create or update QuarksJobs, Services, PodDisruptionBudgets, QuarksStatefulSets and HorizontalPodAutoscalers for instance groups
 func (r *ReconcileBPM) deployInstanceGroups(ctx context.Context, bdpl *bdv1.BOSHDeployment, instanceGroupName string, resources *bpmconverter.Resources) error {
 	log.Debugf(ctx, "Creating quarksJobs and quarksStatefulSets for instance group '%s'", instanceGroupName)
 
@@ -287,12 +347,32 @@ func (r *ReconcileBPM) deployInstanceGroups(ctx context.Context, bdpl *bdv1.BOSH
 		log.Debugf(ctx, "Service '%s' has been %s", svc.Name, op)
 	}
 
+	for _, pdb := range resources.PodDisruptionBudgets {
+		if pdb.Labels[bdm.LabelInstanceGroupName] != instanceGroupName {
+			log.Debugf(ctx, "Skipping apply PodDisruptionBudget '%s' for instance group '%s' because of mismatching '%s' label", pdb.Name, bdpl.Name, bdm.LabelInstanceGroupName)
+			continue
+		}
+
+		if err := r.setReference(bdpl, &pdb, r.scheme); err != nil {
+			return log.WithEvent(bdpl, "PodDisruptionBudgetForDeploymentError").Errorf(ctx, "Failed to set reference for PodDisruptionBudget instance group '%s' : %v", instanceGroupName, err)
+		}
+
+		op, err := r.applyPodDisruptionBudget(ctx, &pdb)
+		if err != nil {
+			return log.WithEvent(bdpl, "ApplyPodDisruptionBudgetError").Errorf(ctx, "Failed to apply PodDisruptionBudget for instance group '%s' : %v", instanceGroupName, err)
+		}
+
+		log.Debugf(ctx, "PodDisruptionBudget '%s' has been %s", pdb.Name, op)
+	}
+
 	for _, qSts := range resources.InstanceGroups {
 		if qSts.Labels[bdm.LabelInstanceGroupName] != instanceGroupName {
 			log.Debugf(ctx, "Skipping apply QuarksStatefulSet '%s' for instance group '%s' because of mismatching '%s' label", qSts.Name, bdpl.Name, bdm.LabelInstanceGroupName)
 			continue
 		}
 
+		qSts.Spec.PrunePolicy = bdpl.Spec.PrunePolicy
+
 		if err := r.setReference(bdpl, &qSts, r.scheme); err != nil {
 			return log.WithEvent(bdpl, "QuarksStatefulSetForDeploymentError").Errorf(ctx, "Failed to set reference for QuarksStatefulSet instance group '%s' : %v", instanceGroupName, err)
 		}
@@ -305,5 +385,23 @@ func (r *ReconcileBPM) deployInstanceGroups(ctx context.Context, bdpl *bdv1.BOSH
 		log.Debugf(ctx, "QuarksStatefulSet '%s' has been %s", qSts.Name, op)
 	}
 
+	for _, hpa := range resources.HorizontalPodAutoscalers {
+		if hpa.Labels[bdm.LabelInstanceGroupName] != instanceGroupName {
+			log.Debugf(ctx, "Skipping apply HorizontalPodAutoscaler '%s' for instance group '%s' because of mismatching '%s' label", hpa.Name, bdpl.Name, bdm.LabelInstanceGroupName)
+			continue
+		}
+
+		if err := r.setReference(bdpl, &hpa, r.scheme); err != nil {
+			return log.WithEvent(bdpl, "HorizontalPodAutoscalerForDeploymentError").Errorf(ctx, "Failed to set reference for HorizontalPodAutoscaler instance group '%s' : %v", instanceGroupName, err)
+		}
+
+		op, err := controllerutil.CreateOrUpdate(ctx, r.client, &hpa, mutate.HorizontalPodAutoscalerMutateFn(&hpa))
+		if err != nil {
+			return log.WithEvent(bdpl, "ApplyHorizontalPodAutoscalerError").Errorf(ctx, "Failed to apply HorizontalPodAutoscaler for instance group '%s' : %v", instanceGroupName, err)
+		}
+
+		log.Debugf(ctx, "HorizontalPodAutoscaler '%s' has been %s", hpa.Name, op)
+	}
+
 	return nil
 }
@@ -11,11 +11,13 @@ import (
 	"go.uber.org/zap/zaptest/observer"
 
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
@@ -24,6 +26,7 @@ import (
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpmconverter"
 	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
 	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
 	cfd "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/boshdeployment"
@@ -53,6 +56,7 @@ var _ = Describe("ReconcileBPM", func() {
 		manifestWithVars          *corev1.Secret
 		bpmInformation            *corev1.Secret
 		bpmInformationNoProcesses *corev1.Secret
+		vmTypesConfigMap          string
 	)
 
 	BeforeEach(func() {
@@ -63,6 +67,7 @@ var _ = Describe("ReconcileBPM", func() {
 		manager.GetEventRecorderForReturns(recorder)
 		resolver = fakes.FakeDesiredManifest{}
 		kubeConverter = fakes.FakeBPMConverter{}
+		vmTypesConfigMap = ""
 
 		kubeConverter.ResourcesReturns(&bpmconverter.Resources{}, nil)
 		size := 1024
@@ -226,6 +231,8 @@ variables: []
 			return nil
 		})
 
+		client.StatusCalls(func() crc.StatusWriter { return &fakes.FakeStatusWriter{} })
+
 		manager.GetClientReturns(client)
 
 		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo.bpm.fakepod", Namespace: "default"}}
@@ -238,6 +245,7 @@ variables: []
 			func(name string, m bdm.Manifest) (boshdns.DomainNameService, error) {
 				return boshdns.NewSimpleDomainNameService("fake-manifest"), nil
 			},
+			vmTypesConfigMap,
 		)
 	})
 
@@ -335,5 +343,263 @@ variables: []
 				Expect(err).ToNot(HaveOccurred())
 			})
 		})
+
+		Context("when resources are not desired anymore", func() {
+			var (
+				bdpl     *bdv1.BOSHDeployment
+				services []corev1.Service
+			)
+
+			newService := func(name, instanceGroupName string, controlled bool) corev1.Service {
+				svc := corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+						Labels: map[string]string{
+							bdm.LabelDeploymentName:    "foo",
+							bdm.LabelInstanceGroupName: instanceGroupName,
+						},
+					},
+				}
+				if controlled {
+					Expect(controllerutil.SetControllerReference(bdpl, &svc, scheme.Scheme)).To(Succeed())
+				}
+				return svc
+			}
+
+			BeforeEach(func() {
+				bdpl = &bdv1.BOSHDeployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "foo-uid"},
+				}
+				services = []corev1.Service{
+					newService("foo-fakepod-0", "fakepod", true),
+					newService("foo-fakepod-1", "fakepod", true),
+					newService("foo-removed-0", "removed", true),
+					newService("foo-other-0", "other", false),
+				}
+
+				kubeConverter.ResourcesReturns(&bpmconverter.Resources{
+					Services: []corev1.Service{newService("foo-fakepod-0", "fakepod", false)},
+				}, nil)
+
+				get := client.GetStub
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					if object, ok := object.(*bdv1.BOSHDeployment); ok {
+						bdpl.DeepCopyInto(object)
+						return nil
+					}
+					return get(context, nn, object)
+				})
+				list := client.ListStub
+				client.ListCalls(func(context context.Context, object runtime.Object, opts ...crc.ListOption) error {
+					if object, ok := object.(*corev1.ServiceList); ok {
+						object.Items = services
+						return nil
+					}
+					return list(context, object, opts...)
+				})
+			})
+
+			It("deletes the resources of removed instance groups and instances", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(client.DeleteCallCount()).To(Equal(2))
+				deleted := []string{}
+				for i := 0; i < client.DeleteCallCount(); i++ {
+					_, object, _ := client.DeleteArgsForCall(i)
+					deleted = append(deleted, object.(*corev1.Service).Name)
+				}
+				Expect(deleted).To(ConsistOf("foo-fakepod-1", "foo-removed-0"))
+				Expect(recorder.Events).To(Receive(ContainSubstring("Deleted resources no longer desired by BOSHDeployment 'foo': Service/foo-fakepod-1, Service/foo-removed-0")))
+			})
+
+			It("orphans the resources with the orphan prune policy", func() {
+				bdpl.Spec.PrunePolicy = apis.PrunePolicyOrphan
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(client.DeleteCallCount()).To(Equal(0))
+				orphaned := []string{}
+				for i := 0; i < client.UpdateCallCount(); i++ {
+					_, object, _ := client.UpdateArgsForCall(i)
+					if svc, ok := object.(*corev1.Service); ok && len(svc.OwnerReferences) == 0 {
+						orphaned = append(orphaned, svc.Name)
+					}
+				}
+				Expect(orphaned).To(ConsistOf("foo-fakepod-1", "foo-removed-0"))
+			})
+		})
+
+		Context("when a pod disruption budget is applied", func() {
+			var existing *policyv1beta1.PodDisruptionBudget
+
+			newPDB := func(maxUnavailable int) policyv1beta1.PodDisruptionBudget {
+				budget := intstr.FromInt(maxUnavailable)
+				return policyv1beta1.PodDisruptionBudget{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo-fakepod",
+						Namespace: "default",
+						Labels: map[string]string{
+							bdm.LabelDeploymentName:    "foo",
+							bdm.LabelInstanceGroupName: "fakepod",
+						},
+					},
+					Spec: policyv1beta1.PodDisruptionBudgetSpec{MaxUnavailable: &budget},
+				}
+			}
+
+			BeforeEach(func() {
+				pdb := newPDB(1)
+				pdb.ResourceVersion = "1"
+				existing = &pdb
+
+				get := client.GetStub
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					if object, ok := object.(*policyv1beta1.PodDisruptionBudget); ok {
+						existing.DeepCopyInto(object)
+						return nil
+					}
+					return get(context, nn, object)
+				})
+			})
+
+			updatedPDBs := func() []*policyv1beta1.PodDisruptionBudget {
+				updated := []*policyv1beta1.PodDisruptionBudget{}
+				for i := 0; i < client.UpdateCallCount(); i++ {
+					_, object, _ := client.UpdateArgsForCall(i)
+					if pdb, ok := object.(*policyv1beta1.PodDisruptionBudget); ok {
+						updated = append(updated, pdb)
+					}
+				}
+				return updated
+			}
+
+			It("updates the budget in place if its spec changed", func() {
+				kubeConverter.ResourcesReturns(&bpmconverter.Resources{
+					PodDisruptionBudgets: []policyv1beta1.PodDisruptionBudget{newPDB(2)},
+				}, nil)
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(client.DeleteCallCount()).To(Equal(0))
+				updated := updatedPDBs()
+				Expect(updated).To(HaveLen(1))
+				Expect(updated[0].Spec.MaxUnavailable.IntValue()).To(Equal(2))
+			})
+
+			It("deletes and recreates the budget if the update is rejected as invalid", func() {
+				kubeConverter.ResourcesReturns(&bpmconverter.Resources{
+					PodDisruptionBudgets: []policyv1beta1.PodDisruptionBudget{newPDB(2)},
+				}, nil)
+				client.UpdateCalls(func(context context.Context, object runtime.Object, _ ...crc.UpdateOption) error {
+					if _, ok := object.(*policyv1beta1.PodDisruptionBudget); ok {
+						return apierrors.NewInvalid(schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"}, "foo-fakepod", nil)
+					}
+					return nil
+				})
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(client.DeleteCallCount()).To(Equal(1))
+				_, object, _ := client.DeleteArgsForCall(0)
+				Expect(object.(*policyv1beta1.PodDisruptionBudget).Name).To(Equal("foo-fakepod"))
+
+				created := []*policyv1beta1.PodDisruptionBudget{}
+				for i := 0; i < client.CreateCallCount(); i++ {
+					_, object, _ := client.CreateArgsForCall(i)
+					if pdb, ok := object.(*policyv1beta1.PodDisruptionBudget); ok {
+						created = append(created, pdb)
+					}
+				}
+				Expect(created).To(HaveLen(1))
+				Expect(created[0].Spec.MaxUnavailable.IntValue()).To(Equal(2))
+				Expect(created[0].ResourceVersion).To(BeEmpty())
+			})
+
+			It("fails if the update is rejected for other reasons", func() {
+				kubeConverter.ResourcesReturns(&bpmconverter.Resources{
+					PodDisruptionBudgets: []policyv1beta1.PodDisruptionBudget{newPDB(2)},
+				}, nil)
+				client.UpdateCalls(func(context context.Context, object runtime.Object, _ ...crc.UpdateOption) error {
+					if _, ok := object.(*policyv1beta1.PodDisruptionBudget); ok {
+						return apierrors.NewForbidden(schema.GroupResource{Group: "policy", Resource: "poddisruptionbudgets"}, "foo-fakepod", nil)
+					}
+					return nil
+				})
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).To(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a vm types config map is set", func() {
+			BeforeEach(func() {
+				vmTypesConfigMap = "vm-types"
+				config.OperatorNamespace = "cf-operator"
+				manifest.InstanceGroups[0].VMType = "small"
+
+				get := client.GetStub
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					switch object := object.(type) {
+					case *corev1.ConfigMap:
+						if nn.Namespace != "cf-operator" || nn.Name != "vm-types" {
+							return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+						}
+						object.Data = map[string]string{
+							"small": "cpu: 2\nram: 4096\nephemeral_disk_size: 10240\n",
+						}
+						return nil
+					}
+					return get(context, nn, object)
+				})
+				manager.GetAPIReaderReturns(client)
+			})
+
+			It("sets the vm_resources of the instance group from its vm_type", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(kubeConverter.ResourcesCallCount()).To(Equal(1))
+				_, _, _, instanceGroup, _, _, _ := kubeConverter.ResourcesArgsForCall(0)
+				Expect(instanceGroup.VMResources).To(Equal(&bdm.VMResource{CPU: 2, RAM: 4096, EphemeralDiskSize: 10240}))
+			})
+
+			It("keeps explicit vm_resources of the instance group", func() {
+				manifest.InstanceGroups[0].VMResources = &bdm.VMResource{CPU: 1}
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, _, instanceGroup, _, _, _ := kubeConverter.ResourcesArgsForCall(0)
+				Expect(instanceGroup.VMResources).To(Equal(&bdm.VMResource{CPU: 1}))
+			})
+
+			It("ignores unknown vm_types", func() {
+				manifest.InstanceGroups[0].VMType = "huge"
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, _, instanceGroup, _, _, _ := kubeConverter.ResourcesArgsForCall(0)
+				Expect(instanceGroup.VMResources).To(BeNil())
+			})
+
+			Context("when the config map is missing", func() {
+				BeforeEach(func() {
+					vmTypesConfigMap = "missing"
+				})
+
+				It("fails", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("failed to get vm types config map 'cf-operator/missing'"))
+				})
+			})
+		})
 	})
 })
@@ -71,7 +71,8 @@ func AddDeployment(ctx context.Context, config *config.Config, mgr manager.Manag
 		UpdateFunc: func(e event.UpdateEvent) bool {
 			o := e.ObjectOld.(*bdv1.BOSHDeployment)
 			n := e.ObjectNew.(*bdv1.BOSHDeployment)
-			if !reflect.DeepEqual(o.Spec, n.Spec) {
+			dryRunChanged := o.GetAnnotations()[bdv1.AnnotationDryRun] != n.GetAnnotations()[bdv1.AnnotationDryRun]
+			if !reflect.DeepEqual(o.Spec, n.Spec) || dryRunChanged {
 				ctxlog.NewPredicateEvent(e.ObjectNew).Debug(
 					ctx, e.MetaNew, "bdv1.BOSHDeployment",
 					fmt.Sprintf("Update predicate passed for '%s'", e.MetaNew.GetName()),
@@ -86,6 +87,17 @@ func AddDeployment(ctx context.Context, config *config.Config, mgr manager.Manag
 		return errors.Wrapf(err, "Watching bosh deployment failed in bosh deployment controller.")
 	}
 
+	// Watch for changed content of url references, which are polled periodically
+	urlEvents := make(chan event.GenericEvent)
+	err = mgr.Add(NewURLPoller(ctx, mgr.GetClient(), config.Namespace, urlPollTick, urlEvents))
+	if err != nil {
+		return errors.Wrapf(err, "Adding url poller failed in bosh deployment controller.")
+	}
+	err = c.Watch(&source.Channel{Source: urlEvents}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return errors.Wrapf(err, "Watching url references failed in bosh deployment controller.")
+	}
+
 	// Watch ConfigMaps referenced by the BOSHDeployment
 	configMapPredicates := predicate.Funcs{
 		CreateFunc:  func(e event.CreateEvent) bool { return true },
@@ -2,7 +2,9 @@ package boshdeployment
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -20,10 +23,13 @@ import (
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/converter"
 	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/plan"
 	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/desiredmanifest"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/mutate"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
@@ -44,7 +50,7 @@ type VariablesConverter interface {
 
 // WithOps interpolates BOSH manifests and operations files to create the WithOps manifest
 type WithOps interface {
-	Manifest(instance *bdv1.BOSHDeployment, namespace string) (*bdm.Manifest, []string, error)
+	ManifestWithURLContents(instance *bdv1.BOSHDeployment, namespace string, contents withops.URLContents) (*bdm.Manifest, []string, error)
 }
 
 // Check that ReconcileBOSHDeployment implements the reconcile.Reconciler interface
@@ -109,33 +115,38 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 	}
 
 	// Resolve the manifest with ops
-	manifest, err := r.resolveManifest(ctx, instance)
+	manifest, urlDigests, err := r.resolveManifest(ctx, instance)
 	if err != nil {
-		return reconcile.Result{},
-			log.WithEvent(instance, "WithOpsManifestError").Errorf(ctx, "failed to get with-ops manifest for BOSHDeployment '%s': %v", request.NamespacedName, err)
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.ManifestResolved, "WithOpsManifestError",
+			log.WithEvent(instance, "WithOpsManifestError").Errorf(ctx, "failed to get with-ops manifest for BOSHDeployment '%s': %v", request.NamespacedName, err))
+	}
+
+	// Only report the changes in dry-run mode, without applying anything
+	if instance.GetAnnotations()[bdv1.AnnotationDryRun] == "true" {
+		return reconcile.Result{}, r.planChanges(ctx, instance, manifest)
 	}
 
 	// Get link infos containing provider name and its secret name
 	linkInfos, err := r.listLinkInfos(instance, manifest)
 	if err != nil {
-		return reconcile.Result{},
-			log.WithEvent(instance, "InstanceGroupManifestError").Errorf(ctx, "failed to list quarks-link secrets for BOSHDeployment '%s': %v", request.NamespacedName, err)
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.ManifestResolved, "InstanceGroupManifestError",
+			log.WithEvent(instance, "InstanceGroupManifestError").Errorf(ctx, "failed to list quarks-link secrets for BOSHDeployment '%s': %v", request.NamespacedName, err))
 	}
 
 	// Apply the "with-ops" manifest secret
 	log.Debug(ctx, "Creating with-ops manifest secret")
 	manifestSecret, err := r.createManifestWithOps(ctx, instance, *manifest)
 	if err != nil {
-		return reconcile.Result{},
-			log.WithEvent(instance, "WithOpsManifestError").Errorf(ctx, "failed to create with-ops manifest secret for BOSHDeployment '%s': %v", request.NamespacedName, err)
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.ManifestResolved, "WithOpsManifestError",
+			log.WithEvent(instance, "WithOpsManifestError").Errorf(ctx, "failed to create with-ops manifest secret for BOSHDeployment '%s': %v", request.NamespacedName, err))
 	}
 
 	// Create all QuarksSecret variables
 	log.Debug(ctx, "Converting BOSH manifest variables to QuarksSecret resources")
 	secrets, err := r.converter.Variables(instance.Name, manifest.Variables)
 	if err != nil {
-		return reconcile.Result{},
-			log.WithEvent(instance, "BadManifestError").Error(ctx, errors.Wrap(err, "failed to generate quarks secrets from manifest"))
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.VariablesGenerated, "BadManifestError",
+			log.WithEvent(instance, "BadManifestError").Error(ctx, errors.Wrap(err, "failed to generate quarks secrets from manifest")))
 
 	}
 
@@ -143,61 +154,184 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 	if len(secrets) > 0 {
 		err = r.createQuarksSecrets(ctx, manifestSecret, secrets)
 		if err != nil {
-			return reconcile.Result{},
-				log.WithEvent(instance, "VariableGenerationError").Errorf(ctx, "failed to create quarks secrets for BOSH manifest '%s': %v", instance.Name, err)
+			return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.VariablesGenerated, "VariableGenerationError",
+				log.WithEvent(instance, "VariableGenerationError").Errorf(ctx, "failed to create quarks secrets for BOSH manifest '%s': %v", instance.Name, err))
 		}
 	}
 
 	// Apply the "Variable Interpolation" QuarksJob, which creates the desired manifest secret
 	qJob, err := r.jobFactory.VariableInterpolationJob(instance.Name, *manifest)
 	if err != nil {
-		return reconcile.Result{}, log.WithEvent(instance, "DesiredManifestError").Errorf(ctx, "failed to build the desired manifest qJob: %v", err)
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.DesiredManifestRendered, "DesiredManifestError",
+			log.WithEvent(instance, "DesiredManifestError").Errorf(ctx, "failed to build the desired manifest qJob: %v", err))
 	}
 
 	log.Debug(ctx, "Creating desired manifest QuarksJob")
-	err = r.createQuarksJob(ctx, instance, qJob)
+	dmOp, err := r.createQuarksJob(ctx, instance, qJob)
 	if err != nil {
-		return reconcile.Result{},
-			log.WithEvent(instance, "DesiredManifestError").Errorf(ctx, "failed to create desired manifest qJob for BOSHDeployment '%s': %v", request.NamespacedName, err)
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.DesiredManifestRendered, "DesiredManifestError",
+			log.WithEvent(instance, "DesiredManifestError").Errorf(ctx, "failed to create desired manifest qJob for BOSHDeployment '%s': %v", request.NamespacedName, err))
 	}
 
 	// Apply the "Instance group manifest" QuarksJob, which creates instance group manifests (ig-resolved) secrets and BPM config secrets
 	// once the "Variable Interpolation" job created the desired manifest.
 	qJob, err = r.jobFactory.InstanceGroupManifestJob(instance.Name, *manifest, linkInfos, instance.ObjectMeta.Generation == 1)
 	if err != nil {
-		return reconcile.Result{},
-			log.WithEvent(instance, "InstanceGroupManifestError").Errorf(ctx, "failed to build instance group manifest qJob: %v", err)
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.DesiredManifestRendered, "InstanceGroupManifestError",
+			log.WithEvent(instance, "InstanceGroupManifestError").Errorf(ctx, "failed to build instance group manifest qJob: %v", err))
 	}
 
 	log.Debug(ctx, "Creating instance group manifest QuarksJob")
-	err = r.createQuarksJob(ctx, instance, qJob)
+	igOp, err := r.createQuarksJob(ctx, instance, qJob)
 	if err != nil {
-		return reconcile.Result{},
-			log.WithEvent(instance, "InstanceGroupManifestError").Errorf(ctx, "failed to create instance group manifest qJob for BOSHDeployment '%s': %v", request.NamespacedName, err)
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.DesiredManifestRendered, "InstanceGroupManifestError",
+			log.WithEvent(instance, "InstanceGroupManifestError").Errorf(ctx, "failed to create instance group manifest qJob for BOSHDeployment '%s': %v", request.NamespacedName, err))
 	}
 
-	// Update status of bdpl with the timestamp of the last reconcile
-	now := metav1.Now()
-	instance.Status.LastReconcile = &now
+	err = r.recordURLDigests(ctx, instance, urlDigests)
+	if err != nil {
+		return reconcile.Result{}, r.setFailedCondition(ctx, instance, bdv1.ManifestResolved, "URLReferenceError",
+			log.WithEvent(instance, "URLReferenceError").Errorf(ctx, "failed to record url digests for BOSHDeployment '%s': %v", request.NamespacedName, err))
+	}
+
+	// The remaining conditions are set once the QuarksJobs created their
+	// output and the instance groups got deployed. The QuarksJobs only change
+	// if their input changed, so the conditions of a previous generation are
+	// reset, when a new generation is rendering.
+	rendering := dmOp != controllerutil.OperationResultNone || igOp != controllerutil.OperationResultNone
+
+	// Only this reconciler's conditions are written to the latest status, the
+	// status and BPM controllers update the instance groups concurrently
+	err = updateStatus(ctx, r.client, request.NamespacedName, func(status *bdv1.BOSHDeploymentStatus) {
+		status.SetCondition(bdv1.ManifestResolved, corev1.ConditionTrue, "Resolved", "manifest and ops files resolved")
+		status.SetCondition(bdv1.VariablesGenerated, corev1.ConditionTrue, "Applied", fmt.Sprintf("%d QuarksSecrets applied", len(secrets)))
+		for _, t := range []bdv1.BOSHDeploymentConditionType{bdv1.DesiredManifestRendered, bdv1.InstanceGroupsDeployed} {
+			if c := status.GetCondition(t); c == nil || c.Status == corev1.ConditionFalse || rendering {
+				status.SetCondition(t, corev1.ConditionUnknown, "Pending", "waiting for QuarksJob output")
+			}
+		}
+		setReadyCondition(status)
+
+		status.PlannedChanges = nil
+		status.GitReferences = instance.Status.GitReferences
+		status.ObservedGeneration = instance.Generation
 
-	err = r.client.Status().Update(ctx, instance)
+		// Update status of bdpl with the timestamp of the last reconcile
+		now := metav1.Now()
+		status.LastReconcile = &now
+	})
 	if err != nil {
-		log.WithEvent(instance, "UpdateError").Errorf(ctx, "failed to update reconcile timestamp on bdpl '%s' (%v): %s", instance.Name, instance.ResourceVersion, err)
-		return reconcile.Result{Requeue: false}, nil
+		return reconcile.Result{},
+			log.WithEvent(instance, "UpdateError").Errorf(ctx, "failed to update status of bdpl '%s': %v", instance.Name, err)
 	}
 
 	return reconcile.Result{}, nil
 }
 
-// resolveManifest resolves manifest with ops manifest
-func (r *ReconcileBOSHDeployment) resolveManifest(ctx context.Context, instance *bdv1.BOSHDeployment) (*bdm.Manifest, error) {
+// setFailedCondition records a failed deployment stage in the status of the
+// latest BOSHDeployment and returns the original error
+func (r *ReconcileBOSHDeployment) setFailedCondition(ctx context.Context, instance *bdv1.BOSHDeployment, conditionType bdv1.BOSHDeploymentConditionType, reason string, err error) error {
+	updateErr := updateStatus(ctx, r.client, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, func(status *bdv1.BOSHDeploymentStatus) {
+		status.SetCondition(conditionType, corev1.ConditionFalse, reason, err.Error())
+		setReadyCondition(status)
+	})
+	if updateErr != nil {
+		log.WithEvent(instance, "UpdateError").Errorf(ctx, "failed to update conditions on bdpl '%s' (%v): %s", instance.Name, instance.ResourceVersion, updateErr)
+	}
+
+	return err
+}
+
+// planChanges compares the resources generated for the manifest with the
+// ones of the desired manifest and stores the differences in the status
+func (r *ReconcileBOSHDeployment) planChanges(ctx context.Context, instance *bdv1.BOSHDeployment, manifest *bdm.Manifest) error {
+	// Nothing is deployed yet, if the desired manifest doesn't exist. Any
+	// other error would plan to add every resource, so it fails the dry-run.
+	deployed, err := desiredmanifest.NewDesiredManifest(r.client).DesiredManifest(ctx, instance.Name, instance.Namespace)
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		log.Debugf(ctx, "No desired manifest found for BOSHDeployment '%s': %v", instance.Name, err)
+		deployed = nil
+	} else if err != nil {
+		return log.WithEvent(instance, "DryRunError").Errorf(ctx, "failed to read the desired manifest of BOSHDeployment '%s': %v", instance.Name, err)
+	}
+
+	changes, err := plan.Plan(instance.Namespace, instance.Name, deployed, manifest)
+	if err != nil {
+		return log.WithEvent(instance, "DryRunError").Errorf(ctx, "failed to plan changes for BOSHDeployment '%s': %v", instance.Name, err)
+	}
+
+	log.WithEvent(instance, "DryRun").Infof(ctx, "Dry-run of BOSHDeployment '%s' planned %d changes", instance.Name, len(changes))
+
+	err = updateStatus(ctx, r.client, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, func(status *bdv1.BOSHDeploymentStatus) {
+		status.PlannedChanges = changes
+		status.GitReferences = instance.Status.GitReferences
+	})
+	if err != nil {
+		return log.WithEvent(instance, "UpdateError").Errorf(ctx, "failed to update planned changes on bdpl '%s': %v", instance.Name, err)
+	}
+
+	return nil
+}
+
+// resolveManifest resolves manifest with ops manifest. It returns the
+// digests of the polled urls used for the manifest, too.
+func (r *ReconcileBOSHDeployment) resolveManifest(ctx context.Context, instance *bdv1.BOSHDeployment) (*bdm.Manifest, map[string]string, error) {
 	log.Debug(ctx, "Resolving manifest")
-	manifest, _, err := r.withops.Manifest(instance, instance.GetNamespace())
+
+	// Resolve git refs first, so the status reflects the commits used
+	pinned, gitReferences, err := withops.PinGitReferences(ctx, r.client, instance)
+	if err != nil {
+		return nil, nil, log.WithEvent(instance, "GitReferenceError").Errorf(ctx, "Error resolving git references of %s: %s", instance.GetName(), err)
+	}
+
+	// Pin the content of polled urls, so the url poller compares with the
+	// content used. The fetched content is resolved, without fetching again.
+	pinned, urlContents, err := withops.PinURLReferences(ctx, r.client, pinned)
 	if err != nil {
-		return nil, log.WithEvent(instance, "WithOpsManifestError").Errorf(ctx, "Error resolving the manifest %s: %s", instance.GetName(), err)
+		return nil, nil, log.WithEvent(instance, "URLReferenceError").Errorf(ctx, "Error resolving url references of %s: %s", instance.GetName(), err)
 	}
 
-	return manifest, nil
+	manifest, _, err := r.withops.ManifestWithURLContents(pinned, instance.GetNamespace(), urlContents)
+	if err != nil {
+		return nil, nil, log.WithEvent(instance, "WithOpsManifestError").Errorf(ctx, "Error resolving the manifest %s: %s", instance.GetName(), err)
+	}
+	instance.Status.GitReferences = gitReferences
+
+	return manifest, urlContents.Digests(), nil
+}
+
+// recordURLDigests stores the digests of the polled urls used for the render
+// in an annotation, the url poller compares their content with them. The
+// annotation is patched, the status is updated separately.
+func (r *ReconcileBOSHDeployment) recordURLDigests(ctx context.Context, instance *bdv1.BOSHDeployment, digests map[string]string) error {
+	if reflect.DeepEqual(withops.RenderedURLDigests(instance), digests) {
+		return nil
+	}
+
+	value, err := json.Marshal(digests)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal url digests")
+	}
+
+	annotated := instance.DeepCopy()
+	annotations := annotated.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if len(digests) == 0 {
+		delete(annotations, bdv1.AnnotationURLDigests)
+	} else {
+		annotations[bdv1.AnnotationURLDigests] = string(value)
+	}
+	annotated.SetAnnotations(annotations)
+
+	err = r.client.Patch(ctx, annotated, client.MergeFrom(instance))
+	if err != nil {
+		return errors.Wrapf(err, "could not record url digests on bdpl '%s'", instance.Name)
+	}
+	instance.SetAnnotations(annotated.GetAnnotations())
+
+	return nil
 }
 
 // createManifestWithOps creates a secret containing the deployment manifest with ops files applied
@@ -244,19 +378,19 @@ func (r *ReconcileBOSHDeployment) createManifestWithOps(ctx context.Context, ins
 }
 
 // createQuarksJob creates a QuarksJob and sets its ownership
-func (r *ReconcileBOSHDeployment) createQuarksJob(ctx context.Context, instance *bdv1.BOSHDeployment, qJob *qjv1a1.QuarksJob) error {
+func (r *ReconcileBOSHDeployment) createQuarksJob(ctx context.Context, instance *bdv1.BOSHDeployment, qJob *qjv1a1.QuarksJob) (controllerutil.OperationResult, error) {
 	if err := r.setReference(instance, qJob, r.scheme); err != nil {
-		return errors.Errorf("failed to set ownerReference for QuarksJob '%s': %v", qJob.GetName(), err)
+		return controllerutil.OperationResultNone, errors.Errorf("failed to set ownerReference for QuarksJob '%s': %v", qJob.GetName(), err)
 	}
 
 	op, err := controllerutil.CreateOrUpdate(ctx, r.client, qJob, mutate.QuarksJobMutateFn(qJob))
 	if err != nil {
-		return errors.Wrapf(err, "creating or updating QuarksJob '%s'", qJob.Name)
+		return op, errors.Wrapf(err, "creating or updating QuarksJob '%s'", qJob.Name)
 	}
 
 	log.Debugf(ctx, "QuarksJob '%s' has been %s", qJob.Name, op)
 
-	return err
+	return op, nil
 }
 
 // listLinkInfos returns a LinkInfos containing link providers if needed
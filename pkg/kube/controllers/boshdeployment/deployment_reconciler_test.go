@@ -3,11 +3,13 @@ package boshdeployment_test
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
@@ -30,6 +32,7 @@ import (
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
 	cfd "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/boshdeployment"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	cfwithops "code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
 	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
 	cfcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
@@ -174,7 +177,7 @@ var _ = Describe("ReconcileBoshDeployment", func() {
 	})
 
 	JustBeforeEach(func() {
-		withops.ManifestReturns(manifest, []string{}, nil)
+		withops.ManifestWithURLContentsReturns(manifest, []string{}, nil)
 		reconciler = cfd.NewDeploymentReconciler(
 			ctx, config, manager,
 			&withops, &jobFactory, &kubeConverter,
@@ -205,7 +208,7 @@ var _ = Describe("ReconcileBoshDeployment", func() {
 			})
 
 			It("handles an error when resolving the BOSHDeployment", func() {
-				withops.ManifestReturns(nil, []string{}, fmt.Errorf("resolver error"))
+				withops.ManifestWithURLContentsReturns(nil, []string{}, fmt.Errorf("resolver error"))
 
 				_, err := reconciler.Reconcile(request)
 				Expect(err).To(HaveOccurred())
@@ -214,12 +217,90 @@ var _ = Describe("ReconcileBoshDeployment", func() {
 				// check for events
 				Expect(<-recorder.Events).To(ContainSubstring("WithOpsManifestError"))
 			})
+
+			It("sets the ManifestResolved and Ready conditions to false", func() {
+				statusWriter := &fakes.FakeStatusWriter{}
+				client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+				withops.ManifestWithURLContentsReturns(nil, []string{}, fmt.Errorf("resolver error"))
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).To(HaveOccurred())
+				Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+
+				_, object, _ := statusWriter.UpdateArgsForCall(0)
+				status := object.(*bdv1.BOSHDeployment).Status
+				Expect(status.GetCondition(bdv1.ManifestResolved).Status).To(Equal(corev1.ConditionFalse))
+				Expect(status.GetCondition(bdv1.ManifestResolved).Reason).To(Equal("WithOpsManifestError"))
+				Expect(status.GetCondition(bdv1.Ready).Status).To(Equal(corev1.ConditionFalse))
+				Expect(status.GetCondition(bdv1.Ready).Message).To(ContainSubstring("resolver error"))
+			})
 		})
 
 		Context("when the manifest can be resolved", func() {
+			Context("when the rendered conditions of a previous generation are set", func() {
+				var statusWriter *fakes.FakeStatusWriter
+
+				updatedStatus := func() bdv1.BOSHDeploymentStatus {
+					Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+					_, object, _ := statusWriter.UpdateArgsForCall(0)
+					return object.(*bdv1.BOSHDeployment).Status
+				}
+
+				BeforeEach(func() {
+					instance.Generation = 2
+					instance.Status.ObservedGeneration = 1
+					instance.Status.SetCondition(bdv1.DesiredManifestRendered, corev1.ConditionTrue, "Rendered", "")
+					instance.Status.SetCondition(bdv1.InstanceGroupsDeployed, corev1.ConditionTrue, "Deployed", "")
+					statusWriter = &fakes.FakeStatusWriter{}
+					client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+				})
+
+				It("resets them while the changed QuarksJobs render the new generation", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					status := updatedStatus()
+					Expect(status.ObservedGeneration).To(Equal(int64(2)))
+					Expect(status.GetCondition(bdv1.DesiredManifestRendered).Status).To(Equal(corev1.ConditionUnknown))
+					Expect(status.GetCondition(bdv1.InstanceGroupsDeployed).Status).To(Equal(corev1.ConditionUnknown))
+					Expect(status.GetCondition(bdv1.Ready).Status).To(Equal(corev1.ConditionFalse))
+				})
+
+				It("keeps them if the QuarksJobs did not change", func() {
+					client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+						switch object := object.(type) {
+						case *bdv1.BOSHDeployment:
+							instance.DeepCopyInto(object)
+						case *qjv1a1.QuarksJob:
+							if nn.Name == dmQJob.Name {
+								dmQJob.DeepCopyInto(object)
+							} else {
+								igQJob.DeepCopyInto(object)
+							}
+						}
+						return nil
+					})
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					status := updatedStatus()
+					Expect(status.ObservedGeneration).To(Equal(int64(2)))
+					Expect(status.GetCondition(bdv1.DesiredManifestRendered).Status).To(Equal(corev1.ConditionTrue))
+				})
+
+				It("returns an error to requeue the request if the status can't be updated", func() {
+					statusWriter.UpdateReturns(errors.New("fake-error"))
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("failed to update status of bdpl 'foo': fake-error"))
+				})
+			})
+
 			It("handles an error when resolving manifest", func() {
 				manifest = &bdm.Manifest{}
-				withops.ManifestReturns(manifest, []string{}, errors.New("fake-error"))
+				withops.ManifestWithURLContentsReturns(manifest, []string{}, errors.New("fake-error"))
 
 				_, err := reconciler.Reconcile(request)
 				Expect(err).To(HaveOccurred())
@@ -370,6 +451,54 @@ var _ = Describe("ReconcileBoshDeployment", func() {
 				Expect(err.Error()).To(ContainSubstring("failed to create instance group manifest qJob for BOSHDeployment 'default/foo': creating or updating QuarksJob 'ig-foo': fake-error"))
 			})
 
+			Context("when the manifest is a polled url", func() {
+				var server *ghttp.Server
+
+				BeforeEach(func() {
+					server = ghttp.NewServer()
+					server.RouteToHandler("GET", "/manifest.yml", ghttp.RespondWith(http.StatusOK, "instance_groups: []"))
+					instance.Spec.Manifest = bdv1.ResourceReference{
+						Type:         bdv1.URLReference,
+						Name:         server.URL() + "/manifest.yml",
+						PollInterval: &metav1.Duration{Duration: time.Minute},
+					}
+					instance.Spec.Ops = nil
+				})
+
+				AfterEach(func() {
+					server.Close()
+				})
+
+				It("renders the fetched content and records its digest", func() {
+					digest := cfwithops.Digest([]byte("instance_groups: []"))
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					pinned, _, contents := withops.ManifestWithURLContentsArgsForCall(0)
+					Expect(pinned.Spec.Manifest.SHA256).To(Equal(digest))
+					Expect(contents).To(HaveKeyWithValue(instance.Spec.Manifest.Name, []byte("instance_groups: []")))
+					Expect(server.ReceivedRequests()).To(HaveLen(1))
+
+					Expect(client.PatchCallCount()).To(Equal(1))
+					_, object, _, _ := client.PatchArgsForCall(0)
+					Expect(object.(*bdv1.BOSHDeployment).GetAnnotations()).To(HaveKeyWithValue(
+						bdv1.AnnotationURLDigests, fmt.Sprintf(`{"%s":"%s"}`, instance.Spec.Manifest.Name, digest),
+					))
+				})
+
+				It("doesn't record the digest again", func() {
+					digest := cfwithops.Digest([]byte("instance_groups: []"))
+					instance.SetAnnotations(map[string]string{
+						bdv1.AnnotationURLDigests: fmt.Sprintf(`{"%s":"%s"}`, instance.Spec.Manifest.Name, digest),
+					})
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(client.PatchCallCount()).To(Equal(0))
+				})
+			})
+
 			Context("when the manifest contains variables", func() {
 				BeforeEach(func() {
 					kubeConverter.VariablesReturns([]qsv1a1.QuarksSecret{
@@ -398,6 +527,54 @@ var _ = Describe("ReconcileBoshDeployment", func() {
 				})
 			})
 
+			Context("when the dry-run annotation is set", func() {
+				var secretErr error
+
+				BeforeEach(func() {
+					instance.Annotations = map[string]string{bdv1.AnnotationDryRun: "true"}
+					secretErr = apierrors.NewNotFound(schema.GroupResource{}, "desired-manifest-v0")
+					client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+						switch object := object.(type) {
+						case *bdv1.BOSHDeployment:
+							instance.DeepCopyInto(object)
+						case *corev1.Secret:
+							return secretErr
+						}
+						return nil
+					})
+				})
+
+				It("stores the planned changes in the status without applying them", func() {
+					statusWriter := &fakes.FakeStatusWriter{}
+					client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+
+					result, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result).To(Equal(reconcile.Result{}))
+					Expect(client.CreateCallCount()).To(Equal(0))
+					Expect(client.UpdateCallCount()).To(Equal(0))
+					Expect(<-recorder.Events).To(ContainSubstring("DryRun"))
+
+					Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+					_, object, _ := statusWriter.UpdateArgsForCall(0)
+					changes := object.(*bdv1.BOSHDeployment).Status.PlannedChanges
+					Expect(changes).To(ContainElement(bdv1.PlannedChange{Kind: "InstanceGroup", Name: "fakepod", Action: "added"}))
+					Expect(changes).To(ContainElement(bdv1.PlannedChange{Kind: "QuarksSecret", Name: "foo.var-foo-password", Action: "added"}))
+				})
+
+				It("fails without planning changes, if the desired manifest can't be read", func() {
+					secretErr = apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "desired-manifest-v0", errors.New("fake-error"))
+					statusWriter := &fakes.FakeStatusWriter{}
+					client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("failed to read the desired manifest of BOSHDeployment 'foo'"))
+					Expect(<-recorder.Events).To(ContainSubstring("DryRunError"))
+					Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+				})
+			})
+
 			Context("when the manifest contains explicit links", func() {
 				var bazSecret *corev1.Secret
 
@@ -0,0 +1,95 @@
+package boshdeployment
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/desiredmanifest"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// AddBOSHDNS creates a new bosh-dns controller. BOSH DNS aliases resolve to
+// other BOSHDeployments of the namespace only while these exist, so the
+// bosh-dns of the other deployments is regenerated, whenever a BOSHDeployment
+// is created or deleted.
+func AddBOSHDNS(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	ctx = ctxlog.NewContextWithRecorder(ctx, "bosh-dns-reconciler", mgr.GetEventRecorderFor("bosh-dns-recorder"))
+	r := NewDNSReconciler(
+		ctx, config, mgr,
+		desiredmanifest.NewDesiredManifest(mgr.GetClient()),
+		controllerutil.SetControllerReference,
+		func(deploymentName string, m bdm.Manifest) (boshdns.DomainNameService, error) {
+			return boshdns.NewDNS(deploymentName, m)
+		},
+	)
+
+	c, err := controller.New("bosh-dns-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: config.MaxBoshDeploymentWorkers,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Adding bosh-dns controller to manager failed.")
+	}
+
+	p := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return true },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return true },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return false },
+	}
+	err = c.Watch(&source.Kind{Type: &bdv1.BOSHDeployment{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			reconciles, err := otherDeployments(ctx, mgr.GetClient(), a.Meta.GetNamespace(), a.Meta.GetName())
+			if err != nil {
+				ctxlog.Errorf(ctx, "Failed to calculate bosh-dns reconciles for BOSHDeployment '%s': %v", a.Meta.GetName(), err)
+			}
+
+			for _, reconciliation := range reconciles {
+				ctxlog.NewMappingEvent(a.Object).Debug(ctx, reconciliation, "BOSHDeployment", a.Meta.GetName(), "BOSHDeployment")
+			}
+
+			return reconciles
+		}),
+	}, p)
+	if err != nil {
+		return errors.Wrapf(err, "Watching BOSHDeployments failed in bosh-dns controller.")
+	}
+
+	return nil
+}
+
+// otherDeployments returns a reconcile request for each BOSHDeployment in the
+// namespace, except for the given one
+func otherDeployments(ctx context.Context, c client.Client, namespace string, except string) ([]reconcile.Request, error) {
+	deployments := &bdv1.BOSHDeploymentList{}
+	err := c.List(ctx, deployments, client.InNamespace(namespace))
+	if err != nil {
+		return []reconcile.Request{}, errors.Wrapf(err, "failed to list BOSHDeployments in namespace '%s'", namespace)
+	}
+
+	reconciles := []reconcile.Request{}
+	for _, deployment := range deployments.Items {
+		if deployment.Name == except {
+			continue
+		}
+		reconciles = append(reconciles, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name},
+		})
+	}
+	return reconciles, nil
+}
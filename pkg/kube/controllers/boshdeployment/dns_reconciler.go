@@ -0,0 +1,89 @@
+package boshdeployment
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+var _ reconcile.Reconciler = &ReconcileBOSHDNS{}
+
+// NewDNSReconciler returns a new reconcile.Reconciler for the bosh-dns of a
+// BOSHDeployment
+func NewDNSReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, resolver DesiredManifest, srf setReferenceFunc, dns boshdns.NewDNSFunc) reconcile.Reconciler {
+	return &ReconcileBOSHDNS{
+		ctx:          ctx,
+		config:       config,
+		client:       mgr.GetClient(),
+		scheme:       mgr.GetScheme(),
+		resolver:     resolver,
+		setReference: srf,
+		newDNSFunc:   dns,
+	}
+}
+
+// ReconcileBOSHDNS regenerates the bosh-dns config map, which contains the
+// aliases, of a BOSHDeployment
+type ReconcileBOSHDNS struct {
+	ctx          context.Context
+	config       *config.Config
+	client       client.Client
+	scheme       *runtime.Scheme
+	resolver     DesiredManifest
+	setReference setReferenceFunc
+	newDNSFunc   boshdns.NewDNSFunc
+}
+
+// Reconcile regenerates the bosh-dns of the BOSHDeployment from its desired
+// manifest. The instance groups are left alone, they are deployed by the BPM
+// reconciler.
+func (r *ReconcileBOSHDNS) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
+	defer cancel()
+
+	log.Debugf(ctx, "Reconciling bosh-dns of BOSHDeployment '%s'", request.NamespacedName)
+	bdpl := &bdv1.BOSHDeployment{}
+	err := r.client.Get(ctx, request.NamespacedName, bdpl)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Debug(ctx, "Skip bosh-dns reconcile: BOSHDeployment not found")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get BOSHDeployment '%s'", request.NamespacedName)
+	}
+
+	// The BPM reconciler creates the bosh-dns, once the desired manifest is
+	// rendered
+	manifest, err := r.resolver.DesiredManifest(ctx, bdpl.Name, bdpl.Namespace)
+	if err != nil {
+		log.Debugf(ctx, "Skip bosh-dns reconcile: desired manifest for BOSHDeployment '%s' not available: %v", request.NamespacedName, err)
+		return reconcile.Result{}, nil
+	}
+
+	dns, err := r.newDNSFunc(bdpl.Name, *manifest)
+	if err != nil {
+		return reconcile.Result{},
+			log.WithEvent(bdpl, "DesiredManifestReadError").Errorf(ctx, "Failed to load BOSH DNS for manifest '%s': %v", request.NamespacedName, err)
+	}
+
+	err = dns.Reconcile(ctx, bdpl.Namespace, r.client, func(object metav1.Object) error {
+		return r.setReference(bdpl, object, r.scheme)
+	})
+	if err != nil {
+		return reconcile.Result{},
+			log.WithEvent(bdpl, "DnsReconcileError").Errorf(ctx, "Failed to reconcile dns: %v", err)
+	}
+
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,146 @@
+package boshdeployment_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	cfd "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/boshdeployment"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
+	cfcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+// reconcilingDNS records the reconciles of the bosh-dns and sets the owner
+// of a config map like the bosh-dns does
+type reconcilingDNS struct {
+	boshdns.DomainNameService
+	err        error
+	namespaces []string
+	configMap  *corev1.ConfigMap
+}
+
+func (d *reconcilingDNS) Reconcile(ctx context.Context, namespace string, c crc.Client, setOwner func(object metav1.Object) error) error {
+	d.namespaces = append(d.namespaces, namespace)
+	d.configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo-bosh-dns", Namespace: namespace}}
+	if err := setOwner(d.configMap); err != nil {
+		return err
+	}
+	return d.err
+}
+
+var _ = Describe("ReconcileBOSHDNS", func() {
+	var (
+		manager    *fakes.FakeManager
+		reconciler reconcile.Reconciler
+		request    reconcile.Request
+		ctx        context.Context
+		resolver   fakes.FakeDesiredManifest
+		config     *cfcfg.Config
+		client     *fakes.FakeClient
+		instance   *bdv1.BOSHDeployment
+		dns        *reconcilingDNS
+		dnsNames   []string
+	)
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		manager = &fakes.FakeManager{}
+		manager.GetSchemeReturns(scheme.Scheme)
+		resolver = fakes.FakeDesiredManifest{}
+		resolver.DesiredManifestReturns(&bdm.Manifest{}, nil)
+		config = &cfcfg.Config{CtxTimeOut: 10 * time.Second}
+		_, log := helper.NewTestLogger()
+		ctx = ctxlog.NewParentContext(log)
+
+		instance = &bdv1.BOSHDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "foo-uid"},
+		}
+		client = &fakes.FakeClient{}
+		client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+			switch object := object.(type) {
+			case *bdv1.BOSHDeployment:
+				instance.DeepCopyInto(object)
+			}
+			return nil
+		})
+		manager.GetClientReturns(client)
+
+		dns = &reconcilingDNS{DomainNameService: boshdns.NewSimpleDomainNameService("foo")}
+		dnsNames = []string{}
+		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+	})
+
+	JustBeforeEach(func() {
+		reconciler = cfd.NewDNSReconciler(ctx, config, manager, &resolver,
+			controllerutil.SetControllerReference,
+			func(name string, m bdm.Manifest) (boshdns.DomainNameService, error) {
+				dnsNames = append(dnsNames, name)
+				return dns, nil
+			},
+		)
+	})
+
+	It("regenerates the bosh-dns of the BOSHDeployment", func() {
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(dnsNames).To(Equal([]string{"foo"}))
+		Expect(dns.namespaces).To(Equal([]string{"default"}))
+		Expect(metav1.IsControlledBy(dns.configMap, instance)).To(BeTrue())
+
+		_, name, namespace := resolver.DesiredManifestArgsForCall(0)
+		Expect(name).To(Equal("foo"))
+		Expect(namespace).To(Equal("default"))
+	})
+
+	It("leaves the instance groups alone", func() {
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.CreateCallCount()).To(Equal(0))
+		Expect(client.UpdateCallCount()).To(Equal(0))
+	})
+
+	It("skips BOSHDeployments, which don't exist anymore", func() {
+		client.GetReturns(apierrors.NewNotFound(schema.GroupResource{}, "foo"))
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dns.namespaces).To(BeEmpty())
+	})
+
+	It("skips BOSHDeployments, whose desired manifest isn't rendered yet", func() {
+		resolver.DesiredManifestReturns(nil, errors.New("not found"))
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dns.namespaces).To(BeEmpty())
+	})
+
+	It("fails if the bosh-dns can't be reconciled", func() {
+		dns.err = errors.New("fake-error")
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("failed to reconcile dns: fake-error"))
+	})
+})
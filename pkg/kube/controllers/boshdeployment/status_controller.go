@@ -0,0 +1,149 @@
+package boshdeployment
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/qjobs"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/statefulset"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/desiredmanifest"
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// AddDeploymentStatus creates a new BOSHDeployment status controller, which
+// watches the StatefulSets of all instance groups and reflects their
+// readiness in the BOSHDeployment status. It also reports failed jobs of
+// the QuarksJobs, which render the manifests.
+func AddDeploymentStatus(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	ctx = ctxlog.NewContextWithRecorder(ctx, "boshdeployment-status-reconciler", mgr.GetEventRecorderFor("boshdeployment-status-recorder"))
+	r := NewStatusReconciler(ctx, config, mgr, desiredmanifest.NewDesiredManifest(mgr.GetClient()))
+
+	c, err := controller.New("boshdeployment-status-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: config.MaxBoshDeploymentWorkers,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Adding BOSHDeployment status controller to manager failed.")
+	}
+
+	// Only StatefulSets, which belong to a BOSHDeployment are relevant
+	p := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isDeploymentStatefulSet(e.Meta.GetLabels())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return isDeploymentStatefulSet(e.Meta.GetLabels())
+		},
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if !isDeploymentStatefulSet(e.MetaNew.GetLabels()) {
+				return false
+			}
+
+			o := e.ObjectOld.(*appsv1.StatefulSet)
+			n := e.ObjectNew.(*appsv1.StatefulSet)
+			if o.Status.ReadyReplicas == n.Status.ReadyReplicas &&
+				o.Status.Replicas == n.Status.Replicas &&
+				reflect.DeepEqual(o.Spec.Replicas, n.Spec.Replicas) &&
+				o.Annotations[statefulset.AnnotationRolloutFailure] == n.Annotations[statefulset.AnnotationRolloutFailure] &&
+				o.Annotations[statefulset.AnnotationRolledBackRevision] == n.Annotations[statefulset.AnnotationRolledBackRevision] {
+				return false
+			}
+
+			ctxlog.NewPredicateEvent(e.ObjectNew).Debug(
+				ctx, e.MetaNew, "StatefulSet",
+				fmt.Sprintf("Update predicate passed for '%s'", e.MetaNew.GetName()),
+			)
+			return true
+		},
+	}
+
+	err = c.Watch(&source.Kind{Type: &appsv1.StatefulSet{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: a.Meta.GetNamespace(),
+					Name:      a.Meta.GetLabels()[bdm.LabelDeploymentName],
+				},
+			}
+			ctxlog.NewMappingEvent(a.Object).Debug(ctx, request, "BOSHDeployment", a.Meta.GetName(), "StatefulSet")
+
+			return []reconcile.Request{request}
+		}),
+	}, p)
+	if err != nil {
+		return errors.Wrapf(err, "Watching StatefulSets failed in BOSHDeployment status controller.")
+	}
+
+	// Failed jobs of the QuarksJobs, which render the desired manifest and the instance group manifests
+	jobPredicate := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			n := e.ObjectNew.(*batchv1.Job)
+			if _, ok := n.Labels[qjv1a1.LabelQJobName]; !ok {
+				return false
+			}
+			for _, c := range n.Status.Conditions {
+				if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			qJob := &qjv1a1.QuarksJob{}
+			key := types.NamespacedName{Namespace: a.Meta.GetNamespace(), Name: a.Meta.GetLabels()[qjv1a1.LabelQJobName]}
+			if err := mgr.GetClient().Get(ctx, key, qJob); err != nil {
+				return []reconcile.Request{}
+			}
+
+			deploymentName, ok := qJob.Labels[bdv1.LabelDeploymentName]
+			if !ok || (qJob.Name != qjobs.VariableInterpolationJobName(deploymentName) && qJob.Name != qjobs.InstanceGroupManifestJobName(deploymentName)) {
+				return []reconcile.Request{}
+			}
+
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: a.Meta.GetNamespace(),
+					Name:      deploymentName,
+				},
+			}
+			ctxlog.NewMappingEvent(a.Object).Debug(ctx, request, "BOSHDeployment", a.Meta.GetName(), "Job")
+
+			return []reconcile.Request{request}
+		}),
+	}, jobPredicate)
+	if err != nil {
+		return errors.Wrapf(err, "Watching Jobs failed in BOSHDeployment status controller.")
+	}
+
+	return nil
+}
+
+func isDeploymentStatefulSet(labels map[string]string) bool {
+	_, ok := labels[bdm.LabelDeploymentName]
+	return ok
+}
@@ -0,0 +1,317 @@
+package boshdeployment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/qjobs"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/statefulset"
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+var _ reconcile.Reconciler = &ReconcileBOSHDeploymentStatus{}
+
+// NewStatusReconciler returns a new reconcile.Reconciler for the BOSHDeployment status
+func NewStatusReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, resolver DesiredManifest) reconcile.Reconciler {
+	return &ReconcileBOSHDeploymentStatus{
+		ctx:      ctx,
+		config:   config,
+		client:   mgr.GetClient(),
+		resolver: resolver,
+	}
+}
+
+// ReconcileBOSHDeploymentStatus aggregates the state of the instance group
+// StatefulSets into the BOSHDeployment status
+type ReconcileBOSHDeploymentStatus struct {
+	ctx      context.Context
+	config   *config.Config
+	client   client.Client
+	resolver DesiredManifest
+}
+
+// Reconcile compares the StatefulSets of a BOSHDeployment with the instance
+// groups of its desired manifest and updates replica counts and conditions
+func (r *ReconcileBOSHDeploymentStatus) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
+	defer cancel()
+
+	log.Debugf(ctx, "Reconciling status of BOSHDeployment '%s'", request.NamespacedName)
+	bdpl := &bdv1.BOSHDeployment{}
+	err := r.client.Get(ctx, request.NamespacedName, bdpl)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Debug(ctx, "Skip status reconcile: BOSHDeployment not found")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get BOSHDeployment '%s'", request.NamespacedName)
+	}
+
+	failure, err := r.renderFailure(ctx, bdpl)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to list QuarksJob jobs of BOSHDeployment '%s'", request.NamespacedName)
+	}
+	if failure != "" {
+		err = updateStatus(ctx, r.client, request.NamespacedName, func(status *bdv1.BOSHDeploymentStatus) {
+			status.SetCondition(bdv1.DesiredManifestRendered, corev1.ConditionFalse, "QuarksJobFailed", failure)
+			setReadyCondition(status)
+		})
+		if err != nil {
+			return reconcile.Result{}, log.WithEvent(bdpl, "UpdateError").Errorf(ctx, "failed to update status of BOSHDeployment '%s': %v", request.NamespacedName, err)
+		}
+		return reconcile.Result{}, nil
+	}
+
+	manifest, err := r.resolver.DesiredManifest(ctx, bdpl.Name, bdpl.Namespace)
+	if err != nil {
+		log.Debugf(ctx, "Skip status reconcile: desired manifest for BOSHDeployment '%s' not available: %v", request.NamespacedName, err)
+		return reconcile.Result{}, nil
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	err = r.client.List(ctx, statefulSets,
+		client.InNamespace(bdpl.Namespace),
+		client.MatchingLabels{bdm.LabelDeploymentName: bdpl.Name},
+	)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to list StatefulSets of BOSHDeployment '%s'", request.NamespacedName)
+	}
+
+	qStatefulSets, err := r.quarksStatefulSets(ctx, bdpl, manifest)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get QuarksStatefulSets of BOSHDeployment '%s'", request.NamespacedName)
+	}
+
+	igStatuses, missing := instanceGroupStatuses(manifest, qStatefulSets, statefulSets.Items)
+	notDeployed := map[string]struct{}{}
+	for _, name := range missing {
+		notDeployed[name] = struct{}{}
+	}
+
+	err = updateStatus(ctx, r.client, request.NamespacedName, func(status *bdv1.BOSHDeploymentStatus) {
+		status.InstanceGroups = igStatuses
+		status.TotalInstanceGroups = int32(len(igStatuses))
+		status.ReadyInstanceGroups = 0
+		for _, ig := range igStatuses {
+			if _, ok := notDeployed[ig.Name]; ok {
+				continue
+			}
+			if ig.ReadyReplicas >= ig.Replicas {
+				status.ReadyInstanceGroups++
+			}
+		}
+
+		if len(missing) > 0 {
+			status.SetCondition(bdv1.InstanceGroupsDeployed, corev1.ConditionFalse, "Deploying",
+				fmt.Sprintf("waiting for instance groups: %s", strings.Join(missing, ", ")))
+		} else {
+			status.SetCondition(bdv1.InstanceGroupsDeployed, corev1.ConditionTrue, "Deployed",
+				fmt.Sprintf("%d instance groups deployed", len(igStatuses)))
+		}
+		setReadyCondition(status)
+	})
+	if err != nil {
+		return reconcile.Result{}, log.WithEvent(bdpl, "UpdateError").Errorf(ctx, "failed to update status of BOSHDeployment '%s': %v", request.NamespacedName, err)
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// quarksStatefulSets returns the existing QuarksStatefulSets of the
+// deployment's instance groups by instance group name
+func (r *ReconcileBOSHDeploymentStatus) quarksStatefulSets(ctx context.Context, bdpl *bdv1.BOSHDeployment, manifest *bdm.Manifest) (map[string]*qstsv1a1.QuarksStatefulSet, error) {
+	qStatefulSets := map[string]*qstsv1a1.QuarksStatefulSet{}
+	for _, ig := range manifest.InstanceGroups {
+		qSts := &qstsv1a1.QuarksStatefulSet{}
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: bdpl.Namespace, Name: ig.QuarksStatefulSetName(bdpl.Name)}, qSts)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		qStatefulSets[ig.Name] = qSts
+	}
+	return qStatefulSets, nil
+}
+
+// renderFailure returns the failure of the latest job of the desired
+// manifest and instance group manifest QuarksJobs, if one of them failed
+func (r *ReconcileBOSHDeploymentStatus) renderFailure(ctx context.Context, bdpl *bdv1.BOSHDeployment) (string, error) {
+	for _, qJobName := range []string{qjobs.VariableInterpolationJobName(bdpl.Name), qjobs.InstanceGroupManifestJobName(bdpl.Name)} {
+		jobs := &batchv1.JobList{}
+		err := r.client.List(ctx, jobs,
+			client.InNamespace(bdpl.Namespace),
+			client.MatchingLabels{qjv1a1.LabelQJobName: qJobName},
+		)
+		if err != nil {
+			return "", err
+		}
+
+		var latest *batchv1.Job
+		for i, job := range jobs.Items {
+			if latest == nil || latest.CreationTimestamp.Before(&job.CreationTimestamp) {
+				latest = &jobs.Items[i]
+			}
+		}
+		if latest == nil {
+			continue
+		}
+
+		for _, c := range latest.Status.Conditions {
+			if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+				return fmt.Sprintf("job '%s' of QuarksJob '%s' failed: %s", latest.Name, qJobName, c.Message), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// instanceGroupStatuses sums up the replicas of the current StatefulSets per
+// instance group. It also returns the names of the instance groups, for which
+// no StatefulSet exists yet. Errands are not included.
+func instanceGroupStatuses(manifest *bdm.Manifest, qStatefulSets map[string]*qstsv1a1.QuarksStatefulSet, statefulSets []appsv1.StatefulSet) ([]bdv1.InstanceGroupStatus, []string) {
+	statuses := []bdv1.InstanceGroupStatus{}
+	missing := []string{}
+
+	for _, ig := range manifest.InstanceGroups {
+		if ig.LifeCycle != bdm.IGTypeService && ig.LifeCycle != "" {
+			continue
+		}
+
+		status := bdv1.InstanceGroupStatus{Name: ig.Name}
+		current := currentStatefulSets(qStatefulSets[ig.Name], statefulSets)
+		for _, sts := range current {
+			if sts.Spec.Replicas != nil {
+				status.Replicas += *sts.Spec.Replicas
+			}
+			status.ReadyReplicas += sts.Status.ReadyReplicas
+			if failure := rolloutFailure(sts); failure != "" {
+				status.RolloutFailure = failure
+			}
+		}
+
+		if len(current) == 0 {
+			missing = append(missing, ig.Name)
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, missing
+}
+
+// currentStatefulSets returns the StatefulSets of the latest version, which
+// are controlled by the QuarksStatefulSet. Older versions and orphaned
+// StatefulSets of the instance group are not part of the deployment anymore.
+func currentStatefulSets(qSts *qstsv1a1.QuarksStatefulSet, statefulSets []appsv1.StatefulSet) []appsv1.StatefulSet {
+	if qSts == nil {
+		return nil
+	}
+
+	current := []appsv1.StatefulSet{}
+	maxVersion := -1
+	for _, sts := range statefulSets {
+		if !metav1.IsControlledBy(&sts, qSts) {
+			continue
+		}
+
+		version, err := strconv.Atoi(sts.Annotations[qstsv1a1.AnnotationVersion])
+		if err != nil {
+			continue
+		}
+		if version > maxVersion {
+			current = current[:0]
+			maxVersion = version
+		}
+		if version == maxVersion {
+			current = append(current, sts)
+		}
+	}
+	return current
+}
+
+// rolloutFailure returns the reason of a failed rollout of the StatefulSet
+func rolloutFailure(sts appsv1.StatefulSet) string {
+	reason, ok := sts.Annotations[statefulset.AnnotationRolloutFailure]
+	if !ok {
+		return ""
+	}
+	if revision, ok := sts.Annotations[statefulset.AnnotationRolledBackRevision]; ok {
+		return fmt.Sprintf("%s, revision '%s' of StatefulSet '%s' was rolled back", reason, revision, sts.Name)
+	}
+	return fmt.Sprintf("%s, StatefulSet '%s'", reason, sts.Name)
+}
+
+// setReadyCondition derives the Ready condition from the stage conditions
+// and the instance group replica counts
+func setReadyCondition(status *bdv1.BOSHDeploymentStatus) {
+	for _, t := range []bdv1.BOSHDeploymentConditionType{
+		bdv1.ManifestResolved,
+		bdv1.VariablesGenerated,
+		bdv1.DesiredManifestRendered,
+		bdv1.InstanceGroupsDeployed,
+	} {
+		c := status.GetCondition(t)
+		if c == nil || c.Status != corev1.ConditionTrue {
+			reason := "Pending"
+			message := fmt.Sprintf("waiting for %s", t)
+			if c != nil && c.Status == corev1.ConditionFalse {
+				reason = c.Reason
+				message = c.Message
+			}
+			status.SetCondition(bdv1.Ready, corev1.ConditionFalse, reason, message)
+			return
+		}
+	}
+
+	for _, ig := range status.InstanceGroups {
+		if ig.RolloutFailure != "" {
+			status.SetCondition(bdv1.Ready, corev1.ConditionFalse, "RolloutFailed",
+				fmt.Sprintf("rollout of instance group '%s' failed: %s", ig.Name, ig.RolloutFailure))
+			return
+		}
+	}
+
+	if status.ReadyInstanceGroups < status.TotalInstanceGroups {
+		status.SetCondition(bdv1.Ready, corev1.ConditionFalse, "InstanceGroupsNotReady",
+			fmt.Sprintf("%d/%d instance groups ready", status.ReadyInstanceGroups, status.TotalInstanceGroups))
+		return
+	}
+
+	status.SetCondition(bdv1.Ready, corev1.ConditionTrue, "Ready", "all instance groups are ready")
+}
+
+// updateStatus fetches the latest BOSHDeployment and applies the given
+// mutation to its status. The update is retried on conflicts, since several
+// controllers write the BOSHDeployment status.
+func updateStatus(ctx context.Context, c client.Client, nn types.NamespacedName, mutate func(*bdv1.BOSHDeploymentStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		bdpl := &bdv1.BOSHDeployment{}
+		if err := c.Get(ctx, nn, bdpl); err != nil {
+			return err
+		}
+
+		mutate(&bdpl.Status)
+
+		return c.Status().Update(ctx, bdpl)
+	})
+}
@@ -0,0 +1,327 @@
+package boshdeployment_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	cfd "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/boshdeployment"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/statefulset"
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	cfcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("ReconcileBOSHDeploymentStatus", func() {
+	var (
+		manager      *fakes.FakeManager
+		reconciler   reconcile.Reconciler
+		request      reconcile.Request
+		ctx          context.Context
+		resolver     fakes.FakeDesiredManifest
+		manifest     *bdm.Manifest
+		config       *cfcfg.Config
+		client       *fakes.FakeClient
+		statusWriter *fakes.FakeStatusWriter
+		instance     *bdv1.BOSHDeployment
+		statefulSets []appsv1.StatefulSet
+		jobs         []batchv1.Job
+	)
+
+	job := func(name, qJobName string, created int64, failed bool) batchv1.Job {
+		j := batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.Unix(created, 0),
+				Labels:            map[string]string{qjv1a1.LabelQJobName: qJobName},
+			},
+		}
+		if failed {
+			j.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "Job has reached the specified backoff limit"},
+			}
+		}
+		return j
+	}
+
+	qStatefulSet := func(ig string) *qstsv1a1.QuarksStatefulSet {
+		return &qstsv1a1.QuarksStatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo-" + ig,
+				Namespace: "default",
+				UID:       types.UID(ig + "-uid"),
+			},
+		}
+	}
+
+	statefulSet := func(name, ig string, replicas, ready int32) appsv1.StatefulSet {
+		return appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					bdm.LabelDeploymentName:    "foo",
+					bdm.LabelInstanceGroupName: ig,
+				},
+				Annotations: map[string]string{
+					qstsv1a1.AnnotationVersion: "1",
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{Name: "foo-" + ig, UID: types.UID(ig + "-uid"), Controller: pointers.Bool(true)},
+				},
+			},
+			Spec:   appsv1.StatefulSetSpec{Replicas: pointers.Int32(replicas)},
+			Status: appsv1.StatefulSetStatus{ReadyReplicas: ready},
+		}
+	}
+
+	updatedStatus := func() bdv1.BOSHDeploymentStatus {
+		Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+		_, object, _ := statusWriter.UpdateArgsForCall(0)
+		return object.(*bdv1.BOSHDeployment).Status
+	}
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		manager = &fakes.FakeManager{}
+		manager.GetSchemeReturns(scheme.Scheme)
+		resolver = fakes.FakeDesiredManifest{}
+		config = &cfcfg.Config{CtxTimeOut: 10 * time.Second}
+		_, log := helper.NewTestLogger()
+		ctx = ctxlog.NewParentContext(log)
+
+		manifest = &bdm.Manifest{
+			InstanceGroups: []*bdm.InstanceGroup{
+				{Name: "api", Instances: 2, AZs: []string{"z1", "z2"}},
+				{Name: "database", Instances: 1},
+				{Name: "smoke-tests", Instances: 1, LifeCycle: bdm.IGTypeErrand},
+			},
+		}
+
+		instance = &bdv1.BOSHDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Status: bdv1.BOSHDeploymentStatus{
+				Conditions: []bdv1.BOSHDeploymentCondition{
+					{Type: bdv1.ManifestResolved, Status: corev1.ConditionTrue},
+					{Type: bdv1.VariablesGenerated, Status: corev1.ConditionTrue},
+					{Type: bdv1.DesiredManifestRendered, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+
+		statefulSets = []appsv1.StatefulSet{
+			statefulSet("foo-api-z0", "api", 2, 2),
+			statefulSet("foo-api-z1", "api", 2, 1),
+			statefulSet("foo-database", "database", 1, 1),
+		}
+
+		client = &fakes.FakeClient{}
+		client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+			switch object := object.(type) {
+			case *bdv1.BOSHDeployment:
+				instance.DeepCopyInto(object)
+			case *qstsv1a1.QuarksStatefulSet:
+				for _, ig := range []string{"api", "database"} {
+					if nn.Name == "foo-"+ig {
+						qStatefulSet(ig).DeepCopyInto(object)
+						return nil
+					}
+				}
+				return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+			}
+			return nil
+		})
+		jobs = []batchv1.Job{}
+		client.ListCalls(func(context context.Context, object runtime.Object, opts ...crc.ListOption) error {
+			switch object := object.(type) {
+			case *appsv1.StatefulSetList:
+				list := appsv1.StatefulSetList{Items: statefulSets}
+				list.DeepCopyInto(object)
+			case *batchv1.JobList:
+				listOpts := &crc.ListOptions{}
+				listOpts.ApplyOptions(opts)
+				list := batchv1.JobList{}
+				for _, j := range jobs {
+					if listOpts.LabelSelector.Matches(labels.Set(j.Labels)) {
+						list.Items = append(list.Items, j)
+					}
+				}
+				list.DeepCopyInto(object)
+			}
+			return nil
+		})
+		statusWriter = &fakes.FakeStatusWriter{}
+		client.StatusCalls(func() crc.StatusWriter { return statusWriter })
+		manager.GetClientReturns(client)
+
+		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+	})
+
+	JustBeforeEach(func() {
+		resolver.DesiredManifestReturns(manifest, nil)
+		reconciler = cfd.NewStatusReconciler(ctx, config, manager, &resolver)
+	})
+
+	It("skips the reconcile if the BOSHDeployment was deleted", func() {
+		client.GetReturns(apierrors.NewNotFound(schema.GroupResource{}, "foo"))
+		client.GetCalls(nil)
+
+		result, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(reconcile.Result{}))
+		Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+	})
+
+	It("skips the reconcile if the desired manifest was not rendered yet", func() {
+		resolver.DesiredManifestReturns(nil, errors.New("not found"))
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+	})
+
+	It("sums up the replicas of all zones per instance group", func() {
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.InstanceGroups).To(Equal([]bdv1.InstanceGroupStatus{
+			{Name: "api", Replicas: 4, ReadyReplicas: 3},
+			{Name: "database", Replicas: 1, ReadyReplicas: 1},
+		}))
+		Expect(status.TotalInstanceGroups).To(Equal(int32(2)))
+		Expect(status.ReadyInstanceGroups).To(Equal(int32(1)))
+		Expect(status.GetCondition(bdv1.InstanceGroupsDeployed).Status).To(Equal(corev1.ConditionTrue))
+		Expect(status.GetCondition(bdv1.Ready).Status).To(Equal(corev1.ConditionFalse))
+		Expect(status.GetCondition(bdv1.Ready).Reason).To(Equal("InstanceGroupsNotReady"))
+	})
+
+	It("only counts the latest StatefulSets of the QuarksStatefulSet", func() {
+		outdated := statefulSet("foo-database-v1", "database", 1, 0)
+		statefulSets[2].Annotations[qstsv1a1.AnnotationVersion] = "2"
+		orphaned := statefulSet("foo-api-z2", "api", 3, 0)
+		orphaned.OwnerReferences = nil
+		statefulSets = append(statefulSets, outdated, orphaned)
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.InstanceGroups).To(Equal([]bdv1.InstanceGroupStatus{
+			{Name: "api", Replicas: 4, ReadyReplicas: 3},
+			{Name: "database", Replicas: 1, ReadyReplicas: 1},
+		}))
+		Expect(status.ReadyInstanceGroups).To(Equal(int32(1)))
+	})
+
+	It("is ready when all replicas are ready", func() {
+		statefulSets[1].Status.ReadyReplicas = 2
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.ReadyInstanceGroups).To(Equal(int32(2)))
+		Expect(status.GetCondition(bdv1.Ready).Status).To(Equal(corev1.ConditionTrue))
+	})
+
+	It("waits for instance groups without StatefulSets", func() {
+		statefulSets = statefulSets[:2]
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.GetCondition(bdv1.InstanceGroupsDeployed).Status).To(Equal(corev1.ConditionFalse))
+		Expect(status.GetCondition(bdv1.InstanceGroupsDeployed).Message).To(ContainSubstring("database"))
+		Expect(status.GetCondition(bdv1.Ready).Reason).To(Equal("Deploying"))
+	})
+
+	It("does not count instance groups without StatefulSets as ready", func() {
+		statefulSets[1].Status.ReadyReplicas = 2
+		statefulSets = statefulSets[:2]
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.TotalInstanceGroups).To(Equal(int32(2)))
+		Expect(status.ReadyInstanceGroups).To(Equal(int32(1)))
+	})
+
+	It("sets the DesiredManifestRendered condition to false if a rendering job failed", func() {
+		jobs = append(jobs, job("ig-foo-abc", "ig-foo", 10, true))
+		resolver.DesiredManifestReturns(nil, errors.New("not found"))
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.GetCondition(bdv1.DesiredManifestRendered).Status).To(Equal(corev1.ConditionFalse))
+		Expect(status.GetCondition(bdv1.DesiredManifestRendered).Reason).To(Equal("QuarksJobFailed"))
+		Expect(status.GetCondition(bdv1.Ready).Status).To(Equal(corev1.ConditionFalse))
+		Expect(status.GetCondition(bdv1.Ready).Message).To(ContainSubstring("backoff limit"))
+	})
+
+	It("ignores failed jobs, which were superseded by a newer job", func() {
+		jobs = append(jobs, job("dm-foo-abc", "dm-foo", 10, true), job("dm-foo-def", "dm-foo", 20, false))
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.GetCondition(bdv1.DesiredManifestRendered).Status).To(Equal(corev1.ConditionTrue))
+	})
+
+	It("reports failed rollouts of instance groups", func() {
+		statefulSets[1].Status.ReadyReplicas = 2
+		statefulSets[1].Annotations[statefulset.AnnotationRolloutFailure] = statefulset.ReasonCanaryWatchTimeExceeded
+		statefulSets[1].Annotations[statefulset.AnnotationRolledBackRevision] = "foo-api-z1-2"
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.InstanceGroups[0].RolloutFailure).To(Equal("CanaryWatchTimeExceeded, revision 'foo-api-z1-2' of StatefulSet 'foo-api-z1' was rolled back"))
+		Expect(status.InstanceGroups[1].RolloutFailure).To(BeEmpty())
+		Expect(status.GetCondition(bdv1.Ready).Status).To(Equal(corev1.ConditionFalse))
+		Expect(status.GetCondition(bdv1.Ready).Reason).To(Equal("RolloutFailed"))
+		Expect(status.GetCondition(bdv1.Ready).Message).To(ContainSubstring("instance group 'api'"))
+	})
+
+	It("reports earlier failed stages as ready reason", func() {
+		instance.Status.Conditions[1].Status = corev1.ConditionFalse
+		instance.Status.Conditions[1].Reason = "VariableGenerationError"
+
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		status := updatedStatus()
+		Expect(status.GetCondition(bdv1.Ready).Status).To(Equal(corev1.ConditionFalse))
+		Expect(status.GetCondition(bdv1.Ready).Reason).To(Equal("VariableGenerationError"))
+	})
+})
@@ -0,0 +1,114 @@
+package boshdeployment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
+	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// urlPollTick is the resolution of url poll intervals
+const urlPollTick = 10 * time.Second
+
+// URLPoller periodically fetches the url references of all BOSHDeployments,
+// which have a poll interval. It sends an event for a BOSHDeployment, if the
+// content of one of its urls differs from the content used for its last
+// render.
+type URLPoller struct {
+	ctx       context.Context
+	client    client.Client
+	namespace string
+	tick      time.Duration
+	events    chan<- event.GenericEvent
+	polled    map[string]time.Time
+}
+
+// NewURLPoller returns a new URLPoller, which checks every tick which
+// url references are due for polling
+func NewURLPoller(ctx context.Context, client client.Client, namespace string, tick time.Duration, events chan<- event.GenericEvent) *URLPoller {
+	return &URLPoller{
+		ctx:       ctx,
+		client:    client,
+		namespace: namespace,
+		tick:      tick,
+		events:    events,
+		polled:    map[string]time.Time{},
+	}
+}
+
+// Start implements manager.Runnable
+func (p *URLPoller) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(p.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			p.Poll(stop)
+		}
+	}
+}
+
+// Poll fetches all url references, whose poll interval elapsed, and compares
+// their digest with the one recorded on the BOSHDeployment by its last
+// render.
+func (p *URLPoller) Poll(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	bdpls := &bdv1.BOSHDeploymentList{}
+	err := p.client.List(ctx, bdpls, client.InNamespace(p.namespace))
+	if err != nil {
+		log.Errorf(ctx, "Failed to list BOSHDeployments for url polling: %v", err)
+		return
+	}
+
+	polled := map[string]time.Time{}
+	for i := range bdpls.Items {
+		bdpl := &bdpls.Items[i]
+		rendered := withops.RenderedURLDigests(bdpl)
+
+		changed := false
+		for _, ref := range append([]bdv1.ResourceReference{bdpl.Spec.Manifest}, bdpl.Spec.Ops...) {
+			if !withops.IsPolledURL(ref) {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s/%s", bdpl.Namespace, bdpl.Name, ref.Name)
+			if last, ok := p.polled[key]; ok && time.Since(last) < ref.PollInterval.Duration {
+				polled[key] = last
+				continue
+			}
+
+			data, err := withops.FetchURL(ctx, p.client, bdpl.Namespace, ref)
+			polled[key] = time.Now()
+			if err != nil {
+				log.WithEvent(bdpl, "URLPollError").Errorf(ctx, "Failed to poll url '%s' of BOSHDeployment '%s/%s': %v", ref.Name, bdpl.Namespace, bdpl.Name, err)
+				continue
+			}
+
+			if withops.Digest(data) != rendered[ref.Name] {
+				log.Infof(ctx, "Content of url '%s' of BOSHDeployment '%s/%s' changed", ref.Name, bdpl.Namespace, bdpl.Name)
+				changed = true
+			}
+		}
+
+		if changed {
+			select {
+			case p.events <- event.GenericEvent{Meta: bdpl, Object: bdpl}:
+			case <-stop:
+				return
+			}
+		}
+	}
+
+	p.polled = polled
+}
@@ -0,0 +1,138 @@
+package boshdeployment_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	cfd "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/boshdeployment"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("URLPoller", func() {
+	var (
+		server  *ghttp.Server
+		client  *fakes.FakeClient
+		poller  *cfd.URLPoller
+		events  chan event.GenericEvent
+		stop    chan struct{}
+		bdpl    bdv1.BOSHDeployment
+		content string
+	)
+
+	poll := func() []event.GenericEvent {
+		done := make(chan struct{})
+		go func() {
+			poller.Poll(stop)
+			close(done)
+		}()
+
+		received := []event.GenericEvent{}
+		for {
+			select {
+			case e := <-events:
+				received = append(received, e)
+			case <-done:
+				return received
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		content = "instance_groups: []"
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/manifest.yml", func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(content))
+		})
+
+		bdpl = bdv1.BOSHDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Spec: bdv1.BOSHDeploymentSpec{
+				Manifest: bdv1.ResourceReference{
+					Type:         bdv1.URLReference,
+					Name:         server.URL() + "/manifest.yml",
+					PollInterval: &metav1.Duration{Duration: time.Nanosecond},
+				},
+			},
+		}
+
+		client = &fakes.FakeClient{}
+		client.ListCalls(func(_ context.Context, object runtime.Object, _ ...crc.ListOption) error {
+			list := bdv1.BOSHDeploymentList{Items: []bdv1.BOSHDeployment{bdpl}}
+			list.DeepCopyInto(object.(*bdv1.BOSHDeploymentList))
+			return nil
+		})
+
+		_, log := helper.NewTestLogger()
+		ctx := ctxlog.NewParentContext(log)
+		events = make(chan event.GenericEvent)
+		stop = make(chan struct{})
+		poller = cfd.NewURLPoller(ctx, client, "default", time.Second, events)
+	})
+
+	AfterEach(func() {
+		close(stop)
+		server.Close()
+	})
+
+	rendered := func(content string) {
+		bdpl.Annotations = map[string]string{
+			bdv1.AnnotationURLDigests: fmt.Sprintf(`{"%s":"%s"}`, bdpl.Spec.Manifest.Name, withops.Digest([]byte(content))),
+		}
+	}
+
+	It("doesn't send an event if the content was used for the last render", func() {
+		rendered(content)
+
+		Expect(poll()).To(BeEmpty())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("sends an event when the content differs from the last render", func() {
+		rendered(content)
+		Expect(poll()).To(BeEmpty())
+
+		content = "instance_groups: [{name: foo}]"
+		received := poll()
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Meta.GetName()).To(Equal("foo"))
+
+		rendered(content)
+		Expect(poll()).To(BeEmpty())
+	})
+
+	It("sends an event if no render recorded the content", func() {
+		Expect(poll()).To(HaveLen(1))
+	})
+
+	It("waits for the poll interval", func() {
+		bdpl.Spec.Manifest.PollInterval.Duration = time.Hour
+		rendered(content)
+
+		poll()
+		content = "changed"
+		Expect(poll()).To(BeEmpty())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("does not poll urls with a pinned checksum", func() {
+		bdpl.Spec.Manifest.SHA256 = "da6fda72d0393902d69c4f1f38b92b2b26c824612208e60dcc5fb539593da5e7"
+
+		poll()
+		Expect(server.ReceivedRequests()).To(BeEmpty())
+	})
+})
@@ -146,6 +146,10 @@ func (v *Validator) OpsResourcesExist(ctx context.Context, specOpsResource []bdv
 						break
 					}
 				}
+
+			default:
+				// Remote resources are checked when resolving the manifest
+				found = true
 			}
 
 			missingResources[resourceName] = !found
@@ -0,0 +1,186 @@
+package boshdeployment
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/names"
+	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
+)
+
+// resolveVMType sets the vm_resources of an instance group from the profile of
+// its vm_type. Explicit vm_resources take precedence, unknown vm_types are
+// ignored. Each key of the vm types config map is a vm_type name, its value
+// is a vm_resources YAML document, e.g. 'cpu: 2' and 'ram: 4096'.
+func (r *ReconcileBPM) resolveVMType(ctx context.Context, instanceGroup *bdm.InstanceGroup) error {
+	if !usesVMType(instanceGroup) || r.vmTypesConfigMap == "" {
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: r.config.OperatorNamespace, Name: r.vmTypesConfigMap}
+	if err := r.apiReader.Get(ctx, key, configMap); err != nil {
+		return errors.Wrapf(err, "failed to get vm types config map '%s'", key)
+	}
+
+	profile, ok := configMap.Data[instanceGroup.VMType]
+	if !ok {
+		log.Debugf(ctx, "vm_type '%s' of instance group '%s' not found in config map '%s'", instanceGroup.VMType, instanceGroup.Name, key)
+		return nil
+	}
+
+	vmResources := &bdm.VMResource{}
+	if err := yaml.Unmarshal([]byte(profile), vmResources); err != nil {
+		return errors.Wrapf(err, "failed to parse vm_type '%s' in config map '%s'", instanceGroup.VMType, key)
+	}
+	instanceGroup.VMResources = vmResources
+
+	return nil
+}
+
+// usesVMType is true if the vm_resources of the instance group are taken from
+// its vm_type
+func usesVMType(instanceGroup *bdm.InstanceGroup) bool {
+	return instanceGroup.VMResources == nil && instanceGroup.VMType != ""
+}
+
+// watchVMTypes reconciles the instance groups, which use a vm_type, when the
+// vm types config map is created or its data changes. The manager's cache
+// only contains the watched namespace, so the config map in the operator
+// namespace is watched by an informer restricted to its name.
+func watchVMTypes(ctx context.Context, config *config.Config, mgr manager.Manager, c controller.Controller, resolver DesiredManifest, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	kclient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return errors.Wrap(err, "could not create kubernetes client")
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(kclient, 0,
+		informers.WithNamespace(config.OperatorNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		factory.Start(stop)
+		<-stop
+		return nil
+	}))
+	if err != nil {
+		return errors.Wrapf(err, "could not add informer for config map '%s' to manager", name)
+	}
+
+	// The initial list of the informer creates an event for an existing
+	// config map, which was already applied by the BPM reconciles
+	started := time.Now()
+	p := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return e.Meta.GetCreationTimestamp().Time.After(started) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			o := e.ObjectOld.(*corev1.ConfigMap)
+			n := e.ObjectNew.(*corev1.ConfigMap)
+			return !reflect.DeepEqual(o.Data, n.Data)
+		},
+	}
+
+	return c.Watch(&source.Informer{Informer: informer}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			reconciles, err := vmTypeBPMSecrets(ctx, mgr.GetClient(), resolver, config.Namespace)
+			if err != nil {
+				log.Errorf(ctx, "Failed to calculate reconciles for vm types config map '%s': %v", a.Meta.GetName(), err)
+			}
+
+			for _, reconciliation := range reconciles {
+				log.NewMappingEvent(a.Object).Debug(ctx, reconciliation, "BPMSecret", a.Meta.GetName(), "ConfigMap")
+			}
+
+			return reconciles
+		}),
+	}, p)
+}
+
+// vmTypeBPMSecrets returns a reconcile request for the latest BPM secret of
+// each instance group in the namespace, which uses a vm_type
+func vmTypeBPMSecrets(ctx context.Context, c client.Client, resolver DesiredManifest, namespace string) ([]reconcile.Request, error) {
+	secrets := &corev1.SecretList{}
+	err := c.List(ctx, secrets,
+		client.InNamespace(namespace),
+		client.MatchingLabels{bdv1.LabelDeploymentSecretType: names.DeploymentSecretBpmInformation.String()},
+	)
+	if err != nil {
+		return []reconcile.Request{}, errors.Wrapf(err, "failed to list BPM secrets in namespace '%s'", namespace)
+	}
+
+	type instanceGroupKey struct{ deploymentName, instanceGroupName string }
+	latest := map[instanceGroupKey]*corev1.Secret{}
+	versions := map[instanceGroupKey]int{}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		key := instanceGroupKey{secret.Labels[bdv1.LabelDeploymentName], secret.Labels[qjv1a1.LabelRemoteID]}
+		if key.deploymentName == "" || key.instanceGroupName == "" || !isBPMInfoSecret(secret) {
+			continue
+		}
+		version, err := vss.Version(*secret)
+		if err != nil {
+			continue
+		}
+		if _, ok := latest[key]; !ok || version > versions[key] {
+			latest[key] = secret
+			versions[key] = version
+		}
+	}
+
+	manifests := map[string]*bdm.Manifest{}
+	reconciles := []reconcile.Request{}
+	for key, secret := range latest {
+		manifest, ok := manifests[key.deploymentName]
+		if !ok {
+			manifest, err = resolver.DesiredManifest(ctx, key.deploymentName, namespace)
+			if err != nil {
+				log.Debugf(ctx, "Skipping BOSHDeployment '%s', its desired manifest can't be read: %v", key.deploymentName, err)
+			}
+			manifests[key.deploymentName] = manifest
+		}
+		if manifest == nil {
+			continue
+		}
+
+		instanceGroup, found := manifest.InstanceGroups.InstanceGroupByName(key.instanceGroupName)
+		if !found || !usesVMType(instanceGroup) {
+			continue
+		}
+		reconciles = append(reconciles, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name},
+		})
+	}
+	return reconciles, nil
+}
@@ -46,11 +46,11 @@ const (
 var addToManagerFuncs = []func(context.Context, *config.Config, manager.Manager) error{
 	watchnamespace.AddTerminate,
 	boshdeployment.AddDeployment,
-	boshdeployment.AddBPM,
-	quarkssecret.AddQuarksSecret,
-	quarkssecret.AddCertificateSigningRequest,
+	boshdeployment.AddDeploymentStatus,
+	boshdeployment.AddBOSHDNS,
 	quarkssecret.AddSecretRotation,
 	quarksstatefulset.AddQuarksStatefulSet,
+	quarksstatefulset.AddQuarksStatefulSetScale,
 	statefulset.AddStatefulSetRollout,
 	quarkslink.AddRestart,
 	quarksstatefulset.AddStatefulSetActivePassive,
@@ -75,14 +75,40 @@ var mutatingHookFuncs = []func(*zap.SugaredLogger, *config.Config) *wh.OperatorW
 	quarkslink.NewBOSHLinkPodMutator,
 }
 
+// Options configures the controllers, in addition to the config shared with
+// the other quarks operators
+type Options struct {
+	// GeneratorBackends are the credential generators of QuarksSecrets
+	GeneratorBackends quarkssecret.GeneratorBackends
+	// VMTypesConfigMap is the name of the config map in the operator
+	// namespace, which maps vm_type names to vm_resources
+	VMTypesConfigMap string
+	// CertificateRenewalFraction is the fraction of their lifetime after
+	// which generated certificates are renewed, zero disables the renewal
+	CertificateRenewalFraction float64
+	// CopyNamespaces are the namespaces QuarksSecrets may copy their
+	// secrets to
+	CopyNamespaces []string
+	// ApprovalPolicy restricts which certificate signing requests of
+	// cluster signed QuarksSecrets are approved
+	ApprovalPolicy quarkssecret.ApprovalPolicy
+}
+
 // AddToManager adds all Controllers to the Manager
-func AddToManager(ctx context.Context, config *config.Config, m manager.Manager) error {
+func AddToManager(ctx context.Context, config *config.Config, m manager.Manager, options Options) error {
 	for _, f := range addToManagerFuncs {
 		if err := f(ctx, config, m); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	if err := boshdeployment.AddBPM(ctx, config, m, options.VMTypesConfigMap); err != nil {
+		return err
+	}
+	if err := quarkssecret.AddCertificateSigningRequest(ctx, config, m, options.ApprovalPolicy); err != nil {
+		return err
+	}
+	return quarkssecret.AddQuarksSecret(ctx, config, m, options.GeneratorBackends, options.CertificateRenewalFraction, options.CopyNamespaces)
 }
 
 // AddToScheme adds all Resources to the Scheme
@@ -91,7 +117,7 @@ func AddToScheme(s *runtime.Scheme) error {
 }
 
 // AddHooks adds all web hooks to the Manager
-func AddHooks(ctx context.Context, config *config.Config, m manager.Manager, generator credsgen.Generator) error {
+func AddHooks(ctx context.Context, config *config.Config, m manager.Manager, generator credsgen.Generator, options Options) error {
 	ctxlog.Infof(ctx, "Setting up webhook server on %s:%d", config.WebhookServerHost, config.WebhookServerPort)
 
 	ctxlog.Info(ctx, "Setting a cf-operator namespace label on the watched namespace")
@@ -114,6 +140,9 @@ func AddHooks(ctx context.Context, config *config.Config, m manager.Manager, gen
 		validatingWebhooks[idx] = hook
 		hookServer.Register(hook.Path, hook.Webhook)
 	}
+	hook := quarkssecret.NewQuarksSecretValidator(log, config, options.CopyNamespaces)
+	validatingWebhooks = append(validatingWebhooks, hook)
+	hookServer.Register(hook.Path, hook.Webhook)
 
 	mutatingWebhooks := make([]*wh.OperatorWebhook, len(mutatingHookFuncs))
 	for idx, f := range mutatingHookFuncs {
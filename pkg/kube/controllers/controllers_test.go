@@ -100,7 +100,7 @@ var _ = Describe("Controllers", func() {
 				return nil
 			})
 
-			err := controllers.AddHooks(ctx, config, manager, generator)
+			err := controllers.AddHooks(ctx, config, manager, generator, controllers.Options{})
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -117,7 +117,7 @@ var _ = Describe("Controllers", func() {
 					return nil
 				})
 
-				err := controllers.AddHooks(ctx, config, manager, generator)
+				err := controllers.AddHooks(ctx, config, manager, generator, controllers.Options{})
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(afero.Exists(config.Fs, file)).To(BeTrue())
@@ -153,7 +153,7 @@ var _ = Describe("Controllers", func() {
 			})
 
 			It("does not overwrite the existing secret", func() {
-				err := controllers.AddHooks(ctx, config, manager, generator)
+				err := controllers.AddHooks(ctx, config, manager, generator, controllers.Options{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(client.CreateCallCount()).To(Equal(2)) // webhook config for Mutation and Validation
 			})
@@ -176,7 +176,7 @@ var _ = Describe("Controllers", func() {
 						return nil
 					case *admissionregistration.ValidatingWebhookConfiguration:
 						Expect(config.Name).To(Equal("cf-operator-hook-default"))
-						Expect(len(config.Webhooks)).To(Equal(2))
+						Expect(len(config.Webhooks)).To(Equal(3))
 
 						wh := config.Webhooks[0]
 						Expect(wh.Name).To(Equal("validate-boshdeployment.quarks.cloudfoundry.org"))
@@ -188,7 +188,7 @@ var _ = Describe("Controllers", func() {
 						return errors.New("unexpected type")
 					}
 				})
-				err := controllers.AddHooks(ctx, config, manager, generator)
+				err := controllers.AddHooks(ctx, config, manager, generator, controllers.Options{})
 				Expect(err).ToNot(HaveOccurred())
 			})
 		})
@@ -7,21 +7,23 @@ import (
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/boshdeployment"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
 )
 
 type FakeWithOps struct {
-	ManifestStub        func(*v1alpha1.BOSHDeployment, string) (*manifest.Manifest, []string, error)
-	manifestMutex       sync.RWMutex
-	manifestArgsForCall []struct {
+	ManifestWithURLContentsStub        func(*v1alpha1.BOSHDeployment, string, withops.URLContents) (*manifest.Manifest, []string, error)
+	manifestWithURLContentsMutex       sync.RWMutex
+	manifestWithURLContentsArgsForCall []struct {
 		arg1 *v1alpha1.BOSHDeployment
 		arg2 string
+		arg3 withops.URLContents
 	}
-	manifestReturns struct {
+	manifestWithURLContentsReturns struct {
 		result1 *manifest.Manifest
 		result2 []string
 		result3 error
 	}
-	manifestReturnsOnCall map[int]struct {
+	manifestWithURLContentsReturnsOnCall map[int]struct {
 		result1 *manifest.Manifest
 		result2 []string
 		result3 error
@@ -30,67 +32,68 @@ type FakeWithOps struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeWithOps) Manifest(arg1 *v1alpha1.BOSHDeployment, arg2 string) (*manifest.Manifest, []string, error) {
-	fake.manifestMutex.Lock()
-	ret, specificReturn := fake.manifestReturnsOnCall[len(fake.manifestArgsForCall)]
-	fake.manifestArgsForCall = append(fake.manifestArgsForCall, struct {
+func (fake *FakeWithOps) ManifestWithURLContents(arg1 *v1alpha1.BOSHDeployment, arg2 string, arg3 withops.URLContents) (*manifest.Manifest, []string, error) {
+	fake.manifestWithURLContentsMutex.Lock()
+	ret, specificReturn := fake.manifestWithURLContentsReturnsOnCall[len(fake.manifestWithURLContentsArgsForCall)]
+	fake.manifestWithURLContentsArgsForCall = append(fake.manifestWithURLContentsArgsForCall, struct {
 		arg1 *v1alpha1.BOSHDeployment
 		arg2 string
-	}{arg1, arg2})
-	fake.recordInvocation("Manifest", []interface{}{arg1, arg2})
-	fake.manifestMutex.Unlock()
-	if fake.ManifestStub != nil {
-		return fake.ManifestStub(arg1, arg2)
+		arg3 withops.URLContents
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("ManifestWithURLContents", []interface{}{arg1, arg2, arg3})
+	fake.manifestWithURLContentsMutex.Unlock()
+	if fake.ManifestWithURLContentsStub != nil {
+		return fake.ManifestWithURLContentsStub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3
 	}
-	fakeReturns := fake.manifestReturns
+	fakeReturns := fake.manifestWithURLContentsReturns
 	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
 }
 
-func (fake *FakeWithOps) ManifestCallCount() int {
-	fake.manifestMutex.RLock()
-	defer fake.manifestMutex.RUnlock()
-	return len(fake.manifestArgsForCall)
+func (fake *FakeWithOps) ManifestWithURLContentsCallCount() int {
+	fake.manifestWithURLContentsMutex.RLock()
+	defer fake.manifestWithURLContentsMutex.RUnlock()
+	return len(fake.manifestWithURLContentsArgsForCall)
 }
 
-func (fake *FakeWithOps) ManifestCalls(stub func(*v1alpha1.BOSHDeployment, string) (*manifest.Manifest, []string, error)) {
-	fake.manifestMutex.Lock()
-	defer fake.manifestMutex.Unlock()
-	fake.ManifestStub = stub
+func (fake *FakeWithOps) ManifestWithURLContentsCalls(stub func(*v1alpha1.BOSHDeployment, string, withops.URLContents) (*manifest.Manifest, []string, error)) {
+	fake.manifestWithURLContentsMutex.Lock()
+	defer fake.manifestWithURLContentsMutex.Unlock()
+	fake.ManifestWithURLContentsStub = stub
 }
 
-func (fake *FakeWithOps) ManifestArgsForCall(i int) (*v1alpha1.BOSHDeployment, string) {
-	fake.manifestMutex.RLock()
-	defer fake.manifestMutex.RUnlock()
-	argsForCall := fake.manifestArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+func (fake *FakeWithOps) ManifestWithURLContentsArgsForCall(i int) (*v1alpha1.BOSHDeployment, string, withops.URLContents) {
+	fake.manifestWithURLContentsMutex.RLock()
+	defer fake.manifestWithURLContentsMutex.RUnlock()
+	argsForCall := fake.manifestWithURLContentsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
-func (fake *FakeWithOps) ManifestReturns(result1 *manifest.Manifest, result2 []string, result3 error) {
-	fake.manifestMutex.Lock()
-	defer fake.manifestMutex.Unlock()
-	fake.ManifestStub = nil
-	fake.manifestReturns = struct {
+func (fake *FakeWithOps) ManifestWithURLContentsReturns(result1 *manifest.Manifest, result2 []string, result3 error) {
+	fake.manifestWithURLContentsMutex.Lock()
+	defer fake.manifestWithURLContentsMutex.Unlock()
+	fake.ManifestWithURLContentsStub = nil
+	fake.manifestWithURLContentsReturns = struct {
 		result1 *manifest.Manifest
 		result2 []string
 		result3 error
 	}{result1, result2, result3}
 }
 
-func (fake *FakeWithOps) ManifestReturnsOnCall(i int, result1 *manifest.Manifest, result2 []string, result3 error) {
-	fake.manifestMutex.Lock()
-	defer fake.manifestMutex.Unlock()
-	fake.ManifestStub = nil
-	if fake.manifestReturnsOnCall == nil {
-		fake.manifestReturnsOnCall = make(map[int]struct {
+func (fake *FakeWithOps) ManifestWithURLContentsReturnsOnCall(i int, result1 *manifest.Manifest, result2 []string, result3 error) {
+	fake.manifestWithURLContentsMutex.Lock()
+	defer fake.manifestWithURLContentsMutex.Unlock()
+	fake.ManifestWithURLContentsStub = nil
+	if fake.manifestWithURLContentsReturnsOnCall == nil {
+		fake.manifestWithURLContentsReturnsOnCall = make(map[int]struct {
 			result1 *manifest.Manifest
 			result2 []string
 			result3 error
 		})
 	}
-	fake.manifestReturnsOnCall[i] = struct {
+	fake.manifestWithURLContentsReturnsOnCall[i] = struct {
 		result1 *manifest.Manifest
 		result2 []string
 		result3 error
@@ -100,8 +103,8 @@ func (fake *FakeWithOps) ManifestReturnsOnCall(i int, result1 *manifest.Manifest
 func (fake *FakeWithOps) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.manifestMutex.RLock()
-	defer fake.manifestMutex.RUnlock()
+	fake.manifestWithURLContentsMutex.RLock()
+	defer fake.manifestWithURLContentsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
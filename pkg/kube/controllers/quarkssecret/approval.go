@@ -0,0 +1,318 @@
+package quarkssecret
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	certv1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// Reasons for the approval decision of certificate signing requests
+const (
+	ReasonApproved             = "AutoApproved"
+	ReasonQuarksSecretNotFound = "QuarksSecretNotFound"
+	ReasonRequesterNotAllowed  = "RequesterNotAllowed"
+	ReasonNotClusterSigned     = "NotClusterSigned"
+	ReasonUsagesMismatch       = "UsagesMismatch"
+	ReasonUsageNotAllowed      = "UsageNotAllowed"
+	ReasonInvalidRequest       = "InvalidRequest"
+	ReasonNamesMismatch        = "NamesMismatch"
+)
+
+// ApprovalPolicy restricts which certificate signing requests of cluster
+// signed QuarksSecrets are approved by the operator. A request is only
+// approved if its names and usages match its QuarksSecret.
+type ApprovalPolicy struct {
+	// Requesters are the users allowed to request certificates, usually
+	// the operator's service account. All requests are denied if it's
+	// empty.
+	Requesters []string
+	// Usages are the key usages allowed for certificates, the usages of
+	// the QuarksSecret are allowed if it's empty
+	Usages []certv1.KeyUsage
+}
+
+// serviceAccountUsernamePrefix starts the usernames of service accounts
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// ServiceAccountUsername returns the username of the service account whose
+// token authenticates the operator, i.e. the requester of the operator's
+// certificate signing requests
+func ServiceAccountUsername(restConfig *rest.Config) (string, error) {
+	token := restConfig.BearerToken
+	if token == "" && restConfig.BearerTokenFile != "" {
+		data, err := ioutil.ReadFile(restConfig.BearerTokenFile)
+		if err != nil {
+			return "", errors.Wrapf(err, "could not read service account token '%s'", restConfig.BearerTokenFile)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	// The token is a JWT, its subject is the service account's username
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("the operator is not authenticated by a service account token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", errors.Wrap(err, "could not decode service account token")
+	}
+	claims := struct {
+		Subject string `json:"sub"`
+	}{}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return "", errors.Wrap(err, "could not parse service account token")
+	}
+	if !strings.HasPrefix(claims.Subject, serviceAccountUsernamePrefix) {
+		return "", errors.Errorf("subject '%s' of the operator's token is not a service account", claims.Subject)
+	}
+
+	return claims.Subject, nil
+}
+
+// approvalDecision is the result of reviewing a certificate signing request
+type approvalDecision struct {
+	approved bool
+	reason   string
+	message  string
+}
+
+func approve() approvalDecision {
+	return approvalDecision{approved: true, reason: ReasonApproved, message: "This CSR was approved by csr-controller"}
+}
+
+func deny(reason string, format string, v ...interface{}) approvalDecision {
+	return approvalDecision{reason: reason, message: fmt.Sprintf(format, v...)}
+}
+
+// reviewRequest approves or denies the pending certificatesigningrequest and
+// records the decision in the status of its QuarksSecret
+func (r *ReconcileCertificateSigningRequest) reviewRequest(ctx context.Context, csrName string) error {
+	csr, err := r.certClient.CertificateSigningRequests().Get(csrName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "could not get certificatesigningrequest '%s'", csrName)
+	}
+
+	if isApproved(csr.Status.Conditions) || isDenied(csr.Status.Conditions) {
+		ctxlog.Debugf(ctx, "Skip review: certificatesigningrequest %s has already been decided on", csrName)
+		return nil
+	}
+
+	qsec, err := r.originatingQuarksSecret(ctx, csr)
+	if err != nil {
+		return err
+	}
+
+	decision := deny(ReasonQuarksSecretNotFound, "The QuarksSecret which requested the certificate doesn't exist")
+	if qsec != nil {
+		decision, err = r.decide(ctx, csr, qsec)
+		if err != nil {
+			return err
+		}
+	}
+
+	condition := certv1.CertificateSigningRequestCondition{
+		Type:    certv1.CertificateApproved,
+		Reason:  decision.reason,
+		Message: decision.message,
+	}
+	if decision.approved {
+		ctxlog.Debugf(ctx, "Approving certificatesigningrequest '%s'", csrName)
+	} else {
+		condition.Type = certv1.CertificateDenied
+		var object runtime.Object = csr
+		if qsec != nil {
+			object = qsec
+		}
+		ctxlog.WithEvent(object, "CertificateDenied").Errorf(ctx, "Denying certificatesigningrequest '%s': %s", csrName, decision.message)
+	}
+	csr.Status.Conditions = append(csr.Status.Conditions, condition)
+
+	_, err = r.certClient.CertificateSigningRequests().UpdateApproval(csr)
+	if err != nil {
+		return errors.Wrapf(err, "could not update approval of certificatesigningrequest '%s'", csrName)
+	}
+
+	ctxlog.Debugf(ctx, "CertificateSigningRequest '%s' has been updated", csrName)
+
+	if qsec == nil {
+		return nil
+	}
+
+	qsec.Status.Approval = &qsv1a1.CertificateApproval{
+		Request:  csrName,
+		Approved: decision.approved,
+		Reason:   decision.reason,
+		Message:  decision.message,
+		Time:     metav1.Now(),
+	}
+	err = r.client.Status().Update(ctx, qsec)
+	if err != nil {
+		return errors.Wrapf(err, "could not update status of quarks secret '%s/%s'", qsec.Namespace, qsec.Name)
+	}
+
+	return nil
+}
+
+// originatingQuarksSecret returns the QuarksSecret named in the annotations
+// of the certificatesigningrequest, or nil if it doesn't exist
+func (r *ReconcileCertificateSigningRequest) originatingQuarksSecret(ctx context.Context, csr *certv1.CertificateSigningRequest) (*qsv1a1.QuarksSecret, error) {
+	annotations := csr.GetAnnotations()
+	namespace := annotations[qsv1a1.AnnotationQSecNamespace]
+	name, ok := annotations[qsv1a1.AnnotationQSecName]
+	if !ok {
+		return nil, nil
+	}
+
+	qsec := &qsv1a1.QuarksSecret{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, qsec)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not get quarks secret '%s/%s'", namespace, name)
+	}
+	return qsec, nil
+}
+
+// decide checks the certificatesigningrequest against the approval policy
+// and the certificate request of its QuarksSecret
+func (r *ReconcileCertificateSigningRequest) decide(ctx context.Context, csr *certv1.CertificateSigningRequest, qsec *qsv1a1.QuarksSecret) (approvalDecision, error) {
+	if !contains(r.approvalPolicy.Requesters, csr.Spec.Username) {
+		return deny(ReasonRequesterNotAllowed, "User '%s' is not allowed to request certificates", csr.Spec.Username), nil
+	}
+
+	certificateRequest := qsec.Spec.Request.CertificateRequest
+	if qsec.Spec.Type != qsv1a1.Certificate || certificateRequest.SignerType != qsv1a1.ClusterSigner {
+		return deny(ReasonNotClusterSigned, "QuarksSecret '%s' doesn't request a cluster signed certificate", qsec.Name), nil
+	}
+
+	requested := usageNames(csr.Spec.Usages)
+	expected := usageNames(certificateRequest.Usages)
+	if len(expected) == 0 {
+		// The API server defaults the usages of requests without usages
+		expected = usageNames([]certv1.KeyUsage{certv1.UsageDigitalSignature, certv1.UsageKeyEncipherment})
+	}
+	if strings.Join(requested, ",") != strings.Join(expected, ",") {
+		return deny(ReasonUsagesMismatch, "Requested usages [%s] don't match the usages [%s] of QuarksSecret '%s'", strings.Join(requested, ", "), strings.Join(expected, ", "), qsec.Name), nil
+	}
+	if len(r.approvalPolicy.Usages) > 0 {
+		allowed := usageNames(r.approvalPolicy.Usages)
+		for _, usage := range requested {
+			if !contains(allowed, usage) {
+				return deny(ReasonUsageNotAllowed, "Usage '%s' is not allowed", usage), nil
+			}
+		}
+	}
+
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return deny(ReasonInvalidRequest, "Could not decode the PEM encoded certificate request"), nil
+	}
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return deny(ReasonInvalidRequest, "Could not parse the certificate request: %s", err), nil
+	}
+	if err := request.CheckSignature(); err != nil {
+		return deny(ReasonInvalidRequest, "Invalid signature of the certificate request: %s", err), nil
+	}
+
+	allowedNames, err := r.allowedNames(ctx, qsec)
+	if err != nil {
+		return approvalDecision{}, err
+	}
+	unexpected := []string{}
+	for _, name := range requestedNames(request) {
+		if !contains(allowedNames, name) {
+			unexpected = append(unexpected, name)
+		}
+	}
+	if len(unexpected) > 0 {
+		return deny(ReasonNamesMismatch, "Requested names [%s] are not part of QuarksSecret '%s'", strings.Join(unexpected, ", "), qsec.Name), nil
+	}
+
+	return approve(), nil
+}
+
+// allowedNames returns the common name and alternative names of the
+// QuarksSecret's certificate request, including those of referenced services
+func (r *ReconcileCertificateSigningRequest) allowedNames(ctx context.Context, qsec *qsv1a1.QuarksSecret) ([]string, error) {
+	certificateRequest := qsec.Spec.Request.CertificateRequest
+	names := append([]string{certificateRequest.CommonName}, certificateRequest.AlternativeNames...)
+
+	for _, serviceRef := range certificateRequest.ServiceRef {
+		service := &corev1.Service{}
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: qsec.Namespace, Name: serviceRef.Name}, service)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get service reference '%s' of quarks secret '%s/%s'", serviceRef.Name, qsec.Namespace, qsec.Name)
+		}
+		names = append(names, serviceAlternativeNames(service)...)
+	}
+
+	return names, nil
+}
+
+// requestedNames returns the subject's common name and all alternative names
+// of the certificate request
+func requestedNames(request *x509.CertificateRequest) []string {
+	names := []string{request.Subject.CommonName}
+	names = append(names, request.DNSNames...)
+	names = append(names, request.EmailAddresses...)
+	for _, ip := range request.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range request.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// usageNames returns the sorted, unique names of the key usages
+func usageNames(usages []certv1.KeyUsage) []string {
+	names := []string{}
+	for _, usage := range usages {
+		if !contains(names, string(usage)) {
+			names = append(names, string(usage))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isDenied returns true if the certificatesigningrequest has been denied
+func isDenied(conditions []certv1.CertificateSigningRequestCondition) bool {
+	for _, condition := range conditions {
+		if condition.Type == certv1.CertificateDenied {
+			return true
+		}
+	}
+
+	return false
+}
@@ -19,14 +19,14 @@ import (
 )
 
 // AddCertificateSigningRequest creates a new CertificateSigningRequest controller to watch for new and changed
-// certificate signing request. Reconciliation will approve them and create a secret.
-func AddCertificateSigningRequest(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+// certificate signing request. Reconciliation will approve them according to the policy and create a secret.
+func AddCertificateSigningRequest(ctx context.Context, config *config.Config, mgr manager.Manager, approvalPolicy ApprovalPolicy) error {
 	ctx = ctxlog.NewContextWithRecorder(ctx, "csr-reconciler", mgr.GetEventRecorderFor("csr-recorder"))
 	certClient, err := certv1client.NewForConfig(mgr.GetConfig())
 	if err != nil {
 		return errors.Wrap(err, "Could not get kube client")
 	}
-	r := NewCertificateSigningRequestReconciler(ctx, config, mgr, certClient)
+	r := NewCertificateSigningRequestReconciler(ctx, config, mgr, certClient, approvalPolicy)
 
 	// Create a new controller
 	c, err := controller.New("certificate-signing-request-controller", mgr, controller.Options{
@@ -25,13 +25,16 @@ import (
 )
 
 // NewCertificateSigningRequestReconciler returns a new Reconciler
-func NewCertificateSigningRequestReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, certClient certv1client.CertificatesV1beta1Interface) reconcile.Reconciler {
+func NewCertificateSigningRequestReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, certClient certv1client.CertificatesV1beta1Interface, approvalPolicy ApprovalPolicy) reconcile.Reconciler {
 	return &ReconcileCertificateSigningRequest{
 		ctx:        ctx,
 		config:     config,
 		client:     mgr.GetClient(),
+		apiReader:  mgr.GetAPIReader(),
 		certClient: certClient,
 		scheme:     mgr.GetScheme(),
+
+		approvalPolicy: approvalPolicy,
 	}
 }
 
@@ -40,11 +43,14 @@ type ReconcileCertificateSigningRequest struct {
 	ctx        context.Context
 	config     *config.Config
 	client     client.Client
+	apiReader  client.Reader
 	certClient certv1client.CertificatesV1beta1Interface
 	scheme     *runtime.Scheme
+
+	approvalPolicy ApprovalPolicy
 }
 
-// Reconcile approves pending CSR and creates its certificate secret
+// Reconcile approves or denies pending CSR and creates its certificate secret
 func (r *ReconcileCertificateSigningRequest) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	instance := &certv1.CertificateSigningRequest{}
 
@@ -119,52 +125,38 @@ func (r *ReconcileCertificateSigningRequest) Reconcile(request reconcile.Request
 			return reconcile.Result{}, err
 		}
 
+		// CSRs created by older operator versions don't name the QuarksSecret
+		if qsecName, ok := annotations[qev1a1.AnnotationQSecName]; ok {
+			qsec, err := r.updateCertificateValidity(ctx, namespace, qsecName, instance.Status.Certificate)
+			if err != nil {
+				ctxlog.Errorf(ctx, "Failed to record the certificate validity: %v", err.Error())
+				return reconcile.Result{}, err
+			}
+
+			if qsec != nil {
+				err = syncCopies(ctx, r.client, r.apiReader, qsec)
+				if err != nil {
+					return reconcile.Result{}, ctxlog.WithEvent(qsec, "CopySecretError").Errorf(ctx, "Failed to copy secret of QuarksSecret '%s': %s", qsec.Name, err)
+				}
+			}
+		}
+
 		err = r.deleteSecret(ctx, privatekeySecret)
 		if err != nil {
 			ctxlog.Errorf(ctx, "Failed to delete the CSR private key secret: %v", err.Error())
 			return reconcile.Result{}, err
 		}
 	} else {
-		err = r.approveRequest(ctx, instance.Name)
+		err = r.reviewRequest(ctx, instance.Name)
 		if err != nil {
-			ctxlog.Errorf(ctx, "Failed to approve certificate signing request: %v", err.Error())
-			return reconcile.Result{}, errors.Wrap(err, "approving cert request failed")
+			ctxlog.Errorf(ctx, "Failed to review certificate signing request: %v", err.Error())
+			return reconcile.Result{}, errors.Wrap(err, "reviewing cert request failed")
 		}
 	}
 
 	return reconcile.Result{}, nil
 }
 
-// approveRequest approves the certificatesigningrequest
-func (r *ReconcileCertificateSigningRequest) approveRequest(ctx context.Context, csrName string) error {
-	csr, err := r.certClient.CertificateSigningRequests().Get(csrName, metav1.GetOptions{})
-	if err != nil {
-		return errors.Wrapf(err, "could not get certificatesigningrequest '%s'", csrName)
-	}
-
-	if isApproved(csr.Status.Conditions) {
-		ctxlog.Debugf(ctx, "Skip approve: certificatesigningrequest %s has already been approved", csrName)
-		return nil
-	}
-
-	ctxlog.Debugf(ctx, "Approving certificatesigningrequest '%s'", csrName)
-
-	csr.Status.Conditions = append(csr.Status.Conditions, certv1.CertificateSigningRequestCondition{
-		Type:    certv1.CertificateApproved,
-		Reason:  "AutoApproved",
-		Message: "This CSR was approved by csr-controller",
-	})
-
-	_, err = r.certClient.CertificateSigningRequests().UpdateApproval(csr)
-	if err != nil {
-		return errors.Wrapf(err, "could not update approval of certificatesigningrequest '%s'", csrName)
-	}
-
-	ctxlog.Debugf(ctx, "CertificateSigningRequest '%s' has been updated", csrName)
-
-	return nil
-}
-
 // createSecret creates secret
 func (r *ReconcileCertificateSigningRequest) createSecret(ctx context.Context, secret *corev1.Secret) error {
 	ctxlog.Debugf(ctx, "Creating secret '%s'", secret.Name)
@@ -183,6 +175,32 @@ func (r *ReconcileCertificateSigningRequest) createSecret(ctx context.Context, s
 	return nil
 }
 
+// updateCertificateValidity records the validity of the issued certificate
+// in the QuarksSecret's status, to renew the certificate in time. It returns
+// the QuarksSecret, or nil if it doesn't exist anymore.
+func (r *ReconcileCertificateSigningRequest) updateCertificateValidity(ctx context.Context, namespace string, name string, certificate []byte) (*qev1a1.QuarksSecret, error) {
+	qsec := &qev1a1.QuarksSecret{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, qsec)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			ctxlog.Debugf(ctx, "Skip recording certificate validity: quarks secret '%s/%s' not found", namespace, name)
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not get quarks secret '%s/%s'", namespace, name)
+	}
+
+	if err := setCertificateValidity(&qsec.Status, certificate); err != nil {
+		ctxlog.WithEvent(qsec, "CertificateValidityError").Errorf(ctx, "Could not read validity of issued certificate for quarks secret '%s/%s': %s", namespace, name, err)
+		return qsec, nil
+	}
+
+	err = r.client.Status().Update(ctx, qsec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not update status of quarks secret '%s/%s'", namespace, name)
+	}
+	return qsec, nil
+}
+
 // getSecret gets secret
 func (r *ReconcileCertificateSigningRequest) getSecret(ctx context.Context, namespace string, secretName string) (*corev1.Secret, error) {
 	ctxlog.Debugf(ctx, "getting secret '%s'", secretName)
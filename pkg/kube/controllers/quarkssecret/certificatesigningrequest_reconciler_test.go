@@ -2,6 +2,7 @@ package quarkssecret_test
 
 import (
 	"context"
+	"encoding/base64"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -15,10 +16,13 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	certv1clientfakes "k8s.io/client-go/kubernetes/typed/certificates/v1beta1/fake"
+	"k8s.io/client-go/rest"
 	ktesting "k8s.io/client-go/testing"
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
 	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned/scheme"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
@@ -42,6 +46,7 @@ var _ = Describe("ReconcileCertificateSigningRequest", func() {
 		certClient       *certv1clientfakes.FakeCertificatesV1beta1
 		csr              *certv1.CertificateSigningRequest
 		privateKeySecret *corev1.Secret
+		approvalPolicy   escontroller.ApprovalPolicy
 	)
 
 	BeforeEach(func() {
@@ -73,6 +78,7 @@ var _ = Describe("ReconcileCertificateSigningRequest", func() {
 		manager = &cfakes.FakeManager{}
 		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
 		config = &cfcfg.Config{CtxTimeOut: 10 * time.Second}
+		approvalPolicy = escontroller.ApprovalPolicy{}
 		_, log = helper.NewTestLogger()
 		ctx = ctxlog.NewParentContext(log)
 
@@ -99,11 +105,77 @@ var _ = Describe("ReconcileCertificateSigningRequest", func() {
 	})
 
 	JustBeforeEach(func() {
-		reconciler = escontroller.NewCertificateSigningRequestReconciler(ctx, config, manager, certClient)
+		reconciler = escontroller.NewCertificateSigningRequestReconciler(ctx, config, manager, certClient, approvalPolicy)
 	})
 
 	Context("when reconciling pending CSR", func() {
+		var (
+			qsec         *qsv1a1.QuarksSecret
+			statusWriter *cfakes.FakeStatusWriter
+			updated      *certv1.CertificateSigningRequest
+		)
+
+		generateRequest := func(commonName string, alternativeNames ...string) []byte {
+			generator := inmemorygenerator.NewInMemoryGenerator(log)
+			request, _, err := generator.GenerateCertificateSigningRequest(credsgen.CertificateGenerationRequest{
+				CommonName:       commonName,
+				AlternativeNames: alternativeNames,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			return request
+		}
+
+		lastCondition := func() certv1.CertificateSigningRequestCondition {
+			Expect(updated).ToNot(BeNil())
+			return updated.Status.Conditions[len(updated.Status.Conditions)-1]
+		}
+
 		BeforeEach(func() {
+			qsec = &qsv1a1.QuarksSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "qsec", Namespace: "fake-namespace"},
+				Spec: qsv1a1.QuarksSecretSpec{
+					Type:       qsv1a1.Certificate,
+					SecretName: "fake-cert",
+					Request: qsv1a1.Request{
+						CertificateRequest: qsv1a1.CertificateRequest{
+							CommonName:       "example.com",
+							AlternativeNames: []string{"foo.example.com"},
+							SignerType:       qsv1a1.ClusterSigner,
+							Usages:           []certv1.KeyUsage{certv1.UsageServerAuth},
+						},
+					},
+				},
+			}
+
+			csr.Annotations[qsv1a1.AnnotationQSecName] = "qsec"
+			csr.Spec.Username = "system:serviceaccount:cf-operator:cf-operator"
+			csr.Spec.Usages = []certv1.KeyUsage{certv1.UsageServerAuth}
+			csr.Spec.Request = generateRequest("example.com", "foo.example.com")
+
+			client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+				switch object := object.(type) {
+				case *certv1.CertificateSigningRequest:
+					csr.DeepCopyInto(object)
+					return nil
+				case *qsv1a1.QuarksSecret:
+					if nn.Name == qsec.Name && nn.Namespace == qsec.Namespace {
+						qsec.DeepCopyInto(object)
+						return nil
+					}
+				case *corev1.Service:
+					if nn.Name == "svc" {
+						object.Name = nn.Name
+						object.Namespace = nn.Namespace
+						object.Spec.ClusterIP = "10.0.0.1"
+						return nil
+					}
+				}
+				return apierrors.NewNotFound(schema.GroupResource{}, "not found")
+			})
+			statusWriter = &cfakes.FakeStatusWriter{}
+			client.StatusReturns(statusWriter)
+
+			updated = nil
 			certClient.AddReactor("get", "certificatesigningrequests", func(action ktesting.Action) (handled bool, ret runtime.Object, err error) {
 				if action, ok := action.(ktesting.GetActionImpl); ok {
 					Expect(action.Name).To(Equal(csr.Name))
@@ -115,22 +187,138 @@ var _ = Describe("ReconcileCertificateSigningRequest", func() {
 				if action, ok := action.(ktesting.UpdateActionImpl); ok {
 					switch object := action.Object.(type) {
 					case *certv1.CertificateSigningRequest:
-						Expect(object.Status.Conditions).To(ContainElement(certv1.CertificateSigningRequestCondition{
-							Type:    certv1.CertificateApproved,
-							Reason:  "AutoApproved",
-							Message: "This CSR was approved by csr-controller",
-						}))
+						updated = object
 						return true, csr, nil
 					}
 				}
 
 				return true, &certv1.CertificateSigningRequest{}, apierrors.NewBadRequest("fake-error")
 			})
+
+			approvalPolicy = escontroller.ApprovalPolicy{Requesters: []string{"system:serviceaccount:cf-operator:cf-operator"}}
 		})
+
 		It("approves CSR", func() {
 			result, err := reconciler.Reconcile(request)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(reconcile.Result{}).To(Equal(result))
+			Expect(lastCondition()).To(Equal(certv1.CertificateSigningRequestCondition{
+				Type:    certv1.CertificateApproved,
+				Reason:  "AutoApproved",
+				Message: "This CSR was approved by csr-controller",
+			}))
+		})
+
+		It("records the decision in the status of the quarks secret", func() {
+			_, err := reconciler.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			approval := object.(*qsv1a1.QuarksSecret).Status.Approval
+			Expect(approval).ToNot(BeNil())
+			Expect(approval.Request).To(Equal("foo"))
+			Expect(approval.Approved).To(BeTrue())
+			Expect(approval.Reason).To(Equal(escontroller.ReasonApproved))
+		})
+
+		It("approves names of referenced services", func() {
+			qsec.Spec.Request.CertificateRequest.ServiceRef = []qsv1a1.ServiceReference{{Name: "svc"}}
+			csr.Spec.Request = generateRequest("10.0.0.1", "example.com", "svc.fake-namespace")
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lastCondition().Type).To(Equal(certv1.CertificateApproved))
+		})
+
+		It("denies names not requested by the quarks secret", func() {
+			csr.Spec.Request = generateRequest("example.com", "evil.example.com")
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lastCondition().Type).To(Equal(certv1.CertificateDenied))
+			Expect(lastCondition().Reason).To(Equal(escontroller.ReasonNamesMismatch))
+			Expect(lastCondition().Message).To(ContainSubstring("evil.example.com"))
+
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			approval := object.(*qsv1a1.QuarksSecret).Status.Approval
+			Expect(approval.Approved).To(BeFalse())
+			Expect(approval.Reason).To(Equal(escontroller.ReasonNamesMismatch))
+		})
+
+		It("denies usages not requested by the quarks secret", func() {
+			csr.Spec.Usages = []certv1.KeyUsage{certv1.UsageServerAuth, certv1.UsageCertSign}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lastCondition().Type).To(Equal(certv1.CertificateDenied))
+			Expect(lastCondition().Reason).To(Equal(escontroller.ReasonUsagesMismatch))
+		})
+
+		Context("when the policy restricts the usages", func() {
+			BeforeEach(func() {
+				approvalPolicy.Usages = []certv1.KeyUsage{certv1.UsageClientAuth}
+			})
+
+			It("denies usages not allowed by the policy", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lastCondition().Type).To(Equal(certv1.CertificateDenied))
+				Expect(lastCondition().Reason).To(Equal(escontroller.ReasonUsageNotAllowed))
+			})
+		})
+
+		Context("when the policy allows other requesters", func() {
+			BeforeEach(func() {
+				approvalPolicy.Requesters = []string{"system:serviceaccount:other:other"}
+			})
+
+			It("denies requesters not allowed by the policy", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lastCondition().Type).To(Equal(certv1.CertificateDenied))
+				Expect(lastCondition().Reason).To(Equal(escontroller.ReasonRequesterNotAllowed))
+			})
+		})
+
+		Context("when the policy has no requesters", func() {
+			BeforeEach(func() {
+				approvalPolicy = escontroller.ApprovalPolicy{}
+			})
+
+			It("denies all requesters", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lastCondition().Type).To(Equal(certv1.CertificateDenied))
+				Expect(lastCondition().Reason).To(Equal(escontroller.ReasonRequesterNotAllowed))
+			})
+		})
+
+		It("denies invalid requests", func() {
+			csr.Spec.Request = []byte("fake-certificate-signing-request")
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lastCondition().Type).To(Equal(certv1.CertificateDenied))
+			Expect(lastCondition().Reason).To(Equal(escontroller.ReasonInvalidRequest))
+		})
+
+		It("denies requests of missing quarks secrets", func() {
+			csr.Annotations[qsv1a1.AnnotationQSecName] = "missing"
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lastCondition().Type).To(Equal(certv1.CertificateDenied))
+			Expect(lastCondition().Reason).To(Equal(escontroller.ReasonQuarksSecretNotFound))
+			Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+		})
+
+		It("skips if pending certificatesigningrequest has been denied", func() {
+			csr.Status.Conditions = []certv1.CertificateSigningRequestCondition{{Type: certv1.CertificateDenied}}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated).To(BeNil())
 		})
 
 		It("skips if the resource was not found", func() {
@@ -340,3 +528,25 @@ var _ = Describe("ReconcileCertificateSigningRequest", func() {
 		})
 	})
 })
+
+var _ = Describe("ServiceAccountUsername", func() {
+	token := func(claims string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+	}
+
+	It("returns the subject of the service account token", func() {
+		username, err := escontroller.ServiceAccountUsername(&rest.Config{BearerToken: token(`{"sub":"system:serviceaccount:cf-operator:cf-operator"}`)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(username).To(Equal("system:serviceaccount:cf-operator:cf-operator"))
+	})
+
+	It("fails if the operator is not authenticated by a token", func() {
+		_, err := escontroller.ServiceAccountUsername(&rest.Config{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails if the subject is not a service account", func() {
+		_, err := escontroller.ServiceAccountUsername(&rest.Config{BearerToken: token(`{"sub":"admin"}`)})
+		Expect(err).To(MatchError(ContainSubstring("is not a service account")))
+	})
+})
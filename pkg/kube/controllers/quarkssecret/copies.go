@@ -0,0 +1,222 @@
+package quarkssecret
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// copySecrets creates or updates the copies of the QuarksSecret's secret in
+// other namespaces and deletes recorded copies, which are no longer in the
+// spec. The manager's cache only contains the watched namespace, so secrets
+// are read with the API reader.
+func copySecrets(ctx context.Context, c client.Client, reader client.Reader, qsec *qsv1a1.QuarksSecret) error {
+	keep := map[string]bool{}
+	for _, target := range desiredCopies(qsec) {
+		keep[copyKey(target)] = true
+	}
+	err := pruneCopies(ctx, c, reader, qsec, keep)
+	if err != nil {
+		return err
+	}
+
+	return syncCopies(ctx, c, reader, qsec)
+}
+
+// syncCopies creates or updates the copies of the QuarksSecret's secret,
+// without deleting copies which were removed from the spec
+func syncCopies(ctx context.Context, c client.Client, reader client.Reader, qsec *qsv1a1.QuarksSecret) error {
+	if len(qsec.Spec.Copies) == 0 {
+		return nil
+	}
+
+	source := &corev1.Secret{}
+	err := reader.Get(ctx, types.NamespacedName{Name: qsec.Spec.SecretName, Namespace: qsec.Namespace}, source)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			ctxlog.Debugf(ctx, "Skip copying secret '%s/%s': it doesn't exist yet", qsec.Namespace, qsec.Spec.SecretName)
+			return nil
+		}
+		return errors.Wrapf(err, "could not get secret '%s/%s'", qsec.Namespace, qsec.Spec.SecretName)
+	}
+
+	for _, target := range qsec.Spec.Copies {
+		err := copySecret(ctx, c, reader, qsec, source, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copySecret creates or updates a single copy of the source secret
+func copySecret(ctx context.Context, c client.Client, reader client.Reader, qsec *qsv1a1.QuarksSecret, source *corev1.Secret, target qsv1a1.SecretCopy) error {
+	name := copyName(source.Name, target)
+
+	existing := &corev1.Secret{}
+	err := reader.Get(ctx, types.NamespacedName{Name: name, Namespace: target.Namespace}, existing)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "could not get secret copy '%s/%s'", target.Namespace, name)
+		}
+
+		ctxlog.Debugf(ctx, "Creating secret copy '%s/%s'", target.Namespace, name)
+		secretCopy := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: target.Namespace,
+				Labels: map[string]string{
+					qsv1a1.LabelKind: qsv1a1.CopiedSecretKind,
+				},
+				Annotations: map[string]string{
+					qsv1a1.AnnotationQSecNamespace: qsec.Namespace,
+					qsv1a1.AnnotationQSecName:      qsec.Name,
+				},
+			},
+			Type: source.Type,
+			Data: source.Data,
+		}
+		err = c.Create(ctx, secretCopy)
+		if err != nil {
+			return errors.Wrapf(err, "could not create secret copy '%s/%s'", target.Namespace, name)
+		}
+		return nil
+	}
+
+	if !isCopyOf(existing, qsec) {
+		return errors.Errorf("secret '%s/%s' exists and is not a copy of quarks secret '%s/%s'", target.Namespace, name, qsec.Namespace, qsec.Name)
+	}
+
+	if reflect.DeepEqual(existing.Data, source.Data) {
+		ctxlog.Debugf(ctx, "Secret copy '%s/%s' is up to date", target.Namespace, name)
+		return nil
+	}
+
+	ctxlog.Debugf(ctx, "Updating secret copy '%s/%s'", target.Namespace, name)
+	existing.Data = source.Data
+	err = c.Update(ctx, existing)
+	if err != nil {
+		return errors.Wrapf(err, "could not update secret copy '%s/%s'", target.Namespace, name)
+	}
+
+	return nil
+}
+
+// deleteCopies deletes all copies of the QuarksSecret's secret
+func deleteCopies(ctx context.Context, c client.Client, reader client.Reader, qsec *qsv1a1.QuarksSecret) error {
+	return pruneCopies(ctx, c, reader, qsec, map[string]bool{})
+}
+
+// pruneCopies deletes the copies recorded in the QuarksSecret's status,
+// which are not kept
+func pruneCopies(ctx context.Context, c client.Client, reader client.Reader, qsec *qsv1a1.QuarksSecret, keep map[string]bool) error {
+	for _, target := range qsec.Status.Copies {
+		if keep[copyKey(target)] {
+			continue
+		}
+
+		secret := &corev1.Secret{}
+		err := reader.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: target.Namespace}, secret)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "could not get secret copy '%s/%s'", target.Namespace, target.Name)
+		}
+		if !isCopyOf(secret, qsec) {
+			continue
+		}
+
+		ctxlog.Debugf(ctx, "Deleting secret copy '%s/%s'", secret.Namespace, secret.Name)
+		err = c.Delete(ctx, secret)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "could not delete secret copy '%s/%s'", secret.Namespace, secret.Name)
+		}
+	}
+
+	return nil
+}
+
+// desiredCopies returns the copies of the QuarksSecret's spec with their
+// names defaulted, as they are recorded in the status
+func desiredCopies(qsec *qsv1a1.QuarksSecret) []qsv1a1.SecretCopy {
+	var copies []qsv1a1.SecretCopy
+	for _, target := range qsec.Spec.Copies {
+		copies = append(copies, qsv1a1.SecretCopy{
+			Namespace: target.Namespace,
+			Name:      copyName(qsec.Spec.SecretName, target),
+		})
+	}
+	return copies
+}
+
+// copyKey identifies a copy by its namespace and name
+func copyKey(target qsv1a1.SecretCopy) string {
+	return types.NamespacedName{Namespace: target.Namespace, Name: target.Name}.String()
+}
+
+// copyName returns the name of the copy, which defaults to the name of the
+// source secret
+func copyName(secretName string, target qsv1a1.SecretCopy) string {
+	if target.Name != "" {
+		return target.Name
+	}
+	return secretName
+}
+
+// hasCopiesFinalizer returns true if the QuarksSecret's copies are deleted
+// before the QuarksSecret
+func hasCopiesFinalizer(qsec *qsv1a1.QuarksSecret) bool {
+	for _, finalizer := range qsec.GetFinalizers() {
+		if finalizer == qsv1a1.FinalizerSecretCopies {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCopiesFinalizer removes the finalizer for the copies from the
+// QuarksSecret
+func removeCopiesFinalizer(qsec *qsv1a1.QuarksSecret) {
+	finalizers := []string{}
+	for _, finalizer := range qsec.GetFinalizers() {
+		if finalizer != qsv1a1.FinalizerSecretCopies {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	qsec.SetFinalizers(finalizers)
+}
+
+// isCopyOf returns true if the secret is a copy managed by the QuarksSecret
+func isCopyOf(secret *corev1.Secret, qsec *qsv1a1.QuarksSecret) bool {
+	namespace, name, ok := copySource(secret)
+	return ok && namespace == qsec.Namespace && name == qsec.Name
+}
+
+// copySource returns the namespace and name of the QuarksSecret a copy
+// belongs to. It returns false if the secret is not a copy.
+func copySource(secret *corev1.Secret) (string, string, bool) {
+	if secret.GetLabels()[qsv1a1.LabelKind] != qsv1a1.CopiedSecretKind {
+		return "", "", false
+	}
+	annotations := secret.GetAnnotations()
+	namespace, ok := annotations[qsv1a1.AnnotationQSecNamespace]
+	if !ok {
+		return "", "", false
+	}
+	name, ok := annotations[qsv1a1.AnnotationQSecName]
+	if !ok {
+		return "", "", false
+	}
+	return namespace, name, true
+}
@@ -0,0 +1,91 @@
+package quarkssecret
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+)
+
+const (
+	// maxDHParamsGenerations limits the concurrent searches for safe primes
+	maxDHParamsGenerations = 2
+	// dhParamsRequeueAfter is the delay between checks for the result of a
+	// running generation
+	dhParamsRequeueAfter = 10 * time.Second
+	// dhParamsResultTTL is how long results are kept, which weren't fetched,
+	// e.g. because their QuarksSecret was deleted
+	dhParamsResultTTL = time.Hour
+)
+
+// dhParamsGeneration is the state of the generation of DH parameters for a
+// QuarksSecret
+type dhParamsGeneration struct {
+	bits   int
+	done   bool
+	doneAt time.Time
+	params []byte
+	err    error
+}
+
+// dhParamsGenerations runs the search for the safe prime of DH parameters in
+// the background, so it doesn't block a reconcile worker. The QuarksSecret is
+// requeued until its parameters are generated.
+type dhParamsGenerations struct {
+	mutex       sync.Mutex
+	generations map[string]*dhParamsGeneration
+	slots       chan struct{}
+}
+
+func newDHParamsGenerations(max int) *dhParamsGenerations {
+	return &dhParamsGenerations{
+		generations: map[string]*dhParamsGeneration{},
+		slots:       make(chan struct{}, max),
+	}
+}
+
+// result returns the generated parameters for the key, once they are done.
+// It starts the generation if there is none for the requested bits yet and
+// less than the maximum number of generations are running. The result is
+// forgotten once it's returned.
+func (g *dhParamsGenerations) result(key string, generator credsgen.Generator, name string, bits int) ([]byte, bool, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for k, generation := range g.generations {
+		if generation.done && time.Since(generation.doneAt) > dhParamsResultTTL {
+			delete(g.generations, k)
+		}
+	}
+
+	generation, ok := g.generations[key]
+	if ok && generation.bits == bits {
+		if !generation.done {
+			return nil, false, nil
+		}
+		delete(g.generations, key)
+		return generation.params, true, generation.err
+	}
+
+	select {
+	case g.slots <- struct{}{}:
+	default:
+		return nil, false, nil
+	}
+
+	// A running generation for other bits is replaced, its result discarded
+	generation = &dhParamsGeneration{bits: bits}
+	g.generations[key] = generation
+	go func() {
+		defer func() { <-g.slots }()
+
+		params, err := generator.GenerateDHParams(name, credsgen.DHParamsGenerationRequest{Bits: bits})
+
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		generation.params, generation.err = params, err
+		generation.done, generation.doneAt = true, time.Now()
+	}()
+
+	return nil, false, nil
+}
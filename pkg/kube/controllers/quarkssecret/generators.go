@@ -0,0 +1,56 @@
+package quarkssecret
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	externalgenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/external_generator"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
+)
+
+// GeneratorBackends configures the credential generator backends available
+// to QuarksSecrets
+type GeneratorBackends struct {
+	// Default is the backend used if a QuarksSecret doesn't select one
+	Default string
+	// ExternalURL is the url of the external credential store, the
+	// external backend is only available if it's set
+	ExternalURL string
+	// ExternalCACert is used to verify the TLS certificate of the store
+	ExternalCACert []byte
+	// ExternalToken is sent as bearer token to the store
+	ExternalToken string
+}
+
+// NewGeneratorRegistry returns a registry with the in-memory backend and,
+// if configured, the external backend
+func NewGeneratorRegistry(log *zap.SugaredLogger, backends GeneratorBackends) (*credsgen.Registry, error) {
+	registry := credsgen.NewRegistry(credsgen.InMemoryBackend, inmemorygenerator.NewInMemoryGenerator(log))
+
+	if backends.ExternalURL != "" {
+		external, err := externalgenerator.NewExternalGenerator(log, backends.ExternalURL, backends.ExternalCACert, backends.ExternalToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to setup external credential generator backend")
+		}
+		registry.Register(credsgen.ExternalBackend, external)
+	}
+
+	if backends.Default != "" {
+		if err := registry.SetDefault(backends.Default); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+// backendName returns the generator backend requested by the QuarksSecret,
+// the spec takes precedence over the annotation
+func backendName(qsec *qsv1a1.QuarksSecret) string {
+	if qsec.Spec.Backend != "" {
+		return qsec.Spec.Backend
+	}
+	return qsec.GetAnnotations()[qsv1a1.AnnotationCredsgenBackend]
+}
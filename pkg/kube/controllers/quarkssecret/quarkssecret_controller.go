@@ -3,10 +3,15 @@ package quarkssecret
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -14,20 +19,27 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	credsgen "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
 	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
 )
 
 // AddQuarksSecret creates a new QuarksSecrets controller to watch for the
-// custom resource and reconcile it into k8s secrets.
-func AddQuarksSecret(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+// custom resource and reconcile it into k8s secrets. The credentials are
+// generated by the configured backends and certificates are renewed after
+// the renewal fraction of their lifetime. Secrets are copied into the copy
+// namespaces, the operator needs access to the secrets in them.
+func AddQuarksSecret(ctx context.Context, config *config.Config, mgr manager.Manager, backends GeneratorBackends, renewalFraction float64, copyNamespaces []string) error {
 	ctx = ctxlog.NewContextWithRecorder(ctx, "quarks-secret-reconciler", mgr.GetEventRecorderFor("quarks-secret-recorder"))
 	log := ctxlog.ExtractLogger(ctx)
-	r := NewQuarksSecretReconciler(ctx, config, mgr, credsgen.NewInMemoryGenerator(log), controllerutil.SetControllerReference)
+	generators, err := NewGeneratorRegistry(log, backends)
+	if err != nil {
+		return errors.Wrap(err, "Setting up credential generators for quarks secret controller failed.")
+	}
+	r := NewQuarksSecretReconciler(ctx, config, mgr, generators, controllerutil.SetControllerReference, renewalFraction)
 
 	// Create a new controller
 	c, err := controller.New("quarks-secret-controller", mgr, controller.Options{
@@ -46,7 +58,8 @@ func AddQuarksSecret(ctx context.Context, config *config.Config, mgr manager.Man
 			if err != nil {
 				ctxlog.Errorf(ctx, "Failed to list secrets owned by QuarksSecret '%s': %s in quarksSecret reconciler", o.Name, err)
 			}
-			if len(secrets) == 0 {
+			// Certificates with a known validity are requeued for renewal
+			if _, renew := renewalTime(o, renewalFraction); renew || len(secrets) == 0 {
 				ctxlog.NewPredicateEvent(e.Object).Debug(
 					ctx, e.Meta, "qsv1a1.QuarksSecret",
 					fmt.Sprintf("Create predicate passed for '%s'", e.Meta.GetName()),
@@ -58,8 +71,12 @@ func AddQuarksSecret(ctx context.Context, config *config.Config, mgr manager.Man
 		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
 		GenericFunc: func(e event.GenericEvent) bool { return false },
 		UpdateFunc: func(e event.UpdateEvent) bool {
+			o := e.ObjectOld.(*qsv1a1.QuarksSecret)
 			n := e.ObjectNew.(*qsv1a1.QuarksSecret)
-			if !n.Status.Generated {
+			if !n.Status.Generated ||
+				n.GetDeletionTimestamp() != nil ||
+				!reflect.DeepEqual(o.Status.NotAfter, n.Status.NotAfter) ||
+				!reflect.DeepEqual(o.Spec.Copies, n.Spec.Copies) {
 				ctxlog.NewPredicateEvent(e.ObjectNew).Debug(
 					ctx, e.MetaNew, "qsv1a1.QuarksSecret",
 					fmt.Sprintf("Update predicate passed for '%s'", e.MetaNew.GetName()),
@@ -74,6 +91,78 @@ func AddQuarksSecret(ctx context.Context, config *config.Config, mgr manager.Man
 		return errors.Wrapf(err, "Watching quarks secrets failed in quarksSecret controller.")
 	}
 
+	err = watchCopies(ctx, mgr, c, copyNamespaces)
+	if err != nil {
+		return errors.Wrapf(err, "Watching secret copies failed in quarksSecret controller.")
+	}
+
+	return nil
+}
+
+// watchCopies reconciles the QuarksSecret a copy belongs to, when the copy is
+// changed or deleted, so it's synced from its source again. The manager's
+// cache only contains the watched namespace, so the copies in each copy
+// namespace are watched by an informer restricted to copied secrets.
+func watchCopies(ctx context.Context, mgr manager.Manager, c controller.Controller, copyNamespaces []string) error {
+	if len(copyNamespaces) == 0 {
+		return nil
+	}
+
+	kclient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return errors.Wrap(err, "could not create kubernetes client")
+	}
+
+	p := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return true },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			o := e.ObjectOld.(*corev1.Secret)
+			n := e.ObjectNew.(*corev1.Secret)
+			return !reflect.DeepEqual(o.Data, n.Data)
+		},
+	}
+	h := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			secret, ok := a.Object.(*corev1.Secret)
+			if !ok {
+				return []reconcile.Request{}
+			}
+			namespace, name, ok := copySource(secret)
+			if !ok {
+				return []reconcile.Request{}
+			}
+			request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+			ctxlog.NewMappingEvent(a.Object).Debug(ctx, request, "QuarksSecret", a.Meta.GetName(), "Secret")
+			return []reconcile.Request{request}
+		}),
+	}
+
+	for _, namespace := range copyNamespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(kclient, 0,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = labels.SelectorFromSet(labels.Set{qsv1a1.LabelKind: qsv1a1.CopiedSecretKind}).String()
+			}),
+		)
+		informer := factory.Core().V1().Secrets().Informer()
+
+		err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			factory.Start(stop)
+			<-stop
+			return nil
+		}))
+		if err != nil {
+			return errors.Wrapf(err, "could not add informer for namespace '%s' to manager", namespace)
+		}
+
+		err = c.Watch(&source.Informer{Informer: informer}, h, p)
+		if err != nil {
+			return errors.Wrapf(err, "could not watch secret copies in namespace '%s'", namespace)
+		}
+	}
+
 	return nil
 }
 
@@ -3,6 +3,7 @@ package quarkssecret
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -30,25 +31,31 @@ import (
 type setReferenceFunc func(owner, object metav1.Object, scheme *runtime.Scheme) error
 
 // NewQuarksSecretReconciler returns a new ReconcileQuarksSecret
-func NewQuarksSecretReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, generator credsgen.Generator, srf setReferenceFunc) reconcile.Reconciler {
+func NewQuarksSecretReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, generators *credsgen.Registry, srf setReferenceFunc, renewalFraction float64) reconcile.Reconciler {
 	return &ReconcileQuarksSecret{
-		ctx:          ctx,
-		config:       config,
-		client:       mgr.GetClient(),
-		scheme:       mgr.GetScheme(),
-		generator:    generator,
-		setReference: srf,
+		ctx:             ctx,
+		config:          config,
+		client:          mgr.GetClient(),
+		apiReader:       mgr.GetAPIReader(),
+		scheme:          mgr.GetScheme(),
+		generators:      generators,
+		setReference:    srf,
+		dhParams:        newDHParamsGenerations(maxDHParamsGenerations),
+		renewalFraction: renewalFraction,
 	}
 }
 
 // ReconcileQuarksSecret reconciles an QuarksSecret object
 type ReconcileQuarksSecret struct {
-	ctx          context.Context
-	client       client.Client
-	generator    credsgen.Generator
-	scheme       *runtime.Scheme
-	setReference setReferenceFunc
-	config       *config.Config
+	ctx             context.Context
+	client          client.Client
+	apiReader       client.Reader
+	generators      *credsgen.Registry
+	scheme          *runtime.Scheme
+	setReference    setReferenceFunc
+	config          *config.Config
+	dhParams        *dhParamsGenerations
+	renewalFraction float64
 }
 
 type caNotReadyError struct {
@@ -98,49 +105,106 @@ func (r *ReconcileQuarksSecret) Reconcile(request reconcile.Request) (reconcile.
 		return reconcile.Result{}, errors.Wrap(err, "Error reading quarksSecret")
 	}
 
+	if instance.GetDeletionTimestamp() != nil {
+		return reconcile.Result{}, r.deleteCopies(ctx, instance)
+	}
+
 	if meltdown.NewWindow(r.config.MeltdownDuration, instance.Status.LastReconcile).Contains(time.Now()) {
 		ctxlog.WithEvent(instance, "Meltdown").Debugf(ctx, "Resource '%s' is in meltdown, requeue reconcile after %s", instance.Name, r.config.MeltdownRequeueAfter)
 		return reconcile.Result{RequeueAfter: r.config.MeltdownRequeueAfter}, nil
 	}
 
+	// Certificates are regenerated after a fraction of their lifetime
+	if instance.Status.Generated {
+		if renewAt, ok := renewalTime(instance, r.renewalFraction); ok {
+			if time.Now().Before(renewAt) {
+				ctxlog.Debugf(ctx, "Certificate of QuarksSecret '%s' will be renewed at %s", instance.Name, renewAt)
+				return renewalResult(instance, r.renewalFraction), r.copySecrets(ctx, instance)
+			}
+			ctxlog.WithEvent(instance, "RenewCertificate").Infof(ctx, "Renewing certificate of QuarksSecret '%s', it expires at %s", instance.Name, instance.Status.NotAfter)
+			instance.Status.Generated = false
+		}
+	}
+
 	// Check if allowed to generate secret, could be already done or
 	// created manually by a user
-	skipReconcile, err := r.skipReconcile(ctx, instance)
+	skipReconcile, regenerate, err := r.skipReconcile(ctx, instance)
 	if err != nil {
 		ctxlog.Errorf(ctx, "Error reading the secret: %v", err.Error())
 		return reconcile.Result{}, err
 	}
 	if skipReconcile {
 		ctxlog.WithEvent(instance, "SkipReconcile").Infof(ctx, "Skip reconcile: quarksSecret '%s' is already generated", instance.Name)
-		return reconcile.Result{}, nil
+		return reconcile.Result{}, r.copySecrets(ctx, instance)
+	}
+
+	generator, err := r.generators.Generator(backendName(instance))
+	if err != nil {
+		return reconcile.Result{}, ctxlog.WithEvent(instance, "InvalidBackendError").Errorf(ctx, "Invalid credential generator backend for QuarksSecret '%s': %s", instance.Name, err)
+	}
+	// Rotated and renewed secrets must not get the credentials a store kept
+	if storing, ok := generator.(credsgen.StoringGenerator); ok && regenerate {
+		generator = storing.Regenerating()
 	}
 
 	// Create secret
 	switch instance.Spec.Type {
 	case qsv1a1.Password:
 		ctxlog.Info(ctx, "Generating password")
-		err = r.createPasswordSecret(ctx, instance)
+		err = r.createPasswordSecret(ctx, generator, instance)
 		if err != nil {
 			ctxlog.Infof(ctx, "Error generating password secret: %s", err.Error())
 			return reconcile.Result{}, errors.Wrap(err, "generating password secret failed.")
 		}
 	case qsv1a1.RSAKey:
 		ctxlog.Info(ctx, "Generating RSA Key")
-		err = r.createRSASecret(ctx, instance)
+		err = r.createRSASecret(ctx, generator, instance)
 		if err != nil {
 			ctxlog.Infof(ctx, "Error generating RSA key secret: %s", err.Error())
 			return reconcile.Result{}, errors.Wrap(err, "generating RSA key secret failed.")
 		}
 	case qsv1a1.SSHKey:
 		ctxlog.Info(ctx, "Generating SSH Key")
-		err = r.createSSHSecret(ctx, instance)
+		err = r.createSSHSecret(ctx, generator, instance)
 		if err != nil {
 			ctxlog.Infof(ctx, "Error generating SSH key secret: %s", err.Error())
 			return reconcile.Result{}, errors.Wrap(err, "generating SSH key secret failed.")
 		}
+	case qsv1a1.User:
+		ctxlog.Info(ctx, "Generating user")
+		err = r.createUserSecret(ctx, generator, instance)
+		if err != nil {
+			ctxlog.Infof(ctx, "Error generating user secret: %s", err.Error())
+			return reconcile.Result{}, errors.Wrap(err, "generating user secret failed.")
+		}
+	case qsv1a1.HTPasswd:
+		ctxlog.Info(ctx, "Generating htpasswd")
+		err = r.createHTPasswdSecret(ctx, generator, instance)
+		if err != nil {
+			ctxlog.Infof(ctx, "Error generating htpasswd secret: %s", err.Error())
+			return reconcile.Result{}, errors.Wrap(err, "generating htpasswd secret failed.")
+		}
+	case qsv1a1.DHParams:
+		var params []byte
+		var done bool
+		params, done, err = r.dhParams.result(request.NamespacedName.String(), generator, credentialName(instance), instance.Spec.Request.DHParamsRequest.Bits)
+		if err != nil {
+			ctxlog.Infof(ctx, "Error generating DH parameters: %s", err.Error())
+			return reconcile.Result{}, errors.Wrap(err, "generating DH parameters failed.")
+		}
+		if !done {
+			ctxlog.Infof(ctx, "Generating DH parameters for QuarksSecret '%s' in the background", instance.Name)
+			return reconcile.Result{RequeueAfter: dhParamsRequeueAfter}, nil
+		}
+
+		err = r.createDHParamsSecret(ctx, instance, params)
+		if err != nil {
+			ctxlog.Infof(ctx, "Error generating DH parameters secret: %s", err.Error())
+			return reconcile.Result{}, errors.Wrap(err, "generating DH parameters secret failed.")
+		}
 	case qsv1a1.Certificate:
 		ctxlog.Info(ctx, "Generating certificate")
-		err = r.createCertificateSecret(ctx, instance)
+		err = r.createCertificateSecret(ctx, generator, instance)
 		if err != nil {
 			if isCaNotReady(err) {
 				ctxlog.Info(ctx, fmt.Sprintf("CA for secret '%s' is not ready yet: %s", instance.Name, err))
@@ -154,7 +218,70 @@ func (r *ReconcileQuarksSecret) Reconcile(request reconcile.Request) (reconcile.
 		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{}, r.updateStatus(ctx, instance)
+	err = r.updateStatus(ctx, instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	return renewalResult(instance, r.renewalFraction), r.copySecrets(ctx, instance)
+}
+
+// copySecrets updates the copies of the generated secret in other namespaces.
+// The QuarksSecret gets a finalizer as long as it has copies, so they are
+// deleted along with it.
+func (r *ReconcileQuarksSecret) copySecrets(ctx context.Context, instance *qsv1a1.QuarksSecret) error {
+	if len(instance.Spec.Copies) > 0 && !hasCopiesFinalizer(instance) {
+		instance.SetFinalizers(append(instance.GetFinalizers(), qsv1a1.FinalizerSecretCopies))
+		err := r.client.Update(ctx, instance)
+		if err != nil {
+			return ctxlog.WithEvent(instance, "CopySecretError").Errorf(ctx, "Failed to add finalizer to QuarksSecret '%s': %s", instance.Name, err)
+		}
+	}
+
+	err := copySecrets(ctx, r.client, r.apiReader, instance)
+	if err != nil {
+		return ctxlog.WithEvent(instance, "CopySecretError").Errorf(ctx, "Failed to copy secret of QuarksSecret '%s': %s", instance.Name, err)
+	}
+
+	// Recorded copies are deleted once they are removed from the spec
+	copies := desiredCopies(instance)
+	if !reflect.DeepEqual(instance.Status.Copies, copies) {
+		instance.Status.Copies = copies
+		err := r.client.Status().Update(ctx, instance)
+		if err != nil {
+			return ctxlog.WithEvent(instance, "CopySecretError").Errorf(ctx, "Failed to record copies of QuarksSecret '%s': %s", instance.Name, err)
+		}
+	}
+
+	if len(instance.Spec.Copies) == 0 && hasCopiesFinalizer(instance) {
+		removeCopiesFinalizer(instance)
+		err := r.client.Update(ctx, instance)
+		if err != nil {
+			return ctxlog.WithEvent(instance, "CopySecretError").Errorf(ctx, "Failed to remove finalizer from QuarksSecret '%s': %s", instance.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteCopies deletes the copies of a deleted QuarksSecret and removes its
+// finalizer
+func (r *ReconcileQuarksSecret) deleteCopies(ctx context.Context, instance *qsv1a1.QuarksSecret) error {
+	if !hasCopiesFinalizer(instance) {
+		return nil
+	}
+
+	ctxlog.Infof(ctx, "Deleting copies of QuarksSecret '%s'", instance.Name)
+	err := deleteCopies(ctx, r.client, r.apiReader, instance)
+	if err != nil {
+		return ctxlog.WithEvent(instance, "CopySecretError").Errorf(ctx, "Failed to delete copies of QuarksSecret '%s': %s", instance.Name, err)
+	}
+
+	removeCopiesFinalizer(instance)
+	err = r.client.Update(ctx, instance)
+	if err != nil {
+		return ctxlog.WithEvent(instance, "CopySecretError").Errorf(ctx, "Failed to remove finalizer from QuarksSecret '%s': %s", instance.Name, err)
+	}
+	return nil
 }
 
 func (r *ReconcileQuarksSecret) updateStatus(ctx context.Context, instance *qsv1a1.QuarksSecret) error {
@@ -170,9 +297,11 @@ func (r *ReconcileQuarksSecret) updateStatus(ctx context.Context, instance *qsv1
 	return nil
 }
 
-func (r *ReconcileQuarksSecret) createPasswordSecret(ctx context.Context, instance *qsv1a1.QuarksSecret) error {
-	request := credsgen.PasswordGenerationRequest{}
-	password := r.generator.GeneratePassword(instance.GetName(), request)
+func (r *ReconcileQuarksSecret) createPasswordSecret(ctx context.Context, generator credsgen.Generator, instance *qsv1a1.QuarksSecret) error {
+	password, err := generator.GeneratePassword(credentialName(instance), passwordGenerationRequest(instance))
+	if err != nil {
+		return err
+	}
 
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -187,8 +316,98 @@ func (r *ReconcileQuarksSecret) createPasswordSecret(ctx context.Context, instan
 	return r.createSecret(ctx, instance, secret)
 }
 
-func (r *ReconcileQuarksSecret) createRSASecret(ctx context.Context, instance *qsv1a1.QuarksSecret) error {
-	key, err := r.generator.GenerateRSAKey(instance.GetName())
+// passwordGenerationRequest returns the requested password policy
+func passwordGenerationRequest(instance *qsv1a1.QuarksSecret) credsgen.PasswordGenerationRequest {
+	policy := instance.Spec.Request.PasswordRequest
+	return credsgen.PasswordGenerationRequest{
+		Length:            policy.Length,
+		ExcludeUpper:      policy.ExcludeUpper,
+		ExcludeLower:      policy.ExcludeLower,
+		ExcludeNumber:     policy.ExcludeNumber,
+		IncludeSpecial:    policy.IncludeSpecial,
+		RequireEachClass:  policy.RequireEachClass,
+		MinSpecial:        policy.MinSpecial,
+		ExcludeCharacters: policy.ExcludeCharacters,
+		Passphrase:        policy.Passphrase,
+		Words:             policy.Words,
+		Separator:         policy.Separator,
+	}
+}
+
+// userGenerationRequest returns the requested username and password policy
+func userGenerationRequest(instance *qsv1a1.QuarksSecret) credsgen.UserGenerationRequest {
+	return credsgen.UserGenerationRequest{
+		Username: instance.Spec.Request.UserRequest.Username,
+		Password: passwordGenerationRequest(instance),
+	}
+}
+
+func (r *ReconcileQuarksSecret) createUserSecret(ctx context.Context, generator credsgen.Generator, instance *qsv1a1.QuarksSecret) error {
+	user, err := generator.GenerateUser(credentialName(instance), userGenerationRequest(instance))
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.Spec.SecretName,
+			Namespace: instance.GetNamespace(),
+		},
+		StringData: map[string]string{
+			"username": user.Username,
+			"password": user.Password,
+		},
+	}
+
+	return r.createSecret(ctx, instance, secret)
+}
+
+func (r *ReconcileQuarksSecret) createHTPasswdSecret(ctx context.Context, generator credsgen.Generator, instance *qsv1a1.QuarksSecret) error {
+	htpasswd, err := generator.GenerateHTPasswd(credentialName(instance), userGenerationRequest(instance))
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.Spec.SecretName,
+			Namespace: instance.GetNamespace(),
+		},
+		StringData: map[string]string{
+			"username": htpasswd.Username,
+			"password": htpasswd.Password,
+			"auth":     htpasswd.Entry,
+		},
+	}
+
+	return r.createSecret(ctx, instance, secret)
+}
+
+func (r *ReconcileQuarksSecret) createDHParamsSecret(ctx context.Context, instance *qsv1a1.QuarksSecret, params []byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.Spec.SecretName,
+			Namespace: instance.GetNamespace(),
+		},
+		StringData: map[string]string{
+			"dhparams": string(params),
+		},
+	}
+
+	return r.createSecret(ctx, instance, secret)
+}
+
+// keyGenerationRequest returns the requested key algorithm, empty fields
+// select the generator's defaults
+func keyGenerationRequest(instance *qsv1a1.QuarksSecret) credsgen.KeyGenerationRequest {
+	return credsgen.KeyGenerationRequest{
+		Algorithm: instance.Spec.Request.KeyRequest.Algorithm,
+		Bits:      instance.Spec.Request.KeyRequest.Bits,
+	}
+}
+
+func (r *ReconcileQuarksSecret) createRSASecret(ctx context.Context, generator credsgen.Generator, instance *qsv1a1.QuarksSecret) error {
+	key, err := generator.GenerateRSAKey(credentialName(instance), keyGenerationRequest(instance))
 	if err != nil {
 		return err
 	}
@@ -207,8 +426,8 @@ func (r *ReconcileQuarksSecret) createRSASecret(ctx context.Context, instance *q
 	return r.createSecret(ctx, instance, secret)
 }
 
-func (r *ReconcileQuarksSecret) createSSHSecret(ctx context.Context, instance *qsv1a1.QuarksSecret) error {
-	key, err := r.generator.GenerateSSHKey(instance.GetName())
+func (r *ReconcileQuarksSecret) createSSHSecret(ctx context.Context, generator credsgen.Generator, instance *qsv1a1.QuarksSecret) error {
+	key, err := generator.GenerateSSHKey(credentialName(instance), keyGenerationRequest(instance))
 	if err != nil {
 		return err
 	}
@@ -227,14 +446,28 @@ func (r *ReconcileQuarksSecret) createSSHSecret(ctx context.Context, instance *q
 	return r.createSecret(ctx, instance, secret)
 }
 
-func (r *ReconcileQuarksSecret) createCertificateSecret(ctx context.Context, instance *qsv1a1.QuarksSecret) error {
+// serviceAlternativeNames returns the names and IPs a service referenced by
+// a certificate request adds to the certificate's alternative names
+func serviceAlternativeNames(service *corev1.Service) []string {
+	return append([]string{
+		service.Name,
+		service.Name + "." + service.Namespace,
+		"*." + service.Name,
+		"*." + service.Name + "." + service.Namespace,
+		service.Spec.ClusterIP,
+		service.Spec.LoadBalancerIP,
+		service.Spec.ExternalName,
+	}, service.Spec.ExternalIPs...)
+}
+
+func (r *ReconcileQuarksSecret) createCertificateSecret(ctx context.Context, generator credsgen.Generator, instance *qsv1a1.QuarksSecret) error {
 
 	serviceIPForEKSWorkaround := ""
 
 	for _, serviceRef := range instance.Spec.Request.CertificateRequest.ServiceRef {
 		service := &corev1.Service{}
 
-		err := r.client.Get(ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: serviceRef.Name}, service)
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: instance.GetNamespace(), Name: serviceRef.Name}, service)
 
 		if err != nil {
 			return errors.Wrapf(err, "Failed to get service reference '%s' for QuarksSecret '%s'", serviceRef.Name, instance.Name)
@@ -244,26 +477,24 @@ func (r *ReconcileQuarksSecret) createCertificateSecret(ctx context.Context, ins
 			serviceIPForEKSWorkaround = service.Spec.ClusterIP
 		}
 
-		instance.Spec.Request.CertificateRequest.AlternativeNames = append(append(
+		instance.Spec.Request.CertificateRequest.AlternativeNames = append(
 			instance.Spec.Request.CertificateRequest.AlternativeNames,
-			service.Name,
-			service.Name+"."+service.Namespace,
-			"*."+service.Name,
-			"*."+service.Name+"."+service.Namespace,
-			service.Spec.ClusterIP,
-			service.Spec.LoadBalancerIP,
-			service.Spec.ExternalName,
-		), service.Spec.ExternalIPs...)
+			serviceAlternativeNames(service)...,
+		)
 	}
 
 	if len(instance.Spec.Request.CertificateRequest.SignerType) == 0 {
 		instance.Spec.Request.CertificateRequest.SignerType = qsv1a1.LocalSigner
 	}
 
-	generationRequest, err := r.generateCertificateGenerationRequest(ctx, instance.Namespace, instance.Spec.Request.CertificateRequest)
+	generationRequest, err := r.generateCertificateGenerationRequest(ctx, instance.Namespace, r.backend(instance), instance.Spec.Request.CertificateRequest)
 	if err != nil {
 		return errors.Wrap(err, "generating certificate generation request")
 	}
+	generationRequest.Key = keyGenerationRequest(instance)
+	if duration := instance.Spec.Request.CertificateRequest.Duration; duration != nil {
+		generationRequest.Duration = duration.Duration
+	}
 
 	switch instance.Spec.Request.CertificateRequest.SignerType {
 	case qsv1a1.ClusterSigner:
@@ -277,8 +508,12 @@ func (r *ReconcileQuarksSecret) createCertificateSecret(ctx context.Context, ins
 			generationRequest.CommonName = serviceIPForEKSWorkaround
 		}
 
+		// The validity is known once the certificate is issued
+		instance.Status.NotBefore = nil
+		instance.Status.NotAfter = nil
+
 		ctxlog.Info(ctx, "Generating certificate signing request and its key")
-		csr, key, err := r.generator.GenerateCertificateSigningRequest(generationRequest)
+		csr, key, err := generator.GenerateCertificateSigningRequest(generationRequest)
 		if err != nil {
 			return err
 		}
@@ -303,7 +538,7 @@ func (r *ReconcileQuarksSecret) createCertificateSecret(ctx context.Context, ins
 		return r.createCertificateSigningRequest(ctx, instance, csr)
 	case qsv1a1.LocalSigner:
 		// Generate certificate
-		cert, err := r.generator.GenerateCertificate(instance.GetName(), generationRequest)
+		cert, err := generator.GenerateCertificate(credentialName(instance), generationRequest)
 		if err != nil {
 			return err
 		}
@@ -320,10 +555,38 @@ func (r *ReconcileQuarksSecret) createCertificateSecret(ctx context.Context, ins
 		}
 
 		if len(generationRequest.CA.Certificate) > 0 {
-			secret.StringData["ca"] = string(generationRequest.CA.Certificate)
+			ca, err := r.caBundle(ctx, instance.Namespace, instance.Spec.Request.CertificateRequest.CARef.Name, generationRequest.CA.Certificate)
+			if err != nil {
+				return err
+			}
+			secret.StringData["ca"] = string(ca)
+		}
+
+		// A renewed CA keeps trusting the previous CA, which signed the
+		// certificates of its dependants until they are regenerated
+		if instance.Spec.Request.CertificateRequest.IsCA {
+			previous, err := r.previousCertificate(ctx, instance)
+			if err != nil {
+				return err
+			}
+			if len(previous) > 0 {
+				secret.StringData["ca"] = string(transitionalCABundle([]byte(secret.StringData["ca"]), cert.Certificate, previous))
+			}
 		}
 
-		return r.createSecret(ctx, instance, secret)
+		if err := setCertificateValidity(&instance.Status, cert.Certificate); err != nil {
+			ctxlog.Errorf(ctx, "Could not read validity of certificate for QuarksSecret '%s', it won't be renewed: %s", instance.Name, err)
+		}
+
+		err = r.createSecret(ctx, instance, secret)
+		if err != nil {
+			return err
+		}
+
+		if instance.Spec.Request.CertificateRequest.IsCA {
+			return r.renewDependants(ctx, instance)
+		}
+		return nil
 	default:
 		return fmt.Errorf("unrecognized signer type: %s", instance.Spec.Request.CertificateRequest.SignerType)
 	}
@@ -332,9 +595,10 @@ func (r *ReconcileQuarksSecret) createCertificateSecret(ctx context.Context, ins
 // Skip reconcile when
 // * secret is already generated according to qsecs status field
 // * secret exists, but was not generated (user created secret)
-func (r *ReconcileQuarksSecret) skipReconcile(ctx context.Context, instance *qsv1a1.QuarksSecret) (bool, error) {
+// The second result reports a generated secret, which is regenerated
+func (r *ReconcileQuarksSecret) skipReconcile(ctx context.Context, instance *qsv1a1.QuarksSecret) (bool, bool, error) {
 	if instance.Status.Generated {
-		return true, nil
+		return true, false, nil
 	}
 
 	secretName := instance.Spec.SecretName
@@ -343,9 +607,9 @@ func (r *ReconcileQuarksSecret) skipReconcile(ctx context.Context, instance *qsv
 	err := r.client.Get(ctx, types.NamespacedName{Name: secretName, Namespace: instance.GetNamespace()}, existingSecret)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			return false, nil
+			return false, false, nil
 		}
-		return false, errors.Wrapf(err, "could not get secret")
+		return false, false, errors.Wrapf(err, "could not get secret")
 	}
 
 	secretLabels := existingSecret.GetLabels()
@@ -355,10 +619,17 @@ func (r *ReconcileQuarksSecret) skipReconcile(ctx context.Context, instance *qsv
 
 	// skip the user generated secret
 	if secretLabels[qsv1a1.LabelKind] != qsv1a1.GeneratedSecretKind {
-		return true, nil
+		return true, false, nil
 	}
 
-	return false, nil
+	// the generated secret is rotated or renewed
+	return false, true, nil
+}
+
+// credentialName identifies the credentials of the QuarksSecret across
+// namespaces
+func credentialName(instance *qsv1a1.QuarksSecret) string {
+	return types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}.String()
 }
 
 // createSecret applies common properties(labels and ownerReferences) to the secret and creates it
@@ -374,6 +645,15 @@ func (r *ReconcileQuarksSecret) createSecret(ctx context.Context, instance *qsv1
 
 	secret.SetLabels(secretLabels)
 
+	// Record the backend, certificates can only be signed by a CA of the
+	// external backend, if they are generated by it, too
+	secretAnnotations := secret.GetAnnotations()
+	if secretAnnotations == nil {
+		secretAnnotations = map[string]string{}
+	}
+	secretAnnotations[qsv1a1.AnnotationCredsgenBackend] = r.backend(instance)
+	secret.SetAnnotations(secretAnnotations)
+
 	if err := r.setReference(instance, secret, r.scheme); err != nil {
 		return errors.Wrapf(err, "error setting owner for secret '%s' to QuarksSecret '%s' in namespace '%s'", secret.GetName(), instance.GetName(), instance.GetNamespace())
 	}
@@ -390,8 +670,17 @@ func (r *ReconcileQuarksSecret) createSecret(ctx context.Context, instance *qsv1
 	return nil
 }
 
+// backend returns the name of the generator backend of the QuarksSecret,
+// which is the operator's default backend, if it doesn't select one
+func (r *ReconcileQuarksSecret) backend(instance *qsv1a1.QuarksSecret) string {
+	if name := backendName(instance); name != "" {
+		return name
+	}
+	return r.generators.Default()
+}
+
 // generateCertificateGenerationRequest generates CertificateGenerationRequest for certificate
-func (r *ReconcileQuarksSecret) generateCertificateGenerationRequest(ctx context.Context, namespace string, certificateRequest qsv1a1.CertificateRequest) (credsgen.CertificateGenerationRequest, error) {
+func (r *ReconcileQuarksSecret) generateCertificateGenerationRequest(ctx context.Context, namespace string, backend string, certificateRequest qsv1a1.CertificateRequest) (credsgen.CertificateGenerationRequest, error) {
 	var request credsgen.CertificateGenerationRequest
 	switch certificateRequest.SignerType {
 	case qsv1a1.ClusterSigner:
@@ -424,6 +713,12 @@ func (r *ReconcileQuarksSecret) generateCertificateGenerationRequest(ctx context
 			}
 			ca := caSecret.Data[certificateRequest.CARef.Key]
 
+			// The external store only gets the CA certificate and signs with
+			// the key it keeps, so it can't sign with CAs of other backends
+			if backend == credsgen.ExternalBackend && caSecret.GetAnnotations()[qsv1a1.AnnotationCredsgenBackend] != credsgen.ExternalBackend {
+				return request, errors.Errorf("CA secret '%s' wasn't generated by the '%s' backend, which can only sign with the CAs it generated", certificateRequest.CARef.Name, credsgen.ExternalBackend)
+			}
+
 			// Get CA key
 			if certificateRequest.CAKeyRef.Name != certificateRequest.CARef.Name {
 				caSecret = &corev1.Secret{}
@@ -464,6 +759,7 @@ func (r *ReconcileQuarksSecret) createCertificateSigningRequest(ctx context.Cont
 	}
 	annotations[qsv1a1.AnnotationCertSecretName] = instance.Spec.SecretName
 	annotations[qsv1a1.AnnotationQSecNamespace] = instance.Namespace
+	annotations[qsv1a1.AnnotationQSecName] = instance.Name
 
 	csrObj := &certv1.CertificateSigningRequest{
 		ObjectMeta: metav1.ObjectMeta{
@@ -478,7 +774,8 @@ func (r *ReconcileQuarksSecret) createCertificateSigningRequest(ctx context.Cont
 	}
 
 	// CSR spec is immutable after the request is created
-	err := r.client.Get(ctx, types.NamespacedName{Name: csrObj.Name}, instance)
+	existing := &certv1.CertificateSigningRequest{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: csrObj.Name}, existing)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			err = r.client.Create(ctx, csrObj)
@@ -490,5 +787,19 @@ func (r *ReconcileQuarksSecret) createCertificateSigningRequest(ctx context.Cont
 		return errors.Wrapf(err, "could not get certificatesigningrequest '%s'", csrObj.Name)
 	}
 
+	// A certificate was already issued for the old request, replace it to
+	// renew the certificate. Denied requests are replaced, too.
+	if len(existing.Status.Certificate) > 0 || isDenied(existing.Status.Conditions) {
+		ctxlog.Debugf(ctx, "Replacing issued certificatesigningrequest '%s'", csrObj.Name)
+		err = r.client.Delete(ctx, existing)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "could not delete certificatesigningrequest '%s'", csrObj.Name)
+		}
+		err = r.client.Create(ctx, csrObj)
+		if err != nil {
+			return errors.Wrapf(err, "could not create certificatesigningrequest '%s'", csrObj.Name)
+		}
+	}
+
 	return nil
 }
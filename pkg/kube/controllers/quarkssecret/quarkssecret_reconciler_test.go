@@ -2,6 +2,8 @@ package quarkssecret_test
 
 import (
 	"context"
+	"encoding/pem"
+	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -19,6 +21,7 @@ import (
 
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
 	generatorfakes "code.cloudfoundry.org/cf-operator/pkg/credsgen/fakes"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
 	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned/scheme"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
@@ -40,6 +43,7 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 		client           *cfakes.FakeClient
 		generator        *generatorfakes.FakeGenerator
 		qSecret          *qsv1a1.QuarksSecret
+		renewalFraction  float64
 		setReferenceFunc func(owner, object metav1.Object, scheme *runtime.Scheme) error = func(owner, object metav1.Object, scheme *runtime.Scheme) error { return nil }
 	)
 
@@ -48,6 +52,7 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 		manager = &cfakes.FakeManager{}
 		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
 		config = &cfcfg.Config{CtxTimeOut: 10 * time.Second}
+		renewalFraction = qscontroller.DefaultRenewalFraction
 		_, log = helper.NewTestLogger()
 		ctx = ctxlog.NewParentContext(log)
 		qSecret = &qsv1a1.QuarksSecret{
@@ -76,7 +81,7 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 	})
 
 	JustBeforeEach(func() {
-		reconciler = qscontroller.NewQuarksSecretReconciler(ctx, config, manager, generator, setReferenceFunc)
+		reconciler = qscontroller.NewQuarksSecretReconciler(ctx, config, manager, credsgen.NewRegistry(credsgen.InMemoryBackend, generator), setReferenceFunc, renewalFraction)
 	})
 
 	Context("if the resource can not be resolved", func() {
@@ -100,9 +105,121 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 		})
 	})
 
+	Context("when selecting a generator backend", func() {
+		var (
+			registry *credsgen.Registry
+			external *generatorfakes.FakeGenerator
+		)
+
+		BeforeEach(func() {
+			generator.GeneratePasswordReturns("in-memory-password", nil)
+			external = &generatorfakes.FakeGenerator{}
+			external.GeneratePasswordReturns("external-password", nil)
+			registry = credsgen.NewRegistry(credsgen.InMemoryBackend, generator)
+			registry.Register(credsgen.ExternalBackend, external)
+		})
+
+		JustBeforeEach(func() {
+			reconciler = qscontroller.NewQuarksSecretReconciler(ctx, config, manager, registry, setReferenceFunc, renewalFraction)
+		})
+
+		passwordOf := func() string {
+			Expect(client.CreateCallCount()).To(Equal(1))
+			_, object, _ := client.CreateArgsForCall(0)
+			return object.(*corev1.Secret).StringData["password"]
+		}
+
+		It("uses the default backend", func() {
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(passwordOf()).To(Equal("in-memory-password"))
+		})
+
+		It("uses the operator's default backend", func() {
+			Expect(registry.SetDefault(credsgen.ExternalBackend)).To(Succeed())
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(passwordOf()).To(Equal("external-password"))
+		})
+
+		It("uses the backend from the annotation", func() {
+			qSecret.Annotations = map[string]string{qsv1a1.AnnotationCredsgenBackend: credsgen.ExternalBackend}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(passwordOf()).To(Equal("external-password"))
+		})
+
+		It("prefers the backend from the spec over the annotation", func() {
+			qSecret.Annotations = map[string]string{qsv1a1.AnnotationCredsgenBackend: credsgen.ExternalBackend}
+			qSecret.Spec.Backend = credsgen.InMemoryBackend
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(passwordOf()).To(Equal("in-memory-password"))
+		})
+
+		It("fails for unknown backends", func() {
+			qSecret.Spec.Backend = "vault"
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unknown credential generator backend 'vault'"))
+			Expect(client.CreateCallCount()).To(Equal(0))
+		})
+
+		It("fails if the backend can't generate the credential", func() {
+			external.GeneratePasswordReturns("", fmt.Errorf("store unavailable"))
+			qSecret.Spec.Backend = credsgen.ExternalBackend
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("store unavailable"))
+			Expect(client.CreateCallCount()).To(Equal(0))
+		})
+
+		Context("when the backend stores the credentials", func() {
+			var regenerating *generatorfakes.FakeGenerator
+
+			BeforeEach(func() {
+				regenerating = &generatorfakes.FakeGenerator{}
+				regenerating.GeneratePasswordReturns("regenerated-password", nil)
+				registry.Register(credsgen.ExternalBackend, storingGenerator{FakeGenerator: external, regenerating: regenerating})
+				qSecret.Spec.Backend = credsgen.ExternalBackend
+			})
+
+			It("requests the stored credential for new secrets", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(passwordOf()).To(Equal("external-password"))
+			})
+
+			It("requests a new credential when the generated secret is rotated", func() {
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					switch object := object.(type) {
+					case *qsv1a1.QuarksSecret:
+						qSecret.DeepCopyInto(object)
+					case *corev1.Secret:
+						object.Name = nn.Name
+						object.Namespace = nn.Namespace
+						object.Labels = map[string]string{qsv1a1.LabelKind: qsv1a1.GeneratedSecretKind}
+					}
+					return nil
+				})
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(external.GeneratePasswordCallCount()).To(Equal(0))
+				name, _ := regenerating.GeneratePasswordArgsForCall(0)
+				Expect(name).To(Equal("default/foo"))
+			})
+		})
+	})
+
 	Context("when generating passwords", func() {
 		BeforeEach(func() {
-			generator.GeneratePasswordReturns("securepassword")
+			generator.GeneratePasswordReturns("securepassword", nil)
 		})
 
 		It("skips reconciling if the secret was already generated", func() {
@@ -128,6 +245,29 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 			Expect(client.CreateCallCount()).To(Equal(1))
 			Expect(reconcile.Result{}).To(Equal(result))
 		})
+
+		It("passes the password policy to the generator", func() {
+			qSecret.Spec.Request.PasswordRequest = qsv1a1.PasswordRequest{
+				Length:           16,
+				IncludeSpecial:   true,
+				RequireEachClass: true,
+				Passphrase:       true,
+				Words:            4,
+			}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+
+			name, passwordRequest := generator.GeneratePasswordArgsForCall(0)
+			Expect(name).To(Equal("default/foo"))
+			Expect(passwordRequest).To(Equal(credsgen.PasswordGenerationRequest{
+				Length:           16,
+				IncludeSpecial:   true,
+				RequireEachClass: true,
+				Passphrase:       true,
+				Words:            4,
+			}))
+		})
 	})
 
 	Context("when generating RSA keys", func() {
@@ -181,6 +321,109 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 			Expect(client.CreateCallCount()).To(Equal(1))
 			Expect(reconcile.Result{}).To(Equal(result))
 		})
+
+		It("passes the key algorithm to the generator", func() {
+			qSecret.Spec.Request.KeyRequest = qsv1a1.KeyRequest{Algorithm: credsgen.Ed25519KeyAlgorithm}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, keyRequest := generator.GenerateSSHKeyArgsForCall(0)
+			Expect(keyRequest).To(Equal(credsgen.KeyGenerationRequest{Algorithm: credsgen.Ed25519KeyAlgorithm}))
+		})
+	})
+
+	Context("when generating users", func() {
+		BeforeEach(func() {
+			qSecret.Spec.Type = "user"
+			qSecret.Spec.Request.UserRequest.Username = "admin"
+			qSecret.Spec.Request.PasswordRequest.Length = 20
+
+			generator.GenerateUserReturns(credsgen.User{Username: "admin", Password: "secret"}, nil)
+		})
+
+		It("generates the username and password", func() {
+			client.CreateCalls(func(context context.Context, object runtime.Object, _ ...crc.CreateOption) error {
+				secret := object.(*corev1.Secret)
+				Expect(secret.StringData).To(Equal(map[string]string{"username": "admin", "password": "secret"}))
+				Expect(secret.GetName()).To(Equal("generated-secret"))
+				return nil
+			})
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(1))
+
+			_, userRequest := generator.GenerateUserArgsForCall(0)
+			Expect(userRequest.Username).To(Equal("admin"))
+			Expect(userRequest.Password.Length).To(Equal(20))
+		})
+	})
+
+	Context("when generating htpasswd entries", func() {
+		BeforeEach(func() {
+			qSecret.Spec.Type = "htpasswd"
+
+			generator.GenerateHTPasswdReturns(credsgen.HTPasswd{Username: "admin", Password: "secret", Entry: "admin:hash"}, nil)
+		})
+
+		It("stores the entry along with the user", func() {
+			client.CreateCalls(func(context context.Context, object runtime.Object, _ ...crc.CreateOption) error {
+				secret := object.(*corev1.Secret)
+				Expect(secret.StringData).To(Equal(map[string]string{"username": "admin", "password": "secret", "auth": "admin:hash"}))
+				return nil
+			})
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when generating DH parameters", func() {
+		BeforeEach(func() {
+			qSecret.Spec.Type = "dhparams"
+			qSecret.Spec.Request.DHParamsRequest.Bits = 4096
+
+			generator.GenerateDHParamsReturns([]byte("params"), nil)
+		})
+
+		It("generates DH parameters", func() {
+			client.CreateCalls(func(context context.Context, object runtime.Object, _ ...crc.CreateOption) error {
+				secret := object.(*corev1.Secret)
+				Expect(secret.StringData).To(Equal(map[string]string{"dhparams": "params"}))
+				return nil
+			})
+
+			result, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).ToNot(BeZero())
+			Expect(client.CreateCallCount()).To(Equal(0))
+
+			Eventually(generator.GenerateDHParamsCallCount).Should(Equal(1))
+			_, dhRequest := generator.GenerateDHParamsArgsForCall(0)
+			Expect(dhRequest.Bits).To(Equal(4096))
+
+			Eventually(func() int {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				return client.CreateCallCount()
+			}).Should(Equal(1))
+			Expect(generator.GenerateDHParamsCallCount()).To(Equal(1))
+		})
+
+		It("reports errors of the background generation", func() {
+			generator.GenerateDHParamsReturns(nil, fmt.Errorf("no safe prime"))
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() error {
+				_, err := reconciler.Reconcile(request)
+				return err
+			}).Should(MatchError(ContainSubstring("no safe prime")))
+			Expect(client.CreateCallCount()).To(Equal(0))
+		})
 	})
 
 	Context("when generating certificates", func() {
@@ -203,8 +446,10 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 		})
 
 		Context("if the CA is ready", func() {
+			var ca *corev1.Secret
+
 			BeforeEach(func() {
-				ca := &corev1.Secret{
+				ca = &corev1.Secret{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "mysecret",
 						Namespace: "default",
@@ -244,6 +489,7 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 						Expect(secret.StringData["private_key"]).To(Equal("private_key"))
 						Expect(secret.StringData["ca"]).To(Equal("theca"))
 						Expect(secret.GetLabels()).To(HaveKeyWithValue(qsv1a1.LabelKind, qsv1a1.GeneratedSecretKind))
+						Expect(secret.GetAnnotations()).To(HaveKeyWithValue(qsv1a1.AnnotationCredsgenBackend, credsgen.InMemoryBackend))
 						return nil
 					})
 
@@ -254,10 +500,12 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 				})
 
 				It("considers generation parameters", func() {
+					qSecret.Spec.Request.KeyRequest = qsv1a1.KeyRequest{Algorithm: credsgen.ECDSAKeyAlgorithm, Bits: 384}
 					generator.GenerateCertificateCalls(func(name string, request credsgen.CertificateGenerationRequest) (credsgen.Certificate, error) {
 						Expect(request.IsCA).To(BeFalse())
 						Expect(request.CommonName).To(Equal("foo.com"))
 						Expect(request.AlternativeNames).To(Equal([]string{"bar.com", "baz.com"}))
+						Expect(request.Key).To(Equal(credsgen.KeyGenerationRequest{Algorithm: credsgen.ECDSAKeyAlgorithm, Bits: 384}))
 						return credsgen.Certificate{Certificate: []byte("the_cert"), PrivateKey: []byte("private_key"), IsCA: false}, nil
 					})
 					client.CreateCalls(func(context context.Context, object runtime.Object, _ ...crc.CreateOption) error {
@@ -276,6 +524,42 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 				})
 			})
 
+			Context("and the certificate is generated by the external backend", func() {
+				var external *generatorfakes.FakeGenerator
+
+				BeforeEach(func() {
+					external = &generatorfakes.FakeGenerator{}
+					external.GenerateCertificateReturns(credsgen.Certificate{Certificate: []byte("the_cert")}, nil)
+					qSecret.Spec.Backend = credsgen.ExternalBackend
+				})
+
+				JustBeforeEach(func() {
+					registry := credsgen.NewRegistry(credsgen.InMemoryBackend, generator)
+					registry.Register(credsgen.ExternalBackend, external)
+					reconciler = qscontroller.NewQuarksSecretReconciler(ctx, config, manager, registry, setReferenceFunc, renewalFraction)
+				})
+
+				It("rejects CAs of other backends", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("CA secret 'mysecret' wasn't generated by the 'external' backend"))
+					Expect(external.GenerateCertificateCallCount()).To(Equal(0))
+					Expect(client.CreateCallCount()).To(Equal(0))
+				})
+
+				It("signs with CAs of the external backend", func() {
+					ca.Annotations = map[string]string{qsv1a1.AnnotationCredsgenBackend: credsgen.ExternalBackend}
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(external.GenerateCertificateCallCount()).To(Equal(1))
+					Expect(client.CreateCallCount()).To(Equal(1))
+
+					_, object, _ := client.CreateArgsForCall(0)
+					Expect(object.(*corev1.Secret).GetAnnotations()).To(HaveKeyWithValue(qsv1a1.AnnotationCredsgenBackend, credsgen.ExternalBackend))
+				})
+			})
+
 			Context("and the generated cert is a ca", func() {
 				BeforeEach(func() {
 					qSecret.Spec.Request.CertificateRequest.IsCA = true
@@ -307,6 +591,206 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 		})
 	})
 
+	Context("when renewing certificates", func() {
+		var statusWriter *cfakes.FakeStatusWriter
+
+		validity := func(notBefore, notAfter time.Duration) {
+			from := metav1.NewTime(time.Now().Add(notBefore))
+			until := metav1.NewTime(time.Now().Add(notAfter))
+			qSecret.Status.NotBefore = &from
+			qSecret.Status.NotAfter = &until
+		}
+
+		BeforeEach(func() {
+			qSecret.Spec.Type = "certificate"
+			qSecret.Spec.Request.CertificateRequest.CommonName = "foo.com"
+			qSecret.Status.Generated = true
+
+			statusWriter = &cfakes.FakeStatusWriter{}
+			client.StatusReturns(statusWriter)
+			generator.GenerateCertificateReturns(credsgen.Certificate{Certificate: []byte("the_cert"), PrivateKey: []byte("private_key")}, nil)
+		})
+
+		It("requeues the certificate until it's due for renewal", func() {
+			validity(-time.Hour, 9*time.Hour)
+
+			result, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(0))
+			Expect(result.RequeueAfter).To(BeNumerically("~", 6*time.Hour, time.Minute))
+		})
+
+		It("regenerates the certificate after the renewal fraction of its lifetime", func() {
+			validity(-8*time.Hour, 2*time.Hour)
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generator.GenerateCertificateCallCount()).To(Equal(1))
+			Expect(client.CreateCallCount()).To(Equal(1))
+
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			Expect(object.(*qsv1a1.QuarksSecret).Status.Generated).To(BeTrue())
+		})
+
+		Context("if the renewal is disabled", func() {
+			BeforeEach(func() {
+				renewalFraction = 0
+			})
+
+			It("doesn't renew certificates", func() {
+				validity(-8*time.Hour, 2*time.Hour)
+
+				result, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(0))
+				Expect(result).To(Equal(reconcile.Result{}))
+			})
+		})
+
+		It("records the validity of generated certificates and requeues them", func() {
+			qSecret.Status.Generated = false
+			qSecret.Spec.Request.CertificateRequest.IsCA = true
+			qSecret.Spec.Request.CertificateRequest.Duration = &metav1.Duration{Duration: 10 * time.Hour}
+
+			_, log := helper.NewTestLogger()
+			inMemory := inmemorygenerator.NewInMemoryGenerator(log)
+			inMemory.Algorithm = credsgen.ECDSAKeyAlgorithm
+			inMemory.Bits = 256
+			generator.GenerateCertificateCalls(func(name string, request credsgen.CertificateGenerationRequest) (credsgen.Certificate, error) {
+				Expect(request.Duration).To(Equal(10 * time.Hour))
+				return inMemory.GenerateCertificate(name, request)
+			})
+
+			result, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 6*time.Hour))
+
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			status := object.(*qsv1a1.QuarksSecret).Status
+			Expect(status.NotBefore).ToNot(BeNil())
+			Expect(status.NotAfter.Sub(status.NotBefore.Time)).To(BeNumerically("~", 10*time.Hour, 10*time.Minute))
+		})
+
+		It("regenerates certificates signed by a regenerated CA", func() {
+			qSecret.Status.Generated = false
+			qSecret.Spec.Request.CertificateRequest.IsCA = true
+			client.ListCalls(func(context context.Context, object runtime.Object, _ ...crc.ListOption) error {
+				list := object.(*qsv1a1.QuarksSecretList)
+				list.Items = []qsv1a1.QuarksSecret{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "signed", Namespace: "default"},
+						Spec: qsv1a1.QuarksSecretSpec{
+							Type: "certificate",
+							Request: qsv1a1.Request{CertificateRequest: qsv1a1.CertificateRequest{
+								CARef: qsv1a1.SecretReference{Name: "generated-secret", Key: "certificate"},
+							}},
+						},
+						Status: qsv1a1.QuarksSecretStatus{Generated: true},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+						Spec: qsv1a1.QuarksSecretSpec{
+							Type: "certificate",
+							Request: qsv1a1.Request{CertificateRequest: qsv1a1.CertificateRequest{
+								CARef: qsv1a1.SecretReference{Name: "other-ca", Key: "certificate"},
+							}},
+						},
+						Status: qsv1a1.QuarksSecretStatus{Generated: true},
+					},
+				}
+				return nil
+			})
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statusWriter.UpdateCallCount()).To(Equal(2))
+
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			dependant := object.(*qsv1a1.QuarksSecret)
+			Expect(dependant.Name).To(Equal("signed"))
+			Expect(dependant.Status.Generated).To(BeFalse())
+		})
+
+		Context("when the CA is renewed", func() {
+			var previousCA credsgen.Certificate
+
+			BeforeEach(func() {
+				_, log := helper.NewTestLogger()
+				inMemory := inmemorygenerator.NewInMemoryGenerator(log)
+				inMemory.Algorithm = credsgen.ECDSAKeyAlgorithm
+				inMemory.Bits = 256
+
+				var err error
+				previousCA, err = inMemory.GenerateCertificate("ca", credsgen.CertificateGenerationRequest{CommonName: "ca", IsCA: true})
+				Expect(err).ToNot(HaveOccurred())
+				generator.GenerateCertificateCalls(inMemory.GenerateCertificate)
+			})
+
+			It("keeps trusting the previous CA in the bundle of the renewed CA", func() {
+				qSecret.Spec.Request.CertificateRequest.IsCA = true
+				validity(-8*time.Hour, 2*time.Hour)
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					switch object := object.(type) {
+					case *qsv1a1.QuarksSecret:
+						qSecret.DeepCopyInto(object)
+					case *corev1.Secret:
+						*object = corev1.Secret{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      nn.Name,
+								Namespace: nn.Namespace,
+								Labels:    map[string]string{qsv1a1.LabelKind: qsv1a1.GeneratedSecretKind},
+							},
+							Data: map[string][]byte{"certificate": previousCA.Certificate, "private_key": previousCA.PrivateKey},
+						}
+					}
+					return nil
+				})
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.UpdateCallCount()).To(Equal(1))
+
+				_, object, _ := client.UpdateArgsForCall(0)
+				secret := object.(*corev1.Secret)
+				certificate := []byte(secret.StringData["certificate"])
+				Expect(certificate).ToNot(Equal(previousCA.Certificate))
+
+				first, rest := pem.Decode([]byte(secret.StringData["ca"]))
+				Expect(pem.EncodeToMemory(first)).To(Equal(certificate))
+				second, rest := pem.Decode(rest)
+				Expect(pem.EncodeToMemory(second)).To(Equal(previousCA.Certificate))
+				Expect(rest).To(BeEmpty())
+			})
+
+			It("passes the bundle of the renewed CA to the certificates it signs", func() {
+				bundle := append([]byte("renewed_ca\n"), previousCA.Certificate...)
+				qSecret.Status.Generated = false
+				qSecret.Spec.Request.CertificateRequest.CARef = qsv1a1.SecretReference{Name: "ca-secret", Key: "certificate"}
+				qSecret.Spec.Request.CertificateRequest.CAKeyRef = qsv1a1.SecretReference{Name: "ca-secret", Key: "private_key"}
+				generator.GenerateCertificateReturns(credsgen.Certificate{Certificate: []byte("the_cert"), PrivateKey: []byte("private_key")}, nil)
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					switch object := object.(type) {
+					case *qsv1a1.QuarksSecret:
+						qSecret.DeepCopyInto(object)
+					case *corev1.Secret:
+						if nn.Name != "ca-secret" {
+							return errors.NewNotFound(schema.GroupResource{}, nn.Name)
+						}
+						object.Data = map[string][]byte{"certificate": []byte("renewed_ca\n"), "private_key": []byte("ca_key"), "ca": bundle}
+					}
+					return nil
+				})
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+
+				_, object, _ := client.CreateArgsForCall(0)
+				Expect(object.(*corev1.Secret).StringData["ca"]).To(Equal(string(bundle)))
+			})
+		})
+	})
+
 	Context("when secret is set manually", func() {
 		var (
 			password string
@@ -342,7 +826,7 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 				return nil
 			})
 
-			generator.GeneratePasswordReturns(password)
+			generator.GeneratePasswordReturns(password, nil)
 		})
 
 		It("Skips generation of a secret when existing secret has not `generated` label", func() {
@@ -391,4 +875,201 @@ var _ = Describe("ReconcileQuarksSecret", func() {
 
 		})
 	})
+
+	Context("when copying the secret", func() {
+		var (
+			reader *cfakes.FakeClient
+			copies map[string]*corev1.Secret
+		)
+
+		copyMeta := func() metav1.ObjectMeta {
+			return metav1.ObjectMeta{
+				Labels: map[string]string{qsv1a1.LabelKind: qsv1a1.CopiedSecretKind},
+				Annotations: map[string]string{
+					qsv1a1.AnnotationQSecNamespace: "default",
+					qsv1a1.AnnotationQSecName:      "foo",
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			qSecret.Status.Generated = true
+			qSecret.Finalizers = []string{qsv1a1.FinalizerSecretCopies}
+			qSecret.Spec.Copies = []qsv1a1.SecretCopy{
+				{Namespace: "target"},
+				{Namespace: "other", Name: "renamed"},
+			}
+
+			copies = map[string]*corev1.Secret{}
+			reader = &cfakes.FakeClient{}
+			reader.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+				secret, ok := object.(*corev1.Secret)
+				if !ok {
+					return errors.NewNotFound(schema.GroupResource{}, nn.Name)
+				}
+				if nn.Namespace == "default" && nn.Name == "generated-secret" {
+					secret.Name = nn.Name
+					secret.Namespace = nn.Namespace
+					secret.Data = map[string][]byte{"password": []byte("secret")}
+					return nil
+				}
+				if existing, ok := copies[nn.String()]; ok {
+					existing.DeepCopyInto(secret)
+					secret.Namespace, secret.Name = nn.Namespace, nn.Name
+					return nil
+				}
+				return errors.NewNotFound(schema.GroupResource{}, nn.Name)
+			})
+			manager.GetAPIReaderReturns(reader)
+		})
+
+		It("creates the copies in the target namespaces", func() {
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(2))
+
+			_, object, _ := client.CreateArgsForCall(0)
+			secret := object.(*corev1.Secret)
+			Expect(secret.Namespace).To(Equal("target"))
+			Expect(secret.Name).To(Equal("generated-secret"))
+			Expect(secret.Data).To(HaveKeyWithValue("password", []byte("secret")))
+			Expect(secret.GetLabels()).To(HaveKeyWithValue(qsv1a1.LabelKind, qsv1a1.CopiedSecretKind))
+			Expect(secret.GetAnnotations()).To(HaveKeyWithValue(qsv1a1.AnnotationQSecNamespace, "default"))
+			Expect(secret.GetAnnotations()).To(HaveKeyWithValue(qsv1a1.AnnotationQSecName, "foo"))
+
+			_, object, _ = client.CreateArgsForCall(1)
+			secret = object.(*corev1.Secret)
+			Expect(secret.Namespace).To(Equal("other"))
+			Expect(secret.Name).To(Equal("renamed"))
+		})
+
+		It("updates outdated copies", func() {
+			copies["target/generated-secret"] = &corev1.Secret{
+				ObjectMeta: copyMeta(),
+				Data:       map[string][]byte{"password": []byte("rotated")},
+			}
+			copies["other/renamed"] = &corev1.Secret{
+				ObjectMeta: copyMeta(),
+				Data:       map[string][]byte{"password": []byte("secret")},
+			}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(0))
+			Expect(client.UpdateCallCount()).To(Equal(1))
+
+			_, object, _ := client.UpdateArgsForCall(0)
+			Expect(object.(*corev1.Secret).Data).To(HaveKeyWithValue("password", []byte("secret")))
+		})
+
+		It("doesn't overwrite secrets which aren't copies", func() {
+			copies["target/generated-secret"] = &corev1.Secret{
+				Data: map[string][]byte{"password": []byte("user")},
+			}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exists and is not a copy of quarks secret 'default/foo'"))
+			Expect(client.UpdateCallCount()).To(Equal(0))
+		})
+
+		It("adds the finalizer for the copies", func() {
+			qSecret.Finalizers = nil
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.UpdateCallCount()).To(Equal(1))
+
+			_, object, _ := client.UpdateArgsForCall(0)
+			Expect(object.(*qsv1a1.QuarksSecret).GetFinalizers()).To(ConsistOf(qsv1a1.FinalizerSecretCopies))
+		})
+
+		It("records the copies in the status", func() {
+			statusWriter := &cfakes.FakeStatusWriter{}
+			client.StatusReturns(statusWriter)
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			Expect(object.(*qsv1a1.QuarksSecret).Status.Copies).To(ConsistOf(
+				qsv1a1.SecretCopy{Namespace: "target", Name: "generated-secret"},
+				qsv1a1.SecretCopy{Namespace: "other", Name: "renamed"},
+			))
+		})
+
+		It("deletes recorded copies which were removed from the spec", func() {
+			qSecret.Spec.Copies = []qsv1a1.SecretCopy{{Namespace: "target"}}
+			qSecret.Status.Copies = []qsv1a1.SecretCopy{
+				{Namespace: "target", Name: "generated-secret"},
+				{Namespace: "other", Name: "renamed"},
+				{Namespace: "other", Name: "unrelated"},
+			}
+			copies["target/generated-secret"] = &corev1.Secret{ObjectMeta: copyMeta()}
+			copies["other/renamed"] = &corev1.Secret{ObjectMeta: copyMeta()}
+			copies["other/unrelated"] = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{qsv1a1.LabelKind: qsv1a1.CopiedSecretKind},
+					Annotations: map[string]string{
+						qsv1a1.AnnotationQSecNamespace: "default",
+						qsv1a1.AnnotationQSecName:      "bar",
+					},
+				},
+			}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(1))
+
+			_, object, _ := client.DeleteArgsForCall(0)
+			Expect(object.(*corev1.Secret).Namespace).To(Equal("other"))
+			Expect(object.(*corev1.Secret).Name).To(Equal("renamed"))
+		})
+
+		It("removes the finalizer once all copies were removed from the spec", func() {
+			qSecret.Spec.Copies = nil
+			qSecret.Status.Copies = []qsv1a1.SecretCopy{{Namespace: "target", Name: "generated-secret"}}
+			copies["target/generated-secret"] = &corev1.Secret{ObjectMeta: copyMeta()}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(1))
+			Expect(client.UpdateCallCount()).To(Equal(1))
+
+			_, object, _ := client.UpdateArgsForCall(0)
+			Expect(object.(*qsv1a1.QuarksSecret).GetFinalizers()).To(BeEmpty())
+		})
+
+		It("deletes all copies before the quarks secret is deleted", func() {
+			now := metav1.Now()
+			qSecret.DeletionTimestamp = &now
+			qSecret.Status.Copies = []qsv1a1.SecretCopy{
+				{Namespace: "target", Name: "generated-secret"},
+				{Namespace: "other", Name: "renamed"},
+			}
+			copies["target/generated-secret"] = &corev1.Secret{ObjectMeta: copyMeta()}
+			copies["other/renamed"] = &corev1.Secret{ObjectMeta: copyMeta()}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(2))
+			Expect(client.CreateCallCount()).To(Equal(0))
+			Expect(client.UpdateCallCount()).To(Equal(1))
+
+			_, object, _ := client.UpdateArgsForCall(0)
+			Expect(object.(*qsv1a1.QuarksSecret).GetFinalizers()).To(BeEmpty())
+		})
+	})
 })
+
+// storingGenerator returns the regenerating fake, when it's asked to replace
+// the credentials it keeps
+type storingGenerator struct {
+	*generatorfakes.FakeGenerator
+	regenerating *generatorfakes.FakeGenerator
+}
+
+func (g storingGenerator) Regenerating() credsgen.Generator {
+	return g.regenerating
+}
@@ -0,0 +1,225 @@
+package quarkssecret
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"k8s.io/api/admission/v1beta1"
+	admissionregistration "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
+	wh "code.cloudfoundry.org/cf-operator/pkg/kube/util/webhook"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/names"
+)
+
+// NewQuarksSecretValidator creates a validating hook for QuarksSecrets, which
+// checks the generation request and the namespaces of secret copies, and adds
+// it to the manager.
+func NewQuarksSecretValidator(log *zap.SugaredLogger, config *config.Config, copyNamespaces []string) *wh.OperatorWebhook {
+	log.Info("Setting up validator for QuarksSecret")
+
+	quarksSecretValidator := NewQuarksSecretValidationHandler(log, config, copyNamespaces)
+
+	globalScopeType := admissionregistration.ScopeType("*")
+	return &wh.OperatorWebhook{
+		FailurePolicy: admissionregistration.Fail,
+		Rules: []admissionregistration.RuleWithOperations{
+			{
+				Rule: admissionregistration.Rule{
+					APIGroups:   []string{names.GroupName},
+					APIVersions: []string{"v1alpha1"},
+					Resources:   []string{"quarkssecrets"},
+					Scope:       &globalScopeType,
+				},
+				Operations: []admissionregistration.OperationType{
+					"CREATE",
+					"UPDATE",
+				},
+			},
+		},
+		Path: "/validate-quarkssecret",
+		Name: "validate-quarkssecret." + names.GroupName,
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				wh.LabelWatchNamespace: config.OperatorNamespace,
+			},
+		},
+		Webhook: &admission.Webhook{
+			Handler: quarksSecretValidator,
+		},
+	}
+}
+
+// QuarksSecretValidationHandler validates QuarksSecrets
+type QuarksSecretValidationHandler struct {
+	log       *zap.SugaredLogger
+	config    *config.Config
+	apiReader client.Reader
+	decoder   *admission.Decoder
+
+	copyNamespaces []string
+}
+
+// NewQuarksSecretValidationHandler returns a new QuarksSecretValidationHandler
+func NewQuarksSecretValidationHandler(log *zap.SugaredLogger, config *config.Config, copyNamespaces []string) admission.Handler {
+	validationLog := log.Named("quarks-secret-validator")
+	validationLog.Info("Creating a validator for QuarksSecret")
+	return &QuarksSecretValidationHandler{
+		log:            validationLog,
+		config:         config,
+		copyNamespaces: copyNamespaces,
+	}
+}
+
+// Handle denies QuarksSecrets with out of range generation requests and
+// QuarksSecrets, which copy their secret into namespaces not watched by the
+// operator
+func (v *QuarksSecretValidationHandler) Handle(_ context.Context, req admission.Request) admission.Response {
+	qsec := &qsv1a1.QuarksSecret{}
+	ctx := log.NewParentContext(v.log)
+
+	err := v.decoder.Decode(req, qsec)
+	if err != nil {
+		return admission.Response{
+			AdmissionResponse: v1beta1.AdmissionResponse{
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("Failed to decode QuarksSecret: %s", err.Error()),
+				},
+			},
+		}
+	}
+
+	err = validateRequest(qsec)
+	if err != nil {
+		log.Infof(ctx, "Denying quarks secret '%s': %s", qsec.Name, err.Error())
+		return admission.Response{
+			AdmissionResponse: v1beta1.AdmissionResponse{
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("Invalid request of quarks secret '%s': %s", qsec.Name, err.Error()),
+				},
+			},
+		}
+	}
+
+	err = v.validateCopies(ctx, qsec)
+	if err != nil {
+		log.Infof(ctx, "Denying quarks secret '%s': %s", qsec.Name, err.Error())
+		return admission.Response{
+			AdmissionResponse: v1beta1.AdmissionResponse{
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("Invalid copies of quarks secret '%s': %s", qsec.Name, err.Error()),
+				},
+			},
+		}
+	}
+
+	return admission.Response{
+		AdmissionResponse: v1beta1.AdmissionResponse{
+			Allowed: true,
+		},
+	}
+}
+
+// validateRequest checks the ranges of the generation parameters, zero values
+// select the generator's defaults
+func validateRequest(qsec *qsv1a1.QuarksSecret) error {
+	password := qsec.Spec.Request.PasswordRequest
+	if password.Length < 0 || password.Length > credsgen.MaxPasswordLength {
+		return fmt.Errorf("password length %d is not between 1 and %d", password.Length, credsgen.MaxPasswordLength)
+	}
+	if password.MinSpecial < 0 {
+		return fmt.Errorf("minimum of %d special characters is negative", password.MinSpecial)
+	}
+	if password.Words < 0 || password.Words > credsgen.MaxPassphraseWords {
+		return fmt.Errorf("passphrase words %d is not between 1 and %d", password.Words, credsgen.MaxPassphraseWords)
+	}
+	if len(password.Separator) > credsgen.MaxPassphraseSeparatorLength {
+		return fmt.Errorf("passphrase separator is longer than %d characters", credsgen.MaxPassphraseSeparatorLength)
+	}
+
+	bits := qsec.Spec.Request.DHParamsRequest.Bits
+	if bits != 0 && (bits < credsgen.MinDHParamsBits || bits > credsgen.MaxDHParamsBits) {
+		return fmt.Errorf("DH parameters bits %d are not between %d and %d", bits, credsgen.MinDHParamsBits, credsgen.MaxDHParamsBits)
+	}
+
+	return nil
+}
+
+// validateCopies checks that every copy targets a distinct secret in a
+// namespace the operator may copy secrets to
+func (v *QuarksSecretValidationHandler) validateCopies(ctx context.Context, qsec *qsv1a1.QuarksSecret) error {
+	targets := map[string]bool{}
+	watched := map[string]bool{}
+
+	for _, target := range qsec.Spec.Copies {
+		if target.Namespace == "" {
+			return fmt.Errorf("copies need a namespace")
+		}
+
+		name := target.Name
+		if name == "" {
+			name = qsec.Spec.SecretName
+		}
+		if target.Namespace == qsec.Namespace && name == qsec.Spec.SecretName {
+			return fmt.Errorf("copy '%s/%s' would replace the generated secret", target.Namespace, name)
+		}
+		key := target.Namespace + "/" + name
+		if targets[key] {
+			return fmt.Errorf("secret '%s' is copied more than once", key)
+		}
+		targets[key] = true
+
+		if watched[target.Namespace] {
+			continue
+		}
+		ns := &corev1.Namespace{}
+		err := v.apiReader.Get(ctx, types.NamespacedName{Name: target.Namespace}, ns)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Errorf("namespace '%s' does not exist", target.Namespace)
+			}
+			return fmt.Errorf("could not get namespace '%s': %s", target.Namespace, err.Error())
+		}
+		if !contains(v.copyNamespaces, target.Namespace) {
+			return fmt.Errorf("namespace '%s' is not one of the operator's secret copy namespaces", target.Namespace)
+		}
+		watched[target.Namespace] = true
+	}
+
+	return nil
+}
+
+// QuarksSecretValidationHandler implements inject.APIReader.
+// The API reader will be automatically injected.
+var _ inject.APIReader = &QuarksSecretValidationHandler{}
+
+// InjectAPIReader injects the API reader.
+func (v *QuarksSecretValidationHandler) InjectAPIReader(r client.Reader) error {
+	v.apiReader = r
+	return nil
+}
+
+// QuarksSecretValidationHandler implements inject.Decoder.
+// A decoder will be automatically injected.
+var _ admission.DecoderInjector = &QuarksSecretValidationHandler{}
+
+// InjectDecoder injects the decoder.
+func (v *QuarksSecretValidationHandler) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
@@ -0,0 +1,152 @@
+package quarkssecret_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
+	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarkssecret"
+	cfcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("When the webhook handles a quarks secret", func() {
+	var (
+		log      *zap.SugaredLogger
+		ctx      context.Context
+		reader   *cfakes.FakeClient
+		qsec     qsv1a1.QuarksSecret
+		validate func() admission.Response
+	)
+
+	BeforeEach(func() {
+		_, log = helper.NewTestLogger()
+		ctx = ctxlog.NewParentContext(log)
+
+		reader = &cfakes.FakeClient{}
+		reader.GetCalls(func(_ context.Context, nn types.NamespacedName, object runtime.Object) error {
+			ns := object.(*corev1.Namespace)
+			switch nn.Name {
+			case "watched", "unwatched":
+				ns.Name = nn.Name
+			default:
+				return errors.NewNotFound(schema.GroupResource{}, nn.Name)
+			}
+			return nil
+		})
+
+		qsec = qsv1a1.QuarksSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "qsec", Namespace: "source"},
+			Spec: qsv1a1.QuarksSecretSpec{
+				Type:       qsv1a1.Password,
+				SecretName: "generated",
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(qsv1a1.AddToScheme(scheme)).To(Succeed())
+		decoder, _ := admission.NewDecoder(scheme)
+		validator := quarkssecret.NewQuarksSecretValidationHandler(log, &cfcfg.Config{OperatorNamespace: "cf-operator"}, []string{"watched", "missing"})
+		validator.(admission.DecoderInjector).InjectDecoder(decoder)
+		validator.(inject.APIReader).InjectAPIReader(reader)
+
+		validate = func() admission.Response {
+			raw, _ := json.Marshal(qsec)
+			return validator.Handle(ctx, admission.Request{
+				AdmissionRequest: v1beta1.AdmissionRequest{
+					Object: runtime.RawExtension{Raw: raw},
+				},
+			})
+		}
+	})
+
+	It("allows copies into the copy namespaces", func() {
+		qsec.Spec.Copies = []qsv1a1.SecretCopy{{Namespace: "watched"}, {Namespace: "watched", Name: "other"}}
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeTrue())
+	})
+
+	It("denies copies into other namespaces", func() {
+		qsec.Spec.Copies = []qsv1a1.SecretCopy{{Namespace: "unwatched"}}
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("namespace 'unwatched' is not one of the operator's secret copy namespaces"))
+	})
+
+	It("denies copies into missing namespaces", func() {
+		qsec.Spec.Copies = []qsv1a1.SecretCopy{{Namespace: "missing"}}
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("namespace 'missing' does not exist"))
+	})
+
+	It("denies duplicate copies", func() {
+		qsec.Spec.Copies = []qsv1a1.SecretCopy{{Namespace: "watched"}, {Namespace: "watched", Name: "generated"}}
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("secret 'watched/generated' is copied more than once"))
+	})
+
+	It("denies copies replacing the generated secret", func() {
+		qsec.Spec.Copies = []qsv1a1.SecretCopy{{Namespace: "source"}}
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("would replace the generated secret"))
+	})
+
+	It("denies passwords longer than the maximum length", func() {
+		qsec.Spec.Request.PasswordRequest.Length = 1025
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("password length 1025 is not between 1 and 1024"))
+	})
+
+	It("denies passphrases with too many words", func() {
+		qsec.Spec.Request.PasswordRequest.Passphrase = true
+		qsec.Spec.Request.PasswordRequest.Words = 65
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("passphrase words 65 is not between 1 and 64"))
+	})
+
+	It("denies long passphrase separators", func() {
+		qsec.Spec.Request.PasswordRequest.Separator = "---------"
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("passphrase separator is longer than 8 characters"))
+	})
+
+	It("denies DH parameters with too many bits", func() {
+		qsec.Spec.Request.DHParamsRequest.Bits = 8192
+
+		response := validate()
+		Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+		Expect(response.AdmissionResponse.Result.Message).To(ContainSubstring("DH parameters bits 8192 are not between 1024 and 4096"))
+	})
+})
@@ -0,0 +1,161 @@
+package quarkssecret
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// DefaultRenewalFraction is the fraction of a certificate's lifetime after
+// which the certificate is renewed
+const DefaultRenewalFraction = 0.7
+
+// renewalTime returns when the certificate of the QuarksSecret is due for
+// renewal after the given fraction of its lifetime. It returns false if the
+// certificate's validity isn't known or renewal is disabled by a zero
+// fraction.
+func renewalTime(qsec *qsv1a1.QuarksSecret, renewalFraction float64) (time.Time, bool) {
+	if renewalFraction <= 0 || qsec.Spec.Type != qsv1a1.Certificate {
+		return time.Time{}, false
+	}
+	if qsec.Status.NotBefore == nil || qsec.Status.NotAfter == nil {
+		return time.Time{}, false
+	}
+
+	lifetime := qsec.Status.NotAfter.Sub(qsec.Status.NotBefore.Time)
+	return qsec.Status.NotBefore.Add(time.Duration(float64(lifetime) * renewalFraction)), true
+}
+
+// renewalResult requeues the QuarksSecret for the renewal of its certificate
+func renewalResult(qsec *qsv1a1.QuarksSecret, renewalFraction float64) reconcile.Result {
+	renewAt, ok := renewalTime(qsec, renewalFraction)
+	if !ok {
+		return reconcile.Result{}
+	}
+	wait := time.Until(renewAt)
+	if wait <= 0 {
+		return reconcile.Result{Requeue: true}
+	}
+	return reconcile.Result{RequeueAfter: wait}
+}
+
+// setCertificateValidity records the validity of the PEM encoded certificate
+// in the status. The validity is reset if the certificate can't be parsed.
+func setCertificateValidity(status *qsv1a1.QuarksSecretStatus, certificate []byte) error {
+	status.NotBefore = nil
+	status.NotAfter = nil
+
+	block, _ := pem.Decode(certificate)
+	if block == nil {
+		return errors.New("could not decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "parsing certificate")
+	}
+
+	notBefore := metav1.NewTime(cert.NotBefore)
+	notAfter := metav1.NewTime(cert.NotAfter)
+	status.NotBefore = &notBefore
+	status.NotAfter = &notAfter
+	return nil
+}
+
+// renewDependants resets the generated status of all QuarksSecrets with
+// certificates signed by the CA of the given QuarksSecret, so they get
+// regenerated with the new CA
+func (r *ReconcileQuarksSecret) renewDependants(ctx context.Context, ca *qsv1a1.QuarksSecret) error {
+	list := &qsv1a1.QuarksSecretList{}
+	err := r.client.List(ctx, list, crc.InNamespace(ca.Namespace))
+	if err != nil {
+		return errors.Wrapf(err, "could not list QuarksSecrets signed by CA '%s'", ca.Name)
+	}
+
+	for i := range list.Items {
+		qsec := &list.Items[i]
+		if qsec.Spec.Type != qsv1a1.Certificate || !qsec.Status.Generated {
+			continue
+		}
+		if qsec.Spec.Request.CertificateRequest.CARef.Name != ca.Spec.SecretName {
+			continue
+		}
+
+		ctxlog.WithEvent(qsec, "RenewCertificate").Infof(ctx, "Renewing certificate of QuarksSecret '%s', its CA '%s' was regenerated", qsec.Name, ca.Name)
+		qsec.Status.Generated = false
+		err = r.client.Status().Update(ctx, qsec)
+		if err != nil {
+			return errors.Wrapf(err, "could not update status of QuarksSecret '%s'", qsec.Name)
+		}
+	}
+
+	return nil
+}
+
+// previousCertificate returns the PEM encoded certificate of the generated
+// secret, which is replaced by a renewal. It returns nil, if the secret
+// doesn't exist yet, wasn't generated or has no certificate.
+func (r *ReconcileQuarksSecret) previousCertificate(ctx context.Context, instance *qsv1a1.QuarksSecret) ([]byte, error) {
+	secret := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Spec.SecretName}, secret)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get secret '%s'", instance.Spec.SecretName)
+	}
+	if secret.GetLabels()[qsv1a1.LabelKind] != qsv1a1.GeneratedSecretKind {
+		return nil, nil
+	}
+
+	block, _ := pem.Decode(secret.Data["certificate"])
+	if block == nil {
+		return nil, nil
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// transitionalCABundle appends the previous certificate of a renewed CA to
+// the CA certificates trusted by its secret, like a BOSH transitional CA.
+// Clients keep trusting the certificates signed by the previous CA, until the
+// renewed dependants are rolled out.
+func transitionalCABundle(trusted []byte, certificate []byte, previous []byte) []byte {
+	// Root CAs trust themselves
+	if len(trusted) == 0 {
+		trusted = certificate
+	}
+	if len(previous) == 0 || bytes.Contains(trusted, previous) {
+		return trusted
+	}
+	return append(append([]byte{}, trusted...), previous...)
+}
+
+// caBundle returns the CA certificates, which verify a certificate signed by
+// the given CA. For a renewed root CA these include its transitional CA.
+func (r *ReconcileQuarksSecret) caBundle(ctx context.Context, namespace string, caName string, caCertificate []byte) ([]byte, error) {
+	caSecret := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: caName}, caSecret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get CA secret '%s'", caName)
+	}
+
+	// Only the bundle of a root CA starts with its own certificate, the
+	// bundle of an intermediate CA holds the CAs it's verified with
+	bundle := caSecret.Data["ca"]
+	if len(bundle) > len(caCertificate) && bytes.HasPrefix(bundle, caCertificate) {
+		return bundle, nil
+	}
+	return caCertificate, nil
+}
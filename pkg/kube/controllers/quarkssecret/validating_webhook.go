@@ -3,17 +3,21 @@ package quarkssecret
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	"go.uber.org/zap"
 
 	"k8s.io/api/admission/v1beta1"
 	admissionregistration "k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
 	wh "code.cloudfoundry.org/cf-operator/pkg/kube/util/webhook"
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	log "code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
@@ -21,7 +25,7 @@ import (
 	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
 )
 
-// NewSecretValidator creates a validating hook to deny updates to versioned secrets and adds it to the manager.
+// NewSecretValidator creates a validating hook to deny updates to versioned secrets and secret copies and adds it to the manager.
 func NewSecretValidator(log *zap.SugaredLogger, config *config.Config) *wh.OperatorWebhook {
 	log.Info("Setting up validator for Secret")
 
@@ -58,9 +62,10 @@ func NewSecretValidator(log *zap.SugaredLogger, config *config.Config) *wh.Opera
 
 // ValidationHandler is a struct for secret validator object.
 type ValidationHandler struct {
-	log     *zap.SugaredLogger
-	client  client.Client
-	decoder *admission.Decoder
+	log       *zap.SugaredLogger
+	client    client.Client
+	apiReader client.Reader
+	decoder   *admission.Decoder
 }
 
 // NewValidationHandler returns a new ValidationHandler
@@ -72,7 +77,8 @@ func NewValidationHandler(log *zap.SugaredLogger) admission.Handler {
 	}
 }
 
-//Handle denies changes to all versioned secrets as they are immutable
+//Handle denies changes to all versioned secrets as they are immutable. Copies
+// of QuarksSecrets are read-only, only updates to the source's data are allowed.
 func (v *ValidationHandler) Handle(_ context.Context, req admission.Request) admission.Response {
 	secret := &corev1.Secret{}
 	ctx := log.NewParentContext(v.log)
@@ -103,6 +109,32 @@ func (v *ValidationHandler) Handle(_ context.Context, req admission.Request) adm
 		}
 	}
 
+	if namespace, name, ok := copySource(secret); ok {
+		source, err := v.copySource(ctx, namespace, name)
+		if err != nil {
+			return admission.Response{
+				AdmissionResponse: v1beta1.AdmissionResponse{
+					Allowed: false,
+					Result: &metav1.Status{
+						Message: fmt.Sprintf("Failed to get the source of secret copy '%s': %s", secret.GetName(), err.Error()),
+					},
+				},
+			}
+		}
+
+		if source != nil && !reflect.DeepEqual(source.Data, secret.Data) {
+			log.Infof(ctx, "Denying update to secret '%s' as it is a copy of quarks secret '%s/%s'.", secret.Name, namespace, name)
+			return admission.Response{
+				AdmissionResponse: v1beta1.AdmissionResponse{
+					Allowed: false,
+					Result: &metav1.Status{
+						Message: fmt.Sprintf("Denying update to secret '%s' as it is a read-only copy of quarks secret '%s/%s'.", secret.GetName(), namespace, name),
+					},
+				},
+			}
+		}
+	}
+
 	return admission.Response{
 		AdmissionResponse: v1beta1.AdmissionResponse{
 			Allowed: true,
@@ -110,6 +142,30 @@ func (v *ValidationHandler) Handle(_ context.Context, req admission.Request) adm
 	}
 }
 
+// copySource returns the secret generated by the QuarksSecret, which is the
+// source of a copy. It returns nil if either doesn't exist anymore.
+func (v *ValidationHandler) copySource(ctx context.Context, namespace string, name string) (*corev1.Secret, error) {
+	qsec := &qsv1a1.QuarksSecret{}
+	err := v.apiReader.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, qsec)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	source := &corev1.Secret{}
+	err = v.apiReader.Get(ctx, types.NamespacedName{Name: qsec.Spec.SecretName, Namespace: namespace}, source)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return source, nil
+}
+
 // Validator implements inject.Client.
 // A client will be automatically injected.
 var _ inject.Client = &ValidationHandler{}
@@ -120,6 +176,16 @@ func (v *ValidationHandler) InjectClient(c client.Client) error {
 	return nil
 }
 
+// Validator implements inject.APIReader.
+// The API reader will be automatically injected.
+var _ inject.APIReader = &ValidationHandler{}
+
+// InjectAPIReader injects the API reader.
+func (v *ValidationHandler) InjectAPIReader(r client.Reader) error {
+	v.apiReader = r
+	return nil
+}
+
 // Validator implements inject.Decoder.
 // A decoder will be automatically injected.
 var _ admission.DecoderInjector = &ValidationHandler{}
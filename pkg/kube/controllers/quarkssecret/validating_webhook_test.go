@@ -9,11 +9,17 @@ import (
 
 	"k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	qsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarkssecret/v1alpha1"
+	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarkssecret"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
 	vss "code.cloudfoundry.org/quarks-utils/pkg/versionedsecretstore"
@@ -26,6 +32,7 @@ var _ = Describe("When the webhook handles update request of a secret", func() {
 		ctx            context.Context
 		decoder        *admission.Decoder
 		validator      admission.Handler
+		reader         *cfakes.FakeClient
 		secretBytes    []byte
 		validateSecret func() admission.Response
 		secret         corev1.Secret
@@ -34,6 +41,7 @@ var _ = Describe("When the webhook handles update request of a secret", func() {
 	BeforeEach(func() {
 		_, log = helper.NewTestLogger()
 		ctx = ctxlog.NewParentContext(log)
+		reader = &cfakes.FakeClient{}
 
 		secret = corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
@@ -54,6 +62,7 @@ var _ = Describe("When the webhook handles update request of a secret", func() {
 		decoder, _ = admission.NewDecoder(scheme)
 		validator = quarkssecret.NewValidationHandler(log)
 		validator.(admission.DecoderInjector).InjectDecoder(decoder)
+		validator.(inject.APIReader).InjectAPIReader(reader)
 
 		validateSecret = func() admission.Response {
 			response := validator.Handle(ctx, admission.Request{
@@ -90,4 +99,43 @@ var _ = Describe("When the webhook handles update request of a secret", func() {
 			Expect(response.AdmissionResponse.Result.Message).To(Equal("Denying update to versioned secret 'mysecret' as it is immutable."))
 		})
 	})
+
+	Context("which is a copy of a quarks secret", func() {
+		BeforeEach(func() {
+			secret.SetLabels(map[string]string{qsv1a1.LabelKind: qsv1a1.CopiedSecretKind})
+			secret.SetAnnotations(map[string]string{
+				qsv1a1.AnnotationQSecNamespace: "source",
+				qsv1a1.AnnotationQSecName:      "qsec",
+			})
+
+			reader.GetCalls(func(_ context.Context, nn types.NamespacedName, object runtime.Object) error {
+				switch object := object.(type) {
+				case *qsv1a1.QuarksSecret:
+					object.Spec.SecretName = "generated"
+				case *corev1.Secret:
+					if nn.Namespace != "source" || nn.Name != "generated" {
+						return errors.NewNotFound(schema.GroupResource{}, nn.Name)
+					}
+					object.Data = map[string][]byte{"key": []byte("value")}
+				}
+				return nil
+			})
+		})
+
+		It("should allow updates from the source", func() {
+			secretBytes, _ = json.Marshal(secret)
+
+			response := validateSecret()
+			Expect(response.AdmissionResponse.Allowed).To(BeTrue())
+		})
+
+		It("should not allow modifications", func() {
+			secret.Data["key"] = []byte("modified")
+			secretBytes, _ = json.Marshal(secret)
+
+			response := validateSecret()
+			Expect(response.AdmissionResponse.Allowed).To(BeFalse())
+			Expect(response.AdmissionResponse.Result.Message).To(Equal("Denying update to secret 'mysecret' as it is a read-only copy of quarks secret 'source/qsec'."))
+		})
+	})
 })
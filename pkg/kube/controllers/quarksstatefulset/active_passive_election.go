@@ -0,0 +1,241 @@
+package quarksstatefulset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	podutil "code.cloudfoundry.org/quarks-utils/pkg/pod"
+)
+
+// LeaseElection elects the active pods of an exclusive active/passive
+// QuarksStatefulSet. There is one lease per active pod, named
+// '<quarksstatefulset>-active-<slot>'. A lease stays with its holder as long
+// as the holder passes the probe, otherwise it's handed over to another
+// healthy pod. The lease transitions are counted on every hand over and
+// serve as fencing token of the holder.
+type LeaseElection struct {
+	client crc.Client
+	scheme *runtime.Scheme
+}
+
+// NewLeaseElection returns a new LeaseElection
+func NewLeaseElection(client crc.Client, scheme *runtime.Scheme) *LeaseElection {
+	return &LeaseElection{client: client, scheme: scheme}
+}
+
+// Elect returns the pods holding one of the leases, mapped to their fencing
+// token '<lease>/<transitions>'. Only the given pods, which are healthy, are elected. Leases
+// for slots above the maximum of active pods are deleted.
+func (e *LeaseElection) Elect(ctx context.Context, qSts *qstsv1a1.QuarksStatefulSet, pods []corev1.Pod, healthy map[string]bool, leaseDuration time.Duration) (map[string]string, error) {
+	maxActive := qSts.Spec.ActivePassive.GetMaxActive()
+
+	if err := e.deleteSurplusLeases(ctx, qSts, maxActive); err != nil {
+		return nil, err
+	}
+
+	leases := make([]*coordinationv1.Lease, maxActive)
+	for slot := range leases {
+		lease := &coordinationv1.Lease{}
+		err := e.client.Get(ctx, crc.ObjectKey{Namespace: qSts.Namespace, Name: leaseName(qSts, slot)}, lease)
+		if err == nil {
+			leases[slot] = lease
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "could not get lease '%s'", leaseName(qSts, slot))
+		}
+	}
+
+	// pods, which are gone, can't hold a lease
+	eligible := map[string]bool{}
+	for _, pod := range pods {
+		eligible[pod.Name] = healthy[pod.Name]
+	}
+
+	active := map[string]string{}
+	now := metav1.NewMicroTime(time.Now())
+	durationSeconds := int32(leaseDuration.Seconds())
+
+	// Holders passing the probe keep their lease
+	vacant := []int{}
+	for slot, lease := range leases {
+		holder := leaseHolder(lease)
+		if _, taken := active[holder]; holder == "" || taken || !eligible[holder] {
+			vacant = append(vacant, slot)
+			continue
+		}
+		lease.Spec.RenewTime = &now
+		lease.Spec.LeaseDurationSeconds = &durationSeconds
+		if err := e.client.Update(ctx, lease); err != nil {
+			return nil, errors.Wrapf(err, "could not renew lease '%s' of pod '%s'", lease.Name, holder)
+		}
+		active[holder] = fencingToken(lease)
+	}
+
+	candidates := electionCandidates(pods, healthy, active)
+	for _, slot := range vacant {
+		lease := leases[slot]
+
+		if len(candidates) == 0 {
+			if lease == nil || leaseHolder(lease) == "" {
+				continue
+			}
+			ctxlog.WithEvent(qSts, "ActivePassive").Infof(ctx, "Releasing lease '%s' of pod '%s', no healthy pod is left", lease.Name, leaseHolder(lease))
+			lease.Spec.HolderIdentity = nil
+			if err := e.client.Update(ctx, lease); err != nil {
+				return nil, errors.Wrapf(err, "could not release lease '%s'", lease.Name)
+			}
+			continue
+		}
+
+		candidate := candidates[0]
+		candidates = candidates[1:]
+
+		if lease == nil {
+			lease = &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      leaseName(qSts, slot),
+					Namespace: qSts.Namespace,
+					Labels:    map[string]string{qstsv1a1.LabelQStsName: qSts.Name},
+				},
+			}
+			if err := controllerutil.SetControllerReference(qSts, lease, e.scheme); err != nil {
+				return nil, errors.Wrapf(err, "could not set owner reference of lease '%s'", lease.Name)
+			}
+		}
+
+		previous := leaseHolder(lease)
+		transitions := int32(0)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.HolderIdentity = &candidate
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+		lease.Spec.LeaseDurationSeconds = &durationSeconds
+		lease.Spec.LeaseTransitions = &transitions
+
+		var err error
+		if lease.ResourceVersion == "" {
+			err = e.client.Create(ctx, lease)
+		} else {
+			// A conflict means another reconcile changed the lease, the pods are re-elected on retry
+			err = e.client.Update(ctx, lease)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not hand over lease '%s' to pod '%s'", lease.Name, candidate)
+		}
+
+		if previous == "" {
+			ctxlog.WithEvent(qSts, "ActivePassive").Infof(ctx, "Pod '%s' acquired lease '%s'", candidate, lease.Name)
+		} else {
+			ctxlog.WithEvent(qSts, "ActivePassive").Infof(ctx, "Pod '%s' took over lease '%s' from pod '%s'", candidate, lease.Name, previous)
+		}
+		active[candidate] = fencingToken(lease)
+	}
+
+	return active, nil
+}
+
+// deleteSurplusLeases deletes the leases of slots, which are not
+// needed anymore after the maximum of active pods was decreased.
+func (e *LeaseElection) deleteSurplusLeases(ctx context.Context, qSts *qstsv1a1.QuarksStatefulSet, maxActive int) error {
+	leases := &coordinationv1.LeaseList{}
+	err := e.client.List(ctx, leases,
+		crc.InNamespace(qSts.Namespace),
+		crc.MatchingLabels{qstsv1a1.LabelQStsName: qSts.Name},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "could not list leases of QuarksStatefulSet '%s'", qSts.Name)
+	}
+
+	prefix := fmt.Sprintf("%s-active-", qSts.Name)
+	for i := range leases.Items {
+		lease := &leases.Items[i]
+		if !strings.HasPrefix(lease.Name, prefix) {
+			continue
+		}
+		slot, err := strconv.Atoi(strings.TrimPrefix(lease.Name, prefix))
+		if err != nil || slot < maxActive {
+			continue
+		}
+		if err := e.client.Delete(ctx, lease); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "could not delete lease '%s'", lease.Name)
+		}
+	}
+	return nil
+}
+
+// electionCandidates returns the healthy pods without a lease. Pods, which
+// are labeled active already, come first to avoid needless fail overs.
+func electionCandidates(pods []corev1.Pod, healthy map[string]bool, active map[string]string) []string {
+	candidates := []corev1.Pod{}
+	for _, pod := range pods {
+		if _, ok := active[pod.Name]; ok || !healthy[pod.Name] {
+			continue
+		}
+		candidates = append(candidates, pod)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		iActive, jActive := isActive(&candidates[i]), isActive(&candidates[j])
+		if iActive != jActive {
+			return iActive
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	names := make([]string, len(candidates))
+	for i, pod := range candidates {
+		names[i] = pod.Name
+	}
+	return names
+}
+
+// healthyPods returns the pods, which passed the probe and are ready.
+func healthyPods(pods []corev1.Pod, passed map[string]bool) map[string]bool {
+	healthy := map[string]bool{}
+	for i := range pods {
+		healthy[pods[i].Name] = passed[pods[i].Name] && podutil.IsPodReady(&pods[i])
+	}
+	return healthy
+}
+
+func isActive(pod *corev1.Pod) bool {
+	_, found := pod.GetLabels()[qstsv1a1.LabelActivePod]
+	return found
+}
+
+func leaseName(qSts *qstsv1a1.QuarksStatefulSet, slot int) string {
+	return fmt.Sprintf("%s-active-%d", qSts.Name, slot)
+}
+
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease == nil || lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func fencingToken(lease *coordinationv1.Lease) string {
+	transitions := int32(0)
+	if lease.Spec.LeaseTransitions != nil {
+		transitions = *lease.Spec.LeaseTransitions
+	}
+	return fmt.Sprintf("%s/%d", lease.Name, transitions)
+}
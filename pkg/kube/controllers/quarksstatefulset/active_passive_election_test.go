@@ -0,0 +1,162 @@
+package quarksstatefulset_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	qstscontroller "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarksstatefulset"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("LeaseElection", func() {
+	var (
+		ctx      context.Context
+		c        client.Client
+		election *qstscontroller.LeaseElection
+		qSts     *qstsv1a1.QuarksStatefulSet
+		pods     []corev1.Pod
+		healthy  map[string]bool
+	)
+
+	newPod := func(name string, active bool) corev1.Pod {
+		pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{}}}
+		if active {
+			pod.Labels[qstsv1a1.LabelActivePod] = "active"
+		}
+		return pod
+	}
+
+	getLease := func(name string) *coordinationv1.Lease {
+		lease := &coordinationv1.Lease{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, lease)).To(Succeed())
+		return lease
+	}
+
+	elect := func() map[string]string {
+		active, err := election.Elect(ctx, qSts, pods, healthy, 90*time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		return active
+	}
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		_, log := helper.NewTestLogger()
+		ctx = ctxlog.NewParentContext(log)
+
+		qSts = &qstsv1a1.QuarksStatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "foo-uid"},
+			Spec: qstsv1a1.QuarksStatefulSetSpec{
+				ActivePassive: &qstsv1a1.ActivePassive{Exclusive: true},
+			},
+		}
+		pods = []corev1.Pod{newPod("foo-0", false), newPod("foo-1", false), newPod("foo-2", false)}
+		healthy = map[string]bool{"foo-0": true, "foo-1": true, "foo-2": true}
+		c = fake.NewFakeClientWithScheme(scheme.Scheme, qSts)
+	})
+
+	JustBeforeEach(func() {
+		election = qstscontroller.NewLeaseElection(c, scheme.Scheme)
+	})
+
+	Context("when no lease exists", func() {
+		It("elects a single healthy pod by default", func() {
+			Expect(elect()).To(Equal(map[string]string{"foo-0": "foo-active-0/0"}))
+
+			lease := getLease("foo-active-0")
+			Expect(*lease.Spec.HolderIdentity).To(Equal("foo-0"))
+			Expect(*lease.Spec.LeaseDurationSeconds).To(Equal(int32(90)))
+			Expect(lease.Labels).To(HaveKeyWithValue(qstsv1a1.LabelQStsName, "foo"))
+			Expect(lease.OwnerReferences).To(HaveLen(1))
+			Expect(lease.OwnerReferences[0].Name).To(Equal("foo"))
+		})
+
+		It("prefers pods, which are labeled active already", func() {
+			pods[2] = newPod("foo-2", true)
+
+			Expect(elect()).To(Equal(map[string]string{"foo-2": "foo-active-0/0"}))
+		})
+
+		It("skips unhealthy pods", func() {
+			healthy["foo-0"] = false
+
+			Expect(elect()).To(Equal(map[string]string{"foo-1": "foo-active-0/0"}))
+		})
+
+		It("elects up to the maximum of active pods", func() {
+			qSts.Spec.ActivePassive.MaxActive = 2
+
+			Expect(elect()).To(Equal(map[string]string{
+				"foo-0": "foo-active-0/0",
+				"foo-1": "foo-active-1/0",
+			}))
+		})
+
+		It("elects no pod if none is healthy", func() {
+			healthy = map[string]bool{}
+
+			Expect(elect()).To(BeEmpty())
+		})
+	})
+
+	Context("when a lease is held", func() {
+		JustBeforeEach(func() {
+			pods[1] = newPod("foo-1", true)
+			Expect(elect()).To(Equal(map[string]string{"foo-1": "foo-active-0/0"}))
+		})
+
+		It("keeps the healthy holder", func() {
+			Expect(elect()).To(Equal(map[string]string{"foo-1": "foo-active-0/0"}))
+		})
+
+		It("hands the lease over and increments the transitions, if the holder fails", func() {
+			healthy["foo-1"] = false
+
+			Expect(elect()).To(Equal(map[string]string{"foo-0": "foo-active-0/1"}))
+			lease := getLease("foo-active-0")
+			Expect(*lease.Spec.HolderIdentity).To(Equal("foo-0"))
+			Expect(*lease.Spec.LeaseTransitions).To(Equal(int32(1)))
+		})
+
+		It("hands the lease over, if the holder is gone", func() {
+			pods = pods[:1]
+
+			Expect(elect()).To(Equal(map[string]string{"foo-0": "foo-active-0/1"}))
+		})
+
+		It("releases the lease, if no healthy pod is left", func() {
+			healthy = map[string]bool{}
+
+			Expect(elect()).To(BeEmpty())
+			Expect(getLease("foo-active-0").Spec.HolderIdentity).To(BeNil())
+
+			healthy["foo-2"] = true
+			Expect(elect()).To(Equal(map[string]string{"foo-2": "foo-active-0/1"}))
+		})
+
+		It("deletes leases above the maximum of active pods", func() {
+			qSts.Spec.ActivePassive.MaxActive = 2
+			Expect(elect()).To(HaveLen(2))
+
+			qSts.Spec.ActivePassive.MaxActive = 1
+			Expect(elect()).To(Equal(map[string]string{"foo-1": "foo-active-0/0"}))
+
+			leases := &coordinationv1.LeaseList{}
+			Expect(c.List(ctx, leases)).To(Succeed())
+			Expect(leases.Items).To(HaveLen(1))
+			Expect(leases.Items[0].Name).To(Equal("foo-active-0"))
+		})
+	})
+})
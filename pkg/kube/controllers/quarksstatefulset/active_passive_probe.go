@@ -0,0 +1,292 @@
+package quarksstatefulset
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+)
+
+const (
+	// defaultProbeTimeout is the timeout of a network probe, like the
+	// kubelet's default. Exec probes only time out after an explicit timeout.
+	defaultProbeTimeout = time.Second
+	// defaultProbeThreshold is the number of consecutive results needed to change the state of a probe
+	defaultProbeThreshold = 1
+)
+
+// ExecFunc runs a command in a container of the pod, it returns an error
+// once the context is done
+type ExecFunc func(ctx context.Context, pod *corev1.Pod, container string, command []string) error
+
+// ProbeRunner runs active/passive probes. HTTP, TCP and gRPC probes connect
+// to the pod IP directly, only exec probes run a command in the container.
+// Probes can't target other hosts, they would connect from the operator's
+// network identity.
+type ProbeRunner struct {
+	exec ExecFunc
+}
+
+// NewProbeRunner returns a new ProbeRunner, which runs exec probes with the exec func
+func NewProbeRunner(exec ExecFunc) *ProbeRunner {
+	return &ProbeRunner{exec: exec}
+}
+
+// Run returns an error if the container of the pod fails the probe
+func (p *ProbeRunner) Run(ctx context.Context, pod *corev1.Pod, container string, probe qstsv1a1.ActivePassiveProbe) error {
+	// Exec probes run through the API server, they only time out if the
+	// probe sets a timeout
+	if probe.TimeoutSeconds > 0 || probe.Exec == nil {
+		timeout := defaultProbeTimeout
+		if probe.TimeoutSeconds > 0 {
+			timeout = time.Duration(probe.TimeoutSeconds) * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	switch {
+	case probe.Exec != nil:
+		return p.exec(ctx, pod, container, probe.Exec.Command)
+	case probe.HTTPGet != nil:
+		return probeHTTP(ctx, pod, container, probe.HTTPGet)
+	case probe.TCPSocket != nil:
+		return probeTCP(ctx, pod, container, probe.TCPSocket)
+	case probe.GRPCHealthCheck != nil:
+		return probeGRPC(ctx, pod, probe.GRPCHealthCheck)
+	}
+	return errors.Errorf("active/passive probe of container '%s' has no handler", container)
+}
+
+func probeHTTP(ctx context.Context, pod *corev1.Pod, container string, action *corev1.HTTPGetAction) error {
+	port, err := resolvePort(pod, container, action.Port)
+	if err != nil {
+		return err
+	}
+	if action.Host != "" {
+		return errors.Errorf("http probe of pod '%s' can't set the host '%s', it probes the pod IP", pod.Name, action.Host)
+	}
+	host, err := probeHost(pod)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if action.Scheme == corev1.URISchemeHTTPS {
+		scheme = "https"
+	}
+
+	u := &url.URL{Scheme: scheme, Host: net.JoinHostPort(host, strconv.Itoa(port))}
+	path, err := url.Parse(action.Path)
+	if err != nil {
+		return errors.Wrapf(err, "invalid probe path '%s'", action.Path)
+	}
+	u = u.ResolveReference(path)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	for _, header := range action.HTTPHeaders {
+		if http.CanonicalHeaderKey(header.Name) == "Host" {
+			return errors.Errorf("http probe of pod '%s' can't set the Host header, it probes the pod IP", pod.Name)
+		}
+		req.Header.Add(header.Name, header.Value)
+	}
+
+	// Like the kubelet, certificates aren't verified and redirects aren't followed
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "http probe of pod '%s' failed", pod.Name)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("http probe of pod '%s' failed with status %d", pod.Name, resp.StatusCode)
+	}
+	return nil
+}
+
+func probeTCP(ctx context.Context, pod *corev1.Pod, container string, action *corev1.TCPSocketAction) error {
+	port, err := resolvePort(pod, container, action.Port)
+	if err != nil {
+		return err
+	}
+	if action.Host != "" {
+		return errors.Errorf("tcp probe of pod '%s' can't set the host '%s', it probes the pod IP", pod.Name, action.Host)
+	}
+	host, err := probeHost(pod)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return errors.Wrapf(err, "tcp probe of pod '%s' failed", pod.Name)
+	}
+	return conn.Close()
+}
+
+func probeGRPC(ctx context.Context, pod *corev1.Pod, action *qstsv1a1.GRPCAction) error {
+	host, err := probeHost(pod)
+	if err != nil {
+		return err
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(int(action.Port)))
+	conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return errors.Wrapf(err, "grpc probe of pod '%s' failed to connect", pod.Name)
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: action.Service})
+	if err != nil {
+		return errors.Wrapf(err, "grpc probe of pod '%s' failed", pod.Name)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return errors.Errorf("grpc probe of pod '%s' failed with status %s", pod.Name, resp.Status)
+	}
+	return nil
+}
+
+// probeHost returns the pod IP, which is the host of all network probes. It
+// fails if the pod has no IP yet, rather than probing the operator's own host.
+func probeHost(pod *corev1.Pod) (string, error) {
+	if pod.Status.PodIP == "" {
+		return "", errors.Errorf("pod '%s' has no IP to probe", pod.Name)
+	}
+	return pod.Status.PodIP, nil
+}
+
+// resolvePort returns the port number, looking up named ports in the container spec
+func resolvePort(pod *corev1.Pod, container string, port intstr.IntOrString) (int, error) {
+	if port.Type == intstr.Int {
+		return port.IntValue(), nil
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name != container {
+			continue
+		}
+		for _, p := range c.Ports {
+			if p.Name == port.StrVal {
+				return int(p.ContainerPort), nil
+			}
+		}
+	}
+	if number, err := strconv.Atoi(port.StrVal); err == nil {
+		return number, nil
+	}
+	return 0, errors.Errorf("port '%s' not found in container '%s' of pod '%s'", port.StrVal, container, pod.Name)
+}
+
+// probeState counts the consecutive results of a probe
+type probeState struct {
+	passing   bool
+	successes int32
+	failures  int32
+}
+
+// ProbeTracker applies the success and failure thresholds of the probes to
+// their results. Probes are tracked per pod UID and container, grouped by
+// the QuarksStatefulSet owning the pods.
+type ProbeTracker struct {
+	mutex  sync.Mutex
+	states map[string]map[string]*probeState
+}
+
+// NewProbeTracker returns a new ProbeTracker
+func NewProbeTracker() *ProbeTracker {
+	return &ProbeTracker{states: map[string]map[string]*probeState{}}
+}
+
+// Update records the result of a probe and returns whether the probe is
+// passing. The state changes after FailureThreshold consecutive failures or
+// SuccessThreshold consecutive successes. Initially pods labeled active are
+// passing, so a restart of the operator doesn't cause a fail over.
+func (t *ProbeTracker) Update(owner string, pod *corev1.Pod, container string, probe qstsv1a1.ActivePassiveProbe, result error) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.states[owner] == nil {
+		t.states[owner] = map[string]*probeState{}
+	}
+	key := probeKey(pod, container)
+	state, ok := t.states[owner][key]
+	if !ok {
+		state = &probeState{passing: isActive(pod)}
+		t.states[owner][key] = state
+	}
+
+	if result == nil {
+		state.successes++
+		state.failures = 0
+		if state.successes >= threshold(probe.SuccessThreshold) {
+			state.passing = true
+		}
+	} else {
+		state.failures++
+		state.successes = 0
+		if state.failures >= threshold(probe.FailureThreshold) {
+			state.passing = false
+		}
+	}
+	return state.passing
+}
+
+// Prune forgets the probes of pods, which are gone
+func (t *ProbeTracker) Prune(owner string, pods []corev1.Pod) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	uids := map[string]struct{}{}
+	for _, pod := range pods {
+		uids[string(pod.UID)] = struct{}{}
+	}
+	for key := range t.states[owner] {
+		uid := strings.SplitN(key, "/", 2)[0]
+		if _, ok := uids[uid]; !ok {
+			delete(t.states[owner], key)
+		}
+	}
+}
+
+// Forget forgets all probes of the owner
+func (t *ProbeTracker) Forget(owner string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.states, owner)
+}
+
+func probeKey(pod *corev1.Pod, container string) string {
+	return fmt.Sprintf("%s/%s", pod.UID, container)
+}
+
+func threshold(value int32) int32 {
+	if value < 1 {
+		return defaultProbeThreshold
+	}
+	return value
+}
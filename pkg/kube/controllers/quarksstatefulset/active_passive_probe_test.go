@@ -0,0 +1,296 @@
+package quarksstatefulset_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	qstscontroller "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarksstatefulset"
+)
+
+var _ = Describe("ProbeRunner", func() {
+	var (
+		runner   *qstscontroller.ProbeRunner
+		pod      *corev1.Pod
+		probe    qstsv1a1.ActivePassiveProbe
+		commands [][]string
+		execErr  error
+		// execBlocks makes the exec func wait for the probe timeout
+		execBlocks bool
+		// execDeadline records whether the exec func got a deadline
+		execDeadline bool
+	)
+
+	port := func(address string) int {
+		_, p, err := net.SplitHostPort(address)
+		Expect(err).ToNot(HaveOccurred())
+		number, err := strconv.Atoi(p)
+		Expect(err).ToNot(HaveOccurred())
+		return number
+	}
+
+	BeforeEach(func() {
+		commands = [][]string{}
+		execErr = nil
+		execBlocks = false
+		runner = qstscontroller.NewProbeRunner(func(ctx context.Context, _ *corev1.Pod, _ string, command []string) error {
+			commands = append(commands, command)
+			_, execDeadline = ctx.Deadline()
+			if execBlocks {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return execErr
+		})
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-0"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Ports: []corev1.ContainerPort{{Name: "health", ContainerPort: 8080}}}},
+			},
+			Status: corev1.PodStatus{PodIP: "127.0.0.1"},
+		}
+		probe = qstsv1a1.ActivePassiveProbe{}
+	})
+
+	act := func() error {
+		return runner.Run(context.Background(), pod, "app", probe)
+	}
+
+	Context("with an exec probe", func() {
+		BeforeEach(func() {
+			probe.Exec = &corev1.ExecAction{Command: []string{"check"}}
+		})
+
+		It("runs the command in the container", func() {
+			Expect(act()).To(Succeed())
+			Expect(commands).To(Equal([][]string{{"check"}}))
+		})
+
+		It("fails if the command fails", func() {
+			execErr = fmt.Errorf("exit 1")
+			Expect(act()).To(HaveOccurred())
+		})
+
+		It("fails if the command doesn't finish within the timeout", func() {
+			execBlocks = true
+			probe.TimeoutSeconds = 1
+			start := time.Now()
+			Expect(act()).To(MatchError(context.DeadlineExceeded))
+			Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+		})
+
+		It("doesn't time out without a timeout", func() {
+			Expect(act()).To(Succeed())
+			Expect(execDeadline).To(BeFalse())
+		})
+	})
+
+	Context("with an http probe", func() {
+		var (
+			server *httptest.Server
+			status int
+		)
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/active" || r.Header.Get("X-Probe") != "quarks" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.WriteHeader(status)
+			}))
+			probe.HTTPGet = &corev1.HTTPGetAction{
+				Path:        "/active",
+				Port:        intstr.FromInt(port(server.Listener.Addr().String())),
+				HTTPHeaders: []corev1.HTTPHeader{{Name: "X-Probe", Value: "quarks"}},
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("requests the path from the pod IP", func() {
+			Expect(act()).To(Succeed())
+			Expect(commands).To(BeEmpty())
+		})
+
+		It("fails on error status codes", func() {
+			status = http.StatusServiceUnavailable
+			Expect(act()).To(MatchError(ContainSubstring("status 503")))
+		})
+
+		It("resolves named ports of the container", func() {
+			pod.Spec.Containers[0].Ports[0].ContainerPort = int32(port(server.Listener.Addr().String()))
+			probe.HTTPGet.Port = intstr.FromString("health")
+			Expect(act()).To(Succeed())
+		})
+
+		It("fails for unknown named ports", func() {
+			probe.HTTPGet.Port = intstr.FromString("unknown")
+			Expect(act()).To(MatchError(ContainSubstring("port 'unknown' not found")))
+		})
+
+		It("fails if the pod has no IP", func() {
+			pod.Status.PodIP = ""
+			Expect(act()).To(MatchError(ContainSubstring("pod 'foo-0' has no IP to probe")))
+		})
+
+		It("fails if the probe sets a host", func() {
+			probe.HTTPGet.Host = "169.254.169.254"
+			Expect(act()).To(MatchError("http probe of pod 'foo-0' can't set the host '169.254.169.254', it probes the pod IP"))
+		})
+
+		It("fails if the probe sets the Host header", func() {
+			probe.HTTPGet.HTTPHeaders = append(probe.HTTPGet.HTTPHeaders, corev1.HTTPHeader{Name: "host", Value: "admin.example.com"})
+			Expect(act()).To(MatchError(ContainSubstring("can't set the Host header")))
+		})
+	})
+
+	Context("with a tcp probe", func() {
+		var listener net.Listener
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			probe.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt(port(listener.Addr().String()))}
+		})
+
+		It("connects to the pod IP", func() {
+			Expect(act()).To(Succeed())
+			Expect(listener.Close()).To(Succeed())
+		})
+
+		It("fails if the port is closed", func() {
+			Expect(listener.Close()).To(Succeed())
+			Expect(act()).To(HaveOccurred())
+		})
+
+		It("fails if the pod has no IP", func() {
+			pod.Status.PodIP = ""
+			Expect(act()).To(MatchError(ContainSubstring("pod 'foo-0' has no IP to probe")))
+			Expect(listener.Close()).To(Succeed())
+		})
+
+		It("fails if the probe sets a host", func() {
+			probe.TCPSocket.Host = "10.0.0.1"
+			Expect(act()).To(MatchError(ContainSubstring("can't set the host '10.0.0.1'")))
+			Expect(listener.Close()).To(Succeed())
+		})
+	})
+
+	Context("with a grpc probe", func() {
+		var (
+			server       *grpc.Server
+			healthServer *health.Server
+		)
+
+		BeforeEach(func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			server = grpc.NewServer()
+			healthServer = health.NewServer()
+			healthpb.RegisterHealthServer(server, healthServer)
+			go server.Serve(listener)
+
+			probe.GRPCHealthCheck = &qstsv1a1.GRPCAction{Port: int32(port(listener.Addr().String())), Service: "db"}
+		})
+
+		AfterEach(func() {
+			server.Stop()
+		})
+
+		It("succeeds if the service is serving", func() {
+			healthServer.SetServingStatus("db", healthpb.HealthCheckResponse_SERVING)
+			Expect(act()).To(Succeed())
+		})
+
+		It("fails if the service is not serving", func() {
+			healthServer.SetServingStatus("db", healthpb.HealthCheckResponse_NOT_SERVING)
+			Expect(act()).To(MatchError(ContainSubstring("NOT_SERVING")))
+		})
+
+		It("fails if the pod has no IP", func() {
+			pod.Status.PodIP = ""
+			Expect(act()).To(MatchError(ContainSubstring("pod 'foo-0' has no IP to probe")))
+		})
+	})
+
+	It("fails without a handler", func() {
+		Expect(act()).To(MatchError(ContainSubstring("has no handler")))
+	})
+})
+
+var _ = Describe("ProbeTracker", func() {
+	var (
+		tracker *qstscontroller.ProbeTracker
+		pod     *corev1.Pod
+		probe   qstsv1a1.ActivePassiveProbe
+		failed  = fmt.Errorf("failed")
+	)
+
+	BeforeEach(func() {
+		tracker = qstscontroller.NewProbeTracker()
+		pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo-0", UID: "uid-0", Labels: map[string]string{}}}
+		probe = qstsv1a1.ActivePassiveProbe{}
+	})
+
+	update := func(result error) bool {
+		return tracker.Update("default/foo", pod, "app", probe, result)
+	}
+
+	It("follows the results by default", func() {
+		Expect(update(nil)).To(BeTrue())
+		Expect(update(failed)).To(BeFalse())
+		Expect(update(nil)).To(BeTrue())
+	})
+
+	It("applies the failure threshold", func() {
+		probe.FailureThreshold = 3
+		Expect(update(nil)).To(BeTrue())
+		Expect(update(failed)).To(BeTrue())
+		Expect(update(failed)).To(BeTrue())
+		Expect(update(failed)).To(BeFalse())
+	})
+
+	It("applies the success threshold", func() {
+		probe.SuccessThreshold = 2
+		Expect(update(nil)).To(BeFalse())
+		Expect(update(failed)).To(BeFalse())
+		Expect(update(nil)).To(BeFalse())
+		Expect(update(nil)).To(BeTrue())
+	})
+
+	It("starts with pods labeled active passing", func() {
+		pod.Labels[qstsv1a1.LabelActivePod] = "active"
+		probe.FailureThreshold = 2
+		Expect(update(failed)).To(BeTrue())
+		Expect(update(failed)).To(BeFalse())
+	})
+
+	It("forgets pods, which are gone", func() {
+		probe.FailureThreshold = 2
+		Expect(update(nil)).To(BeTrue())
+		Expect(update(failed)).To(BeTrue())
+
+		tracker.Prune("default/foo", []corev1.Pod{})
+		Expect(update(failed)).To(BeFalse())
+	})
+})
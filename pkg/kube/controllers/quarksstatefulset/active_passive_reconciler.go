@@ -1,9 +1,16 @@
 package quarksstatefulset
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
@@ -14,29 +21,38 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/httpstream"
 	"k8s.io/client-go/kubernetes"
 	clientscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// maxConcurrentProbes limits the pods probed at the same time
+const maxConcurrentProbes = 10
+
 // NewActivePassiveReconciler returns a new reconcile.Reconciler for the active/passive controller
 func NewActivePassiveReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, kclient kubernetes.Interface) reconcile.Reconciler {
-	return &ReconcileStatefulSetActivePassive{
+	r := &ReconcileStatefulSetActivePassive{
 		ctx:        ctx,
 		config:     config,
 		client:     mgr.GetClient(),
 		kclient:    kclient,
 		scheme:     mgr.GetScheme(),
 		restConfig: mgr.GetConfig(),
+		tracker:    NewProbeTracker(),
 	}
+	r.prober = NewProbeRunner(r.execContainerCmd)
+	return r
 }
 
 // ReconcileStatefulSetActivePassive reconciles an QuarksStatefulSet object when references change
@@ -47,6 +63,8 @@ type ReconcileStatefulSetActivePassive struct {
 	scheme     *runtime.Scheme
 	config     *config.Config
 	restConfig *restclient.Config
+	prober     *ProbeRunner
+	tracker    *ProbeTracker
 }
 
 // Reconcile reads the state of the cluster for a QuarksStatefulSet object
@@ -67,6 +85,7 @@ func (r *ReconcileStatefulSetActivePassive) Reconcile(request reconcile.Request)
 		if apierrors.IsNotFound(err) {
 			// Reconcile successful - don't requeue
 			ctxlog.Infof(ctx, "Failed to find quarks statefulset '%s', not retrying: %s", request.NamespacedName, err)
+			r.tracker.Forget(request.NamespacedName.String())
 			return reconcile.Result{}, nil
 		}
 		// Reconcile failed due to error - requeue
@@ -86,72 +105,157 @@ func (r *ReconcileStatefulSetActivePassive) Reconcile(request reconcile.Request)
 		return reconcile.Result{}, errors.Wrapf(err, "couldn't retrieve pod items from sts: %s", qSts.Name)
 	}
 
-	// retrieves the ActivePassiveProbe children key,
-	// this is the container name in where the ActivePassiveProbe
-	// cmd, needs to be executed
-	containerName, err := getProbeContainerName(qSts.Spec.ActivePassiveProbes)
-	if err != nil {
+	if len(qSts.Spec.ActivePassiveProbes) == 0 {
 		// Reconcile failed due to error - requeue
-		return reconcile.Result{}, errors.Wrapf(err, "None container name found in probe for %s QuarksStatefulSet", qSts.Name)
+		return reconcile.Result{}, errors.Errorf("no active/passive probe found for %s QuarksStatefulSet", qSts.Name)
 	}
 
-	err = r.markActiveContainers(ctx, containerName, ownedPods, qSts)
-	if err != nil {
-		// Reconcile failed due to error - requeue
-		return reconcile.Result{}, err
-	}
-
-	periodSeconds := time.Second * time.Duration(qSts.Spec.ActivePassiveProbes[containerName].PeriodSeconds)
+	periodSeconds := probePeriod(qSts.Spec.ActivePassiveProbes)
 	if periodSeconds == (time.Second * time.Duration(0)) {
 		ctxlog.WithEvent(qSts, "active-passive").Debugf(ctx, "periodSeconds probe was not specified, going to default to 30 secs")
 		periodSeconds = time.Second * 30
 	}
 
+	err = r.markActiveContainers(ctx, ownedPods, qSts, periodSeconds)
+	if err != nil {
+		// Reconcile failed due to error - requeue
+		return reconcile.Result{}, err
+	}
+
 	// Reconcile for any reason than error after the ActivePassiveProbe PeriodSeconds
 	return reconcile.Result{RequeueAfter: periodSeconds}, nil
 }
 
-func (r *ReconcileStatefulSetActivePassive) markActiveContainers(ctx context.Context, container string, pods *corev1.PodList, qSts *qstsv1a1.QuarksStatefulSet) (err error) {
+// markActiveContainers labels the pods passing the probes of all their
+// containers as active. In exclusive mode only the pods holding a lease are
+// labeled. Pods are always demoted before other pods are promoted, so a
+// failed over pod is fenced off before its successor becomes active.
+func (r *ReconcileStatefulSetActivePassive) markActiveContainers(ctx context.Context, pods *corev1.PodList, qSts *qstsv1a1.QuarksStatefulSet, period time.Duration) (err error) {
+	passed := r.probePods(ctx, pods.Items, qSts)
+
+	// maps the active pods to their fencing token
+	active := map[string]string{}
+	if qSts.IsExclusiveActivePassive() {
+		// a lease is held for three periods, so missing a single renewal isn't fatal for observers
+		active, err = NewLeaseElection(r.client, r.scheme).Elect(ctx, qSts, pods.Items, healthyPods(pods.Items, passed), 3*period)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't elect active pods for %s QuarksStatefulSet", qSts.Name)
+		}
+	} else {
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			// pods passing the probe, which are not ready yet, keep their label
+			if passed[pod.Name] && (podutil.IsPodReady(pod) || isActive(pod)) {
+				active[pod.Name] = ""
+			}
+		}
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if _, ok := active[pod.Name]; ok {
+			continue
+		}
+		// mark as passive
+		if err := r.deleteActiveLabel(ctx, pod, qSts); err != nil {
+			return errors.Wrapf(err, "couldn't remove label from active pod %s", pod.Name)
+		}
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		token, ok := active[pod.Name]
+		if !ok {
+			continue
+		}
+		// mark as active
+		if err := r.addActiveLabel(ctx, pod, qSts, token); err != nil {
+			return errors.Wrapf(err, "couldn't label pod %s as active", pod.Name)
+		}
+	}
+
+	return r.updateActivePodsStatus(ctx, qSts, active)
+}
+
+// probePods runs the probes of all containers against the pods concurrently
+// and returns the pods passing all of them.
+func (r *ReconcileStatefulSetActivePassive) probePods(ctx context.Context, pods []corev1.Pod, qSts *qstsv1a1.QuarksStatefulSet) map[string]bool {
+	owner := fmt.Sprintf("%s/%s", qSts.Namespace, qSts.Name)
+	r.tracker.Prune(owner, pods)
 
-	probeCmd := qSts.Spec.ActivePassiveProbes[container].Exec.Command
+	containers := make([]string, 0, len(qSts.Spec.ActivePassiveProbes))
+	for container := range qSts.Spec.ActivePassiveProbes {
+		containers = append(containers, container)
+	}
+	sort.Strings(containers)
 
-	for _, pod := range pods.Items {
-		ctxlog.WithEvent(qSts, "active-passive").Debugf(ctx, "validating probe in pod: %s", pod.Name)
-		if err := r.execContainerCmd(&pod, container, probeCmd); err != nil {
-			ctxlog.WithEvent(qSts, "active-passive").Debugf(
-				ctx,
-				"failed to execute active/passive probe: %s",
-				err,
-			)
-			// mark as passive
-			err := r.deleteActiveLabel(ctx, &pod, qSts)
-			if err != nil {
-				return errors.Wrapf(err, "couldn't remove label from active pod %s", pod.Name)
-			}
-		} else {
-			if podutil.IsPodReady(&pod) {
-				// mark as active
-				err := r.addActiveLabel(ctx, &pod, qSts)
-				if err != nil {
-					return errors.Wrapf(err, "couldn't label pod %s as active", pod.Name)
+	var (
+		mutex  sync.Mutex
+		wg     sync.WaitGroup
+		passed = map[string]bool{}
+		limit  = make(chan struct{}, maxConcurrentProbes)
+	)
+	for i := range pods {
+		pod := &pods[i]
+		wg.Add(1)
+		limit <- struct{}{}
+		go func() {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+
+			ok := true
+			for _, container := range containers {
+				probe := qSts.Spec.ActivePassiveProbes[container]
+				ctxlog.WithEvent(qSts, "active-passive").Debugf(ctx, "validating probe in pod: %s, container: %s", pod.Name, container)
+				result := r.prober.Run(ctx, pod, container, probe)
+				if result != nil {
+					ctxlog.WithEvent(qSts, "active-passive").Debugf(
+						ctx,
+						"failed to execute active/passive probe: %s",
+						result,
+					)
+				}
+				// every probe is run, so its thresholds are counted
+				if !r.tracker.Update(owner, pod, container, probe, result) {
+					ok = false
 				}
 			}
-		}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			passed[pod.Name] = ok
+		}()
 	}
-	return nil
+	wg.Wait()
+
+	return passed
 }
 
-func (r *ReconcileStatefulSetActivePassive) addActiveLabel(ctx context.Context, p *corev1.Pod, qSts *qstsv1a1.QuarksStatefulSet) error {
+func (r *ReconcileStatefulSetActivePassive) addActiveLabel(ctx context.Context, p *corev1.Pod, qSts *qstsv1a1.QuarksStatefulSet, token string) error {
 	podLabels := p.GetLabels()
 	if podLabels == nil {
 		podLabels = map[string]string{}
 	}
+	podAnnotations := p.GetAnnotations()
+	if podAnnotations == nil {
+		podAnnotations = map[string]string{}
+	}
 
-	if _, found := podLabels[qstsv1a1.LabelActivePod]; found {
+	_, found := podLabels[qstsv1a1.LabelActivePod]
+	if found && podAnnotations[qstsv1a1.AnnotationActiveLease] == token {
 		return nil
 	}
 
 	podLabels[qstsv1a1.LabelActivePod] = "active"
+	p.SetLabels(podLabels)
+	if token == "" {
+		delete(podAnnotations, qstsv1a1.AnnotationActiveLease)
+	} else {
+		podAnnotations[qstsv1a1.AnnotationActiveLease] = token
+	}
+	p.SetAnnotations(podAnnotations)
 
 	return r.updatePodLabels(ctx, p, qSts, "active")
 }
@@ -163,12 +267,13 @@ func (r *ReconcileStatefulSetActivePassive) deleteActiveLabel(ctx context.Contex
 		return nil
 	}
 	delete(podLabels, qstsv1a1.LabelActivePod)
+	delete(p.GetAnnotations(), qstsv1a1.AnnotationActiveLease)
 
 	return r.updatePodLabels(ctx, p, qSts, "passive")
 }
 
 func (r *ReconcileStatefulSetActivePassive) updatePodLabels(ctx context.Context, p *corev1.Pod, qSts *qstsv1a1.QuarksStatefulSet, mode string) error {
-	err := r.client.Update(r.ctx, p)
+	err := r.client.Update(ctx, p)
 	if err != nil {
 		return err
 	}
@@ -182,7 +287,31 @@ func (r *ReconcileStatefulSetActivePassive) updatePodLabels(ctx context.Context,
 	return nil
 }
 
-func (r *ReconcileStatefulSetActivePassive) execContainerCmd(pod *corev1.Pod, container string, command []string) error {
+// updateActivePodsStatus records the active pods in the status of the
+// QuarksStatefulSet, if they changed.
+func (r *ReconcileStatefulSetActivePassive) updateActivePodsStatus(ctx context.Context, qSts *qstsv1a1.QuarksStatefulSet, active map[string]string) error {
+	activePods := make([]string, 0, len(active))
+	for name := range active {
+		activePods = append(activePods, name)
+	}
+	sort.Strings(activePods)
+
+	if reflect.DeepEqual(activePods, qSts.Status.ActivePods) || (len(activePods) == 0 && len(qSts.Status.ActivePods) == 0) {
+		return nil
+	}
+
+	now := metav1.Now()
+	qSts.Status.ActivePods = activePods
+	qSts.Status.LastActiveTransition = &now
+	if err := r.client.Status().Update(ctx, qSts); err != nil {
+		return errors.Wrapf(err, "couldn't update active pods in status of %s QuarksStatefulSet", qSts.Name)
+	}
+
+	ctxlog.WithEvent(qSts, "ActivePods").Infof(ctx, "Active pods of QuarksStatefulSet '%s' changed to %v", qSts.Name, activePods)
+	return nil
+}
+
+func (r *ReconcileStatefulSetActivePassive) execContainerCmd(ctx context.Context, pod *corev1.Pod, container string, command []string) error {
 	req := r.kclient.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(pod.Name).
@@ -191,20 +320,39 @@ func (r *ReconcileStatefulSetActivePassive) execContainerCmd(pod *corev1.Pod, co
 		VersionedParams(&corev1.PodExecOptions{
 			Container: container,
 			Command:   command,
-			Stdin:     true,
+			Stdin:     false,
 			Stdout:    true,
 			Stderr:    false,
 		}, clientscheme.ParameterCodec)
 
-	executor, err := remotecommand.NewSPDYExecutor(r.restConfig, "POST", req.URL())
+	transport, upgrader, err := spdy.RoundTripperFor(r.restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize remote command transport")
+	}
+	conn := &closableUpgrader{Upgrader: upgrader}
+	executor, err := remotecommand.NewSPDYExecutorForTransports(transport, conn, "POST", req.URL())
 	if err != nil {
 		return errors.New("failed to initialize remote command executor")
 	}
-	if err = executor.Stream(remotecommand.StreamOptions{Stdin: os.Stdin, Stdout: os.Stdout, Tty: false}); err != nil {
-		return errors.Wrapf(err, "failed executing command in pod: %s, container: %s in namespace: %s",
+
+	var stdout bytes.Buffer
+	result := make(chan error, 1)
+	go func() {
+		result <- executor.Stream(remotecommand.StreamOptions{Stdout: &stdout, Tty: false})
+	}()
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		// Closing the connection ends the stream of the timed out probe
+		conn.Close()
+		err = ctx.Err()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed executing command in pod: %s, container: %s in namespace: %s, output: %s",
 			pod.Name,
 			container,
 			pod.Namespace,
+			strings.TrimSpace(stdout.String()),
 		)
 	}
 	ctxlog.Info(r.ctx, "Succesfully exec cmd in container: ", container, ", inside pod: ", pod.Name)
@@ -212,6 +360,43 @@ func (r *ReconcileStatefulSetActivePassive) execContainerCmd(pod *corev1.Pod, co
 	return nil
 }
 
+// closableUpgrader keeps the connection of a remote command, so it can be
+// closed if the command takes too long
+type closableUpgrader struct {
+	spdy.Upgrader
+
+	mutex  sync.Mutex
+	conn   httpstream.Connection
+	closed bool
+}
+
+// NewConnection creates the connection of the remote command. It is closed
+// right away if the upgrader was closed before.
+func (u *closableUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := u.Upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.conn = conn
+	if u.closed {
+		conn.Close()
+	}
+	return conn, nil
+}
+
+// Close closes the connection of the remote command
+func (u *closableUpgrader) Close() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.closed = true
+	if u.conn != nil {
+		u.conn.Close()
+	}
+}
+
 func (r *ReconcileStatefulSetActivePassive) getStsPodList(ctx context.Context, desiredSts *appsv1.StatefulSet) (*corev1.PodList, error) {
 	podList := &corev1.PodList{}
 	stsSelector := labels.SelectorFromSet(labels.Set(map[string]string{qstsv1a1.LabelQStsName: desiredSts.Name}))
@@ -226,11 +411,16 @@ func (r *ReconcileStatefulSetActivePassive) getStsPodList(ctx context.Context, d
 	return podList, nil
 }
 
-func getProbeContainerName(p map[string]corev1.Probe) (string, error) {
-	for key := range p {
-		return key, nil
+// probePeriod returns the shortest period of the probes
+func probePeriod(probes map[string]qstsv1a1.ActivePassiveProbe) time.Duration {
+	period := time.Duration(0)
+	for _, probe := range probes {
+		p := time.Second * time.Duration(probe.PeriodSeconds)
+		if p > 0 && (period == 0 || p < period) {
+			period = p
+		}
 	}
-	return "", errors.New("failed to find a container key in the active/passive probe in the current QuarksStatefulSet")
+	return period
 }
 
 // KubeConfig returns a kube config for this environment
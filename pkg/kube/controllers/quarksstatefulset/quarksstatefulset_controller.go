@@ -71,7 +71,8 @@ func AddQuarksStatefulSet(ctx context.Context, config *config.Config, mgr manage
 		UpdateFunc: func(e event.UpdateEvent) bool {
 			o := e.ObjectOld.(*qstsv1a1.QuarksStatefulSet)
 			n := e.ObjectNew.(*qstsv1a1.QuarksStatefulSet)
-			if !reflect.DeepEqual(o.Spec, n.Spec) {
+			// Scaling is handled by the scale controller
+			if !reflect.DeepEqual(o.Spec, n.Spec) && !isScaleOnly(o, n) {
 				ctxlog.NewPredicateEvent(e.ObjectNew).Debug(
 					ctx, e.MetaNew, "qstsv1a1.QuarksStatefulSet",
 					fmt.Sprintf("Update predicate passed for '%s'", e.MetaNew.GetName()),
@@ -0,0 +1,44 @@
+package quarksstatefulset
+
+import (
+	"context"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/prune"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// pruneStatefulSets removes owned StatefulSets, which are not desired
+// anymore, e.g. the StatefulSets of zones removed from the spec.
+func (r *ReconcileQuarksStatefulSet) pruneStatefulSets(ctx context.Context, qStatefulSet *qstsv1a1.QuarksStatefulSet, desiredStatefulSets []appsv1.StatefulSet) error {
+	desired := map[string]struct{}{}
+	for _, statefulSet := range desiredStatefulSets {
+		desired[statefulSet.Name] = struct{}{}
+	}
+
+	statefulSets, err := listStatefulSetsFromInformer(ctx, r.client, qStatefulSet)
+	if err != nil {
+		return err
+	}
+
+	stale := []apis.Object{}
+	for i := range statefulSets {
+		if _, ok := desired[statefulSets[i].Name]; !ok {
+			stale = append(stale, &statefulSets[i])
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	pruned, err := prune.Objects(ctx, r.client, qStatefulSet, qStatefulSet.Spec.PrunePolicy, "StatefulSet", stale)
+	if len(pruned) > 0 {
+		ctxlog.WithEvent(qStatefulSet, "Pruned").Infof(ctx, "%s resources no longer desired by QuarksStatefulSet '%s': %s",
+			prune.Verb(qStatefulSet.Spec.PrunePolicy), qStatefulSet.Name, strings.Join(pruned, ", "))
+	}
+	return err
+}
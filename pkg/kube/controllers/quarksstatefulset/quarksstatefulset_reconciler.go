@@ -2,6 +2,7 @@ package quarksstatefulset
 
 import (
 	"context"
+	"crypto/sha1"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -14,6 +15,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -121,6 +123,15 @@ func (r *ReconcileQuarksStatefulSet) Reconcile(request reconcile.Request) (recon
 		// If it doesn't exist, create it
 		ctxlog.Info(ctx, "StatefulSet '", desiredStatefulSet.Name, "' owned by QuarksStatefulSet '", request.NamespacedName, "' not found, will be created.")
 
+		rolledBack, err := r.isRolledBack(ctx, &desiredStatefulSet)
+		if err != nil {
+			return reconcile.Result{}, ctxlog.WithEvent(qStatefulSet, "GetStatefulSetError").Error(ctx, "Could not get StatefulSet of QuarksStatefulSet '", request.NamespacedName, "': ", err)
+		}
+		if rolledBack {
+			ctxlog.WithEvent(qStatefulSet, "RolledBack").Infof(ctx, "Keeping rolled back StatefulSet '%s' of QuarksStatefulSet '%s', its pod template didn't change since the failed rollout", desiredStatefulSet.Name, request.NamespacedName)
+			continue
+		}
+
 		if err = r.versionedSecretStore.SetSecretReferences(ctx, request.Namespace, &qStatefulSet.Spec.Template.Spec.Template.Spec); err != nil {
 			return reconcile.Result{}, ctxlog.WithEvent(qStatefulSet, "UpdateVersionedSecretReferencesError").Error(ctx, "Could not update versioned secret references in pod spec for QuarksStatefulSet '", request.NamespacedName, "': ", err)
 		}
@@ -129,6 +140,20 @@ func (r *ReconcileQuarksStatefulSet) Reconcile(request reconcile.Request) (recon
 		}
 	}
 
+	// Remove StatefulSets, which are not desired anymore, e.g. of removed zones
+	if err := r.pruneStatefulSets(ctx, qStatefulSet, desiredStatefulSets); err != nil {
+		return reconcile.Result{}, ctxlog.WithEvent(qStatefulSet, "PruneStatefulSetError").Error(ctx, "Could not prune StatefulSets of QuarksStatefulSet '", request.NamespacedName, "': ", err)
+	}
+
+	// Report the replicas and the pod selector for the scale subresource
+	qStatefulSet.Status.Replicas = 0
+	for _, desiredStatefulSet := range desiredStatefulSets {
+		if desiredStatefulSet.Spec.Replicas != nil {
+			qStatefulSet.Status.Replicas += *desiredStatefulSet.Spec.Replicas
+		}
+	}
+	qStatefulSet.Status.Selector = labels.SelectorFromSet(labels.Set{qstsv1a1.LabelQStsName: qStatefulSet.Name}).String()
+
 	now := metav1.Now()
 	qStatefulSet.Status.LastReconcile = &now
 	err = r.client.Status().Update(ctx, qStatefulSet)
@@ -225,6 +250,37 @@ func (r *ReconcileQuarksStatefulSet) createStatefulSet(ctx context.Context, qSta
 	return nil
 }
 
+// isRolledBack returns true if the existing StatefulSet was rolled back after
+// a failed rollout of the desired pod template. The rollback is kept until the
+// template changes or the rollout is retried.
+func (r *ReconcileQuarksStatefulSet) isRolledBack(ctx context.Context, desired *appsv1.StatefulSet) (bool, error) {
+	existing := &appsv1.StatefulSet{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, existing)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if _, ok := existing.Annotations[statefulset.AnnotationRolledBackRevision]; !ok {
+		return false, nil
+	}
+	return existing.Annotations[qstsv1a1.AnnotationTemplateHash] == desired.Annotations[qstsv1a1.AnnotationTemplateHash], nil
+}
+
+// templateHash returns the hash of the StatefulSet's pod template without
+// its version, which changes on every reconcile
+func templateHash(statefulSet *appsv1.StatefulSet) (string, error) {
+	template := statefulSet.Spec.Template.DeepCopy()
+	delete(template.Annotations, qstsv1a1.AnnotationVersion)
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha1.Sum(data)), nil
+}
+
 // generateSingleStatefulSet creates a StatefulSet from one zone
 func (r *ReconcileQuarksStatefulSet) generateSingleStatefulSet(qStatefulSet *qstsv1a1.QuarksStatefulSet, template *appsv1.StatefulSet, zoneIndex int, zoneName string, version int) (*appsv1.StatefulSet, error) {
 	statefulSet := template.DeepCopy()
@@ -263,8 +319,16 @@ func (r *ReconcileQuarksStatefulSet) generateSingleStatefulSet(qStatefulSet *qst
 		MatchLabels: labels,
 	}
 	statefulSet.SetAnnotations(util.UnionMaps(statefulSet.GetAnnotations(), annotations))
+	statefulSet.Spec.Replicas = qStatefulSet.GetZoneReplicas(zoneIndex)
 
+	// The env keeps the replicas of the template, so scaling doesn't restart all pods
 	r.injectContainerEnv(&statefulSet.Spec.Template.Spec, zoneIndex, zoneName, qStatefulSet.Spec.Template.Spec.Replicas)
+
+	hash, err := templateHash(statefulSet)
+	if err != nil {
+		return &appsv1.StatefulSet{}, errors.Wrap(err, "Could not hash pod template")
+	}
+	statefulSet.Annotations[qstsv1a1.AnnotationTemplateHash] = hash
 	return statefulSet, nil
 }
 
@@ -331,7 +395,9 @@ func (r *ReconcileQuarksStatefulSet) injectContainerEnv(podSpec *corev1.PodSpec,
 			// Default to zone 1
 			envs = upsertEnvs(envs, EnvCFOperatorAZIndex, "1")
 		}
-		envs = upsertEnvs(envs, EnvReplicas, strconv.Itoa(int(*replicas)))
+		if replicas != nil {
+			envs = upsertEnvs(envs, EnvReplicas, strconv.Itoa(int(*replicas)))
+		}
 
 		container.Env = envs
 	}
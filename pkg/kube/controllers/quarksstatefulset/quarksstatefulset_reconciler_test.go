@@ -21,10 +21,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
 	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
 	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
 	qstscontroller "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarksstatefulset"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/statefulset"
 	cfcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
 	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
@@ -147,6 +149,48 @@ var _ = Describe("ReconcileQuarksStatefulSet", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 
+			Context("when the StatefulSet was rolled back", func() {
+				var rollBack = func() {
+					ss := &appsv1.StatefulSet{}
+					err := client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, ss)
+					Expect(err).ToNot(HaveOccurred())
+					ss.Annotations[statefulset.AnnotationRolledBackRevision] = "foo-2"
+					ss.Spec.Template.Spec.Containers[0].Image = "previous"
+					Expect(client.Update(context.Background(), ss)).To(Succeed())
+				}
+
+				var image = func() string {
+					ss := &appsv1.StatefulSet{}
+					err := client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, ss)
+					Expect(err).ToNot(HaveOccurred())
+					return ss.Spec.Template.Spec.Containers[0].Image
+				}
+
+				JustBeforeEach(func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					rollBack()
+				})
+
+				It("keeps the rollback if the pod template didn't change", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(image()).To(Equal("previous"))
+				})
+
+				It("rolls out a changed pod template", func() {
+					qsts := &qstsv1a1.QuarksStatefulSet{}
+					err := client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, qsts)
+					Expect(err).ToNot(HaveOccurred())
+					qsts.Spec.Template.Spec.Template.Spec.Containers[0].Image = "fixed"
+					Expect(client.Update(context.Background(), qsts)).To(Succeed())
+
+					_, err = reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(image()).To(Equal("fixed"))
+				})
+			})
+
 			Context("with multiple replicas", func() {
 				var ss *appsv1.StatefulSet
 				BeforeEach(func() {
@@ -286,6 +330,89 @@ var _ = Describe("ReconcileQuarksStatefulSet", func() {
 					})
 				})
 
+				When("the total replicas are set", func() {
+					BeforeEach(func() {
+						desiredQStatefulSet.Spec.Replicas = pointers.Int32(5)
+						client = fake.NewFakeClient(
+							desiredQStatefulSet,
+						)
+						manager.GetClientReturns(client)
+					})
+
+					It("spreads the replicas across the zones and reports them in the status", func() {
+						_, err := reconciler.Reconcile(request)
+						Expect(err).ToNot(HaveOccurred())
+
+						for name, replicas := range map[string]int32{"foo-z0": 2, "foo-z1": 2, "foo-z2": 1} {
+							ss := &appsv1.StatefulSet{}
+							Expect(client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "default"}, ss)).To(Succeed())
+							Expect(*ss.Spec.Replicas).To(Equal(replicas), name)
+						}
+
+						qSts := &qstsv1a1.QuarksStatefulSet{}
+						Expect(client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, qSts)).To(Succeed())
+						Expect(qSts.Status.Replicas).To(Equal(int32(5)))
+						Expect(qSts.Status.Selector).To(Equal(qstsv1a1.LabelQStsName + "=foo"))
+					})
+				})
+
+				When("zones were removed", func() {
+					BeforeEach(func() {
+						client = fake.NewFakeClient(
+							desiredQStatefulSet,
+						)
+						manager.GetClientReturns(client)
+					})
+
+					JustBeforeEach(func() {
+						_, err := reconciler.Reconcile(request)
+						Expect(err).ToNot(HaveOccurred())
+
+						qSts := &qstsv1a1.QuarksStatefulSet{}
+						Expect(client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, qSts)).To(Succeed())
+						qSts.Spec.Zones = []string{"z1"}
+						Expect(client.Update(context.Background(), qSts)).To(Succeed())
+
+						_, err = reconciler.Reconcile(request)
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("deletes the StatefulSets of the removed zones", func() {
+						ss := &appsv1.StatefulSet{}
+						Expect(client.Get(context.Background(), types.NamespacedName{Name: "foo-z0", Namespace: "default"}, ss)).To(Succeed())
+
+						for _, name := range []string{"foo-z1", "foo-z2"} {
+							err := client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "default"}, ss)
+							Expect(errors.IsNotFound(err)).To(BeTrue(), name)
+						}
+					})
+
+					Context("with the orphan prune policy", func() {
+						BeforeEach(func() {
+							desiredQStatefulSet.Spec.PrunePolicy = apis.PrunePolicyOrphan
+							client = fake.NewFakeClient(
+								desiredQStatefulSet,
+							)
+							manager.GetClientReturns(client)
+						})
+
+						It("keeps the StatefulSets of the removed zones without owner", func() {
+							qSts := &qstsv1a1.QuarksStatefulSet{}
+							Expect(client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, qSts)).To(Succeed())
+
+							ss := &appsv1.StatefulSet{}
+							Expect(client.Get(context.Background(), types.NamespacedName{Name: "foo-z0", Namespace: "default"}, ss)).To(Succeed())
+							Expect(metav1.IsControlledBy(ss, qSts)).To(BeTrue())
+
+							for _, name := range []string{"foo-z1", "foo-z2"} {
+								ss := &appsv1.StatefulSet{}
+								Expect(client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "default"}, ss)).To(Succeed())
+								Expect(ss.OwnerReferences).To(BeEmpty())
+							}
+						})
+					})
+				})
+
 				When("When zoneNodeLabel has been specified", func() {
 					var (
 						customizedNodeLabel string
@@ -0,0 +1,70 @@
+package quarksstatefulset
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// AddQuarksStatefulSetScale creates a new QuarksStatefulSet controller, which
+// scales the StatefulSets when only the replicas of the QuarksStatefulSet
+// change, e.g. by the scale subresource. In contrast to other changes this
+// doesn't create a new version and doesn't restart the pods.
+func AddQuarksStatefulSetScale(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	ctx = ctxlog.NewContextWithRecorder(ctx, "quarks-statefulset-scale-reconciler", mgr.GetEventRecorderFor("quarks-statefulset-scale-recorder"))
+	r := NewScaleReconciler(ctx, config, mgr)
+
+	c, err := controller.New("quarks-statefulset-scale-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: config.MaxQuarksStatefulSetWorkers,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Adding QuarksStatefulSet scale controller to manager failed.")
+	}
+
+	p := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			o := e.ObjectOld.(*qstsv1a1.QuarksStatefulSet)
+			n := e.ObjectNew.(*qstsv1a1.QuarksStatefulSet)
+			if isScaleOnly(o, n) {
+				ctxlog.NewPredicateEvent(e.ObjectNew).Debug(
+					ctx, e.MetaNew, "qstsv1a1.QuarksStatefulSet",
+					fmt.Sprintf("Update predicate passed for scaling '%s'", e.MetaNew.GetName()),
+				)
+				return true
+			}
+			return false
+		},
+	}
+	err = c.Watch(&source.Kind{Type: &qstsv1a1.QuarksStatefulSet{}}, &handler.EnqueueRequestForObject{}, p)
+	if err != nil {
+		return errors.Wrapf(err, "Watching QuarksStatefulSet failed in QuarksStatefulSet scale controller.")
+	}
+
+	return nil
+}
+
+// isScaleOnly returns true if the replicas are the only difference between the specs
+func isScaleOnly(o, n *qstsv1a1.QuarksStatefulSet) bool {
+	if reflect.DeepEqual(o.Spec.Replicas, n.Spec.Replicas) {
+		return false
+	}
+	oldSpec, newSpec := o.Spec.DeepCopy(), n.Spec.DeepCopy()
+	oldSpec.Replicas, newSpec.Replicas = nil, nil
+	return reflect.DeepEqual(oldSpec, newSpec)
+}
@@ -0,0 +1,88 @@
+package quarksstatefulset
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// NewScaleReconciler returns a new reconcile.Reconciler for the QuarksStatefulSet scale controller
+func NewScaleReconciler(ctx context.Context, config *config.Config, mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileQuarksStatefulSetScale{
+		ctx:    ctx,
+		config: config,
+		client: mgr.GetClient(),
+	}
+}
+
+// ReconcileQuarksStatefulSetScale reconciles the replicas of the StatefulSets of a QuarksStatefulSet
+type ReconcileQuarksStatefulSetScale struct {
+	ctx    context.Context
+	client client.Client
+	config *config.Config
+}
+
+// Reconcile spreads the replicas of the QuarksStatefulSet across the
+// StatefulSets of its zones and updates the replicas in the status.
+func (r *ReconcileQuarksStatefulSetScale) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
+	defer cancel()
+
+	ctxlog.Info(ctx, "Reconciling replicas of QuarksStatefulSet ", request.NamespacedName)
+	qSts := &qstsv1a1.QuarksStatefulSet{}
+	err := r.client.Get(ctx, request.NamespacedName, qSts)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			ctxlog.Debug(ctx, "Skip QuarksStatefulSet scale reconcile: QuarksStatefulSet not found")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	statefulSets, err := listStatefulSetsFromInformer(ctx, r.client, qSts)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "could not list StatefulSets of QuarksStatefulSet '%s'", request.NamespacedName)
+	}
+
+	total := int32(0)
+	for i := range statefulSets {
+		statefulSet := &statefulSets[i]
+		zoneIndex, err := strconv.Atoi(statefulSet.Labels[qstsv1a1.LabelAZIndex])
+		if err != nil {
+			zoneIndex = 0
+		}
+
+		replicas := qSts.GetZoneReplicas(zoneIndex)
+		if replicas == nil {
+			continue
+		}
+		total += *replicas
+		if statefulSet.Spec.Replicas != nil && *statefulSet.Spec.Replicas == *replicas {
+			continue
+		}
+
+		ctxlog.WithEvent(qSts, "Scale").Infof(ctx, "Scaling StatefulSet '%s' to %d replicas", statefulSet.Name, *replicas)
+		statefulSet.Spec.Replicas = replicas
+		if err := r.client.Update(ctx, statefulSet); err != nil {
+			return reconcile.Result{}, ctxlog.WithEvent(qSts, "ScaleError").Errorf(ctx, "Could not scale StatefulSet '%s': %v", statefulSet.Name, err)
+		}
+	}
+
+	qSts.Status.Replicas = total
+	qSts.Status.Selector = labels.SelectorFromSet(labels.Set{qstsv1a1.LabelQStsName: qSts.Name}).String()
+	if err := r.client.Status().Update(ctx, qSts); err != nil {
+		return reconcile.Result{}, ctxlog.WithEvent(qSts, "UpdateStatusError").Errorf(ctx, "Failed to update replicas on QuarksStatefulSet '%s': %v", qSts.Name, err)
+	}
+
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,105 @@
+package quarksstatefulset_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	qstscontroller "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/quarksstatefulset"
+	cfcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
+	helper "code.cloudfoundry.org/quarks-utils/testing/testhelper"
+)
+
+var _ = Describe("ReconcileQuarksStatefulSetScale", func() {
+	var (
+		c          crc.Client
+		reconciler reconcile.Reconciler
+		request    reconcile.Request
+		qSts       *qstsv1a1.QuarksStatefulSet
+	)
+
+	newStatefulSet := func(zoneIndex string, replicas int32) *appsv1.StatefulSet {
+		ss := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "foo-z" + zoneIndex,
+				Namespace:   "default",
+				Labels:      map[string]string{qstsv1a1.LabelAZIndex: zoneIndex},
+				Annotations: map[string]string{qstsv1a1.AnnotationVersion: "1"},
+			},
+			Spec: appsv1.StatefulSetSpec{Replicas: pointers.Int32(replicas)},
+		}
+		Expect(controllerutil.SetControllerReference(qSts, ss, scheme.Scheme)).To(Succeed())
+		return ss
+	}
+
+	replicas := func(name string) int32 {
+		ss := &appsv1.StatefulSet{}
+		Expect(c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, ss)).To(Succeed())
+		return *ss.Spec.Replicas
+	}
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		qSts = &qstsv1a1.QuarksStatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: "foo-uid"},
+			Spec: qstsv1a1.QuarksStatefulSetSpec{
+				Zones:    []string{"z1", "z2"},
+				Replicas: pointers.Int32(5),
+				Template: appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: pointers.Int32(1)}},
+			},
+		}
+		c = fake.NewFakeClientWithScheme(scheme.Scheme, qSts, newStatefulSet("0", 1), newStatefulSet("1", 1))
+		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+	})
+
+	JustBeforeEach(func() {
+		manager := &cfakes.FakeManager{}
+		manager.GetClientReturns(c)
+		_, log := helper.NewTestLogger()
+		reconciler = qstscontroller.NewScaleReconciler(ctxlog.NewParentContext(log), &cfcfg.Config{CtxTimeOut: 10 * time.Second}, manager)
+	})
+
+	It("spreads the replicas across the StatefulSets of the zones", func() {
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(replicas("foo-z0")).To(Equal(int32(3)))
+		Expect(replicas("foo-z1")).To(Equal(int32(2)))
+
+		updated := &qstsv1a1.QuarksStatefulSet{}
+		Expect(c.Get(context.Background(), request.NamespacedName, updated)).To(Succeed())
+		Expect(updated.Status.Replicas).To(Equal(int32(5)))
+		Expect(updated.Status.Selector).To(Equal(qstsv1a1.LabelQStsName + "=foo"))
+	})
+
+	Context("when no total replicas are set", func() {
+		BeforeEach(func() {
+			qSts.Spec.Replicas = nil
+			c = fake.NewFakeClientWithScheme(scheme.Scheme, qSts, newStatefulSet("0", 3), newStatefulSet("1", 3))
+		})
+
+		It("uses the replicas of the template", func() {
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(replicas("foo-z0")).To(Equal(int32(1)))
+			Expect(replicas("foo-z1")).To(Equal(int32(1)))
+		})
+	})
+})
@@ -115,6 +115,12 @@ func WithFailure() UpdateOpts {
 	}
 }
 
+func WithAnnotation(key, value string) UpdateOpts {
+	return func(sse *StatefulSetEmulation) {
+		sse.statefulSet.Annotations[key] = value
+	}
+}
+
 func (sse *StatefulSetEmulation) Update(updateOpts ...UpdateOpts) *event.UpdateEvent {
 	var old appsv1.StatefulSet
 	sse.statefulSet.DeepCopyInto(&old)
@@ -0,0 +1,72 @@
+package statefulset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+// triggerPostDeploy triggers the post-deploy QuarksJob of the StatefulSet,
+// once all StatefulSets sharing the job, i.e. all zones of the instance
+// group, are rolled out. The job is triggered once per set of revisions.
+func (r *ReconcileStatefulSetRollout) triggerPostDeploy(ctx context.Context, statefulSet *appsv1.StatefulSet) error {
+	jobName, ok := statefulSet.Annotations[AnnotationPostDeployJob]
+	if !ok || jobName == "" {
+		return nil
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := r.client.List(ctx, statefulSets, crc.InNamespace(statefulSet.Namespace)); err != nil {
+		return errors.Wrapf(err, "listing StatefulSets for post-deploy job '%s/%s'", statefulSet.Namespace, jobName)
+	}
+
+	// The listed StatefulSet being reconciled might not be updated yet
+	revisions := []string{fmt.Sprintf("%s/%s", statefulSet.Name, statefulSet.Status.UpdateRevision)}
+	for _, sts := range statefulSets.Items {
+		if sts.Name == statefulSet.Name || sts.Annotations[AnnotationPostDeployJob] != jobName {
+			continue
+		}
+		if sts.Annotations[AnnotationCanaryRollout] != rolloutStateDone {
+			ctxlog.Debugf(ctx, "Not triggering post-deploy job '%s/%s', StatefulSet '%s' is not rolled out yet", statefulSet.Namespace, jobName, sts.Name)
+			return nil
+		}
+		revisions = append(revisions, fmt.Sprintf("%s/%s", sts.Name, sts.Status.UpdateRevision))
+	}
+	sort.Strings(revisions)
+	deployed := strings.Join(revisions, ",")
+
+	qJob := &qjv1a1.QuarksJob{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: statefulSet.Namespace, Name: jobName}, qJob)
+	if apierrors.IsNotFound(err) {
+		ctxlog.Infof(ctx, "Not triggering post-deploy job '%s/%s', it does not exist", statefulSet.Namespace, jobName)
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "getting post-deploy job '%s/%s'", statefulSet.Namespace, jobName)
+	}
+
+	if qJob.Annotations[AnnotationPostDeployRevisions] == deployed {
+		return nil
+	}
+	if qJob.Annotations == nil {
+		qJob.Annotations = map[string]string{}
+	}
+	qJob.Annotations[AnnotationPostDeployRevisions] = deployed
+	qJob.Spec.Trigger.Strategy = qjv1a1.TriggerNow
+
+	if err := r.client.Update(ctx, qJob); err != nil {
+		return errors.Wrapf(err, "triggering post-deploy job '%s/%s'", statefulSet.Namespace, jobName)
+	}
+	ctxlog.WithEvent(statefulSet, "PostDeploy").Infof(ctx, "Triggered post-deploy job '%s/%s' for revisions %s", statefulSet.Namespace, jobName, deployed)
+	return nil
+}
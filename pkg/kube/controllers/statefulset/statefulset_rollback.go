@@ -0,0 +1,212 @@
+package statefulset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/meltdown"
+)
+
+// Reasons for failed rollouts
+const (
+	ReasonCanaryWatchTimeExceeded = "CanaryWatchTimeExceeded"
+	ReasonUpdateWatchTimeExceeded = "UpdateWatchTimeExceeded"
+)
+
+var failureMessages = map[string]string{
+	ReasonCanaryWatchTimeExceeded: "canary pods were not ready within the canary watch time",
+	ReasonUpdateWatchTimeExceeded: "pods were not ready within the update watch time",
+}
+
+// rolloutAnnotations are the annotations written by the rollout reconciler
+var rolloutAnnotations = []string{
+	AnnotationCanaryRollout,
+	AnnotationUpdateStartTime,
+	AnnotationRolloutFailure,
+	AnnotationRolledBackRevision,
+	AnnotationRetryRollout,
+	meltdown.AnnotationLastReconcile,
+}
+
+func rollbackEnabled(statefulSet *appsv1.StatefulSet) bool {
+	return statefulSet.Annotations[AnnotationRollback] == "true"
+}
+
+// failRollout marks the rollout of the StatefulSet as failed. If rollbacks
+// are enabled, the pod template of the previous revision is restored and
+// rolled out instead. A failed rollback is not rolled back again.
+func (r *ReconcileStatefulSetRollout) failRollout(ctx context.Context, statefulSet appsv1.StatefulSet, reason string) error {
+	failure := &qstsv1a1.RolloutFailure{
+		StatefulSet: statefulSet.Name,
+		Revision:    statefulSet.Status.UpdateRevision,
+		Reason:      reason,
+		Message:     failureMessages[reason],
+		Time:        metav1.Now(),
+	}
+
+	statefulSet.Annotations[AnnotationCanaryRollout] = rolloutStateFailed
+	statefulSet.Annotations[AnnotationRolloutFailure] = reason
+
+	_, isRollback := statefulSet.Annotations[AnnotationRolledBackRevision]
+	previous := statefulSet.Status.CurrentRevision
+	switch {
+	case !rollbackEnabled(&statefulSet):
+	case isRollback:
+		ctxlog.Infof(ctx, "Not rolling back StatefulSet '%s/%s' again, the rollback itself failed", statefulSet.Namespace, statefulSet.Name)
+	case previous == "" || previous == statefulSet.Status.UpdateRevision:
+		ctxlog.Infof(ctx, "Not rolling back StatefulSet '%s/%s', there is no previous revision", statefulSet.Namespace, statefulSet.Name)
+	default:
+		template, err := r.revisionTemplate(ctx, &statefulSet, previous)
+		if err != nil {
+			return err
+		}
+		statefulSet.Spec.Template = *template
+		ConfigureStatefulSetForRollout(&statefulSet)
+		statefulSet.Annotations[AnnotationRolledBackRevision] = failure.Revision
+		failure.RolledBack = true
+	}
+
+	if err := r.updateRollout(ctx, &statefulSet); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Rollout of StatefulSet '%s/%s' failed: %s", statefulSet.Namespace, statefulSet.Name, failure.Message)
+	if failure.RolledBack {
+		message = fmt.Sprintf("%s, rolling back to revision '%s'", message, previous)
+	}
+	ctxlog.WithEvent(&statefulSet, "RolloutFailed").Error(ctx, message)
+
+	return r.setRolloutFailure(ctx, &statefulSet, failure)
+}
+
+// retryRollout starts the rollout of a failed or rolled back StatefulSet
+// again. The pod template of a rolled back revision is restored first.
+func (r *ReconcileStatefulSetRollout) retryRollout(ctx context.Context, statefulSet appsv1.StatefulSet) error {
+	delete(statefulSet.Annotations, AnnotationRetryRollout)
+
+	revision, isRollback := statefulSet.Annotations[AnnotationRolledBackRevision]
+	if !isRollback && statefulSet.Annotations[AnnotationCanaryRollout] != rolloutStateFailed {
+		ctxlog.Infof(ctx, "Ignoring retry for StatefulSet '%s/%s', its rollout didn't fail", statefulSet.Namespace, statefulSet.Name)
+		return r.updateRollout(ctx, &statefulSet)
+	}
+
+	if isRollback {
+		template, err := r.revisionTemplate(ctx, &statefulSet, revision)
+		if err != nil {
+			return err
+		}
+		statefulSet.Spec.Template = *template
+	}
+	delete(statefulSet.Annotations, AnnotationRolloutFailure)
+	delete(statefulSet.Annotations, AnnotationRolledBackRevision)
+	ConfigureStatefulSetForRollout(&statefulSet)
+
+	if err := r.updateRollout(ctx, &statefulSet); err != nil {
+		return err
+	}
+	ctxlog.WithEvent(&statefulSet, "RolloutRetried").Infof(ctx, "Retrying rollout of StatefulSet '%s/%s'", statefulSet.Namespace, statefulSet.Name)
+
+	return r.setRolloutFailure(ctx, &statefulSet, nil)
+}
+
+// revisionTemplate returns the pod template stored in the controller revision
+// of the StatefulSet
+func (r *ReconcileStatefulSetRollout) revisionTemplate(ctx context.Context, statefulSet *appsv1.StatefulSet, name string) (*corev1.PodTemplateSpec, error) {
+	revision := &appsv1.ControllerRevision{}
+	err := r.client.Get(ctx, crc.ObjectKey{Namespace: statefulSet.Namespace, Name: name}, revision)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get controller revision '%s' of StatefulSet '%s/%s'", name, statefulSet.Namespace, statefulSet.Name)
+	}
+
+	// The StatefulSet controller stores the template as a patch of the spec
+	patch := struct {
+		Spec struct {
+			Template corev1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal(revision.Data.Raw, &patch); err != nil {
+		return nil, errors.Wrapf(err, "could not decode controller revision '%s' of StatefulSet '%s/%s'", name, statefulSet.Namespace, statefulSet.Name)
+	}
+
+	return &patch.Spec.Template, nil
+}
+
+// updateRollout writes the pod template, the update strategy and the rollout
+// annotations of the StatefulSet
+func (r *ReconcileStatefulSetRollout) updateRollout(ctx context.Context, statefulSet *appsv1.StatefulSet) error {
+	meltdown.SetLastReconcile(&statefulSet.ObjectMeta, time.Now())
+	desired := statefulSet.DeepCopy()
+	_, err := controllerutil.CreateOrUpdate(ctx, r.client, statefulSet, func() error {
+		statefulSet.Spec.Template = desired.Spec.Template
+		statefulSet.Spec.UpdateStrategy = desired.Spec.UpdateStrategy
+		if statefulSet.Annotations == nil {
+			statefulSet.Annotations = map[string]string{}
+		}
+		for _, key := range rolloutAnnotations {
+			if value, ok := desired.Annotations[key]; ok {
+				statefulSet.Annotations[key] = value
+			} else {
+				delete(statefulSet.Annotations, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not update rollout of StatefulSet '%s/%s'", statefulSet.Namespace, statefulSet.Name)
+	}
+	return nil
+}
+
+// setRolloutFailure records the failed rollout of the StatefulSet in the
+// status of the owning QuarksStatefulSet. The failure is removed if it's nil.
+func (r *ReconcileStatefulSetRollout) setRolloutFailure(ctx context.Context, statefulSet *appsv1.StatefulSet, failure *qstsv1a1.RolloutFailure) error {
+	owner := metav1.GetControllerOf(statefulSet)
+	if owner == nil || owner.Kind != qstsv1a1.QuarksStatefulSetResourceKind {
+		return nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		qsts := &qstsv1a1.QuarksStatefulSet{}
+		err := r.client.Get(ctx, crc.ObjectKey{Namespace: statefulSet.Namespace, Name: owner.Name}, qsts)
+		if err != nil {
+			return err
+		}
+
+		failures := []qstsv1a1.RolloutFailure{}
+		for _, f := range qsts.Status.FailedRollouts {
+			if f.StatefulSet != statefulSet.Name {
+				failures = append(failures, f)
+			}
+		}
+		if failure == nil && len(failures) == len(qsts.Status.FailedRollouts) {
+			return nil
+		}
+		if failure != nil {
+			failures = append(failures, *failure)
+		}
+		qsts.Status.FailedRollouts = failures
+
+		return r.client.Status().Update(ctx, qsts)
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "could not update rollout status of QuarksStatefulSet '%s/%s'", statefulSet.Namespace, owner.Name)
+	}
+	return nil
+}
@@ -60,6 +60,9 @@ func AddStatefulSetRollout(ctx context.Context, config *config.Config, mgr manag
 // CheckUpdate checks if update event should be processed
 func CheckUpdate(e event.UpdateEvent) bool {
 	newSts := e.ObjectNew.(*appsv1.StatefulSet)
+	if _, ok := newSts.Annotations[AnnotationRetryRollout]; ok {
+		return true
+	}
 	state, ok := newSts.Annotations[AnnotationCanaryRollout]
 	if !ok || state == rolloutStateDone || state == rolloutStateFailed {
 		return false
@@ -0,0 +1,48 @@
+package statefulset
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// jobHashesRequeueAfter is the time the kubelets get to update the job hashes
+// volumes of the pods, before the rollout replaces them
+const jobHashesRequeueAfter = 5 * time.Second
+
+// publishDesiredJobHashes annotates the pods of the StatefulSet with the job
+// hashes of its pod template, before the rollout replaces them. When a pod
+// terminates, container-run compares them to the hashes of the jobs it runs,
+// to pass the job and hash changes to the drain scripts. It returns true, if
+// a pod was annotated.
+func (r *ReconcileStatefulSetRollout) publishDesiredJobHashes(ctx context.Context, statefulSet appsv1.StatefulSet) (bool, error) {
+	hashes, ok := statefulSet.Spec.Template.Annotations[AnnotationJobHashes]
+	if !ok {
+		return false, nil
+	}
+
+	published := false
+	for index := int32(0); index < statefulSet.Status.Replicas; index++ {
+		pod, _, err := getPodWithIndex(ctx, r.client, &statefulSet, index)
+		if err != nil {
+			return false, err
+		}
+		if pod == nil || pod.Annotations[AnnotationDesiredJobHashes] == hashes {
+			continue
+		}
+
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[AnnotationDesiredJobHashes] = hashes
+		if err := r.client.Update(ctx, pod); err != nil {
+			return false, errors.Wrapf(err, "annotating pod '%s/%s' with the desired job hashes", pod.Namespace, pod.Name)
+		}
+		published = true
+	}
+
+	return published, nil
+}
@@ -42,6 +42,26 @@ var (
 	AnnotationUpdateWatchTime = fmt.Sprintf("%s/update-watch-time-ms", apis.GroupName)
 	// AnnotationUpdateStartTime is the timestamp when the update started
 	AnnotationUpdateStartTime = fmt.Sprintf("%s/update-start-time", apis.GroupName)
+	// AnnotationCanaries is the number of pods updated first, before the rest of the rollout
+	AnnotationCanaries = fmt.Sprintf("%s/canaries", apis.GroupName)
+	// AnnotationMaxInFlight is the number or percentage of pods updated in parallel after the canaries
+	AnnotationMaxInFlight = fmt.Sprintf("%s/max-in-flight", apis.GroupName)
+	// AnnotationRollback if set to "true" failed rollouts are rolled back to the previous revision
+	AnnotationRollback = fmt.Sprintf("%s/rollback", apis.GroupName)
+	// AnnotationRolloutFailure is the reason of the last failed rollout
+	AnnotationRolloutFailure = fmt.Sprintf("%s/rollout-failure", apis.GroupName)
+	// AnnotationRolledBackRevision is the revision, which failed and was rolled back
+	AnnotationRolledBackRevision = fmt.Sprintf("%s/rolled-back-revision", apis.GroupName)
+	// AnnotationRetryRollout if set, the failed or rolled back rollout is started again
+	AnnotationRetryRollout = fmt.Sprintf("%s/retry-rollout", apis.GroupName)
+	// AnnotationPostDeployJob is the name of the QuarksJob, which is triggered once all StatefulSets of the instance group are rolled out
+	AnnotationPostDeployJob = fmt.Sprintf("%s/post-deploy-job", apis.GroupName)
+	// AnnotationPostDeployRevisions are the revisions of the StatefulSets, which the post-deploy QuarksJob was last triggered for
+	AnnotationPostDeployRevisions = fmt.Sprintf("%s/post-deploy-revisions", apis.GroupName)
+	// AnnotationJobHashes are the hashes of the jobs a pod runs, which container-run compares to the desired ones when draining
+	AnnotationJobHashes = fmt.Sprintf("%s/job-hashes", apis.GroupName)
+	// AnnotationDesiredJobHashes are the job hashes of the pod template, which a rollout replaces the pod with
+	AnnotationDesiredJobHashes = fmt.Sprintf("%s/desired-job-hashes", apis.GroupName)
 )
 
 // NewStatefulSetRolloutReconciler returns a new reconcile.Reconciler
@@ -83,11 +103,36 @@ func (r *ReconcileStatefulSetRollout) Reconcile(request reconcile.Request) (reco
 		return reconcile.Result{RequeueAfter: r.config.MeltdownRequeueAfter}, nil
 	}
 
+	if _, ok := statefulSet.Annotations[AnnotationRetryRollout]; ok {
+		return reconcile.Result{}, r.retryRollout(ctx, statefulSet)
+	}
+
 	var status = statefulSet.Annotations[AnnotationCanaryRollout]
 	if status == rolloutStateFailed || status == rolloutStateDone {
 		return reconcile.Result{}, nil
 	}
 
+	if status == rolloutStatePending {
+		zone, err := r.previousZoneInRollout(ctx, statefulSet)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if zone != "" {
+			ctxlog.Debugf(ctx, "Waiting for the rollout of StatefulSet '%s' before rolling out '%s'", zone, request.NamespacedName)
+			return reconcile.Result{RequeueAfter: zoneRolloutRequeueAfter}, nil
+		}
+		published, err := r.publishDesiredJobHashes(ctx, statefulSet)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if published {
+			ctxlog.Debugf(ctx, "Waiting for the pods of StatefulSet '%s' to receive the desired job hashes", request.NamespacedName)
+			return reconcile.Result{RequeueAfter: jobHashesRequeueAfter}, nil
+		}
+		// The watch times start once the previous zones are rolled out
+		statefulSet.Annotations[AnnotationUpdateStartTime] = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+
 	var newStatus = status
 	dirty := false
 	var oldPartition int32
@@ -101,8 +146,7 @@ func (r *ReconcileStatefulSetRollout) Reconcile(request reconcile.Request) (reco
 	var resultWithRetrigger reconcile.Result
 	timeLeft := getTimeOut(ctx, statefulSet, AnnotationUpdateWatchTime)
 	if timeLeft < 0 {
-		statefulSet.Annotations[AnnotationCanaryRollout] = rolloutStateFailed
-		if err = r.updateStatefulSet(ctx, statefulSet, oldPartition); err != nil {
+		if err = r.failRollout(ctx, statefulSet, ReasonUpdateWatchTimeExceeded); err != nil {
 			ctxlog.Debug(ctx, "Error updating StatefulSet ", request.NamespacedName, err)
 			return reconcile.Result{}, err
 		}
@@ -110,20 +154,26 @@ func (r *ReconcileStatefulSetRollout) Reconcile(request reconcile.Request) (reco
 	}
 	resultWithRetrigger.RequeueAfter = timeLeft
 
+	partition := statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition
+	canaries, maxInFlight := getRolloutSteps(ctx, statefulSet)
+
 	switch status {
 	case rolloutStateCanaryUpscale:
 		if statefulSet.Status.Replicas == *statefulSet.Spec.Replicas && statefulSet.Status.ReadyReplicas == *statefulSet.Spec.Replicas {
-			if *statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition == 0 {
+			if *partition == 0 {
 				newStatus = rolloutStateDone
 			} else {
-				(*statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)--
+				*partition = decreasePartition(*partition, maxInFlight)
 				newStatus = rolloutStateRollout
 			}
 		}
 	case rolloutStateCanary:
 		if getTimeOut(ctx, statefulSet, AnnotationCanaryWatchTime) < 0 {
-			newStatus = rolloutStateFailed
-			break
+			if err = r.failRollout(ctx, statefulSet, ReasonCanaryWatchTimeExceeded); err != nil {
+				ctxlog.Debug(ctx, "Error updating StatefulSet ", request.NamespacedName, err)
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{}, nil
 		}
 		fallthrough
 	case rolloutStateRollout:
@@ -131,11 +181,11 @@ func (r *ReconcileStatefulSetRollout) Reconcile(request reconcile.Request) (reco
 			timeLeft = time.Minute
 		}
 		resultWithRetrigger.RequeueAfter = timeLeft
-		ready, err := partitionPodIsReadyAndUpdated(ctx, r.client, &statefulSet)
+		ready, err := podsAreReadyAndUpdated(ctx, r.client, &statefulSet, *partition)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
-		if *statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition == 0 {
+		if *partition == 0 {
 			if ready {
 				newStatus = rolloutStateDone
 			}
@@ -144,17 +194,21 @@ func (r *ReconcileStatefulSetRollout) Reconcile(request reconcile.Request) (reco
 		if !ready {
 			break
 		}
-		(*statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)--
+		*partition = decreasePartition(*partition, maxInFlight)
 		dirty = true
 		newStatus = rolloutStateRollout
 	case rolloutStatePending:
 		if statefulSet.Status.Replicas < *statefulSet.Spec.Replicas {
 			newStatus = rolloutStateCanaryUpscale
 			resultWithRetrigger.RequeueAfter = getTimeOut(ctx, statefulSet, AnnotationUpdateWatchTime)
+		} else if canaries == 0 {
+			newStatus = rolloutStateRollout
+			*partition = decreasePartition(*partition, maxInFlight)
+			dirty = true
 		} else {
 			resultWithRetrigger.RequeueAfter = getTimeOut(ctx, statefulSet, AnnotationCanaryWatchTime)
 			newStatus = rolloutStateCanary
-			(*statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)--
+			*partition = decreasePartition(*partition, canaries)
 			dirty = true
 		}
 	}
@@ -169,6 +223,18 @@ func (r *ReconcileStatefulSetRollout) Reconcile(request reconcile.Request) (reco
 			return reconcile.Result{}, err
 		}
 	}
+	if statusChanged && newStatus == rolloutStateDone {
+		// A rollback keeps the failure in the status until the rollout is retried
+		if _, ok := statefulSet.Annotations[AnnotationRolledBackRevision]; !ok {
+			if err = r.setRolloutFailure(ctx, &statefulSet, nil); err != nil {
+				return reconcile.Result{}, err
+			}
+			if err = r.triggerPostDeploy(ctx, &statefulSet); err != nil {
+				ctxlog.Error(ctx, "Error triggering post-deploy job for StatefulSet ", request.NamespacedName, err)
+				return reconcile.Result{}, err
+			}
+		}
+	}
 	return resultWithRetrigger, nil
 }
 
@@ -179,8 +245,9 @@ func (r *ReconcileStatefulSetRollout) updateStatefulSet(ctx context.Context, sta
 		return err
 	}
 
-	if oldPartition != *statefulset.Spec.UpdateStrategy.RollingUpdate.Partition {
-		err = CleanupNonReadyPod(ctx, r.client, &statefulset, *statefulset.Spec.UpdateStrategy.RollingUpdate.Partition)
+	// Pods which are going to be updated are not waited for
+	for index := *statefulset.Spec.UpdateStrategy.RollingUpdate.Partition; index < oldPartition; index++ {
+		err = CleanupNonReadyPod(ctx, r.client, &statefulset, index)
 		if err != nil {
 			return err
 		}
@@ -208,13 +275,51 @@ func getTimeOut(ctx context.Context, statefulSet appsv1.StatefulSet, watchTimeAn
 	return time.Until(updateStartTime.Add(time.Millisecond * time.Duration(watchTime)))
 }
 
+// getRolloutSteps returns the number of canaries and the number of pods
+// updated in parallel after the canaries. Without annotations pods are
+// updated one by one.
+func getRolloutSteps(ctx context.Context, statefulSet appsv1.StatefulSet) (int32, int32) {
+	canaries := int32(1)
+	if value, ok := statefulSet.Annotations[AnnotationCanaries]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			ctxlog.Errorf(ctx, "Invalid annotation %s: %s", AnnotationCanaries, value)
+		} else {
+			canaries = int32(n)
+		}
+	}
+
+	maxInFlight := int32(1)
+	if value, ok := statefulSet.Annotations[AnnotationMaxInFlight]; ok {
+		n, err := numberOfPods(value, *statefulSet.Spec.Replicas)
+		if err != nil {
+			ctxlog.Errorf(ctx, "Invalid annotation %s: %s", AnnotationMaxInFlight, value)
+		} else if n > 1 {
+			maxInFlight = n
+		}
+	}
+
+	return canaries, maxInFlight
+}
+
+// decreasePartition lowers the partition by the number of pods, which are
+// updated next
+func decreasePartition(partition int32, pods int32) int32 {
+	if pods > partition {
+		return 0
+	}
+	return partition - pods
+}
+
 func (r *ReconcileStatefulSetRollout) update(ctx context.Context, statefulSet *appsv1.StatefulSet) error {
 
 	partition := *statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition
 	state := statefulSet.Annotations[AnnotationCanaryRollout]
+	startTime := statefulSet.Annotations[AnnotationUpdateStartTime]
 	_, err := controllerutil.CreateOrUpdate(ctx, r.client, statefulSet, func() error {
 		statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition = pointers.Int32(partition)
 		statefulSet.Annotations[AnnotationCanaryRollout] = state
+		statefulSet.Annotations[AnnotationUpdateStartTime] = startTime
 		return nil
 	})
 	if err != nil {
@@ -234,19 +339,21 @@ func (r *ReconcileStatefulSetRollout) update(ctx context.Context, statefulSet *a
 	return nil
 }
 
-func partitionPodIsReadyAndUpdated(ctx context.Context, client crc.Client, statefulSet *appsv1.StatefulSet) (bool, error) {
-	ready := false
-	updated := false
-	if statefulSet.Spec.UpdateStrategy.RollingUpdate != nil {
-		pod, podReady, err := getPodWithIndex(ctx, client, statefulSet, *statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)
+// podsAreReadyAndUpdated returns true if all pods starting at the index of
+// the partition are ready and updated
+func podsAreReadyAndUpdated(ctx context.Context, client crc.Client, statefulSet *appsv1.StatefulSet, partition int32) (bool, error) {
+	if statefulSet.Spec.UpdateStrategy.RollingUpdate == nil {
+		return false, nil
+	}
+	for index := partition; index < *statefulSet.Spec.Replicas; index++ {
+		pod, podReady, err := getPodWithIndex(ctx, client, statefulSet, index)
 		if err != nil {
 			ctxlog.Debug(ctx, "Error calling GetNoneReadyPod ", statefulSet.Namespace, "/", statefulSet.Name, err)
 			return false, err
 		}
-		if podReady {
-			ready = true
-			updated = pod.Labels[appsv1.StatefulSetRevisionLabel] == statefulSet.Status.UpdateRevision
+		if !podReady || pod.Labels[appsv1.StatefulSetRevisionLabel] != statefulSet.Status.UpdateRevision {
+			return false, nil
 		}
 	}
-	return ready && updated, nil
+	return true, nil
 }
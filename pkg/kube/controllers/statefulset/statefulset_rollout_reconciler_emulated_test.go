@@ -141,6 +141,62 @@ var _ = Describe("ReconcileStatefulSetRollout", func() {
 		})
 	})
 
+	Context("Update with canaries and max_in_flight", func() {
+		It("updates the canaries first and then max_in_flight pods per step", func() {
+			for ev := emulation.Reconcile(); ev != nil; ev = emulation.Reconcile() {
+			}
+			By("Update ")
+			r := reconciler()
+			reconcile(r, emulation.Update(
+				WithAnnotation(statefulset.AnnotationCanaries, "2"),
+				WithAnnotation(statefulset.AnnotationMaxInFlight, "50%"),
+			))
+			Expect(client.UpdateCallCount()).To(Equal(1))
+			Expect(emulation.statefulSet.Annotations).To(HaveKeyWithValue("quarks.cloudfoundry.org/canary-rollout", "Canary"))
+			Expect(int(*emulation.statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)).To(Equal(2))
+
+			for i := 3; i > 1; i-- {
+				By(fmt.Sprintf("canary %d is restarted", i))
+				r = reconciler()
+				reconcile(r, emulation.Reconcile())
+				Expect(client.UpdateCallCount()).To(Equal(0))
+
+				By(fmt.Sprintf("canary %d gets ready", i))
+				r = reconciler()
+				reconcile(r, emulation.Reconcile())
+			}
+			Expect(client.UpdateCallCount()).To(Equal(1))
+			Expect(emulation.statefulSet.Annotations).To(HaveKeyWithValue("quarks.cloudfoundry.org/canary-rollout", "Rollout"))
+			Expect(int(*emulation.statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)).To(Equal(0))
+
+			for ev := emulation.Reconcile(); ev != nil; ev = emulation.Reconcile() {
+				r = reconciler()
+				reconcile(r, ev)
+			}
+			Expect(emulation.statefulSet.Annotations).To(HaveKeyWithValue("quarks.cloudfoundry.org/canary-rollout", "Done"))
+		})
+
+		It("skips the canary phase without canaries", func() {
+			for ev := emulation.Reconcile(); ev != nil; ev = emulation.Reconcile() {
+			}
+			r := reconciler()
+			reconcile(r, emulation.Update(
+				WithAnnotation(statefulset.AnnotationCanaries, "0"),
+				WithAnnotation(statefulset.AnnotationMaxInFlight, "3"),
+			))
+			Expect(client.UpdateCallCount()).To(Equal(1))
+			Expect(emulation.statefulSet.Annotations).To(HaveKeyWithValue("quarks.cloudfoundry.org/canary-rollout", "Rollout"))
+			Expect(int(*emulation.statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)).To(Equal(1))
+
+			for ev := emulation.Reconcile(); ev != nil; ev = emulation.Reconcile() {
+				r = reconciler()
+				reconcile(r, ev)
+			}
+			Expect(emulation.statefulSet.Annotations).To(HaveKeyWithValue("quarks.cloudfoundry.org/canary-rollout", "Done"))
+			Expect(int(*emulation.statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition)).To(Equal(0))
+		})
+	})
+
 	Context("Failed Update", func() {
 		It("It recovers from failed update", func() {
 			for ev := emulation.Reconcile(); ev != nil; ev = emulation.Reconcile() {
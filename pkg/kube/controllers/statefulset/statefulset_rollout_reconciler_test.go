@@ -21,9 +21,11 @@ import (
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
 	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/statefulset"
+	qjv1a1 "code.cloudfoundry.org/quarks-job/pkg/kube/apis/quarksjob/v1alpha1"
 	cfcfg "code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
 	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
@@ -46,6 +48,12 @@ var _ = Describe("ReconcileStatefulSetRollout", func() {
 		readyReplicas      int32
 		updatedReplicas    int32
 		updatedStatefulSet appsv1.StatefulSet
+		statusWriter       *cfakes.FakeStatusWriter
+		qsts               *qstsv1a1.QuarksStatefulSet
+		revisions          map[string]string
+		qJob               *qjv1a1.QuarksJob
+		updatedQuarksJob   *qjv1a1.QuarksJob
+		statefulSets       []appsv1.StatefulSet
 	)
 	annotations := make(map[string]string)
 	timeout := 10 * time.Second
@@ -60,6 +68,24 @@ var _ = Describe("ReconcileStatefulSetRollout", func() {
 		readyReplicas = 2
 		updatedReplicas = 0
 		partition = 0
+		qsts = nil
+		revisions = map[string]string{}
+		qJob = nil
+		updatedQuarksJob = nil
+		statefulSets = []appsv1.StatefulSet{}
+	})
+
+	AfterEach(func() {
+		for _, key := range []string{
+			statefulset.AnnotationRollback,
+			statefulset.AnnotationRolloutFailure,
+			statefulset.AnnotationRolledBackRevision,
+			statefulset.AnnotationRetryRollout,
+			statefulset.AnnotationPostDeployJob,
+			qstsv1a1.AnnotationVersion,
+		} {
+			delete(annotations, key)
+		}
 	})
 
 	JustBeforeEach(func() {
@@ -135,6 +161,22 @@ var _ = Describe("ReconcileStatefulSetRollout", func() {
 			case *appsv1.StatefulSet:
 				statefulSet.DeepCopyInto(object)
 				return nil
+			case *appsv1.ControllerRevision:
+				if subdomain, ok := revisions[nn.Name]; ok {
+					object.Name = nn.Name
+					object.Data.Raw = []byte(`{"spec":{"template":{"spec":{"subdomain":"` + subdomain + `"},"$patch":"replace"}}}`)
+					return nil
+				}
+			case *qstsv1a1.QuarksStatefulSet:
+				if qsts != nil {
+					qsts.DeepCopyInto(object)
+					return nil
+				}
+			case *qjv1a1.QuarksJob:
+				if qJob != nil && qJob.Name == nn.Name {
+					qJob.DeepCopyInto(object)
+					return nil
+				}
 			case *corev1.Pod:
 				if replicas != readyReplicas && noneReadyPod.Name == nn.Name {
 					noneReadyPod.DeepCopyInto(object)
@@ -147,11 +189,27 @@ var _ = Describe("ReconcileStatefulSetRollout", func() {
 			return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
 		})
 
+		client.ListCalls(func(ctx context.Context, object runtime.Object, _ ...k8sclient.ListOption) error {
+			switch object := object.(type) {
+			case *appsv1.StatefulSetList:
+				object.Items = append(statefulSets, *statefulSet)
+			}
+			return nil
+		})
+
 		client.UpdateCalls(func(ctx context.Context, object runtime.Object, option ...k8sclient.UpdateOption) error {
-			object.(*appsv1.StatefulSet).DeepCopyInto(&updatedStatefulSet)
+			switch object := object.(type) {
+			case *appsv1.StatefulSet:
+				object.DeepCopyInto(&updatedStatefulSet)
+			case *qjv1a1.QuarksJob:
+				updatedQuarksJob = object.DeepCopy()
+			}
 			return nil
 		})
 
+		statusWriter = &cfakes.FakeStatusWriter{}
+		client.StatusCalls(func() k8sclient.StatusWriter { return statusWriter })
+
 		manager.GetClientReturns(client)
 		reconciler = statefulset.NewStatefulSetRolloutReconciler(ctx, config, manager)
 	})
@@ -270,6 +328,132 @@ var _ = Describe("ReconcileStatefulSetRollout", func() {
 					Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue("quarks.cloudfoundry.org/canary-rollout", "Canary"))
 				})
 			})
+
+			Context("with job hashes in the pod template", func() {
+				request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+				hashes := `{"redis":{"job":"a","spec":"b"}}`
+				var (
+					updatedPods        []*corev1.Pod
+					statefulSetUpdates int
+				)
+
+				JustBeforeEach(func() {
+					statefulSet.Spec.Template.Annotations = map[string]string{statefulset.AnnotationJobHashes: hashes}
+					updatedPods = []*corev1.Pod{}
+					statefulSetUpdates = 0
+					client.UpdateCalls(func(ctx context.Context, object runtime.Object, option ...k8sclient.UpdateOption) error {
+						switch object := object.(type) {
+						case *appsv1.StatefulSet:
+							object.DeepCopyInto(&updatedStatefulSet)
+							statefulSetUpdates++
+						case *corev1.Pod:
+							updatedPods = append(updatedPods, object.DeepCopy())
+						}
+						return nil
+					})
+				})
+
+				It("annotates the pods with the desired job hashes before replacing them", func() {
+					result, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(5 * time.Second))
+					Expect(updatedPods).To(HaveLen(2))
+					for _, pod := range updatedPods {
+						Expect(pod.Annotations).To(HaveKeyWithValue(statefulset.AnnotationDesiredJobHashes, hashes))
+					}
+					Expect(statefulSetUpdates).To(Equal(0))
+				})
+
+				It("starts the canaries once the pods are annotated", func() {
+					readyPod.Annotations = map[string]string{statefulset.AnnotationDesiredJobHashes: hashes}
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(updatedPods).To(BeEmpty())
+					Expect(statefulSetUpdates).To(Equal(1))
+					Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Canary"))
+				})
+			})
+
+			Context("in a zone of an instance group with multiple zones", func() {
+				request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo-z1", Namespace: "default"}}
+
+				zone := func(index int, version string, state string) appsv1.StatefulSet {
+					return appsv1.StatefulSet{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "foo-z" + strconv.Itoa(index),
+							Namespace: "default",
+							Labels: map[string]string{
+								qstsv1a1.LabelQStsName: "foo",
+								qstsv1a1.LabelAZIndex:  strconv.Itoa(index),
+							},
+							Annotations: map[string]string{
+								qstsv1a1.AnnotationVersion:          version,
+								statefulset.AnnotationCanaryRollout: state,
+							},
+						},
+					}
+				}
+
+				BeforeEach(func() {
+					annotations[qstsv1a1.AnnotationVersion] = "2"
+				})
+
+				JustBeforeEach(func() {
+					statefulSet.Name = "foo-z1"
+					statefulSet.Labels = map[string]string{
+						qstsv1a1.LabelQStsName: "foo",
+						qstsv1a1.LabelAZIndex:  "1",
+					}
+				})
+
+				It("waits while a previous zone is rolled out", func() {
+					statefulSets = append(statefulSets, zone(0, "2", "Rollout"), zone(2, "2", "Pending"))
+
+					result, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.RequeueAfter).To(Equal(10 * time.Second))
+					Expect(client.UpdateCallCount()).To(Equal(0))
+				})
+
+				It("waits while the rollout of a previous zone has failed", func() {
+					statefulSets = append(statefulSets, zone(0, "2", "Failed"))
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.UpdateCallCount()).To(Equal(0))
+				})
+
+				It("starts the canaries once the previous zones are rolled out", func() {
+					statefulSets = append(statefulSets, zone(0, "2", "Done"), zone(2, "2", "Pending"))
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.UpdateCallCount()).To(Equal(1))
+					Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Canary"))
+				})
+
+				It("ignores the zones of other versions", func() {
+					statefulSets = append(statefulSets, zone(0, "1", "Rollout"))
+
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Canary"))
+				})
+
+				It("starts the watch times once the previous zones are rolled out", func() {
+					annotations[statefulset.AnnotationUpdateStartTime] = strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+					statefulSets = append(statefulSets, zone(0, "2", "Done"))
+
+					result, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.RequeueAfter).To(And(BeNumerically("<=", timeout), BeNumerically(">", timeout-timeoutTolerance)))
+					Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Canary"))
+					startTime, err := strconv.ParseInt(updatedStatefulSet.Annotations[statefulset.AnnotationUpdateStartTime], 10, 64)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(time.Unix(startTime, 0)).To(BeTemporally("~", time.Now(), 5*time.Second))
+				})
+			})
 		})
 
 		Context("in rollout state 'Rollout'", func() {
@@ -400,4 +584,214 @@ var _ = Describe("ReconcileStatefulSetRollout", func() {
 
 		})
 	})
+
+	Context("with a post-deploy job", func() {
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+
+		BeforeEach(func() {
+			annotations[statefulset.AnnotationCanaryRollout] = "Rollout"
+			annotations[statefulset.AnnotationPostDeployJob] = "foo-post-deploy"
+			readyReplicas = 3
+			replicas = 3
+			updatedReplicas = 3
+			qJob = &qjv1a1.QuarksJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-post-deploy", Namespace: "default"},
+				Spec: qjv1a1.QuarksJobSpec{
+					Trigger: qjv1a1.Trigger{Strategy: qjv1a1.TriggerManual},
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			statefulSet.Status.UpdateRevision = "foo-2"
+			readyPod.Labels = map[string]string{appsv1.StatefulSetRevisionLabel: "foo-2"}
+		})
+
+		zone := func(name string, state string) appsv1.StatefulSet {
+			return appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+					Annotations: map[string]string{
+						statefulset.AnnotationCanaryRollout: state,
+						statefulset.AnnotationPostDeployJob: "foo-post-deploy",
+					},
+				},
+				Status: appsv1.StatefulSetStatus{UpdateRevision: name + "-2"},
+			}
+		}
+
+		It("triggers the job once the rollout is done", func() {
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Done"))
+			Expect(updatedQuarksJob).ToNot(BeNil())
+			Expect(updatedQuarksJob.Spec.Trigger.Strategy).To(Equal(qjv1a1.TriggerNow))
+			Expect(updatedQuarksJob.Annotations).To(HaveKeyWithValue(statefulset.AnnotationPostDeployRevisions, "foo/foo-2"))
+		})
+
+		It("triggers the job once all zones are rolled out", func() {
+			statefulSets = append(statefulSets, zone("foo-z1", "Done"))
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedQuarksJob).ToNot(BeNil())
+			Expect(updatedQuarksJob.Annotations).To(HaveKeyWithValue(statefulset.AnnotationPostDeployRevisions, "foo-z1/foo-z1-2,foo/foo-2"))
+		})
+
+		It("doesn't trigger the job while another zone is rolled out", func() {
+			statefulSets = append(statefulSets, zone("foo-z1", "Rollout"))
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Done"))
+			Expect(updatedQuarksJob).To(BeNil())
+		})
+
+		It("doesn't trigger the job twice for the same revisions", func() {
+			qJob.Annotations = map[string]string{statefulset.AnnotationPostDeployRevisions: "foo/foo-2"}
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedQuarksJob).To(BeNil())
+		})
+
+		It("doesn't trigger the job after a rollback", func() {
+			annotations[statefulset.AnnotationRolledBackRevision] = "3"
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedQuarksJob).To(BeNil())
+		})
+	})
+
+	Context("if the rollout fails", func() {
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+
+		BeforeEach(func() {
+			annotations[statefulset.AnnotationCanaryRollout] = "Canary"
+			annotations[statefulset.AnnotationCanaryWatchTime] = "-1"
+			replicas = 3
+			readyReplicas = 2
+			updatedReplicas = 1
+			partition = 2
+			revisions["1"] = "old"
+			revisions["2"] = "new"
+			qsts = &qstsv1a1.QuarksStatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			}
+		})
+
+		JustBeforeEach(func() {
+			statefulSet.Status.UpdateRevision = "2"
+			statefulSet.Spec.Template.Spec.Subdomain = "new"
+		})
+
+		updatedQuarksStatefulSet := func() *qstsv1a1.QuarksStatefulSet {
+			Expect(statusWriter.UpdateCallCount()).To(Equal(1))
+			_, object, _ := statusWriter.UpdateArgsForCall(0)
+			return object.(*qstsv1a1.QuarksStatefulSet)
+		}
+
+		It("records the failure reason", func() {
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Failed"))
+			Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationRolloutFailure, "CanaryWatchTimeExceeded"))
+			Expect(updatedStatefulSet.Spec.Template.Spec.Subdomain).To(Equal("new"))
+
+			failures := updatedQuarksStatefulSet().Status.FailedRollouts
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0].StatefulSet).To(Equal("foo"))
+			Expect(failures[0].Revision).To(Equal("2"))
+			Expect(failures[0].Reason).To(Equal("CanaryWatchTimeExceeded"))
+			Expect(failures[0].RolledBack).To(BeFalse())
+		})
+
+		Context("with rollback enabled", func() {
+			BeforeEach(func() {
+				annotations[statefulset.AnnotationRollback] = "true"
+			})
+
+			It("restores the previous revision", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(updatedStatefulSet.Spec.Template.Spec.Subdomain).To(Equal("old"))
+				Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Pending"))
+				Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationRolloutFailure, "CanaryWatchTimeExceeded"))
+				Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationRolledBackRevision, "2"))
+				Expect(*updatedStatefulSet.Spec.UpdateStrategy.RollingUpdate.Partition).To(BeEquivalentTo(replicas))
+
+				failures := updatedQuarksStatefulSet().Status.FailedRollouts
+				Expect(failures).To(HaveLen(1))
+				Expect(failures[0].RolledBack).To(BeTrue())
+			})
+
+			It("doesn't roll back a failed rollback", func() {
+				annotations[statefulset.AnnotationRolledBackRevision] = "3"
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(updatedStatefulSet.Spec.Template.Spec.Subdomain).To(Equal("new"))
+				Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Failed"))
+			})
+
+			It("fails if the previous revision doesn't exist", func() {
+				delete(revisions, "1")
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("could not get controller revision '1'"))
+				Expect(client.UpdateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("and it is retried", func() {
+			BeforeEach(func() {
+				annotations[statefulset.AnnotationCanaryRollout] = "Failed"
+				annotations[statefulset.AnnotationCanaryWatchTime] = strconv.FormatInt(timeout.Milliseconds(), 10)
+				annotations[statefulset.AnnotationRolloutFailure] = "CanaryWatchTimeExceeded"
+				annotations[statefulset.AnnotationRetryRollout] = "true"
+				qsts.Status.FailedRollouts = []qstsv1a1.RolloutFailure{
+					{StatefulSet: "foo", Reason: "CanaryWatchTimeExceeded"},
+					{StatefulSet: "foo-z1", Reason: "UpdateWatchTimeExceeded"},
+				}
+			})
+
+			It("starts the rollout again", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Pending"))
+				Expect(updatedStatefulSet.Annotations).NotTo(HaveKey(statefulset.AnnotationRolloutFailure))
+				Expect(updatedStatefulSet.Annotations).NotTo(HaveKey(statefulset.AnnotationRetryRollout))
+				Expect(updatedStatefulSet.Spec.Template.Spec.Subdomain).To(Equal("new"))
+
+				failures := updatedQuarksStatefulSet().Status.FailedRollouts
+				Expect(failures).To(HaveLen(1))
+				Expect(failures[0].StatefulSet).To(Equal("foo-z1"))
+			})
+
+			It("restores the rolled back revision", func() {
+				annotations[statefulset.AnnotationCanaryRollout] = "Done"
+				annotations[statefulset.AnnotationRolledBackRevision] = "2"
+				statefulSet.Spec.Template.Spec.Subdomain = "old"
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(updatedStatefulSet.Spec.Template.Spec.Subdomain).To(Equal("new"))
+				Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Pending"))
+				Expect(updatedStatefulSet.Annotations).NotTo(HaveKey(statefulset.AnnotationRolledBackRevision))
+			})
+
+			It("ignores the retry of a successful rollout", func() {
+				annotations[statefulset.AnnotationCanaryRollout] = "Done"
+
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(updatedStatefulSet.Annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaryRollout, "Done"))
+				Expect(updatedStatefulSet.Annotations).NotTo(HaveKey(statefulset.AnnotationRetryRollout))
+				Expect(statusWriter.UpdateCallCount()).To(Equal(0))
+			})
+		})
+	})
 })
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
@@ -79,9 +80,62 @@ func ComputeAnnotations(ig *manifest.InstanceGroup) (map[string]string, error) {
 		statefulSetAnnotations[AnnotationUpdateWatchTime] = updateWatchTime
 	}
 
+	if ig.Update.Canaries != nil {
+		if *ig.Update.Canaries < 0 {
+			return nil, fmt.Errorf("invalid canaries")
+		}
+		statefulSetAnnotations[AnnotationCanaries] = strconv.Itoa(*ig.Update.Canaries)
+	}
+
+	maxInFlight, err := ExtractMaxInFlight(ig.Update.MaxInFlight)
+	if err != nil {
+		return nil, err
+	}
+	if maxInFlight != "" {
+		statefulSetAnnotations[AnnotationMaxInFlight] = maxInFlight
+	}
+
 	return statefulSetAnnotations, nil
 }
 
+var (
+	maxInFlightRegex = regexp.MustCompile(`^\s*(\d+%?)\s*$`) // https://github.com/cloudfoundry/bosh/blob/914edca5278b994df7d91620c4f55f1c6665f81c/src/bosh-director/lib/bosh/director/numerical_value_calculator.rb
+)
+
+//ExtractMaxInFlight validates max_in_flight, which is an absolute number or a percentage of the instances
+func ExtractMaxInFlight(rawMaxInFlight string) (string, error) {
+	if rawMaxInFlight == "" {
+		return "", nil
+	}
+	if matches := maxInFlightRegex.FindStringSubmatch(rawMaxInFlight); len(matches) > 0 {
+		return matches[1], nil
+	}
+	return "", fmt.Errorf("invalid max_in_flight")
+}
+
+// numberOfPods resolves an absolute number or a percentage of the replicas
+// the same way as the BOSH director, percentages are rounded down
+func numberOfPods(value string, replicas int32) (int32, error) {
+	matches := maxInFlightRegex.FindStringSubmatch(value)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("invalid number of pods '%s'", value)
+	}
+
+	if strings.HasSuffix(matches[1], "%") {
+		percentage, err := strconv.Atoi(strings.TrimSuffix(matches[1], "%"))
+		if err != nil {
+			return 0, err
+		}
+		return util.MinInt32(int32(percentage)*replicas/100, replicas), nil
+	}
+
+	n, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, err
+	}
+	return int32(n), nil
+}
+
 //ExtractWatchTime computes the watch time from a range or an absolute value
 func ExtractWatchTime(rawWatchTime string, field string) (string, error) {
 	if rawWatchTime == "" {
@@ -44,6 +44,46 @@ var _ = Describe("FilterLabels", func() {
 
 })
 
+var _ = Describe("ComputeAnnotations", func() {
+	var ig *manifest.InstanceGroup
+
+	BeforeEach(func() {
+		ig = &manifest.InstanceGroup{
+			Update: &manifest.Update{
+				Canaries:    pointers.Int(2),
+				MaxInFlight: " 25% ",
+			},
+		}
+	})
+
+	It("adds canaries and max_in_flight", func() {
+		annotations, err := statefulset.ComputeAnnotations(ig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).To(HaveKeyWithValue(statefulset.AnnotationCanaries, "2"))
+		Expect(annotations).To(HaveKeyWithValue(statefulset.AnnotationMaxInFlight, "25%"))
+	})
+
+	It("omits canaries and max_in_flight if they are not set", func() {
+		ig.Update = &manifest.Update{}
+		annotations, err := statefulset.ComputeAnnotations(ig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations).NotTo(HaveKey(statefulset.AnnotationCanaries))
+		Expect(annotations).NotTo(HaveKey(statefulset.AnnotationMaxInFlight))
+	})
+
+	It("fails for an invalid max_in_flight", func() {
+		ig.Update.MaxInFlight = "1.5"
+		_, err := statefulset.ComputeAnnotations(ig)
+		Expect(err).To(MatchError("invalid max_in_flight"))
+	})
+
+	It("fails for negative canaries", func() {
+		ig.Update.Canaries = pointers.Int(-1)
+		_, err := statefulset.ComputeAnnotations(ig)
+		Expect(err).To(MatchError("invalid canaries"))
+	})
+})
+
 var _ = Describe("CleanupNonReadyPod", func() {
 	var (
 		ctx          context.Context
@@ -0,0 +1,60 @@
+package statefulset
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	appsv1 "k8s.io/api/apps/v1"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	qstsv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/quarksstatefulset/v1alpha1"
+)
+
+// zoneRolloutRequeueAfter is the interval in which a pending StatefulSet
+// checks whether the StatefulSets of the previous zones are rolled out
+const zoneRolloutRequeueAfter = 10 * time.Second
+
+// previousZoneInRollout returns the name of a StatefulSet of a previous zone
+// of the same QuarksStatefulSet version, which is not rolled out yet. Like the
+// BOSH director updates one AZ after another, the zones are rolled out one
+// after another, so canaries and max_in_flight limit the pods updated in
+// parallel for the whole instance group instead of for each zone. A failed
+// zone stops the rollout of the following zones until it's retried.
+func (r *ReconcileStatefulSetRollout) previousZoneInRollout(ctx context.Context, statefulSet appsv1.StatefulSet) (string, error) {
+	qStsName, ok := statefulSet.Labels[qstsv1a1.LabelQStsName]
+	if !ok {
+		return "", nil
+	}
+	zoneIndex, err := strconv.Atoi(statefulSet.Labels[qstsv1a1.LabelAZIndex])
+	if err != nil {
+		return "", nil
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	err = r.client.List(ctx, statefulSets,
+		crc.InNamespace(statefulSet.Namespace),
+		crc.MatchingLabels{qstsv1a1.LabelQStsName: qStsName},
+	)
+	if err != nil {
+		return "", errors.Wrapf(err, "listing StatefulSets of QuarksStatefulSet '%s/%s'", statefulSet.Namespace, qStsName)
+	}
+
+	version := statefulSet.Annotations[qstsv1a1.AnnotationVersion]
+	for _, sts := range statefulSets.Items {
+		if sts.Labels[qstsv1a1.LabelQStsName] != qStsName || sts.Annotations[qstsv1a1.AnnotationVersion] != version {
+			continue
+		}
+		index, err := strconv.Atoi(sts.Labels[qstsv1a1.LabelAZIndex])
+		if err != nil || index >= zoneIndex {
+			continue
+		}
+		if state, ok := sts.Annotations[AnnotationCanaryRollout]; ok && state != rolloutStateDone {
+			return sts.Name, nil
+		}
+	}
+
+	return "", nil
+}
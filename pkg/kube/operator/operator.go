@@ -2,12 +2,16 @@ package operator
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/pkg/errors"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	extv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	credsgen "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
@@ -19,19 +23,22 @@ import (
 	"code.cloudfoundry.org/quarks-utils/pkg/config"
 	"code.cloudfoundry.org/quarks-utils/pkg/crd"
 	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+	"code.cloudfoundry.org/quarks-utils/pkg/pointers"
 )
 
 type resource struct {
-	name         string
-	kind         string
-	plural       string
-	shortNames   []string
-	groupVersion schema.GroupVersion
-	validation   *extv1.CustomResourceValidation
+	name           string
+	kind           string
+	plural         string
+	shortNames     []string
+	groupVersion   schema.GroupVersion
+	validation     *extv1.CustomResourceValidation
+	printerColumns []extv1.CustomResourceColumnDefinition
+	scale          *extv1.CustomResourceSubresourceScale
 }
 
 // NewManager adds schemes, controllers and starts the manager
-func NewManager(ctx context.Context, config *config.Config, cfg *rest.Config, options manager.Options) (manager.Manager, error) {
+func NewManager(ctx context.Context, config *config.Config, controllerOptions controllers.Options, cfg *rest.Config, options manager.Options) (manager.Manager, error) {
 	mgr, err := manager.New(cfg, options)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize new manager")
@@ -48,13 +55,13 @@ func NewManager(ctx context.Context, config *config.Config, cfg *rest.Config, op
 	}
 
 	// Setup Hooks for all resources
-	err = controllers.AddHooks(ctx, config, mgr, credsgen.NewInMemoryGenerator(log))
+	err = controllers.AddHooks(ctx, config, mgr, credsgen.NewInMemoryGenerator(log), controllerOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to setup hooks")
 	}
 
 	// Setup all Controllers
-	err = controllers.AddToManager(ctx, config, mgr)
+	err = controllers.AddToManager(ctx, config, mgr, controllerOptions)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to add controllers to manager")
 	}
@@ -77,6 +84,8 @@ func ApplyCRDs(config *rest.Config) error {
 			bdv1.BOSHDeploymentResourceShortNames,
 			bdv1.SchemeGroupVersion,
 			&bdv1.BOSHDeploymentValidation,
+			bdv1.BOSHDeploymentAdditionalPrinterColumns,
+			nil,
 		},
 		{
 			qjv1a1.QuarksJobResourceName,
@@ -85,6 +94,8 @@ func ApplyCRDs(config *rest.Config) error {
 			qjv1a1.QuarksJobResourceShortNames,
 			qjv1a1.SchemeGroupVersion,
 			&qjv1a1.QuarksJobValidation,
+			nil,
+			nil,
 		},
 		{
 			qsv1a1.QuarksSecretResourceName,
@@ -93,6 +104,8 @@ func ApplyCRDs(config *rest.Config) error {
 			qsv1a1.QuarksSecretResourceShortNames,
 			qsv1a1.SchemeGroupVersion,
 			nil,
+			nil,
+			nil,
 		},
 		{
 			qstsv1a1.QuarksStatefulSetResourceName,
@@ -101,17 +114,11 @@ func ApplyCRDs(config *rest.Config) error {
 			qstsv1a1.QuarksStatefulSetResourceShortNames,
 			qstsv1a1.SchemeGroupVersion,
 			&qstsv1a1.QuarksStatefulSetValidation,
+			nil,
+			&qstsv1a1.QuarksStatefulSetScaleSubresource,
 		},
 	} {
-		err = crd.ApplyCRD(
-			exClient,
-			res.name,
-			res.kind,
-			res.plural,
-			res.shortNames,
-			res.groupVersion,
-			res.validation,
-		)
+		err = applyCRD(exClient, res)
 		if err != nil {
 			return errors.Wrapf(err, "failed to apply CRD '%s'", res.name)
 		}
@@ -123,3 +130,69 @@ func ApplyCRDs(config *rest.Config) error {
 
 	return nil
 }
+
+// applyCRD creates or updates the CRD. In contrast to crd.ApplyCRD it
+// supports additional printer columns and the scale subresource, which are
+// part of the same create or update as the rest of the spec.
+func applyCRD(client extv1client.ApiextensionsV1beta1Interface, res resource) error {
+	spec := extv1.CustomResourceDefinitionSpec{
+		Group: res.groupVersion.Group,
+		Versions: []extv1.CustomResourceDefinitionVersion{
+			{
+				Name:    res.groupVersion.Version,
+				Served:  true,
+				Storage: true,
+			},
+		},
+		Validation:               res.validation,
+		AdditionalPrinterColumns: res.printerColumns,
+		PreserveUnknownFields:    pointers.Bool(false),
+		Subresources: &extv1.CustomResourceSubresources{
+			Status: &extv1.CustomResourceSubresourceStatus{},
+			Scale:  res.scale,
+		},
+		Scope: extv1.NamespaceScoped,
+		Names: extv1.CustomResourceDefinitionNames{
+			Kind:       res.kind,
+			Plural:     res.plural,
+			ShortNames: res.shortNames,
+		},
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		exCrd, err := client.CustomResourceDefinitions().Get(res.name, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "getting CRD '%s'", res.name)
+			}
+			_, err := client.CustomResourceDefinitions().Create(&extv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: res.name},
+				Spec:       spec,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "creating CRD '%s'", res.name)
+			}
+			return nil
+		}
+
+		// Only compare the fields set by the operator, the API server
+		// defaults the others
+		updated := exCrd.DeepCopy()
+		updated.Spec.Group = spec.Group
+		updated.Spec.Versions = spec.Versions
+		updated.Spec.Validation = spec.Validation
+		updated.Spec.AdditionalPrinterColumns = spec.AdditionalPrinterColumns
+		updated.Spec.PreserveUnknownFields = spec.PreserveUnknownFields
+		updated.Spec.Subresources = spec.Subresources
+		updated.Spec.Scope = spec.Scope
+		updated.Spec.Names = spec.Names
+		updated.Spec.Names.Singular = exCrd.Spec.Names.Singular
+		updated.Spec.Names.ListKind = exCrd.Spec.Names.ListKind
+		if reflect.DeepEqual(updated.Spec, exCrd.Spec) {
+			return nil
+		}
+
+		_, err = client.CustomResourceDefinitions().Update(updated)
+		return err
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/mutate"
 )
@@ -159,7 +160,16 @@ func (dns *boshDomainNameService) Reconcile(ctx context.Context, namespace strin
 		Labels:    map[string]string{"app": appName},
 	}
 
-	corefile, err := dns.createCorefile(namespace)
+	deployments := &bdv1.BOSHDeploymentList{}
+	if err := c.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return errors.Wrapf(err, "failed to list BOSHDeployments in namespace '%s'", namespace)
+	}
+	deploymentNames := map[string]struct{}{}
+	for _, deployment := range deployments.Items {
+		deploymentNames[deployment.Name] = struct{}{}
+	}
+
+	corefile, err := dns.createCorefile(namespace, deploymentNames)
 	if err != nil {
 		return err
 	}
@@ -273,31 +283,42 @@ func (dns *boshDomainNameService) Reconcile(ctx context.Context, namespace strin
 	return nil
 }
 
-func (dns *boshDomainNameService) createCorefile(namespace string) (string, error) {
+func (dns *boshDomainNameService) createCorefile(namespace string, deploymentNames map[string]struct{}) (string, error) {
 	rewrites := make([]string, 0)
 	for _, alias := range dns.Aliases {
 		for _, target := range alias.Targets {
-			// Implement BOSH DNS placeholder alias: https://bosh.io/docs/dns/#placeholder-alias.
-			if target.Query == "_" {
+			deploymentName, local := dns.targetDeployment(target, deploymentNames)
+			if local {
 				instanceGroup, found := dns.InstanceGroups.InstanceGroupByName(target.InstanceGroup)
-				if !found {
+				if !found || !hasNetwork(instanceGroup, target.Network) {
 					continue
 				}
-				for i := 0; i < instanceGroup.Instances; i++ {
-					id := fmt.Sprintf("%s-%d", target.InstanceGroup, i)
-					from := strings.Replace(alias.Domain, "_", id, 1)
-					serviceName := instanceGroup.IndexedServiceName(dns.ManifestName, i)
-					to := fmt.Sprintf("%s.%s.svc.%s", serviceName, namespace, clusterDomain)
-					rewrites = append(rewrites, dnsTemplate(from, to, target.Query))
-				}
-			} else {
-				from := alias.Domain
-				to := fmt.Sprintf("%s.%s.svc.%s", dns.HeadlessServiceName(target.InstanceGroup), namespace, clusterDomain)
-				rewrites = append(rewrites, dnsTemplate(from, to, target.Query))
 			}
+
+			// Implement BOSH DNS placeholder alias: https://bosh.io/docs/dns/#placeholder-alias.
+			// The placeholder is replaced by the instance id, e.g. `diego-cell-0`.
+			if target.Query == "_" {
+				parts := strings.SplitN(alias.Domain, "_", 2)
+				match := fmt.Sprintf(`^%s%s-(\d+)%s\.$`,
+					regexp.QuoteMeta(parts[0]), regexp.QuoteMeta(target.InstanceGroup), regexp.QuoteMeta(parts[len(parts)-1]))
+				to := dns.serviceAddress(indexedServiceName(target.InstanceGroup, deploymentName, "{{ index .Match 1 }}"), namespace)
+				rewrites = append(rewrites, templateRules(placeholderZone(alias.Domain), match, to))
+				continue
+			}
+
+			// All (`q-s4`) queries resolve to the service, which publishes
+			// all pods. All other queries resolve to the headless service,
+			// which only publishes ready pods, i.e. the healthy instances.
+			to := dns.serviceAddress(util.ServiceName(target.InstanceGroup, deploymentName, 63), namespace)
+			if target.Query == "q-s4" {
+				to = dns.serviceAddress(allServiceName(target.InstanceGroup, deploymentName), namespace)
+			}
+			rewrites = append(rewrites, dnsTemplate(alias.Domain, to, target.Query))
 		}
 	}
 
+	rewrites = append(rewrites, dns.nativeTemplates(namespace)...)
+
 	tmpl := template.Must(template.New("Corefile").Parse(corefileTemplate))
 	var config strings.Builder
 	if err := tmpl.Execute(&config, rewrites); err != nil {
@@ -307,6 +328,96 @@ func (dns *boshDomainNameService) createCorefile(namespace string) (string, erro
 	return config.String(), nil
 }
 
+// nativeTemplates resolves the BOSH native DNS names of the instance groups,
+// see https://bosh.io/docs/dns/#constructing-queries.
+// `q-s<status>.<instance-group>.<network>.<deployment>.bosh` resolves to the
+// headless service of the instance group. Kubernetes only publishes ready
+// pods, so healthy (`q-s3`) queries are backed by pod readiness. Smart
+// (`q-s0`) queries resolve the same way. All (`q-s4`) queries resolve to the
+// service, which publishes the pods which are not ready, too.
+// `<instance-group>-<index>.<instance-group>.<network>.<deployment>.bosh`
+// resolves to the service of a single instance.
+func (dns *boshDomainNameService) nativeTemplates(namespace string) []string {
+	rewrites := make([]string, 0)
+	zone := fmt.Sprintf("%s.bosh", dns.ManifestName)
+	for _, ig := range dns.InstanceGroups {
+		suffix := fmt.Sprintf(`\.%s\.%s\.%s\.$`, regexp.QuoteMeta(ig.Name), networkPattern(ig), regexp.QuoteMeta(zone))
+
+		to := dns.serviceAddress(dns.HeadlessServiceName(ig.Name), namespace)
+		rewrites = append(rewrites, templateRules(zone, `^q-s[03]`+suffix, to))
+
+		to = dns.serviceAddress(ig.AllServiceName(dns.ManifestName), namespace)
+		rewrites = append(rewrites, templateRules(zone, `^q-s4`+suffix, to))
+
+		to = dns.serviceAddress(indexedServiceName(ig.Name, dns.ManifestName, "{{ index .Match 1 }}"), namespace)
+		rewrites = append(rewrites, templateRules(zone, fmt.Sprintf(`^%s-(\d+)%s`, regexp.QuoteMeta(ig.Name), suffix), to))
+	}
+	return rewrites
+}
+
+// targetDeployment returns the name of the BOSHDeployment an alias target
+// refers to and whether it is this deployment. A target refers to another
+// deployment only if a BOSHDeployment of that name exists in the namespace,
+// since manifests usually name their own deployment differently.
+func (dns *boshDomainNameService) targetDeployment(target Target, deploymentNames map[string]struct{}) (string, bool) {
+	if _, found := deploymentNames[target.Deployment]; found && target.Deployment != dns.ManifestName {
+		return target.Deployment, false
+	}
+	return dns.ManifestName, true
+}
+
+func (dns *boshDomainNameService) serviceAddress(serviceName string, namespace string) string {
+	return fmt.Sprintf("%s.%s.svc.%s", serviceName, namespace, clusterDomain)
+}
+
+// indexedServiceName mirrors InstanceGroup.IndexedServiceName, index may be a template expression.
+func indexedServiceName(instanceGroupName string, deploymentName string, index string) string {
+	return fmt.Sprintf("%s-%s", util.ServiceName(instanceGroupName, deploymentName, 53), index)
+}
+
+// allServiceName mirrors InstanceGroup.AllServiceName.
+func allServiceName(instanceGroupName string, deploymentName string) string {
+	return fmt.Sprintf("%s-all", util.ServiceName(instanceGroupName, deploymentName, 59))
+}
+
+// hasNetwork checks if the instance group is in the network. Instance groups
+// without networks are reachable from any network.
+func hasNetwork(ig *bdm.InstanceGroup, network string) bool {
+	if network == "" || len(ig.Networks) == 0 {
+		return true
+	}
+	for _, n := range ig.Networks {
+		if n != nil && n.Name == network {
+			return true
+		}
+	}
+	return false
+}
+
+// networkPattern matches the network names of the instance group.
+func networkPattern(ig *bdm.InstanceGroup) string {
+	names := []string{}
+	for _, n := range ig.Networks {
+		if n != nil && n.Name != "" {
+			names = append(names, regexp.QuoteMeta(n.Name))
+		}
+	}
+	if len(names) == 0 {
+		return `[A-Za-z0-9\-]+`
+	}
+	return fmt.Sprintf("(?:%s)", strings.Join(names, "|"))
+}
+
+// placeholderZone returns the zone of a placeholder alias domain, i.e. the
+// domain following the `_` label.
+func placeholderZone(domain string) string {
+	zone := domain[strings.Index(domain, "_")+1:]
+	if i := strings.Index(zone, "."); i >= 0 {
+		return zone[i+1:]
+	}
+	return "."
+}
+
 // The Corefile values other than the rewrites were based on the default cluster CoreDNS Corefile.
 const corefileTemplate = `
 .:8053 {
@@ -327,23 +438,35 @@ func dnsTemplate(from, to, queryType string) string {
 	if queryType == "*" {
 		matchPrefix = `(([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])\.)*`
 	}
-	return fmt.Sprintf(cnameTemplate, regexp.QuoteMeta(from), matchPrefix, to, from)
+	return templateRules(from, fmt.Sprintf(`^%s%s\.$`, matchPrefix, regexp.QuoteMeta(from)), to)
+}
+
+// templateRules answers queries in the zone matching the regular expression
+// with a CNAME to the target. The target may refer to the submatches.
+// CoreDNS tries the templates in order and answers NXDOMAIN if the zone of a
+// template matches, but its regular expression doesn't, so all templates fall
+// through to the next one and finally to the forward plugin.
+func templateRules(zone, match, to string) string {
+	return fmt.Sprintf(cnameTemplate, match, to, zone)
 }
 
 const cnameTemplate = `
-template IN A %[4]s {
-	match ^%[2]s%[1]s\.$
-	answer "{{ .Name }} 60 IN CNAME %[3]s"
+template IN A %[3]s {
+	match %[1]s
+	answer "{{ .Name }} 60 IN CNAME %[2]s"
+	fallthrough
 	upstream
 }
-template IN AAAA %[4]s {
-	match ^%[2]s%[1]s\.$
-	answer "{{ .Name }} 60 IN CNAME %[3]s"
+template IN AAAA %[3]s {
+	match %[1]s
+	answer "{{ .Name }} 60 IN CNAME %[2]s"
+	fallthrough
 	upstream
 }
-template IN CNAME %[4]s {
-	match ^%[2]s%[1]s\.$
-	answer "{{ .Name }} 60 IN CNAME %[3]s"
+template IN CNAME %[3]s {
+	match %[1]s
+	answer "{{ .Name }} 60 IN CNAME %[2]s"
+	fallthrough
 	upstream
 }`
 
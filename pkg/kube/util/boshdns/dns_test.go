@@ -3,6 +3,8 @@ package boshdns_test
 import (
 	"context"
 	"encoding/json"
+	"regexp"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -11,9 +13,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
 )
 
@@ -150,14 +154,65 @@ func loadAddOn() *manifest.AddOn {
 	return &addOn
 }
 
+// resolve returns the CNAME target of the first Corefile A record template
+// answering the name, like CoreDNS does. A template answers NXDOMAIN if its
+// zone matches, but its regular expression doesn't and it doesn't fall through.
+// An empty target means the name is forwarded.
+func resolve(corefile string, name string) string {
+	re := regexp.MustCompile(`(?m)^\s*template IN A (\S+) \{\n\s*match (\S+)\n\s*answer "\{\{ \.Name \}\} 60 IN CNAME (.+)"\n((?:\s*\w+\n)*)\}`)
+	for _, m := range re.FindAllStringSubmatch(corefile, -1) {
+		zone := strings.TrimSuffix(m[1], ".") + "."
+		if zone != "." && name != zone && !strings.HasSuffix(name, "."+zone) {
+			continue
+		}
+		if regexp.MustCompile(m[2]).MatchString(name) {
+			return m[3]
+		}
+		if !regexp.MustCompile(`(?m)^\s*fallthrough$`).MatchString(m[4]) {
+			return "NXDOMAIN"
+		}
+	}
+	return ""
+}
+
 var _ = Describe("BOSH DNS", func() {
 	Context("bosh-dns", func() {
+		var (
+			scheme         *runtime.Scheme
+			instanceGroups manifest.InstanceGroups
+		)
+
+		BeforeEach(func() {
+			boshdns.SetClusterDomain("cluster.local")
+			scheme = runtime.NewScheme()
+			Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			Expect(appsv1.AddToScheme(scheme)).To(Succeed())
+			Expect(bdv1.AddToScheme(scheme)).To(Succeed())
+			instanceGroups = manifest.InstanceGroups{
+				{Name: "diego-cell", Instances: 2, Networks: []*manifest.Network{{Name: "default"}}},
+				{Name: "scheduler", Instances: 1},
+			}
+		})
+
+		corefile := func(objects ...runtime.Object) string {
+			d, err := boshdns.NewBoshDomainNameService("scf", loadAddOn(), instanceGroups)
+			Expect(err).NotTo(HaveOccurred())
+
+			client := fake.NewFakeClientWithScheme(scheme, objects...)
+			err = d.Reconcile(context.Background(), "default", client, func(object v1.Object) error {
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			configMap := &corev1.ConfigMap{}
+			err = client.Get(context.Background(), crc.ObjectKey{Namespace: "default", Name: "scf-bosh-dns"}, configMap)
+			Expect(err).NotTo(HaveOccurred())
+			return configMap.Data["Corefile"]
+		}
+
 		It("reconciles dns stuff", func() {
 			d, err := boshdns.NewBoshDomainNameService("scf", loadAddOn(), nil)
 			Expect(err).NotTo(HaveOccurred())
-			scheme := runtime.NewScheme()
-			Expect(corev1.AddToScheme(scheme)).To(Succeed())
-			Expect(appsv1.AddToScheme(scheme)).To(Succeed())
 
 			client := fake.NewFakeClientWithScheme(scheme)
 			counter := 0
@@ -168,6 +223,55 @@ var _ = Describe("BOSH DNS", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(counter).To(Equal(3))
 		})
+
+		It("resolves aliases to the instance groups of the deployment", func() {
+			config := corefile()
+			Expect(resolve(config, "auctioneer.service.cf.internal.")).To(Equal("scf-scheduler-all.default.svc.cluster.local"))
+			Expect(resolve(config, "diego-cell-1.cell.service.cf.internal.")).To(Equal("scf-diego-cell-{{ index .Match 1 }}.default.svc.cluster.local"))
+			Expect(resolve(config, "isolated-diego-cell-0.cell.service.cf.internal.")).To(BeEmpty())
+			Expect(resolve(config, "bbs.service.cf.internal.")).To(BeEmpty())
+		})
+
+		It("resolves aliases to instance groups of other BOSHDeployments in the namespace", func() {
+			config := corefile(&bdv1.BOSHDeployment{ObjectMeta: v1.ObjectMeta{Name: "cf", Namespace: "default"}})
+			Expect(resolve(config, "auctioneer.service.cf.internal.")).To(Equal("cf-scheduler-all.default.svc.cluster.local"))
+			Expect(resolve(config, "bbs.service.cf.internal.")).To(Equal("cf-diego-api-all.default.svc.cluster.local"))
+			Expect(resolve(config, "isolated-diego-cell-0.cell.service.cf.internal.")).To(Equal("cf-isolated-diego-cell-{{ index .Match 1 }}.default.svc.cluster.local"))
+		})
+
+		It("resolves BOSH native group queries to the headless service", func() {
+			config := corefile()
+			Expect(resolve(config, "q-s3.diego-cell.default.scf.bosh.")).To(Equal("scf-diego-cell.default.svc.cluster.local"))
+			Expect(resolve(config, "q-s0.scheduler.any.scf.bosh.")).To(Equal("scf-scheduler.default.svc.cluster.local"))
+			Expect(resolve(config, "q-s4.diego-cell.default.scf.bosh.")).To(Equal("scf-diego-cell-all.default.svc.cluster.local"))
+			Expect(resolve(config, "q-s1.diego-cell.default.scf.bosh.")).To(BeEmpty())
+			Expect(resolve(config, "q-s3.diego-cell.other.scf.bosh.")).To(BeEmpty())
+		})
+
+		It("resolves BOSH native instance names to the instance service", func() {
+			config := corefile()
+			Expect(resolve(config, "diego-cell-1.diego-cell.default.scf.bosh.")).To(Equal("scf-diego-cell-{{ index .Match 1 }}.default.svc.cluster.local"))
+			Expect(resolve(config, "scheduler-0.diego-cell.default.scf.bosh.")).To(BeEmpty())
+		})
+
+		It("resolves every placeholder alias target of the same domain", func() {
+			instanceGroups = append(instanceGroups, &manifest.InstanceGroup{Name: "isolated-diego-cell", Instances: 1})
+			config := corefile()
+			Expect(resolve(config, "diego-cell-0.cell.service.cf.internal.")).To(Equal("scf-diego-cell-{{ index .Match 1 }}.default.svc.cluster.local"))
+			Expect(resolve(config, "isolated-diego-cell-0.cell.service.cf.internal.")).To(Equal("scf-isolated-diego-cell-{{ index .Match 1 }}.default.svc.cluster.local"))
+		})
+
+		It("resolves BOSH native names of every instance group", func() {
+			config := corefile()
+			Expect(resolve(config, "q-s0.scheduler.default.scf.bosh.")).To(Equal("scf-scheduler.default.svc.cluster.local"))
+			Expect(resolve(config, "scheduler-0.scheduler.default.scf.bosh.")).To(Equal("scf-scheduler-{{ index .Match 1 }}.default.svc.cluster.local"))
+		})
+
+		It("forwards unknown names of the alias and native zones", func() {
+			config := corefile()
+			Expect(resolve(config, "unknown.service.cf.internal.")).To(BeEmpty())
+			Expect(resolve(config, "unknown.scf.bosh.")).To(BeEmpty())
+		})
 	})
 
 	Context("simple-dns", func() {
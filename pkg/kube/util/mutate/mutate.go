@@ -4,7 +4,9 @@ import (
 	"reflect"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
@@ -28,13 +30,17 @@ func BoshDeploymentMutateFn(boshDeployment *bdv1.BOSHDeployment) controllerutil.
 
 // QuarksStatefulSetMutateFn returns MutateFn which mutates QuarksStatefulSet including:
 // - labels, annotations
-// - spec
+// - spec, but existing replicas are kept, they are owned by the scale subresource
 func QuarksStatefulSetMutateFn(qSts *qstsv1a1.QuarksStatefulSet) controllerutil.MutateFn {
 	updated := qSts.DeepCopy()
 	return func() error {
+		replicas := qSts.Spec.Replicas
 		qSts.Labels = updated.Labels
 		qSts.Annotations = updated.Annotations
 		qSts.Spec = updated.Spec
+		if updated.Spec.Replicas != nil && replicas != nil {
+			qSts.Spec.Replicas = replicas
+		}
 		return nil
 	}
 }
@@ -127,3 +133,29 @@ func ServiceMutateFn(svc *corev1.Service) controllerutil.MutateFn {
 		return nil
 	}
 }
+
+// PodDisruptionBudgetMutateFn returns MutateFn which mutates PodDisruptionBudget including:
+// - labels, annotations
+// - spec
+func PodDisruptionBudgetMutateFn(pdb *policyv1beta1.PodDisruptionBudget) controllerutil.MutateFn {
+	updated := pdb.DeepCopy()
+	return func() error {
+		pdb.Labels = updated.Labels
+		pdb.Annotations = updated.Annotations
+		pdb.Spec = updated.Spec
+		return nil
+	}
+}
+
+// HorizontalPodAutoscalerMutateFn returns MutateFn which mutates HorizontalPodAutoscaler including:
+// - labels, annotations
+// - spec
+func HorizontalPodAutoscalerMutateFn(hpa *autoscalingv2beta2.HorizontalPodAutoscaler) controllerutil.MutateFn {
+	updated := hpa.DeepCopy()
+	return func() error {
+		hpa.Labels = updated.Labels
+		hpa.Annotations = updated.Annotations
+		hpa.Spec = updated.Spec
+		return nil
+	}
+}
@@ -8,11 +8,13 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
@@ -191,6 +193,26 @@ var _ = Describe("Mutate", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(ops).To(Equal(controllerutil.OperationResultNone))
 			})
+
+			It("keeps the replicas set by the scale subresource", func() {
+				eSts.Spec.Replicas = pointers.Int32(3)
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					switch object := object.(type) {
+					case *qstsv1a1.QuarksStatefulSet:
+						existing := eSts.DeepCopy()
+						existing.Spec.Replicas = pointers.Int32(5)
+						existing.DeepCopyInto(object)
+
+						return nil
+					}
+
+					return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+				})
+				ops, err := controllerutil.CreateOrUpdate(ctx, client, eSts, mutate.QuarksStatefulSetMutateFn(eSts))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ops).To(Equal(controllerutil.OperationResultNone))
+				Expect(*eSts.Spec.Replicas).To(Equal(int32(5)))
+			})
 		})
 	})
 
@@ -562,4 +584,73 @@ var _ = Describe("Mutate", func() {
 			})
 		})
 	})
+
+	Describe("PodDisruptionBudgetMutateFn", func() {
+		var (
+			pdb *policyv1beta1.PodDisruptionBudget
+		)
+
+		BeforeEach(func() {
+			maxUnavailable := intstr.FromInt(2)
+			pdb = &policyv1beta1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+				},
+				Spec: policyv1beta1.PodDisruptionBudgetSpec{
+					MaxUnavailable: &maxUnavailable,
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"foo": "bar",
+						},
+					},
+				},
+			}
+		})
+
+		Context("when the pod disruption budget is not found", func() {
+			It("creates the pod disruption budget", func() {
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+				})
+
+				ops, err := controllerutil.CreateOrUpdate(ctx, client, pdb, mutate.PodDisruptionBudgetMutateFn(pdb))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ops).To(Equal(controllerutil.OperationResultCreated))
+			})
+		})
+
+		Context("when the pod disruption budget is found", func() {
+			var existing *policyv1beta1.PodDisruptionBudget
+
+			BeforeEach(func() {
+				existing = pdb.DeepCopy()
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					switch object := object.(type) {
+					case *policyv1beta1.PodDisruptionBudget:
+						existing.DeepCopyInto(object)
+						return nil
+					}
+
+					return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+				})
+			})
+
+			It("updates the pod disruption budget when spec is changed", func() {
+				maxUnavailable := intstr.FromInt(1)
+				existing.Spec.MaxUnavailable = &maxUnavailable
+
+				ops, err := controllerutil.CreateOrUpdate(ctx, client, pdb, mutate.PodDisruptionBudgetMutateFn(pdb))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ops).To(Equal(controllerutil.OperationResultUpdated))
+				Expect(pdb.Spec.MaxUnavailable.IntValue()).To(Equal(2))
+			})
+
+			It("does not update the pod disruption budget when nothing is changed", func() {
+				ops, err := controllerutil.CreateOrUpdate(ctx, client, pdb, mutate.PodDisruptionBudgetMutateFn(pdb))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ops).To(Equal(controllerutil.OperationResultNone))
+			})
+		})
+	})
 })
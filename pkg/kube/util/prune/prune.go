@@ -0,0 +1,56 @@
+// Package prune removes owned resources, which are not desired anymore.
+package prune
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+)
+
+// Objects deletes the objects, or releases them from the owner if the
+// policy is orphan. It returns the pruned objects as '<kind>/<name>'.
+func Objects(ctx context.Context, client crc.Client, owner metav1.Object, policy apis.PrunePolicy, kind string, objects []apis.Object) ([]string, error) {
+	pruned := []string{}
+	for _, object := range objects {
+		var err error
+		if policy == apis.PrunePolicyOrphan {
+			err = orphan(ctx, client, owner, object)
+		} else {
+			err = client.Delete(ctx, object, crc.PropagationPolicy(metav1.DeletePropagationBackground))
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			return pruned, errors.Wrapf(err, "could not prune %s '%s'", kind, object.GetName())
+		}
+		pruned = append(pruned, fmt.Sprintf("%s/%s", kind, object.GetName()))
+	}
+	return pruned, nil
+}
+
+// orphan removes the owner references to the owner from the object
+func orphan(ctx context.Context, client crc.Client, owner metav1.Object, object apis.Object) error {
+	refs := []metav1.OwnerReference{}
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID != owner.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == len(object.GetOwnerReferences()) {
+		return nil
+	}
+	object.SetOwnerReferences(refs)
+	return client.Update(ctx, object)
+}
+
+// Verb returns the verb describing what happens to pruned objects for events
+func Verb(policy apis.PrunePolicy) string {
+	if policy == apis.PrunePolicyOrphan {
+		return "Orphaned"
+	}
+	return "Deleted"
+}
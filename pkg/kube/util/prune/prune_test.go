@@ -0,0 +1,74 @@
+package prune_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/prune"
+)
+
+var _ = Describe("Objects", func() {
+	var (
+		ctx     context.Context
+		c       client.Client
+		owner   *corev1.ConfigMap
+		service *corev1.Service
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		owner = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: "owner-uid"}}
+		service = &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{Name: "owner", UID: "owner-uid"},
+					{Name: "other", UID: "other-uid"},
+				},
+			},
+		}
+		c = fake.NewFakeClientWithScheme(scheme.Scheme, service.DeepCopy())
+	})
+
+	act := func(policy apis.PrunePolicy) []string {
+		pruned, err := prune.Objects(ctx, c, owner, policy, "Service", []apis.Object{service})
+		Expect(err).ToNot(HaveOccurred())
+		return pruned
+	}
+
+	It("deletes the objects by default", func() {
+		Expect(act("")).To(Equal([]string{"Service/foo"}))
+
+		err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "foo"}, &corev1.Service{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("ignores objects, which are gone already", func() {
+		Expect(act(apis.PrunePolicyDelete)).To(HaveLen(1))
+		Expect(act(apis.PrunePolicyDelete)).To(Equal([]string{"Service/foo"}))
+	})
+
+	It("removes the owner reference with the orphan policy", func() {
+		Expect(act(apis.PrunePolicyOrphan)).To(Equal([]string{"Service/foo"}))
+
+		orphaned := &corev1.Service{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "foo"}, orphaned)).To(Succeed())
+		Expect(orphaned.OwnerReferences).To(Equal([]metav1.OwnerReference{{Name: "other", UID: "other-uid"}}))
+	})
+
+	It("describes the policy", func() {
+		Expect(prune.Verb(apis.PrunePolicyOrphan)).To(Equal("Orphaned"))
+		Expect(prune.Verb("")).To(Equal("Deleted"))
+	})
+})
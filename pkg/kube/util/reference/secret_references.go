@@ -31,13 +31,15 @@ func GetSecretsReferencedBy(ctx context.Context, client crc.Client, object inter
 func getSecretRefFromBdpl(ctx context.Context, client crc.Client, object bdv1.BOSHDeployment) (map[string]bool, error) {
 	result := map[string]bool{}
 
-	if object.Spec.Manifest.Type == bdv1.SecretReference {
-		result[object.Spec.Manifest.Name] = true
-	}
-
-	for _, ops := range object.Spec.Ops {
-		if ops.Type == bdv1.SecretReference {
-			result[ops.Name] = true
+	for _, ref := range append([]bdv1.ResourceReference{object.Spec.Manifest}, object.Spec.Ops...) {
+		switch ref.Type {
+		case bdv1.SecretReference:
+			result[ref.Name] = true
+		case bdv1.URLReference, bdv1.GitReference:
+			// Secrets with CA bundle or credentials for the remote resource
+			if ref.SecretName != "" {
+				result[ref.SecretName] = true
+			}
 		}
 	}
 
@@ -0,0 +1,371 @@
+package withops
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/quarks-utils/pkg/ctxlog"
+)
+
+var (
+	// GitCacheDir contains a bare repository for every fetched git url and
+	// secret
+	GitCacheDir = filepath.Join(os.TempDir(), "cf-operator-git")
+
+	// GitCacheMaxAge is the time after which the cached repository of an
+	// unused git url is removed
+	GitCacheMaxAge = 24 * time.Hour
+
+	// AllowFileGitRepositories enables file:// repository URLs, e.g. for
+	// tests. They are rejected by default, since they expose the operator's
+	// file system.
+	AllowFileGitRepositories = false
+
+	// gitMutex serializes git commands, since fetches into the same cache
+	// repository would conflict
+	gitMutex sync.Mutex
+
+	commitRegexp = regexp.MustCompile("^[0-9a-f]{40}$")
+
+	// gitSchemes are the URL schemes allowed for git references. Other
+	// transports, like local paths, would expose the operator's file system
+	// or run commands.
+	gitSchemes = []string{"https", "http", "ssh"}
+
+	// gitCredentialSchemes are the URL schemes, which allow credentials
+	// from a secret. Credentials are never sent in plain text.
+	gitCredentialSchemes = []string{"https", "ssh"}
+
+	// gitProtocolConfig restricts the transports git may use, also for
+	// redirects and submodules
+	gitProtocolConfig = []string{
+		"protocol.allow=never",
+		"protocol.https.allow=always",
+		"protocol.http.allow=always",
+		"protocol.ssh.allow=always",
+	}
+
+	// gitCredentialProtocolConfig restricts the transports for fetches with
+	// credentials, which must not be sent via http after a redirect
+	gitCredentialProtocolConfig = []string{
+		"protocol.allow=never",
+		"protocol.https.allow=always",
+		"protocol.ssh.allow=always",
+	}
+)
+
+// GitFile is a file read from a git repository
+type GitFile struct {
+	Path string
+	Data []byte
+}
+
+// ResolveGitCommit fetches the ref of a git reference into the cache and
+// returns the commit it points to. Refs, which already are commit SHAs
+// present in the cache, are not fetched again. The cache is separate for
+// every secret, so a commit fetched with credentials can't be read by
+// references without them.
+func ResolveGitCommit(ctx context.Context, c client.Client, namespace string, ref bdv1.ResourceReference) (string, error) {
+	gitMutex.Lock()
+	defer gitMutex.Unlock()
+
+	return resolveGitCommit(ctx, c, namespace, ref)
+}
+
+// FetchGit returns all files of a git reference, which match its path, and
+// the commit they were read from. The path may be a glob pattern, matching
+// files are sorted by name.
+func FetchGit(ctx context.Context, c client.Client, namespace string, ref bdv1.ResourceReference) ([]GitFile, string, error) {
+	gitMutex.Lock()
+	defer gitMutex.Unlock()
+
+	if ref.Path == "" {
+		return nil, "", fmt.Errorf("missing path for git repository '%s'", ref.Name)
+	}
+
+	commit, err := resolveGitCommit(ctx, c, namespace, ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir := gitRepoDir(namespace, ref)
+	out, err := git(ctx, dir, nil, "ls-tree", "-r", "--name-only", commit)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to list files of commit '%s' in git repository '%s'", commit, ref.Name)
+	}
+
+	pattern := strings.TrimPrefix(ref.Path, "/")
+	matches := []string{}
+	for _, file := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		ok, err := path.Match(pattern, file)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "invalid path '%s' for git repository '%s'", ref.Path, ref.Name)
+		}
+		if ok {
+			matches = append(matches, file)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, "", fmt.Errorf("path '%s' doesn't match any file in commit '%s' of git repository '%s'", ref.Path, commit, ref.Name)
+	}
+	sort.Strings(matches)
+
+	files := make([]GitFile, len(matches))
+	for i, file := range matches {
+		data, err := git(ctx, dir, nil, "show", fmt.Sprintf("%s:%s", commit, file))
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to read '%s' from commit '%s' of git repository '%s'", file, commit, ref.Name)
+		}
+		files[i] = GitFile{Path: file, Data: data}
+	}
+
+	return files, commit, nil
+}
+
+// PinGitReferences resolves the refs of all git references of the
+// BOSHDeployment to commits. It returns a copy of the BOSHDeployment, which
+// references these commits, and the resolved commits for the status.
+func PinGitReferences(ctx context.Context, c client.Client, bdpl *bdv1.BOSHDeployment) (*bdv1.BOSHDeployment, []bdv1.GitReferenceStatus, error) {
+	pinned := bdpl.DeepCopy()
+	statuses := []bdv1.GitReferenceStatus{}
+
+	pin := func(ref *bdv1.ResourceReference) error {
+		if ref.Type != bdv1.GitReference {
+			return nil
+		}
+
+		commit, err := ResolveGitCommit(ctx, c, bdpl.Namespace, *ref)
+		if err != nil {
+			return err
+		}
+
+		statuses = append(statuses, bdv1.GitReferenceStatus{
+			Repository: ref.Name,
+			Ref:        ref.Ref,
+			Path:       ref.Path,
+			Commit:     commit,
+		})
+		ref.Ref = commit
+		return nil
+	}
+
+	if err := pin(&pinned.Spec.Manifest); err != nil {
+		return nil, nil, err
+	}
+	for i := range pinned.Spec.Ops {
+		if err := pin(&pinned.Spec.Ops[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return pinned, statuses, nil
+}
+
+func resolveGitCommit(ctx context.Context, c client.Client, namespace string, ref bdv1.ResourceReference) (string, error) {
+	if err := validateGitReference(ref); err != nil {
+		return "", err
+	}
+
+	pruneGitCache(ctx)
+
+	dir := gitRepoDir(namespace, ref)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", errors.Wrapf(err, "failed to create cache directory for git repository '%s'", ref.Name)
+		}
+		if _, err := git(ctx, dir, nil, "init", "--bare", "--quiet"); err != nil {
+			os.RemoveAll(dir)
+			return "", errors.Wrapf(err, "failed to initialize cache for git repository '%s'", ref.Name)
+		}
+	}
+
+	// The modification time of the cache directory marks its last use
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		return "", errors.Wrapf(err, "failed to update cache directory of git repository '%s'", ref.Name)
+	}
+
+	if commitRegexp.MatchString(ref.Ref) {
+		if _, err := git(ctx, dir, nil, "cat-file", "-e", ref.Ref+"^{commit}"); err == nil {
+			return ref.Ref, nil
+		}
+	}
+
+	config, err := gitAuthConfig(ctx, c, namespace, ref)
+	if err != nil {
+		return "", err
+	}
+
+	gitRef := ref.Ref
+	if gitRef == "" {
+		gitRef = "HEAD"
+	}
+	if _, err := git(ctx, dir, config, "fetch", "--quiet", "--force", "--", ref.Name, gitRef); err != nil {
+		return "", errors.Wrapf(err, "failed to fetch ref '%s' from git repository '%s'", gitRef, ref.Name)
+	}
+
+	out, err := git(ctx, dir, nil, "rev-parse", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve ref '%s' of git repository '%s'", gitRef, ref.Name)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// validateGitReference checks that the repository URL uses an allowed
+// scheme and that neither URL nor ref can be mistaken for git options
+func validateGitReference(ref bdv1.ResourceReference) error {
+	if strings.HasPrefix(ref.Name, "-") {
+		return fmt.Errorf("invalid git repository '%s': must not start with '-'", ref.Name)
+	}
+	if strings.HasPrefix(ref.Ref, "-") {
+		return fmt.Errorf("invalid ref '%s' for git repository '%s': must not start with '-'", ref.Ref, ref.Name)
+	}
+
+	u, err := url.Parse(ref.Name)
+	if err != nil {
+		return errors.Wrapf(err, "invalid git repository '%s'", ref.Name)
+	}
+	if u.Scheme == "file" && AllowFileGitRepositories && ref.SecretName == "" {
+		return nil
+	}
+	if !containsString(gitSchemes, u.Scheme) {
+		return fmt.Errorf("invalid git repository '%s': only %s URLs are supported", ref.Name, strings.Join(gitSchemes, ", "))
+	}
+	if u.Host == "" || strings.HasPrefix(u.Hostname(), "-") || strings.HasPrefix(u.User.Username(), "-") {
+		return fmt.Errorf("invalid git repository '%s': invalid host", ref.Name)
+	}
+	if ref.SecretName != "" && !containsString(gitCredentialSchemes, u.Scheme) {
+		return fmt.Errorf("invalid git repository '%s': secret '%s' requires %s", ref.Name, ref.SecretName, strings.Join(gitCredentialSchemes, " or "))
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// gitAuthConfig returns git config options to authenticate with the
+// credentials of the reference's secret. They are passed to git in its
+// environment, the command line of a process is readable by everyone.
+func gitAuthConfig(ctx context.Context, c client.Client, namespace string, ref bdv1.ResourceReference) ([]string, error) {
+	if ref.SecretName == "" {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Name: ref.SecretName, Namespace: namespace}, secret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve secret '%s/%s' for git repository '%s' via client.Get", namespace, ref.SecretName, ref.Name)
+	}
+
+	if token, ok := secret.Data[corev1.ServiceAccountTokenKey]; ok {
+		return []string{"http.extraHeader=Authorization: Bearer " + string(token)}, nil
+	}
+	if username, ok := secret.Data[corev1.BasicAuthUsernameKey]; ok {
+		credentials := base64.StdEncoding.EncodeToString([]byte(string(username) + ":" + string(secret.Data[corev1.BasicAuthPasswordKey])))
+		return []string{"http.extraHeader=Authorization: Basic " + credentials}, nil
+	}
+
+	return nil, nil
+}
+
+// gitRepoDir returns the cache directory for the url of a git reference.
+// References with a secret get their own cache per secret.
+func gitRepoDir(namespace string, ref bdv1.ResourceReference) string {
+	key := ref.Name
+	if ref.SecretName != "" {
+		key = fmt.Sprintf("%s\n%s/%s", ref.Name, namespace, ref.SecretName)
+	}
+	return filepath.Join(GitCacheDir, Digest([]byte(key)))
+}
+
+// pruneGitCache removes the cached repositories, which weren't used for
+// longer than GitCacheMaxAge
+func pruneGitCache(ctx context.Context) {
+	entries, err := ioutil.ReadDir(GitCacheDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || time.Since(entry.ModTime()) < GitCacheMaxAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(GitCacheDir, entry.Name())); err != nil {
+			ctxlog.Errorf(ctx, "Failed to remove unused git repository cache '%s': %v", entry.Name(), err)
+		}
+	}
+}
+
+// git runs a git command in dir and returns its stdout. The config is passed
+// in the environment, so it doesn't show up in the process list. Commands
+// with config, i.e. credentials, may not use http.
+func git(ctx context.Context, dir string, config []string, args ...string) ([]byte, error) {
+	protocolConfig := gitProtocolConfig
+	if len(config) > 0 {
+		protocolConfig = gitCredentialProtocolConfig
+	} else if AllowFileGitRepositories {
+		protocolConfig = append([]string{"protocol.file.allow=always"}, protocolConfig...)
+	}
+	cmdArgs := []string{}
+	for _, c := range protocolConfig {
+		cmdArgs = append(cmdArgs, "-c", c)
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.CommandContext(ctx, "git", cmdArgs...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_PROTOCOL_FROM_USER=0")
+	if len(config) > 0 {
+		cmd.Env = append(cmd.Env, "GIT_CONFIG_PARAMETERS="+gitConfigParameters(config))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if execErr, ok := err.(*exec.Error); ok && execErr.Err == exec.ErrNotFound {
+			return nil, errors.Wrap(err, "git references require a git binary in the operator's PATH")
+		}
+		return nil, errors.Wrapf(err, "git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// gitConfigParameters quotes config options for the GIT_CONFIG_PARAMETERS
+// environment variable, which git reads like `-c` options
+func gitConfigParameters(config []string) string {
+	quoted := make([]string, len(config))
+	for i, c := range config {
+		quoted[i] = "'" + strings.Replace(c, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,440 @@
+package withops_test
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdc "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/boshdns"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
+)
+
+var _ = Describe("Git references", func() {
+	var (
+		rootDir  string
+		repoDir  string
+		cacheDir string
+		repoURL  string
+		server   *httptest.Server
+		client   client.Client
+		ctx      context.Context
+	)
+
+	run := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repoDir
+		out, err := cmd.CombinedOutput()
+		Expect(err).ToNot(HaveOccurred(), string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	commit := func(files map[string]string) string {
+		for name, content := range files {
+			path := filepath.Join(repoDir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+		run("add", "-A")
+		run("commit", "--quiet", "-m", "update")
+		return run("rev-parse", "HEAD")
+	}
+
+	BeforeEach(func() {
+		var err error
+		rootDir, err = ioutil.TempDir("", "withops-git-repo")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir = filepath.Join(rootDir, "repo")
+		Expect(os.Mkdir(repoDir, 0755)).To(Succeed())
+		cacheDir, err = ioutil.TempDir("", "withops-git-cache")
+		Expect(err).ToNot(HaveOccurred())
+		withops.GitCacheDir = cacheDir
+
+		// Serve the repository with the smart HTTP protocol
+		execPath, err := exec.Command("git", "--exec-path").Output()
+		Expect(err).ToNot(HaveOccurred())
+		server = httptest.NewServer(&cgi.Handler{
+			Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+			Root: "/",
+			Env:  []string{"GIT_PROJECT_ROOT=" + rootDir, "GIT_HTTP_EXPORT_ALL=1"},
+		})
+
+		repoURL = server.URL + "/repo/.git"
+		ctx = context.Background()
+		client = fakeClient.NewFakeClient()
+
+		run("init", "--quiet")
+		run("config", "uploadpack.allowAnySHA1InWant", "true")
+		run("checkout", "--quiet", "-b", "main")
+		commit(map[string]string{
+			"cf-deployment.yml": `---
+name: cf
+instance_groups:
+- name: api
+  instances: 1
+`,
+			"operations/a-scale.yml": `---
+- type: replace
+  path: /instance_groups/name=api/instances
+  value: 2
+`,
+			"operations/b-scale.yml": `---
+- type: replace
+  path: /instance_groups/name=api/instances
+  value: 3
+`,
+			"README.md": "docs",
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(rootDir)
+		os.RemoveAll(cacheDir)
+	})
+
+	Describe("hostile references", func() {
+		var marker string
+
+		BeforeEach(func() {
+			marker = filepath.Join(cacheDir, "pwned")
+		})
+
+		fetch := func(name, ref string) error {
+			_, _, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: name,
+				Ref:  ref,
+				Path: "cf-deployment.yml",
+			})
+			return err
+		}
+
+		It("rejects repository URLs, which are git options", func() {
+			err := fetch("--upload-pack=touch "+marker, "main")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("must not start with '-'"))
+			Expect(marker).ToNot(BeAnExistingFile())
+		})
+
+		It("rejects refs, which are git options", func() {
+			err := fetch(repoURL, "--upload-pack=touch "+marker)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("must not start with '-'"))
+			Expect(marker).ToNot(BeAnExistingFile())
+		})
+
+		It("rejects local repositories", func() {
+			for _, name := range []string{"file://" + repoDir, repoDir, filepath.Join(rootDir, "repo", ".git")} {
+				err := fetch(name, "main")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("only https, http, ssh URLs are supported"))
+			}
+		})
+
+		It("rejects local paths even if file repositories are allowed", func() {
+			withops.AllowFileGitRepositories = true
+			defer func() { withops.AllowFileGitRepositories = false }()
+
+			err := fetch(repoDir, "main")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("only https, http, ssh URLs are supported"))
+		})
+
+		It("rejects other transports", func() {
+			for _, name := range []string{"ext::sh -c touch% " + marker, "git://example.com/repo.git", "fd::1"} {
+				Expect(fetch(name, "main")).To(HaveOccurred())
+			}
+			Expect(marker).ToNot(BeAnExistingFile())
+		})
+
+		It("rejects ssh hosts, which are ssh options", func() {
+			err := fetch("ssh://-oProxyCommand=touch%20"+marker+"/repo.git", "main")
+			Expect(err).To(HaveOccurred())
+			Expect(marker).ToNot(BeAnExistingFile())
+		})
+
+		It("does not follow redirects to other transports", func() {
+			redirect := httptest.NewServer(http.RedirectHandler("file://"+repoDir+"/.git", http.StatusFound))
+			defer redirect.Close()
+
+			err := fetch(redirect.URL+"/repo.git", "main")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("FetchGit", func() {
+		It("returns the file at the ref and its commit", func() {
+			sha := run("rev-parse", "HEAD")
+
+			files, commit, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: repoURL,
+				Ref:  "main",
+				Path: "cf-deployment.yml",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commit).To(Equal(sha))
+			Expect(files).To(HaveLen(1))
+			Expect(files[0].Path).To(Equal("cf-deployment.yml"))
+			Expect(string(files[0].Data)).To(ContainSubstring("name: cf"))
+		})
+
+		It("returns all files matching a glob in alphabetical order", func() {
+			files, _, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: repoURL,
+				Path: "operations/*.yml",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(HaveLen(2))
+			Expect(files[0].Path).To(Equal("operations/a-scale.yml"))
+			Expect(files[1].Path).To(Equal("operations/b-scale.yml"))
+		})
+
+		It("reads files from older commits", func() {
+			old := run("rev-parse", "HEAD")
+			commit(map[string]string{"cf-deployment.yml": "name: changed"})
+
+			files, commit, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: repoURL,
+				Ref:  old,
+				Path: "cf-deployment.yml",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commit).To(Equal(old))
+			Expect(string(files[0].Data)).To(ContainSubstring("name: cf"))
+		})
+
+		It("picks up new commits of a branch", func() {
+			ref := bdc.ResourceReference{Type: bdc.GitReference, Name: repoURL, Ref: "main", Path: "cf-deployment.yml"}
+			_, first, err := withops.FetchGit(ctx, client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+
+			second := commit(map[string]string{"cf-deployment.yml": "name: changed"})
+			files, commit, err := withops.FetchGit(ctx, client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commit).To(Equal(second))
+			Expect(commit).ToNot(Equal(first))
+			Expect(string(files[0].Data)).To(Equal("name: changed"))
+		})
+
+		It("fetches file repositories if they are allowed", func() {
+			withops.AllowFileGitRepositories = true
+			defer func() { withops.AllowFileGitRepositories = false }()
+			sha := run("rev-parse", "HEAD")
+
+			_, commit, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: "file://" + repoDir,
+				Ref:  "main",
+				Path: "cf-deployment.yml",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commit).To(Equal(sha))
+		})
+
+		Context("when the repository requires credentials", func() {
+			var (
+				authenticated *httptest.Server
+				ref           bdc.ResourceReference
+			)
+
+			BeforeEach(func() {
+				backend := server.Config.Handler
+				authenticated = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					username, password, ok := r.BasicAuth()
+					if !ok || username != "user" || password != "secret" {
+						w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					backend.ServeHTTP(w, r)
+				}))
+
+				caFile := filepath.Join(rootDir, "ca.crt")
+				Expect(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+					Type:  "CERTIFICATE",
+					Bytes: authenticated.Certificate().Raw,
+				}), 0644)).To(Succeed())
+				os.Setenv("GIT_SSL_CAINFO", caFile)
+
+				client = fakeClient.NewFakeClient(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "git-credentials", Namespace: "default"},
+					Data: map[string][]byte{
+						corev1.BasicAuthUsernameKey: []byte("user"),
+						corev1.BasicAuthPasswordKey: []byte("secret"),
+					},
+				})
+
+				ref = bdc.ResourceReference{
+					Type: bdc.GitReference,
+					Name: authenticated.URL + "/repo/.git",
+					Ref:  "main",
+					Path: "cf-deployment.yml",
+				}
+			})
+
+			AfterEach(func() {
+				os.Unsetenv("GIT_SSL_CAINFO")
+				authenticated.Close()
+			})
+
+			It("authenticates with the credentials of the secret", func() {
+				_, _, err := withops.FetchGit(ctx, client, "default", ref)
+				Expect(err).To(HaveOccurred())
+
+				ref.SecretName = "git-credentials"
+				_, _, err = withops.FetchGit(ctx, client, "default", ref)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not share commits fetched with credentials with references without them", func() {
+				ref.SecretName = "git-credentials"
+				_, sha, err := withops.FetchGit(ctx, client, "default", ref)
+				Expect(err).ToNot(HaveOccurred())
+
+				ref.SecretName = ""
+				ref.Ref = sha
+				_, _, err = withops.FetchGit(ctx, client, "default", ref)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("failed to fetch ref"))
+			})
+
+			It("rejects credentials for http repositories", func() {
+				ref.Name = repoURL
+				ref.SecretName = "git-credentials"
+				_, _, err := withops.FetchGit(ctx, client, "default", ref)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("requires https or ssh"))
+			})
+		})
+
+		It("removes cached repositories, which weren't used for a while", func() {
+			stale := filepath.Join(cacheDir, "stale")
+			Expect(os.Mkdir(stale, 0700)).To(Succeed())
+			old := time.Now().Add(-2 * withops.GitCacheMaxAge)
+			Expect(os.Chtimes(stale, old, old)).To(Succeed())
+
+			_, _, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: repoURL,
+				Path: "cf-deployment.yml",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stale).ToNot(BeADirectory())
+
+			entries, err := ioutil.ReadDir(cacheDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+		})
+
+		It("fails if the path doesn't match any file", func() {
+			_, _, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: repoURL,
+				Path: "ops/*.yml",
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("doesn't match any file"))
+		})
+
+		It("fails for unknown refs", func() {
+			_, _, err := withops.FetchGit(ctx, client, "default", bdc.ResourceReference{
+				Type: bdc.GitReference,
+				Name: repoURL,
+				Ref:  "not-existing",
+				Path: "cf-deployment.yml",
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to fetch ref 'not-existing'"))
+		})
+	})
+
+	Describe("PinGitReferences", func() {
+		It("replaces refs with commits and returns their status", func() {
+			sha := run("rev-parse", "HEAD")
+			bdpl := &bdc.BOSHDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cf", Namespace: "default"},
+				Spec: bdc.BOSHDeploymentSpec{
+					Manifest: bdc.ResourceReference{Type: bdc.GitReference, Name: repoURL, Ref: "main", Path: "cf-deployment.yml"},
+					Ops: []bdc.ResourceReference{
+						{Type: bdc.ConfigMapReference, Name: "ops"},
+					},
+				},
+			}
+
+			pinned, statuses, err := withops.PinGitReferences(ctx, client, bdpl)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pinned.Spec.Manifest.Ref).To(Equal(sha))
+			Expect(bdpl.Spec.Manifest.Ref).To(Equal("main"))
+			Expect(statuses).To(Equal([]bdc.GitReferenceStatus{
+				{Repository: repoURL, Ref: "main", Path: "cf-deployment.yml", Commit: sha},
+			}))
+		})
+	})
+
+	Describe("Resolver", func() {
+		var resolver *withops.Resolver
+
+		BeforeEach(func() {
+			resolver = withops.NewResolver(
+				client,
+				func() withops.Interpolator { return withops.NewInterpolator() },
+				func(_ string, _ bdm.Manifest) (withops.DomainNameService, error) {
+					return boshdns.NewSimpleDomainNameService(""), nil
+				},
+			)
+		})
+
+		It("applies the manifest and all matching ops files from git", func() {
+			bdpl := &bdc.BOSHDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cf", Namespace: "default"},
+				Spec: bdc.BOSHDeploymentSpec{
+					Manifest: bdc.ResourceReference{Type: bdc.GitReference, Name: repoURL, Path: "cf-deployment.yml"},
+					Ops: []bdc.ResourceReference{
+						{Type: bdc.GitReference, Name: repoURL, Path: "operations/*.yml"},
+					},
+				},
+			}
+
+			manifest, _, err := resolver.Manifest(bdpl, "default")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(manifest.InstanceGroups[0].Instances).To(Equal(3))
+
+			manifest, _, err = resolver.ManifestDetailed(bdpl, "default")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(manifest.InstanceGroups[0].Instances).To(Equal(3))
+		})
+
+		It("fails if the manifest path matches several files", func() {
+			bdpl := &bdc.BOSHDeployment{
+				Spec: bdc.BOSHDeploymentSpec{
+					Manifest: bdc.ResourceReference{Type: bdc.GitReference, Name: repoURL, Path: "operations/*.yml"},
+				},
+			}
+
+			_, _, err := resolver.Manifest(bdpl, "default")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("matches 2 files, expected exactly one"))
+		})
+	})
+})
@@ -3,8 +3,6 @@ package withops
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"reflect"
 	"strings"
 
@@ -54,6 +52,12 @@ func NewResolver(client client.Client, f NewInterpolatorFunc, dns NewDNSFunc) *R
 // The resulting manifest has variables interpolated and ops files applied.
 // It is the 'with-ops' manifest.
 func (r *Resolver) Manifest(bdpl *bdv1.BOSHDeployment, namespace string) (*bdm.Manifest, []string, error) {
+	return r.ManifestWithURLContents(bdpl, namespace, nil)
+}
+
+// ManifestWithURLContents returns the 'with-ops' manifest like Manifest, but
+// uses the given contents for url references instead of fetching them again.
+func (r *Resolver) ManifestWithURLContents(bdpl *bdv1.BOSHDeployment, namespace string, contents URLContents) (*bdm.Manifest, []string, error) {
 	interpolator := r.newInterpolatorFunc()
 	spec := bdpl.Spec
 	var (
@@ -61,7 +65,7 @@ func (r *Resolver) Manifest(bdpl *bdv1.BOSHDeployment, namespace string) (*bdm.M
 		err error
 	)
 
-	m, err = r.resourceData(namespace, spec.Manifest.Type, spec.Manifest.Name, bdv1.ManifestSpecName)
+	m, err = r.resourceData(namespace, spec.Manifest, bdv1.ManifestSpecName, contents)
 	if err != nil {
 		return nil, []string{}, errors.Wrapf(err, "Interpolation failed for bosh deployment %s", bdpl.GetName())
 	}
@@ -70,13 +74,15 @@ func (r *Resolver) Manifest(bdpl *bdv1.BOSHDeployment, namespace string) (*bdm.M
 	ops := spec.Ops
 
 	for _, op := range ops {
-		opsData, err := r.resourceData(namespace, op.Type, op.Name, bdv1.OpsSpecName)
+		opsData, err := r.opsData(namespace, op, contents)
 		if err != nil {
 			return nil, []string{}, errors.Wrapf(err, "Interpolation failed for bosh deployment %s", bdpl.GetName())
 		}
-		err = interpolator.BuildOps([]byte(opsData))
-		if err != nil {
-			return nil, []string{}, errors.Wrapf(err, "Interpolation failed for bosh deployment %s", bdpl.GetName())
+		for _, data := range opsData {
+			err = interpolator.BuildOps([]byte(data))
+			if err != nil {
+				return nil, []string{}, errors.Wrapf(err, "Interpolation failed for bosh deployment %s", bdpl.GetName())
+			}
 		}
 	}
 
@@ -117,7 +123,7 @@ func (r *Resolver) Manifest(bdpl *bdv1.BOSHDeployment, namespace string) (*bdm.M
 			varSecretName = names.DeploymentSecretName(names.DeploymentSecretTypeVariable, bdpl.GetName(), v)
 		}
 
-		varData, err := r.resourceData(namespace, bdv1.SecretReference, varSecretName, varKeyName)
+		varData, err := r.resourceData(namespace, bdv1.ResourceReference{Type: bdv1.SecretReference, Name: varSecretName}, varKeyName, nil)
 		if err != nil {
 			return nil, varSecrets, errors.Wrapf(err, "failed to load secret for variable '%s'", v)
 		}
@@ -151,7 +157,7 @@ func (r *Resolver) ManifestDetailed(bdpl *bdv1.BOSHDeployment, namespace string)
 		err error
 	)
 
-	m, err = r.resourceData(namespace, spec.Manifest.Type, spec.Manifest.Name, bdv1.ManifestSpecName)
+	m, err = r.resourceData(namespace, spec.Manifest, bdv1.ManifestSpecName, nil)
 	if err != nil {
 		return nil, []string{}, errors.Wrapf(err, "Interpolation failed for bosh deployment %s", bdpl.GetName())
 	}
@@ -161,20 +167,23 @@ func (r *Resolver) ManifestDetailed(bdpl *bdv1.BOSHDeployment, namespace string)
 	bytes := []byte(m)
 
 	for _, op := range ops {
-		interpolator := r.newInterpolatorFunc()
-
-		opsData, err := r.resourceData(namespace, op.Type, op.Name, bdv1.OpsSpecName)
+		opsData, err := r.opsData(namespace, op, nil)
 		if err != nil {
 			return nil, []string{}, errors.Wrapf(err, "Failed to get resource data for interpolation of bosh deployment '%s' and ops '%s'", bdpl.GetName(), op.Name)
 		}
-		err = interpolator.BuildOps([]byte(opsData))
-		if err != nil {
-			return nil, []string{}, errors.Wrapf(err, "Interpolation failed for bosh deployment '%s' and ops '%s'", bdpl.GetName(), op.Name)
-		}
 
-		bytes, err = interpolator.Interpolate(bytes)
-		if err != nil {
-			return nil, []string{}, errors.Wrapf(err, "Failed to interpolate ops '%s' for manifest '%s'", op.Name, bdpl.Name)
+		for _, data := range opsData {
+			interpolator := r.newInterpolatorFunc()
+
+			err = interpolator.BuildOps([]byte(data))
+			if err != nil {
+				return nil, []string{}, errors.Wrapf(err, "Interpolation failed for bosh deployment '%s' and ops '%s'", bdpl.GetName(), op.Name)
+			}
+
+			bytes, err = interpolator.Interpolate(bytes)
+			if err != nil {
+				return nil, []string{}, errors.Wrapf(err, "Failed to interpolate ops '%s' for manifest '%s'", op.Name, bdpl.Name)
+			}
 		}
 	}
 
@@ -207,7 +216,7 @@ func (r *Resolver) ManifestDetailed(bdpl *bdv1.BOSHDeployment, namespace string)
 			varSecretName = names.DeploymentSecretName(names.DeploymentSecretTypeVariable, bdpl.GetName(), v)
 		}
 
-		varData, err := r.resourceData(namespace, bdv1.SecretReference, varSecretName, varKeyName)
+		varData, err := r.resourceData(namespace, bdv1.ResourceReference{Type: bdv1.SecretReference, Name: varSecretName}, varKeyName, nil)
 		if err != nil {
 			return nil, varSecrets, errors.Wrapf(err, "failed to load secret for variable '%s'", v)
 		}
@@ -294,14 +303,38 @@ func (r *Resolver) replaceVarRecursive(copy, v reflect.Value, varName, varValue
 	}
 }
 
-// resourceData resolves different manifest reference types and returns the resource's data
-func (r *Resolver) resourceData(namespace string, resType bdv1.ReferenceType, name string, key string) (string, error) {
+// opsData returns the ops files of a reference. Git references can match
+// several ops files.
+func (r *Resolver) opsData(namespace string, ref bdv1.ResourceReference, contents URLContents) ([]string, error) {
+	if ref.Type != bdv1.GitReference {
+		data, err := r.resourceData(namespace, ref, bdv1.OpsSpecName, contents)
+		if err != nil {
+			return nil, err
+		}
+		return []string{data}, nil
+	}
+
+	files, _, err := FetchGit(context.TODO(), r.client, namespace, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve %s from git", bdv1.OpsSpecName)
+	}
+	data := make([]string, len(files))
+	for i, f := range files {
+		data[i] = string(f.Data)
+	}
+	return data, nil
+}
+
+// resourceData resolves different manifest reference types and returns the resource's data.
+// Url references found in contents are not fetched again.
+func (r *Resolver) resourceData(namespace string, ref bdv1.ResourceReference, key string, contents URLContents) (string, error) {
 	var (
 		data string
 		ok   bool
 	)
 
-	switch resType {
+	name := ref.Name
+	switch ref.Type {
 	case bdv1.ConfigMapReference:
 		opsConfig := &corev1.ConfigMap{}
 		err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, opsConfig)
@@ -324,15 +357,24 @@ func (r *Resolver) resourceData(namespace string, resType bdv1.ReferenceType, na
 		}
 		data = string(encodedData)
 	case bdv1.URLReference:
-		httpResponse, err := http.Get(name)
-		if err != nil {
-			return data, errors.Wrapf(err, "failed to resolve %s from url '%s' via http.Get", key, name)
+		if body, ok := contents[ref.Name]; ok {
+			data = string(body)
+			break
 		}
-		body, err := ioutil.ReadAll(httpResponse.Body)
+		body, err := FetchURL(context.TODO(), r.client, namespace, ref)
 		if err != nil {
-			return data, errors.Wrapf(err, "failed to read %s response body '%s' via ioutil", key, name)
+			return data, errors.Wrapf(err, "failed to resolve %s from url", key)
 		}
 		data = string(body)
+	case bdv1.GitReference:
+		files, _, err := FetchGit(context.TODO(), r.client, namespace, ref)
+		if err != nil {
+			return data, errors.Wrapf(err, "failed to resolve %s from git", key)
+		}
+		if len(files) != 1 {
+			return data, fmt.Errorf("path '%s' of git repository '%s' matches %d files, expected exactly one", ref.Path, name, len(files))
+		}
+		data = string(files[0].Data)
 	default:
 		return data, fmt.Errorf("unrecognized %s ref type %s", key, name)
 	}
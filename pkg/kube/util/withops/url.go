@@ -0,0 +1,188 @@
+package withops
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+)
+
+const (
+	// urlFetchTimeout limits the time for fetching a single url resource
+	urlFetchTimeout = 30 * time.Second
+	// maxURLContentSize limits the size of a single url resource
+	maxURLContentSize = 10 * 1024 * 1024
+)
+
+// URLContents are the fetched contents of url references by url
+type URLContents map[string][]byte
+
+// Digests returns the digests of the contents by url
+func (c URLContents) Digests() map[string]string {
+	digests := map[string]string{}
+	for name, data := range c {
+		digests[name] = Digest(data)
+	}
+	return digests
+}
+
+// FetchURL fetches the content of an url resource reference via HTTP(S).
+// The CA bundle and credentials are taken from the reference and its secret,
+// credentials are only sent via https. If the reference pins a SHA256
+// checksum, the content is verified against it.
+func FetchURL(ctx context.Context, c client.Client, namespace string, ref bdv1.ResourceReference) ([]byte, error) {
+	u, err := url.Parse(ref.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse url '%s'", ref.Name)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme '%s' in url '%s', expected http or https", u.Scheme, ref.Name)
+	}
+	if ref.SecretName != "" && u.Scheme != "https" {
+		return nil, fmt.Errorf("secret '%s' of url '%s' requires https", ref.SecretName, ref.Name)
+	}
+
+	caBundle := ref.CABundle
+	var secret *corev1.Secret
+	if ref.SecretName != "" {
+		secret = &corev1.Secret{}
+		err := c.Get(ctx, types.NamespacedName{Name: ref.SecretName, Namespace: namespace}, secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to retrieve secret '%s/%s' for url '%s' via client.Get", namespace, ref.SecretName, ref.Name)
+		}
+		if ca, ok := secret.Data[corev1.ServiceAccountRootCAKey]; ok {
+			caBundle = caBundle + "\n" + string(ca)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if strings.TrimSpace(caBundle) != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caBundle)) {
+			return nil, fmt.Errorf("failed to parse CA bundle for url '%s'", ref.Name)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: urlFetchTimeout}
+
+	req, err := http.NewRequest(http.MethodGet, ref.Name, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for url '%s'", ref.Name)
+	}
+	req = req.WithContext(ctx)
+	if secret != nil {
+		if token, ok := secret.Data[corev1.ServiceAccountTokenKey]; ok {
+			req.Header.Set("Authorization", "Bearer "+string(token))
+		} else if username, ok := secret.Data[corev1.BasicAuthUsernameKey]; ok {
+			req.SetBasicAuth(string(username), string(secret.Data[corev1.BasicAuthPasswordKey]))
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get url '%s'", ref.Name)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get url '%s': unexpected response status '%s'", ref.Name, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxURLContentSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read response body of url '%s'", ref.Name)
+	}
+	if len(body) > maxURLContentSize {
+		return nil, fmt.Errorf("content of url '%s' exceeds %d bytes", ref.Name, maxURLContentSize)
+	}
+
+	if ref.SHA256 != "" {
+		if sum := Digest(body); !strings.EqualFold(sum, ref.SHA256) {
+			return nil, fmt.Errorf("checksum mismatch for url '%s': expected sha256 '%s', got '%s'", ref.Name, ref.SHA256, sum)
+		}
+	}
+
+	return body, nil
+}
+
+// Digest returns the hex encoded SHA256 checksum of the data
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// IsPolledURL returns true for url references with a poll interval and
+// without a pinned checksum
+func IsPolledURL(ref bdv1.ResourceReference) bool {
+	return ref.Type == bdv1.URLReference &&
+		ref.SHA256 == "" &&
+		ref.PollInterval != nil &&
+		ref.PollInterval.Duration > 0
+}
+
+// PinURLReferences fetches the content of all polled url references of the
+// BOSHDeployment and pins their checksum. It returns a copy of the
+// BOSHDeployment, which references the fetched content, and the fetched
+// contents, which have to be used for resolving the manifest.
+func PinURLReferences(ctx context.Context, c client.Client, bdpl *bdv1.BOSHDeployment) (*bdv1.BOSHDeployment, URLContents, error) {
+	pinned := bdpl.DeepCopy()
+	contents := URLContents{}
+
+	pin := func(ref *bdv1.ResourceReference) error {
+		if !IsPolledURL(*ref) {
+			return nil
+		}
+
+		data, err := FetchURL(ctx, c, bdpl.Namespace, *ref)
+		if err != nil {
+			return err
+		}
+
+		contents[ref.Name] = data
+		ref.SHA256 = Digest(data)
+		return nil
+	}
+
+	if err := pin(&pinned.Spec.Manifest); err != nil {
+		return nil, nil, err
+	}
+	for i := range pinned.Spec.Ops {
+		if err := pin(&pinned.Spec.Ops[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return pinned, contents, nil
+}
+
+// RenderedURLDigests returns the digests of the polled urls, which were used
+// for the last render of the BOSHDeployment
+func RenderedURLDigests(bdpl *bdv1.BOSHDeployment) map[string]string {
+	digests := map[string]string{}
+	value, ok := bdpl.GetAnnotations()[bdv1.AnnotationURLDigests]
+	if !ok {
+		return digests
+	}
+	// An invalid annotation is treated like a missing one
+	if err := json.Unmarshal([]byte(value), &digests); err != nil {
+		return map[string]string{}
+	}
+	return digests
+}
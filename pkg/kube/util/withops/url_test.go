@@ -0,0 +1,257 @@
+package withops_test
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeClient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	bdc "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/withops"
+)
+
+var _ = Describe("FetchURL", func() {
+	const (
+		content = "instance_groups: []"
+		// sha256 of content
+		digest = "da6fda72d0393902d69c4f1f38b92b2b26c824612208e60dcc5fb539593da5e7"
+	)
+
+	var (
+		server *ghttp.Server
+		client client.Client
+		ref    bdc.ResourceReference
+	)
+
+	BeforeEach(func() {
+		client = fakeClient.NewFakeClient(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "basic-auth", Namespace: "default"},
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("admin"),
+					corev1.BasicAuthPasswordKey: []byte("secret"),
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default"},
+				Data: map[string][]byte{
+					corev1.ServiceAccountTokenKey: []byte("foo"),
+				},
+			},
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when using http", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			ref = bdc.ResourceReference{Type: bdc.URLReference, Name: server.URL() + "/manifest.yml"}
+		})
+
+		It("returns the content", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, content))
+
+			data, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(content))
+		})
+
+		It("fails on unexpected response status", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound, ""))
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unexpected response status '404 Not Found'"))
+		})
+
+		It("fails on unsupported schemes", func() {
+			ref.Name = "ftp://example.com/manifest.yml"
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unsupported scheme 'ftp'"))
+		})
+
+		It("verifies a pinned checksum", func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, content),
+				ghttp.RespondWith(http.StatusOK, "changed"),
+			)
+			ref.SHA256 = withops.Digest([]byte(content))
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("checksum mismatch"))
+		})
+
+		It("does not send credentials via http", func() {
+			ref.SecretName = "basic-auth"
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("requires https"))
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+
+		It("fails if the content is too large", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, strings.Repeat("a", 10*1024*1024+1)))
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exceeds 10485760 bytes"))
+		})
+	})
+
+	Context("when using https", func() {
+		var caBundle string
+
+		BeforeEach(func() {
+			server = ghttp.NewTLSServer()
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, content))
+			ref = bdc.ResourceReference{Type: bdc.URLReference, Name: server.URL() + "/manifest.yml"}
+
+			caBundle = string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: server.HTTPTestServer.Certificate().Raw,
+			}))
+		})
+
+		It("fails for unknown certificate authorities", func() {
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("certificate"))
+		})
+
+		It("trusts the CA bundle from the reference", func() {
+			ref.CABundle = caBundle
+
+			data, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(content))
+		})
+
+		It("trusts the CA bundle from the secret", func() {
+			Expect(client.Create(context.Background(), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "default"},
+				Data:       map[string][]byte{corev1.ServiceAccountRootCAKey: []byte(caBundle)},
+			})).To(Succeed())
+			ref.SecretName = "ca"
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("uses basic auth credentials from the secret", func() {
+			server.SetHandler(0, ghttp.CombineHandlers(
+				ghttp.VerifyBasicAuth("admin", "secret"),
+				ghttp.RespondWith(http.StatusOK, content),
+			))
+			ref.CABundle = caBundle
+			ref.SecretName = "basic-auth"
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("uses a bearer token from the secret", func() {
+			server.SetHandler(0, ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "Bearer foo"),
+				ghttp.RespondWith(http.StatusOK, content),
+			))
+			ref.CABundle = caBundle
+			ref.SecretName = "token"
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("fails if the secret does not exist", func() {
+			ref.SecretName = "not-existing"
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to retrieve secret 'default/not-existing'"))
+		})
+
+		It("fails for an invalid CA bundle", func() {
+			ref.CABundle = "invalid"
+
+			_, err := withops.FetchURL(context.Background(), client, "default", ref)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to parse CA bundle"))
+		})
+	})
+
+	Describe("Digest", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+		})
+
+		It("returns the hex encoded sha256 checksum", func() {
+			Expect(withops.Digest([]byte(content))).To(Equal(digest))
+		})
+	})
+
+	Describe("PinURLReferences", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			server.RouteToHandler("GET", "/manifest.yml", ghttp.RespondWith(http.StatusOK, content))
+		})
+
+		It("pins the checksum of polled urls and returns their contents", func() {
+			bdpl := &bdc.BOSHDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec: bdc.BOSHDeploymentSpec{
+					Manifest: bdc.ResourceReference{
+						Type:         bdc.URLReference,
+						Name:         server.URL() + "/manifest.yml",
+						PollInterval: &metav1.Duration{Duration: time.Minute},
+					},
+					Ops: []bdc.ResourceReference{
+						{Type: bdc.URLReference, Name: server.URL() + "/ops.yml"},
+					},
+				},
+			}
+
+			pinned, contents, err := withops.PinURLReferences(context.Background(), client, bdpl)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(Equal(withops.URLContents{server.URL() + "/manifest.yml": []byte(content)}))
+			Expect(contents.Digests()).To(Equal(map[string]string{server.URL() + "/manifest.yml": digest}))
+			Expect(pinned.Spec.Manifest.SHA256).To(Equal(digest))
+			Expect(pinned.Spec.Ops[0].SHA256).To(BeEmpty())
+			Expect(bdpl.Spec.Manifest.SHA256).To(BeEmpty())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Describe("RenderedURLDigests", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+		})
+
+		It("returns the digests recorded in the annotation", func() {
+			bdpl := &bdc.BOSHDeployment{}
+			bdpl.SetAnnotations(map[string]string{bdc.AnnotationURLDigests: `{"http://example.com/manifest.yml":"` + digest + `"}`})
+			Expect(withops.RenderedURLDigests(bdpl)).To(Equal(map[string]string{"http://example.com/manifest.yml": digest}))
+
+			bdpl.SetAnnotations(map[string]string{bdc.AnnotationURLDigests: "invalid"})
+			Expect(withops.RenderedURLDigests(bdpl)).To(BeEmpty())
+		})
+	})
+})
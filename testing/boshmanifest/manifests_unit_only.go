@@ -2415,6 +2415,8 @@ releases:
     version: 36.g03b4653-30.80-7.0.0_316.gcf9fe4a7
 update:
   serial: false
+  canaries: 2
+  max_in_flight: 25%
   canary_watch_time: 20000-1200000
   update_watch_time: 20000-1200000
 instance_groups:
@@ -2445,6 +2447,8 @@ instance_groups:
           internal: 1338
 - name: bpm3
   update:
+    canaries: 0
+    max_in_flight: "1"
     canary_watch_time: 10000-9900000
     update_watch_time: 10000-9900000
   jobs:
@@ -2727,4 +2731,10 @@ instance_groups:
               command:
               - find
               - "*"
+          grpc-process:
+            periodSeconds: 5
+            failureThreshold: 3
+            grpcHealthCheck:
+              port: 9090
+              service: db
 `
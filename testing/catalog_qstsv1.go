@@ -27,12 +27,14 @@ func (c *Catalog) QstsWithProbeSinglePod(name string, cmd []string) qstsv1a1.Qua
 		},
 		Spec: qstsv1a1.QuarksStatefulSetSpec{
 			Template: c.DefaultStatefulSet(name),
-			ActivePassiveProbes: map[string]v1.Probe{
-				"busybox": v1.Probe{
-					PeriodSeconds: 2,
-					Handler: v1.Handler{
-						Exec: &v1.ExecAction{
-							Command: cmd,
+			ActivePassiveProbes: map[string]qstsv1a1.ActivePassiveProbe{
+				"busybox": qstsv1a1.ActivePassiveProbe{
+					Probe: v1.Probe{
+						PeriodSeconds: 2,
+						Handler: v1.Handler{
+							Exec: &v1.ExecAction{
+								Command: cmd,
+							},
 						},
 					},
 				}},
@@ -48,12 +50,14 @@ func (c *Catalog) QstsWithActiveSinglePod(name string, cmd []string) qstsv1a1.Qu
 		},
 		Spec: qstsv1a1.QuarksStatefulSetSpec{
 			Template: c.DefaultStatefulSetWithActiveSinglePod(name),
-			ActivePassiveProbes: map[string]v1.Probe{
-				"busybox": v1.Probe{
-					PeriodSeconds: 2,
-					Handler: v1.Handler{
-						Exec: &v1.ExecAction{
-							Command: cmd,
+			ActivePassiveProbes: map[string]qstsv1a1.ActivePassiveProbe{
+				"busybox": qstsv1a1.ActivePassiveProbe{
+					Probe: v1.Probe{
+						PeriodSeconds: 2,
+						Handler: v1.Handler{
+							Exec: &v1.ExecAction{
+								Command: cmd,
+							},
 						},
 					},
 				}},
@@ -69,11 +73,13 @@ func (c *Catalog) QstsWithoutProbeMultiplePods(name string, cmd []string) qstsv1
 		},
 		Spec: qstsv1a1.QuarksStatefulSetSpec{
 			Template: c.DefaultStatefulSetWithReplicasN(name),
-			ActivePassiveProbes: map[string]v1.Probe{
-				"busybox": v1.Probe{
-					Handler: v1.Handler{
-						Exec: &v1.ExecAction{
-							Command: cmd,
+			ActivePassiveProbes: map[string]qstsv1a1.ActivePassiveProbe{
+				"busybox": qstsv1a1.ActivePassiveProbe{
+					Probe: v1.Probe{
+						Handler: v1.Handler{
+							Exec: &v1.ExecAction{
+								Command: cmd,
+							},
 						},
 					},
 				}},
@@ -89,12 +95,14 @@ func (c *Catalog) QstsWithProbeMultiplePods(name string, cmd []string) qstsv1a1.
 		},
 		Spec: qstsv1a1.QuarksStatefulSetSpec{
 			Template: c.DefaultStatefulSetWithReplicasN(name),
-			ActivePassiveProbes: map[string]v1.Probe{
-				"busybox": v1.Probe{
-					PeriodSeconds: 2,
-					Handler: v1.Handler{
-						Exec: &v1.ExecAction{
-							Command: cmd,
+			ActivePassiveProbes: map[string]qstsv1a1.ActivePassiveProbe{
+				"busybox": qstsv1a1.ActivePassiveProbe{
+					Probe: v1.Probe{
+						PeriodSeconds: 2,
+						Handler: v1.Handler{
+							Exec: &v1.ExecAction{
+								Command: cmd,
+							},
 						},
 					},
 				}},